@@ -0,0 +1,873 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/google/uuid"
+)
+
+type Admin_RebuildIndexesResponse struct {
+	Status  commonapi.FdoConfApiStatus `json:"status"`
+	Scanned int                        `json:"scanned"`
+	Indexed int                        `json:"indexed"`
+}
+
+type Admin_ResponseDelayPayload struct {
+	Cmd     fdoshared.FdoCmd `json:"cmd"`
+	DelayMs uint32           `json:"delayMs"`
+}
+
+type Admin_ResponseDelaysResponse struct {
+	Status commonapi.FdoConfApiStatus   `json:"status"`
+	Delays []Admin_ResponseDelayPayload `json:"delays"`
+}
+
+type Admin_ServiceInfoSummary struct {
+	Key  fdoshared.SIM_ID `json:"key"`
+	Size int              `json:"size"`
+}
+
+// Admin_SessionStateResponse is decoded DO session state. Key material and private keys are never included
+type Admin_SessionStateResponse struct {
+	Status    commonapi.FdoConfApiStatus `json:"status"`
+	SessionId string                     `json:"sessionId"`
+	Guid      string                     `json:"guid"`
+	Protocol  fdoshared.FdoToProtocol    `json:"protocol"`
+	PrevCMD   fdoshared.FdoCmd           `json:"prevCmd"`
+
+	KexSuite        fdoshared.KexSuiteName    `json:"kexSuite"`
+	CipherSuite     fdoshared.CipherSuiteName `json:"cipherSuite"`
+	EASgType        fdoshared.DeviceSgType    `json:"eaSgType"`
+	PublicKeyType   fdoshared.FdoPkType       `json:"publicKeyType"`
+	SignatureSgType fdoshared.DeviceSgType    `json:"signatureSgType"`
+
+	NonceTO2ProveOV60 string `json:"nonceTO2ProveOV60"`
+	NonceTO2ProveDv61 string `json:"nonceTO2ProveDv61"`
+	NonceTO2SetupDv64 string `json:"nonceTO2SetupDv64"`
+
+	NumOVEntries       uint8   `json:"numOVEntries"`
+	RequestedOVEntries []uint8 `json:"requestedOVEntries"`
+
+	MaxDeviceServiceInfoSz                  uint16                     `json:"maxDeviceServiceInfoSz"`
+	ServiceInfoMsgNo                        uint8                      `json:"serviceInfoMsgNo"`
+	OwnerServiceInfoIsMoreServiceInfoIsTrue bool                       `json:"ownerServiceInfoIsMoreServiceInfoIsTrue"`
+	DeviceSIMs                              []Admin_ServiceInfoSummary `json:"deviceSIMs"`
+	OwnerSIMs                               []Admin_ServiceInfoSummary `json:"ownerSIMs"`
+	OwnerSIMsSendCounter                    uint16                     `json:"ownerSIMsSendCounter"`
+	OwnerSIMsFinishedSending                bool                       `json:"ownerSIMsFinishedSending"`
+	PendingModuleActivations                []string                   `json:"pendingModuleActivations"`
+
+	HasReplacementCredential bool `json:"hasReplacementCredential"`
+}
+
+type Admin_IdentityPayload struct {
+	Role        fdoshared.ServerIdentityRole `json:"role"`
+	Certificate string                       `json:"certificate"`
+	PrivateKey  string                       `json:"privateKey"`
+}
+
+type Admin_IdentityInfo struct {
+	Role     fdoshared.ServerIdentityRole `json:"role"`
+	Subject  string                       `json:"subject"`
+	Issuer   string                       `json:"issuer"`
+	NotAfter time.Time                    `json:"notAfter"`
+	SgType   fdoshared.DeviceSgType       `json:"sgType"`
+	ChainLen int                          `json:"chainLen"`
+}
+
+// Admin_IdentitiesResponse lists imported identities. Private keys are never included
+type Admin_IdentitiesResponse struct {
+	Status     commonapi.FdoConfApiStatus `json:"status"`
+	Identities []Admin_IdentityInfo       `json:"identities"`
+}
+
+type Admin_VoucherInfo struct {
+	Guid         string `json:"guid"`
+	NumOVEntries int    `json:"numOVEntries"`
+	// Unix seconds. Omitted for vouchers stored before creation time was recorded
+	CreatedAt         int64  `json:"createdAt,omitempty"`
+	DeviceCertSubject string `json:"deviceCertSubject,omitempty"`
+}
+
+type Admin_VouchersResponse struct {
+	Status   commonapi.FdoConfApiStatus `json:"status"`
+	Vouchers []Admin_VoucherInfo        `json:"vouchers"`
+	// Pass as cursor to get the next page. Omitted on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+const DEFAULT_VOUCHER_LIST_LIMIT int = 50
+
+const MAX_BULK_VOUCHERS int = 1000
+const MAX_BULK_VOUCHER_OV_ENTRIES int = 32
+
+type Admin_GenerateVouchersPayload struct {
+	Count   int    `json:"count"`
+	KeyType string `json:"keyType"`
+	// Random when 0
+	NumOVEntries int `json:"numOVEntries"`
+}
+
+// Admin_GenerateVouchersProgress is streamed as event after each voucher. Last event has Done set, and all GUIDs
+type Admin_GenerateVouchersProgress struct {
+	Generated int      `json:"generated"`
+	Failed    int      `json:"failed"`
+	Total     int      `json:"total"`
+	Guid      string   `json:"guid,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Done      bool     `json:"done,omitempty"`
+	Guids     []string `json:"guids,omitempty"`
+}
+
+// Device attestation and owner keys of bulk vouchers. Device attestation does not support RSA, so RSA devices attest with SECP256R1
+var bulkVoucherKeyTypes = map[string]struct {
+	DeviceSgType fdoshared.DeviceSgType
+	OwnerSgType  fdoshared.DeviceSgType
+}{
+	"EC256":   {fdoshared.StSECP256R1, fdoshared.StSECP256R1},
+	"EC384":   {fdoshared.StSECP384R1, fdoshared.StSECP384R1},
+	"RSA":     {fdoshared.StSECP256R1, fdoshared.StRSA2048},
+	"Ed25519": {fdoshared.StED25519, fdoshared.StED25519},
+}
+
+type AdminAPI struct {
+	ListenerDB  *testdbs.ListenerTestDB
+	DelayDB     *testdbs.ResponseDelayDB
+	DOSessionDB *dodbs.SessionDB
+	IdentityDB  *dodbs.IdentityDB
+	CaptureDB   *testdbs.CaptureDB
+	DOVoucherDB *dodbs.VoucherDB
+	UserDB      *dbs.UserTestDB
+	SessionDB   *dbs.SessionDB
+	Ctx         context.Context
+}
+
+// checkAdminToken compares Authorization bearer with ADMIN_TOKEN. Admin API is disabled when token is not set
+func (h *AdminAPI) checkAdminToken(w http.ResponseWriter, r *http.Request) bool {
+	adminToken, _ := h.Ctx.Value(fdoshared.CFG_ENV_ADMIN_TOKEN).(string)
+	if adminToken == "" {
+		commonapi.RespondError(w, "Admin API is disabled!", http.StatusForbidden)
+		return false
+	}
+
+	receivedToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(receivedToken), []byte(adminToken)) != 1 {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// RebuildIndexes re-creates secondary indexes from primary entries. Idempotent
+func (h *AdminAPI) RebuildIndexes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkAdminToken(w, r) {
+		return
+	}
+
+	scanned, indexed, err := h.ListenerDB.RebuildIndexes()
+	if err != nil {
+		log.Println("Failed to rebuild indexes. " + err.Error())
+		commonapi.RespondError(w, "Failed to rebuild indexes! "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: admin rebuilt indexes. %d entries scanned, %d mappings written", scanned, indexed)
+
+	commonapi.RespondSuccessStruct(w, Admin_RebuildIndexesResponse{
+		Status:  commonapi.FdoApiStatus_OK,
+		Scanned: scanned,
+		Indexed: indexed,
+	})
+}
+
+func (h *AdminAPI) respondResponseDelays(w http.ResponseWriter) {
+	delays, err := h.DelayDB.Get()
+	if err != nil {
+		log.Println("Failed to read response delays. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	delaysResp := Admin_ResponseDelaysResponse{
+		Status: commonapi.FdoApiStatus_OK,
+		Delays: []Admin_ResponseDelayPayload{},
+	}
+
+	for cmd, delayMs := range delays {
+		delaysResp.Delays = append(delaysResp.Delays, Admin_ResponseDelayPayload{Cmd: cmd, DelayMs: delayMs})
+	}
+
+	sort.Slice(delaysResp.Delays, func(i, j int) bool {
+		return delaysResp.Delays[i].Cmd < delaysResp.Delays[j].Cmd
+	})
+
+	commonapi.RespondSuccessStruct(w, delaysResp)
+}
+
+// ResponseDelays lists, or with POST sets, artificial delays of RV and DO responses per message. Zero delay removes it
+func (h *AdminAPI) ResponseDelays(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkAdminToken(w, r) {
+		return
+	}
+
+	if r.Method == "GET" {
+		h.respondResponseDelays(w)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var delayReq Admin_ResponseDelayPayload
+	err = json.Unmarshal(bodyBytes, &delayReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	err = h.DelayDB.Set(delayReq.Cmd, delayReq.DelayMs)
+	if err != nil {
+		commonapi.RespondError(w, "Failed to set delay! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("AUDIT: admin set %dms response delay for message %d", delayReq.DelayMs, delayReq.Cmd)
+
+	h.respondResponseDelays(w)
+}
+
+func summarizeServiceInfo(sims []fdoshared.ServiceInfoKV) []Admin_ServiceInfoSummary {
+	summary := []Admin_ServiceInfoSummary{}
+	for _, sim := range sims {
+		summary = append(summary, Admin_ServiceInfoSummary{
+			Key:  sim.ServiceInfoKey,
+			Size: len(sim.ServiceInfoVal),
+		})
+	}
+
+	return summary
+}
+
+// SessionState dumps decoded state of DO TO2 session, found by sessionId or by device guid, for debugging stuck flows
+func (h *AdminAPI) SessionState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkAdminToken(w, r) {
+		return
+	}
+
+	sessionId := r.URL.Query().Get("sessionId")
+	guidStr := r.URL.Query().Get("guid")
+
+	if len(sessionId) == 0 && len(guidStr) == 0 {
+		commonapi.RespondError(w, "Missing sessionId or guid!", http.StatusBadRequest)
+		return
+	}
+
+	if len(sessionId) == 0 {
+		guid, err := fdoshared.ParseFdoGuid(guidStr)
+		if err != nil {
+			commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		activeSessionId, err := h.DOSessionDB.GetActiveSessionId(guid)
+		if err != nil {
+			log.Println("Failed to find active session. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if activeSessionId == nil {
+			commonapi.RespondError(w, "Session not found!", http.StatusNotFound)
+			return
+		}
+
+		sessionId = string(activeSessionId)
+	}
+
+	session, err := h.DOSessionDB.GetSessionEntry([]byte(sessionId))
+	if err != nil {
+		log.Println("Failed to read session. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if session == nil {
+		commonapi.RespondError(w, "Session not found!", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("AUDIT: admin inspected state of session %s, device %s", sessionId, hex.EncodeToString(session.Guid[:]))
+
+	commonapi.RespondSuccessStruct(w, Admin_SessionStateResponse{
+		Status:    commonapi.FdoApiStatus_OK,
+		SessionId: sessionId,
+		Guid:      hex.EncodeToString(session.Guid[:]),
+		Protocol:  session.Protocol,
+		PrevCMD:   session.PrevCMD,
+
+		KexSuite:        session.KexSuiteName,
+		CipherSuite:     session.CipherSuiteName,
+		EASgType:        session.EASigInfo.SgType,
+		PublicKeyType:   session.PublicKeyType,
+		SignatureSgType: session.SignatureSgType,
+
+		NonceTO2ProveOV60: hex.EncodeToString(session.NonceTO2ProveOV60[:]),
+		NonceTO2ProveDv61: hex.EncodeToString(session.NonceTO2ProveDv61[:]),
+		NonceTO2SetupDv64: hex.EncodeToString(session.NonceTO2SetupDv64[:]),
+
+		NumOVEntries:       session.NumOVEntries,
+		RequestedOVEntries: session.RequestedOVEntries,
+
+		MaxDeviceServiceInfoSz:                  session.MaxDeviceServiceInfoSz,
+		ServiceInfoMsgNo:                        session.ServiceInfoMsgNo,
+		OwnerServiceInfoIsMoreServiceInfoIsTrue: session.OwnerServiceInfoIsMoreServiceInfoIsTrue,
+		DeviceSIMs:                              summarizeServiceInfo(session.DeviceSIMs),
+		OwnerSIMs:                               summarizeServiceInfo(session.OwnerSIMs),
+		OwnerSIMsSendCounter:                    session.OwnerSIMsSendCounter,
+		OwnerSIMsFinishedSending:                session.OwnerSIMsFinishedSending,
+		PendingModuleActivations:                session.PendingModuleActivations,
+
+		HasReplacementCredential: session.ReplacementCredential != nil,
+	})
+}
+
+// StreamLogs streams live server log lines mentioning device guid, its active DO session, sessionId or runId, as server-sent events.
+// Streamed lines follow LOG_REDACT_PII
+func (h *AdminAPI) StreamLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkAdminToken(w, r) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		commonapi.RespondError(w, "Streaming is not supported!", http.StatusInternalServerError)
+		return
+	}
+
+	// Log lines carry hash of the session token, never the token itself
+	correlationValues := []string{r.URL.Query().Get("runId")}
+	if sessionId := r.URL.Query().Get("sessionId"); len(sessionId) != 0 {
+		correlationValues = append(correlationValues, fdoshared.TokenCorrelationID(sessionId))
+	}
+
+	guidStr := r.URL.Query().Get("guid")
+	if len(guidStr) != 0 {
+		guid, err := fdoshared.ParseFdoGuid(guidStr)
+		if err != nil {
+			commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		correlationValues = append(correlationValues, hex.EncodeToString(guid[:]), uuid.UUID(guid).String())
+
+		activeSessionId, err := h.DOSessionDB.GetActiveSessionId(guid)
+		if err != nil {
+			log.Println("Failed to find active session. " + err.Error())
+		}
+
+		if activeSessionId != nil {
+			correlationValues = append(correlationValues, fdoshared.TokenCorrelationID(string(activeSessionId)))
+		}
+	}
+
+	if strings.Join(correlationValues, "") == "" {
+		commonapi.RespondError(w, "Missing guid, sessionId or runId!", http.StatusBadRequest)
+		return
+	}
+
+	// Lines below LOG_LEVEL are never logged, so level can only raise it
+	minLevel := fdoshared.MinLogLevel
+	if levelStr := r.URL.Query().Get("level"); len(levelStr) != 0 {
+		logLevel, err := fdoshared.ParseLogLevel(levelStr)
+		if err != nil {
+			commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if logLevel > minLevel {
+			minLevel = logLevel
+		}
+	}
+
+	log.Printf("AUDIT: admin started log stream for guid %s, session %s, run %s", guidStr, fdoshared.TokenCorrelationID(r.URL.Query().Get("sessionId")), r.URL.Query().Get("runId"))
+
+	stream := commonapi.Logs.Subscribe(commonapi.NewCorrelationFilter(correlationValues, minLevel))
+	defer commonapi.Logs.Unsubscribe(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-stream.Lines:
+			for _, subLine := range strings.Split(line, "\n") {
+				fmt.Fprintf(w, "data: %s\n", subLine)
+			}
+			fmt.Fprint(w, "\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *AdminAPI) respondIdentities(w http.ResponseWriter) {
+	identitiesResp := Admin_IdentitiesResponse{
+		Status:     commonapi.FdoApiStatus_OK,
+		Identities: []Admin_IdentityInfo{},
+	}
+
+	for _, role := range fdoshared.ServerIdentityRoles {
+		identity, err := h.IdentityDB.Get(role)
+		if err != nil {
+			log.Println("Failed to read identity. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if identity == nil {
+			continue
+		}
+
+		leafCert, err := identity.GetLeafCertificate()
+		if err != nil {
+			log.Println("Failed to decode identity certificate. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		identitiesResp.Identities = append(identitiesResp.Identities, Admin_IdentityInfo{
+			Role:     role,
+			Subject:  leafCert.Subject.String(),
+			Issuer:   leafCert.Issuer.String(),
+			NotAfter: leafCert.NotAfter,
+			SgType:   identity.SgType,
+			ChainLen: len(identity.CertificateChain),
+		})
+	}
+
+	commonapi.RespondSuccessStruct(w, identitiesResp)
+}
+
+// Identities lists imported owner and RV identities. POST imports certificate chain and key of a role, DELETE with ?role= reverts it to self-generated keys
+func (h *AdminAPI) Identities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" && r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkAdminToken(w, r) {
+		return
+	}
+
+	if r.Method == "GET" {
+		h.respondIdentities(w)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		role := fdoshared.ServerIdentityRole(r.URL.Query().Get("role"))
+		if !isIdentityRole(role) {
+			commonapi.RespondError(w, "Unknown role!", http.StatusBadRequest)
+			return
+		}
+
+		err := h.IdentityDB.Delete(role)
+		if err != nil {
+			log.Println("Failed to delete identity. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("AUDIT: admin removed %s identity", role)
+
+		h.respondIdentities(w)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var identityReq Admin_IdentityPayload
+	err = json.Unmarshal(bodyBytes, &identityReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	if !isIdentityRole(identityReq.Role) {
+		commonapi.RespondError(w, "Unknown role!", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := fdoshared.NewServerIdentity([]byte(identityReq.Certificate), []byte(identityReq.PrivateKey))
+	if err != nil {
+		commonapi.RespondError(w, "Invalid identity! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.IdentityDB.Save(identityReq.Role, *identity)
+	if err != nil {
+		log.Println("Failed to save identity. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	leafCert, _ := identity.GetLeafCertificate()
+	log.Printf("AUDIT: admin imported %s identity %s", identityReq.Role, leafCert.Subject.String())
+
+	h.respondIdentities(w)
+}
+
+func isIdentityRole(role fdoshared.ServerIdentityRole) bool {
+	for _, knownRole := range fdoshared.ServerIdentityRoles {
+		if role == knownRole {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Captures downloads raw CBOR requests captured from device guid with CAPTURE_MESSAGES, as zip
+func (h *AdminAPI) Captures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkAdminToken(w, r) {
+		return
+	}
+
+	guid, err := fdoshared.ParseFdoGuid(r.URL.Query().Get("guid"))
+	if err != nil {
+		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, err := h.CaptureDB.Get(guid)
+	if err != nil {
+		log.Println("Failed to read captures. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if len(messages) == 0 {
+		commonapi.RespondError(w, "No captures found!", http.StatusNotFound)
+		return
+	}
+
+	var zipBuffer bytes.Buffer
+	err = testdbs.WriteCapturesZip(&zipBuffer, messages)
+	if err != nil {
+		log.Println("Failed to write captures zip. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	guidHex := hex.EncodeToString(guid[:])
+	log.Printf("AUDIT: admin downloaded %d captured messages of device %s", len(messages), guidHex)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.captures.zip\"", guidHex))
+	w.Write(zipBuffer.Bytes())
+}
+
+// DOVouchers lists vouchers stored in DO, in GUID order, a page at a time. Query offset, limit, up to 500, and cursor, nextCursor of the previous page
+func (h *AdminAPI) DOVouchers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkAdminToken(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	offset := 0
+	if query.Get("offset") != "" {
+		var err error
+		offset, err = strconv.Atoi(query.Get("offset"))
+		if err != nil {
+			commonapi.RespondError(w, "Invalid offset!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := DEFAULT_VOUCHER_LIST_LIMIT
+	if query.Get("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil {
+			commonapi.RespondError(w, "Invalid limit!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var cursor *fdoshared.FdoGuid
+	if query.Get("cursor") != "" {
+		cursorGuid, err := fdoshared.ParseFdoGuid(query.Get("cursor"))
+		if err != nil {
+			commonapi.RespondError(w, "Invalid cursor! "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cursor = &cursorGuid
+	}
+
+	vouchers, more, err := h.DOVoucherDB.ListPage(cursor, offset, limit)
+	if err != nil {
+		log.Println("Failed to list vouchers. " + err.Error())
+		commonapi.RespondError(w, "Failed to list vouchers! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := Admin_VouchersResponse{
+		Status:   commonapi.FdoApiStatus_OK,
+		Vouchers: []Admin_VoucherInfo{},
+	}
+
+	for _, voucher := range vouchers {
+		response.Vouchers = append(response.Vouchers, Admin_VoucherInfo{
+			Guid:              hex.EncodeToString(voucher.Guid[:]),
+			NumOVEntries:      voucher.NumOVEntries,
+			CreatedAt:         voucher.CreatedAt,
+			DeviceCertSubject: voucher.DeviceCertSubject,
+		})
+	}
+
+	if more && len(response.Vouchers) != 0 {
+		response.NextCursor = response.Vouchers[len(response.Vouchers)-1].Guid
+	}
+
+	commonapi.RespondSuccessStruct(w, response)
+}
+
+type bulkVoucherResult struct {
+	Guid fdoshared.FdoGuid
+	Err  error
+}
+
+func (h *AdminAPI) generateBulkVoucher(deviceSgType fdoshared.DeviceSgType, entrySgTypes []fdoshared.DeviceSgType, rvInfo fdoshared.RendezvousInfo) bulkVoucherResult {
+	credential, err := fdoshared.NewWawDeviceCredential(deviceSgType)
+	if err != nil {
+		return bulkVoucherResult{Err: errors.New("error generating device credential. " + err.Error())}
+	}
+
+	credAndVoucher, err := fdodeviceimplementation.NewVirtualDeviceAndMixedVoucher(*credential, entrySgTypes[0], entrySgTypes, rvInfo, testcom.NULL_TEST)
+	if err != nil {
+		return bulkVoucherResult{Err: errors.New("error generating voucher. " + err.Error())}
+	}
+
+	err = h.DOVoucherDB.Save(credAndVoucher.VoucherDBEntry)
+	if err != nil {
+		return bulkVoucherResult{Err: err}
+	}
+
+	return bulkVoucherResult{Guid: credential.DCGuid}
+}
+
+// GenerateVouchers generates count virtual devices and saves their vouchers to DO, for load testing. Vouchers use RVBypass to FDO_SERVICE_URL.
+// Progress is streamed as server-sent events, generation runs on a worker per CPU and stops when client disconnects
+func (h *AdminAPI) GenerateVouchers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkAdminToken(w, r) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		commonapi.RespondError(w, "Streaming is not supported!", http.StatusInternalServerError)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var generateReq Admin_GenerateVouchersPayload
+	err = json.Unmarshal(bodyBytes, &generateReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	if generateReq.Count < 1 || generateReq.Count > MAX_BULK_VOUCHERS {
+		commonapi.RespondError(w, fmt.Sprintf("Count must be between 1 and %d!", MAX_BULK_VOUCHERS), http.StatusBadRequest)
+		return
+	}
+
+	keyType, ok := bulkVoucherKeyTypes[generateReq.KeyType]
+	if !ok {
+		commonapi.RespondError(w, "Unknown key type! Expected EC256, EC384, RSA or Ed25519", http.StatusBadRequest)
+		return
+	}
+
+	if keyType.DeviceSgType == fdoshared.StED25519 && !fdoshared.ExperimentalEd25519 {
+		commonapi.RespondError(w, "Ed25519 is disabled! Enable it with EXPERIMENTAL_ED25519=true", http.StatusBadRequest)
+		return
+	}
+
+	if generateReq.NumOVEntries < 0 || generateReq.NumOVEntries > MAX_BULK_VOUCHER_OV_ENTRIES {
+		commonapi.RespondError(w, fmt.Sprintf("Number of OV entries must be between 1 and %d, or 0 for random!", MAX_BULK_VOUCHER_OV_ENTRIES), http.StatusBadRequest)
+		return
+	}
+
+	serviceUrl, _ := h.Ctx.Value(fdoshared.CFG_ENV_FDO_SERVICE_URL).(string)
+	rvInfo, err := fdoshared.UrlsToBypassRendezvousInfo([]string{serviceUrl})
+	if err != nil {
+		log.Println("Failed to generate RVInfo. " + err.Error())
+		commonapi.RespondError(w, "Failed to generate RVInfo! "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: admin started generating %d %s vouchers", generateReq.Count, generateReq.KeyType)
+
+	jobs := make(chan []fdoshared.DeviceSgType)
+	results := make(chan bulkVoucherResult)
+
+	workers := runtime.NumCPU()
+	if workers > generateReq.Count {
+		workers = generateReq.Count
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entrySgTypes := range jobs {
+				results <- h.generateBulkVoucher(keyType.DeviceSgType, entrySgTypes, rvInfo)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := 0; i < generateReq.Count; i++ {
+			numOVEntries := generateReq.NumOVEntries
+			if numOVEntries == 0 {
+				numOVEntries = fdoshared.NewRandomInt(3, 7)
+			}
+
+			entrySgTypes := make([]fdoshared.DeviceSgType, numOVEntries)
+			for j := range entrySgTypes {
+				entrySgTypes[j] = keyType.OwnerSgType
+			}
+
+			select {
+			case jobs <- entrySgTypes:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	progress := Admin_GenerateVouchersProgress{Total: generateReq.Count}
+	guids := []string{}
+
+	for result := range results {
+		progress.Guid = ""
+		progress.Error = ""
+
+		if result.Err != nil {
+			log.Println("Failed to generate voucher. " + result.Err.Error())
+			progress.Failed++
+			progress.Error = result.Err.Error()
+		} else {
+			progress.Generated++
+			progress.Guid = hex.EncodeToString(result.Guid[:])
+			guids = append(guids, progress.Guid)
+		}
+
+		progressBytes, _ := json.Marshal(progress)
+		fmt.Fprintf(w, "data: %s\n\n", progressBytes)
+		flusher.Flush()
+	}
+
+	log.Printf("AUDIT: admin generated %d vouchers, %d failed", progress.Generated, progress.Failed)
+
+	progress.Guid = ""
+	progress.Error = ""
+	progress.Done = true
+	progress.Guids = guids
+
+	progressBytes, _ := json.Marshal(progress)
+	fmt.Fprintf(w, "data: %s\n\n", progressBytes)
+	flusher.Flush()
+}
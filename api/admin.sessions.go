@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+type Admin_UserSessionInfo struct {
+	Ref      string `json:"ref"`
+	Email    string `json:"email"`
+	LoggedIn bool   `json:"loggedIn"`
+	// Unix seconds. Omitted for sessions created before creation time was recorded
+	CreatedAt int64 `json:"createdAt,omitempty"`
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+}
+
+type Admin_UserSessionsResponse struct {
+	Status   commonapi.FdoConfApiStatus `json:"status"`
+	Sessions []Admin_UserSessionInfo    `json:"sessions"`
+}
+
+type Admin_RevokeSessionsResponse struct {
+	Status  commonapi.FdoConfApiStatus `json:"status"`
+	Revoked int                        `json:"revoked"`
+}
+
+type Admin_UserRolePayload struct {
+	Email string `json:"email"`
+	Admin bool   `json:"admin"`
+}
+
+// checkAdmin accepts ADMIN_TOKEN bearer, or session of a user with admin role. Returns the admin user, nil for ADMIN_TOKEN
+func (h *AdminAPI) checkAdmin(w http.ResponseWriter, r *http.Request) (*dbs.UserTestDBEntry, bool) {
+	if r.Header.Get("Authorization") != "" {
+		return nil, h.checkAdminToken(w, r)
+	}
+
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil || !sessionInst.LoggedIn {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	userInst, err := h.UserDB.Get(sessionInst.Email)
+	if err != nil || !userInst.IsAdmin {
+		commonapi.RespondError(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return userInst, true
+}
+
+// adminActor names who made the admin request in audit log
+func adminActor(adminUser *dbs.UserTestDBEntry) string {
+	if adminUser == nil {
+		return "admin"
+	}
+
+	return commonapi.RedactEmail(adminUser.Email)
+}
+
+// UserSessions lists web login sessions with GET. DELETE with query ref revokes a single session, with email logs the user out of all sessions
+func (h *AdminAPI) UserSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Same as CheckHeaders for POST. Cross-site requests can not send JSON without CORS preflight
+	if r.Method == "DELETE" && r.Header.Get("Content-Type") != commonapi.CONTENT_TYPE_JSON {
+		commonapi.RespondError(w, "Unsupported media types!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	adminUser, ok := h.checkAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	actor := adminActor(adminUser)
+	if r.Method == "DELETE" {
+		h.revokeUserSessions(w, r, actor)
+		return
+	}
+
+	sessions, err := h.SessionDB.List()
+	if err != nil {
+		log.Println("Failed to list sessions. " + err.Error())
+		commonapi.RespondError(w, "Failed to list sessions!", http.StatusInternalServerError)
+		return
+	}
+
+	response := Admin_UserSessionsResponse{
+		Status:   commonapi.FdoApiStatus_OK,
+		Sessions: []Admin_UserSessionInfo{},
+	}
+
+	for _, session := range sessions {
+		sessionInfo := Admin_UserSessionInfo{
+			Ref:      session.Ref,
+			Email:    session.Email,
+			LoggedIn: session.LoggedIn,
+		}
+
+		if !session.CreatedAt.IsZero() {
+			sessionInfo.CreatedAt = session.CreatedAt.Unix()
+		}
+
+		if !session.ExpiresAt.IsZero() {
+			sessionInfo.ExpiresAt = session.ExpiresAt.Unix()
+		}
+
+		response.Sessions = append(response.Sessions, sessionInfo)
+	}
+
+	log.Printf("AUDIT: %s listed %d user sessions", actor, len(response.Sessions))
+
+	commonapi.RespondSuccessStruct(w, response)
+}
+
+func (h *AdminAPI) revokeUserSessions(w http.ResponseWriter, r *http.Request, actor string) {
+	ref := r.URL.Query().Get("ref")
+	email := r.URL.Query().Get("email")
+
+	if (len(ref) == 0) == (len(email) == 0) {
+		commonapi.RespondError(w, "Expected either ref or email!", http.StatusBadRequest)
+		return
+	}
+
+	if len(ref) != 0 {
+		found, err := h.SessionDB.RevokeSession(ref)
+		if err != nil {
+			log.Println("Failed to revoke session. " + err.Error())
+			commonapi.RespondError(w, "Failed to revoke session!", http.StatusInternalServerError)
+			return
+		}
+
+		if !found {
+			commonapi.RespondError(w, "Session not found!", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("AUDIT: %s revoked session %s", actor, ref)
+
+		commonapi.RespondSuccessStruct(w, Admin_RevokeSessionsResponse{Status: commonapi.FdoApiStatus_OK, Revoked: 1})
+		return
+	}
+
+	revoked, err := h.SessionDB.DeleteUserSessions(email)
+	if err != nil {
+		log.Println("Failed to revoke user sessions. " + err.Error())
+		commonapi.RespondError(w, "Failed to revoke user sessions!", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: %s logged out %s from %d sessions", actor, commonapi.RedactEmail(email), revoked)
+
+	commonapi.RespondSuccessStruct(w, Admin_RevokeSessionsResponse{Status: commonapi.FdoApiStatus_OK, Revoked: revoked})
+}
+
+// UserRole grants or removes admin role of the user. Admins can not remove their own role
+func (h *AdminAPI) UserRole(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	adminUser, ok := h.checkAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var roleReq Admin_UserRolePayload
+	err = json.Unmarshal(bodyBytes, &roleReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	userInst, err := h.UserDB.Get(roleReq.Email)
+	if err != nil {
+		commonapi.RespondError(w, "User not found!", http.StatusNotFound)
+		return
+	}
+
+	if !roleReq.Admin && adminUser != nil && strings.EqualFold(adminUser.Email, userInst.Email) {
+		commonapi.RespondError(w, "Can not remove your own admin role!", http.StatusBadRequest)
+		return
+	}
+
+	userInst.IsAdmin = roleReq.Admin
+	err = h.UserDB.Save(*userInst)
+	if err != nil {
+		log.Println("Failed to save user. " + err.Error())
+		commonapi.RespondError(w, "Failed to save user!", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: %s set admin role of %s to %v", adminActor(adminUser), commonapi.RedactEmail(userInst.Email), roleReq.Admin)
+
+	commonapi.RespondSuccess(w)
+}
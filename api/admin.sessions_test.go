@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+func TestAdminAPI_UserSessions(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	ctx := context.WithValue(context.Background(), fdoshared.CFG_ENV_ADMIN_TOKEN, "admin")
+
+	userDb := dbs.NewUserTestDB(db)
+	sessionDb := dbs.NewSessionDB(db)
+	adminApi := AdminAPI{UserDB: userDb, SessionDB: sessionDb, Ctx: ctx}
+
+	for _, user := range []dbs.UserTestDBEntry{
+		{Email: "admin@example.com", IsAdmin: true},
+		{Email: "user@example.com"},
+	} {
+		if err := userDb.Save(user); err != nil {
+			t.Fatalf("Failed to save user. %s", err.Error())
+		}
+	}
+
+	newSession := func(email string) []byte {
+		sessionId, err := sessionDb.NewSessionEntry(dbs.SessionEntry{Email: email, LoggedIn: true})
+		if err != nil {
+			t.Fatalf("Failed to create session. %s", err.Error())
+		}
+		return sessionId
+	}
+
+	adminSession := newSession("admin@example.com")
+	userSession := newSession("user@example.com")
+	newSession("user@example.com")
+
+	request := func(method string, url string, sessionId []byte, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, nil)
+		if method == "DELETE" {
+			r.Header.Set("Content-Type", commonapi.CONTENT_TYPE_JSON)
+		}
+		if sessionId != nil {
+			r.AddCookie(&http.Cookie{Name: "session", Value: string(sessionId)})
+		}
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		adminApi.UserSessions(w, r)
+		return w
+	}
+
+	if w := request("GET", "/api/admin/sessions", nil, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credentials. Got %d", w.Code)
+	}
+
+	if w := request("GET", "/api/admin/sessions", userSession, ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non admin user. Got %d", w.Code)
+	}
+
+	if w := request("GET", "/api/admin/sessions", nil, "wrong"); w.Code == http.StatusOK {
+		t.Errorf("Expected wrong admin token to be rejected")
+	}
+
+	w := request("GET", "/api/admin/sessions", adminSession, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected admin user to list sessions. Got %d %s", w.Code, w.Body.String())
+	}
+
+	var listResp Admin_UserSessionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &listResp); err != nil {
+		t.Fatalf("Failed to decode sessions. %s", err.Error())
+	}
+
+	if len(listResp.Sessions) != 3 {
+		t.Fatalf("Expected 3 sessions. Got %d", len(listResp.Sessions))
+	}
+
+	var adminRef string
+	for _, session := range listResp.Sessions {
+		if session.CreatedAt == 0 {
+			t.Errorf("Expected session creation time")
+		}
+
+		if session.Ref == string(adminSession) || session.Ref == string(userSession) {
+			t.Errorf("Expected session ref not to expose session id")
+		}
+
+		if session.Email == "admin@example.com" {
+			adminRef = session.Ref
+		}
+	}
+
+	if w := request("DELETE", "/api/admin/sessions", nil, "admin"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without ref or email. Got %d", w.Code)
+	}
+
+	if w := request("DELETE", "/api/admin/sessions?ref=00", nil, "admin"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown ref. Got %d", w.Code)
+	}
+
+	w = request("DELETE", "/api/admin/sessions?email=user@example.com", nil, "admin")
+	var revokeResp Admin_RevokeSessionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &revokeResp); err != nil || revokeResp.Revoked != 2 {
+		t.Fatalf("Expected 2 user sessions to be revoked. Got %d %s", w.Code, w.Body.String())
+	}
+
+	if _, err := sessionDb.GetSessionEntry(userSession); err == nil {
+		t.Errorf("Expected user session to be revoked")
+	}
+
+	if w := request("DELETE", "/api/admin/sessions?ref="+adminRef, nil, "admin"); w.Code != http.StatusOK {
+		t.Fatalf("Expected admin session to be revoked. Got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := request("GET", "/api/admin/sessions", adminSession, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked session to be rejected. Got %d", w.Code)
+	}
+}
+
+func TestAdminAPI_UserRole(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	userDb := dbs.NewUserTestDB(db)
+	sessionDb := dbs.NewSessionDB(db)
+	adminApi := AdminAPI{UserDB: userDb, SessionDB: sessionDb, Ctx: context.Background()}
+
+	userDb.Save(dbs.UserTestDBEntry{Email: "admin@example.com", IsAdmin: true})
+	userDb.Save(dbs.UserTestDBEntry{Email: "user@example.com"})
+
+	adminSession, _ := sessionDb.NewSessionEntry(dbs.SessionEntry{Email: "admin@example.com", LoggedIn: true})
+
+	setRoleWithContentType := func(payload Admin_UserRolePayload, contentType string) int {
+		payloadBytes, _ := json.Marshal(payload)
+		r := httptest.NewRequest("POST", "/api/admin/users/role", bytes.NewReader(payloadBytes))
+		r.Header.Set("Content-Type", contentType)
+		r.AddCookie(&http.Cookie{Name: "session", Value: string(adminSession)})
+
+		w := httptest.NewRecorder()
+		adminApi.UserRole(w, r)
+		return w.Code
+	}
+
+	setRole := func(payload Admin_UserRolePayload) int {
+		return setRoleWithContentType(payload, commonapi.CONTENT_TYPE_JSON)
+	}
+
+	// Cross-site form POST can only send form and text/plain bodies
+	if code := setRoleWithContentType(Admin_UserRolePayload{Email: "user@example.com", Admin: true}, "text/plain"); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("Expected text/plain role change to be rejected. Got %d", code)
+	}
+
+	userInst, _ := userDb.Get("user@example.com")
+	if userInst.IsAdmin {
+		t.Fatalf("Expected user not to be admin after rejected request")
+	}
+
+	if code := setRole(Admin_UserRolePayload{Email: "user@example.com", Admin: true}); code != http.StatusOK {
+		t.Fatalf("Expected admin role to be granted. Got %d", code)
+	}
+
+	userInst, _ = userDb.Get("user@example.com")
+	if !userInst.IsAdmin {
+		t.Errorf("Expected user to be admin")
+	}
+
+	if code := setRole(Admin_UserRolePayload{Email: "admin@example.com"}); code != http.StatusBadRequest {
+		t.Errorf("Expected own admin role removal to be rejected. Got %d", code)
+	}
+
+	if code := setRole(Admin_UserRolePayload{Email: "missing@example.com", Admin: true}); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown user. Got %d", code)
+	}
+}
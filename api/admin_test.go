@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestAdminAPI_GenerateVouchers(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	ctx := context.WithValue(context.Background(), fdoshared.CFG_ENV_ADMIN_TOKEN, "admin")
+	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_FDO_SERVICE_URL, "http://do.example.com:8080")
+
+	voucherDb := dodbs.NewVoucherDB(db)
+	adminApi := AdminAPI{DOVoucherDB: voucherDb, Ctx: ctx}
+
+	generate := func(payload Admin_GenerateVouchersPayload) *httptest.ResponseRecorder {
+		payloadBytes, _ := json.Marshal(payload)
+		r := httptest.NewRequest("POST", "/api/do/vouchers/generate", bytes.NewReader(payloadBytes))
+		r.Header.Set("Authorization", "Bearer admin")
+
+		w := httptest.NewRecorder()
+		adminApi.GenerateVouchers(w, r)
+		return w
+	}
+
+	w := generate(Admin_GenerateVouchersPayload{Count: 5, KeyType: "EC256", NumOVEntries: 2})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected vouchers to be generated. Got %d %s", w.Code, w.Body.String())
+	}
+
+	events := []Admin_GenerateVouchersProgress{}
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var progress Admin_GenerateVouchersProgress
+		err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &progress)
+		if err != nil {
+			t.Fatalf("Failed to decode progress event. %s", err.Error())
+		}
+		events = append(events, progress)
+	}
+
+	if len(events) != 6 {
+		t.Fatalf("Expected 5 progress events and final event. Got %d", len(events))
+	}
+
+	final := events[len(events)-1]
+	if !final.Done || final.Generated != 5 || final.Failed != 0 || len(final.Guids) != 5 {
+		t.Fatalf("Expected 5 generated vouchers. Got %+v", final)
+	}
+
+	for _, guidHex := range final.Guids {
+		guid, err := fdoshared.ParseFdoGuid(guidHex)
+		if err != nil {
+			t.Fatalf("Failed to parse GUID. %s", err.Error())
+		}
+
+		voucherDBEntry, err := voucherDb.Get(guid)
+		if err != nil {
+			t.Fatalf("Expected voucher %s to be saved. %s", guidHex, err.Error())
+		}
+
+		if len(voucherDBEntry.Voucher.OVEntryArray) != 2 {
+			t.Errorf("Expected 2 OV entries. Got %d", len(voucherDBEntry.Voucher.OVEntryArray))
+		}
+	}
+
+	for _, payload := range []Admin_GenerateVouchersPayload{
+		{Count: 0, KeyType: "EC256"},
+		{Count: MAX_BULK_VOUCHERS + 1, KeyType: "EC256"},
+		{Count: 1, KeyType: "DSA"},
+		{Count: 1, KeyType: "EC384", NumOVEntries: MAX_BULK_VOUCHER_OV_ENTRIES + 1},
+	} {
+		if w := generate(payload); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %+v to be rejected. Got %d", payload, w.Code)
+		}
+	}
+}
+
+func TestAdminAPI_SessionState(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	ctx := context.WithValue(context.Background(), fdoshared.CFG_ENV_ADMIN_TOKEN, "admin")
+	adminApi := AdminAPI{DOSessionDB: dodbs.NewSessionDB(db), Ctx: ctx}
+
+	guid := fdoshared.NewFdoGuid()
+	shSe := []byte("session shared secret")
+	sessionId, err := adminApi.DOSessionDB.NewTo2SessionEntry(dodbs.SessionEntry{
+		Protocol:                 fdoshared.To2,
+		PrevCMD:                  fdoshared.TO2_68_DEVICE_SERVICE_INFO,
+		Guid:                     guid,
+		SessionKey:               fdoshared.SessionKeyInfo{ShSe: shSe},
+		CipherSuiteName:          fdoshared.CIPHER_A128GCM,
+		DeviceSIMs:               []fdoshared.ServiceInfoKV{{ServiceInfoKey: fdoshared.SIM_DEVMOD_OS, ServiceInfoVal: []byte("linux")}},
+		ReplacementPrivateKeyDER: []byte("replacement private key"),
+	}, fdoshared.ONBOARDING_POLICY_REJECT)
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	getState := func(query string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/admin/session?"+query, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		adminApi.SessionState(w, r)
+		return w
+	}
+
+	if w := getState("sessionId="+string(sessionId), "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected wrong admin token to be rejected. Got %d", w.Code)
+	}
+
+	for _, query := range []string{"sessionId=" + string(sessionId), "guid=" + guid.GetFormatted()} {
+		w := getState(query, "admin")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected session state for %s. Got %d %s", query, w.Code, w.Body.String())
+		}
+
+		var state Admin_SessionStateResponse
+		err = json.Unmarshal(w.Body.Bytes(), &state)
+		if err != nil {
+			t.Fatalf("Failed to decode session state. %s", err.Error())
+		}
+
+		if state.SessionId != string(sessionId) || state.PrevCMD != fdoshared.TO2_68_DEVICE_SERVICE_INFO || len(state.DeviceSIMs) != 1 || state.DeviceSIMs[0].Size != 5 {
+			t.Errorf("Expected decoded state of the session. Got %+v", state)
+		}
+
+		if strings.Contains(w.Body.String(), hex.EncodeToString(shSe)) || strings.Contains(w.Body.String(), base64.StdEncoding.EncodeToString(shSe)) {
+			t.Errorf("Expected session key material to be left out")
+		}
+	}
+
+	if w := getState("guid="+fdoshared.NewFdoGuid().GetFormatted(), "admin"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown GUID to be not found. Got %d", w.Code)
+	}
+
+	if w := getState("", "admin"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected request without sessionId or guid to fail. Got %d", w.Code)
+	}
+}
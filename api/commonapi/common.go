@@ -8,9 +8,10 @@ import (
 
 const CONTENT_TYPE_JSON string = "application/json"
 
-func GenerateCookie(token []byte) *http.Cookie {
-	expires := time.Now().Add(14 * 24 * time.Hour)
-	cookie := http.Cookie{Name: "session", Value: string(token), Expires: expires, HttpOnly: true, Path: "/api/"}
+// GenerateCookie returns session cookie expiring after ttl. SameSite Lax keeps the cookie out of cross-site POSTs, and still sends it on links to the server
+func GenerateCookie(token []byte, ttl time.Duration) *http.Cookie {
+	expires := time.Now().Add(ttl)
+	cookie := http.Cookie{Name: "session", Value: string(token), Expires: expires, HttpOnly: true, Path: "/api/", SameSite: http.SameSiteLaxMode}
 
 	return &cookie
 }
@@ -0,0 +1,93 @@
+package commonapi
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gzWriter *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(statusCode int) {
+	g.Header().Del("Content-Length")
+	g.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	return g.gzWriter.Write(b)
+}
+
+// Flush sends compressed data written so far, so streamed responses are not held back
+func (g *gzipResponseWriter) Flush() {
+	g.gzWriter.Flush()
+
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// encodingQValue returns q-value of Accept-Encoding entry params, e.g. "q=0.5". Missing or malformed q-value is 1
+func encodingQValue(params []string) float64 {
+	for _, param := range params {
+		name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+
+		qValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 1
+		}
+
+		return qValue
+	}
+
+	return 1
+}
+
+// acceptsGzip checks Accept-Encoding for gzip. Entry with q=0 refuses encoding. Explicit gzip entry takes precedence over "*"
+func acceptsGzip(r *http.Request) bool {
+	wildcardAccepted := false
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encodingParts := strings.Split(encoding, ";")
+		encodingName := strings.ToLower(strings.TrimSpace(encodingParts[0]))
+
+		switch encodingName {
+		case "gzip":
+			return encodingQValue(encodingParts[1:]) > 0
+		case "*":
+			wildcardAccepted = encodingQValue(encodingParts[1:]) > 0
+		}
+	}
+
+	return wildcardAccepted
+}
+
+// GzipMiddleware compresses /api/ responses when client sends Accept-Encoding: gzip.
+// FDO CBOR endpoints are served outside of the API router, and frontend is left to the file server/dev proxy.
+func GzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+
+		gzWriter := gzip.NewWriter(w)
+		defer gzWriter.Close()
+
+		next.ServeHTTP(&gzipResponseWriter{ResponseWriter: w, gzWriter: gzWriter}, r)
+	})
+}
@@ -0,0 +1,82 @@
+package commonapi
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondError(w, "Test error!", http.StatusBadRequest)
+	}))
+
+	req := httptest.NewRequest("POST", "/api/test", nil)
+	req.Header.Set("Accept-Encoding", "deflate, gzip;q=1.0")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d. Got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip Content-Encoding. Got \"%s\"", rec.Header().Get("Content-Encoding"))
+	}
+
+	gzReader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip reader. %s", err.Error())
+	}
+
+	bodyBytes, err := io.ReadAll(gzReader)
+	if err != nil {
+		t.Fatalf("Failed to decompress body. %s", err.Error())
+	}
+
+	var apiError FdoConformanceApiError
+	err = json.Unmarshal(bodyBytes, &apiError)
+	if err != nil {
+		t.Fatalf("Failed to decode body. %s", err.Error())
+	}
+
+	if apiError.ErrorMessage != "Test error!" {
+		t.Errorf("Expected \"Test error!\". Got \"%s\"", apiError.ErrorMessage)
+	}
+
+	req = httptest.NewRequest("POST", "/api/test", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected no Content-Encoding without Accept-Encoding. Got \"%s\"", rec.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for acceptEncoding, expected := range map[string]bool{
+		"gzip":                true,
+		"deflate, gzip;q=1.0": true,
+		"gzip; q=0.5":         true,
+		"GZIP":                true,
+		"gzip;q=0":            false,
+		"gzip;q=0.000":        false,
+		"deflate, gzip; Q=0":  false,
+		"*":                   true,
+		"*;q=0":               false,
+		"*, gzip;q=0":         false,
+		"gzip;q=0.1, *;q=0":   true,
+		"deflate, br":         false,
+		"":                    false,
+	} {
+		req := httptest.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+
+		if acceptsGzip(req) != expected {
+			t.Errorf("Expected Accept-Encoding \"%s\" to accept gzip %t", acceptEncoding, expected)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package commonapi
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Max buffered lines per log stream. Lines are dropped for streams that do not keep up, so logging never blocks
+const LOG_STREAM_BUFFER = 256
+
+// LogFilter returns true for log lines the stream is interested in
+type LogFilter func(line string) bool
+
+type LogStream struct {
+	Lines  chan string
+	filter LogFilter
+}
+
+// LogHub fans out process log lines to live streams. Set as log output, next to stderr, on startup
+type LogHub struct {
+	mu      sync.Mutex
+	streams map[*LogStream]bool
+}
+
+func NewLogHub() *LogHub {
+	return &LogHub{
+		streams: map[*LogStream]bool{},
+	}
+}
+
+var Logs = NewLogHub()
+
+var emailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// RedactLine masks emails left in the log line, when redaction is enabled
+func RedactLine(line string) string {
+	if !RedactPII {
+		return line
+	}
+
+	return emailRegexp.ReplaceAllStringFunc(line, func(email string) string {
+		// Already redacted by the caller
+		if strings.Contains(email, "***") {
+			return email
+		}
+
+		return RedactEmail(email)
+	})
+}
+
+// Write is called by log package once per log entry
+func (h *LogHub) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.streams) == 0 {
+		return len(p), nil
+	}
+
+	line := RedactLine(strings.TrimRight(string(p), "\n"))
+	for stream := range h.streams {
+		if !stream.filter(line) {
+			continue
+		}
+
+		select {
+		case stream.Lines <- line:
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+func (h *LogHub) Subscribe(filter LogFilter) *LogStream {
+	stream := &LogStream{
+		Lines:  make(chan string, LOG_STREAM_BUFFER),
+		filter: filter,
+	}
+
+	h.mu.Lock()
+	h.streams[stream] = true
+	h.mu.Unlock()
+
+	return stream
+}
+
+func (h *LogHub) Unsubscribe(stream *LogStream) {
+	h.mu.Lock()
+	delete(h.streams, stream)
+	h.mu.Unlock()
+}
+
+var messageLogRegexp = regexp.MustCompile(`\[(DEBUG|INFO|WARN|ERROR)\] TO\d+ \d+ cid=([^\s:]+):`)
+
+// parseLogLine returns level and correlation ID of FDO message log lines. Other log lines are INFO, with no correlation ID
+func parseLogLine(line string) (fdoshared.LogLevel, string) {
+	match := messageLogRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return fdoshared.LOG_INFO, ""
+	}
+
+	logLevel, _ := fdoshared.ParseLogLevel(match[1])
+	return logLevel, strings.ToLower(match[2])
+}
+
+// NewCorrelationFilter matches log lines at or above minLevel for any of the correlation values, e.g. device GUID, session token hash or test run id.
+// FDO message log lines are matched on their correlation ID. Other lines are matched on whole words, so values are not found inside longer ids
+func NewCorrelationFilter(values []string, minLevel fdoshared.LogLevel) LogFilter {
+	needles := map[string]bool{}
+	for _, value := range values {
+		if value != "" {
+			needles[strings.ToLower(value)] = true
+		}
+	}
+
+	return func(line string) bool {
+		logLevel, correlationID := parseLogLine(line)
+		if logLevel < minLevel {
+			return false
+		}
+
+		if correlationID != "" {
+			return needles[correlationID]
+		}
+
+		words := strings.FieldsFunc(strings.ToLower(line), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+		})
+		for _, word := range words {
+			if needles[word] {
+				return true
+			}
+		}
+
+		return false
+	}
+}
@@ -0,0 +1,90 @@
+package commonapi
+
+import (
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestLogHub_CorrelationFilter(t *testing.T) {
+	hub := NewLogHub()
+
+	stream := hub.Subscribe(NewCorrelationFilter([]string{"", "ABCDEF0123"}, fdoshared.LOG_INFO))
+	defer hub.Unsubscribe(stream)
+
+	hub.Write([]byte("Device abcdef0123 started TO2\n"))
+	hub.Write([]byte("Device 9999999999 started TO2\n"))
+	// Value inside longer id is not a match
+	hub.Write([]byte("Device abcdef0123ff started TO2\n"))
+	// Message log lines are matched on cid only
+	hub.Write([]byte("2026/01/01 00:00:00 [INFO] TO2 60 cid=abcdef0123: Receiving HelloDevice60...\n"))
+	hub.Write([]byte("2026/01/01 00:00:00 [INFO] TO2 60 cid=9999999999: Replacement of abcdef0123\n"))
+
+	expected := []string{
+		"Device abcdef0123 started TO2",
+		"2026/01/01 00:00:00 [INFO] TO2 60 cid=abcdef0123: Receiving HelloDevice60...",
+	}
+
+	if len(stream.Lines) != len(expected) {
+		t.Fatalf("Expected %d streamed lines, got %d", len(expected), len(stream.Lines))
+	}
+
+	for _, expectedLine := range expected {
+		line := <-stream.Lines
+		if line != expectedLine {
+			t.Errorf("Unexpected streamed line: %s", line)
+		}
+	}
+}
+
+func TestLogHub_LevelFilter(t *testing.T) {
+	hub := NewLogHub()
+
+	stream := hub.Subscribe(NewCorrelationFilter([]string{"abcdef0123"}, fdoshared.LOG_WARN))
+	defer hub.Unsubscribe(stream)
+
+	hub.Write([]byte("[INFO] TO2 60 cid=abcdef0123: Receiving HelloDevice60...\n"))
+	hub.Write([]byte("Device abcdef0123 started TO2\n"))
+	hub.Write([]byte("[WARN] TO2 60 cid=abcdef0123: Error decoding HelloDevice60\n"))
+
+	if len(stream.Lines) != 1 {
+		t.Fatalf("Expected 1 streamed line, got %d", len(stream.Lines))
+	}
+
+	line := <-stream.Lines
+	if line != "[WARN] TO2 60 cid=abcdef0123: Error decoding HelloDevice60" {
+		t.Errorf("Unexpected streamed line: %s", line)
+	}
+}
+
+func TestLogHub_Redaction(t *testing.T) {
+	defer func() { RedactPII = false }()
+	RedactPII = true
+
+	hub := NewLogHub()
+
+	stream := hub.Subscribe(NewCorrelationFilter([]string{"run-1"}, fdoshared.LOG_INFO))
+	defer hub.Unsubscribe(stream)
+
+	hub.Write([]byte("run-1 started by john.doe@example.com\n"))
+
+	line := <-stream.Lines
+	if line != "run-1 started by "+RedactEmail("john.doe@example.com") {
+		t.Errorf("Expected email redacted, got %s", line)
+	}
+}
+
+func TestLogHub_SlowStreamDoesNotBlock(t *testing.T) {
+	hub := NewLogHub()
+
+	stream := hub.Subscribe(func(line string) bool { return true })
+	defer hub.Unsubscribe(stream)
+
+	for i := 0; i < LOG_STREAM_BUFFER+10; i++ {
+		hub.Write([]byte("line\n"))
+	}
+
+	if len(stream.Lines) != LOG_STREAM_BUFFER {
+		t.Errorf("Expected %d buffered lines, got %d", LOG_STREAM_BUFFER, len(stream.Lines))
+	}
+}
@@ -0,0 +1,113 @@
+package commonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	CONTENT_TYPE_CBOR      string = "application/cbor"
+	CONTENT_TYPE_MSGPACK   string = "application/msgpack"
+	CONTENT_TYPE_X_MSGPACK string = "application/x-msgpack"
+	CONTENT_TYPE_XML       string = "application/xml"
+	CONTENT_TYPE_TEXT_XML  string = "text/xml"
+)
+
+// Msgpack reuses json tags, so field names match JSON responses
+var msgpackHandle = func() *codec.MsgpackHandle {
+	handle := &codec.MsgpackHandle{}
+	handle.TypeInfos = codec.NewTypeInfos([]string{"json"})
+	handle.WriteExt = true
+	handle.RawToString = true
+
+	return handle
+}()
+
+// NegotiateContentType picks results format from Accept header. Defaults to JSON
+func NegotiateContentType(r *http.Request) string {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(mediaRange, ";")[0]))
+
+		switch mediaType {
+		case CONTENT_TYPE_JSON, CONTENT_TYPE_CBOR, CONTENT_TYPE_MSGPACK:
+			return mediaType
+		case CONTENT_TYPE_X_MSGPACK:
+			return CONTENT_TYPE_MSGPACK
+		}
+	}
+
+	return CONTENT_TYPE_JSON
+}
+
+// AcceptsXML is used by reports that are also available as JUnit XML
+func AcceptsXML(r *http.Request) bool {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(mediaRange, ";")[0]))
+
+		switch mediaType {
+		case CONTENT_TYPE_XML, CONTENT_TYPE_TEXT_XML:
+			return true
+		case CONTENT_TYPE_JSON, CONTENT_TYPE_CBOR, CONTENT_TYPE_MSGPACK, CONTENT_TYPE_X_MSGPACK:
+			return false
+		}
+	}
+
+	return false
+}
+
+func MarshalNegotiated(contentType string, v interface{}) ([]byte, error) {
+	switch contentType {
+	case CONTENT_TYPE_JSON:
+		return json.Marshal(v)
+	case CONTENT_TYPE_CBOR:
+		return fdoshared.CborCust.Marshal(v)
+	case CONTENT_TYPE_MSGPACK:
+		var result []byte
+		err := codec.NewEncoderBytes(&result, msgpackHandle).Encode(v)
+		return result, err
+	default:
+		return nil, fmt.Errorf("unsupported content type %s", contentType)
+	}
+}
+
+func UnmarshalNegotiated(contentType string, data []byte, v interface{}) error {
+	switch contentType {
+	case CONTENT_TYPE_JSON:
+		return json.Unmarshal(data, v)
+	case CONTENT_TYPE_CBOR:
+		return fdoshared.CborCust.Unmarshal(data, v)
+	case CONTENT_TYPE_MSGPACK:
+		return codec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+	default:
+		return fmt.Errorf("unsupported content type %s", contentType)
+	}
+}
+
+// RespondSuccessStructNegotiated same as RespondSuccessStruct, but encodes as JSON, CBOR or msgpack based on Accept header
+func RespondSuccessStructNegotiated(w http.ResponseWriter, r *http.Request, successStruct interface{}) {
+	contentType := NegotiateContentType(r)
+
+	successStructBytes, err := MarshalNegotiated(contentType, successStruct)
+	if err != nil {
+		RespondError(w, "Failed to encode response!", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	w.Write(successStructBytes)
+}
+
+// RespondSuccessXML responds with already encoded XML document
+func RespondSuccessXML(w http.ResponseWriter, xmlBytes []byte) {
+	w.Header().Set("Content-Type", CONTENT_TYPE_XML)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlBytes)
+}
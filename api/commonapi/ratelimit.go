@@ -0,0 +1,128 @@
+package commonapi
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DEFAULT_LOGIN_RATE_LIMIT int = 10
+const DEFAULT_LOGIN_RATE_BURST int = 5
+
+// Buckets that are full again are dropped once the limiter tracks more keys than this
+const RATE_LIMITER_MAX_KEYS int = 10000
+
+// Set from LOGIN_RATE_LIMIT and LOGIN_RATE_BURST at startup. Nil does not limit
+var LoginLimiter *RateLimiter
+
+// RateLimiter is a token bucket per key, e.g. client IP. Each key holds up to burst tokens, refilled at perMinute rate.
+// Bucket is kept as the time it is full again, so refill is exact
+type RateLimiter struct {
+	perMinute int
+	burst     int
+	now       func() time.Time
+
+	mu     sync.Mutex
+	fullAt map[string]time.Time
+}
+
+func NewRateLimiter(perMinute int, burst int) *RateLimiter {
+	return &RateLimiter{
+		perMinute: perMinute,
+		burst:     burst,
+		now:       time.Now,
+		fullAt:    map[string]time.Time{},
+	}
+}
+
+// ParseRateLimit parses requests per minute and burst. Empty selects the default, 0 requests per minute disables the limiter, so nil is returned
+func ParseRateLimit(perMinuteStr string, burstStr string, defaultPerMinute int, defaultBurst int) (*RateLimiter, error) {
+	perMinute := defaultPerMinute
+	if perMinuteStr != "" {
+		var err error
+		perMinute, err = strconv.Atoi(perMinuteStr)
+		if err != nil || perMinute < 0 {
+			return nil, fmt.Errorf("expected requests per minute of 0 or more, got %s", perMinuteStr)
+		}
+	}
+
+	burst := defaultBurst
+	if burstStr != "" {
+		var err error
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("expected burst of 1 or more, got %s", burstStr)
+		}
+	}
+
+	if perMinute == 0 {
+		return nil, nil
+	}
+
+	return NewRateLimiter(perMinute, burst), nil
+}
+
+// Allow takes a token of the key. Returns false when the key has none left
+func (h *RateLimiter) Allow(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	interval := time.Minute / time.Duration(h.perMinute)
+
+	fullAt, ok := h.fullAt[key]
+	if !ok || fullAt.Before(now) {
+		if !ok && len(h.fullAt) >= RATE_LIMITER_MAX_KEYS {
+			h.pruneFull(now)
+		}
+
+		fullAt = now
+	}
+
+	// Each taken token moves the full time one interval further. Bucket is empty when that is burst intervals away
+	if fullAt.Sub(now) > time.Duration(h.burst-1)*interval {
+		return false
+	}
+
+	h.fullAt[key] = fullAt.Add(interval)
+	return true
+}
+
+// pruneFull drops buckets refilled to burst, as they do not limit anything. Must be called with mu held
+func (h *RateLimiter) pruneFull(now time.Time) {
+	for key, fullAt := range h.fullAt {
+		if !fullAt.After(now) {
+			delete(h.fullAt, key)
+		}
+	}
+}
+
+// ClientIP returns the address of the connecting client. X-Forwarded-For is not trusted, as it is set by the client
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Limit wraps handler, responding 429 to clients over the limit. Nil limiter passes all requests
+func (h *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	if h == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.Allow(ClientIP(r)) {
+			w.Header().Set("Retry-After", strconv.Itoa(60/h.perMinute+1))
+			RespondError(w, "Too many requests!", http.StatusTooManyRequests)
+			return
+		}
+
+		next(w, r)
+	}
+}
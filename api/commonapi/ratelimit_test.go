@@ -0,0 +1,103 @@
+package commonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewRateLimiter(6, 3)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("192.0.2.1") {
+			t.Fatalf("Expected request %d within burst to be allowed", i+1)
+		}
+	}
+
+	if limiter.Allow("192.0.2.1") {
+		t.Errorf("Expected request over burst to be limited")
+	}
+
+	if !limiter.Allow("192.0.2.2") {
+		t.Errorf("Expected other client to have its own bucket")
+	}
+
+	// 6 per minute refills a token every 10 seconds
+	now = now.Add(9 * time.Second)
+	if limiter.Allow("192.0.2.1") {
+		t.Errorf("Expected no token before refill")
+	}
+
+	now = now.Add(time.Second)
+	if !limiter.Allow("192.0.2.1") || limiter.Allow("192.0.2.1") {
+		t.Errorf("Expected exactly one token after 10 seconds")
+	}
+
+	// Refill does not exceed burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("192.0.2.1") {
+			t.Fatalf("Expected request %d to be allowed after refill", i+1)
+		}
+	}
+
+	if limiter.Allow("192.0.2.1") {
+		t.Errorf("Expected refill to be capped at burst")
+	}
+}
+
+func TestRateLimiter_Limit(t *testing.T) {
+	limiter := NewRateLimiter(1, 2)
+	handler := limiter.Limit(func(w http.ResponseWriter, r *http.Request) {
+		RespondSuccess(w)
+	})
+
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest("POST", "/api/user/login/onprem", nil)
+		r.RemoteAddr = "192.0.2.1:50000"
+		r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+		w := httptest.NewRecorder()
+		handler(w, r)
+		codes = append(codes, w.Code)
+
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("Expected Retry-After with 429")
+		}
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("Expected two requests allowed and the third limited. Got %v", codes)
+	}
+
+	var nilLimiter *RateLimiter
+	w := httptest.NewRecorder()
+	nilLimiter.Limit(func(w http.ResponseWriter, r *http.Request) { RespondSuccess(w) })(w, httptest.NewRequest("POST", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected nil limiter to pass requests")
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	limiter, err := ParseRateLimit("", "", DEFAULT_LOGIN_RATE_LIMIT, DEFAULT_LOGIN_RATE_BURST)
+	if err != nil || limiter == nil || limiter.perMinute != DEFAULT_LOGIN_RATE_LIMIT || limiter.burst != DEFAULT_LOGIN_RATE_BURST {
+		t.Errorf("Expected default limits. %v", err)
+	}
+
+	limiter, err = ParseRateLimit("0", "", DEFAULT_LOGIN_RATE_LIMIT, DEFAULT_LOGIN_RATE_BURST)
+	if err != nil || limiter != nil {
+		t.Errorf("Expected 0 to disable the limiter")
+	}
+
+	for _, invalid := range [][2]string{{"-1", ""}, {"ten", ""}, {"10", "0"}} {
+		_, err = ParseRateLimit(invalid[0], invalid[1], DEFAULT_LOGIN_RATE_LIMIT, DEFAULT_LOGIN_RATE_BURST)
+		if err == nil {
+			t.Errorf("Expected %v to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package commonapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Set from LOG_REDACT_PII at startup
+var RedactPII bool = false
+
+// RedactEmail returns email for logging. With redaction enabled, local part is masked and a stable hash is appended, so entries of the same user can still be correlated
+func RedactEmail(email string) string {
+	if !RedactPII {
+		return email
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	emailHash := sha256.Sum256([]byte(email))
+	hashStr := hex.EncodeToString(emailHash[:6])
+
+	localPart, domain, found := strings.Cut(email, "@")
+	if !found || len(localPart) == 0 {
+		return "***#" + hashStr
+	}
+
+	return localPart[:1] + "***@" + domain + "#" + hashStr
+}
@@ -0,0 +1,44 @@
+package commonapi
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRedactEmail(t *testing.T) {
+	defer func() { RedactPII = false }()
+
+	email := "john.doe@example.com"
+
+	RedactPII = false
+	if RedactEmail(email) != email {
+		t.Errorf("Expected email unchanged when redaction is off, got %s", RedactEmail(email))
+	}
+
+	RedactPII = true
+
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	log.Printf("AUDIT: %s exported session keys", RedactEmail(email))
+
+	if strings.Contains(logBuf.String(), email) || strings.Contains(logBuf.String(), "john.doe") {
+		t.Errorf("Raw email found in log: %s", logBuf.String())
+	}
+
+	if RedactEmail(email) != RedactEmail(" John.Doe@example.com") {
+		t.Errorf("Expected stable hash for the same email. Got %s and %s", RedactEmail(email), RedactEmail(" John.Doe@example.com"))
+	}
+
+	if RedactEmail(email) == RedactEmail("jane.doe@example.com") {
+		t.Errorf("Expected different emails to produce different redacted values")
+	}
+
+	if strings.Contains(RedactEmail("notanemail"), "notanemail") {
+		t.Errorf("Expected malformed email to be fully redacted, got %s", RedactEmail("notanemail"))
+	}
+}
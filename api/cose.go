@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fxamacker/cbor/v2"
+)
+
+type Cose_InspectPayload struct {
+	Cose        string `json:"cose"`
+	Certificate string `json:"certificate"`
+}
+
+type Cose_InspectResponse struct {
+	Status          commonapi.FdoConfApiStatus `json:"status"`
+	Alg             int                        `json:"alg"`
+	Protected       string                     `json:"protected"`
+	Unprotected     string                     `json:"unprotected"`
+	Payload         string                     `json:"payload"`
+	Signature       string                     `json:"signature"`
+	Valid           bool                       `json:"valid"`
+	ValidationError string                     `json:"validationError"`
+}
+
+type CoseAPI struct {
+	UserDB    *dbs.UserTestDB
+	SessionDB *dbs.SessionDB
+}
+
+func (h *CoseAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, errors.New("failed to read cookie. " + err.Error())
+	}
+
+	if sessionCookie == nil {
+		return nil, errors.New("cookie does not exists")
+	}
+
+	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil {
+		return nil, errors.New("session expired. " + err.Error())
+	}
+
+	if !sessionInst.LoggedIn {
+		return nil, errors.New("unauthorized")
+	}
+
+	userInst, err := h.UserDB.Get(sessionInst.Email)
+	if err != nil {
+		return nil, errors.New("user does not exists. " + err.Error())
+	}
+
+	return userInst, nil
+}
+
+func decodePemCertificates(pemChain string) ([]fdoshared.X509CertificateBytes, error) {
+	var certs []fdoshared.X509CertificateBytes
+
+	rest := []byte(strings.TrimSpace(pemChain))
+	for len(rest) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %s", block.Type)
+		}
+
+		certs = append(certs, block.Bytes)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+
+	return certs, nil
+}
+
+// Inspect decodes COSE_Sign1 and checks its signature against the provided certificate chain. Read only.
+func (h *CoseAPI) Inspect(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	_, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var inspectReq Cose_InspectPayload
+	err = json.Unmarshal(bodyBytes, &inspectReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	coseBytes, err := hex.DecodeString(strings.TrimSpace(inspectReq.Cose))
+	if err != nil {
+		commonapi.RespondError(w, "Failed to decode COSE hex!", http.StatusBadRequest)
+		return
+	}
+
+	var coseSig fdoshared.CoseSignature
+	err = fdoshared.CborCust.Unmarshal(coseBytes, &coseSig)
+	if err != nil {
+		commonapi.RespondError(w, "Failed to decode COSE_Sign1! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var protectedHeader fdoshared.ProtectedHeader
+	err = fdoshared.CborCust.Unmarshal(coseSig.Protected, &protectedHeader)
+	if err != nil {
+		commonapi.RespondError(w, "Failed to decode protected header! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inspectResp := Cose_InspectResponse{
+		Status:    commonapi.FdoApiStatus_OK,
+		Signature: hex.EncodeToString(coseSig.Signature),
+	}
+
+	inspectResp.Protected, _ = cbor.Diagnose(coseSig.Protected)
+	inspectResp.Payload, _ = cbor.Diagnose(coseSig.Payload)
+
+	unprotectedBytes, err := fdoshared.CborCust.Marshal(coseSig.Unprotected)
+	if err == nil {
+		inspectResp.Unprotected, _ = cbor.Diagnose(unprotectedBytes)
+	}
+
+	if protectedHeader.Alg == nil {
+		inspectResp.ValidationError = "protected header is missing alg"
+		commonapi.RespondSuccessStruct(w, inspectResp)
+		return
+	}
+	inspectResp.Alg = *protectedHeader.Alg
+
+	pkType, ok := fdoshared.SgTypeToFdoPkType[fdoshared.DeviceSgType(*protectedHeader.Alg)]
+	if !ok {
+		inspectResp.ValidationError = fmt.Sprintf("unsupported alg %d", *protectedHeader.Alg)
+		commonapi.RespondSuccessStruct(w, inspectResp)
+		return
+	}
+
+	if len(inspectReq.Certificate) == 0 {
+		inspectResp.ValidationError = "no certificate provided"
+		commonapi.RespondSuccessStruct(w, inspectResp)
+		return
+	}
+
+	certs, err := decodePemCertificates(inspectReq.Certificate)
+	if err != nil {
+		commonapi.RespondError(w, "Failed to decode certificate! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = fdoshared.VerifyCoseSignatureWithCertificate(coseSig, pkType, certs)
+	if err != nil {
+		inspectResp.ValidationError = err.Error()
+	} else {
+		inspectResp.Valid = true
+	}
+
+	commonapi.RespondSuccessStruct(w, inspectResp)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+type Debug_SessionKeysPayload struct {
+	SessionId string `json:"sessionId"`
+}
+
+type Debug_SessionKeysResponse struct {
+	Status      commonapi.FdoConfApiStatus `json:"status"`
+	Warning     string                     `json:"warning"`
+	Guid        string                     `json:"guid"`
+	CipherSuite fdoshared.CipherSuiteName  `json:"cipherSuite"`
+	KexSuite    fdoshared.KexSuiteName     `json:"kexSuite"`
+	ShSe        string                     `json:"shSe"`
+	ContextRand string                     `json:"contextRand"`
+	SEK         string                     `json:"sek,omitempty"`
+	SVK         string                     `json:"svk,omitempty"`
+	SEVK        string                     `json:"sevk,omitempty"`
+}
+
+const SESSION_KEYS_WARNING = "SENSITIVE: These keys decrypt all traffic of this session. Do not share them outside of the certification lab."
+
+type DebugAPI struct {
+	UserDB      *dbs.UserTestDB
+	SessionDB   *dbs.SessionDB
+	DOSessionDB *dodbs.SessionDB
+	Ctx         context.Context
+}
+
+func (h *DebugAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, errors.New("failed to read cookie. " + err.Error())
+	}
+
+	if sessionCookie == nil {
+		return nil, errors.New("cookie does not exists")
+	}
+
+	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil {
+		return nil, errors.New("session expired. " + err.Error())
+	}
+
+	if !sessionInst.LoggedIn {
+		return nil, errors.New("unauthorized")
+	}
+
+	userInst, err := h.UserDB.Get(sessionInst.Email)
+	if err != nil {
+		return nil, errors.New("user does not exists. " + err.Error())
+	}
+
+	return userInst, nil
+}
+
+// ExportSessionKeys returns SEK/SVK of a completed TO2 session. Only available with EXPORT_SESSION_KEYS=true
+func (h *DebugAPI) ExportSessionKeys(w http.ResponseWriter, r *http.Request) {
+	if h.Ctx.Value(fdoshared.CFG_ENV_EXPORT_SESSION_KEYS) != "true" {
+		commonapi.RespondError(w, "Session keys export is disabled!", http.StatusForbidden)
+		return
+	}
+
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var exportReq Debug_SessionKeysPayload
+	err = json.Unmarshal(bodyBytes, &exportReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	if len(exportReq.SessionId) == 0 {
+		commonapi.RespondError(w, "Missing session id!", http.StatusBadRequest)
+		return
+	}
+
+	doSession, err := h.DOSessionDB.GetSessionEntry([]byte(exportReq.SessionId))
+	if err != nil || doSession == nil {
+		commonapi.RespondError(w, "Session not found!", http.StatusNotFound)
+		return
+	}
+
+	if !userInst.DeviceT_ContainGuid(doSession.Guid) {
+		log.Printf("AUDIT: %s was denied session keys export for session %s", commonapi.RedactEmail(userInst.Email), exportReq.SessionId)
+		commonapi.RespondError(w, "Session not found!", http.StatusNotFound)
+		return
+	}
+
+	if doSession.PrevCMD != fdoshared.TO2_71_DONE2 {
+		commonapi.RespondError(w, "Session is not completed!", http.StatusBadRequest)
+		return
+	}
+
+	sessionKeys, err := fdoshared.DeriveSessionKeys(doSession.SessionKey, doSession.CipherSuiteName)
+	if err != nil {
+		log.Println("Failed to derive session keys. " + err.Error())
+		commonapi.RespondError(w, "Failed to derive session keys!", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: %s exported session keys for session %s, device %s", commonapi.RedactEmail(userInst.Email), exportReq.SessionId, hex.EncodeToString(doSession.Guid[:]))
+
+	commonapi.RespondSuccessStruct(w, Debug_SessionKeysResponse{
+		Status:      commonapi.FdoApiStatus_OK,
+		Warning:     SESSION_KEYS_WARNING,
+		Guid:        hex.EncodeToString(doSession.Guid[:]),
+		CipherSuite: doSession.CipherSuiteName,
+		KexSuite:    doSession.KexSuiteName,
+		ShSe:        hex.EncodeToString(doSession.SessionKey.ShSe),
+		ContextRand: hex.EncodeToString(doSession.SessionKey.ContextRand),
+		SEK:         hex.EncodeToString(sessionKeys.SEK),
+		SVK:         hex.EncodeToString(sessionKeys.SVK),
+		SEVK:        hex.EncodeToString(sessionKeys.SEVK),
+	})
+}
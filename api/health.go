@@ -0,0 +1,50 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+// HealthAPI serves container liveness and readiness probes. Both are unauthenticated
+type HealthAPI struct {
+	DB       *badger.DB
+	ConfigDB *dbs.ConfigDB
+	Drainer  *fdoshared.Drainer
+}
+
+// Health responds OK while the process is serving requests. DB is not touched
+func (h *HealthAPI) Health(w http.ResponseWriter, r *http.Request) {
+	commonapi.RespondSuccess(w)
+}
+
+// Ready responds OK once the DB is open and seeded, until shutdown starts draining. Only looks up the config key, so probes do not load the DB
+func (h *HealthAPI) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.Drainer != nil && h.Drainer.Draining() {
+		commonapi.RespondError(w, "Server is shutting down!", http.StatusServiceUnavailable)
+		return
+	}
+
+	if h.DB.IsClosed() {
+		commonapi.RespondError(w, "Database is closed!", http.StatusServiceUnavailable)
+		return
+	}
+
+	seeded, err := h.ConfigDB.Exists()
+	if err != nil {
+		log.Println("Readiness check failed. " + err.Error())
+		commonapi.RespondError(w, "Database is not available!", http.StatusServiceUnavailable)
+		return
+	}
+
+	if !seeded {
+		commonapi.RespondError(w, "Database is not seeded!", http.StatusServiceUnavailable)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
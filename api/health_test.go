@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+func TestHealthAPI_Ready(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+
+	configDb := dbs.NewConfigDB(db)
+	healthApi := HealthAPI{DB: db, ConfigDB: configDb}
+
+	probe := func(handler http.HandlerFunc) int {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/", nil))
+		return w.Code
+	}
+
+	if probe(healthApi.Health) != http.StatusOK {
+		t.Errorf("Expected health to be OK")
+	}
+
+	if probe(healthApi.Ready) != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready before seeding")
+	}
+
+	err = configDb.Save(dbs.MainConfig{})
+	if err != nil {
+		t.Fatalf("Failed to save config. %s", err.Error())
+	}
+
+	if probe(healthApi.Ready) != http.StatusOK {
+		t.Errorf("Expected ready after seeding")
+	}
+
+	healthApi.Drainer = fdoshared.NewDrainer()
+	healthApi.Drainer.Drain(0)
+	if probe(healthApi.Ready) != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready while draining")
+	}
+	healthApi.Drainer = nil
+
+	db.Close()
+
+	if probe(healthApi.Ready) != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready with closed DB")
+	}
+
+	if probe(healthApi.Health) != http.StatusOK {
+		t.Errorf("Expected health to be OK with closed DB")
+	}
+}
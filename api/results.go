@@ -1,15 +1,114 @@
 package api
 
 import (
+	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
 )
 
+// Version of the results export schema. Increased on any change of the exported fields
+const RESULTS_EXPORT_VERSION int = 1
+
+type ResultsExportInstType string
+
+const (
+	ResultsExportInst_RV     ResultsExportInstType = "rv"
+	ResultsExportInst_DO     ResultsExportInstType = "do"
+	ResultsExportInst_Device ResultsExportInstType = "device"
+)
+
+type Results_ExportTest struct {
+	TestID testcom.FDOTestID `json:"testId"`
+	Passed bool              `json:"passed"`
+	Error  string            `json:"error"`
+}
+
+type Results_ExportRun struct {
+	TestRunId string                  `json:"testRunId"`
+	Protocol  fdoshared.FdoToProtocol `json:"protocol"`
+	Timestamp int64                   `json:"timestamp"`
+	Tests     []Results_ExportTest    `json:"tests"`
+	// DO runs executed with a selected KEX and cipher suite
+	KexSuiteName    fdoshared.KexSuiteName    `json:"kexSuiteName,omitempty"`
+	CipherSuiteName fdoshared.CipherSuiteName `json:"cipherSuiteName,omitempty"`
+	// DO runs where the owner passed ProveDevice64
+	NegotiatedKexSuiteName    fdoshared.KexSuiteName    `json:"negotiatedKexSuiteName,omitempty"`
+	NegotiatedCipherSuiteName fdoshared.CipherSuiteName `json:"negotiatedCipherSuiteName,omitempty"`
+}
+
+type Results_ExportInst struct {
+	Type ResultsExportInstType `json:"type"`
+	Id   string                `json:"id"`
+	// RV or DO URL, or device name
+	Name string              `json:"name"`
+	Guid string              `json:"guid,omitempty"`
+	Runs []Results_ExportRun `json:"runs"`
+}
+
+type Results_ProtocolSummary struct {
+	Runs   int `json:"runs"`
+	Tests  int `json:"tests"`
+	Passed int `json:"passed"`
+	Failed int `json:"failed"`
+}
+
+type Results_Summary struct {
+	To0 Results_ProtocolSummary `json:"to0"`
+	To1 Results_ProtocolSummary `json:"to1"`
+	To2 Results_ProtocolSummary `json:"to2"`
+}
+
+type Results_Export struct {
+	Version   int                        `json:"version"`
+	Timestamp int64                      `json:"timestamp"`
+	Summary   Results_Summary            `json:"summary"`
+	Insts     []Results_ExportInst       `json:"instances"`
+	Status    commonapi.FdoConfApiStatus `json:"status"`
+}
+
 type ResultsAPI struct {
-	UserDB    *dbs.UserTestDB
-	SessionDB *dbs.SessionDB
+	UserDB     *dbs.UserTestDB
+	SessionDB  *dbs.SessionDB
+	ReqTDB     *testdbs.RequestTestDB
+	ListenerDB *testdbs.ListenerTestDB
+}
+
+func (h *ResultsAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, errors.New("failed to read cookie. " + err.Error())
+	}
+
+	if sessionCookie == nil {
+		return nil, errors.New("cookie does not exists")
+	}
+
+	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil {
+		return nil, errors.New("session expired. " + err.Error())
+	}
+
+	if !sessionInst.LoggedIn {
+		return nil, errors.New("unauthorized")
+	}
+
+	userInst, err := h.UserDB.Get(sessionInst.Email)
+	if err != nil {
+		return nil, errors.New("user does not exists. " + err.Error())
+	}
+
+	return userInst, nil
 }
 
 func (h *ResultsAPI) Submit(w http.ResponseWriter, r *http.Request) {
@@ -18,3 +117,212 @@ func (h *ResultsAPI) Submit(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// newResultsExportRequestRun converts RV or DO test run. Tests are sorted by ID, so the export is stable
+func newResultsExportRequestRun(testRun reqtestsdeps.RequestTestRun) Results_ExportRun {
+	exportRun := Results_ExportRun{
+		TestRunId: testRun.Uuid,
+		Protocol:  testRun.Protocol,
+		Timestamp: testRun.Timestamp,
+		Tests:     []Results_ExportTest{},
+
+		KexSuiteName:    testRun.KexSuiteName,
+		CipherSuiteName: testRun.CipherSuiteName,
+
+		NegotiatedKexSuiteName:    testRun.NegotiatedKexSuiteName,
+		NegotiatedCipherSuiteName: testRun.NegotiatedCipherSuiteName,
+	}
+
+	for _, testId := range testRun.GetAllTestIDs() {
+		testState := testRun.Tests[testId]
+		exportRun.Tests = append(exportRun.Tests, Results_ExportTest{
+			TestID: testId,
+			Passed: testState.Passed,
+			Error:  testState.Error,
+		})
+	}
+
+	sort.Slice(exportRun.Tests, func(i, j int) bool {
+		return exportRun.Tests[i].TestID < exportRun.Tests[j].TestID
+	})
+
+	return exportRun
+}
+
+// newResultsExportListenerRun converts device test run. Tests keep the order they were run in
+func newResultsExportListenerRun(testRun listenertestsdeps.ListenerTestRun) Results_ExportRun {
+	exportRun := Results_ExportRun{
+		TestRunId: testRun.Uuid,
+		Protocol:  testRun.Protocol,
+		Timestamp: testRun.Timestamp,
+		Tests:     []Results_ExportTest{},
+	}
+
+	for _, testState := range testRun.TestRuns {
+		exportRun.Tests = append(exportRun.Tests, Results_ExportTest{
+			TestID: testState.TestID,
+			Passed: testState.Passed,
+			Error:  testState.Error,
+		})
+	}
+
+	return exportRun
+}
+
+// appendResultsExportRuns adds runs matching runId, or all runs when runId is empty
+func appendResultsExportRuns(exportInst *Results_ExportInst, runId string, exportRuns ...Results_ExportRun) {
+	for _, exportRun := range exportRuns {
+		if runId == "" || exportRun.TestRunId == runId {
+			exportInst.Runs = append(exportInst.Runs, exportRun)
+		}
+	}
+}
+
+// NewResultsExport builds export of the given instances, and counts runs and test results per protocol
+func NewResultsExport(exportInsts []Results_ExportInst) Results_Export {
+	export := Results_Export{
+		Version:   RESULTS_EXPORT_VERSION,
+		Timestamp: time.Now().Unix(),
+		Insts:     []Results_ExportInst{},
+		Status:    commonapi.FdoApiStatus_OK,
+	}
+
+	for _, exportInst := range exportInsts {
+		if len(exportInst.Runs) == 0 {
+			continue
+		}
+
+		for _, exportRun := range exportInst.Runs {
+			var protocolSummary *Results_ProtocolSummary
+			switch exportRun.Protocol {
+			case fdoshared.To0:
+				protocolSummary = &export.Summary.To0
+			case fdoshared.To1:
+				protocolSummary = &export.Summary.To1
+			case fdoshared.To2:
+				protocolSummary = &export.Summary.To2
+			default:
+				continue
+			}
+
+			protocolSummary.Runs++
+			for _, exportTest := range exportRun.Tests {
+				protocolSummary.Tests++
+				if exportTest.Passed {
+					protocolSummary.Passed++
+				} else {
+					protocolSummary.Failed++
+				}
+			}
+		}
+
+		export.Insts = append(export.Insts, exportInst)
+	}
+
+	return export
+}
+
+// Export returns RV, DO and device test results of the user as JSON. Optional runId limits the export to one test run
+func (h *ResultsAPI) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	runId := r.URL.Query().Get("runId")
+	exportInsts := []Results_ExportInst{}
+
+	for _, rvtInst := range userInst.RVTestInsts {
+		exportInst := Results_ExportInst{
+			Type: ResultsExportInst_RV,
+			Id:   hex.EncodeToString(rvtInst.Uuid),
+			Name: rvtInst.Url,
+			Runs: []Results_ExportRun{},
+		}
+
+		reqtes, err := h.ReqTDB.GetMany([][]byte{rvtInst.To0, rvtInst.To1})
+		if err != nil {
+			log.Println("Error reading rvts. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		for _, reqte := range *reqtes {
+			for _, testRun := range reqte.TestsHistory {
+				appendResultsExportRuns(&exportInst, runId, newResultsExportRequestRun(testRun))
+			}
+		}
+
+		exportInsts = append(exportInsts, exportInst)
+	}
+
+	for _, dotInst := range userInst.DOTestInsts {
+		exportInst := Results_ExportInst{
+			Type: ResultsExportInst_DO,
+			Id:   hex.EncodeToString(dotInst.Uuid),
+			Name: dotInst.Url,
+			Runs: []Results_ExportRun{},
+		}
+
+		reqte, err := h.ReqTDB.Get(dotInst.To2)
+		if err != nil {
+			log.Println("Error reading dot. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		for _, testRun := range reqte.TestsHistory {
+			appendResultsExportRuns(&exportInst, runId, newResultsExportRequestRun(testRun))
+		}
+
+		if len(dotInst.ListenerTo0) != 0 {
+			reqListener, err := h.ListenerDB.Get(dotInst.ListenerTo0)
+			if err != nil {
+				log.Printf("Failed find TO0 listener for %s. %s", hex.EncodeToString(dotInst.Uuid), err.Error())
+			} else {
+				for _, testRun := range reqListener.To0.GetTestRuns() {
+					appendResultsExportRuns(&exportInst, runId, newResultsExportListenerRun(testRun))
+				}
+			}
+		}
+
+		exportInsts = append(exportInsts, exportInst)
+	}
+
+	for _, devInst := range userInst.DeviceTestInsts {
+		exportInst := Results_ExportInst{
+			Type: ResultsExportInst_Device,
+			Id:   hex.EncodeToString(devInst.ListenerUuid),
+			Name: devInst.Name,
+			Guid: hex.EncodeToString(devInst.DeviceGuid[:]),
+			Runs: []Results_ExportRun{},
+		}
+
+		reqListener, err := h.ListenerDB.Get(devInst.ListenerUuid)
+		if err != nil {
+			log.Printf("Failed find entry for %s. %s", hex.EncodeToString(devInst.Uuid), err.Error())
+			continue
+		}
+
+		for _, testRun := range append(reqListener.To1.GetTestRuns(), reqListener.To2.GetTestRuns()...) {
+			appendResultsExportRuns(&exportInst, runId, newResultsExportListenerRun(testRun))
+		}
+
+		exportInsts = append(exportInsts, exportInst)
+	}
+
+	export := NewResultsExport(exportInsts)
+	if runId != "" && len(export.Insts) == 0 {
+		commonapi.RespondError(w, "Test run not found!", http.StatusNotFound)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, export)
+}
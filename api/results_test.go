@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+func TestNewResultsExport(t *testing.T) {
+	rvRun := newResultsExportRequestRun(reqtestsdeps.RequestTestRun{
+		Uuid:      "rv-run",
+		Timestamp: 1700000000,
+		Protocol:  fdoshared.To0,
+		Tests: reqtestsdeps.RequestTestResultMap{
+			testcom.FIDO_RVT_23_CHECK_RESP: testcom.NewFailTestState(testcom.FIDO_RVT_23_CHECK_RESP, "Bad response"),
+			testcom.FIDO_RVT_21_CHECK_RESP: testcom.NewSuccessTestState(testcom.FIDO_RVT_21_CHECK_RESP),
+		},
+	})
+
+	if len(rvRun.Tests) != 2 || rvRun.Tests[0].TestID != testcom.FIDO_RVT_21_CHECK_RESP || rvRun.Tests[1].Error != "Bad response" {
+		t.Errorf("Expected tests sorted by ID with errors kept. Got %+v", rvRun.Tests)
+	}
+
+	doRun := newResultsExportRequestRun(reqtestsdeps.RequestTestRun{Uuid: "do-run", Protocol: fdoshared.To2, KexSuiteName: fdoshared.KEX_ECDH384, CipherSuiteName: fdoshared.CIPHER_A256GCM})
+	if doRun.KexSuiteName != fdoshared.KEX_ECDH384 || doRun.CipherSuiteName != fdoshared.CIPHER_A256GCM {
+		t.Errorf("Expected run KEX and cipher suite to be exported. Got %s %d", doRun.KexSuiteName, doRun.CipherSuiteName)
+	}
+
+	negotiatedRun := newResultsExportRequestRun(reqtestsdeps.RequestTestRun{Uuid: "do-run", Protocol: fdoshared.To2, NegotiatedKexSuiteName: fdoshared.KEX_DHKEXid15, NegotiatedCipherSuiteName: fdoshared.CIPHER_A128GCM})
+	if negotiatedRun.NegotiatedKexSuiteName != fdoshared.KEX_DHKEXid15 || negotiatedRun.NegotiatedCipherSuiteName != fdoshared.CIPHER_A128GCM || negotiatedRun.KexSuiteName != "" {
+		t.Errorf("Expected negotiated KEX and cipher suite to be exported. Got %+v", negotiatedRun)
+	}
+
+	deviceRun := newResultsExportListenerRun(listenertestsdeps.ListenerTestRun{
+		Uuid:      "device-run",
+		Timestamp: 1700000001,
+		Protocol:  fdoshared.To2,
+		TestRuns:  []testcom.FDOTestState{testcom.NewSuccessTestState(testcom.FIDO_LISTENER_POSITIVE)},
+	})
+
+	deviceInst := Results_ExportInst{Type: ResultsExportInst_Device, Runs: []Results_ExportRun{}}
+	appendResultsExportRuns(&deviceInst, "device-run", rvRun, deviceRun)
+	if len(deviceInst.Runs) != 1 || deviceInst.Runs[0].TestRunId != "device-run" {
+		t.Errorf("Expected only the run matching runId. Got %+v", deviceInst.Runs)
+	}
+
+	export := NewResultsExport([]Results_ExportInst{
+		{Type: ResultsExportInst_RV, Runs: []Results_ExportRun{rvRun}},
+		{Type: ResultsExportInst_DO, Runs: []Results_ExportRun{}},
+		deviceInst,
+	})
+
+	if export.Version != RESULTS_EXPORT_VERSION || len(export.Insts) != 2 {
+		t.Fatalf("Expected versioned export without instances lacking runs. Got %+v", export)
+	}
+
+	if export.Summary.To0 != (Results_ProtocolSummary{Runs: 1, Tests: 2, Passed: 1, Failed: 1}) {
+		t.Errorf("Unexpected TO0 summary %+v", export.Summary.To0)
+	}
+
+	if export.Summary.To1 != (Results_ProtocolSummary{}) || export.Summary.To2 != (Results_ProtocolSummary{Runs: 1, Tests: 1, Passed: 1}) {
+		t.Errorf("Unexpected TO1 or TO2 summary %+v %+v", export.Summary.To1, export.Summary.To2)
+	}
+
+	exportBytes, _ := json.Marshal(export)
+	var decoded map[string]interface{}
+	json.Unmarshal(exportBytes, &decoded)
+	for _, key := range []string{"version", "timestamp", "summary", "instances", "status"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("Expected %s in exported JSON", key)
+		}
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"net/http"
 
 	"github.com/dgraph-io/badger/v4"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/testapi"
 	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
@@ -28,12 +30,19 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 	listenerDb := testdbs.NewListenerTestDB(db)
 	doVoucherDb := dodbs.NewVoucherDB(db)
 
+	runRetention := &testapi.RunRetention{
+		ReqTDB:     rvtDb,
+		ListenerDB: listenerDb,
+		RunPinDB:   testdbs.NewRunPinDB(db),
+	}
+
 	rvtApiHandler := testapi.RVTestMgmtAPI{
 		UserDB:    userDb,
 		ReqTDB:    rvtDb,
 		SessionDB: sessionDb,
 		ConfigDB:  configDb,
 		DevBaseDB: devBaseDb,
+		Retention: runRetention,
 		Ctx:       ctx,
 	}
 
@@ -43,8 +52,25 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 		SessionDB: sessionDb,
 		ConfigDB:  configDb,
 		DevBaseDB: devBaseDb,
+		Retention: runRetention,
+
+		VoucherTagDB: testdbs.NewVoucherTagDB(db),
+		ListenerDB:   listenerDb,
 	}
 
+	campaignApiHandler := testapi.CampaignMgmtAPI{
+		UserDB:     userDb,
+		ReqTDB:     rvtDb,
+		SessionDB:  sessionDb,
+		DevBaseDB:  devBaseDb,
+		CampaignDB: testdbs.NewCampaignDB(db),
+		Retention:  runRetention,
+		Ctx:        ctx,
+	}
+
+	doSessionDb := dodbs.NewSessionDB(db)
+	ownerSignDb := fdorv.NewOwnerSignDB(db)
+
 	deviceApiHandler := testapi.DeviceTestMgmtAPI{
 		UserDB:       userDb,
 		ListenerDB:   listenerDb,
@@ -52,9 +78,21 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 		ConfigDB:     configDb,
 		DevBaseDB:    devBaseDb,
 		DOVouchersDB: doVoucherDb,
+		DOSessionDB:  doSessionDb,
+		OwnerSignDB:  &ownerSignDb,
+		IdentityDB:   dodbs.NewIdentityDB(db),
+		MsgLogDB:     testdbs.NewMessageLogDB(db),
+		MetricsDB:    testdbs.NewEndpointMetricsDB(db),
+		Retention:    runRetention,
 		Ctx:          ctx,
 	}
 
+	runsApiHandler := testapi.RunsMgmtAPI{
+		UserDB:    userDb,
+		SessionDB: sessionDb,
+		Retention: runRetention,
+	}
+
 	userApiHandler := UserAPI{
 		UserDB:    userDb,
 		SessionDB: sessionDb,
@@ -65,28 +103,109 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 		Ctx:          ctx,
 	}
 
+	coseApi := CoseAPI{
+		UserDB:    userDb,
+		SessionDB: sessionDb,
+	}
+
+	debugApi := DebugAPI{
+		UserDB:      userDb,
+		SessionDB:   sessionDb,
+		DOSessionDB: doSessionDb,
+		Ctx:         ctx,
+	}
+
+	resultsApi := ResultsAPI{
+		UserDB:     userDb,
+		SessionDB:  sessionDb,
+		ReqTDB:     rvtDb,
+		ListenerDB: listenerDb,
+	}
+
+	adminApi := AdminAPI{
+		ListenerDB:  listenerDb,
+		DelayDB:     testdbs.NewResponseDelayDB(db),
+		DOSessionDB: doSessionDb,
+		IdentityDB:  dodbs.NewIdentityDB(db),
+		CaptureDB:   testdbs.NewCaptureDB(db),
+		DOVoucherDB: doVoucherDb,
+		UserDB:      userDb,
+		SessionDB:   sessionDb,
+		Ctx:         ctx,
+	}
+
+	healthApi := HealthAPI{
+		DB:       db,
+		ConfigDB: configDb,
+		Drainer:  fdoshared.FdoDrainer,
+	}
+
 	r := mux.NewRouter()
+	r.Use(commonapi.GzipMiddleware)
+	r.HandleFunc("/health", healthApi.Health).Methods("GET")
+	r.HandleFunc("/ready", healthApi.Ready).Methods("GET")
+	r.Handle("/metrics", fdoshared.FdoMetrics).Methods("GET")
 
 	r.HandleFunc("/api/rvt/create", rvtApiHandler.Generate)
 	r.HandleFunc("/api/rvt/testruns", rvtApiHandler.List)
 	r.HandleFunc("/api/rvt/testruns/{testinsthex}/{testrunid}", rvtApiHandler.DeleteTestRun).Methods("DELETE")
+	r.HandleFunc("/api/rvt/testruns/{testinsthex}/{testrunid}/changes", rvtApiHandler.TestRunStateChanges)
 	r.HandleFunc("/api/rvt/execute", rvtApiHandler.Execute)
+	r.HandleFunc("/api/rvt/execute/all", rvtApiHandler.ExecuteAll)
 
 	r.HandleFunc("/api/dot/create", dotApiHandler.Generate)
 	r.HandleFunc("/api/dot/testruns", dotApiHandler.List)
 	r.HandleFunc("/api/dot/testruns/{testinsthex}/{testrunid}", dotApiHandler.DeleteTestRun).Methods("DELETE")
+	r.HandleFunc("/api/dot/testruns/{testinsthex}/{testrunid}/changes", dotApiHandler.TestRunStateChanges)
+	r.HandleFunc("/api/dot/vouchers/tags", dotApiHandler.TagVouchers)
+	r.HandleFunc("/api/dot/vouchers/import", dotApiHandler.ImportVoucher)
 	r.HandleFunc("/api/dot/vouchers/{uuid}", dotApiHandler.GetVouchers)
+	r.HandleFunc("/api/dot/vouchers/{uuid}/tags", dotApiHandler.GetVoucherTags)
+	r.HandleFunc("/api/dot/listener/to0/{testinsthex}", dotApiHandler.StartTo0TestRun).Methods("POST")
 	r.HandleFunc("/api/dot/execute", dotApiHandler.Execute)
+	r.HandleFunc("/api/dot/execute/suite", dotApiHandler.ExecuteSuite)
+
+	r.HandleFunc("/api/campaign/execute", campaignApiHandler.Execute)
+	r.HandleFunc("/api/campaign/pause", campaignApiHandler.Pause)
+	r.HandleFunc("/api/campaign/resume", campaignApiHandler.Resume)
+	r.HandleFunc("/api/campaign/abort", campaignApiHandler.Abort)
+	r.HandleFunc("/api/campaign/status", campaignApiHandler.Status)
+	r.HandleFunc("/api/campaign/report", campaignApiHandler.Report)
+	r.HandleFunc("/api/campaign/report/assertions", campaignApiHandler.ReportAssertions)
 
 	r.HandleFunc("/api/device/create", deviceApiHandler.Generate)
+	r.HandleFunc("/api/device/import", deviceApiHandler.Import)
 	r.HandleFunc("/api/device/testruns", deviceApiHandler.List)
 	r.HandleFunc("/api/device/testruns/{toprotocol}/{testinsthex}/{testrunid}", deviceApiHandler.DeleteTestRun).Methods("DELETE")
 	r.HandleFunc("/api/device/testruns/{toprotocol}/{testinsthex}", deviceApiHandler.StartNewTestRun).Methods("POST")
+	r.HandleFunc("/api/device/messagelog/{testinsthex}", deviceApiHandler.MessageLog)
+
+	r.HandleFunc("/api/results/export", resultsApi.Export)
+
+	r.HandleFunc("/api/runs/pinned", runsApiHandler.Pinned)
+	r.HandleFunc("/api/runs/{testrunid}/pin", runsApiHandler.Pin).Methods("POST", "DELETE")
+	r.HandleFunc("/api/run/replay", runsApiHandler.Replay)
+	r.HandleFunc("/api/run/stream", runsApiHandler.Stream)
 
 	r.HandleFunc("/api/iop/do/add", iopApi.IopAddVoucherToDO)
 	r.HandleFunc("/api/iop/is_iop_only", iopApi.IsOipOnly)
 
-	r.HandleFunc("/api/user/login/onprem", userApiHandler.OnPremNoLogin)
+	r.HandleFunc("/api/tools/cose/inspect", coseApi.Inspect)
+	r.HandleFunc("/api/debug/sessionkeys", debugApi.ExportSessionKeys)
+	r.HandleFunc("/api/admin/reindex", adminApi.RebuildIndexes)
+	r.HandleFunc("/api/admin/delays", adminApi.ResponseDelays)
+	r.HandleFunc("/api/admin/session", adminApi.SessionState)
+	r.HandleFunc("/api/admin/logs", adminApi.StreamLogs)
+	r.HandleFunc("/api/admin/identity", adminApi.Identities)
+	r.HandleFunc("/api/admin/sessions", adminApi.UserSessions)
+	r.HandleFunc("/api/admin/users/role", adminApi.UserRole)
+	r.HandleFunc("/api/capture", adminApi.Captures)
+	r.HandleFunc("/api/do/vouchers", adminApi.DOVouchers)
+	r.HandleFunc("/api/do/vouchers/generate", adminApi.GenerateVouchers)
+	r.HandleFunc("/api/do/vouchers/validate", dotApiHandler.ValidateVoucher)
+	r.HandleFunc("/api/do/vouchers/{guid}", deviceApiHandler.DeleteVoucher).Methods("DELETE")
+
+	r.HandleFunc("/api/user/login/onprem", commonapi.LoginLimiter.Limit(userApiHandler.OnPremNoLogin))
 	r.HandleFunc("/api/user/loggedin", userApiHandler.UserLoggedIn)
 	r.HandleFunc("/api/user/logout", userApiHandler.Logout)
 	r.HandleFunc("/api/user/purgetests", userApiHandler.PurgeTests)
@@ -0,0 +1,379 @@
+package testapi
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/testexec"
+)
+
+// CampaignMgmtAPI runs RV and DO tests of a full certification together, and reports them as one
+type CampaignMgmtAPI struct {
+	UserDB     *dbs.UserTestDB
+	ReqTDB     *testdbs.RequestTestDB
+	DevBaseDB  *dbs.DeviceBaseDB
+	SessionDB  *dbs.SessionDB
+	CampaignDB *testdbs.CampaignDB
+	Retention  *RunRetention
+	Ctx        context.Context
+}
+
+func (h *CampaignMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, errors.New("Failed to read cookie. " + err.Error())
+	}
+
+	if sessionCookie == nil {
+		return nil, errors.New("cookie does not exists")
+	}
+
+	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil {
+		return nil, errors.New("session expired. " + err.Error())
+	}
+
+	if !sessionInst.LoggedIn {
+		return nil, errors.New("unauthorized!")
+	}
+
+	userInst, err := h.UserDB.Get(sessionInst.Email)
+	if err != nil {
+		return nil, errors.New("user does not exists. " + err.Error())
+	}
+
+	return userInst, nil
+}
+
+// getCampaignInsts returns TO0, TO1 and TO2 test instances of the user RVT and DOT entries
+func (h *CampaignMgmtAPI) getCampaignInsts(userInst *dbs.UserTestDBEntry, campaignReq Campaign_RequestInfo) (*[]reqtestsdeps.RequestTestInst, error) {
+	rvtId, err := hex.DecodeString(campaignReq.RvtId)
+	if err != nil {
+		return nil, errors.New("can not decode hex rvtId. " + err.Error())
+	}
+
+	dotId, err := hex.DecodeString(campaignReq.DotId)
+	if err != nil {
+		return nil, errors.New("can not decode hex dotId. " + err.Error())
+	}
+
+	rvtInst, ok := userInst.RVT_GetInst(rvtId)
+	if !ok {
+		return nil, errors.New("rvtId does not belong to user")
+	}
+
+	dotInst, ok := userInst.DOT_GetInst(dotId)
+	if !ok {
+		return nil, errors.New("dotId does not belong to user")
+	}
+
+	return h.ReqTDB.GetMany([][]byte{rvtInst.To0, rvtInst.To1, dotInst.To2})
+}
+
+func (h *CampaignMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var campaignReq Campaign_RequestInfo
+	err = json.Unmarshal(bodyBytes, &campaignReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	reqtes, err := h.getCampaignInsts(userInst, campaignReq)
+	if err != nil {
+		log.Println("Can not get campaign entries. " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	for _, reqte := range *reqtes {
+		err = fdoshared.Outbound.CheckURL(reqte.URL)
+		if err != nil {
+			log.Println("URL not allowed. " + err.Error())
+			commonapi.RespondError(w, "URL not allowed! "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	campaignId := campaignReq.CampaignId()
+	control, err := testexec.StartCampaignControl(campaignId, func(state testdbs.CampaignState) {
+		err := h.CampaignDB.Save(campaignId, state)
+		if err != nil {
+			log.Printf("Failed to save campaign %s state. %s", campaignId, err.Error())
+		}
+	})
+	if err != nil {
+		commonapi.RespondError(w, "Campaign is already running!", http.StatusConflict)
+		return
+	}
+
+	h.Retention.Enforce(userInst, 3)
+
+	// Campaign outlives the request. Follow it with /api/campaign/status
+	go func() {
+		defer testexec.EndCampaignControl(campaignId)
+
+		testexec.ExecuteCampaign((*reqtes)[0], (*reqtes)[1], (*reqtes)[2], h.ReqTDB, h.DevBaseDB, h.Ctx, control)
+	}()
+
+	commonapi.RespondSuccessStruct(w, Campaign_Checkpoint{
+		CampaignState: control.Checkpoint(),
+		Status:        commonapi.FdoApiStatus_OK,
+	})
+}
+
+// getRunningCampaignControl returns control of the user campaign requested in the body
+func (h *CampaignMgmtAPI) getRunningCampaignControl(w http.ResponseWriter, r *http.Request) (*testexec.CampaignControl, bool) {
+	if !commonapi.CheckHeaders(w, r) {
+		return nil, false
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var campaignReq Campaign_RequestInfo
+	err = json.Unmarshal(bodyBytes, &campaignReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return nil, false
+	}
+
+	_, err = h.getCampaignInsts(userInst, campaignReq)
+	if err != nil {
+		log.Println("Can not get campaign entries. " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return nil, false
+	}
+
+	control, ok := testexec.GetCampaignControl(campaignReq.CampaignId())
+	if !ok {
+		commonapi.RespondError(w, "Campaign is not running!", http.StatusNotFound)
+		return nil, false
+	}
+
+	return control, true
+}
+
+// Pause stops dispatching new campaign tests. Tests in flight finish, and the campaign waits for Resume. Responds with checkpoint
+func (h *CampaignMgmtAPI) Pause(w http.ResponseWriter, r *http.Request) {
+	control, ok := h.getRunningCampaignControl(w, r)
+	if !ok {
+		return
+	}
+
+	control.Pause()
+
+	commonapi.RespondSuccessStruct(w, Campaign_Checkpoint{
+		CampaignState: control.Checkpoint(),
+		Status:        commonapi.FdoApiStatus_OK,
+	})
+}
+
+// Resume continues paused campaign from its checkpoint, within the same test runs
+func (h *CampaignMgmtAPI) Resume(w http.ResponseWriter, r *http.Request) {
+	control, ok := h.getRunningCampaignControl(w, r)
+	if !ok {
+		return
+	}
+
+	control.Resume()
+
+	commonapi.RespondSuccessStruct(w, Campaign_Checkpoint{
+		CampaignState: control.Checkpoint(),
+		Status:        commonapi.FdoApiStatus_OK,
+	})
+}
+
+// Abort stops dispatching campaign tests, also when paused. Tests in flight finish, and test runs are finished with the results so far
+func (h *CampaignMgmtAPI) Abort(w http.ResponseWriter, r *http.Request) {
+	control, ok := h.getRunningCampaignControl(w, r)
+	if !ok {
+		return
+	}
+
+	control.Abort()
+
+	commonapi.RespondSuccessStruct(w, Campaign_Checkpoint{
+		CampaignState: control.Checkpoint(),
+		Status:        commonapi.FdoApiStatus_OK,
+	})
+}
+
+// Status returns checkpoint of running campaign, or saved state of the last one. Campaign saved as running or paused, with no control, was interrupted by server restart
+func (h *CampaignMgmtAPI) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	campaignReq := Campaign_RequestInfo{
+		RvtId: r.URL.Query().Get("rvtId"),
+		DotId: r.URL.Query().Get("dotId"),
+	}
+
+	_, err = h.getCampaignInsts(userInst, campaignReq)
+	if err != nil {
+		log.Println("Can not get campaign entries. " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	if control, ok := testexec.GetCampaignControl(campaignReq.CampaignId()); ok {
+		commonapi.RespondSuccessStruct(w, Campaign_Checkpoint{
+			CampaignState: control.Checkpoint(),
+			Status:        commonapi.FdoApiStatus_OK,
+		})
+		return
+	}
+
+	state, err := h.CampaignDB.Get(campaignReq.CampaignId())
+	if err != nil {
+		log.Println("Can not get campaign state. " + err.Error())
+		commonapi.RespondError(w, "Internal server error!", http.StatusInternalServerError)
+		return
+	}
+
+	if state == nil {
+		commonapi.RespondError(w, "Campaign never ran!", http.StatusNotFound)
+		return
+	}
+
+	if state.State == testdbs.CAMPAIGN_STATE_RUNNING || state.State == testdbs.CAMPAIGN_STATE_PAUSED {
+		state.State = testdbs.CAMPAIGN_STATE_INTERRUPTED
+	}
+
+	commonapi.RespondSuccessStruct(w, Campaign_Checkpoint{
+		CampaignState: *state,
+		Status:        commonapi.FdoApiStatus_OK,
+	})
+}
+
+// Report returns latest TO0, TO1 and TO2 results with overall verdict. Encoded as JSON, CBOR, msgpack or JUnit XML based on Accept header
+func (h *CampaignMgmtAPI) Report(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	campaignReq := Campaign_RequestInfo{
+		RvtId: r.URL.Query().Get("rvtId"),
+		DotId: r.URL.Query().Get("dotId"),
+	}
+
+	reqtes, err := h.getCampaignInsts(userInst, campaignReq)
+	if err != nil {
+		log.Println("Can not get campaign entries. " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	campaignReport := reqtestsdeps.NewCampaignReport(*reqtes...)
+
+	if commonapi.AcceptsXML(r) {
+		junitBytes, err := campaignReport.JUnit()
+		if err != nil {
+			log.Println("Failed to encode JUnit report. " + err.Error())
+			commonapi.RespondError(w, "Failed to encode response!", http.StatusInternalServerError)
+			return
+		}
+
+		commonapi.RespondSuccessXML(w, junitBytes)
+		return
+	}
+
+	commonapi.RespondSuccessStructNegotiated(w, r, Campaign_Report{
+		CampaignReport: campaignReport,
+		Status:         commonapi.FdoApiStatus_OK,
+	})
+}
+
+// ReportAssertions returns latest TO0, TO1 and TO2 results grouped by FDO spec assertion, with coverage and verdict per assertion
+func (h *CampaignMgmtAPI) ReportAssertions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	campaignReq := Campaign_RequestInfo{
+		RvtId: r.URL.Query().Get("rvtId"),
+		DotId: r.URL.Query().Get("dotId"),
+	}
+
+	reqtes, err := h.getCampaignInsts(userInst, campaignReq)
+	if err != nil {
+		log.Println("Can not get campaign entries. " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	campaignReport := reqtestsdeps.NewCampaignReport(*reqtes...)
+
+	commonapi.RespondSuccessStructNegotiated(w, r, Campaign_AssertionReport{
+		FDOAssertionReport: testcom.NewAssertionReport(campaignReport.TestStates()),
+		Timestamp:          campaignReport.Timestamp,
+		Passed:             campaignReport.Passed,
+		Status:             commonapi.FdoApiStatus_OK,
+	})
+}
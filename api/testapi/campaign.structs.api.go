@@ -0,0 +1,35 @@
+package testapi
+
+import (
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+type Campaign_RequestInfo struct {
+	RvtId string `json:"rvtId"`
+	DotId string `json:"dotId"`
+}
+
+// CampaignId identifies running campaign by its RVT and DOT entries
+func (h Campaign_RequestInfo) CampaignId() string {
+	return h.RvtId + ":" + h.DotId
+}
+
+type Campaign_Checkpoint struct {
+	testdbs.CampaignState
+	Status commonapi.FdoConfApiStatus `json:"status"`
+}
+
+type Campaign_Report struct {
+	reqtestsdeps.CampaignReport
+	Status commonapi.FdoConfApiStatus `json:"status"`
+}
+
+type Campaign_AssertionReport struct {
+	testcom.FDOAssertionReport
+	Timestamp int64                      `json:"timestamp"`
+	Passed    bool                       `json:"passed"`
+	Status    commonapi.FdoConfApiStatus `json:"status"`
+}
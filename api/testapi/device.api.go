@@ -10,11 +10,13 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	fdodocommon "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/common"
 	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to0"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testcomdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
@@ -31,13 +33,20 @@ type DeviceTestMgmtAPI struct {
 	SessionDB    *dbs.SessionDB
 	ConfigDB     *dbs.ConfigDB
 	DOVouchersDB *dodbs.VoucherDB
+	DOSessionDB  *dodbs.SessionDB
+	OwnerSignDB  *fdorv.OwnerSignDB
+	IdentityDB   *dodbs.IdentityDB
+	MsgLogDB     *testcomdbs.MessageLogDB
+	MetricsDB    *testcomdbs.EndpointMetricsDB
+	Retention    *RunRetention
 	Ctx          context.Context
 }
 
-func (h *DeviceTestMgmtAPI) submitToRvOwnerSign(voucherdbe *fdoshared.VoucherDBEntry) error {
+func (h *DeviceTestMgmtAPI) submitToRvOwnerSign(voucherdbe *fdoshared.VoucherDBEntry, to2Addr *fdoshared.To2AddrConfig) error {
 	to0client := to0.NewTo0Requestor(fdoshared.SRVEntry{
 		SrvURL: h.Ctx.Value(fdoshared.CFG_ENV_FDO_SERVICE_URL).(string),
 	}, *voucherdbe, h.Ctx)
+	to0client.SetTo2Addr(to2Addr)
 
 	helloAck21, _, err := to0client.Hello20(testcom.NULL_TEST)
 	if err != nil {
@@ -84,6 +93,160 @@ func (h *DeviceTestMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestD
 	return userInst, nil
 }
 
+// readVoucherUploadBody reads request body of voucher upload, up to the size of the max number of max size files
+func readVoucherUploadBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	maxRequestSize := fdoshared.UploadLimits.MaxRequestSize()
+
+	bodyBytes, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if int64(len(bodyBytes)) > maxRequestSize {
+		commonapi.RespondError(w, fmt.Sprintf("Request exceeds %d bytes. See voucher upload limits", maxRequestSize), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+
+	return bodyBytes, true
+}
+
+// voucherStorageSize returns CBOR encoded size of voucher, as counted against user voucher storage
+func voucherStorageSize(voucherEntry fdoshared.VoucherDBEntry) int {
+	voucherBytes, _ := fdoshared.CborCust.Marshal(voucherEntry)
+	return len(voucherBytes)
+}
+
+// userVoucherStorage returns CBOR encoded size of vouchers stored for user device test instances
+func (h *DeviceTestMgmtAPI) userVoucherStorage(userInst *dbs.UserTestDBEntry) int {
+	storageSize := 0
+	for _, devInst := range userInst.DeviceTestInsts {
+		reqListener, err := h.ListenerDB.Get(devInst.ListenerUuid)
+		if err != nil {
+			log.Printf("Skipping voucher storage of %s. %s", hex.EncodeToString(devInst.ListenerUuid), err.Error())
+			continue
+		}
+
+		storageSize += voucherStorageSize(reqListener.TestVoucher)
+	}
+
+	return storageSize
+}
+
+// decodeVoucherUploads checks uploaded voucher files against upload limits, and decodes them. Nothing is stored when any file is rejected.
+// Storage quota is checked early here to skip RV and DO submissions, and again by addDeviceTestInsts when device tests are added
+func (h *DeviceTestMgmtAPI) decodeVoucherUploads(userInst *dbs.UserTestDBEntry, testCases []Device_CreateTestCase) ([]*fdoshared.VoucherDBEntry, error) {
+	err := fdoshared.UploadLimits.CheckFilesCount(len(testCases))
+	if err != nil {
+		return nil, err
+	}
+
+	vandvs := []*fdoshared.VoucherDBEntry{}
+	addedSize := 0
+	for i, testCase := range testCases {
+		if len(testCase.Name) == 0 || len(testCase.VoucherAndPrivateKey) == 0 {
+			return nil, fmt.Errorf("voucher %d: missing name or voucher", i)
+		}
+
+		err := fdoshared.UploadLimits.CheckFile([]byte(testCase.VoucherAndPrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("voucher %d: %s", i, err.Error())
+		}
+
+		newVand, err := fdodocommon.DecodePemVoucherAndKey(testCase.VoucherAndPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("voucher %d: failed to decode voucher. %s", i, err.Error())
+		}
+
+		addedSize += voucherStorageSize(*newVand)
+		vandvs = append(vandvs, newVand)
+	}
+
+	err = fdoshared.UploadLimits.CheckUserStorage(h.userVoucherStorage(userInst), addedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return vandvs, nil
+}
+
+// createDeviceTestInst registers voucher with RV and DO, and saves its listener. Returned device test instance is not yet added to the user
+func (h *DeviceTestMgmtAPI) createDeviceTestInst(name string, newVand *fdoshared.VoucherDBEntry, to2Addr *fdoshared.To2AddrConfig) (*dbs.DeviceTestInst, error) {
+	err := h.submitToRvOwnerSign(newVand, to2Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed submit owner sign to RV! %s", err.Error())
+	}
+
+	err = h.submitVoucherToDO(newVand)
+	if err != nil {
+		return nil, fmt.Errorf("error submitting voucher to DO! %s", err.Error())
+	}
+
+	ovHeader, _ := newVand.Voucher.GetOVHeader()
+
+	deviceListenerInsts := listenertestsdeps.NewDevice_RequestListenerInst(*newVand, ovHeader.OVGuid)
+	err = h.ListenerDB.Save(deviceListenerInsts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save device test! %s", err.Error())
+	}
+
+	devInst := dbs.NewDeviceTestInst(name, deviceListenerInsts.Uuid, ovHeader.OVGuid)
+	return &devInst, nil
+}
+
+// removeDeviceTestInst undoes createDeviceTestInst for device test that was not added to the user
+func (h *DeviceTestMgmtAPI) removeDeviceTestInst(devInst dbs.DeviceTestInst) {
+	err := h.DOVouchersDB.Delete(devInst.DeviceGuid)
+	if err != nil {
+		log.Printf("Failed to remove voucher %s. %s", devInst.DeviceGuid.GetFormatted(), err.Error())
+	}
+
+	err = h.OwnerSignDB.Delete(devInst.DeviceGuid)
+	if err != nil {
+		log.Printf("Failed to remove OwnerSign of %s. %s", devInst.DeviceGuid.GetFormatted(), err.Error())
+	}
+
+	err = h.ListenerDB.Delete(devInst.ListenerUuid)
+	if err != nil {
+		log.Printf("Failed to remove device test %s. %s", hex.EncodeToString(devInst.ListenerUuid), err.Error())
+	}
+}
+
+// addDeviceTestInsts checks user voucher storage quota and adds device test instances in the same transaction, so concurrent uploads can not exceed the quota.
+// Device tests that could not be added are removed
+func (h *DeviceTestMgmtAPI) addDeviceTestInsts(userInst *dbs.UserTestDBEntry, devInsts []dbs.DeviceTestInst, addedSize int) error {
+	err := h.UserDB.Update(userInst.Email, func(usere *dbs.UserTestDBEntry) error {
+		err := fdoshared.UploadLimits.CheckUserStorage(h.userVoucherStorage(usere), addedSize)
+		if err != nil {
+			return err
+		}
+
+		usere.DeviceTestInsts = append(usere.DeviceTestInsts, devInsts...)
+		return nil
+	})
+	if err != nil {
+		for _, devInst := range devInsts {
+			h.removeDeviceTestInst(devInst)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// respondAddDeviceTestInstsError responds with error of addDeviceTestInsts
+func respondAddDeviceTestInstsError(w http.ResponseWriter, err error) {
+	if errors.Is(err, fdoshared.ErrUserStorageQuota) {
+		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Println("Failed to save user. " + err.Error())
+	commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+}
+
 func (h *DeviceTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 	if !commonapi.CheckHeaders(w, r) {
 		return
@@ -96,10 +259,8 @@ func (h *DeviceTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Println("Failed to read body. " + err.Error())
-		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+	bodyBytes, ok := readVoucherUploadBody(w, r)
+	if !ok {
 		return
 	}
 
@@ -117,47 +278,116 @@ func (h *DeviceTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newVand, err := fdodocommon.DecodePemVoucherAndKey(createTestCase.VoucherAndPrivateKey)
+	if createTestCase.To2Addr != nil {
+		err = createTestCase.To2Addr.Validate()
+		if err != nil {
+			commonapi.RespondError(w, "Invalid TO2 address. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	newVands, err := h.decodeVoucherUploads(userInst, []Device_CreateTestCase{createTestCase})
 	if err != nil {
 		log.Println("Failed to decode voucher. " + err.Error())
 		commonapi.RespondError(w, "Failed to decode voucher! "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = h.submitToRvOwnerSign(newVand)
+	devInst, err := h.createDeviceTestInst(createTestCase.Name, newVands[0], createTestCase.To2Addr)
 	if err != nil {
-		log.Println("Failed submit owner sign to RV! " + err.Error())
-		commonapi.RespondError(w, "Failed submit owner sign to RV! "+err.Error(), http.StatusInternalServerError)
+		log.Println("Failed to create device test. " + err.Error())
+		commonapi.RespondError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = h.submitVoucherToDO(newVand)
+	err = h.addDeviceTestInsts(userInst, []dbs.DeviceTestInst{*devInst}, voucherStorageSize(*newVands[0]))
 	if err != nil {
-		log.Println("Error submitting voucher to DO " + err.Error())
-		commonapi.RespondError(w, "Error submitting voucher to DO! "+err.Error(), http.StatusInternalServerError)
+		respondAddDeviceTestInstsError(w, err)
 		return
 	}
 
-	ovHeader, _ := newVand.Voucher.GetOVHeader()
+	commonapi.RespondSuccess(w)
+}
 
-	deviceListenerInsts := listenertestsdeps.NewDevice_RequestListenerInst(*newVand, ovHeader.OVGuid)
-	err = h.ListenerDB.Save(deviceListenerInsts)
+// Import creates device tests from multiple vouchers. All files are checked against upload limits before any is imported
+func (h *DeviceTestMgmtAPI) Import(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
 	if err != nil {
-		log.Println("Failed to decode voucher. " + err.Error())
-		commonapi.RespondError(w, "Failed to decode voucher! "+err.Error(), http.StatusBadRequest)
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	userInst.DeviceTestInsts = append(userInst.DeviceTestInsts, dbs.NewDeviceTestInst(createTestCase.Name, deviceListenerInsts.Uuid, ovHeader.OVGuid))
+	bodyBytes, ok := readVoucherUploadBody(w, r)
+	if !ok {
+		return
+	}
 
-	err = h.UserDB.Save(*userInst)
+	var importReq Device_ImportRequest
+	err = json.Unmarshal(bodyBytes, &importReq)
 	if err != nil {
-		log.Println("Failed to save user. " + err.Error())
-		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
 		return
 	}
 
-	commonapi.RespondSuccess(w)
+	for i, createTestCase := range importReq.Vouchers {
+		if createTestCase.To2Addr == nil {
+			continue
+		}
+
+		err = createTestCase.To2Addr.Validate()
+		if err != nil {
+			commonapi.RespondError(w, fmt.Sprintf("Invalid TO2 address of voucher %d. %s", i, err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	newVands, err := h.decodeVoucherUploads(userInst, importReq.Vouchers)
+	if err != nil {
+		log.Println("Failed to decode vouchers. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode vouchers! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	devInsts := []dbs.DeviceTestInst{}
+	addedSize := 0
+	var importErr error
+	for i, newVand := range newVands {
+		devInst, err := h.createDeviceTestInst(importReq.Vouchers[i].Name, newVand, importReq.Vouchers[i].To2Addr)
+		if err != nil {
+			importErr = fmt.Errorf("voucher %d: %s", i, err.Error())
+			break
+		}
+
+		devInsts = append(devInsts, *devInst)
+		addedSize += voucherStorageSize(*newVand)
+	}
+
+	// Keep device tests created before the failure
+	imported := len(devInsts)
+	if imported != 0 {
+		err = h.addDeviceTestInsts(userInst, devInsts, addedSize)
+		if err != nil {
+			respondAddDeviceTestInstsError(w, err)
+			return
+		}
+	}
+
+	if importErr != nil {
+		log.Println("Failed to import vouchers. " + importErr.Error())
+		commonapi.RespondError(w, fmt.Sprintf("Imported %d of %d vouchers. %s", imported, len(newVands), importErr.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, Device_ImportResponse{
+		Imported: imported,
+		Status:   commonapi.FdoApiStatus_OK,
+	})
 }
 
 func (h *DeviceTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
@@ -173,11 +403,27 @@ func (h *DeviceTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Optional filter by device GUID
+	var guidFilter *fdoshared.FdoGuid
+	if guidStr := r.URL.Query().Get("guid"); guidStr != "" {
+		guid, err := fdoshared.ParseFdoGuid(guidStr)
+		if err != nil {
+			commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		guidFilter = &guid
+	}
+
 	listDeviceRuns := Device_ListRuns{
 		DeviceItems: []Device_Item{},
 	}
 
 	for _, devInsts := range userInst.DeviceTestInsts {
+		if guidFilter != nil && devInsts.DeviceGuid != *guidFilter {
+			continue
+		}
+
 		reqListener, err := h.ListenerDB.Get(devInsts.ListenerUuid)
 		if err != nil {
 			log.Printf("Failed find entry for %s. %s", hex.EncodeToString(devInsts.Uuid), err.Error())
@@ -198,18 +444,30 @@ func (h *DeviceTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 			to2testRunHistory = reqListener.To2.TestRunHistory
 		}
 
+		runMetrics := map[string]testcomdbs.RunEndpointMetrics{}
+		for _, testRun := range append(append([]listenertestsdeps.ListenerTestRun{}, to1testRunHistory...), to2testRunHistory...) {
+			metrics, err := h.MetricsDB.Get(testRun.Uuid)
+			if err != nil {
+				log.Printf("Failed to get endpoint metrics for %s. %s", testRun.Uuid, err.Error())
+				continue
+			}
+
+			runMetrics[testRun.Uuid] = metrics
+		}
+
 		listDeviceRuns.DeviceItems = append(listDeviceRuns.DeviceItems, Device_Item{
-			Id:   hex.EncodeToString(reqListener.Uuid),
-			Name: devInsts.Name,
-			Guid: hex.EncodeToString(devInsts.DeviceGuid[:]),
-			To1:  to1testRunHistory,
-			To2:  to2testRunHistory,
+			Id:      hex.EncodeToString(reqListener.Uuid),
+			Name:    devInsts.Name,
+			Guid:    hex.EncodeToString(devInsts.DeviceGuid[:]),
+			To1:     to1testRunHistory,
+			To2:     to2testRunHistory,
+			Metrics: runMetrics,
 		})
 	}
 
 	listDeviceRuns.Status = commonapi.FdoApiStatus_OK
 
-	commonapi.RespondSuccessStruct(w, listDeviceRuns)
+	commonapi.RespondSuccessStructNegotiated(w, r, listDeviceRuns)
 }
 
 func (h *DeviceTestMgmtAPI) StartNewTestRun(w http.ResponseWriter, r *http.Request) {
@@ -252,6 +510,9 @@ func (h *DeviceTestMgmtAPI) StartNewTestRun(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Evict before reading the entry, so the update below does not restore evicted runs
+	h.Retention.Enforce(userInst, 1)
+
 	reqListInst, err := h.ListenerDB.Get(testInstIdBytes)
 	if err != nil {
 		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
@@ -264,6 +525,93 @@ func (h *DeviceTestMgmtAPI) StartNewTestRun(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Devices have no TO0 tests. TO0 owner tests are run on DO tests
+	if len(runnerInst.Tests) == 0 {
+		commonapi.RespondError(w, "No tests for this protocol!", http.StatusBadRequest)
+		return
+	}
+
+	// Optional body. Empty body starts run with default settings
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var startRunReq Device_StartTestRunRequest
+	if len(bodyBytes) != 0 {
+		err = json.Unmarshal(bodyBytes, &startRunReq)
+		if err != nil {
+			commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if startRunReq.ServiceInfo != nil {
+		if toPInt != int64(fdoshared.To2) {
+			commonapi.RespondError(w, "ServiceInfo settings are only supported for TO2!", http.StatusBadRequest)
+			return
+		}
+
+		err = startRunReq.ServiceInfo.Validate()
+		if err != nil {
+			commonapi.RespondError(w, "Invalid ServiceInfo settings. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if startRunReq.To1dOwnerMismatch && toPInt != int64(fdoshared.To2) {
+		commonapi.RespondError(w, "To1d owner mismatch is only supported for TO2!", http.StatusBadRequest)
+		return
+	}
+
+	if startRunReq.To1dCountersign != "" {
+		if toPInt != int64(fdoshared.To2) {
+			commonapi.RespondError(w, "To1d countersignature is only supported for TO2!", http.StatusBadRequest)
+			return
+		}
+
+		if !listenertestsdeps.IsTo1dCountersignCase(startRunReq.To1dCountersign) {
+			commonapi.RespondError(w, fmt.Sprintf("Unknown To1d countersignature case %s. Expected one of %v", startRunReq.To1dCountersign, listenertestsdeps.To1dCountersignCases), http.StatusBadRequest)
+			return
+		}
+
+		if !fdoshared.RvCountersignTo1d {
+			commonapi.RespondError(w, "RV does not countersign To1d. Set RV_COUNTERSIGN_TO1D", http.StatusBadRequest)
+			return
+		}
+
+		rvIdentity, err := h.IdentityDB.Get(fdoshared.IDENTITY_ROLE_RV)
+		if err != nil || rvIdentity == nil {
+			commonapi.RespondError(w, "RV does not countersign To1d without RV identity. See /api/admin/identity", http.StatusBadRequest)
+			return
+		}
+
+		if startRunReq.To1dOwnerMismatch {
+			commonapi.RespondError(w, "To1d countersignature can not be combined with To1d owner mismatch!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if startRunReq.CanonicalCbor && toPInt != int64(fdoshared.To1) && toPInt != int64(fdoshared.To2) {
+		commonapi.RespondError(w, "Canonical CBOR check is only supported for TO1 and TO2!", http.StatusBadRequest)
+		return
+	}
+
+	runnerInst.CanonicalCbor = startRunReq.CanonicalCbor
+
+	if toPInt == int64(fdoshared.To2) {
+		reqListInst.RVBypassIgnored = false
+		reqListInst.To1dOwnerMismatch = startRunReq.To1dOwnerMismatch
+		reqListInst.To1dOwnerMismatchServed = false
+		reqListInst.To1dCountersign = startRunReq.To1dCountersign
+		reqListInst.To1dCountersignServed = false
+		reqListInst.To2ServiceInfo = listenertestsdeps.To2ServiceInfoConfig{}
+		if startRunReq.ServiceInfo != nil {
+			reqListInst.To2ServiceInfo = *startRunReq.ServiceInfo
+		}
+	}
+
 	runnerInst.StartNewTestRun()
 
 	err = h.ListenerDB.Update(reqListInst)
@@ -321,3 +669,115 @@ func (h *DeviceTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request
 
 	commonapi.RespondSuccess(w)
 }
+
+// MessageLog exports raw TO1/TO2 messages exchanged with the device, with direction, timestamp and HTTP headers. Bodies are hex encoded
+func (h *DeviceTestMgmtAPI) MessageLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	testinsthex := mux.Vars(r)["testinsthex"]
+	if len(testinsthex) == 0 {
+		commonapi.RespondError(w, "Missing testInstID!", http.StatusBadRequest)
+		return
+	}
+
+	testInstIdBytes, err := hex.DecodeString(testinsthex)
+	if err != nil {
+		commonapi.RespondError(w, "Failed to decode test inst id!", http.StatusBadRequest)
+		return
+	}
+
+	if !userInst.DeviceT_ContainID(testInstIdBytes) {
+		commonapi.RespondError(w, "Invalid test id!", http.StatusBadRequest)
+		return
+	}
+
+	msgLogEntries, err := h.MsgLogDB.Get(testInstIdBytes)
+	if err != nil {
+		log.Println("Failed to read message log. " + err.Error())
+		commonapi.RespondError(w, "Failed to read message log!", http.StatusInternalServerError)
+		return
+	}
+
+	exportEntries := []Device_MessageLogEntry{}
+	for _, msgLogEntry := range msgLogEntries {
+		exportEntries = append(exportEntries, Device_MessageLogEntry{
+			Direction:  string(msgLogEntry.Direction),
+			Timestamp:  time.Unix(0, msgLogEntry.Timestamp).UTC().Format(time.RFC3339Nano),
+			Protocol:   int(msgLogEntry.Protocol),
+			Cmd:        int(msgLogEntry.Cmd),
+			SessionId:  msgLogEntry.SessionId,
+			HttpStatus: msgLogEntry.HttpStatus,
+			Headers:    msgLogEntry.Headers,
+			Body:       hex.EncodeToString(msgLogEntry.Body),
+		})
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"fdo-messages-%s.json\"", testinsthex))
+	commonapi.RespondSuccessStruct(w, exportEntries)
+}
+
+// DeleteVoucher removes voucher of the user device test from DO, together with its TO2 session and RV OwnerSign, so the device can not onboard with it.
+// Device test instance and its runs are kept
+func (h *DeviceTestMgmtAPI) DeleteVoucher(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	guid, err := fdoshared.ParseFdoGuid(mux.Vars(r)["guid"])
+	if err != nil {
+		commonapi.RespondError(w, "Invalid GUID! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Vouchers of other users are reported as missing
+	if !userInst.DeviceT_ContainGuid(guid) {
+		commonapi.RespondError(w, "Voucher not found!", http.StatusNotFound)
+		return
+	}
+
+	err = h.DOVouchersDB.Delete(guid)
+	if errors.Is(err, dodbs.ErrVoucherNotFound) {
+		commonapi.RespondError(w, "Voucher not found!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println("Failed to delete voucher. " + err.Error())
+		commonapi.RespondError(w, "Failed to delete voucher!", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.DOSessionDB.DeleteGuidSessions(guid)
+	if err != nil {
+		log.Println("Failed to delete voucher TO2 session. " + err.Error())
+		commonapi.RespondError(w, "Failed to delete voucher session!", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.OwnerSignDB.Delete(guid)
+	if err != nil {
+		log.Println("Failed to delete voucher OwnerSign. " + err.Error())
+		commonapi.RespondError(w, "Failed to delete voucher OwnerSign!", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Deleted voucher %s", guid.GetFormatted())
+
+	commonapi.RespondSuccess(w)
+}
@@ -0,0 +1,101 @@
+package testapi
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	testcomdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+func TestAddDeviceTestInsts_ConcurrentUploadsKeepQuota(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	ownerSignDB := fdorv.NewOwnerSignDB(db)
+	deviceApi := DeviceTestMgmtAPI{
+		UserDB:       dbs.NewUserTestDB(db),
+		ListenerDB:   testcomdbs.NewListenerTestDB(db),
+		DOVouchersDB: dodbs.NewVoucherDB(db),
+		OwnerSignDB:  &ownerSignDB,
+	}
+
+	userInst := dbs.UserTestDBEntry{
+		Email:  "tester@example.com",
+		Status: dbs.AS_Validated,
+	}
+	err = deviceApi.UserDB.Save(userInst)
+	if err != nil {
+		t.Fatalf("Failed to save user. %s", err.Error())
+	}
+
+	voucherEntry := fdoshared.VoucherDBEntry{PrivateKeyX509: bytes.Repeat([]byte{0x01}, 128)}
+	voucherSize := voucherStorageSize(voucherEntry)
+
+	// Each upload fits the quota, both together do not
+	defer func(limits fdoshared.VoucherUploadLimits) { fdoshared.UploadLimits = limits }(fdoshared.UploadLimits)
+	fdoshared.UploadLimits = fdoshared.VoucherUploadLimits{MaxFileSize: voucherSize, MaxFiles: 1, MaxUserStorage: 2*voucherSize - 1}
+
+	devInsts := []dbs.DeviceTestInst{}
+	for _, name := range []string{"first", "second"} {
+		guid := fdoshared.NewFdoGuid()
+		listenerInst := listenertestsdeps.NewDevice_RequestListenerInst(voucherEntry, guid)
+		err = deviceApi.ListenerDB.Save(listenerInst)
+		if err != nil {
+			t.Fatalf("Failed to save listener. %s", err.Error())
+		}
+
+		devInsts = append(devInsts, dbs.NewDeviceTestInst(name, listenerInst.Uuid, guid))
+	}
+
+	errs := make([]error, len(devInsts))
+	var wg sync.WaitGroup
+	for i, devInst := range devInsts {
+		wg.Add(1)
+		go func(i int, devInst dbs.DeviceTestInst) {
+			defer wg.Done()
+			errs[i] = deviceApi.addDeviceTestInsts(&userInst, []dbs.DeviceTestInst{devInst}, voucherSize)
+		}(i, devInst)
+	}
+	wg.Wait()
+
+	rejected := -1
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, fdoshared.ErrUserStorageQuota) || rejected != -1 {
+			t.Fatalf("Expected exactly one upload to be rejected by quota. Got %v", errs)
+		}
+		rejected = i
+	}
+
+	if rejected == -1 {
+		t.Fatalf("Expected one of concurrent uploads to be rejected by quota")
+	}
+
+	storedUser, err := deviceApi.UserDB.Get(userInst.Email)
+	if err != nil {
+		t.Fatalf("Failed to get user. %s", err.Error())
+	}
+
+	if len(storedUser.DeviceTestInsts) != 1 || storedUser.DeviceT_ContainGuid(devInsts[rejected].DeviceGuid) {
+		t.Errorf("Expected only accepted device test to be added. Got %d device tests", len(storedUser.DeviceTestInsts))
+	}
+
+	_, err = deviceApi.ListenerDB.Get(devInsts[rejected].ListenerUuid)
+	if err == nil {
+		t.Errorf("Expected listener of rejected device test to be removed")
+	}
+}
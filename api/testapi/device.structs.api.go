@@ -2,12 +2,35 @@ package testapi
 
 import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	testcomdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 )
 
 type Device_CreateTestCase struct {
 	Name                 string `json:"name"`
 	VoucherAndPrivateKey string `json:"voucher"`
+	// Owner address registered with RV, and served to the device in To1d. Defaults to FDO_SERVICE_URL
+	To2Addr *fdoshared.To2AddrConfig `json:"to2Addr,omitempty"`
+}
+
+type Device_ImportRequest struct {
+	Vouchers []Device_CreateTestCase `json:"vouchers"`
+}
+
+type Device_ImportResponse struct {
+	Imported int                        `json:"imported"`
+	Status   commonapi.FdoConfApiStatus `json:"status"`
+}
+
+type Device_StartTestRunRequest struct {
+	ServiceInfo *listenertestsdeps.To2ServiceInfoConfig `json:"serviceInfo,omitempty"`
+	// RV signs To1d with a key other than the owner key. Device should abort TO2 before ProveDevice
+	To1dOwnerMismatch bool `json:"to1dOwnerMismatch,omitempty"`
+	// RV serves To1d with present, absent or invalid countersignature. Requires RV_COUNTERSIGN_TO1D
+	To1dCountersign listenertestsdeps.To1dCountersignCase `json:"to1dCountersign,omitempty"`
+	// Check TO1.ProveToRV or TO2.ProveDevice for deterministic CBOR encoding
+	CanonicalCbor bool `json:"canonicalCbor,omitempty"`
 }
 
 type Device_Item struct {
@@ -16,6 +39,8 @@ type Device_Item struct {
 	Guid string                              `json:"guid"`
 	To1  []listenertestsdeps.ListenerTestRun `json:"to1"`
 	To2  []listenertestsdeps.ListenerTestRun `json:"to2"`
+	// Requests per FDO message endpoint and HTTP status, keyed by test run uuid
+	Metrics map[string]testcomdbs.RunEndpointMetrics `json:"metrics"`
 }
 
 type Device_ListRuns struct {
@@ -27,3 +52,14 @@ type Device_RequestInfo struct {
 	Id        string `json:"id"`
 	TestRunId string `json:"testRunId,omitempty"`
 }
+
+type Device_MessageLogEntry struct {
+	Direction  string              `json:"direction"`
+	Timestamp  string              `json:"timestamp"`
+	Protocol   int                 `json:"protocol"`
+	Cmd        int                 `json:"cmd"`
+	SessionId  string              `json:"sessionId,omitempty"`
+	HttpStatus int                 `json:"httpStatus,omitempty"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body"`
+}
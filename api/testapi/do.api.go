@@ -11,11 +11,15 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	fdodocommon "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/common"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/testexec"
@@ -30,6 +34,10 @@ type DOTestMgmtAPI struct {
 	DevBaseDB *dbs.DeviceBaseDB
 	SessionDB *dbs.SessionDB
 	ConfigDB  *dbs.ConfigDB
+	Retention *RunRetention
+
+	VoucherTagDB *testdbs.VoucherTagDB
+	ListenerDB   *testdbs.ListenerTestDB
 }
 
 func (h *DOTestMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
@@ -103,6 +111,13 @@ func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 
 	doUrl := parsedUrl.Scheme + "://" + parsedUrl.Host
 
+	err = fdoshared.Outbound.CheckURL(doUrl)
+	if err != nil {
+		log.Println("URL not allowed. " + err.Error())
+		commonapi.RespondError(w, "URL not allowed! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Getting pre-gen config
 	mainConfig, err := h.ConfigDB.Get()
 	if err != nil {
@@ -132,6 +147,22 @@ func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 	newDOTTestTo2.TestVouchers = voucherTestMap
 	newDOTTestTo2.FdoSeedIDs = mainConfig.SeededGuids.GetTestBatch(DOSeedIDsBatchSize)
 
+	// DO registers one of its positive vouchers with RV, and TO0 owner tests are matched on its GUID
+	to0Voucher, err := newDOTTestTo2.TestVouchers.GetVoucher(testcom.NULL_TEST)
+	if err != nil {
+		log.Println("Failed to get TO0 voucher. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	doListenerInst := listenertestsdeps.NewDO_RequestListenerInst(to0Voucher.VoucherDBEntry, to0Voucher.WawDeviceCredential.DCGuid)
+	err = h.ListenerDB.Save(doListenerInst)
+	if err != nil {
+		log.Println("Failed to save do listener inst. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	// Saving stuff
 	err = h.ReqTDB.Save(newDOTTestTo2)
 	if err != nil {
@@ -141,7 +172,7 @@ func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Saving user
-	userInst.DOTestInsts = append(userInst.DOTestInsts, dbs.NewDOTestInst(doUrl, newDOTTestTo2.Uuid))
+	userInst.DOTestInsts = append(userInst.DOTestInsts, dbs.NewDOTestInst(doUrl, newDOTTestTo2.Uuid, doListenerInst.Uuid))
 	err = h.UserDB.Save(*userInst)
 	if err != nil {
 		log.Println("Failed to save user. " + err.Error())
@@ -191,13 +222,74 @@ func (h *DOTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 			Protocol:   dotsInfoPayload.Protocol,
 		}
 
+		if len(dotInfo.ListenerTo0) != 0 {
+			reqListener, err := h.ListenerDB.Get(dotInfo.ListenerTo0)
+			if err != nil {
+				log.Println("Error reading dot listener. " + err.Error())
+				commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			dotItem.ListenerTo0 = &DOT_ListenerInfo{
+				Id:   hex.EncodeToString(reqListener.Uuid),
+				Runs: reqListener.To0.GetTestRuns(),
+			}
+		}
+
 		dotList.TestEntries = append(dotList.TestEntries, dotItem)
 
 	}
 
 	dotList.Status = commonapi.FdoApiStatus_OK
 
-	commonapi.RespondSuccessStruct(w, dotList)
+	commonapi.RespondSuccessStructNegotiated(w, r, dotList)
+}
+
+// StartTo0TestRun starts TO0 test run of the DO as owner client. DO then runs TO0 with its TO0 voucher against RV
+func (h *DOTestMgmtAPI) StartTo0TestRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	listenerId, err := hex.DecodeString(vars["testinsthex"])
+	if err != nil {
+		commonapi.RespondError(w, "Failed to decode test inst id!", http.StatusBadRequest)
+		return
+	}
+
+	dotInst, ok := userInst.DOT_GetInstByListenerTo0(listenerId)
+	if !ok {
+		commonapi.RespondError(w, "Invalid test id!", http.StatusBadRequest)
+		return
+	}
+
+	// Evict before reading the entry, so the update below does not restore evicted runs
+	h.Retention.Enforce(userInst, 1)
+
+	reqListInst, err := h.ListenerDB.Get(dotInst.ListenerTo0)
+	if err != nil {
+		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reqListInst.To0.StartNewTestRun()
+
+	err = h.ListenerDB.Update(reqListInst)
+	if err != nil {
+		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
 }
 
 func (h *DOTestMgmtAPI) GetVouchers(w http.ResponseWriter, r *http.Request) {
@@ -252,6 +344,11 @@ func (h *DOTestMgmtAPI) GetVouchers(w http.ResponseWriter, r *http.Request) {
 	writer := zip.NewWriter(zipBuffer)
 
 	for _, vanv := range voucherList {
+		// Imported vouchers come from the owner toolchain, which already has them, usually without owner private key
+		if len(vanv.VoucherDBEntry.PrivateKeyX509) == 0 {
+			continue
+		}
+
 		zipFile, err := writer.Create(fmt.Sprintf("%s.voucher.pem", hex.EncodeToString(vanv.WawDeviceCredential.DCGuid[:])))
 		if err != nil {
 			log.Println("Error creating new zip file instance. " + err.Error())
@@ -321,6 +418,72 @@ func (h *DOTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request) {
 	commonapi.RespondSuccess(w)
 }
 
+func (h *DOTestMgmtAPI) TestRunStateChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	testinsthex := vars["testinsthex"]
+	testrunid := vars["testrunid"]
+
+	dotId, err := hex.DecodeString(testinsthex)
+	if err != nil {
+		log.Println("Can not decode hex dotId " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	if !userInst.DOT_ContainID(dotId) {
+		log.Println("Id does not belong to user")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	respondStateChanges(w, r, h.ReqTDB, dotId, testrunid)
+}
+
+// parseTestTimeout converts testTimeout seconds of execution request. Zero selects the default
+func parseTestTimeout(seconds int) (time.Duration, error) {
+	maxSeconds := int(reqtestsdeps.MAX_TEST_TIMEOUT / time.Second)
+	if seconds < 0 || seconds > maxSeconds {
+		return 0, fmt.Errorf("expected 0 to %d seconds, got %d", maxSeconds, seconds)
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// parseKexCipherSuite checks KEX and cipher suite of execution request. Returns nil when none is set, so suite is selected from each voucher
+func parseKexCipherSuite(kexSuiteName string, cipherSuiteName int) (*fdoshared.KexCipherSuite, error) {
+	if kexSuiteName == "" && cipherSuiteName == 0 {
+		return nil, nil
+	}
+
+	if kexSuiteName == "" || cipherSuiteName == 0 {
+		return nil, errors.New("expected both kexSuiteName and cipherSuiteName")
+	}
+
+	suite := fdoshared.KexCipherSuite{
+		KexSuiteName:    fdoshared.KexSuiteName(kexSuiteName),
+		CipherSuiteName: fdoshared.CipherSuiteName(cipherSuiteName),
+	}
+
+	err := suite.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &suite, nil
+}
+
 func (h *DOTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 	if !commonapi.CheckHeaders(w, r) {
 		return
@@ -340,7 +503,7 @@ func (h *DOTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var execReq RVT_RequestInfo
+	var execReq DOT_RequestInfo
 	err = json.Unmarshal(bodyBytes, &execReq)
 	if err != nil {
 		log.Println("Failed to decode body. " + err.Error())
@@ -348,6 +511,41 @@ func (h *DOTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	testTimeout, err := parseTestTimeout(execReq.TestTimeout)
+	if err != nil {
+		commonapi.RespondError(w, "Invalid test timeout! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	kexCipherSuite, err := parseKexCipherSuite(execReq.KexSuiteName, execReq.CipherSuiteName)
+	if err != nil {
+		commonapi.RespondError(w, "Invalid KEX suite! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Resale test is opt-in. Runs without policy skip it
+	reonboardPolicy, err := fdoshared.ParseReonboardPolicy(execReq.ReonboardPolicy, "")
+	if err != nil {
+		commonapi.RespondError(w, "Invalid reonboard policy! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rvInfoGuid *fdoshared.FdoGuid
+	if execReq.RVInfoGuid != "" {
+		guid, err := fdoshared.ParseFdoGuid(execReq.RVInfoGuid)
+		if err != nil {
+			commonapi.RespondError(w, "Invalid RVInfo voucher GUID! "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rvInfoGuid = &guid
+	}
+
+	if execReq.AllSuites && kexCipherSuite != nil {
+		commonapi.RespondError(w, "Invalid KEX suite! allSuites can not be combined with kexSuiteName", http.StatusBadRequest)
+		return
+	}
+
 	dotId, err := hex.DecodeString(execReq.Id)
 	if err != nil {
 		log.Println("Can not decode hex dotId " + err.Error())
@@ -368,7 +566,436 @@ func (h *DOTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	testexec.ExecuteDOTestsTo2(*rvte, h.ReqTDB)
+	err = fdoshared.Outbound.CheckURL(rvte.URL)
+	if err != nil {
+		log.Println("URL not allowed. " + err.Error())
+		commonapi.RespondError(w, "URL not allowed! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	newRuns := 1
+	if execReq.AllSuites {
+		newRuns = len(fdoshared.KexCipherSuiteMatrix())
+	}
+
+	h.Retention.Enforce(userInst, newRuns)
+
+	rvte.TestTimeout = testTimeout
+	rvte.KexCipherSuite = kexCipherSuite
+	rvte.ReonboardPolicy = reonboardPolicy
+	rvte.RVInfoGuid = rvInfoGuid
+	if execReq.AllSuites {
+		testexec.ExecuteDOTestsTo2Matrix(*rvte, h.ReqTDB)
+	} else {
+		testexec.ExecuteDOTestsTo2(*rvte, h.ReqTDB)
+	}
+
+	commonapi.RespondSuccess(w)
+}
+
+// checkVoucherFileSizes checks PEM or base64 encoded voucher and credential against upload file size limit
+func checkVoucherFileSizes(files ...string) error {
+	for _, file := range files {
+		if len(file) > fdoshared.UploadLimits.MaxFileSize {
+			return fmt.Errorf("voucher file size %d exceeds limit of %d bytes", len(file), fdoshared.UploadLimits.MaxFileSize)
+		}
+	}
+
+	return nil
+}
+
+// ImportVoucher adds externally generated voucher and device credential to test instance vouchers, for positive tests.
+// Voucher GUID must not be already used by the test instance
+func (h *DOTestMgmtAPI) ImportVoucher(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, ok := readVoucherUploadBody(w, r)
+	if !ok {
+		return
+	}
+
+	var importReq DOT_ImportVoucherRequest
+	err = json.Unmarshal(bodyBytes, &importReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	err = checkVoucherFileSizes(importReq.Voucher, importReq.Credential)
+	if err != nil {
+		commonapi.RespondError(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	dotId, err := hex.DecodeString(importReq.Id)
+	if err != nil {
+		log.Println("Can not decode hex dotId " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	if !userInst.DOT_ContainID(dotId) {
+		log.Println("Id does not belong to user")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	newVanv, err := fdodocommon.DecodeVoucherAndCredential(importReq.Voucher, importReq.Credential)
+	if err != nil {
+		log.Println("Failed to decode voucher. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode voucher! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rvte, err := h.ReqTDB.Get(dotId)
+	if err != nil {
+		log.Println("Can get DOT entry. " + err.Error())
+		commonapi.RespondError(w, "Internal server error!", http.StatusInternalServerError)
+		return
+	}
+
+	if rvte.InProgress {
+		commonapi.RespondError(w, "Test run is in progress!", http.StatusConflict)
+		return
+	}
+
+	newGuid := newVanv.WawDeviceCredential.DCGuid
+	for _, vouchers := range rvte.TestVouchers {
+		for _, voucher := range vouchers {
+			if voucher.WawDeviceCredential.DCGuid == newGuid {
+				commonapi.RespondError(w, fmt.Sprintf("Voucher with GUID %s already exists!", newGuid.GetFormatted()), http.StatusConflict)
+				return
+			}
+		}
+	}
+
+	rvte.TestVouchers[testcom.NULL_TEST] = append(rvte.TestVouchers[testcom.NULL_TEST], *newVanv)
+
+	err = h.ReqTDB.Save(*rvte)
+	if err != nil {
+		log.Println("Failed to save do test inst. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, DOT_ImportVoucherResponse{
+		Guid:   hex.EncodeToString(newGuid[:]),
+		Status: commonapi.FdoApiStatus_OK,
+	})
+}
+
+// ValidateVoucher runs voucher checks without storing the voucher, or starting any session, and reports result of each check
+func (h *DOTestMgmtAPI) ValidateVoucher(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	_, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, ok := readVoucherUploadBody(w, r)
+	if !ok {
+		return
+	}
+
+	var validateReq DOT_ValidateVoucherRequest
+	err = json.Unmarshal(bodyBytes, &validateReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	err = checkVoucherFileSizes(validateReq.Voucher, validateReq.Credential)
+	if err != nil {
+		commonapi.RespondError(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if validateReq.Voucher == "" {
+		commonapi.RespondError(w, "Missing voucher!", http.StatusBadRequest)
+		return
+	}
+
+	response := DOT_ValidateVoucherResponse{
+		Valid:  true,
+		Checks: fdodocommon.ValidateVoucher(validateReq.Voucher, validateReq.Credential),
+		Status: commonapi.FdoApiStatus_OK,
+	}
+
+	for _, check := range response.Checks {
+		if !check.Passed {
+			response.Valid = false
+		}
+	}
+
+	commonapi.RespondSuccessStruct(w, response)
+}
+
+// TagVouchers adds or removes test instance vouchers to a named suite
+func (h *DOTestMgmtAPI) TagVouchers(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var tagReq DOT_TagVouchersRequest
+	err = json.Unmarshal(bodyBytes, &tagReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	dotId, err := hex.DecodeString(tagReq.Id)
+	if err != nil {
+		log.Println("Can not decode hex dotId " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	if !userInst.DOT_ContainID(dotId) {
+		log.Println("Id does not belong to user")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := testdbs.NormalizeVoucherTag(tagReq.Tag)
+	if err != nil {
+		commonapi.RespondError(w, "Invalid tag! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(tagReq.Guids) == 0 {
+		commonapi.RespondError(w, "Missing guids!", http.StatusBadRequest)
+		return
+	}
+
+	rvte, err := h.ReqTDB.Get(dotId)
+	if err != nil {
+		log.Println("Can get DOT entry. " + err.Error())
+		commonapi.RespondError(w, "Internal server error!", http.StatusInternalServerError)
+		return
+	}
+
+	var storedGuids fdoshared.FdoGuidList
+	for _, vouchers := range rvte.TestVouchers {
+		for _, voucher := range vouchers {
+			storedGuids = append(storedGuids, voucher.WawDeviceCredential.DCGuid)
+		}
+	}
+
+	var guids fdoshared.FdoGuidList
+	for _, guidStr := range tagReq.Guids {
+		guid, err := fdoshared.ParseFdoGuid(guidStr)
+		if err != nil {
+			commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !storedGuids.Contains(guid) {
+			commonapi.RespondError(w, "Unknown voucher guid "+guidStr, http.StatusBadRequest)
+			return
+		}
+
+		guids = append(guids, guid)
+	}
+
+	if tagReq.Remove {
+		err = h.VoucherTagDB.Untag(dotId, tag, guids)
+	} else {
+		err = h.VoucherTagDB.Tag(dotId, tag, guids)
+	}
+	if err != nil {
+		log.Println("Failed to save voucher tags. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
+
+func (h *DOTestMgmtAPI) GetVoucherTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	idBytes, err := hex.DecodeString(vars["uuid"])
+	if err != nil {
+		log.Printf("Cound not decode %s hex", vars["uuid"])
+		commonapi.RespondError(w, "ID not found!", http.StatusNotFound)
+		return
+	}
+
+	if !userInst.DOT_ContainID(idBytes) {
+		log.Printf("ID %s does not belong to user", vars["uuid"])
+		commonapi.RespondError(w, "ID not found!", http.StatusNotFound)
+		return
+	}
+
+	voucherTags, err := h.VoucherTagDB.Get(idBytes)
+	if err != nil {
+		log.Println("Error reading voucher tags. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tagsResp := DOT_VoucherTagsResponse{
+		Tags:   []DOT_VoucherTag{},
+		Status: commonapi.FdoApiStatus_OK,
+	}
+
+	for _, tag := range voucherTags.Names() {
+		voucherTag := DOT_VoucherTag{
+			Tag:   tag,
+			Guids: []string{},
+		}
+
+		for _, guid := range voucherTags[tag] {
+			voucherTag.Guids = append(voucherTag.Guids, hex.EncodeToString(guid[:]))
+		}
+
+		tagsResp.Tags = append(tagsResp.Tags, voucherTag)
+	}
+
+	commonapi.RespondSuccessStructNegotiated(w, r, tagsResp)
+}
+
+// ExecuteSuite runs TO2 tests with only the vouchers tagged with the suite name
+func (h *DOTestMgmtAPI) ExecuteSuite(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var execReq DOT_ExecuteSuiteRequest
+	err = json.Unmarshal(bodyBytes, &execReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	testTimeout, err := parseTestTimeout(execReq.TestTimeout)
+	if err != nil {
+		commonapi.RespondError(w, "Invalid test timeout! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	kexCipherSuite, err := parseKexCipherSuite(execReq.KexSuiteName, execReq.CipherSuiteName)
+	if err != nil {
+		commonapi.RespondError(w, "Invalid KEX suite! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Resale test is opt-in. Runs without policy skip it
+	reonboardPolicy, err := fdoshared.ParseReonboardPolicy(execReq.ReonboardPolicy, "")
+	if err != nil {
+		commonapi.RespondError(w, "Invalid reonboard policy! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dotId, err := hex.DecodeString(execReq.Id)
+	if err != nil {
+		log.Println("Can not decode hex dotId " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	if !userInst.DOT_ContainID(dotId) {
+		log.Println("Id does not belong to user")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := testdbs.NormalizeVoucherTag(execReq.Tag)
+	if err != nil {
+		commonapi.RespondError(w, "Invalid tag! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	voucherTags, err := h.VoucherTagDB.Get(dotId)
+	if err != nil {
+		log.Println("Error reading voucher tags. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	suiteGuids, ok := voucherTags[tag]
+	if !ok || len(suiteGuids) == 0 {
+		commonapi.RespondError(w, "Suite not found!", http.StatusNotFound)
+		return
+	}
+
+	rvte, err := h.ReqTDB.Get(dotId)
+	if err != nil {
+		log.Println("Can get DOT entry. " + err.Error())
+		commonapi.RespondError(w, "Internal server error!", http.StatusBadRequest)
+		return
+	}
+
+	err = fdoshared.Outbound.CheckURL(rvte.URL)
+	if err != nil {
+		log.Println("URL not allowed. " + err.Error())
+		commonapi.RespondError(w, "URL not allowed! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.Retention.Enforce(userInst, 1)
+
+	rvte.TestTimeout = testTimeout
+	rvte.KexCipherSuite = kexCipherSuite
+	rvte.ReonboardPolicy = reonboardPolicy
+	testexec.ExecuteDOTestsTo2Suite(*rvte, h.ReqTDB, suiteGuids)
 
 	commonapi.RespondSuccess(w)
 }
@@ -0,0 +1,34 @@
+package testapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestReadVoucherUploadBody_Limit(t *testing.T) {
+	defer func(limits fdoshared.VoucherUploadLimits) { fdoshared.UploadLimits = limits }(fdoshared.UploadLimits)
+	fdoshared.UploadLimits = fdoshared.VoucherUploadLimits{MaxFileSize: 64, MaxFiles: 1, MaxUserStorage: 1024}
+
+	maxRequestSize := int(fdoshared.UploadLimits.MaxRequestSize())
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/dot/vouchers/import", strings.NewReader(strings.Repeat("a", maxRequestSize+1)))
+	if _, ok := readVoucherUploadBody(w, r); ok {
+		t.Errorf("Expected body over %d bytes to be rejected", maxRequestSize)
+	}
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d. Got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+
+	if err := checkVoucherFileSizes(strings.Repeat("a", 64), ""); err != nil {
+		t.Errorf("Expected voucher within limit to pass. %s", err.Error())
+	}
+
+	if err := checkVoucherFileSizes("", strings.Repeat("a", 65)); err == nil {
+		t.Errorf("Expected credential over limit to fail")
+	}
+}
@@ -2,7 +2,9 @@ package testapi
 
 import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdodocommon "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/common"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
@@ -17,10 +19,17 @@ type DOT_InstInfo struct {
 	Protocol   fdoshared.FdoToProtocol       `json:"protocol"`
 }
 
+// DOT_ListenerInfo lists TO0 test runs of the DO as owner client
+type DOT_ListenerInfo struct {
+	Id   string                              `json:"id"`
+	Runs []listenertestsdeps.ListenerTestRun `json:"runs"`
+}
+
 type DOT_Item struct {
-	Id  string       `json:"id"`
-	Url string       `json:"url"`
-	To2 DOT_InstInfo `json:"to2"`
+	Id          string            `json:"id"`
+	Url         string            `json:"url"`
+	To2         DOT_InstInfo      `json:"to2"`
+	ListenerTo0 *DOT_ListenerInfo `json:"listenerTo0,omitempty"`
 }
 
 type DOT_ListTestEntries struct {
@@ -31,4 +40,68 @@ type DOT_ListTestEntries struct {
 type DOT_RequestInfo struct {
 	Id        string `json:"id"`
 	TestRunId string `json:"testRunId,omitempty"`
+	// Seconds each message of the run waits for the owner. Defaults to 30
+	TestTimeout int `json:"testTimeout,omitempty"`
+	// KEX and cipher suite of the run, e.g. "ECDH384" and 3. When not set, suite is selected from each voucher device SigInfo
+	KexSuiteName    string `json:"kexSuiteName,omitempty"`
+	CipherSuiteName int    `json:"cipherSuiteName,omitempty"`
+	// Run once for each ECDH KEX and cipher suite combination, instead of a single run
+	AllSuites bool `json:"allSuites,omitempty"`
+	// Owner handling of onboarded device sending HelloDevice again with the same voucher, "allow" or "deny". Not set skips the resale test
+	ReonboardPolicy string `json:"reonboardPolicy,omitempty"`
+	// GUID of the voucher, e.g. imported, whose RVInfo the device follows before TO2 tests. Not set skips the RVInfo test
+	RVInfoGuid string `json:"rvInfoGuid,omitempty"`
+}
+
+type DOT_TagVouchersRequest struct {
+	Id     string   `json:"id"`
+	Tag    string   `json:"tag"`
+	Guids  []string `json:"guids"`
+	Remove bool     `json:"remove,omitempty"`
+}
+
+// DOT_ImportVoucherRequest carries voucher and device credential generated outside of the server, each PEM or base64 encoded CBOR
+type DOT_ImportVoucherRequest struct {
+	Id         string `json:"id"`
+	Voucher    string `json:"voucher"`
+	Credential string `json:"credential"`
+}
+
+// DOT_ValidateVoucherRequest carries voucher, and optionally device credential, each PEM or base64 encoded CBOR
+type DOT_ValidateVoucherRequest struct {
+	Voucher    string `json:"voucher"`
+	Credential string `json:"credential,omitempty"`
+}
+
+type DOT_ValidateVoucherResponse struct {
+	Valid  bool                       `json:"valid"`
+	Checks []fdodocommon.VoucherCheck `json:"checks"`
+	Status commonapi.FdoConfApiStatus `json:"status"`
+}
+
+type DOT_ImportVoucherResponse struct {
+	Guid   string                     `json:"guid"`
+	Status commonapi.FdoConfApiStatus `json:"status"`
+}
+
+type DOT_VoucherTag struct {
+	Tag   string   `json:"tag"`
+	Guids []string `json:"guids"`
+}
+
+type DOT_VoucherTagsResponse struct {
+	Tags   []DOT_VoucherTag           `json:"tags"`
+	Status commonapi.FdoConfApiStatus `json:"status"`
+}
+
+type DOT_ExecuteSuiteRequest struct {
+	Id  string `json:"id"`
+	Tag string `json:"tag"`
+	// Seconds each message of the run waits for the owner. Defaults to 30
+	TestTimeout int `json:"testTimeout,omitempty"`
+	// KEX and cipher suite of the run. When not set, suite is selected from each voucher device SigInfo
+	KexSuiteName    string `json:"kexSuiteName,omitempty"`
+	CipherSuiteName int    `json:"cipherSuiteName,omitempty"`
+	// Owner handling of onboarded device sending HelloDevice again with the same voucher, "allow" or "deny". Not set skips the resale test
+	ReonboardPolicy string `json:"reonboardPolicy,omitempty"`
 }
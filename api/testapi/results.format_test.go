@@ -0,0 +1,89 @@
+package testapi
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+func TestResultsContentNegotiation(t *testing.T) {
+	testRun := reqtestsdeps.RequestTestRun{
+		Uuid:      "a7f1d1c2-5f6e-4c1b-9a3e-2b8d7c6e5f4a",
+		Timestamp: 1700000000,
+		Protocol:  fdoshared.To0,
+		Tests: reqtestsdeps.RequestTestResultMap{
+			testcom.FIDO_RVT_21_CHECK_RESP: testcom.NewSuccessTestState(testcom.FIDO_RVT_21_CHECK_RESP),
+			testcom.FIDO_RVT_23_CHECK_RESP: testcom.NewFailTestState(testcom.FIDO_RVT_23_CHECK_RESP, "Bad response"),
+		},
+	}
+
+	rvtsList := RVT_ListRvts{
+		Status: commonapi.FdoApiStatus_OK,
+		RVTItems: []RVT_Item{
+			{
+				Id:             "0102",
+				Url:            "http://rv.example.com",
+				To0:            RVT_InstInfo{Id: "03", Runs: []reqtestsdeps.RequestTestRun{testRun}, InProgress: false, Protocol: fdoshared.To0},
+				To1:            RVT_InstInfo{Id: "04", Runs: []reqtestsdeps.RequestTestRun{testRun}, InProgress: true, Protocol: fdoshared.To1},
+				SuccessPassing: false,
+			},
+		},
+	}
+
+	acceptHeaders := map[string]string{
+		"":                                 commonapi.CONTENT_TYPE_JSON,
+		"application/json":                 commonapi.CONTENT_TYPE_JSON,
+		"application/cbor":                 commonapi.CONTENT_TYPE_CBOR,
+		"application/msgpack":              commonapi.CONTENT_TYPE_MSGPACK,
+		"text/html, application/x-msgpack": commonapi.CONTENT_TYPE_MSGPACK,
+	}
+
+	for accept, expectedType := range acceptHeaders {
+		req := httptest.NewRequest("GET", "/api/rvt/testruns", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+
+		commonapi.RespondSuccessStructNegotiated(rec, req, rvtsList)
+
+		if rec.Header().Get("Content-Type") != expectedType {
+			t.Errorf("Accept \"%s\": expected %s. Got %s", accept, expectedType, rec.Header().Get("Content-Type"))
+			continue
+		}
+
+		var decoded RVT_ListRvts
+		err := commonapi.UnmarshalNegotiated(expectedType, rec.Body.Bytes(), &decoded)
+		if err != nil {
+			t.Errorf("Accept \"%s\": failed to decode. %s", accept, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(decoded, rvtsList) {
+			t.Errorf("Accept \"%s\": round trip mismatch. Expected %+v. Got %+v", accept, rvtsList, decoded)
+		}
+	}
+}
+
+func TestResultsAcceptsXML(t *testing.T) {
+	acceptHeaders := map[string]bool{
+		"":                                  false,
+		"application/json":                  false,
+		"application/xml":                   true,
+		"text/xml; charset=utf-8":           true,
+		"application/cbor, application/xml": false,
+		"text/html, application/xml":        true,
+	}
+
+	for accept, expected := range acceptHeaders {
+		req := httptest.NewRequest("GET", "/api/campaign/report", nil)
+		req.Header.Set("Accept", accept)
+
+		if commonapi.AcceptsXML(req) != expected {
+			t.Errorf("Accept \"%s\": expected AcceptsXML %t", accept, expected)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package testapi
+
+import (
+	"encoding/hex"
+	"log"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+// RunRetention caps stored runs per user to fdoshared.MaxRunsPerUser, evicting the oldest unpinned ones
+type RunRetention struct {
+	ReqTDB     *testdbs.RequestTestDB
+	ListenerDB *testdbs.ListenerTestDB
+	RunPinDB   *testdbs.RunPinDB
+}
+
+// UserRuns lists runs of all RVT, DOT and device test instances of the user
+func (h *RunRetention) UserRuns(userInst *dbs.UserTestDBEntry) ([]testcom.StoredRun, error) {
+	pins, err := h.RunPinDB.Get([]byte(userInst.Email))
+	if err != nil {
+		return nil, err
+	}
+
+	reqtIds := [][]byte{}
+	listenerIds := [][]byte{}
+	for _, rvtInst := range userInst.RVTestInsts {
+		reqtIds = append(reqtIds, rvtInst.To0, rvtInst.To1)
+	}
+
+	for _, dotInst := range userInst.DOTestInsts {
+		reqtIds = append(reqtIds, dotInst.To2)
+		if len(dotInst.ListenerTo0) != 0 {
+			listenerIds = append(listenerIds, dotInst.ListenerTo0)
+		}
+	}
+
+	for _, devtInst := range userInst.DeviceTestInsts {
+		listenerIds = append(listenerIds, devtInst.ListenerUuid)
+	}
+
+	runs := []testcom.StoredRun{}
+	seen := map[string]bool{}
+
+	for _, reqtId := range reqtIds {
+		reqte, err := h.ReqTDB.Get(reqtId)
+		if err != nil {
+			log.Printf("Skipping runs of %s. %s", hex.EncodeToString(reqtId), err.Error())
+			continue
+		}
+
+		for _, testRun := range reqte.TestsHistory {
+			if seen[testRun.Uuid] {
+				continue
+			}
+			seen[testRun.Uuid] = true
+
+			runs = append(runs, testcom.StoredRun{
+				InstId:    reqtId,
+				Protocol:  reqte.Protocol,
+				RunId:     testRun.Uuid,
+				Timestamp: testRun.Timestamp,
+				Pinned:    pins[testRun.Uuid],
+			})
+		}
+	}
+
+	for _, listenerId := range listenerIds {
+		listenerInst, err := h.ListenerDB.Get(listenerId)
+		if err != nil {
+			log.Printf("Skipping runs of %s. %s", hex.EncodeToString(listenerId), err.Error())
+			continue
+		}
+
+		for _, runner := range []listenertestsdeps.RequestListenerRunnerInst{listenerInst.To0, listenerInst.To1, listenerInst.To2} {
+			for _, testRun := range runner.TestRunHistory {
+				if seen[testRun.Uuid] {
+					continue
+				}
+				seen[testRun.Uuid] = true
+
+				runs = append(runs, testcom.StoredRun{
+					InstId:    listenerId,
+					Protocol:  runner.Protocol,
+					Listener:  true,
+					RunId:     testRun.Uuid,
+					Timestamp: testRun.Timestamp,
+					Pinned:    pins[testRun.Uuid],
+				})
+			}
+		}
+	}
+
+	return runs, nil
+}
+
+// RunRunning returns true while the stored run is the current run of its test instance, and it is not finished
+func (h *RunRetention) RunRunning(run testcom.StoredRun) (bool, error) {
+	if !run.Listener {
+		reqte, err := h.ReqTDB.Get(run.InstId)
+		if err != nil {
+			return false, err
+		}
+
+		return reqte.InProgress && reqte.CurrentTestRun.Uuid == run.RunId, nil
+	}
+
+	listenerInst, err := h.ListenerDB.Get(run.InstId)
+	if err != nil {
+		return false, err
+	}
+
+	runner := listenerInst.To2
+	switch run.Protocol {
+	case fdoshared.To0:
+		runner = listenerInst.To0
+	case fdoshared.To1:
+		runner = listenerInst.To1
+	}
+
+	return runner.Running && runner.CurrentTestRun.Uuid == run.RunId, nil
+}
+
+// Enforce evicts runs so that newRuns about to start still fit the cap. No-op when cap is not set
+func (h *RunRetention) Enforce(userInst *dbs.UserTestDBEntry, newRuns int) {
+	if fdoshared.MaxRunsPerUser == 0 {
+		return
+	}
+
+	runs, err := h.UserRuns(userInst)
+	if err != nil {
+		log.Println("Failed to list runs for retention. " + err.Error())
+		return
+	}
+
+	for _, run := range testcom.SelectRunsToEvict(runs, fdoshared.MaxRunsPerUser-newRuns) {
+		log.Printf("Evicting run %s of %s", run.RunId, hex.EncodeToString(run.InstId))
+
+		if run.Listener {
+			err = h.ListenerDB.RemoveTestRun(run.Protocol, run.InstId, run.RunId)
+			if err != nil {
+				log.Printf("Failed to evict run %s. %s", run.RunId, err.Error())
+			}
+		} else {
+			h.ReqTDB.RemoveTestRun(run.InstId, run.RunId)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package testapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/testexec"
+	"github.com/gorilla/mux"
+)
+
+// RunsMgmtAPI pins runs, so they are exempt from run retention, and replays them
+type RunsMgmtAPI struct {
+	UserDB    *dbs.UserTestDB
+	SessionDB *dbs.SessionDB
+	Retention *RunRetention
+}
+
+func (h *RunsMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, errors.New("Failed to read cookie. " + err.Error())
+	}
+
+	if sessionCookie == nil {
+		return nil, errors.New("Cookie does not exists")
+	}
+
+	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil {
+		return nil, errors.New("Session expired. " + err.Error())
+	}
+
+	if !sessionInst.LoggedIn {
+		return nil, errors.New("Unauthorized!")
+	}
+
+	userInst, err := h.UserDB.Get(sessionInst.Email)
+	if err != nil {
+		return nil, errors.New("User does not exists. " + err.Error())
+	}
+
+	return userInst, nil
+}
+
+func (h *RunsMgmtAPI) Pinned(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pins, err := h.Retention.RunPinDB.Get([]byte(userInst.Email))
+	if err != nil {
+		log.Println("Error reading pinned runs. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStructNegotiated(w, r, Runs_PinnedResponse{
+		Pinned:         pins.Ids(),
+		MaxRunsPerUser: fdoshared.MaxRunsPerUser,
+		Status:         commonapi.FdoApiStatus_OK,
+	})
+}
+
+// Pin pins the run on POST and unpins it on DELETE
+func (h *RunsMgmtAPI) Pin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	testrunid := mux.Vars(r)["testrunid"]
+
+	if r.Method == "DELETE" {
+		err = h.Retention.RunPinDB.Unpin([]byte(userInst.Email), testrunid)
+		if err != nil {
+			log.Println("Error unpinning run. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		commonapi.RespondSuccess(w)
+		return
+	}
+
+	runs, err := h.Retention.UserRuns(userInst)
+	if err != nil {
+		log.Println("Error listing user runs. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	found := false
+	for _, run := range runs {
+		if run.RunId == testrunid {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		log.Printf("Run %s does not belong to user", testrunid)
+		commonapi.RespondError(w, "Run not found!", http.StatusNotFound)
+		return
+	}
+
+	err = h.Retention.RunPinDB.Pin([]byte(userInst.Email), testrunid)
+	if err != nil {
+		log.Println("Error pinning run. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
+
+// testOutcomeChanges lists tests of the replayed run that passed in one run and failed in the other
+func testOutcomeChanges(originalRun reqtestsdeps.RequestTestRun, replayRun reqtestsdeps.RequestTestRun) []testcom.FDOTestID {
+	changes := []testcom.FDOTestID{}
+	for testId, replayState := range replayRun.Tests {
+		originalState, ok := originalRun.Tests[testId]
+		if ok && originalState.Passed != replayState.Passed {
+			changes = append(changes, testId)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i] < changes[j] })
+
+	return changes
+}
+
+// Replay runs again tests of a previous DO TO2 run, with the same KEX and cipher suite, test timeout and voucher suite.
+// Responds once the new run finished, with outcome of both runs
+func (h *RunsMgmtAPI) Replay(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var replayReq Runs_ReplayRequest
+	err = json.Unmarshal(bodyBytes, &replayReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	runs, err := h.Retention.UserRuns(userInst)
+	if err != nil {
+		log.Println("Error listing user runs. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var storedRun *testcom.StoredRun
+	for _, run := range runs {
+		if run.RunId == replayReq.RunId {
+			run := run
+			storedRun = &run
+			break
+		}
+	}
+
+	if storedRun == nil {
+		log.Printf("Run %s does not belong to user", replayReq.RunId)
+		commonapi.RespondError(w, "Run not found!", http.StatusNotFound)
+		return
+	}
+
+	if storedRun.Listener || storedRun.Protocol != fdoshared.To2 {
+		commonapi.RespondError(w, "Only DO test runs can be replayed!", http.StatusBadRequest)
+		return
+	}
+
+	reqte, err := h.Retention.ReqTDB.Get(storedRun.InstId)
+	if err != nil {
+		log.Println("Can get DOT entry. " + err.Error())
+		commonapi.RespondError(w, "Internal server error!", http.StatusInternalServerError)
+		return
+	}
+
+	if reqte.InProgress {
+		commonapi.RespondError(w, "Test run is in progress!", http.StatusConflict)
+		return
+	}
+
+	var originalRun *reqtestsdeps.RequestTestRun
+	for _, testRun := range reqte.TestsHistory {
+		if testRun.Uuid == replayReq.RunId {
+			testRun := testRun
+			originalRun = &testRun
+			break
+		}
+	}
+
+	if originalRun == nil {
+		commonapi.RespondError(w, "Run not found!", http.StatusNotFound)
+		return
+	}
+
+	onlyTests := originalRun.GetAllTestIDs()
+	if replayReq.FailedOnly {
+		onlyTests = originalRun.GetFailedTestIDs()
+	}
+
+	if len(onlyTests) == 0 {
+		commonapi.RespondError(w, "No tests to replay!", http.StatusBadRequest)
+		return
+	}
+
+	err = fdoshared.Outbound.CheckURL(reqte.URL)
+	if err != nil {
+		log.Println("URL not allowed. " + err.Error())
+		commonapi.RespondError(w, "URL not allowed! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.Retention.Enforce(userInst, 1)
+
+	reqte.OnlyTests = onlyTests
+	reqte.ReplayOf = originalRun.Uuid
+	reqte.TestTimeout = originalRun.TestTimeout
+	reqte.ReonboardPolicy = originalRun.ReonboardPolicy
+	if originalRun.KexSuiteName != "" {
+		reqte.KexCipherSuite = &fdoshared.KexCipherSuite{
+			KexSuiteName:    originalRun.KexSuiteName,
+			CipherSuiteName: originalRun.CipherSuiteName,
+		}
+	}
+
+	if len(originalRun.SuiteGuids) != 0 {
+		testexec.ExecuteDOTestsTo2Suite(*reqte, h.Retention.ReqTDB, originalRun.SuiteGuids)
+	} else {
+		testexec.ExecuteDOTestsTo2(*reqte, h.Retention.ReqTDB)
+	}
+
+	reqte, err = h.Retention.ReqTDB.Get(storedRun.InstId)
+	if err != nil || len(reqte.TestsHistory) == 0 || reqte.TestsHistory[0].ReplayOf != originalRun.Uuid {
+		log.Println("Can not read replay run.")
+		commonapi.RespondError(w, "Internal server error!", http.StatusInternalServerError)
+		return
+	}
+
+	replayRun := reqte.TestsHistory[0]
+
+	commonapi.RespondSuccessStruct(w, Runs_ReplayResponse{
+		Original:       *originalRun,
+		Replay:         replayRun,
+		OutcomeChanged: testOutcomeChanges(*originalRun, replayRun),
+		Status:         commonapi.FdoApiStatus_OK,
+	})
+}
+
+// How often the stream checks whether the run is still running, and sends keepalive comment so proxies do not close idle stream
+var runStreamPollInterval = 2 * time.Second
+var runStreamKeepAliveInterval = 15 * time.Second
+
+// Stream streams results of the run, as they are reported, as server-sent events. Results reported before the stream started are not sent.
+// Once the run is no longer running, stream sends "done" event and ends
+func (h *RunsMgmtAPI) Stream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		commonapi.RespondError(w, "Streaming is not supported!", http.StatusInternalServerError)
+		return
+	}
+
+	runId := r.URL.Query().Get("runId")
+	if runId == "" {
+		commonapi.RespondError(w, "Missing runId!", http.StatusBadRequest)
+		return
+	}
+
+	runs, err := h.Retention.UserRuns(userInst)
+	if err != nil {
+		log.Println("Error listing user runs. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var storedRun *testcom.StoredRun
+	for i, run := range runs {
+		if run.RunId == runId {
+			storedRun = &runs[i]
+			break
+		}
+	}
+
+	if storedRun == nil {
+		log.Printf("Run %s does not belong to user", runId)
+		commonapi.RespondError(w, "Run not found!", http.StatusNotFound)
+		return
+	}
+
+	stream := h.Retention.ReqTDB.Results.Subscribe(runId)
+	defer h.Retention.ReqTDB.Results.Unsubscribe(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	writeResult := func(event testdbs.RunResultEvent) {
+		eventBytes, _ := json.Marshal(event)
+		fmt.Fprintf(w, "data: %s\n\n", eventBytes)
+	}
+
+	// Ends the stream with done event, once the run is no longer running
+	endIfNotRunning := func() bool {
+		running, err := h.Retention.RunRunning(*storedRun)
+		if err != nil {
+			log.Printf("Error checking run %s. %s", runId, err.Error())
+		} else if running {
+			return false
+		}
+
+		// Results reported before the run finished are sent before the done event
+		for len(stream.Results) != 0 {
+			writeResult(<-stream.Results)
+		}
+
+		doneBytes, _ := json.Marshal(Runs_StreamDoneEvent{RunId: runId})
+		fmt.Fprintf(w, "event: done\ndata: %s\n\n", doneBytes)
+		flusher.Flush()
+		return true
+	}
+
+	if endIfNotRunning() {
+		return
+	}
+
+	pollTicker := time.NewTicker(runStreamPollInterval)
+	defer pollTicker.Stop()
+
+	keepAliveTicker := time.NewTicker(runStreamKeepAliveInterval)
+	defer keepAliveTicker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-stream.Results:
+			writeResult(event)
+			flusher.Flush()
+		case <-keepAliveTicker.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-pollTicker.C:
+			if endIfNotRunning() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package testapi
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+func TestRunsStream_EndsWithRun(t *testing.T) {
+	defer func(poll time.Duration, keepAlive time.Duration) {
+		runStreamPollInterval, runStreamKeepAliveInterval = poll, keepAlive
+	}(runStreamPollInterval, runStreamKeepAliveInterval)
+	runStreamPollInterval = 10 * time.Millisecond
+	runStreamKeepAliveInterval = 20 * time.Millisecond
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	runsApi := RunsMgmtAPI{
+		UserDB:    dbs.NewUserTestDB(db),
+		SessionDB: dbs.NewSessionDB(db),
+		Retention: &RunRetention{
+			ReqTDB:     testdbs.NewRequestTestDB(db),
+			ListenerDB: testdbs.NewListenerTestDB(db),
+			RunPinDB:   testdbs.NewRunPinDB(db),
+		},
+	}
+
+	rvteTo0 := reqtestsdeps.NewRequestTestInst("http://rv.example.com", fdoshared.To0)
+	rvteTo1 := reqtestsdeps.NewRequestTestInst("http://rv.example.com", fdoshared.To1)
+	for _, rvte := range []reqtestsdeps.RequestTestInst{rvteTo0, rvteTo1} {
+		err = runsApi.Retention.ReqTDB.Save(rvte)
+		if err != nil {
+			t.Fatalf("Failed to save RVT entry. %s", err.Error())
+		}
+	}
+
+	runsApi.Retention.ReqTDB.StartNewRun(rvteTo0.Uuid)
+	rvte, _ := runsApi.Retention.ReqTDB.Get(rvteTo0.Uuid)
+	runId := rvte.CurrentTestRun.Uuid
+
+	cookie := newTestLoggedInUser(t, runsApi.UserDB, runsApi.SessionDB, dbs.UserTestDBEntry{
+		Email:       "tester@example.com",
+		Status:      dbs.AS_Validated,
+		RVTestInsts: []dbs.RVTestInst{dbs.NewRVTestInst(rvteTo0.URL, rvteTo0.Uuid, rvteTo1.Uuid)},
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(runsApi.Stream))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/run/stream?runId="+runId, nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream. %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected event stream. Got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	nextLine := func(prefix string) string {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatalf("Stream ended before line %s", prefix)
+				}
+
+				if strings.HasPrefix(line, prefix) {
+					return line
+				}
+			case <-timeout:
+				t.Fatalf("Timed out waiting for line %s", prefix)
+			}
+		}
+	}
+
+	nextLine(": keepalive")
+
+	runsApi.Retention.ReqTDB.ReportTest(rvteTo0.Uuid, testcom.FIDO_RVT_21_CHECK_RESP, testcom.NewSuccessTestState(testcom.FIDO_RVT_21_CHECK_RESP))
+	if result := nextLine("data: "); !strings.Contains(result, string(testcom.FIDO_RVT_21_CHECK_RESP)) {
+		t.Errorf("Expected result of %s. Got %s", testcom.FIDO_RVT_21_CHECK_RESP, result)
+	}
+
+	runsApi.Retention.ReqTDB.FinishRun(rvteTo0.Uuid)
+	nextLine("event: done")
+	if done := nextLine("data: "); !strings.Contains(done, runId) {
+		t.Errorf("Expected done event of run %s. Got %s", runId, done)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for ended := false; !ended; {
+		select {
+		case _, ok := <-lines:
+			ended = !ok
+		case <-timeout:
+			t.Fatalf("Expected stream to end after done event")
+		}
+	}
+}
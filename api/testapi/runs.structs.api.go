@@ -0,0 +1,32 @@
+package testapi
+
+import (
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+type Runs_PinnedResponse struct {
+	Pinned         []string                   `json:"pinned"`
+	MaxRunsPerUser int                        `json:"maxRunsPerUser"`
+	Status         commonapi.FdoConfApiStatus `json:"status"`
+}
+
+type Runs_ReplayRequest struct {
+	RunId string `json:"runId"`
+	// Replays only tests that failed in the run
+	FailedOnly bool `json:"failedOnly"`
+}
+
+type Runs_ReplayResponse struct {
+	Original reqtestsdeps.RequestTestRun `json:"original"`
+	Replay   reqtestsdeps.RequestTestRun `json:"replay"`
+	// Tests that passed in one run and failed in the other
+	OutcomeChanged []testcom.FDOTestID        `json:"outcomeChanged"`
+	Status         commonapi.FdoConfApiStatus `json:"status"`
+}
+
+// Runs_StreamDoneEvent is the last event of the run stream, "done", sent once the run is no longer running
+type Runs_StreamDoneEvent struct {
+	RunId string `json:"runId"`
+}
@@ -27,6 +27,7 @@ type RVTestMgmtAPI struct {
 	DevBaseDB *dbs.DeviceBaseDB
 	SessionDB *dbs.SessionDB
 	ConfigDB  *dbs.ConfigDB
+	Retention *RunRetention
 	Ctx       context.Context
 }
 
@@ -100,6 +101,13 @@ func (h *RVTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 
 	rvUrl := parsedUrl.Scheme + "://" + parsedUrl.Host
 
+	err = fdoshared.Outbound.CheckURL(rvUrl)
+	if err != nil {
+		log.Println("URL not allowed. " + err.Error())
+		commonapi.RespondError(w, "URL not allowed! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	mainConfig, err := h.ConfigDB.Get()
 	if err != nil {
 		log.Println("Failed to generate VDIs. " + err.Error())
@@ -189,7 +197,7 @@ func (h *RVTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 
 	rvtsList.Status = commonapi.FdoApiStatus_OK
 
-	commonapi.RespondSuccessStruct(w, rvtsList)
+	commonapi.RespondSuccessStructNegotiated(w, r, rvtsList)
 }
 
 func (h *RVTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request) {
@@ -227,6 +235,39 @@ func (h *RVTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request) {
 	commonapi.RespondSuccess(w)
 }
 
+func (h *RVTestMgmtAPI) TestRunStateChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	testinsthex := vars["testinsthex"]
+	testrunid := vars["testrunid"]
+
+	rvtId, err := hex.DecodeString(testinsthex)
+	if err != nil {
+		log.Println("Can not decode hex rvtId " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	if !userInst.RVT_ContainID(rvtId) {
+		log.Println("Id does not belong to user")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	respondStateChanges(w, r, h.ReqTDB, rvtId, testrunid)
+}
+
 func (h *RVTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 	if !commonapi.CheckHeaders(w, r) {
 		return
@@ -274,6 +315,15 @@ func (h *RVTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = fdoshared.Outbound.CheckURL(rvte.URL)
+	if err != nil {
+		log.Println("URL not allowed. " + err.Error())
+		commonapi.RespondError(w, "URL not allowed! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.Retention.Enforce(userInst, 1)
+
 	if rvte.Protocol == fdoshared.To0 {
 		testexec.ExecuteRVTestsTo0(*rvte, h.ReqTDB, h.DevBaseDB, h.Ctx)
 	} else if rvte.Protocol == fdoshared.To1 {
@@ -286,3 +336,74 @@ func (h *RVTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 
 	commonapi.RespondSuccess(w)
 }
+
+func (h *RVTestMgmtAPI) ExecuteAll(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var execReq RVT_RequestInfo
+	err = json.Unmarshal(bodyBytes, &execReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	rvtId, err := hex.DecodeString(execReq.Id)
+	if err != nil {
+		log.Println("Can not decode hex rvtid " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	rvtInst, ok := userInst.RVT_GetInst(rvtId)
+	if !ok {
+		log.Println("Id does not belong to user")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	rvtes, err := h.ReqTDB.GetMany([][]byte{rvtInst.To0, rvtInst.To1})
+	if err != nil {
+		log.Println("Can get RVT entries. " + err.Error())
+		commonapi.RespondError(w, "Internal server error!", http.StatusBadRequest)
+		return
+	}
+
+	if len(*rvtes) != 2 {
+		log.Println("Expected TO0 and TO1 RVT entries")
+		commonapi.RespondError(w, "Internal server error!", http.StatusBadRequest)
+		return
+	}
+
+	// TO0 and TO1 runners each dial their own entry URL
+	for _, rvte := range *rvtes {
+		err = fdoshared.Outbound.CheckURL(rvte.URL)
+		if err != nil {
+			log.Println("URL not allowed. " + err.Error())
+			commonapi.RespondError(w, "URL not allowed! "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	h.Retention.Enforce(userInst, 2)
+
+	testexec.ExecuteRVTests((*rvtes)[0], (*rvtes)[1], h.ReqTDB, h.DevBaseDB, h.Ctx)
+
+	commonapi.RespondSuccess(w)
+}
@@ -0,0 +1,93 @@
+package testapi
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+// newTestLoggedInUser saves the user and returns session cookie of the user logged in
+func newTestLoggedInUser(t *testing.T, userDB *dbs.UserTestDB, sessionDB *dbs.SessionDB, userInst dbs.UserTestDBEntry) *http.Cookie {
+	err := userDB.Save(userInst)
+	if err != nil {
+		t.Fatalf("Failed to save user. %s", err.Error())
+	}
+
+	sessionId, err := sessionDB.NewSessionEntry(dbs.SessionEntry{
+		Email:    userInst.Email,
+		LoggedIn: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	return &http.Cookie{Name: "session", Value: string(sessionId)}
+}
+
+func TestRVTExecuteAll_ChecksEveryURL(t *testing.T) {
+	defer func(policy fdoshared.OutboundPolicy) { fdoshared.Outbound = policy }(fdoshared.Outbound)
+	policy, err := fdoshared.NewOutboundPolicy("", "10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("Failed to create outbound policy. %s", err.Error())
+	}
+	fdoshared.Outbound = *policy
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	rvtApi := RVTestMgmtAPI{
+		UserDB:    dbs.NewUserTestDB(db),
+		ReqTDB:    testdbs.NewRequestTestDB(db),
+		SessionDB: dbs.NewSessionDB(db),
+	}
+
+	// Second entry, TO1, points to a denied network
+	rvteTo0 := reqtestsdeps.NewRequestTestInst("http://192.0.2.10:8080", fdoshared.To0)
+	rvteTo1 := reqtestsdeps.NewRequestTestInst("http://10.0.0.5:8080", fdoshared.To1)
+	for _, rvte := range []reqtestsdeps.RequestTestInst{rvteTo0, rvteTo1} {
+		err = rvtApi.ReqTDB.Save(rvte)
+		if err != nil {
+			t.Fatalf("Failed to save RVT entry. %s", err.Error())
+		}
+	}
+
+	rvtInst := dbs.NewRVTestInst(rvteTo0.URL, rvteTo0.Uuid, rvteTo1.Uuid)
+	cookie := newTestLoggedInUser(t, rvtApi.UserDB, rvtApi.SessionDB, dbs.UserTestDBEntry{
+		Email:       "tester@example.com",
+		Status:      dbs.AS_Validated,
+		RVTestInsts: []dbs.RVTestInst{rvtInst},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/rvt/execute", strings.NewReader(`{"id":"`+hex.EncodeToString(rvtInst.Uuid)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+
+	rvtApi.ExecuteAll(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "URL not allowed") {
+		t.Fatalf("Expected denied TO1 URL to be rejected. Got %d %s", rec.Code, rec.Body.String())
+	}
+
+	for _, rvteId := range [][]byte{rvteTo0.Uuid, rvteTo1.Uuid} {
+		rvte, err := rvtApi.ReqTDB.Get(rvteId)
+		if err != nil {
+			t.Fatalf("Failed to get RVT entry. %s", err.Error())
+		}
+
+		if len(rvte.TestsHistory) != 0 {
+			t.Errorf("Expected no test run to be started for %s", rvte.URL)
+		}
+	}
+}
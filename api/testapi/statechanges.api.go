@@ -0,0 +1,55 @@
+package testapi
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+type ReqT_StateChangesResponse struct {
+	TestRunId string                                               `json:"testRunId"`
+	Tests     map[testcom.FDOTestID][]reqtestsdeps.TestStateChange `json:"tests"`
+	Flaky     []testcom.FDOTestID                                  `json:"flaky"`
+	Status    commonapi.FdoConfApiStatus                           `json:"status"`
+}
+
+// respondStateChanges responds with every reported result of the run tests. Caller must check that test instance belongs to the user
+func respondStateChanges(w http.ResponseWriter, r *http.Request, reqTDB *testdbs.RequestTestDB, testInstId []byte, testRunId string) {
+	reqte, err := reqTDB.Get(testInstId)
+	if err != nil {
+		log.Println("Can not get test entry. " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	runFound := false
+	for _, testRun := range reqte.TestsHistory {
+		if testRun.Uuid == testRunId {
+			runFound = true
+			break
+		}
+	}
+
+	if !runFound {
+		commonapi.RespondError(w, "Test run not found!", http.StatusNotFound)
+		return
+	}
+
+	changes, err := reqTDB.GetStateChanges(testRunId)
+	if err != nil {
+		log.Println("Error reading test state changes. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStructNegotiated(w, r, ReqT_StateChangesResponse{
+		TestRunId: testRunId,
+		Tests:     changes,
+		Flaky:     changes.Flaky(),
+		Status:    commonapi.FdoApiStatus_OK,
+	})
+}
@@ -52,6 +52,6 @@ func (h *UserAPI) setUserSession(w http.ResponseWriter, sessionInst dbs.SessionE
 		return errors.New("Error creating session. " + err.Error())
 	}
 
-	http.SetCookie(w, commonapi.GenerateCookie(sessionDbId))
+	http.SetCookie(w, commonapi.GenerateCookie(sessionDbId, dbs.LoginSessionTTL))
 	return nil
 }
@@ -78,7 +78,7 @@ func (h *UserAPI) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.SetCookie(w, commonapi.GenerateCookie([]byte{}))
+	http.SetCookie(w, commonapi.GenerateCookie([]byte{}, dbs.LoginSessionTTL))
 
 	commonapi.RespondSuccess(w)
 }
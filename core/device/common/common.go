@@ -1,9 +1,12 @@
 package common
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"strings"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 )
@@ -47,3 +50,204 @@ func DecodePemVoucherAndKey(vandvpem string) (*fdoshared.VoucherDBEntry, error)
 		PrivateKeyX509: privateKeyBytes.Bytes,
 	}, nil
 }
+
+// decodePemOrBase64Cbor returns CBOR bytes of PEM block of pemType, or of base64 encoded CBOR. PEM blocks after the first one are returned as rest
+func decodePemOrBase64Cbor(inputStr string, pemType string) ([]byte, []byte, error) {
+	inputStr = strings.TrimSpace(inputStr)
+	if len(inputStr) == 0 {
+		return nil, nil, errors.New("The input is empty")
+	}
+
+	if strings.HasPrefix(inputStr, "-----BEGIN") {
+		block, rest := pem.Decode([]byte(inputStr))
+		if block == nil {
+			return nil, nil, errors.New("Could not decode PEM data!")
+		}
+
+		if block.Type != pemType {
+			return nil, nil, fmt.Errorf("Unexpected PEM type: %s. Expected %s", block.Type, pemType)
+		}
+
+		return block.Bytes, rest, nil
+	}
+
+	cborBytes, err := base64.StdEncoding.DecodeString(inputStr)
+	if err != nil {
+		return nil, nil, errors.New("Input is neither PEM nor base64 encoded CBOR. " + err.Error())
+	}
+
+	return cborBytes, nil, nil
+}
+
+// decodeDeviceCredential decodes WawDeviceCredential, or FDO device credential blob of other implementations, each PEM or base64 encoded CBOR
+func decodeDeviceCredential(credentialStr string) (*fdoshared.WawDeviceCredential, error) {
+	credentialStr = strings.TrimSpace(credentialStr)
+	if strings.HasPrefix(credentialStr, "-----BEGIN "+fdoshared.FDO_CREDENTIAL_PEM_TYPE+"-----") {
+		credentialBytes, _, err := decodePemOrBase64Cbor(credentialStr, fdoshared.FDO_CREDENTIAL_PEM_TYPE)
+		if err != nil {
+			return nil, err
+		}
+
+		return fdoshared.DecodeFdoDeviceCredential(credentialBytes)
+	}
+
+	credentialBytes, _, err := decodePemOrBase64Cbor(credentialStr, fdoshared.CREDENTIAL_PEM_TYPE)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentialInst fdoshared.WawDeviceCredential
+	err = fdoshared.CborCust.Unmarshal(credentialBytes, &credentialInst)
+	if err == nil {
+		return &credentialInst, nil
+	}
+
+	fdoCredential, fdoErr := fdoshared.DecodeFdoDeviceCredential(credentialBytes)
+	if fdoErr != nil {
+		return nil, fmt.Errorf("Could not CBOR unmarshal device credential! %s. Neither is it FDO credential blob: %s", err.Error(), fdoErr.Error())
+	}
+
+	return fdoCredential, nil
+}
+
+// DecodeVoucherAndCredential decodes externally generated voucher and device credential, each PEM or base64 encoded CBOR.
+// Device credential is WawDeviceCredential, or FDO credential blob exported by other implementations.
+// Voucher must be valid, with verified OVEntries, and belong to the device: same GUID, and header HMAC verified with device secret.
+// Owner private key following PEM voucher is kept, but not required
+func DecodeVoucherAndCredential(voucherStr string, credentialStr string) (*fdoshared.DeviceCredAndVoucher, error) {
+	voucherBytes, rest, err := decodePemOrBase64Cbor(voucherStr, fdoshared.OWNERSHIP_VOUCHER_PEM_TYPE)
+	if err != nil {
+		return nil, errors.New("Error decoding voucher. " + err.Error())
+	}
+
+	var voucherInst fdoshared.OwnershipVoucher
+	err = fdoshared.CborCust.Unmarshal(voucherBytes, &voucherInst)
+	if err != nil {
+		return nil, fmt.Errorf("Could not CBOR unmarshal voucher! %s", err.Error())
+	}
+
+	err = voucherInst.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("Could not validate voucher inst! %s", err.Error())
+	}
+
+	var privateKeyX509 []byte
+	privateKeyBlock, _ := pem.Decode(rest)
+	if privateKeyBlock != nil {
+		privateKeyX509 = privateKeyBlock.Bytes
+	}
+
+	credentialInst, err := decodeDeviceCredential(credentialStr)
+	if err != nil {
+		return nil, errors.New("Error decoding device credential. " + err.Error())
+	}
+
+	ovHeader, _ := voucherInst.GetOVHeader()
+	if ovHeader.OVGuid != credentialInst.DCGuid {
+		return nil, fmt.Errorf("Voucher GUID %s does not match device credential GUID %s", ovHeader.OVGuid.GetFormatted(), credentialInst.DCGuid.GetFormatted())
+	}
+
+	err = fdoshared.VerifyHMac(voucherInst.OVHeaderTag, voucherInst.OVHeaderHMac, credentialInst.DCHmacSecret)
+	if err != nil {
+		return nil, errors.New("Voucher header HMAC does not verify with device credential secret. " + err.Error())
+	}
+
+	return &fdoshared.DeviceCredAndVoucher{
+		VoucherDBEntry: fdoshared.VoucherDBEntry{
+			Voucher:        voucherInst,
+			PrivateKeyX509: privateKeyX509,
+		},
+		WawDeviceCredential: *credentialInst,
+	}, nil
+}
+
+type VoucherCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+
+	// ov_entry_keys: each suspicious key use
+	Anomalies []fdoshared.OVEntryAnomaly `json:"anomalies,omitempty"`
+}
+
+func newVoucherCheck(name string, err error) VoucherCheck {
+	if err != nil {
+		return VoucherCheck{Name: name, Passed: false, Error: err.Error()}
+	}
+
+	return VoucherCheck{Name: name, Passed: true}
+}
+
+// ValidateVoucher runs each voucher check separately, and reports all of them. Checks that depend on a failed one are not run.
+// Device credential is optional. When given, voucher GUID and header HMAC are checked against it
+func ValidateVoucher(voucherStr string, credentialStr string) []VoucherCheck {
+	checks := []VoucherCheck{}
+
+	var voucherInst fdoshared.OwnershipVoucher
+	voucherBytes, _, err := decodePemOrBase64Cbor(voucherStr, fdoshared.OWNERSHIP_VOUCHER_PEM_TYPE)
+	if err == nil {
+		err = fdoshared.CborCust.Unmarshal(voucherBytes, &voucherInst)
+	}
+	checks = append(checks, newVoucherCheck("decode", err))
+	if err != nil {
+		return checks
+	}
+
+	if voucherInst.OVProtVer != fdoshared.ProtVer101 {
+		err = fmt.Errorf("OVProtVer is %d. Expected %d", voucherInst.OVProtVer, fdoshared.ProtVer101)
+	}
+	checks = append(checks, newVoucherCheck("protocol_version", err))
+
+	ovHeader, err := voucherInst.GetOVHeader()
+	if err == nil && ovHeader.OVHProtVer != voucherInst.OVProtVer {
+		err = fmt.Errorf("OVHProtVer %d does not match OVProtVer %d", ovHeader.OVHProtVer, voucherInst.OVProtVer)
+	}
+	checks = append(checks, newVoucherCheck("header", err))
+	if err != nil {
+		return checks
+	}
+
+	if credentialStr != "" {
+		credentialInst, err := decodeDeviceCredential(credentialStr)
+		checks = append(checks, newVoucherCheck("credential_decode", err))
+
+		if err == nil {
+			if ovHeader.OVGuid != credentialInst.DCGuid {
+				err = fmt.Errorf("Voucher GUID %s does not match device credential GUID %s", ovHeader.OVGuid.GetFormatted(), credentialInst.DCGuid.GetFormatted())
+			}
+			checks = append(checks, newVoucherCheck("guid", err))
+
+			checks = append(checks, newVoucherCheck("header_hmac", fdoshared.VerifyHMac(voucherInst.OVHeaderTag, voucherInst.OVHeaderHMac, credentialInst.DCHmacSecret)))
+		}
+	}
+
+	if voucherInst.OVDevCertChain == nil || ovHeader.OVDevCertChainHash == nil {
+		checks = append(checks, newVoucherCheck("device_cert_chain", errors.New("OVDevCertChain is missing. EPID is not supported")))
+	} else {
+		ovDevCertChainHash, err := fdoshared.ComputeOVDevCertChainHash(*voucherInst.OVDevCertChain, ovHeader.OVDevCertChainHash.Type)
+		if err == nil && !bytes.Equal(ovDevCertChainHash.Hash, ovHeader.OVDevCertChainHash.Hash) {
+			err = errors.New("OVDevCertChain does not match OVDevCertChainHash")
+		}
+		checks = append(checks, newVoucherCheck("device_cert_chain_hash", err))
+
+		_, err = fdoshared.VerifyCertificateChain(*voucherInst.OVDevCertChain)
+		checks = append(checks, newVoucherCheck("device_cert_chain", err))
+	}
+
+	if len(voucherInst.OVEntryArray) == 0 {
+		err = errors.New("OVEntryArray is empty")
+	} else {
+		err = voucherInst.OVEntryArray.VerifyEntries(voucherInst.OVHeaderTag, voucherInst.OVHeaderHMac)
+	}
+	checks = append(checks, newVoucherCheck("ov_entries", err))
+
+	if len(voucherInst.OVEntryArray) != 0 {
+		// Anomalies are valid per spec, so they are listed without failing the check
+		anomalies, err := voucherInst.OVEntryArray.CheckKeyAnomalies(voucherInst.OVHeaderTag)
+		keysCheck := newVoucherCheck("ov_entry_keys", err)
+		keysCheck.Anomalies = anomalies
+		checks = append(checks, keysCheck)
+	}
+
+	return checks
+}
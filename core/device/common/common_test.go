@@ -0,0 +1,217 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func newTestVoucherAndCredential(t *testing.T) *fdoshared.DeviceCredAndVoucher {
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	credAndVoucher, err := fdodeviceimplementation.NewVirtualDeviceAndVoucher(*credential, fdoshared.StSECP256R1, fdoshared.RendezvousInfo{}, testcom.NULL_TEST)
+	if err != nil {
+		t.Fatalf("Failed to generate voucher. %s", err.Error())
+	}
+
+	return credAndVoucher
+}
+
+// EC -> RSA -> EC resale chain, final owner key is EC
+func TestValidateVoucher_MixedOwnerKeys(t *testing.T) {
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	entrySgTypes := []fdoshared.DeviceSgType{fdoshared.StSECP256R1, fdoshared.StRSA2048, fdoshared.StSECP256R1}
+	credAndVoucher, err := fdodeviceimplementation.NewVirtualDeviceAndMixedVoucher(*credential, fdoshared.StSECP256R1, entrySgTypes, fdoshared.RendezvousInfo{}, testcom.NULL_TEST)
+	if err != nil {
+		t.Fatalf("Failed to generate voucher. %s", err.Error())
+	}
+
+	voucherPem, _ := fdodeviceimplementation.MarshalVoucherAndPrivateKey(credAndVoucher.VoucherDBEntry)
+	credentialBytes, _ := fdoshared.CborCust.Marshal(credAndVoucher.WawDeviceCredential)
+
+	checks := ValidateVoucher(string(voucherPem), base64.StdEncoding.EncodeToString(credentialBytes))
+	if failed := voucherCheckErrors(checks); len(failed) != 0 || len(checks) != 10 {
+		t.Errorf("Expected all 10 checks to pass. Got %d checks, failed %v", len(checks), failed)
+	}
+
+	// Same path as device test voucher upload
+	decoded, err := DecodePemVoucherAndKey(string(voucherPem))
+	if err != nil {
+		t.Fatalf("Expected mixed voucher and owner key to decode. %s", err.Error())
+	}
+
+	err = decoded.Voucher.VerifyOVEntries()
+	if err != nil {
+		t.Errorf("Expected decoded mixed voucher to verify. %s", err.Error())
+	}
+}
+
+func TestDecodeVoucherAndCredential(t *testing.T) {
+	credAndVoucher := newTestVoucherAndCredential(t)
+
+	voucherPem, err := fdodeviceimplementation.MarshalVoucherAndPrivateKey(credAndVoucher.VoucherDBEntry)
+	if err != nil {
+		t.Fatalf("Failed to marshal voucher. %s", err.Error())
+	}
+
+	credentialBytes, _ := fdoshared.CborCust.Marshal(credAndVoucher.WawDeviceCredential)
+	credentialPem := pem.EncodeToMemory(&pem.Block{Type: fdoshared.CREDENTIAL_PEM_TYPE, Bytes: credentialBytes})
+
+	imported, err := DecodeVoucherAndCredential(string(voucherPem), string(credentialPem))
+	if err != nil {
+		t.Fatalf("Expected PEM voucher and credential to be accepted. %s", err.Error())
+	}
+
+	if imported.WawDeviceCredential.DCGuid != credAndVoucher.WawDeviceCredential.DCGuid || len(imported.VoucherDBEntry.PrivateKeyX509) == 0 {
+		t.Errorf("Expected decoded credential and owner private key")
+	}
+
+	// Raw CBOR, without owner private key
+	voucherBytes, _ := fdoshared.CborCust.Marshal(credAndVoucher.VoucherDBEntry.Voucher)
+	imported, err = DecodeVoucherAndCredential(base64.StdEncoding.EncodeToString(voucherBytes), base64.StdEncoding.EncodeToString(credentialBytes))
+	if err != nil {
+		t.Fatalf("Expected base64 CBOR voucher and credential to be accepted. %s", err.Error())
+	}
+
+	if len(imported.VoucherDBEntry.PrivateKeyX509) != 0 {
+		t.Errorf("Expected no owner private key")
+	}
+
+	// Credential of another device
+	otherCredentialBytes, _ := fdoshared.CborCust.Marshal(newTestVoucherAndCredential(t).WawDeviceCredential)
+	_, err = DecodeVoucherAndCredential(string(voucherPem), base64.StdEncoding.EncodeToString(otherCredentialBytes))
+	if err == nil {
+		t.Errorf("Expected credential of another device to be rejected")
+	}
+
+	// Same GUID, different HMAC secret
+	badSecretCredential := credAndVoucher.WawDeviceCredential
+	badSecretCredential.DCHmacSecret = fdoshared.NewHmacKey(badSecretCredential.DCHmacAlg)
+	badSecretCredentialBytes, _ := fdoshared.CborCust.Marshal(badSecretCredential)
+	_, err = DecodeVoucherAndCredential(string(voucherPem), base64.StdEncoding.EncodeToString(badSecretCredentialBytes))
+	if err == nil {
+		t.Errorf("Expected credential with wrong HMAC secret to be rejected")
+	}
+
+	// OVEntry signature does not verify
+	brokenVoucher := credAndVoucher.VoucherDBEntry.Voucher
+	brokenVoucher.OVEntryArray = append(fdoshared.OVEntryArray{}, brokenVoucher.OVEntryArray...)
+	brokenVoucher.OVEntryArray[0].Signature = append([]byte{}, brokenVoucher.OVEntryArray[0].Signature...)
+	brokenVoucher.OVEntryArray[0].Signature[0] ^= 0xff
+	brokenVoucherBytes, _ := fdoshared.CborCust.Marshal(brokenVoucher)
+	_, err = DecodeVoucherAndCredential(base64.StdEncoding.EncodeToString(brokenVoucherBytes), string(credentialPem))
+	if err == nil {
+		t.Errorf("Expected voucher with bad OVEntry signature to be rejected")
+	}
+
+	_, err = DecodeVoucherAndCredential("not a voucher", string(credentialPem))
+	if err == nil {
+		t.Errorf("Expected malformed voucher to be rejected")
+	}
+}
+
+func TestDecodeVoucherAndCredential_FdoCredential(t *testing.T) {
+	credAndVoucher := newTestVoucherAndCredential(t)
+	credential := credAndVoucher.WawDeviceCredential
+
+	voucherBytes, _ := fdoshared.CborCust.Marshal(credAndVoucher.VoucherDBEntry.Voucher)
+	voucherB64 := base64.StdEncoding.EncodeToString(voucherBytes)
+
+	// Spec DeviceCredential followed by device private key
+	fdoCredentialBytes, _ := fdoshared.CborCust.Marshal([]interface{}{
+		true, credential.DCProtVer, credential.DCHmacSecret, credential.DCDeviceInfo, credential.DCGuid, fdoshared.RendezvousInfo{}, credential.DCPubKeyHash, credential.DCPrivateKeyDer,
+	})
+	fdoCredentialPem := pem.EncodeToMemory(&pem.Block{Type: fdoshared.FDO_CREDENTIAL_PEM_TYPE, Bytes: fdoCredentialBytes})
+
+	for _, credentialStr := range []string{string(fdoCredentialPem), base64.StdEncoding.EncodeToString(fdoCredentialBytes)} {
+		imported, err := DecodeVoucherAndCredential(voucherB64, credentialStr)
+		if err != nil {
+			t.Fatalf("Expected FDO credential to be accepted. %s", err.Error())
+		}
+
+		importedCredential := imported.WawDeviceCredential
+		if importedCredential.DCGuid != credential.DCGuid || importedCredential.DCSigInfo.SgType != fdoshared.StSECP256R1 || importedCredential.DCHmacAlg != credential.DCHmacAlg {
+			t.Errorf("Unexpected imported credential %+v", importedCredential)
+		}
+	}
+
+	checkErrors := voucherCheckErrors(ValidateVoucher(voucherB64, string(fdoCredentialPem)))
+	if len(checkErrors) != 0 {
+		t.Errorf("Expected voucher to validate with FDO credential. Got %v", checkErrors)
+	}
+
+	_, err := DecodeVoucherAndCredential(voucherB64, base64.StdEncoding.EncodeToString([]byte{0x83, 0x01, 0x02, 0x03}))
+	if err == nil {
+		t.Errorf("Expected unsupported credential format to be rejected")
+	}
+}
+
+func voucherCheckErrors(checks []VoucherCheck) map[string]string {
+	checkErrors := map[string]string{}
+	for _, check := range checks {
+		if !check.Passed {
+			checkErrors[check.Name] = check.Error
+		}
+	}
+
+	// Test root is SHA1 signed, so chain result depends on x509sha1 GODEBUG
+	delete(checkErrors, "device_cert_chain")
+
+	return checkErrors
+}
+
+func TestValidateVoucher(t *testing.T) {
+	credAndVoucher := newTestVoucherAndCredential(t)
+
+	voucherPem, _ := fdodeviceimplementation.MarshalVoucherAndPrivateKey(credAndVoucher.VoucherDBEntry)
+	credentialBytes, _ := fdoshared.CborCust.Marshal(credAndVoucher.WawDeviceCredential)
+
+	checks := ValidateVoucher(string(voucherPem), base64.StdEncoding.EncodeToString(credentialBytes))
+	if failed := voucherCheckErrors(checks); len(failed) != 0 || len(checks) != 10 {
+		t.Errorf("Expected all 10 checks to pass. Got %d checks, failed %v", len(checks), failed)
+	}
+
+	// Without credential, GUID and HMAC are not checked
+	checks = ValidateVoucher(string(voucherPem), "")
+	if failed := voucherCheckErrors(checks); len(failed) != 0 || len(checks) != 7 {
+		t.Errorf("Expected 7 checks to pass. Got %d checks, failed %v", len(checks), failed)
+	}
+
+	// Credential of another device
+	otherCredentialBytes, _ := fdoshared.CborCust.Marshal(newTestVoucherAndCredential(t).WawDeviceCredential)
+	failed := voucherCheckErrors(ValidateVoucher(string(voucherPem), base64.StdEncoding.EncodeToString(otherCredentialBytes)))
+	if _, ok := failed["guid"]; !ok || len(failed) != 2 {
+		t.Errorf("Expected guid and header_hmac checks to fail. Got %v", failed)
+	}
+
+	// OVEntry signature does not verify, device cert chain truncated
+	brokenVoucher := credAndVoucher.VoucherDBEntry.Voucher
+	brokenVoucher.OVEntryArray = append(fdoshared.OVEntryArray{}, brokenVoucher.OVEntryArray...)
+	brokenVoucher.OVEntryArray[0].Signature = append([]byte{}, brokenVoucher.OVEntryArray[0].Signature...)
+	brokenVoucher.OVEntryArray[0].Signature[0] ^= 0xff
+	brokenVoucher.OVDevCertChain = &[]fdoshared.X509CertificateBytes{(*brokenVoucher.OVDevCertChain)[0]}
+	brokenVoucherBytes, _ := fdoshared.CborCust.Marshal(brokenVoucher)
+
+	failed = voucherCheckErrors(ValidateVoucher(base64.StdEncoding.EncodeToString(brokenVoucherBytes), ""))
+	for _, name := range []string{"ov_entries", "device_cert_chain_hash"} {
+		if _, ok := failed[name]; !ok {
+			t.Errorf("Expected %s check to fail. Got %v", name, failed)
+		}
+	}
+
+	checks = ValidateVoucher("not a voucher", "")
+	if len(checks) != 1 || checks[0].Name != "decode" || checks[0].Passed {
+		t.Errorf("Expected only failed decode check. Got %v", checks)
+	}
+}
@@ -64,6 +64,20 @@ func GenerateOvEntry(
 }
 
 func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgType fdoshared.DeviceSgType, ovRVInfo fdoshared.RendezvousInfo, fdoTestID testcom.FDOTestID) (*fdoshared.DeviceCredAndVoucher, error) {
+	return NewVirtualDeviceAndMixedVoucher(newDi, voucherSgType, nil, ovRVInfo, fdoTestID)
+}
+
+// NewVirtualDeviceAndMixedVoucher generates voucher where OVEntry i carries owner key of entrySgTypes[i], e.g. EC->RSA->EC resale chain.
+// Manufacturer key in the header is voucherSgType. Empty entrySgTypes generates random count of voucherSgType entries
+func NewVirtualDeviceAndMixedVoucher(newDi fdoshared.WawDeviceCredential, voucherSgType fdoshared.DeviceSgType, entrySgTypes []fdoshared.DeviceSgType, ovRVInfo fdoshared.RendezvousInfo, fdoTestID testcom.FDOTestID) (*fdoshared.DeviceCredAndVoucher, error) {
+	// Test
+	if certValidity := testcom.Conf_DeviceCertValidity(fdoTestID); certValidity != nil {
+		err := newDi.Conf_ReissueLeafCertificate(*certValidity)
+		if err != nil {
+			return nil, errors.New("Error reissuing device certificate. " + err.Error())
+		}
+	}
+
 	negotiatedHashHmac := fdoshared.NegotiateHashHmac(newDi.DCSigInfo.SgType, voucherSgType)
 
 	newDi.UpdatedToNewHashHmac(negotiatedHashHmac)
@@ -127,6 +141,9 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 
 	// Test params preparation
 	var ovEntriesCount int = fdoshared.NewRandomInt(3, 7)
+	if len(entrySgTypes) != 0 {
+		ovEntriesCount = len(entrySgTypes)
+	}
 	var badOvEntryIndex = fdoshared.NewRandomInt(0, ovEntriesCount)
 
 	var prevEntryPrivKey interface{} = mfgPrivateKey
@@ -172,6 +189,10 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 		}
 
 		chosenSgType := voucherSgType
+		if len(entrySgTypes) != 0 {
+			chosenSgType = entrySgTypes[i]
+		}
+
 		// Test
 		if i == badOvEntryIndex {
 			if fdoTestID == testcom.FIDO_TEST_VOUCHER_ENTRY_BAD_HDRINFO_HASH {
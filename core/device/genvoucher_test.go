@@ -0,0 +1,207 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func TestNewVirtualDeviceAndMixedVoucher(t *testing.T) {
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	// EC -> RSA -> EC resale chain
+	entrySgTypes := []fdoshared.DeviceSgType{fdoshared.StSECP256R1, fdoshared.StRSA2048, fdoshared.StSECP384R1}
+	expectedPkTypes := []fdoshared.FdoPkType{fdoshared.SECP256R1, fdoshared.RSA2048RESTR, fdoshared.SECP384R1}
+
+	credAndVoucher, err := NewVirtualDeviceAndMixedVoucher(*credential, fdoshared.StSECP256R1, entrySgTypes, fdoshared.RendezvousInfo{}, testcom.NULL_TEST)
+	if err != nil {
+		t.Fatalf("Failed to generate voucher. %s", err.Error())
+	}
+
+	voucher := credAndVoucher.VoucherDBEntry.Voucher
+	if len(voucher.OVEntryArray) != len(entrySgTypes) {
+		t.Fatalf("Expected %d OVEntries, got %d", len(entrySgTypes), len(voucher.OVEntryArray))
+	}
+
+	for i, ovEntry := range voucher.OVEntryArray {
+		pubKey, err := ovEntry.GetOVEntryPubKey()
+		if err != nil {
+			t.Fatalf("Failed to decode OVEntry %d public key. %s", i, err.Error())
+		}
+
+		if pubKey.PkType != expectedPkTypes[i] {
+			t.Errorf("Expected OVEntry %d pkType %d, got %d", i, expectedPkTypes[i], pubKey.PkType)
+		}
+	}
+
+	err = voucher.VerifyOVEntries()
+	if err != nil {
+		t.Errorf("Expected heterogeneous chain to verify. %s", err.Error())
+	}
+
+	err = voucher.Validate()
+	if err != nil {
+		t.Errorf("Expected heterogeneous voucher to validate. %s", err.Error())
+	}
+
+	// Owner signs TO2.ProveOVHdr with the last entry key, and device checks it against the last entry
+	ownerPubKey, err := voucher.GetFinalOwnerPublicKey()
+	if err != nil {
+		t.Fatalf("Failed to get final owner public key. %s", err.Error())
+	}
+
+	ownerPrivKey, err := fdoshared.ExtractPrivateKey(credAndVoucher.VoucherDBEntry.PrivateKeyX509)
+	if err != nil {
+		t.Fatalf("Failed to decode final owner private key. %s", err.Error())
+	}
+
+	signature, err := fdoshared.GenerateCoseSignature([]byte("ProveOVHdr"), fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{}, ownerPrivKey, fdoshared.PkToSgType[ownerPubKey.PkType])
+	if err != nil {
+		t.Fatalf("Failed to sign with final owner key. %s", err.Error())
+	}
+
+	err = fdoshared.VerifyCoseSignature(*signature, ownerPubKey)
+	if err != nil {
+		t.Errorf("Expected final owner key signature to verify. %s", err.Error())
+	}
+
+	requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{}, credAndVoucher.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	requestor.ProveOVHdr61PubKey = ownerPubKey
+
+	err = requestor.VerifyOwnerPubKey(voucher.OVEntryArray)
+	if err != nil {
+		t.Errorf("Expected device to accept owner key of heterogeneous chain. %s", err.Error())
+	}
+
+	// Broken link in the middle of the chain must still be detected
+	voucher.OVEntryArray[1].Signature = fdoshared.Conf_RandomCborBufferFuzzing(voucher.OVEntryArray[1].Signature)
+	err = voucher.VerifyOVEntries()
+	if err == nil {
+		t.Errorf("Expected tampered RSA entry to fail verification")
+	}
+}
+
+func TestLongResaleChainOwnerKeyRotation(t *testing.T) {
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	// Every resale rotates owner key. Same key type, so only the key itself tells owners apart
+	const rotations = 32
+	entrySgTypes := []fdoshared.DeviceSgType{}
+	for i := 0; i < rotations; i++ {
+		entrySgTypes = append(entrySgTypes, fdoshared.StSECP256R1)
+	}
+
+	credAndVoucher, err := NewVirtualDeviceAndMixedVoucher(*credential, fdoshared.StSECP256R1, entrySgTypes, fdoshared.RendezvousInfo{}, testcom.NULL_TEST)
+	if err != nil {
+		t.Fatalf("Failed to generate voucher. %s", err.Error())
+	}
+
+	voucher := credAndVoucher.VoucherDBEntry.Voucher
+	if len(voucher.OVEntryArray) != rotations {
+		t.Fatalf("Expected %d OVEntries, got %d", rotations, len(voucher.OVEntryArray))
+	}
+
+	ovHeader, err := voucher.GetOVHeader()
+	if err != nil {
+		t.Fatalf("Failed to decode OVHeader. %s", err.Error())
+	}
+
+	entryPubKeys := []fdoshared.FdoPublicKey{}
+	for i, ovEntry := range voucher.OVEntryArray {
+		pubKey, err := ovEntry.GetOVEntryPubKey()
+		if err != nil {
+			t.Fatalf("Failed to decode OVEntry %d public key. %s", i, err.Error())
+		}
+
+		if pubKey.Equal(ovHeader.OVPublicKey) == nil {
+			t.Errorf("Expected OVEntry %d key to differ from manufacturer key", i)
+		}
+
+		for j, prevPubKey := range entryPubKeys {
+			if pubKey.Equal(prevPubKey) == nil {
+				t.Errorf("Expected OVEntry %d key to differ from OVEntry %d key", i, j)
+			}
+		}
+
+		entryPubKeys = append(entryPubKeys, pubKey)
+	}
+
+	// Device follows the chain from the manufacturer key, as in TO2.GetOVNextEntry
+	err = voucher.OVEntryArray.VerifyEntries(voucher.OVHeaderTag, voucher.OVHeaderHMac)
+	if err != nil {
+		t.Fatalf("Expected device to verify %d entries chain. %s", rotations, err.Error())
+	}
+
+	requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{}, credAndVoucher.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+
+	finalOwnerPubKey, err := voucher.GetFinalOwnerPublicKey()
+	if err != nil {
+		t.Fatalf("Failed to get final owner public key. %s", err.Error())
+	}
+
+	requestor.ProveOVHdr61PubKey = finalOwnerPubKey
+	err = requestor.VerifyOwnerPubKey(voucher.OVEntryArray)
+	if err != nil {
+		t.Errorf("Expected device to accept the final owner key. %s", err.Error())
+	}
+
+	// Any earlier owner, or the manufacturer, must not pass as the final owner
+	for i, pubKey := range entryPubKeys[:rotations-1] {
+		requestor.ProveOVHdr61PubKey = pubKey
+		err = requestor.VerifyOwnerPubKey(voucher.OVEntryArray)
+		if err == nil {
+			t.Errorf("Expected device to reject OVEntry %d owner key as final owner", i)
+		}
+	}
+
+	requestor.ProveOVHdr61PubKey = ovHeader.OVPublicKey
+	err = requestor.VerifyOwnerPubKey(voucher.OVEntryArray)
+	if err == nil {
+		t.Errorf("Expected device to reject manufacturer key as final owner")
+	}
+
+	// Chain cut short by one resale ends with a previous owner
+	requestor.ProveOVHdr61PubKey = finalOwnerPubKey
+	err = requestor.VerifyOwnerPubKey(voucher.OVEntryArray[:rotations-1])
+	if err == nil {
+		t.Errorf("Expected device to reject final owner key with truncated chain")
+	}
+}
+
+func TestNewVirtualDeviceAndVoucher_Ed25519(t *testing.T) {
+	fdoshared.SetExperimentalEd25519(true)
+	defer fdoshared.SetExperimentalEd25519(false)
+
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StED25519)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 device credential. %s", err.Error())
+	}
+
+	credAndVoucher, err := NewVirtualDeviceAndVoucher(*credential, fdoshared.StED25519, fdoshared.RendezvousInfo{}, testcom.NULL_TEST)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 voucher. %s", err.Error())
+	}
+
+	voucher := credAndVoucher.VoucherDBEntry.Voucher
+	err = voucher.VerifyOVEntries()
+	if err != nil {
+		t.Errorf("Expected Ed25519 OVEntries to verify. %s", err.Error())
+	}
+
+	ownerPubKey, err := voucher.GetFinalOwnerPublicKey()
+	if err != nil {
+		t.Fatalf("Failed to get final owner public key. %s", err.Error())
+	}
+
+	if ownerPubKey.PkType != fdoshared.ED25519 {
+		t.Errorf("Expected final owner pkType %d, got %d", fdoshared.ED25519, ownerPubKey.PkType)
+	}
+}
@@ -29,6 +29,11 @@ func (h *To1Requestor) HelloRV30(fdoTestID testcom.FDOTestID) (*fdoshared.HelloR
 		return nil, nil, errors.New("HelloRV30: Error marshaling HelloRV30. " + err.Error())
 	}
 
+	// GUID of incorrect length can not be held in FdoGuid, so the message is encoded as array
+	if fdoTestID == testcom.FIDO_DEVT_30_BAD_GUID_LEN {
+		helloRV30Bytes, _ = fdoshared.CborCust.Marshal([]interface{}{helloRv30.Guid[:len(helloRv30.Guid)-1], helloRv30.EASigInfo})
+	}
+
 	if fdoTestID == testcom.FIDO_DEVT_30_BAD_ENCODING {
 		helloRV30Bytes = fdoshared.Conf_RandomCborBufferFuzzing(helloRV30Bytes)
 	}
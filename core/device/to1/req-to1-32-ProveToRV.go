@@ -75,5 +75,12 @@ func (h *To1Requestor) ProveToRV32(helloRVAck31 fdoshared.HelloRVAck31, fdoTestI
 		return nil, &testState, errors.New("RVRedirect33: Received FDO Error: " + fdoError.Error())
 	}
 
+	if h.rvCountersignKey != nil {
+		err = fdoshared.VerifyCoseCounterSignature(rvRedirect33, *h.rvCountersignKey)
+		if err != nil {
+			return nil, &testState, errors.New("RVRedirect33: Failed to verify RV countersignature. " + err.Error())
+		}
+	}
+
 	return &rvRedirect33, &testState, nil
 }
@@ -9,6 +9,9 @@ type To1Requestor struct {
 	rvEntry     fdoshared.SRVEntry
 	credential  fdoshared.WawDeviceCredential
 	authzHeader string
+
+	// When set, RVRedirect33 To1d must carry valid RV countersignature
+	rvCountersignKey *fdoshared.FdoPublicKey
 }
 
 func NewTo1Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential) To1Requestor {
@@ -18,6 +21,11 @@ func NewTo1Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDevice
 	}
 }
 
+// RequireRVCountersign makes the device verify RV countersignature on To1d with the RV public key
+func (h *To1Requestor) RequireRVCountersign(rvPublicKey fdoshared.FdoPublicKey) {
+	h.rvCountersignKey = &rvPublicKey
+}
+
 func (h *To1Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDOTestID, httpStatusCode int) testcom.FDOTestState {
 	switch fdoTestID {
 
@@ -0,0 +1,25 @@
+package to2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Error255 aborts TO2 by sending error message to the owner, so it can terminate the session
+func (h *To2Requestor) Error255(errorCode fdoshared.FdoErrorCode, prevMsgId fdoshared.FdoCmd, messageStr string) error {
+	errorBytes, _ := fdoshared.CborCust.Marshal(fdoshared.NewFdoError(errorCode, prevMsgId, messageStr))
+
+	_, _, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO_ERROR_255, errorBytes, &h.AuthzHeader)
+	if err != nil {
+		return errors.New("Error255: Error sending error message... " + err.Error())
+	}
+
+	if httpStatusCode != http.StatusOK {
+		return fmt.Errorf("Error255: Owner responded with status %d", httpStatusCode)
+	}
+
+	return nil
+}
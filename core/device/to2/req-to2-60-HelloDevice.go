@@ -14,6 +14,7 @@ func (h *To2Requestor) HelloDevice60(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 	var testState testcom.FDOTestState
 
 	h.NonceTO2ProveOV60 = fdoshared.NewFdoNonce()
+	h.fetchedOVEntries = nil
 
 	helloDevice60 := fdoshared.HelloDevice60{
 		MaxDeviceMessageSize: MaxDeviceMessageSize,
@@ -33,9 +34,11 @@ func (h *To2Requestor) HelloDevice60(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		helloDevice60Byte = fdoshared.Conf_RandomCborBufferFuzzing(helloDevice60Byte)
 	}
 
-	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_60_HELLO_DEVICE, helloDevice60Byte, &h.SrvEntry.AccessToken)
+	helloDevice60Byte = h.fuzzPayload(fdoshared.TO2_60_HELLO_DEVICE, helloDevice60Byte)
+
+	resultBytes, authzHeader, httpStatusCode, err := h.sendCborPost(fdoshared.TO2_60_HELLO_DEVICE, helloDevice60Byte, &h.SrvEntry.AccessToken)
 	if fdoTestID != testcom.NULL_TEST {
-		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode)
+		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode, err)
 		return nil, &testState, nil
 	}
 
@@ -50,6 +53,11 @@ func (h *To2Requestor) HelloDevice60(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		}
 	}
 
+	err = checkMessageSize(fdoshared.TO2_61_PROVE_OVHDR, resultBytes)
+	if err != nil {
+		return nil, nil, errors.New("HelloDevice60: " + err.Error())
+	}
+
 	h.AuthzHeader = authzHeader
 
 	var proveOVHdr61 fdoshared.CoseSignature
@@ -82,11 +90,16 @@ func (h *To2Requestor) HelloDevice60(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		return nil, nil, errors.New("HelloDevice60: Failed SigInfo check. " + err.Error())
 	}
 
+	err = fdoshared.Limits.CheckOVEntriesCount(int(proveOvdrPayload.NumOVEntries))
+	if err != nil {
+		return nil, nil, errors.New("HelloDevice60: " + err.Error())
+	}
+
 	if !bytes.Equal(proveOvdrPayload.NonceTO2ProveOV[:], h.NonceTO2ProveOV60[:]) {
 		return nil, nil, errors.New("HelloDevice60: DO returned wrong NonceTO2ProveOV")
 	}
 
-	err = fdoshared.VerifyHMac(proveOvdrPayload.OVHeader, proveOvdrPayload.HMac, h.Credential.DCHmacSecret)
+	err = h.verifyOVHeaderHMac(proveOvdrPayload)
 	if err != nil {
 		return nil, nil, errors.New("HelloDevice60: Unknown Header HMac. " + err.Error())
 	}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
@@ -22,9 +23,11 @@ func (h *To2Requestor) GetOVNextEntry62(entryNum uint8, fdoTestID testcom.FDOTes
 		getOvNextEntryBytes = fdoshared.Conf_RandomCborBufferFuzzing(getOvNextEntryBytes)
 	}
 
-	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_62_GET_OVNEXTENTRY, getOvNextEntryBytes, &h.AuthzHeader)
+	getOvNextEntryBytes = h.fuzzPayload(fdoshared.TO2_62_GET_OVNEXTENTRY, getOvNextEntryBytes)
+
+	resultBytes, authzHeader, httpStatusCode, err := h.sendCborPost(fdoshared.TO2_62_GET_OVNEXTENTRY, getOvNextEntryBytes, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
-		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode)
+		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode, err)
 		return nil, &testState, nil
 	}
 
@@ -32,24 +35,153 @@ func (h *To2Requestor) GetOVNextEntry62(entryNum uint8, fdoTestID testcom.FDOTes
 		return nil, nil, err
 	}
 
+	nextEntry, err := decodeOVNextEntry63(entryNum, resultBytes, httpStatusCode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.AuthzHeader = authzHeader
+
+	return nextEntry, &testState, nil
+}
+
+// decodeOVNextEntry63 checks owner response to GetOVNextEntry62 and decodes it
+func decodeOVNextEntry63(entryNum uint8, resultBytes []byte, httpStatusCode int) (*fdoshared.OVNextEntry63, error) {
 	if httpStatusCode != http.StatusOK {
 		fdoErrInst, err := fdoshared.DecodeErrorResponse(resultBytes)
 		if err == nil {
-			return nil, nil, fmt.Errorf("GetOVNextEntry62: %s", fdoErrInst.EMErrorStr)
+			return nil, fmt.Errorf("GetOVNextEntry62: %s", fdoErrInst.EMErrorStr)
 		}
-	}
 
-	h.AuthzHeader = authzHeader
+		return nil, fmt.Errorf("GetOVNextEntry62: Unexpected HTTP status %d", httpStatusCode)
+	}
 
 	var nextEntry fdoshared.OVNextEntry63
 	fdoError, err := fdoshared.TryCborUnmarshal(resultBytes, &nextEntry)
 	if err != nil {
-		return nil, nil, errors.New("GetOVNextEntry64: Failed to unmarshal OVNextEntry63. " + err.Error())
+		return nil, errors.New("GetOVNextEntry64: Failed to unmarshal OVNextEntry63. " + err.Error())
 	}
 
 	if fdoError != nil {
-		return nil, nil, errors.New("GetOVNextEntry64: Received FDO Error: " + fdoError.Error())
+		return nil, errors.New("GetOVNextEntry64: Received FDO Error: " + fdoError.Error())
+	}
+
+	if len(nextEntry.OVEntry.Payload) == 0 {
+		return nil, fmt.Errorf("GetOVNextEntry62: Owner returned empty OVEntry %d", entryNum)
+	}
+
+	ovEntryBytes, _ := fdoshared.CborCust.Marshal(nextEntry.OVEntry)
+	err = fdoshared.Limits.CheckOVEntrySize(len(ovEntryBytes))
+	if err != nil {
+		return nil, errors.New("GetOVNextEntry62: " + err.Error())
+	}
+
+	return &nextEntry, nil
+}
+
+// fetchOVNextEntry sends GetOVNextEntry62 without changing requestor state, so entries can be fetched concurrently. Returns owner authorization header
+func (h *To2Requestor) fetchOVNextEntry(entryNum uint8, authzHeader string) (*fdoshared.OVNextEntry63, string, error) {
+	getOvNextEntryBytes, _ := fdoshared.CborCust.Marshal(fdoshared.GetOVNextEntry62{
+		GetOVNextEntry: entryNum,
+	})
+
+	resultBytes, respAuthzHeader, httpStatusCode, err := h.sendCborPostWithTimeout(fdoshared.TO2_62_GET_OVNEXTENTRY, getOvNextEntryBytes, &authzHeader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextEntry, err := decodeOVNextEntry63(entryNum, resultBytes, httpStatusCode)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextEntry, respAuthzHeader, nil
+}
+
+type ovNextEntryResult struct {
+	nextEntry   *fdoshared.OVNextEntry63
+	authzHeader string
+	err         error
+}
+
+// fetchOVEntriesBatch fetches entries start to end. Single entry is fetched with GetOVNextEntry62, more are fetched concurrently.
+// Returns entries in order, up to the first entry that failed
+func (h *To2Requestor) fetchOVEntriesBatch(start int, end int, numOVEntries uint8) ([]fdoshared.OVNextEntry63, error) {
+	results := make([]ovNextEntryResult, end-start)
+
+	if end-start == 1 {
+		nextEntry, _, err := h.GetOVNextEntry62(uint8(start), testcom.NULL_TEST)
+		results[0] = ovNextEntryResult{nextEntry: nextEntry, authzHeader: h.AuthzHeader, err: err}
+	} else {
+		var wg sync.WaitGroup
+		authzHeader := h.AuthzHeader
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				result := &results[i-start]
+				result.nextEntry, result.authzHeader, result.err = h.fetchOVNextEntry(uint8(i), authzHeader)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	nextEntries := []fdoshared.OVNextEntry63{}
+	for i, result := range results {
+		entryNum := start + i
+		if result.err != nil {
+			return nextEntries, fmt.Errorf("error fetching OVEntry %d of %d. %s", entryNum, numOVEntries, result.err.Error())
+		}
+
+		if result.nextEntry.OVEntryNum != uint8(entryNum) {
+			return nextEntries, fmt.Errorf("owner returned unexpected OVEntry. Expected %d. Got %d", entryNum, result.nextEntry.OVEntryNum)
+		}
+
+		h.AuthzHeader = result.authzHeader
+		nextEntries = append(nextEntries, *result.nextEntry)
+	}
+
+	return nextEntries, nil
+}
+
+// GetAllOVEntries fetches OVEntries claimed in TO2.ProveOVHdr, OVEntryBatchSize at a time. Fails on the first entry owner does not return, or returns out of order.
+// Entries fetched before the failure are kept, so calling it again within the same TO2 session resumes after them
+func (h *To2Requestor) GetAllOVEntries(numOVEntries uint8) (fdoshared.OVEntryArray, error) {
+	err := fdoshared.Limits.CheckOVEntriesCount(int(numOVEntries))
+	if err != nil {
+		return nil, errors.New("error fetching OVEntries. " + err.Error())
+	}
+
+	if len(h.fetchedOVEntries) > int(numOVEntries) {
+		h.fetchedOVEntries = nil
+	}
+
+	// Fuzzer mutates a single message, so entries are fetched one at a time
+	batchSize := h.OVEntryBatchSize
+	if batchSize < 1 || h.Fuzzer != nil {
+		batchSize = 1
+	}
+
+	for start := len(h.fetchedOVEntries); start < int(numOVEntries); start = len(h.fetchedOVEntries) {
+		end := start + batchSize
+		if end > int(numOVEntries) {
+			end = int(numOVEntries)
+		}
+
+		nextEntries, err := h.fetchOVEntriesBatch(start, end, numOVEntries)
+		for _, nextEntry := range nextEntries {
+			h.fetchedOVEntries = append(h.fetchedOVEntries, nextEntry.OVEntry)
+
+			if h.OVEntryProgress != nil {
+				h.OVEntryProgress(len(h.fetchedOVEntries), int(numOVEntries))
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return &nextEntry, &testState, nil
+	return append(fdoshared.OVEntryArray{}, h.fetchedOVEntries...), nil
 }
@@ -0,0 +1,270 @@
+package to2
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// Owner claiming OVEntries in ProveOVHdr, that only returns the first entry
+func newMissingEntriesTestOwner(t *testing.T, missingEntryResponse func(w http.ResponseWriter), requestCount *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requestCount++
+
+		var getOVNextEntry fdoshared.GetOVNextEntry62
+		bodyBytes, _ := io.ReadAll(r.Body)
+		err := fdoshared.CborCust.Unmarshal(bodyBytes, &getOVNextEntry)
+		if err != nil {
+			t.Errorf("Failed to decode GetOVNextEntry62. %s", err.Error())
+		}
+
+		if getOVNextEntry.GetOVNextEntry == 0 {
+			entryBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OVNextEntry63{
+				OVEntryNum: 0,
+				OVEntry: fdoshared.CoseSignature{
+					Payload: []byte{0xa0},
+				},
+			})
+			w.Write(entryBytes)
+			return
+		}
+
+		missingEntryResponse(w)
+	}))
+}
+
+func TestGetAllOVEntries_MissingEntries(t *testing.T) {
+	emptyEntryBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OVNextEntry63{OVEntryNum: 1})
+	fdoErrorBytes, _ := fdoshared.CborCust.Marshal(fdoshared.FdoError{
+		EMErrorCode: fdoshared.RESOURCE_NOT_FOUND,
+		EMPrevMsgID: fdoshared.TO2_62_GET_OVNEXTENTRY,
+		EMErrorStr:  "No such entry",
+	})
+
+	testCases := map[string]func(w http.ResponseWriter){
+		"empty OVEntry": func(w http.ResponseWriter) {
+			w.Write(emptyEntryBytes)
+		},
+		"empty body": func(w http.ResponseWriter) {},
+		"FDO error": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(fdoErrorBytes)
+		},
+		"error without body": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	}
+
+	for name, missingEntryResponse := range testCases {
+		var requestCount int
+		owner := newMissingEntriesTestOwner(t, missingEntryResponse, &requestCount)
+
+		requestor := NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+
+		ovEntries, err := requestor.GetAllOVEntries(5)
+		if err == nil {
+			t.Errorf("%s: Expected fetching claimed OVEntries to fail", name)
+		}
+
+		if ovEntries != nil {
+			t.Errorf("%s: Expected no OVEntries on failure. Got %d", name, len(ovEntries))
+		}
+
+		// Device must abort on the missing entry, instead of retrying or fetching the rest
+		if requestCount != 2 {
+			t.Errorf("%s: Expected device to stop after 2 requests. Got %d", name, requestCount)
+		}
+
+		owner.Close()
+	}
+}
+
+// Owner returning entry number mapped by entryNums, and failing failEntry once
+func newBatchTestOwner(t *testing.T, entryNums map[uint8]uint8, failEntry uint8) (*httptest.Server, *int) {
+	var mu sync.Mutex
+	var requestCount int
+	failed := false
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var getOVNextEntry fdoshared.GetOVNextEntry62
+		bodyBytes, _ := io.ReadAll(r.Body)
+		err := fdoshared.CborCust.Unmarshal(bodyBytes, &getOVNextEntry)
+		if err != nil {
+			t.Errorf("Failed to decode GetOVNextEntry62. %s", err.Error())
+		}
+
+		entryNum := getOVNextEntry.GetOVNextEntry
+
+		mu.Lock()
+		requestCount++
+		failNow := entryNum == failEntry && !failed
+		if failNow {
+			failed = true
+		}
+		mu.Unlock()
+
+		if failNow {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if mappedNum, ok := entryNums[entryNum]; ok {
+			entryNum = mappedNum
+		}
+
+		entryBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OVNextEntry63{
+			OVEntryNum: entryNum,
+			OVEntry: fdoshared.CoseSignature{
+				Payload: []byte{0xa0},
+			},
+		})
+		w.Write(entryBytes)
+	})), &requestCount
+}
+
+func TestGetAllOVEntries_Batched(t *testing.T) {
+	owner, requestCount := newBatchTestOwner(t, nil, 255)
+	defer owner.Close()
+
+	requestor := NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	requestor.OVEntryBatchSize = 4
+
+	progress := []int{}
+	requestor.OVEntryProgress = func(fetched int, total int) {
+		if total != 10 {
+			t.Errorf("Expected progress total 10. Got %d", total)
+		}
+		progress = append(progress, fetched)
+	}
+
+	ovEntries, err := requestor.GetAllOVEntries(10)
+	if err != nil {
+		t.Fatalf("Expected batched fetch to succeed. %s", err.Error())
+	}
+
+	if len(ovEntries) != 10 || *requestCount != 10 {
+		t.Errorf("Expected 10 OVEntries in 10 requests. Got %d in %d", len(ovEntries), *requestCount)
+	}
+
+	for i, fetched := range progress {
+		if fetched != i+1 {
+			t.Fatalf("Expected progress to count fetched entries in order. Got %v", progress)
+		}
+	}
+}
+
+func TestGetAllOVEntries_BatchedUnexpectedEntryNum(t *testing.T) {
+	owner, _ := newBatchTestOwner(t, map[uint8]uint8{5: 6}, 255)
+	defer owner.Close()
+
+	requestor := NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	requestor.OVEntryBatchSize = 4
+
+	ovEntries, err := requestor.GetAllOVEntries(8)
+	if err == nil || ovEntries != nil {
+		t.Errorf("Expected out of order OVEntryNum to be rejected")
+	}
+}
+
+func TestGetAllOVEntries_Resume(t *testing.T) {
+	owner, requestCount := newBatchTestOwner(t, nil, 6)
+	defer owner.Close()
+
+	requestor := NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	requestor.OVEntryBatchSize = 4
+
+	_, err := requestor.GetAllOVEntries(8)
+	if err == nil {
+		t.Fatalf("Expected fetch to fail on entry 6")
+	}
+
+	if len(requestor.fetchedOVEntries) != 6 {
+		t.Errorf("Expected entries before the failure to be kept. Got %d", len(requestor.fetchedOVEntries))
+	}
+
+	ovEntries, err := requestor.GetAllOVEntries(8)
+	if err != nil {
+		t.Fatalf("Expected resumed fetch to succeed. %s", err.Error())
+	}
+
+	// 8 requests of the first attempt, and entries 6 and 7 again
+	if len(ovEntries) != 8 || *requestCount != 10 {
+		t.Errorf("Expected 8 OVEntries in 10 requests. Got %d in %d", len(ovEntries), *requestCount)
+	}
+}
+
+func TestGetOVNextEntry62_NetworkRetries(t *testing.T) {
+	fdoErrorBytes, _ := fdoshared.CborCust.Marshal(fdoshared.FdoError{
+		EMErrorCode: fdoshared.RESOURCE_NOT_FOUND,
+		EMPrevMsgID: fdoshared.TO2_62_GET_OVNEXTENTRY,
+		EMErrorStr:  "No such entry",
+	})
+
+	// Owner dropping connection of the first dropped requests
+	newFlakyOwner := func(dropped int, requestCount *int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*requestCount++
+			if *requestCount <= dropped {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Errorf("Failed to hijack connection. %s", err.Error())
+					return
+				}
+				conn.Close()
+				return
+			}
+
+			if r.Header.Get("Authorization") == "fdo-error" {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write(fdoErrorBytes)
+				return
+			}
+
+			entryBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OVNextEntry63{
+				OVEntry: fdoshared.CoseSignature{
+					Payload: []byte{0xa0},
+				},
+			})
+			w.Write(entryBytes)
+		}))
+	}
+
+	testCases := []struct {
+		name            string
+		dropped         int
+		networkRetries  int
+		authzHeader     string
+		expectedErr     bool
+		expectedRetries int
+	}{
+		{"recovers after dropped connections", 2, 2, "", false, 2},
+		{"gives up after retries", 2, 1, "", true, 1},
+		{"no retries", 1, 0, "", true, 0},
+		{"FDO error is not retried", 0, 2, "fdo-error", true, 0},
+	}
+
+	for _, testCase := range testCases {
+		requestCount := 0
+		owner := newFlakyOwner(testCase.dropped, &requestCount)
+
+		requestor := NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+		requestor.NetworkRetries = testCase.networkRetries
+		requestor.AuthzHeader = testCase.authzHeader
+
+		_, _, err := requestor.GetOVNextEntry62(0, testcom.NULL_TEST)
+		owner.Close()
+
+		if (err != nil) != testCase.expectedErr {
+			t.Errorf("%s: Unexpected error %v", testCase.name, err)
+		}
+
+		if requestor.NetworkRetryCount() != testCase.expectedRetries || requestCount != testCase.expectedRetries+1 {
+			t.Errorf("%s: Expected %d retries. Got %d retries and %d requests", testCase.name, testCase.expectedRetries, requestor.NetworkRetryCount(), requestCount)
+		}
+	}
+}
@@ -70,9 +70,11 @@ func (h *To2Requestor) ProveDevice64(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 
 	proveDeviceBytes, _ := fdoshared.CborCust.Marshal(proveDevice)
 
-	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_64_PROVE_DEVICE, proveDeviceBytes, &h.AuthzHeader)
+	proveDeviceBytes = h.fuzzPayload(fdoshared.TO2_64_PROVE_DEVICE, proveDeviceBytes)
+
+	rawResultBytes, authzHeader, httpStatusCode, err := h.sendCborPost(fdoshared.TO2_64_PROVE_DEVICE, proveDeviceBytes, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
-		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode)
+		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode, err)
 		return nil, &testState, nil
 	}
 
@@ -87,6 +89,11 @@ func (h *To2Requestor) ProveDevice64(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		}
 	}
 
+	err = checkMessageSize(fdoshared.TO2_65_SETUP_DEVICE, rawResultBytes)
+	if err != nil {
+		return nil, nil, errors.New("ProveDevice64: " + err.Error())
+	}
+
 	h.AuthzHeader = authzHeader
 
 	bodyBytes, err := fdoshared.RemoveEncryptionWrapping(rawResultBytes, h.SessionKey, h.CipherSuiteName)
@@ -27,6 +27,8 @@ func (h *To2Requestor) DeviceServiceInfoReady66(fdoTestID testcom.FDOTestID) (*f
 		deviceSrvInfoReadyBytes = fdoshared.Conf_RandomCborBufferFuzzing(deviceSrvInfoReadyBytes)
 	}
 
+	deviceSrvInfoReadyBytes = h.fuzzPayload(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, deviceSrvInfoReadyBytes)
+
 	deviceSrvInfoReadyBytesEnc, err := fdoshared.AddEncryptionWrapping(deviceSrvInfoReadyBytes, h.SessionKey, h.CipherSuiteName)
 	if err != nil {
 		return nil, nil, errors.New("DeviceServiceInfoReady66: Error encrypting... " + err.Error())
@@ -39,9 +41,9 @@ func (h *To2Requestor) DeviceServiceInfoReady66(fdoTestID testcom.FDOTestID) (*f
 		}
 	}
 
-	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, deviceSrvInfoReadyBytesEnc, &h.AuthzHeader)
+	rawResultBytes, authzHeader, httpStatusCode, err := h.sendCborPost(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, deviceSrvInfoReadyBytesEnc, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
-		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode)
+		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode, err)
 		return nil, &testState, nil
 	}
 
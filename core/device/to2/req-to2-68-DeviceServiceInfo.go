@@ -18,6 +18,8 @@ func (h *To2Requestor) DeviceServiceInfo68(deviceServiceInfo68 fdoshared.DeviceS
 		deviceServiceInfo68Bytes = fdoshared.Conf_RandomCborBufferFuzzing(deviceServiceInfo68Bytes)
 	}
 
+	deviceServiceInfo68Bytes = h.fuzzPayload(fdoshared.TO2_68_DEVICE_SERVICE_INFO, deviceServiceInfo68Bytes)
+
 	deviceServiceInfo68BytesEnc, err := fdoshared.AddEncryptionWrapping(deviceServiceInfo68Bytes, h.SessionKey, h.CipherSuiteName)
 	if err != nil {
 		return nil, nil, errors.New("DeviceServiceInfo68: Error encrypting... " + err.Error())
@@ -30,9 +32,9 @@ func (h *To2Requestor) DeviceServiceInfo68(deviceServiceInfo68 fdoshared.DeviceS
 		}
 	}
 
-	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_68_DEVICE_SERVICE_INFO, deviceServiceInfo68BytesEnc, &h.AuthzHeader)
+	rawResultBytes, authzHeader, httpStatusCode, err := h.sendCborPost(fdoshared.TO2_68_DEVICE_SERVICE_INFO, deviceServiceInfo68BytesEnc, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
-		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode)
+		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode, err)
 		return nil, &testState, nil
 	}
 
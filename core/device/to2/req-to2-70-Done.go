@@ -27,6 +27,8 @@ func (h *To2Requestor) Done70(fdoTestID testcom.FDOTestID) (*fdoshared.Done271,
 		done70Bytes = fdoshared.Conf_RandomCborBufferFuzzing(done70Bytes)
 	}
 
+	done70Bytes = h.fuzzPayload(fdoshared.TO2_70_DONE, done70Bytes)
+
 	done70BytesEnc, err := fdoshared.AddEncryptionWrapping(done70Bytes, h.SessionKey, h.CipherSuiteName)
 	if err != nil {
 		return nil, nil, errors.New("Done70: Error encrypting... " + err.Error())
@@ -39,9 +41,9 @@ func (h *To2Requestor) Done70(fdoTestID testcom.FDOTestID) (*fdoshared.Done271,
 		}
 	}
 
-	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_70_DONE, done70BytesEnc, &h.AuthzHeader)
+	rawResultBytes, authzHeader, httpStatusCode, err := h.sendCborPost(fdoshared.TO2_70_DONE, done70BytesEnc, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
-		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode)
+		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode, err)
 		return nil, &testState, nil
 	}
 
@@ -1,6 +1,11 @@
 package to2
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 )
@@ -8,6 +13,64 @@ import (
 var MaxDeviceMessageSize uint16 = 2048
 var MaxOwnerServiceInfoSize uint16 = 2048
 
+const MAX_OVENTRY_BATCH_SIZE int = 32
+
+// Set once on startup from OVENTRY_BATCH_SIZE. Used by new requestors
+var OVEntryBatchSize int = 1
+
+func ParseOVEntryBatchSize(batchSizeStr string) (int, error) {
+	if batchSizeStr == "" {
+		return 1, nil
+	}
+
+	batchSize, err := strconv.Atoi(batchSizeStr)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing OVEntry batch size. %s", err.Error())
+	}
+
+	if batchSize < 1 || batchSize > MAX_OVENTRY_BATCH_SIZE {
+		return 0, fmt.Errorf("OVEntry batch size must be 1 to %d. Got %d", MAX_OVENTRY_BATCH_SIZE, batchSize)
+	}
+
+	return batchSize, nil
+}
+
+const (
+	DEFAULT_NETWORK_RETRIES    int           = 2
+	MAX_NETWORK_RETRIES        int           = 10
+	NETWORK_RETRY_BASE_BACKOFF time.Duration = 250 * time.Millisecond
+	NETWORK_RETRY_MAX_BACKOFF  time.Duration = 5 * time.Second
+)
+
+// Set once on startup from NETWORK_RETRIES. Used by new requestors
+var NetworkRetries int = DEFAULT_NETWORK_RETRIES
+
+func ParseNetworkRetries(retriesStr string) (int, error) {
+	if retriesStr == "" {
+		return DEFAULT_NETWORK_RETRIES, nil
+	}
+
+	retries, err := strconv.Atoi(retriesStr)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing network retries. %s", err.Error())
+	}
+
+	if retries < 0 || retries > MAX_NETWORK_RETRIES {
+		return 0, fmt.Errorf("network retries must be 0 to %d. Got %d", MAX_NETWORK_RETRIES, retries)
+	}
+
+	return retries, nil
+}
+
+// checkMessageSize rejects owner messages larger than MaxDeviceMessageSize sent in HelloDevice
+func checkMessageSize(cmd fdoshared.FdoCmd, messageBytes []byte) error {
+	if len(messageBytes) > int(MaxDeviceMessageSize) {
+		return fmt.Errorf("%d: Message size %d exceeds MaxDeviceMessageSize %d", cmd, len(messageBytes), MaxDeviceMessageSize)
+	}
+
+	return nil
+}
+
 type To2Requestor struct {
 	SrvEntry        fdoshared.SRVEntry
 	Credential      fdoshared.WawDeviceCredential
@@ -33,6 +96,24 @@ type To2Requestor struct {
 	CredentialReuse bool
 
 	ReplacementCredential fdoshared.TO2SetupDevicePayload
+
+	// Wait for each owner response. Defaults to fdoshared.DEFAULT_MESSAGE_TIMEOUT
+	MessageTimeout time.Duration
+
+	// Number of GetOVNextEntry62 requests in flight. 0 or 1 fetches OVEntries one at a time
+	OVEntryBatchSize int
+	// Called after each fetched OVEntry with the number fetched so far, and NumOVEntries
+	OVEntryProgress func(fetched int, total int)
+	// OVEntries fetched in this TO2 session. Reset by HelloDevice60
+	fetchedOVEntries fdoshared.OVEntryArray
+
+	// Set for generative fuzzing of a single message
+	Fuzzer *To2Fuzzer
+
+	// Times HelloDevice60 and GetOVNextEntry62 are sent again after transient network error
+	NetworkRetries int
+	// Retries made by this requestor. Updated atomically, as OVEntries are fetched concurrently
+	networkRetryCount int64
 }
 
 func NewTo2Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential, kexSuitName fdoshared.KexSuiteName, cipherSuitName fdoshared.CipherSuiteName) To2Requestor {
@@ -41,10 +122,60 @@ func NewTo2Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDevice
 		Credential:      credential,
 		KexSuiteName:    kexSuitName,
 		CipherSuiteName: cipherSuitName,
+
+		OVEntryBatchSize: OVEntryBatchSize,
+		NetworkRetries:   NetworkRetries,
+	}
+}
+
+// Re-derives OVHeader HMAC with device secret, to detect tampered header
+func (h *To2Requestor) verifyOVHeaderHMac(proveOvdrPayload fdoshared.TO2ProveOVHdrPayload) error {
+	if proveOvdrPayload.HMac.Type != h.Credential.DCHmacAlg {
+		return fmt.Errorf("HMac type %d does not match device HMac type %d", proveOvdrPayload.HMac.Type, h.Credential.DCHmacAlg)
+	}
+
+	return fdoshared.VerifyHMac(proveOvdrPayload.OVHeader, proveOvdrPayload.HMac, h.Credential.DCHmacSecret)
+}
+
+// Checks that owner key of TO2.ProveOVHdr is the key of the last OVEntry
+func (h *To2Requestor) VerifyOwnerPubKey(ovEntries fdoshared.OVEntryArray) error {
+	if len(ovEntries) == 0 {
+		return errors.New("error verifying owner public key. No OVEntries received")
+	}
+
+	loePubKey, err := ovEntries[len(ovEntries)-1].GetOVEntryPubKey()
+	if err != nil {
+		return errors.New("error verifying owner public key. Failed to decode last OVEntry public key. " + err.Error())
+	}
+
+	err = h.ProveOVHdr61PubKey.Equal(loePubKey)
+	if err != nil {
+		return errors.New("TO2.ProveOVHdr owner public key does not match last OVEntry public key. " + err.Error())
 	}
+
+	return nil
 }
 
-func (h *To2Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDOTestID, httpStatusCode int) testcom.FDOTestState {
+// Selects KEX and cipher suites compatible with the device eASigInfo
+func NewTo2RequestorAutoSuite(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential) (*To2Requestor, error) {
+	kexSuiteName, cipherSuiteName, err := fdoshared.SelectKexCipherSuite(credential.DCSigInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	to2requestor := NewTo2Requestor(srvEntry, credential, kexSuiteName, cipherSuiteName)
+	return &to2requestor, nil
+}
+
+func (h *To2Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDOTestID, httpStatusCode int, sendErr error) testcom.FDOTestState {
+	var timeoutErr fdoshared.MessageTimeoutError
+	if errors.As(sendErr, &timeoutErr) {
+		return testcom.FDOTestState{
+			Passed: false,
+			Error:  timeoutErr.Error(),
+		}
+	}
+
 	switch fdoTestID {
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_60, fdoTestID):
 		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
@@ -55,6 +186,9 @@ func (h *To2Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDO
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_64, fdoTestID):
 		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
 
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_64_DEVICE_CERT, fdoTestID):
+		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.INVALID_MESSAGE_ERROR, httpStatusCode)
+
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_66, fdoTestID):
 		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
 
@@ -64,6 +198,10 @@ func (h *To2Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDO
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_70, fdoTestID):
 		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
 
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_RESALE, fdoTestID):
+		// Spec does not mandate the error code of rejected reonboarding
+		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+
 	}
 	return testcom.FDOTestState{
 		Passed: false,
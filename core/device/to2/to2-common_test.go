@@ -0,0 +1,200 @@
+package to2
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func TestVerifyOVHeaderHMac(t *testing.T) {
+	credential := fdoshared.WawDeviceCredential{
+		DCHmacAlg:    fdoshared.HASH_HMAC_SHA256,
+		DCHmacSecret: fdoshared.NewHmacKey(fdoshared.HASH_HMAC_SHA256),
+	}
+
+	requestor := NewTo2Requestor(fdoshared.SRVEntry{}, credential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+
+	ovHeader := []byte{0x86, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	ovHeaderHmac, err := fdoshared.GenerateFdoHmac(ovHeader, credential.DCHmacAlg, credential.DCHmacSecret)
+	if err != nil {
+		t.Fatalf("Failed to generate HMAC. %s", err.Error())
+	}
+
+	payload := fdoshared.TO2ProveOVHdrPayload{
+		OVHeader: ovHeader,
+		HMac:     ovHeaderHmac,
+	}
+
+	err = requestor.verifyOVHeaderHMac(payload)
+	if err != nil {
+		t.Errorf("Expected valid OVHeader HMAC to pass. %s", err.Error())
+	}
+
+	// Owner modified OVHeader
+	tamperedHeader := append([]byte{}, ovHeader...)
+	tamperedHeader[len(tamperedHeader)-1] ^= 0xff
+	payload.OVHeader = tamperedHeader
+
+	err = requestor.verifyOVHeaderHMac(payload)
+	if err == nil {
+		t.Errorf("Expected modified OVHeader to fail HMAC verification")
+	}
+
+	// Owner downgraded HMAC type
+	payload.OVHeader = ovHeader
+	payload.HMac.Type = fdoshared.HASH_HMAC_SHA384
+
+	err = requestor.verifyOVHeaderHMac(payload)
+	if err == nil {
+		t.Errorf("Expected mismatching HMAC type to fail")
+	}
+}
+
+func TestNewTo2RequestorAutoSuite(t *testing.T) {
+	testCases := []struct {
+		sgType   fdoshared.DeviceSgType
+		kexSuite fdoshared.KexSuiteName
+		cipher   fdoshared.CipherSuiteName
+	}{
+		{fdoshared.StSECP256R1, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM},
+		{fdoshared.StSECP384R1, fdoshared.KEX_ECDH384, fdoshared.CIPHER_A256GCM},
+	}
+
+	for _, testCase := range testCases {
+		credential := fdoshared.WawDeviceCredential{
+			DCSigInfo: fdoshared.SigInfo{SgType: testCase.sgType},
+		}
+
+		requestor, err := NewTo2RequestorAutoSuite(fdoshared.SRVEntry{}, credential)
+		if err != nil {
+			t.Fatalf("Unexpected error for sgType %d. %s", testCase.sgType, err.Error())
+		}
+
+		if requestor.KexSuiteName != testCase.kexSuite {
+			t.Errorf("For sgType %d expected KEX %s. Got %s", testCase.sgType, testCase.kexSuite, requestor.KexSuiteName)
+		}
+
+		if requestor.CipherSuiteName != testCase.cipher {
+			t.Errorf("For sgType %d expected cipher %d. Got %d", testCase.sgType, testCase.cipher, requestor.CipherSuiteName)
+		}
+	}
+
+	_, err := NewTo2RequestorAutoSuite(fdoshared.SRVEntry{}, fdoshared.WawDeviceCredential{
+		DCSigInfo: fdoshared.SigInfo{SgType: fdoshared.StEPID10},
+	})
+	if err == nil {
+		t.Errorf("Expected unsupported sgType to fail")
+	}
+}
+
+func TestVerifyOwnerPubKey(t *testing.T) {
+	newOVEntry := func(pubKey fdoshared.FdoPublicKey, privKey interface{}) fdoshared.CoseSignature {
+		payloadBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OVEntryPayload{
+			OVEPubKey: pubKey,
+		})
+
+		ovEntry, err := fdoshared.GenerateCoseSignature(payloadBytes, fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{}, privKey, fdoshared.StSECP256R1)
+		if err != nil {
+			t.Fatalf("Failed to generate OVEntry. %s", err.Error())
+		}
+
+		return *ovEntry
+	}
+
+	ownerPrivKey, ownerPubKey, _ := fdoshared.GeneratePKIXECKeypair(fdoshared.StSECP256R1)
+	_, otherPubKey, _ := fdoshared.GeneratePKIXECKeypair(fdoshared.StSECP256R1)
+
+	requestor := NewTo2Requestor(fdoshared.SRVEntry{}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	requestor.ProveOVHdr61PubKey = *ownerPubKey
+
+	ovEntries := fdoshared.OVEntryArray{
+		newOVEntry(*otherPubKey, ownerPrivKey),
+		newOVEntry(*ownerPubKey, ownerPrivKey),
+	}
+
+	err := requestor.VerifyOwnerPubKey(ovEntries)
+	if err != nil {
+		t.Errorf("Expected owner key matching last OVEntry to pass. %s", err.Error())
+	}
+
+	// Owner signed TO2.ProveOVHdr with key that is not in the last OVEntry
+	requestor.ProveOVHdr61PubKey = *otherPubKey
+	err = requestor.VerifyOwnerPubKey(ovEntries)
+	if err == nil {
+		t.Errorf("Expected owner key mismatch to fail")
+	}
+
+	err = requestor.VerifyOwnerPubKey(fdoshared.OVEntryArray{})
+	if err == nil {
+		t.Errorf("Expected empty OVEntries to fail")
+	}
+}
+
+func TestCheckMessageSize(t *testing.T) {
+	ownerPrivKey, ownerPubKey, err := fdoshared.GenerateVoucherKeypair(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate owner key. %s", err.Error())
+	}
+
+	proveOVHdr, err := fdoshared.GenerateCoseSignature([]byte("ProveOVHdr"), fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{CUPHOwnerPubKey: ownerPubKey}, ownerPrivKey, fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to sign ProveOVHdr. %s", err.Error())
+	}
+
+	proveOVHdrBytes, _ := fdoshared.CborCust.Marshal(proveOVHdr)
+	err = checkMessageSize(fdoshared.TO2_61_PROVE_OVHDR, proveOVHdrBytes)
+	if err != nil {
+		t.Errorf("Expected regular ProveOVHdr to fit. %s", err.Error())
+	}
+
+	// Owner oversized message test pads unprotected header, so message is still valid, only too large
+	padding := fdoshared.Conf_OversizedPadding(MaxDeviceMessageSize)
+	proveOVHdr.Unprotected.ConfPadding = &padding
+	proveOVHdrBytes, _ = fdoshared.CborCust.Marshal(proveOVHdr)
+
+	var decodedProveOVHdr fdoshared.CoseSignature
+	err = fdoshared.CborCust.Unmarshal(proveOVHdrBytes, &decodedProveOVHdr)
+	if err != nil {
+		t.Fatalf("Failed to decode padded ProveOVHdr. %s", err.Error())
+	}
+
+	err = fdoshared.VerifyCoseSignature(decodedProveOVHdr, *ownerPubKey)
+	if err != nil {
+		t.Errorf("Expected padded ProveOVHdr signature to verify. %s", err.Error())
+	}
+
+	err = checkMessageSize(fdoshared.TO2_61_PROVE_OVHDR, proveOVHdrBytes)
+	if err == nil {
+		t.Errorf("Expected padded ProveOVHdr of %d bytes to exceed MaxDeviceMessageSize", len(proveOVHdrBytes))
+	}
+}
+
+func TestTo2Requestor_MessageTimeout(t *testing.T) {
+	// Owner does not answer until the test ends
+	release := make(chan struct{})
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer owner.Close()
+	defer close(release)
+
+	requestor := NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	requestor.MessageTimeout = 50 * time.Millisecond
+
+	_, _, err := requestor.GetOVNextEntry62(0, testcom.NULL_TEST)
+
+	var timeoutErr fdoshared.MessageTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Cmd != fdoshared.TO2_63_OV_NEXTENTRY {
+		t.Fatalf("Expected timeout waiting for message 63. Got %v", err)
+	}
+
+	_, testState, _ := requestor.GetOVNextEntry62(0, testcom.FIDO_DOT_62_BAD_ENCODING)
+	if testState == nil || testState.Passed || testState.Error != "timed out waiting for message 63" {
+		t.Errorf("Expected negative test to fail with timeout. Got %+v", testState)
+	}
+}
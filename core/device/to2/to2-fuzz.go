@@ -0,0 +1,110 @@
+package to2
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// To2Fuzzer mutates plaintext of one TO2 message, before encryption, and records owner response to it
+type To2Fuzzer struct {
+	Cmd      fdoshared.FdoCmd
+	Mutation fdoshared.Conf_FuzzMutation
+
+	// Mutated message and mutation description. Empty when mutation was not applicable to the message
+	Input       []byte
+	Description string
+
+	Sent           bool
+	ResponseStatus int
+	ResponseBytes  []byte
+	ResponseErr    error
+}
+
+// fuzzPayload mutates only the first message of the command, e.g. the first GetOVNextEntry
+func (h *To2Requestor) fuzzPayload(cmd fdoshared.FdoCmd, payload []byte) []byte {
+	if h.Fuzzer == nil || h.Fuzzer.Cmd != cmd || h.Fuzzer.Sent {
+		return payload
+	}
+
+	mutatedPayload, description, err := h.Fuzzer.Mutation.Apply(payload)
+	if err != nil {
+		h.Fuzzer.Description = "mutation not applicable. " + err.Error()
+		return payload
+	}
+
+	h.Fuzzer.Input = mutatedPayload
+	h.Fuzzer.Description = description
+
+	return mutatedPayload
+}
+
+// isRetryableCmd is true for messages that do not change owner state, so they can be sent again when the response was lost.
+// HelloDevice60 is not, as owner may have started the session with its nonce before the connection failed
+func isRetryableCmd(cmd fdoshared.FdoCmd) bool {
+	return cmd == fdoshared.TO2_62_GET_OVNEXTENTRY
+}
+
+// networkRetryBackoff returns exponential backoff with jitter before retry number retry, starting at 0
+func networkRetryBackoff(retry int) time.Duration {
+	backoff := NETWORK_RETRY_BASE_BACKOFF << uint(retry)
+	if backoff > NETWORK_RETRY_MAX_BACKOFF || backoff <= 0 {
+		backoff = NETWORK_RETRY_MAX_BACKOFF
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)))
+}
+
+// NetworkRetryCount returns number of messages sent again after transient network errors
+func (h *To2Requestor) NetworkRetryCount() int {
+	return int(atomic.LoadInt64(&h.networkRetryCount))
+}
+
+// sendCborPostWithTimeout sends the message, waiting at most MessageTimeout for each response. GetOVNextEntry62 is sent again
+// on transient network errors, up to NetworkRetries times. FDO errors and timeouts are returned as is
+func (h *To2Requestor) sendCborPostWithTimeout(cmd fdoshared.FdoCmd, payload []byte, authzHeader *string) ([]byte, string, int, error) {
+	resultBytes, respAuthzHeader, httpStatusCode, err := h.sendCborPostOnce(cmd, payload, authzHeader)
+	if !isRetryableCmd(cmd) {
+		return resultBytes, respAuthzHeader, httpStatusCode, err
+	}
+
+	for retry := 0; retry < h.NetworkRetries && fdoshared.IsTransientNetworkError(err); retry++ {
+		backoff := networkRetryBackoff(retry)
+		log.Printf("%d: transient network error, retrying in %s. %s", cmd, backoff, err.Error())
+		time.Sleep(backoff)
+
+		atomic.AddInt64(&h.networkRetryCount, 1)
+		resultBytes, respAuthzHeader, httpStatusCode, err = h.sendCborPostOnce(cmd, payload, authzHeader)
+	}
+
+	return resultBytes, respAuthzHeader, httpStatusCode, err
+}
+
+func (h *To2Requestor) sendCborPostOnce(cmd fdoshared.FdoCmd, payload []byte, authzHeader *string) ([]byte, string, int, error) {
+	messageTimeout := h.MessageTimeout
+	if messageTimeout == 0 {
+		messageTimeout = fdoshared.DEFAULT_MESSAGE_TIMEOUT
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), messageTimeout)
+	defer cancel()
+
+	return fdoshared.SendCborPostContext(ctx, h.SrvEntry, cmd, payload, authzHeader)
+}
+
+func (h *To2Requestor) sendCborPost(cmd fdoshared.FdoCmd, payload []byte, authzHeader *string) ([]byte, string, int, error) {
+	resultBytes, respAuthzHeader, httpStatusCode, err := h.sendCborPostWithTimeout(cmd, payload, authzHeader)
+
+	if h.Fuzzer != nil && h.Fuzzer.Cmd == cmd && !h.Fuzzer.Sent {
+		h.Fuzzer.Sent = true
+		h.Fuzzer.ResponseStatus = httpStatusCode
+		h.Fuzzer.ResponseBytes = resultBytes
+		h.Fuzzer.ResponseErr = err
+	}
+
+	return resultBytes, respAuthzHeader, httpStatusCode, err
+}
@@ -0,0 +1,76 @@
+package to2
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func TestTo2Fuzzer(t *testing.T) {
+	var receivedBodies [][]byte
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bodyBytes, _ := io.ReadAll(r.Body)
+		receivedBodies = append(receivedBodies, bodyBytes)
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer owner.Close()
+
+	requestor := NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	requestor.Fuzzer = &To2Fuzzer{
+		Cmd:      fdoshared.TO2_62_GET_OVNEXTENTRY,
+		Mutation: fdoshared.Conf_FuzzMutation{Strategy: fdoshared.Conf_Fuzz_BitFlip, Seed: 1},
+	}
+
+	validBytes, _ := fdoshared.CborCust.Marshal(fdoshared.GetOVNextEntry62{GetOVNextEntry: 0})
+
+	requestor.GetOVNextEntry62(0, testcom.NULL_TEST)
+	requestor.GetOVNextEntry62(0, testcom.NULL_TEST)
+
+	if len(receivedBodies) != 2 {
+		t.Fatalf("Expected 2 requests. Got %d", len(receivedBodies))
+	}
+
+	if !requestor.Fuzzer.Sent || requestor.Fuzzer.ResponseStatus != http.StatusInternalServerError {
+		t.Errorf("Expected owner response to be recorded. Got %+v", requestor.Fuzzer)
+	}
+
+	if bytes.Equal(receivedBodies[0], validBytes) || !bytes.Equal(receivedBodies[0], requestor.Fuzzer.Input) {
+		t.Errorf("Expected the first message to be mutated")
+	}
+
+	if !bytes.Equal(receivedBodies[1], validBytes) {
+		t.Errorf("Expected only the first message to be mutated")
+	}
+}
+
+func TestHelloDevice60_NotRetried(t *testing.T) {
+	requestCount := 0
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Failed to hijack connection. %s", err.Error())
+			return
+		}
+		conn.Close()
+	}))
+	defer owner.Close()
+
+	requestor := NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	requestor.NetworkRetries = 2
+
+	_, _, err := requestor.HelloDevice60(testcom.NULL_TEST)
+	if err == nil {
+		t.Fatalf("Expected dropped connection to fail HelloDevice60")
+	}
+
+	if requestCount != 1 || requestor.NetworkRetryCount() != 0 {
+		t.Errorf("Expected HelloDevice60 to be sent once. Got %d requests and %d retries", requestCount, requestor.NetworkRetryCount())
+	}
+}
@@ -0,0 +1,73 @@
+package dbs
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// IdentityDB stores imported owner and RV identities. Without one, the server uses self-generated keys
+type IdentityDB struct {
+	db     *badger.DB
+	prefix []byte
+}
+
+func NewIdentityDB(db *badger.DB) *IdentityDB {
+	return &IdentityDB{
+		db:     db,
+		prefix: []byte("identity-"),
+	}
+}
+
+func (h *IdentityDB) getEntryID(role fdoshared.ServerIdentityRole) []byte {
+	return append(append([]byte{}, h.prefix...), []byte(role)...)
+}
+
+func (h *IdentityDB) Save(role fdoshared.ServerIdentityRole, identity fdoshared.ServerIdentity) error {
+	identityBytes, err := fdoshared.CborCust.Marshal(identity)
+	if err != nil {
+		return errors.New("Failed to marshal identity. The error is: " + err.Error())
+	}
+
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		err := dbtxn.SetEntry(badger.NewEntry(h.getEntryID(role), identityBytes))
+		if err != nil {
+			return errors.New("Failed creating identity db entry instance. The error is: " + err.Error())
+		}
+
+		return nil
+	})
+}
+
+// Get returns nil when no identity is imported for the role
+func (h *IdentityDB) Get(role fdoshared.ServerIdentityRole) (*fdoshared.ServerIdentity, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	item, err := dbtxn.Get(h.getEntryID(role))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating identity entry. The error is: " + err.Error())
+	}
+
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading identity entry value. The error is: " + err.Error())
+	}
+
+	var identity fdoshared.ServerIdentity
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &identity)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding identity entry value. The error is: " + err.Error())
+	}
+
+	return &identity, nil
+}
+
+func (h *IdentityDB) Delete(role fdoshared.ServerIdentityRole) error {
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		return dbtxn.Delete(h.getEntryID(role))
+	})
+}
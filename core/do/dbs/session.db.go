@@ -1,11 +1,13 @@
 package dbs
 
 import (
+	"bytes"
 	"errors"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	"github.com/google/uuid"
 )
 
@@ -52,8 +54,30 @@ type SessionEntry struct {
 	OwnerSIMsFinishedSending bool
 	OwnerSIMs                []fdoshared.ServiceInfoKV
 
+	// Credential replacement
+	ReplacementCredential    *fdoshared.TO2SetupDevicePayload
+	ReplacementPrivateKeyDER []byte
+	ReplacementHMac          *fdoshared.HashOrHmac
+
 	// Conformance testing
 	RequestedOVEntries []uint8
+	// From HelloDevice60. Oversized message tests exceed it
+	MaxDeviceMessageSize uint16
+
+	// Set when DeviceServiceInfo68 exceeded MaxDeviceServiceInfoSz configured for the test run
+	ExceededMaxDeviceServiceInfoSz bool
+	// Pre-activated modules device has not yet responded to with modname:active
+	PendingModuleActivations []string
+	// OwnerServiceInfo edge test selected while device was still sending its ServiceInfo. Served with the first owner ServiceInfo
+	PendingServiceInfoEdgeTest testcom.FDOTestID
+
+	// Resends of the current owner ServiceInfo entry after device reported modname:error
+	OwnerSIMRetries uint8
+
+	// Negotiated in DeviceServiceInfoReady66. Owner ServiceInfo is sent in OwnerServiceInfo69 chunks of at most this size
+	OwnerServiceInfoMTU uint16
+	// Number of owner ServiceInfo entries in the last OwnerServiceInfo69
+	OwnerSIMsLastChunkLen uint16
 }
 
 // Conformance
@@ -74,54 +98,151 @@ func (h *SessionEntry) Conf_RequestedOVEntriesContain(entryNum uint8) bool {
 	return false
 }
 
-func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
+var ErrOnboardingInProgress = errors.New("TO2 of the device is already in progress")
+
+const DEFAULT_SESSION_TTL = time.Minute * 10
+
+// Set once on startup from TO2_SESSION_TTL. Independent from web login session TTL
+var SessionTTL time.Duration = DEFAULT_SESSION_TTL
+
+// Set once on startup from TO2_SESSION_SLIDING_TTL. With sliding TTL every session update expires SessionTTL later, so long TO2 runs do not expire mid-flow.
+// Otherwise session expires SessionTTL after HelloDevice
+var SessionSlidingTTL bool = true
+
+// Clock of session expiry. Replaced by tests, to simulate long TO2 runs without waiting
+var sessionClock = time.Now
+
+// newSessionDBEntry returns badger entry expiring SessionTTL from now
+func newSessionDBEntry(key []byte, value []byte) *badger.Entry {
+	entry := badger.NewEntry(key, value)
+	entry.ExpiresAt = uint64(sessionClock().Add(SessionTTL).Unix())
+	return entry
+}
+
+func getActiveGuidId(guid fdoshared.FdoGuid) []byte {
+	return append([]byte("activeguid-"), guid[:]...)
+}
+
+// NewTo2SessionEntry creates session and marks it active for the device GUID. If another session of the GUID is still in progress,
+// it is either deleted or ErrOnboardingInProgress is returned, depending on the policy
+func (h *SessionDB) NewTo2SessionEntry(sessionInst SessionEntry, policy fdoshared.ConcurrentOnboardingPolicy) ([]byte, error) {
 	sessionBytes, err := fdoshared.CborCust.Marshal(sessionInst)
 	if err != nil {
 		return []byte{}, errors.New("Failed to marshal session. The error is: " + err.Error())
 	}
 
 	randomEntryId, _ := uuid.NewRandom()
-	sessionEntryId := []byte("session-" + randomEntryId.String())
+	sessionId := []byte(randomEntryId.String())
+	activeGuidId := getActiveGuidId(sessionInst.Guid)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+	err = fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		activeItem, err := dbtxn.Get(activeGuidId)
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return errors.New("Failed locating active session entry. The error is: " + err.Error())
+		}
 
-	entry := badger.NewEntry(sessionEntryId, sessionBytes).WithTTL(time.Minute * 10) // Session entry will only exist for 10 minutes
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return []byte{}, errors.New("Failed creating session db entry instance. The error is: " + err.Error())
-	}
+		if err == nil {
+			activeSessionId, err := activeItem.ValueCopy(nil)
+			if err != nil {
+				return errors.New("Failed reading active session entry. The error is: " + err.Error())
+			}
+
+			activeSessionEntryId := append([]byte("session-"), activeSessionId...)
+			activeSessionItem, err := dbtxn.Get(activeSessionEntryId)
+			if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+				return errors.New("Failed locating entry. The error is: " + err.Error())
+			}
+
+			if err == nil {
+				var activeSession SessionEntry
+				err = activeSessionItem.Value(func(val []byte) error {
+					return fdoshared.CborCust.Unmarshal(val, &activeSession)
+				})
+				if err != nil {
+					return errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
+				}
+
+				if activeSession.PrevCMD != fdoshared.TO2_71_DONE2 {
+					if policy == fdoshared.ONBOARDING_POLICY_REJECT {
+						return ErrOnboardingInProgress
+					}
+
+					err = dbtxn.Delete(activeSessionEntryId)
+					if err != nil {
+						return errors.New("Failed deleting superseded session. The error is: " + err.Error())
+					}
+				}
+			}
+		}
+
+		err = dbtxn.SetEntry(newSessionDBEntry(append([]byte("session-"), sessionId...), sessionBytes))
+		if err != nil {
+			return errors.New("Failed creating session db entry instance. The error is: " + err.Error())
+		}
 
-	dbtxn.Commit()
+		return dbtxn.SetEntry(newSessionDBEntry(activeGuidId, sessionId))
+	})
 	if err != nil {
-		return []byte{}, errors.New("Failed saving session entry. The error is: " + err.Error())
+		return []byte{}, err
 	}
 
-	return []byte(randomEntryId.String()), nil
+	return sessionId, nil
 }
 
+// UpdateSessionEntry saves session. With SessionSlidingTTL, TTL of the session and of its active GUID mapping is re-applied, otherwise the expiry is kept
 func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry) error {
 	sessionEntryId := append([]byte("session-"), entryId...)
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+	activeGuidId := getActiveGuidId(sessionInst.Guid)
 
 	sessionInstBytes, err := fdoshared.CborCust.Marshal(sessionInst)
 	if err != nil {
 		return errors.New("Failed to marshal session. The error is: " + err.Error())
 	}
 
-	err = dbtxn.Set(sessionEntryId, sessionInstBytes)
-	if err != nil {
-		return errors.New("Failed to create saving inst. The error is: " + err.Error())
-	}
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		item, err := dbtxn.Get(sessionEntryId)
+		if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+			return errors.New("Failed to save session. The session has expired")
+		} else if err != nil {
+			return errors.New("Failed locating entry. The error is: " + err.Error())
+		}
 
-	err = dbtxn.Commit()
-	if err != nil {
-		return errors.New("Failed to save session. The error is: " + err.Error())
-	}
+		sessionEntry := newSessionDBEntry(sessionEntryId, sessionInstBytes)
+		if !SessionSlidingTTL && item.ExpiresAt() != 0 {
+			if item.ExpiresAt() <= uint64(sessionClock().Unix()) {
+				return errors.New("Failed to save session. The session has expired")
+			}
+			sessionEntry.ExpiresAt = item.ExpiresAt()
+		}
+
+		err = dbtxn.SetEntry(sessionEntry)
+		if err != nil {
+			return errors.New("Failed to create saving inst. The error is: " + err.Error())
+		}
+
+		if !SessionSlidingTTL {
+			return nil
+		}
+
+		activeItem, err := dbtxn.Get(activeGuidId)
+		if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		} else if err != nil {
+			return errors.New("Failed locating active session entry. The error is: " + err.Error())
+		}
+
+		activeSessionId, err := activeItem.ValueCopy(nil)
+		if err != nil {
+			return errors.New("Failed reading active session entry. The error is: " + err.Error())
+		}
 
-	return nil
+		// Superseded session must not keep the newer session of the GUID active
+		if !bytes.Equal(activeSessionId, entryId) {
+			return nil
+		}
+
+		return dbtxn.SetEntry(newSessionDBEntry(activeGuidId, entryId))
+	})
 }
 
 func (h *SessionDB) GetSessionEntry(entryId []byte) (*SessionEntry, error) {
@@ -152,3 +273,72 @@ func (h *SessionDB) GetSessionEntry(entryId []byte) (*SessionEntry, error) {
 
 	return &sessionEntryInst, nil
 }
+
+// DeleteSessionEntry terminates the session. Active session of the GUID is released, if it is this session
+func (h *SessionDB) DeleteSessionEntry(entryId []byte, guid fdoshared.FdoGuid) error {
+	activeGuidId := getActiveGuidId(guid)
+
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		err := dbtxn.Delete(append([]byte("session-"), entryId...))
+		if err != nil {
+			return errors.New("Failed deleting session. The error is: " + err.Error())
+		}
+
+		activeItem, err := dbtxn.Get(activeGuidId)
+		if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		} else if err != nil {
+			return errors.New("Failed locating active session entry. The error is: " + err.Error())
+		}
+
+		activeSessionId, err := activeItem.ValueCopy(nil)
+		if err != nil {
+			return errors.New("Failed reading active session entry. The error is: " + err.Error())
+		}
+
+		if !bytes.Equal(activeSessionId, entryId) {
+			return nil
+		}
+
+		err = dbtxn.Delete(activeGuidId)
+		if err != nil {
+			return errors.New("Failed deleting active session entry. The error is: " + err.Error())
+		}
+
+		return nil
+	})
+}
+
+// GetActiveSessionId returns id of the latest TO2 session of the GUID, or nil when there is none
+func (h *SessionDB) GetActiveSessionId(guid fdoshared.FdoGuid) ([]byte, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	item, err := dbtxn.Get(getActiveGuidId(guid))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating active session entry. The error is: " + err.Error())
+	}
+
+	sessionId, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading active session entry. The error is: " + err.Error())
+	}
+
+	return sessionId, nil
+}
+
+// DeleteGuidSessions terminates the active TO2 session of the GUID, if there is one
+func (h *SessionDB) DeleteGuidSessions(guid fdoshared.FdoGuid) error {
+	sessionId, err := h.GetActiveSessionId(guid)
+	if err != nil {
+		return err
+	}
+
+	if sessionId == nil {
+		return nil
+	}
+
+	return h.DeleteSessionEntry(sessionId, guid)
+}
@@ -0,0 +1,227 @@
+package dbs
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func startConcurrentTo2Sessions(t *testing.T, sessionDB *SessionDB, guid fdoshared.FdoGuid, policy fdoshared.ConcurrentOnboardingPolicy, count int) ([][]byte, int) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	sessionIds := [][]byte{}
+	busyCount := 0
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sessionId, err := sessionDB.NewTo2SessionEntry(SessionEntry{
+				Protocol: fdoshared.To2,
+				PrevCMD:  fdoshared.TO2_61_PROVE_OVHDR,
+				Guid:     guid,
+			}, policy)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if errors.Is(err, ErrOnboardingInProgress) {
+				busyCount++
+			} else if err != nil {
+				t.Errorf("Unexpected error starting session. %s", err.Error())
+			} else {
+				sessionIds = append(sessionIds, sessionId)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return sessionIds, busyCount
+}
+
+func TestSessionDB_ConcurrentOnboarding(t *testing.T) {
+	defer func(retries int) { fdoshared.DbConflictRetries = retries }(fdoshared.DbConflictRetries)
+	fdoshared.DbConflictRetries = 100
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	sessionDB := NewSessionDB(db)
+
+	// Reject keeps the first session only
+	rejectGuid := fdoshared.NewFdoGuid()
+	sessionIds, busyCount := startConcurrentTo2Sessions(t, sessionDB, rejectGuid, fdoshared.ONBOARDING_POLICY_REJECT, 16)
+	if len(sessionIds) != 1 || busyCount != 15 {
+		t.Fatalf("Expected one session and 15 busy errors. Got %d sessions, %d busy", len(sessionIds), busyCount)
+	}
+
+	// Completed session does not block the next one
+	session, _ := sessionDB.GetSessionEntry(sessionIds[0])
+	session.PrevCMD = fdoshared.TO2_71_DONE2
+	err = sessionDB.UpdateSessionEntry(sessionIds[0], *session)
+	if err != nil {
+		t.Fatalf("Failed to update session. %s", err.Error())
+	}
+
+	_, err = sessionDB.NewTo2SessionEntry(SessionEntry{Guid: rejectGuid}, fdoshared.ONBOARDING_POLICY_REJECT)
+	if err != nil {
+		t.Errorf("Expected new session after previous completed. %s", err.Error())
+	}
+
+	// Supersede leaves only the latest session alive
+	supersedeGuid := fdoshared.NewFdoGuid()
+	sessionIds, busyCount = startConcurrentTo2Sessions(t, sessionDB, supersedeGuid, fdoshared.ONBOARDING_POLICY_SUPERSEDE, 16)
+	if len(sessionIds) != 16 || busyCount != 0 {
+		t.Fatalf("Expected all sessions to start. Got %d sessions, %d busy", len(sessionIds), busyCount)
+	}
+
+	aliveCount := 0
+	for _, sessionId := range sessionIds {
+		session, err := sessionDB.GetSessionEntry(sessionId)
+		if err != nil {
+			t.Fatalf("Failed to get session. %s", err.Error())
+		}
+
+		if session != nil {
+			aliveCount++
+		}
+	}
+
+	if aliveCount != 1 {
+		t.Errorf("Expected superseded sessions to be removed. %d sessions alive", aliveCount)
+	}
+
+	activeSessionId, err := sessionDB.GetActiveSessionId(supersedeGuid)
+	if err != nil {
+		t.Fatalf("Failed to get active session id. %s", err.Error())
+	}
+
+	session, _ = sessionDB.GetSessionEntry(activeSessionId)
+	if session == nil {
+		t.Errorf("Expected active session id to point to the alive session")
+	}
+
+	activeSessionId, _ = sessionDB.GetActiveSessionId(fdoshared.NewFdoGuid())
+	if activeSessionId != nil {
+		t.Errorf("Expected no active session for unknown GUID")
+	}
+}
+
+func getTestExpiresAt(t *testing.T, db *badger.DB, key []byte) uint64 {
+	var expiresAt uint64
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+
+		expiresAt = item.ExpiresAt()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to get %s. %s", key, err.Error())
+	}
+
+	return expiresAt
+}
+
+// Simulates TO2 running longer than the session TTL, with a message every half TTL. Session clock is moved instead of waiting
+func TestSessionDB_SlidingTTL(t *testing.T) {
+	defer func(ttl time.Duration, sliding bool) {
+		SessionTTL = ttl
+		SessionSlidingTTL = sliding
+		sessionClock = time.Now
+	}(SessionTTL, SessionSlidingTTL)
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	sessionDB := NewSessionDB(db)
+	SessionTTL = 10 * time.Minute
+
+	now := time.Now()
+	sessionClock = func() time.Time { return now }
+
+	runTo2 := func(sliding bool) ([]byte, fdoshared.FdoGuid, error) {
+		SessionSlidingTTL = sliding
+		now = time.Now()
+
+		guid := fdoshared.NewFdoGuid()
+		sessionId, err := sessionDB.NewTo2SessionEntry(SessionEntry{Protocol: fdoshared.To2, Guid: guid}, fdoshared.ONBOARDING_POLICY_SUPERSEDE)
+		if err != nil {
+			t.Fatalf("Failed to start session. %s", err.Error())
+		}
+
+		startExpiresAt := uint64(now.Add(SessionTTL).Unix())
+		for i := 0; i < 8; i++ {
+			now = now.Add(SessionTTL / 2)
+
+			session, err := sessionDB.GetSessionEntry(sessionId)
+			if err != nil {
+				t.Fatalf("Failed to get session. %s", err.Error())
+			}
+
+			session.ServiceInfoMsgNo++
+			err = sessionDB.UpdateSessionEntry(sessionId, *session)
+			if err != nil {
+				return sessionId, guid, err
+			}
+
+			expectedExpiresAt := uint64(now.Add(SessionTTL).Unix())
+			if !sliding {
+				expectedExpiresAt = startExpiresAt
+			}
+
+			expiresAt := getTestExpiresAt(t, db, append([]byte("session-"), sessionId...))
+			if expiresAt != expectedExpiresAt {
+				t.Errorf("Expected session to expire at %d. Got %d", expectedExpiresAt, expiresAt)
+			}
+		}
+
+		return sessionId, guid, nil
+	}
+
+	sessionId, guid, err := runTo2(true)
+	if err != nil {
+		t.Fatalf("Expected session to survive TO2 longer than TTL with sliding TTL. %s", err.Error())
+	}
+
+	activeSessionId, _ := sessionDB.GetActiveSessionId(guid)
+	if string(activeSessionId) != string(sessionId) {
+		t.Errorf("Expected active session of the GUID to survive with sliding TTL")
+	}
+
+	if getTestExpiresAt(t, db, getActiveGuidId(guid)) != uint64(now.Add(SessionTTL).Unix()) {
+		t.Errorf("Expected active session of the GUID to expire with the session")
+	}
+
+	_, _, err = runTo2(false)
+	if err == nil {
+		t.Errorf("Expected session to expire TTL after start without sliding TTL")
+	}
+
+	// Expiry in the past is enforced by badger
+	now = time.Now().Add(-2 * SessionTTL)
+	sessionId, err = sessionDB.NewTo2SessionEntry(SessionEntry{Protocol: fdoshared.To2, Guid: fdoshared.NewFdoGuid()}, fdoshared.ONBOARDING_POLICY_SUPERSEDE)
+	if err != nil {
+		t.Fatalf("Failed to start session. %s", err.Error())
+	}
+
+	session, _ := sessionDB.GetSessionEntry(sessionId)
+	if session != nil {
+		t.Errorf("Expected expired session to be gone")
+	}
+}
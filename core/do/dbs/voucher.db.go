@@ -1,30 +1,59 @@
 package dbs
 
 import (
+	"crypto/x509"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 )
 
+const VOUCHER_LIST_MAX_LIMIT int = 500
+
+var ErrVoucherNotFound = errors.New("voucher does not exist")
+
 type VoucherDB struct {
-	db     *badger.DB
-	prefix []byte
+	db              *badger.DB
+	prefix          []byte
+	createdPrefix   []byte
+	onboardedPrefix []byte
 }
 
 func NewVoucherDB(db *badger.DB) *VoucherDB {
 	return &VoucherDB{
-		db:     db,
-		prefix: []byte("voucher-"),
+		db:              db,
+		prefix:          []byte("voucher-"),
+		createdPrefix:   []byte("vouchercreated-"),
+		onboardedPrefix: []byte("voucheronboarded-"),
 	}
 }
 
+// VoucherInfo is voucher metadata returned by ListPage
+type VoucherInfo struct {
+	Guid         fdoshared.FdoGuid
+	NumOVEntries int
+	// Unix seconds. 0 for vouchers saved before creation time was recorded
+	CreatedAt int64
+	// Empty when voucher has no device certificate chain
+	DeviceCertSubject string
+}
+
 func (h VoucherDB) getEntryID(guid fdoshared.FdoGuid) []byte {
 	return append(h.prefix, guid[:]...)
 }
 
+func (h VoucherDB) getCreatedID(guid fdoshared.FdoGuid) []byte {
+	return append(append([]byte{}, h.createdPrefix...), guid[:]...)
+}
+
+func (h VoucherDB) getOnboardedID(guid fdoshared.FdoGuid) []byte {
+	return append(append([]byte{}, h.onboardedPrefix...), guid[:]...)
+}
+
 func (h *VoucherDB) Save(voucherDBEntry fdoshared.VoucherDBEntry) error {
 	voucherDBBytes, err := fdoshared.CborCust.Marshal(voucherDBEntry)
 	if err != nil {
@@ -45,7 +74,21 @@ func (h *VoucherDB) Save(voucherDBEntry fdoshared.VoucherDBEntry) error {
 		return errors.New("Failed creating voucherDB entry instance. " + err.Error())
 	}
 
-	dbtxn.Commit()
+	// Creation time of voucher saved again is kept
+	_, err = dbtxn.Get(h.getCreatedID(ovHeader.OVGuid))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		createdBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(createdBytes, uint64(time.Now().Unix()))
+
+		err = dbtxn.SetEntry(badger.NewEntry(h.getCreatedID(ovHeader.OVGuid), createdBytes))
+		if err != nil {
+			return errors.New("Failed creating voucherDB creation time entry instance. " + err.Error())
+		}
+	} else if err != nil {
+		return errors.New("Failed locating voucherDB creation time entry. " + err.Error())
+	}
+
+	err = dbtxn.Commit()
 	if err != nil {
 		return errors.New("Failed saving voucherDB entry. " + err.Error())
 	}
@@ -108,3 +151,173 @@ func (h *VoucherDB) List() ([]fdoshared.FdoGuid, error) {
 
 	return result, nil
 }
+
+// ListPage returns metadata of up to limit vouchers, in GUID order. Listing starts after the voucher with GUID after, when set, and skips offset vouchers.
+// GUID of the last voucher is the cursor of the next page, which stays stable when vouchers are added or removed. more is true when vouchers remain after the page
+func (h *VoucherDB) ListPage(after *fdoshared.FdoGuid, offset int, limit int) ([]VoucherInfo, bool, error) {
+	if limit < 1 || limit > VOUCHER_LIST_MAX_LIMIT {
+		return nil, false, fmt.Errorf("limit must be 1 to %d. Got %d", VOUCHER_LIST_MAX_LIMIT, limit)
+	}
+
+	if offset < 0 {
+		return nil, false, fmt.Errorf("offset must not be negative. Got %d", offset)
+	}
+
+	vouchers := []VoucherInfo{}
+
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	// Values are only read for the page
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	iteratorOptions.Prefix = h.prefix
+
+	it := dbtxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	seekKey := h.prefix
+	if after != nil {
+		seekKey = h.getEntryID(*after)
+	}
+
+	for it.Seek(seekKey); it.ValidForPrefix(h.prefix); it.Next() {
+		item := it.Item()
+		guidBytes := item.Key()[len(h.prefix):]
+		if len(guidBytes) != 16 {
+			return nil, false, errors.New("invalid voucherdb entry key length")
+		}
+
+		var guid fdoshared.FdoGuid
+		guid.FromBytes(guidBytes)
+
+		if after != nil && guid == *after {
+			continue
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		if len(vouchers) == limit {
+			return vouchers, true, nil
+		}
+
+		itemBytes, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, false, errors.New("Failed reading voucherdb entry value. " + err.Error())
+		}
+
+		var voucherDBEInst fdoshared.VoucherDBEntry
+		err = fdoshared.CborCust.Unmarshal(itemBytes, &voucherDBEInst)
+		if err != nil {
+			return nil, false, errors.New("Failed cbor decoding voucherdb entry " + err.Error())
+		}
+
+		voucherInfo := VoucherInfo{
+			Guid:         guid,
+			NumOVEntries: len(voucherDBEInst.Voucher.OVEntryArray),
+		}
+
+		devCertChain := voucherDBEInst.Voucher.OVDevCertChain
+		if devCertChain != nil && len(*devCertChain) != 0 {
+			deviceCert, err := x509.ParseCertificate((*devCertChain)[0])
+			if err == nil {
+				voucherInfo.DeviceCertSubject = deviceCert.Subject.String()
+			}
+		}
+
+		createdItem, err := dbtxn.Get(h.getCreatedID(guid))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, false, errors.New("Failed locating voucherDB creation time entry. " + err.Error())
+		} else if err == nil {
+			createdBytes, err := createdItem.ValueCopy(nil)
+			if err != nil {
+				return nil, false, errors.New("Failed reading voucherDB creation time entry value. " + err.Error())
+			}
+
+			if len(createdBytes) == 8 {
+				voucherInfo.CreatedAt = int64(binary.BigEndian.Uint64(createdBytes))
+			}
+		}
+
+		vouchers = append(vouchers, voucherInfo)
+	}
+
+	return vouchers, false, nil
+}
+
+// SetOnboarded records that the device completed TO2 with the voucher. Record is kept when the voucher is saved again, and removed with it
+func (h *VoucherDB) SetOnboarded(deviceGuid fdoshared.FdoGuid) error {
+	onboardedBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(onboardedBytes, uint64(time.Now().Unix()))
+
+	dbtxn := h.db.NewTransaction(true)
+	defer dbtxn.Discard()
+
+	err := dbtxn.SetEntry(badger.NewEntry(h.getOnboardedID(deviceGuid), onboardedBytes))
+	if err != nil {
+		return errors.New("Failed creating voucherDB onboarded entry instance. " + err.Error())
+	}
+
+	err = dbtxn.Commit()
+	if err != nil {
+		return errors.New("Failed saving voucherDB onboarded entry. " + err.Error())
+	}
+
+	return nil
+}
+
+// GetOnboardedAt returns Unix seconds of the last completed TO2 of the device, 0 when it has not completed TO2
+func (h *VoucherDB) GetOnboardedAt(deviceGuid fdoshared.FdoGuid) (int64, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	item, err := dbtxn.Get(h.getOnboardedID(deviceGuid))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.New("Failed locating voucherDB onboarded entry. " + err.Error())
+	}
+
+	onboardedBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, errors.New("Failed reading voucherDB onboarded entry value. " + err.Error())
+	}
+
+	if len(onboardedBytes) != 8 {
+		return 0, errors.New("invalid voucherDB onboarded entry length")
+	}
+
+	return int64(binary.BigEndian.Uint64(onboardedBytes)), nil
+}
+
+// Delete removes the voucher, its creation time and onboarded record. Returns ErrVoucherNotFound when there is no voucher with the GUID
+func (h *VoucherDB) Delete(deviceGuid fdoshared.FdoGuid) error {
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		_, err := dbtxn.Get(h.getEntryID(deviceGuid))
+		if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrVoucherNotFound
+		} else if err != nil {
+			return errors.New("Failed locating voucher entry. " + err.Error())
+		}
+
+		err = dbtxn.Delete(h.getEntryID(deviceGuid))
+		if err != nil {
+			return errors.New("Failed deleting voucher entry. " + err.Error())
+		}
+
+		err = dbtxn.Delete(h.getCreatedID(deviceGuid))
+		if err != nil {
+			return errors.New("Failed deleting voucherDB creation time entry. " + err.Error())
+		}
+
+		err = dbtxn.Delete(h.getOnboardedID(deviceGuid))
+		if err != nil {
+			return errors.New("Failed deleting voucherDB onboarded entry. " + err.Error())
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,247 @@
+package dbs
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func newTestVoucherDBEntry(guid fdoshared.FdoGuid, numOVEntries int, deviceCert []byte) fdoshared.VoucherDBEntry {
+	ovHeaderBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OwnershipVoucherHeader{OVGuid: guid})
+
+	voucher := fdoshared.OwnershipVoucher{
+		OVHeaderTag:  ovHeaderBytes,
+		OVEntryArray: make(fdoshared.OVEntryArray, numOVEntries),
+	}
+
+	if deviceCert != nil {
+		voucher.OVDevCertChain = &[]fdoshared.X509CertificateBytes{deviceCert}
+	}
+
+	return fdoshared.VoucherDBEntry{Voucher: voucher}
+}
+
+func TestVoucherDB_ListPage(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	voucherDB := NewVoucherDB(db)
+
+	deviceKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	deviceCertTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test device"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	deviceCert, err := x509.CreateCertificate(rand.Reader, deviceCertTemplate, deviceCertTemplate, &deviceKey.PublicKey, deviceKey)
+	if err != nil {
+		t.Fatalf("Failed to create device certificate. %s", err.Error())
+	}
+
+	guids := []fdoshared.FdoGuid{}
+	for i := 0; i < 5; i++ {
+		guid := fdoshared.NewFdoGuid()
+		guids = append(guids, guid)
+
+		err = voucherDB.Save(newTestVoucherDBEntry(guid, i+1, deviceCert))
+		if err != nil {
+			t.Fatalf("Failed to save voucher. %s", err.Error())
+		}
+	}
+
+	sort.Slice(guids, func(i, j int) bool {
+		return bytes.Compare(guids[i][:], guids[j][:]) < 0
+	})
+
+	firstPage, more, err := voucherDB.ListPage(nil, 0, 2)
+	if err != nil {
+		t.Fatalf("Failed to list vouchers. %s", err.Error())
+	}
+
+	if len(firstPage) != 2 || !more || firstPage[0].Guid != guids[0] || firstPage[1].Guid != guids[1] {
+		t.Fatalf("Expected first two vouchers in GUID order and more to follow. Got %v %v", firstPage, more)
+	}
+
+	if firstPage[0].CreatedAt == 0 || firstPage[0].DeviceCertSubject != "CN=test device" || firstPage[0].NumOVEntries == 0 {
+		t.Errorf("Expected voucher metadata. Got %+v", firstPage[0])
+	}
+
+	// Voucher removed before the cursor does not shift the next page
+	err = db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(voucherDB.getEntryID(guids[0]))
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete voucher. %s", err.Error())
+	}
+
+	secondPage, more, err := voucherDB.ListPage(&firstPage[1].Guid, 0, 2)
+	if err != nil {
+		t.Fatalf("Failed to list vouchers. %s", err.Error())
+	}
+
+	if len(secondPage) != 2 || !more || secondPage[0].Guid != guids[2] || secondPage[1].Guid != guids[3] {
+		t.Errorf("Expected second page to continue after the cursor. Got %v %v", secondPage, more)
+	}
+
+	lastPage, more, err := voucherDB.ListPage(&secondPage[1].Guid, 0, 2)
+	if err != nil {
+		t.Fatalf("Failed to list vouchers. %s", err.Error())
+	}
+
+	if len(lastPage) != 1 || more || lastPage[0].Guid != guids[4] {
+		t.Errorf("Expected last voucher and no more. Got %v %v", lastPage, more)
+	}
+
+	offsetPage, _, err := voucherDB.ListPage(nil, 3, 10)
+	if err != nil {
+		t.Fatalf("Failed to list vouchers. %s", err.Error())
+	}
+
+	if len(offsetPage) != 1 || offsetPage[0].Guid != guids[4] {
+		t.Errorf("Expected offset to skip vouchers. Got %v", offsetPage)
+	}
+
+	// Saving again keeps creation time
+	createdAt := lastPage[0].CreatedAt
+	err = voucherDB.Save(newTestVoucherDBEntry(guids[4], 1, nil))
+	if err != nil {
+		t.Fatalf("Failed to save voucher. %s", err.Error())
+	}
+
+	lastPage, _, _ = voucherDB.ListPage(&guids[3], 0, 1)
+	if len(lastPage) != 1 || lastPage[0].CreatedAt != createdAt || lastPage[0].DeviceCertSubject != "" {
+		t.Errorf("Expected creation time to be kept and no device certificate. Got %+v", lastPage)
+	}
+
+	for _, invalid := range [][2]int{{0, 0}, {0, VOUCHER_LIST_MAX_LIMIT + 1}, {-1, 10}} {
+		_, _, err = voucherDB.ListPage(nil, invalid[0], invalid[1])
+		if err == nil {
+			t.Errorf("Expected offset %d limit %d to be rejected", invalid[0], invalid[1])
+		}
+	}
+
+	// Existing listing is not affected by creation time entries
+	allGuids, err := voucherDB.List()
+	if err != nil || len(allGuids) != 4 {
+		t.Errorf("Expected 4 vouchers listed. Got %d. %v", len(allGuids), err)
+	}
+}
+
+func TestVoucherDB_Delete(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	voucherDB := NewVoucherDB(db)
+	sessionDB := NewSessionDB(db)
+
+	guid := fdoshared.NewFdoGuid()
+	otherGuid := fdoshared.NewFdoGuid()
+	for _, voucherGuid := range []fdoshared.FdoGuid{guid, otherGuid} {
+		err = voucherDB.Save(newTestVoucherDBEntry(voucherGuid, 1, nil))
+		if err != nil {
+			t.Fatalf("Failed to save voucher. %s", err.Error())
+		}
+	}
+
+	_, err = sessionDB.NewTo2SessionEntry(SessionEntry{Protocol: fdoshared.To2, Guid: guid}, fdoshared.ONBOARDING_POLICY_REJECT)
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	err = voucherDB.Delete(guid)
+	if err != nil {
+		t.Fatalf("Failed to delete voucher. %s", err.Error())
+	}
+
+	err = sessionDB.DeleteGuidSessions(guid)
+	if err != nil {
+		t.Fatalf("Failed to delete voucher sessions. %s", err.Error())
+	}
+
+	if _, err := voucherDB.Get(guid); err == nil {
+		t.Errorf("Expected deleted voucher to be gone")
+	}
+
+	if _, err := voucherDB.Get(otherGuid); err != nil {
+		t.Errorf("Expected other voucher to be kept. %s", err.Error())
+	}
+
+	if sessionId, _ := sessionDB.GetActiveSessionId(guid); sessionId != nil {
+		t.Errorf("Expected active session of deleted voucher to be gone")
+	}
+
+	vouchers, _, err := voucherDB.ListPage(nil, 0, VOUCHER_LIST_MAX_LIMIT)
+	if err != nil || len(vouchers) != 1 {
+		t.Errorf("Expected only other voucher to be listed. Got %d, %v", len(vouchers), err)
+	}
+
+	err = voucherDB.Delete(guid)
+	if !errors.Is(err, ErrVoucherNotFound) {
+		t.Errorf("Expected ErrVoucherNotFound for missing voucher. Got %v", err)
+	}
+}
+
+func TestVoucherDB_Onboarded(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	voucherDB := NewVoucherDB(db)
+
+	guid := fdoshared.NewFdoGuid()
+	err = voucherDB.Save(newTestVoucherDBEntry(guid, 1, nil))
+	if err != nil {
+		t.Fatalf("Failed to save voucher. %s", err.Error())
+	}
+
+	onboardedAt, err := voucherDB.GetOnboardedAt(guid)
+	if err != nil || onboardedAt != 0 {
+		t.Fatalf("Expected voucher not to be onboarded. Got %d, %v", onboardedAt, err)
+	}
+
+	err = voucherDB.SetOnboarded(guid)
+	if err != nil {
+		t.Fatalf("Failed to record onboarded device. %s", err.Error())
+	}
+
+	// Saving voucher again keeps the record
+	err = voucherDB.Save(newTestVoucherDBEntry(guid, 2, nil))
+	if err != nil {
+		t.Fatalf("Failed to save voucher. %s", err.Error())
+	}
+
+	onboardedAt, err = voucherDB.GetOnboardedAt(guid)
+	if err != nil || onboardedAt == 0 {
+		t.Fatalf("Expected voucher to be onboarded. Got %d, %v", onboardedAt, err)
+	}
+
+	err = voucherDB.Delete(guid)
+	if err != nil {
+		t.Fatalf("Failed to delete voucher. %s", err.Error())
+	}
+
+	onboardedAt, err = voucherDB.GetOnboardedAt(guid)
+	if err != nil || onboardedAt != 0 {
+		t.Errorf("Expected onboarded record to be deleted with voucher. Got %d, %v", onboardedAt, err)
+	}
+}
@@ -6,15 +6,25 @@ import (
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 )
 
 func SetupServer(db *badger.DB, ctx context.Context) {
 	doto2 := to2.NewDoTo2(db, ctx)
 
-	http.HandleFunc("/fdo/101/msg/60", doto2.HelloDevice60)
-	http.HandleFunc("/fdo/101/msg/62", doto2.GetOVNextEntry62)
-	http.HandleFunc("/fdo/101/msg/64", doto2.ProveDevice64)
-	http.HandleFunc("/fdo/101/msg/66", doto2.DeviceServiceInfoReady66)
-	http.HandleFunc("/fdo/101/msg/68", doto2.DeviceServiceInfo68)
-	http.HandleFunc("/fdo/101/msg/70", doto2.Done70)
+	msgLogDb := tdbs.NewMessageLogDB(db)
+	listenerDb := tdbs.NewListenerTestDB(db)
+	delayDb := tdbs.NewResponseDelayDB(db)
+
+	http.HandleFunc("/fdo/101/msg/60", fdoshared.FdoDrainer.Track(fdoshared.TO2_60_HELLO_DEVICE, msgLogDb.Capture(listenerDb, fdoshared.To2, fdoshared.TO2_60_HELLO_DEVICE, doto2.ResolveMessageGuid, delayDb.Delay(fdoshared.TO2_60_HELLO_DEVICE, fdoshared.FdoMetrics.Instrument(fdoshared.To2, fdoshared.TO2_60_HELLO_DEVICE, doto2.HelloDevice60)))))
+	http.HandleFunc("/fdo/101/msg/62", fdoshared.FdoDrainer.Track(fdoshared.TO2_62_GET_OVNEXTENTRY, msgLogDb.Capture(listenerDb, fdoshared.To2, fdoshared.TO2_62_GET_OVNEXTENTRY, doto2.ResolveMessageGuid, delayDb.Delay(fdoshared.TO2_62_GET_OVNEXTENTRY, fdoshared.FdoMetrics.Instrument(fdoshared.To2, fdoshared.TO2_62_GET_OVNEXTENTRY, doto2.GetOVNextEntry62)))))
+	http.HandleFunc("/fdo/101/msg/64", fdoshared.FdoDrainer.Track(fdoshared.TO2_64_PROVE_DEVICE, msgLogDb.Capture(listenerDb, fdoshared.To2, fdoshared.TO2_64_PROVE_DEVICE, doto2.ResolveMessageGuid, delayDb.Delay(fdoshared.TO2_64_PROVE_DEVICE, fdoshared.FdoMetrics.Instrument(fdoshared.To2, fdoshared.TO2_64_PROVE_DEVICE, doto2.ProveDevice64)))))
+	http.HandleFunc("/fdo/101/msg/66", fdoshared.FdoDrainer.Track(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, msgLogDb.Capture(listenerDb, fdoshared.To2, fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, doto2.ResolveMessageGuid, delayDb.Delay(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, fdoshared.FdoMetrics.Instrument(fdoshared.To2, fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, doto2.DeviceServiceInfoReady66)))))
+	http.HandleFunc("/fdo/101/msg/68", fdoshared.FdoDrainer.Track(fdoshared.TO2_68_DEVICE_SERVICE_INFO, msgLogDb.Capture(listenerDb, fdoshared.To2, fdoshared.TO2_68_DEVICE_SERVICE_INFO, doto2.ResolveMessageGuid, delayDb.Delay(fdoshared.TO2_68_DEVICE_SERVICE_INFO, fdoshared.FdoMetrics.Instrument(fdoshared.To2, fdoshared.TO2_68_DEVICE_SERVICE_INFO, doto2.DeviceServiceInfo68)))))
+	http.HandleFunc("/fdo/101/msg/70", fdoshared.FdoDrainer.Track(fdoshared.TO2_70_DONE, msgLogDb.Capture(listenerDb, fdoshared.To2, fdoshared.TO2_70_DONE, doto2.ResolveMessageGuid, delayDb.Delay(fdoshared.TO2_70_DONE, fdoshared.FdoMetrics.Instrument(fdoshared.To2, fdoshared.TO2_70_DONE, doto2.Done70)))))
+	http.HandleFunc("/fdo/101/msg/255", fdoshared.FdoDrainer.Track(fdoshared.TO_ERROR_255, fdoshared.FdoMetrics.Instrument(fdoshared.To2, fdoshared.TO_ERROR_255, doto2.DeviceError255)))
+
+	// Catch-all for message numbers not served by DO and RV
+	http.HandleFunc(fdoshared.FDO_101_URL_BASE, doto2.UnknownMessage)
 }
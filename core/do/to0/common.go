@@ -13,6 +13,9 @@ type To0Requestor struct {
 	voucherDBEntry fdoshared.VoucherDBEntry
 	authzHeader    string
 	ctx            context.Context
+
+	// When set, overrides owner address in To1d RVTO2Addr
+	to2Addr *fdoshared.To2AddrConfig
 }
 
 func NewTo0Requestor(rvEntry fdoshared.SRVEntry, voucherDBEntry fdoshared.VoucherDBEntry, ctx context.Context) To0Requestor {
@@ -23,6 +26,11 @@ func NewTo0Requestor(rvEntry fdoshared.SRVEntry, voucherDBEntry fdoshared.Vouche
 	}
 }
 
+// SetTo2Addr makes OwnerSign22 register owner address from to2Addr instead of FDO service URL. Nil resets to the service URL
+func (h *To0Requestor) SetTo2Addr(to2Addr *fdoshared.To2AddrConfig) {
+	h.to2Addr = to2Addr
+}
+
 const ServerWaitSeconds uint32 = 30 * 24 * 60 * 60 // 1 month
 
 func (h *To0Requestor) getRVTO2AddrEntry() (*fdoshared.RVTO2AddrEntry, error) {
@@ -31,6 +39,10 @@ func (h *To0Requestor) getRVTO2AddrEntry() (*fdoshared.RVTO2AddrEntry, error) {
 		return nil, fmt.Errorf("getRVTO2AddrEntry: FDO service URL not set")
 	}
 
+	if h.to2Addr != nil {
+		return h.to2Addr.ToRVTO2AddrEntry(servUrl)
+	}
+
 	return fdoshared.UrlToTOAddrEntry(servUrl)
 }
 
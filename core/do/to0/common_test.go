@@ -0,0 +1,102 @@
+package to0
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func newTestRvServer(t *testing.T, ctx context.Context) (*httptest.Server, *fdorv.OwnerSignDB) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+
+	rvTo0 := fdorv.NewRvTo0(db, ctx)
+	ownerSignDB := fdorv.NewOwnerSignDB(db)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/20", rvTo0.Handle20Hello)
+	mux.HandleFunc("/fdo/101/msg/22", rvTo0.Handle22OwnerSign)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, &ownerSignDB
+}
+
+func TestOwnerSign22_To2Addr(t *testing.T) {
+	ctx := context.WithValue(context.Background(), fdoshared.CFG_ENV_INTEROP_ENABLED, false)
+	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_FDO_SERVICE_URL, "http://do.example.com:8080")
+
+	rvServer, ownerSignDB := newTestRvServer(t, ctx)
+
+	testCases := []struct {
+		to2Addr     *fdoshared.To2AddrConfig
+		expectedUrl string
+	}{
+		{nil, "http://do.example.com:8080"},
+		{&fdoshared.To2AddrConfig{Protocol: "https"}, "https://do.example.com:443"},
+		{&fdoshared.To2AddrConfig{Port: 9443}, "http://do.example.com:9443"},
+		{&fdoshared.To2AddrConfig{Protocol: "https", Host: "10.0.0.5", Port: 8443}, "https://10.0.0.5:8443"},
+	}
+
+	for _, testCase := range testCases {
+		credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+		if err != nil {
+			t.Fatalf("Failed to generate device credential. %s", err.Error())
+		}
+
+		credAndVoucher, err := fdodeviceimplementation.NewVirtualDeviceAndVoucher(*credential, fdoshared.StSECP256R1, fdoshared.RendezvousInfo{}, testcom.NULL_TEST)
+		if err != nil {
+			t.Fatalf("Failed to generate voucher. %s", err.Error())
+		}
+
+		to0client := NewTo0Requestor(fdoshared.SRVEntry{SrvURL: rvServer.URL}, credAndVoucher.VoucherDBEntry, ctx)
+		to0client.SetTo2Addr(testCase.to2Addr)
+
+		helloAck21, _, err := to0client.Hello20(testcom.NULL_TEST)
+		if err != nil {
+			t.Fatalf("Failed Hello20. %s", err.Error())
+		}
+
+		_, _, err = to0client.OwnerSign22(helloAck21.NonceTO0Sign, testcom.NULL_TEST)
+		if err != nil {
+			t.Fatalf("Failed OwnerSign22. %s", err.Error())
+		}
+
+		// RV serves stored To1d as is in RVRedirect33, and device takes owner address from it.
+		// Device TO1 itself is not run, as SHA1 signed test root depends on x509sha1 GODEBUG
+		ownerSign, err := ownerSignDB.Get(credAndVoucher.WawDeviceCredential.DCGuid)
+		if err != nil {
+			t.Fatalf("Failed to get RV owner sign. %s", err.Error())
+		}
+
+		var to1dPayload fdoshared.To1dBlobPayload
+		err = fdoshared.CborCust.Unmarshal(ownerSign.To1d.Payload, &to1dPayload)
+		if err != nil {
+			t.Fatalf("Failed to decode To1d payload. %s", err.Error())
+		}
+
+		if len(to1dPayload.To1dRV) != 1 {
+			t.Fatalf("Expected one RVTO2Addr entry. Got %d", len(to1dPayload.To1dRV))
+		}
+
+		ownerUrl, err := to1dPayload.To1dRV[0].ToUrl()
+		if err != nil {
+			t.Fatalf("Failed to get owner URL. %s", err.Error())
+		}
+
+		if ownerUrl != testCase.expectedUrl {
+			t.Errorf("Expected device to get owner address %s. Got %s", testCase.expectedUrl, ownerUrl)
+		}
+	}
+}
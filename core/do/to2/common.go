@@ -4,24 +4,26 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to0"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 )
 
-const MAX_NUM_OVENTRIES = 255
-
 type DoTo2 struct {
 	session    *dbs.SessionDB
 	voucher    *dbs.VoucherDB
 	listenerDB *tdbs.ListenerTestDB
+	identity   *dbs.IdentityDB
 	ctx        context.Context
 }
 
@@ -34,6 +36,7 @@ func NewDoTo2(db *badger.DB, ctx context.Context) DoTo2 {
 		session:    sessionDb,
 		voucher:    voucherDb,
 		listenerDB: newListenerDb,
+		identity:   dbs.NewIdentityDB(db),
 		ctx:        ctx,
 	}
 }
@@ -52,6 +55,40 @@ func ValidateDeviceSIMs(guid fdoshared.FdoGuid, sims []fdoshared.ServiceInfoKV)
 	return fdoshared.DecodeSims(sims)
 }
 
+// Conformance. Content-Type and Message-Type headers of ProveOVHdr61 per test. Empty header is not sent
+func conf_ProveOVHdrFraming(fdoTestId testcom.FDOTestID) (string, string) {
+	contentType := fdoshared.CONTENT_TYPE_CBOR
+	messageType := fdoshared.TO2_61_PROVE_OVHDR.ToString()
+
+	switch fdoTestId {
+	case testcom.FIDO_LISTENER_DEVICE_60_BAD_CONTENT_TYPE:
+		contentType = "application/json"
+	case testcom.FIDO_LISTENER_DEVICE_60_MISSING_CONTENT_TYPE:
+		contentType = ""
+	case testcom.FIDO_LISTENER_DEVICE_60_BAD_MESSAGE_TYPE:
+		messageType = fdoshared.TO2_63_OV_NEXTENTRY.ToString()
+	case testcom.FIDO_LISTENER_DEVICE_60_MISSING_MESSAGE_TYPE:
+		messageType = ""
+	}
+
+	return contentType, messageType
+}
+
+// Conformance. Each missing or malformed mandatory devmod key is a separate failure
+func conf_DevmodTestStates(sims []fdoshared.ServiceInfoKV) []testcom.FDOTestState {
+	devmodErrs := fdoshared.ValidateDevmod(sims)
+	if len(devmodErrs) == 0 {
+		return []testcom.FDOTestState{testcom.NewSuccessTestState(testcom.FIDO_LISTENER_DEVICE_68_DEVMOD)}
+	}
+
+	testStates := []testcom.FDOTestState{}
+	for _, devmodErr := range devmodErrs {
+		testStates = append(testStates, testcom.NewFailTestState(testcom.FIDO_LISTENER_DEVICE_68_DEVMOD, "Invalid devmod. "+devmodErr.Error()))
+	}
+
+	return testStates
+}
+
 func (h *DoTo2) getEnvInteropSimsMapping() (map[fdoshared.FdoGuid]string, error) {
 	mappings := map[fdoshared.FdoGuid]string{}
 
@@ -115,6 +152,47 @@ func (h *DoTo2) GetOwnerSIMs(guid fdoshared.FdoGuid) ([]fdoshared.ServiceInfoKV,
 	return ownerSims, nil
 }
 
+// Conformance. Finds device GUID of the exchange for the message log
+func (h *DoTo2) ResolveMessageGuid(sessionId []byte, reqBody []byte) *fdoshared.FdoGuid {
+	if sessionId != nil {
+		session, err := h.session.GetSessionEntry(sessionId)
+		if err == nil && session != nil {
+			return &session.Guid
+		}
+	}
+
+	var helloDevice fdoshared.HelloDevice60
+	err := fdoshared.CborCust.Unmarshal(reqBody, &helloDevice)
+	if err == nil {
+		return &helloDevice.Guid
+	}
+
+	return nil
+}
+
+// Conformance. Results of the ServiceInfo handshake configured for the test run
+func conf_ServiceInfoTestStates(session *dbs.SessionEntry, serviceInfoConfig listenertestsdeps.To2ServiceInfoConfig) []testcom.FDOTestState {
+	testStates := []testcom.FDOTestState{}
+
+	if serviceInfoConfig.MaxDeviceServiceInfoSz != 0 {
+		if session.ExceededMaxDeviceServiceInfoSz {
+			testStates = append(testStates, testcom.NewFailTestState(testcom.FIDO_LISTENER_DEVICE_68_MAX_DEVICE_SERVICEINFO_SZ, fmt.Sprintf("Device sent DeviceServiceInfo larger than MaxDeviceServiceInfoSz %d", session.MaxDeviceServiceInfoSz)))
+		} else {
+			testStates = append(testStates, testcom.NewSuccessTestState(testcom.FIDO_LISTENER_DEVICE_68_MAX_DEVICE_SERVICEINFO_SZ))
+		}
+	}
+
+	if len(serviceInfoConfig.ActiveModules) != 0 {
+		if len(session.PendingModuleActivations) != 0 {
+			testStates = append(testStates, testcom.NewFailTestState(testcom.FIDO_LISTENER_DEVICE_68_MODULE_ACTIVATION, fmt.Sprintf("Device did not respond to activation of modules %v", session.PendingModuleActivations)))
+		} else {
+			testStates = append(testStates, testcom.NewSuccessTestState(testcom.FIDO_LISTENER_DEVICE_68_MODULE_ACTIVATION))
+		}
+	}
+
+	return testStates
+}
+
 func (h *DoTo2) receiveAndVerify(w http.ResponseWriter, r *http.Request, currentCmd fdoshared.FdoCmd) (*dbs.SessionEntry, []byte, string, []byte, *listenertestsdeps.RequestListenerInst, error) {
 	if !fdoshared.CheckHeaders(w, r, fdoshared.TO2_64_PROVE_DEVICE) {
 		return nil, []byte{}, "", []byte{}, nil, fmt.Errorf("Error checking header!")
@@ -126,6 +204,9 @@ func (h *DoTo2) receiveAndVerify(w http.ResponseWriter, r *http.Request, current
 	}
 
 	session, err := h.session.GetSessionEntry(sessionId)
+	if err == nil && session == nil {
+		err = errors.New("session expired or was superseded")
+	}
 	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, fmt.Sprintf("%d: Can not find session... %s", currentCmd, err.Error()), http.StatusUnauthorized, nil, fdoshared.To2)
 		return nil, []byte{}, "", []byte{}, nil, fmt.Errorf("%d: Can not find session... %s", currentCmd, err.Error())
@@ -134,12 +215,14 @@ func (h *DoTo2) receiveAndVerify(w http.ResponseWriter, r *http.Request, current
 	// Conformance
 	testcomListener, err := h.listenerDB.GetEntryByFdoGuid(session.Guid)
 	if err != nil {
-		log.Printf("NO TEST CASE FOR %s. %s ", hex.EncodeToString(session.Guid[:]), err.Error())
+		logger := fdoshared.NewMessageLogger(fdoshared.To2, currentCmd, r)
+		logger.SetGuid(session.Guid)
+		logger.Debugf("No test case. %s", err.Error())
 	}
 
-	bodyBytes, err := io.ReadAll(r.Body)
+	bodyBytes, err := fdoshared.ReadMessageBody(w, r, currentCmd)
 	if err != nil {
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to read body!", http.StatusBadRequest, testcomListener, fdoshared.To2)
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to read body! "+err.Error(), fdoshared.MessageBodyErrorStatus(err), testcomListener, fdoshared.To2)
 
 		return nil, []byte{}, "", []byte{}, testcomListener, fmt.Errorf("%d: Error reading body... %s", currentCmd, err.Error())
 	}
@@ -160,3 +243,111 @@ func (h *DoTo2) receiveAndDecrypt(w http.ResponseWriter, r *http.Request, curren
 
 	return session, sessionId, authorizationHeader, bodyBytes, testcomListener, nil
 }
+
+// Conformance. Saves voucher for the credential installed by SetupDevice, and registers it with RV, so device holding it can onboard again
+func (h *DoTo2) conf_SaveReplacementVoucher(session *dbs.SessionEntry) (*fdoshared.VoucherDBEntry, error) {
+	if session.ReplacementHMac == nil {
+		return nil, errors.New("device did not send ReplacementHMac for the new credential")
+	}
+
+	owner2PrivateKey, err := fdoshared.ExtractPrivateKey(session.ReplacementPrivateKeyDER)
+	if err != nil {
+		return nil, errors.New("error decoding Owner2Key. " + err.Error())
+	}
+
+	newVoucher, err := fdoshared.NewReplacementVoucher(session.Voucher, *session.ReplacementCredential, *session.ReplacementHMac, owner2PrivateKey, session.SignatureSgType)
+	if err != nil {
+		return nil, errors.New("error generating replacement voucher. " + err.Error())
+	}
+
+	voucherDBEntry := fdoshared.VoucherDBEntry{
+		Voucher:        *newVoucher,
+		PrivateKeyX509: session.ReplacementPrivateKeyDER,
+	}
+
+	err = h.voucher.Save(voucherDBEntry)
+	if err != nil {
+		return nil, errors.New("error saving replacement voucher. " + err.Error())
+	}
+
+	to0client := to0.NewTo0Requestor(fdoshared.SRVEntry{
+		SrvURL: h.ctx.Value(fdoshared.CFG_ENV_FDO_SERVICE_URL).(string),
+	}, voucherDBEntry, h.ctx)
+
+	helloAck21, _, err := to0client.Hello20(testcom.NULL_TEST)
+	if err != nil {
+		return nil, errors.New("error submitting replacement OwnerSign. " + err.Error())
+	}
+
+	_, _, err = to0client.OwnerSign22(helloAck21.NonceTO0Sign, testcom.NULL_TEST)
+	if err != nil {
+		return nil, errors.New("error submitting replacement OwnerSign. " + err.Error())
+	}
+
+	return &voucherDBEntry, nil
+}
+
+// Conformance. Device accepted Done2 of a negative test, and keeps the credential installed by SetupDevice. Its voucher is saved and new GUID is mapped to the listener, but the listener keeps expecting previous GUID
+func (h *DoTo2) Conf_KeepReplacementCredential(session *dbs.SessionEntry, testcomListener *listenertestsdeps.RequestListenerInst) error {
+	_, err := h.conf_SaveReplacementVoucher(session)
+	if err != nil {
+		return err
+	}
+
+	return h.listenerDB.SaveMapping(session.ReplacementCredential.ReplacementGuid, testcomListener.Uuid)
+}
+
+// Conformance. Saves voucher for the credential installed by SetupDevice, registers it with RV, and maps new GUID to the listener so device can be verified on following TO1/TO2
+func (h *DoTo2) Conf_RegisterReplacementCredential(session *dbs.SessionEntry, testcomListener *listenertestsdeps.RequestListenerInst) error {
+	voucherDBEntry, err := h.conf_SaveReplacementVoucher(session)
+	if err != nil {
+		return err
+	}
+
+	newGuid := session.ReplacementCredential.ReplacementGuid
+	err = h.listenerDB.SaveMapping(newGuid, testcomListener.Uuid)
+	if err != nil {
+		return err
+	}
+
+	replacedGuid := testcomListener.Guid
+	testcomListener.ReplacedGuid = &replacedGuid
+
+	secondaryRvUrl := h.ctx.Value(fdoshared.CFG_ENV_SECONDARY_RV_URL)
+	testcomListener.ReplacedRvInfo = secondaryRvUrl != nil && secondaryRvUrl.(string) != ""
+	testcomListener.Guid = newGuid
+	testcomListener.TestVoucher = *voucherDBEntry
+
+	return nil
+}
+
+// UnknownMessage responds to message numbers not served by DO and RV. When enabled, records it as observation of the running TO2 test run
+func (h *DoTo2) UnknownMessage(w http.ResponseWriter, r *http.Request) {
+	if fdoshared.RecordUnknownMessages {
+		h.conf_RecordUnknownMessage(r)
+	}
+
+	fdoshared.RespondUnknownMessage(w, r)
+}
+
+func (h *DoTo2) conf_RecordUnknownMessage(r *http.Request) {
+	sessionId, err := fdoshared.ParseBearerToken(r.Header.Get("Authorization"))
+	if err != nil {
+		return
+	}
+
+	session, err := h.session.GetSessionEntry(sessionId)
+	if err != nil || session == nil {
+		return
+	}
+
+	testcomListener, err := h.listenerDB.GetEntryByFdoGuid(session.Guid)
+	if err != nil || !testcomListener.To2.PushFailTest(testcom.FIDO_LISTENER_DEVICE_UNKNOWN_MESSAGE, fmt.Sprintf("Observation: device sent unknown message %s", strings.TrimPrefix(r.URL.Path, fdoshared.FDO_101_URL_BASE))) {
+		return
+	}
+
+	err = h.listenerDB.Update(testcomListener)
+	if err != nil {
+		log.Println("Conformance module failed to save result! " + err.Error())
+	}
+}
@@ -2,9 +2,14 @@ package to2
 
 import (
 	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 )
 
 func TestValidateDeviceSIMs(t *testing.T) {
@@ -50,6 +55,9 @@ func TestValidateDeviceSIMs(t *testing.T) {
 	}
 
 	// Test with missing mandatory SIMs
+	prevMandatorySims := fdoshared.MANDATORY_SIMS
+	defer func() { fdoshared.MANDATORY_SIMS = prevMandatorySims }()
+
 	fdoshared.MANDATORY_SIMS = fdoshared.SIM_IDS{"sim1", "sim2", "sim3"}
 	result, err = ValidateDeviceSIMs(guid, sims)
 	if err == nil {
@@ -71,3 +79,100 @@ func TestValidateDeviceSIMs(t *testing.T) {
 		t.Errorf("Expected nil result, but got %v", result)
 	}
 }
+
+func TestConfServiceInfoTestStates(t *testing.T) {
+	session := dbs.SessionEntry{}
+	if len(conf_ServiceInfoTestStates(&session, listenertestsdeps.To2ServiceInfoConfig{})) != 0 {
+		t.Errorf("Expected no test states without ServiceInfo settings")
+	}
+
+	config := listenertestsdeps.To2ServiceInfoConfig{
+		MaxDeviceServiceInfoSz: 512,
+		ActiveModules:          []string{"fdo_sys"},
+	}
+
+	session.ExceededMaxDeviceServiceInfoSz = true
+	session.PendingModuleActivations = []string{"fdo_sys"}
+
+	testStates := conf_ServiceInfoTestStates(&session, config)
+	if len(testStates) != 2 {
+		t.Fatalf("Expected 2 test states, got %d", len(testStates))
+	}
+
+	if testStates[0].TestID != testcom.FIDO_LISTENER_DEVICE_68_MAX_DEVICE_SERVICEINFO_SZ || testStates[0].Passed {
+		t.Errorf("Expected failed %s", testcom.FIDO_LISTENER_DEVICE_68_MAX_DEVICE_SERVICEINFO_SZ)
+	}
+
+	if testStates[1].TestID != testcom.FIDO_LISTENER_DEVICE_68_MODULE_ACTIVATION || testStates[1].Passed {
+		t.Errorf("Expected failed %s", testcom.FIDO_LISTENER_DEVICE_68_MODULE_ACTIVATION)
+	}
+
+	session.ExceededMaxDeviceServiceInfoSz = false
+	session.PendingModuleActivations = listenertestsdeps.Conf_ResolveModuleActivations(session.PendingModuleActivations, config.ActivationSIMs())
+
+	for _, testState := range conf_ServiceInfoTestStates(&session, config) {
+		if !testState.Passed {
+			t.Errorf("Expected %s to pass. %s", testState.TestID, testState.Error)
+		}
+	}
+}
+
+func TestConfDevmodTestStates(t *testing.T) {
+	sims := append(fdoshared.GetDeviceOSSims(),
+		fdoshared.ServiceInfoKV{ServiceInfoKey: fdoshared.SIM_DEVMOD_NUMMODULES, ServiceInfoVal: fdoshared.UintToCborBytes(1)},
+		fdoshared.ServiceInfoKV{ServiceInfoKey: fdoshared.SIM_DEVMOD_MODULES, ServiceInfoVal: fdoshared.SimsListToBytes(fdoshared.SIM_IDS{"devmod"})},
+	)
+
+	testStates := conf_DevmodTestStates(sims)
+	if len(testStates) != 1 || !testStates[0].Passed || testStates[0].TestID != testcom.FIDO_LISTENER_DEVICE_68_DEVMOD {
+		t.Errorf("Expected single passing %s. Got %v", testcom.FIDO_LISTENER_DEVICE_68_DEVMOD, testStates)
+	}
+
+	testStates = conf_DevmodTestStates([]fdoshared.ServiceInfoKV{})
+	if len(testStates) != len(fdoshared.MANDATORY_SIMS) {
+		t.Fatalf("Expected failure per mandatory devmod key. Got %d", len(testStates))
+	}
+
+	for _, testState := range testStates {
+		if testState.Passed || testState.TestID != testcom.FIDO_LISTENER_DEVICE_68_DEVMOD {
+			t.Errorf("Expected failed %s. Got %v", testcom.FIDO_LISTENER_DEVICE_68_DEVMOD, testState)
+		}
+	}
+}
+
+func TestConfProveOVHdrFraming(t *testing.T) {
+	contentType, messageType := conf_ProveOVHdrFraming(testcom.FIDO_LISTENER_POSITIVE)
+	if contentType != fdoshared.CONTENT_TYPE_CBOR || messageType != "61" {
+		t.Errorf("Expected correct framing for positive test. Got %s %s", contentType, messageType)
+	}
+
+	for _, testId := range []testcom.FDOTestID{
+		testcom.FIDO_LISTENER_DEVICE_60_BAD_CONTENT_TYPE,
+		testcom.FIDO_LISTENER_DEVICE_60_MISSING_CONTENT_TYPE,
+		testcom.FIDO_LISTENER_DEVICE_60_BAD_MESSAGE_TYPE,
+		testcom.FIDO_LISTENER_DEVICE_60_MISSING_MESSAGE_TYPE,
+	} {
+		contentType, messageType := conf_ProveOVHdrFraming(testId)
+		if (contentType != fdoshared.CONTENT_TYPE_CBOR) == (messageType != "61") {
+			t.Errorf("%s: Expected exactly one wrong header. Got %q %q", testId, contentType, messageType)
+		}
+	}
+
+	// Missing Content-Type must not be filled in by net/http
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = nil
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte{0x80})
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to send request. %s", err.Error())
+	}
+	resp.Body.Close()
+
+	if _, ok := resp.Header["Content-Type"]; ok {
+		t.Errorf("Expected no Content-Type. Got %q", resp.Header.Get("Content-Type"))
+	}
+}
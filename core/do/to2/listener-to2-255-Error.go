@@ -0,0 +1,55 @@
+package to2
+
+import (
+	"fmt"
+	"net/http"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// DeviceError255 handles device aborting TO2 with error message. Session is terminated, so it can not be continued or left dangling
+func (h *DoTo2) DeviceError255(w http.ResponseWriter, r *http.Request) {
+	var currentCmd fdoshared.FdoCmd = fdoshared.TO_ERROR_255
+	logger := fdoshared.NewMessageLogger(fdoshared.To2, currentCmd, r)
+	logger.Infof("Receiving...")
+
+	session, sessionId, _, bodyBytes, testcomListener, err := h.receiveAndVerify(w, r, currentCmd)
+	if err != nil {
+		return
+	}
+	logger.SetGuid(session.Guid)
+
+	deviceError, err := fdoshared.DecodeErrorResponse(bodyBytes)
+	if err != nil {
+		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode error message. "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger.Warnf("Device aborted TO2 after %d. %d: %s", deviceError.EMPrevMsgID, deviceError.EMErrorCode, deviceError.EMErrorStr)
+
+	err = h.session.DeleteSessionEntry(sessionId, session.Guid)
+	if err != nil {
+		logger.Errorf("Error terminating session. %s", err.Error())
+		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal server error!", http.StatusInternalServerError)
+		return
+	}
+
+	if testcomListener != nil && testcomListener.To2.PushTestStates(conf_DeviceErrorTestState(testcomListener.To2.GetLastTestID(), testcomListener.To2.CheckCmdTestingIsCompleted(testcomListener.To2.ExpectedCmd), *deviceError)) {
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			logger.Errorf("Conformance module failed to save result! %s", err.Error())
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Conformance. Device abort is expected while DO serves negative test of the current message, and a failure otherwise
+func conf_DeviceErrorTestState(lastTestId testcom.FDOTestID, cmdTestingCompleted bool, deviceError fdoshared.FdoError) testcom.FDOTestState {
+	if !cmdTestingCompleted && lastTestId != testcom.NULL_TEST && lastTestId != testcom.FIDO_LISTENER_POSITIVE {
+		return testcom.NewSuccessTestState(testcom.FIDO_LISTENER_DEVICE_ERROR_MESSAGE)
+	}
+
+	return testcom.NewFailTestState(testcom.FIDO_LISTENER_DEVICE_ERROR_MESSAGE, fmt.Sprintf("Device aborted TO2 after %d with error %d: %s", deviceError.EMPrevMsgID, deviceError.EMErrorCode, deviceError.EMErrorStr))
+}
@@ -0,0 +1,106 @@
+package to2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	deviceto2 "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+)
+
+func TestDeviceError255_SessionCleanup(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	doto2 := NewDoTo2(db, context.Background())
+
+	guid := fdoshared.NewFdoGuid_FIDO()
+	sessionId, err := doto2.session.NewTo2SessionEntry(dbs.SessionEntry{
+		Protocol: fdoshared.To2,
+		PrevCMD:  fdoshared.TO2_65_SETUP_DEVICE,
+		Guid:     guid,
+	}, fdoshared.ONBOARDING_POLICY_REJECT)
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	testcomListener := listenertestsdeps.RequestListenerInst{
+		Uuid: []byte("device-error-listener"),
+		Guid: guid,
+	}
+	testcomListener.To2.Protocol = fdoshared.To2
+	testcomListener.To2.StartNewTestRun()
+	testcomListener.To2.ExpectedCmd = fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY
+	testcomListener.To2.CompletedCmds = []fdoshared.FdoCmd{fdoshared.TO2_60_HELLO_DEVICE, fdoshared.TO2_62_GET_OVNEXTENTRY, fdoshared.TO2_64_PROVE_DEVICE}
+	testcomListener.To2.LastTestID = testcom.FIDO_LISTENER_DEVICE_66_ROLLBACK
+
+	err = doto2.listenerDB.Save(testcomListener)
+	if err != nil {
+		t.Fatalf("Failed to save listener. %s", err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/255", doto2.DeviceError255)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Virtual device aborts at 66, after rejecting the rolled back OwnerServiceInfoReady
+	device := deviceto2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: server.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	device.AuthzHeader = "Bearer " + string(sessionId)
+
+	err = device.Error255(fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO2_67_OWNER_SERVICE_INFO_READY, "rollback detected")
+	if err != nil {
+		t.Fatalf("Expected owner to accept error message. %s", err.Error())
+	}
+
+	session, err := doto2.session.GetSessionEntry(sessionId)
+	if err != nil || session != nil {
+		t.Errorf("Expected session to be terminated")
+	}
+
+	activeSessionId, err := doto2.session.GetActiveSessionId(guid)
+	if err != nil || activeSessionId != nil {
+		t.Errorf("Expected active session of the GUID to be released")
+	}
+
+	result, err := doto2.listenerDB.GetEntryByFdoGuid(guid)
+	if err != nil {
+		t.Fatalf("Failed to get listener. %s", err.Error())
+	}
+
+	testRuns := result.To2.CurrentTestRun.TestRuns
+	if len(testRuns) != 1 || testRuns[0].TestID != testcom.FIDO_LISTENER_DEVICE_ERROR_MESSAGE || !testRuns[0].Passed {
+		t.Errorf("Expected passed %s, got %v", testcom.FIDO_LISTENER_DEVICE_ERROR_MESSAGE, testRuns)
+	}
+
+	// Terminated session can not be continued
+	err = device.Error255(fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO2_67_OWNER_SERVICE_INFO_READY, "rollback detected")
+	if err == nil {
+		t.Errorf("Expected error message of terminated session to be rejected")
+	}
+}
+
+func TestConfDeviceErrorTestState(t *testing.T) {
+	deviceError := fdoshared.NewFdoError(fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO2_61_PROVE_OVHDR, "bad signature")
+
+	if !conf_DeviceErrorTestState(testcom.FIDO_LISTENER_DEVICE_60_BAD_COSE_SIGNATURE, false, deviceError).Passed {
+		t.Errorf("Expected abort during negative test to pass")
+	}
+
+	if conf_DeviceErrorTestState(testcom.FIDO_LISTENER_POSITIVE, false, deviceError).Passed {
+		t.Errorf("Expected abort during positive test to fail")
+	}
+
+	if conf_DeviceErrorTestState(testcom.FIDO_LISTENER_DEVICE_60_BAD_COSE_SIGNATURE, true, deviceError).Passed {
+		t.Errorf("Expected abort after message testing completed to fail")
+	}
+}
@@ -1,11 +1,10 @@
 package to2
 
 import (
-	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
+	"time"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -14,17 +13,18 @@ import (
 )
 
 func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
-	log.Println("HelloDevice60: Receiving...")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_60_HELLO_DEVICE
+	logger := fdoshared.NewMessageLogger(fdoshared.To2, currentCmd, r)
+	logger.Infof("Receiving...")
 
 	var testcomListener *listenertestsdeps.RequestListenerInst
 	if !fdoshared.CheckHeaders(w, r, currentCmd) {
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(r.Body)
+	bodyBytes, err := fdoshared.ReadMessageBody(w, r, currentCmd)
 	if err != nil {
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to read body!", http.StatusBadRequest, testcomListener, fdoshared.To2)
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to read body! "+err.Error(), fdoshared.MessageBodyErrorStatus(err), testcomListener, fdoshared.To2)
 		return
 	}
 
@@ -36,11 +36,23 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	logger.SetGuid(helloDevice.Guid)
+
 	// Test stuff
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 	testcomListener, err = h.listenerDB.GetEntryByFdoGuid(helloDevice.Guid)
 	if err != nil {
-		log.Printf("NO TEST CASE FOR %s. %s ", hex.EncodeToString(helloDevice.Guid[:]), err.Error())
+		logger.Debugf("No test case. %s", err.Error())
+	}
+
+	if testcomListener != nil && testcomListener.Conf_CheckAbandonedGuid(helloDevice.Guid) {
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			logger.Errorf("Conformance module failed to save result! %s", err.Error())
+		}
+
+		fdoshared.RespondFDOError(w, r, fdoshared.RESOURCE_NOT_FOUND, currentCmd, "Can not find voucher.", http.StatusUnauthorized)
+		return
 	}
 
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
@@ -71,6 +83,29 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Device tests onboard the same device repeatedly, so the policy only applies to other devices
+	if testcomListener == nil && fdoshared.Reonboard == fdoshared.REONBOARD_POLICY_DENY {
+		onboardedAt, err := h.voucher.GetOnboardedAt(helloDevice.Guid)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error reading voucher state...", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
+
+		if onboardedAt != 0 {
+			logger.Warnf("Rejecting reonboarding. Device completed TO2 at %s", time.Unix(onboardedAt, 0).UTC().Format(time.RFC3339))
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.RESOURCE_NOT_FOUND, currentCmd, "Voucher was already used to onboard the device.", http.StatusUnauthorized, testcomListener, fdoshared.To2)
+			return
+		}
+	}
+
+	if listenertestsdeps.Conf_CheckTLSDeviceCert(r, voucherDBEntry.Voucher.OVDevCertChain, testcomListener, fdoshared.To2) {
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
+			return
+		}
+	}
+
 	NonceTO2ProveDv := fdoshared.NewFdoNonce()
 
 	// KEX Generation
@@ -97,6 +132,12 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 
 	// Generating response
 	NumOVEntries := len(voucherDBEntry.Voucher.OVEntryArray)
+	err = fdoshared.Limits.CheckOVEntriesCount(NumOVEntries)
+	if err != nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Voucher exceeds resource limits. "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
+		return
+	}
+
 	proveOVHdrPayload := fdoshared.TO2ProveOVHdrPayload{
 		OVHeader:            voucherDBEntry.Voucher.OVHeaderTag,
 		NumOVEntries:        uint8(NumOVEntries),
@@ -116,6 +157,10 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		proveOVHdrPayload.NonceTO2ProveOV = fdoshared.NewFdoNonce()
 	}
 
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_STALE_NONCE_TO2PROVEOV {
+		proveOVHdrPayload.NonceTO2ProveOV = testcomListener.Conf_StaleNonceTO2ProveOV()
+	}
+
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_EBSIGNINFO {
 		proveOVHdrPayload.EBSigInfo.SgType = fdoshared.Conf_NewRandomSgTypeExcept(proveOVHdrPayload.EBSigInfo.SgType)
 	}
@@ -160,17 +205,33 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		Voucher:         voucherDBEntry.Voucher, // Stored twice in db, much more accessible from here
 
 		NumOVEntries:             uint8(NumOVEntries),
+		MaxDeviceMessageSize:     helloDevice.MaxDeviceMessageSize,
 		OwnerSIMsFinishedSending: false,
 		OwnerSIMsSendCounter:     0,
 		OwnerSIMs:                []fdoshared.ServiceInfoKV{},
 	}
 
-	sessionId, err := h.session.NewSessionEntry(newSessionInst)
+	sessionId, err := h.session.NewTo2SessionEntry(newSessionInst, fdoshared.ConcurrentOnboarding)
+	if errors.Is(err, dbs.ErrOnboardingInProgress) {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Onboarding of the device is already in progress. Retry after it completes or expires.", http.StatusConflict, testcomListener, fdoshared.To2)
+		return
+	}
 	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error saving session...", http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		return
 	}
 
+	logger.Infof("Session started. Token %s", fdoshared.TokenCorrelationID(string(sessionId)))
+
+	if testcomListener != nil {
+		testcomListener.Conf_SaveIssuedNonces(helloDevice.NonceTO2ProveOV, NonceTO2ProveDv)
+		err = h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
+			return
+		}
+	}
+
 	proveOVHdrPayloadBytes, _ := fdoshared.CborCust.Marshal(proveOVHdrPayload)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING {
 		proveOVHdrPayloadBytes = fdoshared.Conf_RandomCborBufferFuzzing(proveOVHdrPayloadBytes)
@@ -182,9 +243,21 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Signed with valid, but unrelated key. Device must detect that it does not match the last OVEntry, and abort
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_OWNER_PUBKEY {
+		randomPrivateKey, randomPublicKey, err := fdoshared.GenerateVoucherKeypair(signatureSgType)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error generating random owner key...", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
+
+		privateKeyInst = randomPrivateKey
+		proveOVHdrUnprotectedHeader.CUPHOwnerPubKey = randomPublicKey
+	}
+
 	helloAck, err := fdoshared.GenerateCoseSignature(proveOVHdrPayloadBytes, fdoshared.ProtectedHeader{}, proveOVHdrUnprotectedHeader, privateKeyInst, signatureSgType)
 	if err != nil {
-		log.Println("HelloDevice60: Error generating cose signature..." + err.Error())
+		logger.Errorf("Error generating cose signature. %s", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error generating cose signature.", http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		return
 	}
@@ -194,6 +267,12 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		helloAck = &fuzzedCoseSignature
 	}
 
+	// Valid and signed, but larger than device MaxDeviceMessageSize. Padding is in unprotected header, so signature is not affected
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_OVERSIZED_PROVEOVHDR {
+		padding := fdoshared.Conf_OversizedPadding(helloDevice.MaxDeviceMessageSize)
+		helloAck.Unprotected.ConfPadding = &padding
+	}
+
 	helloAckBytes, _ := fdoshared.CborCust.Marshal(helloAck)
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING {
@@ -215,9 +294,20 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	contentType, messageType := conf_ProveOVHdrFraming(fdoTestId)
+
 	w.Header().Set("Authorization", sessionIdToken)
-	w.Header().Set("Content-Type", fdoshared.CONTENT_TYPE_CBOR)
-	w.Header().Set("Message-Type", fdoshared.TO2_61_PROVE_OVHDR.ToString())
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	} else {
+		// Stops net/http from sniffing and setting Content-Type
+		w.Header()["Content-Type"] = nil
+	}
+
+	if messageType != "" {
+		w.Header().Set("Message-Type", messageType)
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write(helloAckBytes)
 }
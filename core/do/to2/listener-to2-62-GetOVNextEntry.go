@@ -2,7 +2,6 @@ package to2
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -11,8 +10,9 @@ import (
 )
 
 func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
-	log.Println("GetOVNextEntry62: Receiving...")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_62_GET_OVNEXTENTRY
+	logger := fdoshared.NewMessageLogger(fdoshared.To2, currentCmd, r)
+	logger.Infof("Receiving...")
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 
 	var testcomListener *listenertestsdeps.RequestListenerInst
@@ -24,9 +24,15 @@ func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
-
-	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-		if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+	logger.SetGuid(session.Guid)
+
+	// Device needs all OVEntries to detect owner key mismatch, so it is allowed to continue with 62
+	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_DEVICE_60_BAD_OWNER_PUBKEY {
+		if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_60_OVERSIZED_PROVEOVHDR {
+			testcomListener.To2.PushFail(fmt.Sprintf("Device accepted TO2.ProveOVHdr larger than its MaxDeviceMessageSize %d", session.MaxDeviceMessageSize))
+		} else if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_62_MISSING_OVENTRY && session.PrevCMD == fdoshared.TO2_63_OV_NEXTENTRY {
+			testcomListener.To2.PushFail("Device continued fetching OVEntries after owner returned empty OVEntry, instead of aborting TO2")
+		} else if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
 			testcomListener.To2.PushFail(fmt.Sprintf("Expected TO2 %d. Got %d", testcomListener.To2.ExpectedCmd, currentCmd))
 		} else if testcomListener.To2.CurrentTestIndex != 0 {
 			testcomListener.To2.PushSuccess()
@@ -73,6 +79,13 @@ func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
 
 	ovEntry := session.Voucher.OVEntryArray[getOVNextEntry.GetOVNextEntry]
 
+	ovEntryBytes, _ := fdoshared.CborCust.Marshal(ovEntry)
+	err = fdoshared.Limits.CheckOVEntrySize(len(ovEntryBytes))
+	if err != nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Voucher exceeds resource limits. "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
+		return
+	}
+
 	var ovNextEntry63 = fdoshared.OVNextEntry63{
 		OVEntryNum: getOVNextEntry.GetOVNextEntry,
 		OVEntry:    ovEntry,
@@ -86,6 +99,11 @@ func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
 		ovNextEntry63.OVEntryNum = uint8(fdoshared.NewRandomInt(int(ovNextEntry63.OVEntryNum)+1, 255))
 	}
 
+	// Owner claimed NumOVEntries in ProveOVHdr, but returns nothing for the entry
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_62_MISSING_OVENTRY {
+		ovNextEntry63.OVEntry = fdoshared.CoseSignature{}
+	}
+
 	ovNextEntryBytes, _ := fdoshared.CborCust.Marshal(ovNextEntry63)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_62_BAD_OVNEXTENTRY_PAYLOAD {
 		ovNextEntryBytes = fdoshared.Conf_RandomCborBufferFuzzing(ovNextEntryBytes)
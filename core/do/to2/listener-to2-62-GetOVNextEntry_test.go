@@ -0,0 +1,97 @@
+package to2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	deviceto2 "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func TestGetOVNextEntry62_LongResaleChain(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.WithValue(context.Background(), fdoshared.CFG_ENV_FDO_SERVICE_URL, server.URL)
+	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_INTEROP_ENABLED, false)
+
+	doto2 := NewDoTo2(db, ctx)
+	mux.HandleFunc("/fdo/101/msg/60", doto2.HelloDevice60)
+	mux.HandleFunc("/fdo/101/msg/62", doto2.GetOVNextEntry62)
+
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	// Every resale rotates owner key
+	const rotations = 32
+	entrySgTypes := []fdoshared.DeviceSgType{}
+	for i := 0; i < rotations; i++ {
+		entrySgTypes = append(entrySgTypes, fdoshared.StSECP256R1)
+	}
+
+	credAndVoucher, err := fdodeviceimplementation.NewVirtualDeviceAndMixedVoucher(*credential, fdoshared.StSECP256R1, entrySgTypes, fdoshared.RendezvousInfo{}, testcom.NULL_TEST)
+	if err != nil {
+		t.Fatalf("Failed to generate voucher. %s", err.Error())
+	}
+
+	err = doto2.voucher.Save(credAndVoucher.VoucherDBEntry)
+	if err != nil {
+		t.Fatalf("Failed to save voucher. %s", err.Error())
+	}
+
+	device := deviceto2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: server.URL}, credAndVoucher.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+
+	proveOVHdrPayload61, _, err := device.HelloDevice60(testcom.NULL_TEST)
+	if err != nil {
+		t.Fatalf("Failed HelloDevice60. %s", err.Error())
+	}
+
+	if proveOVHdrPayload61.NumOVEntries != rotations {
+		t.Fatalf("Expected %d OVEntries. Got %d", rotations, proveOVHdrPayload61.NumOVEntries)
+	}
+
+	// Device follows the chain entry by entry, as received from the owner
+	ovEntries, err := device.GetAllOVEntries(proveOVHdrPayload61.NumOVEntries)
+	if err != nil {
+		t.Fatalf("Failed to get OVEntries. %s", err.Error())
+	}
+
+	err = ovEntries.VerifyEntries(proveOVHdrPayload61.OVHeader, proveOVHdrPayload61.HMac)
+	if err != nil {
+		t.Fatalf("Expected device to verify %d entries chain. %s", rotations, err.Error())
+	}
+
+	err = device.VerifyOwnerPubKey(ovEntries)
+	if err != nil {
+		t.Errorf("Expected device to accept key owner signed ProveOVHdr with. %s", err.Error())
+	}
+
+	// Chain cut short by one resale ends with a previous owner
+	err = device.VerifyOwnerPubKey(ovEntries[:rotations-1])
+	if err == nil {
+		t.Errorf("Expected device to reject owner key with truncated chain")
+	}
+
+	// Any earlier owner must not pass as the final owner
+	for i, ovEntry := range ovEntries[:rotations-1] {
+		device.ProveOVHdr61PubKey, _ = ovEntry.GetOVEntryPubKey()
+		err = device.VerifyOwnerPubKey(ovEntries)
+		if err == nil {
+			t.Errorf("Expected device to reject OVEntry %d owner key as final owner", i)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,14 +12,16 @@ import (
 )
 
 func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
-	log.Println("ProveDevice64: Receiving...")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_64_PROVE_DEVICE
+	logger := fdoshared.NewMessageLogger(fdoshared.To2, currentCmd, r)
+	logger.Infof("Receiving...")
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 
 	session, sessionId, authorizationHeader, bodyBytes, testcomListener, err := h.receiveAndVerify(w, r, currentCmd)
 	if err != nil {
 		return
 	}
+	logger.SetGuid(session.Guid)
 
 	privateKeyInst, err := fdoshared.ExtractPrivateKey(session.PrivateKeyDER)
 	if err != nil {
@@ -30,8 +31,20 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 
 	// Test stuff
 
+	if testcomListener != nil && (testcomListener.To1dOwnerMismatchServed || testcomListener.To1dCountersignServed) {
+		testcomListener.To2.PushTestStates(testcomListener.Conf_To1dOwnerMismatchTestStates(true)...)
+		testcomListener.To2.PushTestStates(testcomListener.Conf_To1dCountersignTestStates(true)...)
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
+			return
+		}
+	}
+
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-		if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+		if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_60_BAD_OWNER_PUBKEY {
+			testcomListener.To2.PushFail("Device accepted TO2.ProveOVHdr signed with owner key that does not match the last OVEntry")
+		} else if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
 			testcomListener.To2.PushFail(fmt.Sprintf("Expected TO2 %d. Got %d", testcomListener.To2.ExpectedCmd, currentCmd))
 		} else if testcomListener.To2.CurrentTestIndex != 0 {
 			testcomListener.To2.PushSuccess()
@@ -67,13 +80,26 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if testcomListener != nil && testcomListener.Conf_CheckCanonicalCbor(fdoshared.To2, bodyBytes) {
+		err = h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
+			return
+		}
+	}
+
 	pkType, ok := fdoshared.SgTypeToFdoPkType[session.EASigInfo.SgType]
 	if !ok {
-		log.Println("ProveToRV32: Unknown signature type. ")
+		logger.Errorf("Unknown signature type %d", session.EASigInfo.SgType)
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveDevice64", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
 
+	if session.Voucher.OVDevCertChain == nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveDevice64. Voucher has no device certificate chain", http.StatusBadRequest, testcomListener, fdoshared.To2)
+		return
+	}
+
 	err = fdoshared.VerifyCoseSignatureWithCertificate(proveDevice64, pkType, *session.Voucher.OVDevCertChain)
 	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Error validating cose signature with certificate..."+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
@@ -89,8 +115,24 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify Nonces
+	var nonceMismatch string
 	if !bytes.Equal(eatPayload.EatNonce[:], session.NonceTO2ProveDv61[:]) {
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, fmt.Sprintf("EatNonce is not set to NonceTO2ProveDv61. Expected %s. Got %s", hex.EncodeToString(eatPayload.EatNonce[:]), hex.EncodeToString(session.NonceTO2ProveDv61[:])), http.StatusBadRequest, testcomListener, fdoshared.To2)
+		nonceMismatch = fmt.Sprintf("EatNonce is not set to NonceTO2ProveDv61. Expected %s. Got %s", hex.EncodeToString(session.NonceTO2ProveDv61[:]), hex.EncodeToString(eatPayload.EatNonce[:]))
+	}
+
+	if testcomListener != nil {
+		nonceMismatch = testcomListener.Conf_NonceTO2ProveDvMismatch(eatPayload.EatNonce, session.NonceTO2ProveDv61)
+		if testcomListener.Conf_RecordNonceTO2ProveDv(nonceMismatch) {
+			err = h.listenerDB.Update(testcomListener)
+			if err != nil {
+				listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
+				return
+			}
+		}
+	}
+
+	if nonceMismatch != "" {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, nonceMismatch, http.StatusBadRequest, testcomListener, fdoshared.To2)
 		return
 	}
 
@@ -115,6 +157,32 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 
 	session.NonceTO2SetupDv64 = *proveDevice64.Unprotected.EUPHNonce
 
+	// Test runs install new GUID and new owner key. SetupDevice is then signed by Owner2Key
+	var setupDeviceSigningKey interface{} = privateKeyInst
+	if testcomListener != nil && testcomListener.To2.Running {
+		owner2PrivateKey, owner2PublicKey, owner2PrivateKeyDER, err := h.newOwner2Key(session.SignatureSgType, logger)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "ProveDevice64: Error generating Owner2Key..."+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
+
+		setupDevicePayload.ReplacementGuid = fdoshared.NewFdoGuid()
+
+		secondaryRvUrl := h.ctx.Value(fdoshared.CFG_ENV_SECONDARY_RV_URL)
+		if secondaryRvUrl != nil && secondaryRvUrl.(string) != "" {
+			setupDevicePayload.RendezvousInfo, err = fdoshared.UrlsToRendezvousInfo([]string{secondaryRvUrl.(string)})
+			if err != nil {
+				listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "ProveDevice64: Error generating replacement RVInfo..."+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
+				return
+			}
+		}
+		setupDevicePayload.ReplacementOwner2Key = *owner2PublicKey
+
+		session.ReplacementCredential = &setupDevicePayload
+		session.ReplacementPrivateKeyDER = owner2PrivateKeyDER
+		setupDeviceSigningKey = owner2PrivateKey
+	}
+
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_64_BAD_NONCE_TO2SETUPDV {
 		setupDevicePayload.NonceTO2SetupDv = fdoshared.NewFdoNonce()
 	}
@@ -126,7 +194,7 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Response signature
-	setupDevice, err := fdoshared.GenerateCoseSignature(setupDevicePayloadBytes, fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{}, privateKeyInst, session.SignatureSgType)
+	setupDevice, err := fdoshared.GenerateCoseSignature(setupDevicePayloadBytes, fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{}, setupDeviceSigningKey, session.SignatureSgType)
 	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "ProveDevice64: Error generating setup device signature..."+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		return
@@ -137,6 +205,11 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 		setupDevice = &tempSig
 	}
 
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_64_OVERSIZED_SETUPDEVICE {
+		padding := fdoshared.Conf_OversizedPadding(session.MaxDeviceMessageSize)
+		setupDevice.Unprotected.ConfPadding = &padding
+	}
+
 	setupDeviceBytes, _ := fdoshared.CborCust.Marshal(setupDevice)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_BYTES {
 		setupDeviceBytes = fdoshared.Conf_RandomCborBufferFuzzing(setupDeviceBytes)
@@ -186,3 +259,35 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(setupDeviceBytesEnc)
 }
+
+// newOwner2Key returns the imported owner identity, if its signature type is the one negotiated with the device. Otherwise a new key is generated
+func (h *DoTo2) newOwner2Key(sgType fdoshared.DeviceSgType, logger *fdoshared.MessageLogger) (interface{}, *fdoshared.FdoPublicKey, []byte, error) {
+	ownerIdentity, err := h.identity.Get(fdoshared.IDENTITY_ROLE_OWNER)
+	if err != nil {
+		logger.Warnf("Failed to load owner identity. %s", err.Error())
+	}
+
+	if ownerIdentity != nil && ownerIdentity.SgType == sgType {
+		privateKey, err := ownerIdentity.GetPrivateKey()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		publicKey := ownerIdentity.GetPublicKey()
+		return privateKey, &publicKey, ownerIdentity.PrivateKeyDer, nil
+	} else if ownerIdentity != nil {
+		logger.Infof("Owner identity sgType %d is not negotiated sgType %d. Generating Owner2Key", ownerIdentity.SgType, sgType)
+	}
+
+	privateKey, publicKey, err := fdoshared.GenerateVoucherKeypair(sgType)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	privateKeyDER, err := fdoshared.MarshalPrivateKey(privateKey, sgType)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return privateKey, publicKey, privateKeyDER, nil
+}
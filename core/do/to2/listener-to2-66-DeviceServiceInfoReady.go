@@ -2,7 +2,6 @@ package to2
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,18 +12,22 @@ import (
 const MAX_DEVICE_SERVICE_INFO_SIZE uint16 = 1300
 
 func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request) {
-	log.Println("DeviceServiceInfoReady66: Receiving...")
-
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 
+	logger := fdoshared.NewMessageLogger(fdoshared.To2, currentCmd, r)
+	logger.Infof("Receiving...")
+
 	session, sessionId, authorizationHeader, bodyBytes, testcomListener, err := h.receiveAndDecrypt(w, r, currentCmd)
 	if err != nil {
 		return
 	}
+	logger.SetGuid(session.Guid)
 
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-		if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+		if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_64_OVERSIZED_SETUPDEVICE {
+			testcomListener.To2.PushFail(fmt.Sprintf("Device accepted TO2.SetupDevice larger than its MaxDeviceMessageSize %d", session.MaxDeviceMessageSize))
+		} else if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
 			testcomListener.To2.PushFail(fmt.Sprintf("Expected TO2 %d. Got %d", testcomListener.To2.ExpectedCmd, currentCmd))
 		} else if testcomListener.To2.CurrentTestIndex != 0 {
 			testcomListener.To2.PushSuccess()
@@ -46,6 +49,31 @@ func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Abort after SetupDevice. Device must roll back to its previous credential
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_66_ROLLBACK && session.ReplacementCredential != nil {
+		abandonedGuid := session.ReplacementCredential.ReplacementGuid
+		testcomListener.AbandonedGuid = &abandonedGuid
+
+		err := h.listenerDB.SaveMapping(abandonedGuid, testcomListener.Uuid)
+		if err == nil {
+			err = h.listenerDB.Update(testcomListener)
+		}
+
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
+			return
+		}
+
+		// Session ends with the abort. Device retries TO2 with its previous GUID, which must not find it still in progress
+		err = h.session.DeleteSessionEntry(sessionId, session.Guid)
+		if err != nil {
+			logger.Errorf("Error terminating aborted session. %s", err.Error())
+		}
+
+		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "TO2 aborted after SetupDevice", http.StatusInternalServerError)
+		return
+	}
+
 	// ----- MAIN BODY ----- //
 
 	var deviceServiceInfoReady fdoshared.DeviceServiceInfoReady66
@@ -62,6 +90,16 @@ func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request)
 		maxDeviceServiceInfoSz = *deviceServiceInfoReady.MaxOwnerServiceInfoSz
 	}
 
+	// Conformance
+	var serviceInfoConfig listenertestsdeps.To2ServiceInfoConfig
+	if testcomListener != nil {
+		serviceInfoConfig = testcomListener.To2ServiceInfo
+	}
+
+	if serviceInfoConfig.MaxDeviceServiceInfoSz != 0 {
+		maxDeviceServiceInfoSz = serviceInfoConfig.MaxDeviceServiceInfoSz
+	}
+
 	var ownerServiceInfoReadyPayload = fdoshared.OwnerServiceInfoReady67{
 		MaxDeviceServiceInfoSz: &maxDeviceServiceInfoSz,
 	}
@@ -92,7 +130,15 @@ func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Pre-activated modules go first, so device has them active before any other owner ServiceInfo
+	session.OwnerSIMs = append(serviceInfoConfig.ActivationSIMs(), session.OwnerSIMs...)
+	session.PendingModuleActivations = serviceInfoConfig.ActiveModules
+
 	session.MaxDeviceServiceInfoSz = maxDeviceServiceInfoSz
+	session.OwnerServiceInfoMTU = negotiateMTU(deviceServiceInfoReady.MaxOwnerServiceInfoSz)
+	logger.Debugf("Negotiated OwnerServiceInfo MTU %d", session.OwnerServiceInfoMTU)
+	session.OwnerSIMs = fragmentOwnerSIMs(session.OwnerSIMs, session.OwnerServiceInfoMTU)
+	session.ReplacementHMac = deviceServiceInfoReady.ReplacementHMac
 	session.PrevCMD = fdoshared.TO2_67_OWNER_SERVICE_INFO_READY
 	err = h.session.UpdateSessionEntry(sessionId, *session)
 	if err != nil {
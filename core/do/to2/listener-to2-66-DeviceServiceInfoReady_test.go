@@ -0,0 +1,90 @@
+package to2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	deviceto2 "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+)
+
+func TestDeviceServiceInfoReady66_RollbackEndsSession(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	doto2 := NewDoTo2(db, context.Background())
+
+	guid := fdoshared.NewFdoGuid_FIDO()
+	sessionKey := fdoshared.SessionKeyInfo{
+		ShSe:        []byte("test ShSe"),
+		ContextRand: []byte("test ContextRand"),
+	}
+
+	sessionId, err := doto2.session.NewTo2SessionEntry(dbs.SessionEntry{
+		Protocol:        fdoshared.To2,
+		PrevCMD:         fdoshared.TO2_65_SETUP_DEVICE,
+		Guid:            guid,
+		SessionKey:      sessionKey,
+		CipherSuiteName: fdoshared.CIPHER_A128GCM,
+		ReplacementCredential: &fdoshared.TO2SetupDevicePayload{
+			ReplacementGuid: fdoshared.NewFdoGuid_FIDO(),
+		},
+	}, fdoshared.ONBOARDING_POLICY_REJECT)
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	testcomListener := listenertestsdeps.RequestListenerInst{
+		Uuid: []byte("rollback-listener"),
+		Guid: guid,
+	}
+	testcomListener.To2.Protocol = fdoshared.To2
+	testcomListener.To2.StartNewTestRun()
+	testcomListener.To2.ExpectedCmd = fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY
+	testcomListener.To2.Tests = map[fdoshared.FdoCmd][]testcom.FDOTestID{
+		fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY: {testcom.FIDO_LISTENER_DEVICE_66_ROLLBACK, testcom.FIDO_LISTENER_POSITIVE},
+	}
+
+	err = doto2.listenerDB.Save(testcomListener)
+	if err != nil {
+		t.Fatalf("Failed to save listener. %s", err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/66", doto2.DeviceServiceInfoReady66)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	device := deviceto2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: server.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	device.AuthzHeader = "Bearer " + string(sessionId)
+	device.SessionKey = sessionKey
+
+	_, _, err = device.DeviceServiceInfoReady66(testcom.NULL_TEST)
+	if err == nil {
+		t.Fatalf("Expected owner to abort TO2 after SetupDevice")
+	}
+
+	session, err := doto2.session.GetSessionEntry(sessionId)
+	if err != nil || session != nil {
+		t.Errorf("Expected aborted session to be terminated")
+	}
+
+	// Device rolled back to its previous credential, and starts TO2 again right away
+	_, err = doto2.session.NewTo2SessionEntry(dbs.SessionEntry{
+		Protocol: fdoshared.To2,
+		PrevCMD:  fdoshared.TO2_60_HELLO_DEVICE,
+		Guid:     guid,
+	}, fdoshared.ONBOARDING_POLICY_REJECT)
+	if err != nil {
+		t.Errorf("Expected new TO2 of the rolled back device to be accepted. %s", err.Error())
+	}
+}
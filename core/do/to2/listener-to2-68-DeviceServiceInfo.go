@@ -2,19 +2,139 @@ package to2
 
 import (
 	"fmt"
-	"log"
 	"net/http"
+	"strings"
 
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 )
 
-const MTU_BYTES = 1500
+// OwnerServiceInfo MTU device advertises as MaxOwnerServiceInfoSz in DeviceServiceInfoReady66. Spec default is 1300
+const DEFAULT_MTU_BYTES uint16 = 1300
+const MIN_MTU_BYTES uint16 = 256
+const MAX_MTU_BYTES uint16 = 8192
+
+// negotiateMTU clamps MTU device advertised to MIN_MTU_BYTES - MAX_MTU_BYTES
+func negotiateMTU(maxOwnerServiceInfoSz *uint16) uint16 {
+	if maxOwnerServiceInfoSz == nil || *maxOwnerServiceInfoSz == 0 {
+		return DEFAULT_MTU_BYTES
+	}
+
+	if *maxOwnerServiceInfoSz < MIN_MTU_BYTES {
+		return MIN_MTU_BYTES
+	}
+
+	if *maxOwnerServiceInfoSz > MAX_MTU_BYTES {
+		return MAX_MTU_BYTES
+	}
+
+	return *maxOwnerServiceInfoSz
+}
+
+func simModule(simKey fdoshared.SIM_ID) string {
+	return strings.SplitN(string(simKey), ":", 2)[0]
+}
+
+// nextOwnerSIMsChunk returns number of owner ServiceInfo entries from start, that fit in OwnerServiceInfo69 of mtu bytes.
+// Chunk holds entries of a single module, so device errors are resolved per module. Conformance modules are sent alone.
+// Entry larger than mtu, that fragmentOwnerSIMs could not split, is sent alone
+func nextOwnerSIMsChunk(ownerSims []fdoshared.ServiceInfoKV, start int, mtu uint16) int {
+	if start >= len(ownerSims) {
+		return 0
+	}
+
+	if fdoshared.Conf_IsConformanceSIM(ownerSims[start].ServiceInfoKey) {
+		return 1
+	}
+
+	if mtu == 0 {
+		mtu = DEFAULT_MTU_BYTES
+	}
+
+	module := simModule(ownerSims[start].ServiceInfoKey)
+	chunkLen := 1
+	for start+chunkLen < len(ownerSims) {
+		nextSim := ownerSims[start+chunkLen]
+		if simModule(nextSim.ServiceInfoKey) != module || fdoshared.Conf_IsConformanceSIM(nextSim.ServiceInfoKey) {
+			break
+		}
+
+		if ownerServiceInfoSize(ownerSims[start:start+chunkLen+1]) > int(mtu) {
+			break
+		}
+
+		chunkLen++
+	}
+
+	return chunkLen
+}
+
+// Owner ServiceInfo keys, which value device appends to the previous one of the same key. E.g. fdo_sys:write
+var appendableOwnerSIMs = map[fdoshared.SIM_ID]bool{
+	"fdo_sys:write":     true,
+	"fdo.download:data": true,
+}
+
+func ownerServiceInfoSize(ownerSims []fdoshared.ServiceInfoKV) int {
+	ownerServiceInfoBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OwnerServiceInfo69{
+		IsMoreServiceInfo: true,
+		ServiceInfo:       ownerSims,
+	})
+
+	return len(ownerServiceInfoBytes)
+}
+
+// fragmentOwnerSIMs splits bstr value of appendable entries, that do not fit OwnerServiceInfo69 of mtu bytes, into consecutive entries of the same key that do.
+// Other entries are kept as they are. Every fragment carries at least one byte, so splitting always terminates
+func fragmentOwnerSIMs(ownerSims []fdoshared.ServiceInfoKV, mtu uint16) []fdoshared.ServiceInfoKV {
+	if mtu == 0 {
+		mtu = DEFAULT_MTU_BYTES
+	}
+
+	fragmentedSims := make([]fdoshared.ServiceInfoKV, 0, len(ownerSims))
+	for _, ownerSim := range ownerSims {
+		if !appendableOwnerSIMs[ownerSim.ServiceInfoKey] || ownerServiceInfoSize([]fdoshared.ServiceInfoKV{ownerSim}) <= int(mtu) {
+			fragmentedSims = append(fragmentedSims, ownerSim)
+			continue
+		}
+
+		// Only CBOR bstr can be split
+		var simData []byte
+		if len(ownerSim.ServiceInfoVal) == 0 || ownerSim.ServiceInfoVal[0]>>5 != 2 || fdoshared.CborCust.Unmarshal(ownerSim.ServiceInfoVal, &simData) != nil {
+			fragmentedSims = append(fragmentedSims, ownerSim)
+			continue
+		}
+
+		// Size with empty value. Headers of the bstr and of its CBOR encoded wrapping grow by up to 2 bytes each below 64KB
+		emptySim := fdoshared.ServiceInfoKV{ServiceInfoKey: ownerSim.ServiceInfoKey, ServiceInfoVal: []byte{0x40}}
+		maxFragmentLen := int(mtu) - ownerServiceInfoSize([]fdoshared.ServiceInfoKV{emptySim}) - 4
+		if maxFragmentLen < 1 {
+			fragmentedSims = append(fragmentedSims, ownerSim)
+			continue
+		}
+
+		for offset := 0; offset < len(simData); offset += maxFragmentLen {
+			end := offset + maxFragmentLen
+			if end > len(simData) {
+				end = len(simData)
+			}
+
+			fragmentedSims = append(fragmentedSims, fdoshared.ServiceInfoKV{
+				ServiceInfoKey: ownerSim.ServiceInfoKey,
+				ServiceInfoVal: fdoshared.BytesToCborBytes(simData[offset:end]),
+			})
+		}
+	}
+
+	return fragmentedSims
+}
 
 func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
-	log.Println("DeviceServiceInfo68: Receiving...")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_68_DEVICE_SERVICE_INFO
+	logger := fdoshared.NewMessageLogger(fdoshared.To2, currentCmd, r)
+	logger.Infof("Receiving...")
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 
 	var testcomListener *listenertestsdeps.RequestListenerInst
@@ -26,10 +146,32 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
+	logger.SetGuid(session.Guid)
+
+	// Edge test is evaluated only once it was served. Binary value test, once echo data was served
+	edgeTestPending := session.PrevCMD == fdoshared.TO2_69_OWNER_SERVICE_INFO && session.PendingServiceInfoEdgeTest != ""
 
-	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
+	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) && !edgeTestPending {
 		if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
 			testcomListener.To2.PushFail(fmt.Sprintf("Expected TO2 %d. Got %d", testcomListener.To2.ExpectedCmd, currentCmd))
+		} else if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_68_UNKNOWN_SIM && session.PrevCMD != fdoshared.TO2_69_OWNER_SERVICE_INFO {
+			// Device must ignore unknown module and continue in the same session
+			testcomListener.To2.PushFail("Device restarted onboarding after receiving unknown ServiceInfo module")
+		} else if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_68_BINARY_SIM && session.PrevCMD != fdoshared.TO2_69_OWNER_SERVICE_INFO {
+			testcomListener.To2.PushFail("Device restarted onboarding after receiving binary ServiceInfo value")
+		} else if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_68_BINARY_SIM {
+			err := conf_CheckBinaryEcho(bodyBytes)
+			if err != nil {
+				testcomListener.To2.PushFail(err.Error())
+			} else {
+				testcomListener.To2.PushSuccess()
+			}
+		} else if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY && session.PrevCMD != fdoshared.TO2_69_OWNER_SERVICE_INFO {
+			testcomListener.To2.PushFail("Device restarted onboarding after receiving IsMoreServiceInfo with empty ServiceInfo. Expected DeviceServiceInfo in the same session")
+		} else if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_68_DONE_WITH_MORE_SERVICEINFO && session.PrevCMD == fdoshared.TO2_69_OWNER_SERVICE_INFO {
+			testcomListener.To2.PushFail("Device continued after OwnerServiceInfo with both IsDone and IsMoreServiceInfo set. Expected device to reject it")
+		} else if testcomListener.To2.GetLastTestID() == testcom.FIDO_LISTENER_DEVICE_68_WRONG_ENC_CONTEXT && session.PrevCMD == fdoshared.TO2_69_OWNER_SERVICE_INFO {
+			testcomListener.To2.PushFail("Device continued after OwnerServiceInfo encrypted with wrong COSE context. Expected device to reject it")
 		} else if testcomListener.To2.CurrentTestIndex != 0 {
 			testcomListener.To2.PushSuccess()
 		}
@@ -59,6 +201,25 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Conformance. Device must follow owner MaxDeviceServiceInfoSz, and respond to pre-activated modules
+	if testcomListener != nil && testcomListener.To2ServiceInfo.MaxDeviceServiceInfoSz != 0 && len(bodyBytes) > int(session.MaxDeviceServiceInfoSz) {
+		session.ExceededMaxDeviceServiceInfoSz = true
+	}
+
+	session.PendingModuleActivations = listenertestsdeps.Conf_ResolveModuleActivations(session.PendingModuleActivations, deviceServiceInfo.ServiceInfo)
+
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_68_UNKNOWN_SIM {
+		session.OwnerSIMs = append([]fdoshared.ServiceInfoKV{fdoshared.Conf_NewUnknownServiceInfoKV()}, session.OwnerSIMs...)
+	}
+
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_68_BINARY_SIM {
+		session.OwnerSIMs = append(fdoshared.Conf_NewBinaryServiceInfoKVs(), session.OwnerSIMs...)
+	}
+
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_68_DONE_WITH_MORE_SERVICEINFO || fdoTestId == testcom.FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY || fdoTestId == testcom.FIDO_LISTENER_DEVICE_68_BINARY_SIM {
+		session.PendingServiceInfoEdgeTest = fdoTestId
+	}
+
 	ownerServiceInfo := fdoshared.OwnerServiceInfo69{}
 
 	if deviceServiceInfo.IsMoreServiceInfo {
@@ -66,21 +227,49 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 		ownerServiceInfo.IsDone = false
 		ownerServiceInfo.IsMoreServiceInfo = false
 
+		err = fdoshared.Limits.CheckDeviceSIMs(append(append([]fdoshared.ServiceInfoKV{}, session.DeviceSIMs...), deviceServiceInfo.ServiceInfo...))
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "DeviceServiceInfo exceeds resource limits. "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
+			return
+		}
+
 		session.DeviceSIMs = append(session.DeviceSIMs, deviceServiceInfo.ServiceInfo...)
+	} else if session.PendingServiceInfoEdgeTest == testcom.FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY {
+		// Legal. Owner module is not consumed, device must continue with DeviceServiceInfo
+		ownerServiceInfo = conf_ServiceInfoEdgeResponse(session.PendingServiceInfoEdgeTest, ownerServiceInfo)
+		session.PendingServiceInfoEdgeTest = ""
 	} else {
 		// Owner is now sending its service info
-		if session.OwnerSIMsSendCounter == 0 {
+		isFirstOwnerServiceInfo := session.OwnerSIMsSendCounter == 0
+
+		ownerSIMRecovery := resolveOwnerSIMError(session, deviceServiceInfo.ServiceInfo)
+		if ownerSIMRecovery != "" {
+			logger.Warnf("%s", ownerSIMRecovery)
+		}
+
+		if isFirstOwnerServiceInfo {
+			// Devmod is only recorded while TO2 test run is running, so plain onboarding does not add to completed runs
+			if testcomListener != nil && testcomListener.To2.PushTestStates(conf_DevmodTestStates(session.DeviceSIMs)...) {
+				err = h.listenerDB.Update(testcomListener)
+				if err != nil {
+					listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result! "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
+					return
+				}
+			}
+
 			resultSims, err := ValidateDeviceSIMs(session.Guid, session.DeviceSIMs)
 			if err != nil {
-				log.Println("DeviceServiceInfo68: Error validating device sims: " + err.Error())
+				logger.Errorf("Error validating device sims. %s", err.Error())
 				fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "DeviceServiceInfo68: Error validating device sims: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
 
-			log.Println("DeviceServiceInfo68: Validated device sims: ", *resultSims.SIM_DEVMOD_ARCH, *resultSims.SIM_DEVMOD_DEVICE, resultSims.SIM_DEVMOD_OS)
+			logger.Infof("Validated device sims. %s %s %v", *resultSims.SIM_DEVMOD_ARCH, *resultSims.SIM_DEVMOD_DEVICE, resultSims.SIM_DEVMOD_OS)
 		}
 
-		if int(session.OwnerSIMsSendCounter+1) >= len(session.OwnerSIMs) {
+		chunkLen := nextOwnerSIMsChunk(session.OwnerSIMs, int(session.OwnerSIMsSendCounter), session.OwnerServiceInfoMTU)
+
+		if int(session.OwnerSIMsSendCounter)+chunkLen >= len(session.OwnerSIMs) {
 			ownerServiceInfo.IsDone = true
 			ownerServiceInfo.IsMoreServiceInfo = false
 
@@ -93,11 +282,33 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 
 		ownerServiceInfo.ServiceInfo = []fdoshared.ServiceInfoKV{}
 
-		if int(session.OwnerSIMsSendCounter)+1 <= len(session.OwnerSIMs) {
-			ownerServiceInfo.ServiceInfo = append(ownerServiceInfo.ServiceInfo, session.OwnerSIMs[session.OwnerSIMsSendCounter])
+		if chunkLen != 0 {
+			ownerServiceInfo.ServiceInfo = append(ownerServiceInfo.ServiceInfo, session.OwnerSIMs[session.OwnerSIMsSendCounter:int(session.OwnerSIMsSendCounter)+chunkLen]...)
 		}
 
-		session.OwnerSIMsSendCounter = session.OwnerSIMsSendCounter + 1
+		// Device must come back with another 68 to prove it handled conformance module
+		if len(ownerServiceInfo.ServiceInfo) == 1 && fdoshared.Conf_IsConformanceSIM(ownerServiceInfo.ServiceInfo[0].ServiceInfoKey) {
+			ownerServiceInfo.IsDone = false
+			session.OwnerSIMsFinishedSending = false
+
+			// Binary value test is evaluated on the echo in the next DeviceServiceInfo
+			if session.PendingServiceInfoEdgeTest == testcom.FIDO_LISTENER_DEVICE_68_BINARY_SIM && ownerServiceInfo.ServiceInfo[0].ServiceInfoKey == fdoshared.CONF_ECHO_SIM_DATA {
+				session.PendingServiceInfoEdgeTest = ""
+			}
+		}
+
+		// Counter passes the end once IsDone was sent
+		if chunkLen == 0 {
+			chunkLen = 1
+		}
+
+		session.OwnerSIMsSendCounter = session.OwnerSIMsSendCounter + uint16(chunkLen)
+		session.OwnerSIMsLastChunkLen = uint16(chunkLen)
+
+		if session.PendingServiceInfoEdgeTest == testcom.FIDO_LISTENER_DEVICE_68_DONE_WITH_MORE_SERVICEINFO {
+			ownerServiceInfo = conf_ServiceInfoEdgeResponse(session.PendingServiceInfoEdgeTest, ownerServiceInfo)
+			session.PendingServiceInfoEdgeTest = ""
+		}
 	}
 
 	ownerServiceInfoBytes, _ := fdoshared.CborCust.Marshal(ownerServiceInfo)
@@ -105,15 +316,23 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 	// ----- MAIN BODY ENDS ----- //
 	ownerServiceInfoEncBytes, err := fdoshared.AddEncryptionWrapping(ownerServiceInfoBytes, session.SessionKey, session.CipherSuiteName)
 	if err != nil {
-		log.Println("DeviceServiceInfo68: Error encrypting..." + err.Error())
+		logger.Errorf("Error encrypting. %s", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal server error!", http.StatusInternalServerError)
 		return
 	}
 
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_68_WRONG_ENC_CONTEXT {
+		ownerServiceInfoEncBytes, err = fdoshared.Conf_AddWrappingWrongContext(ownerServiceInfoBytes, session.SessionKey, session.CipherSuiteName)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "DeviceServiceInfo68: Error encrypting..."+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
+	}
+
 	session.PrevCMD = fdoshared.TO2_69_OWNER_SERVICE_INFO
 	err = h.session.UpdateSessionEntry(sessionId, *session)
 	if err != nil {
-		log.Println("DeviceServiceInfo68: Error saving session..." + err.Error())
+		logger.Errorf("Error saving session. %s", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal server error!", http.StatusInternalServerError)
 		return
 	}
@@ -138,3 +357,75 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(ownerServiceInfoEncBytes)
 }
+
+// Conformance. OwnerServiceInfo completion edges.
+// Legal: IsDone with ServiceInfo, as on the last owner module, and IsMoreServiceInfo with empty ServiceInfo.
+// Invalid: IsDone together with IsMoreServiceInfo
+func conf_ServiceInfoEdgeResponse(edgeTestId testcom.FDOTestID, ownerServiceInfo fdoshared.OwnerServiceInfo69) fdoshared.OwnerServiceInfo69 {
+	switch edgeTestId {
+	case testcom.FIDO_LISTENER_DEVICE_68_DONE_WITH_MORE_SERVICEINFO:
+		ownerServiceInfo.IsDone = true
+		ownerServiceInfo.IsMoreServiceInfo = true
+	case testcom.FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY:
+		ownerServiceInfo.IsDone = false
+		ownerServiceInfo.IsMoreServiceInfo = true
+		ownerServiceInfo.ServiceInfo = []fdoshared.ServiceInfoKV{}
+	}
+
+	return ownerServiceInfo
+}
+
+// conf_CheckBinaryEcho checks DeviceServiceInfo that follows OwnerServiceInfo with echo data. Device must echo binary value byte accurate
+func conf_CheckBinaryEcho(bodyBytes []byte) error {
+	var deviceServiceInfo fdoshared.DeviceServiceInfo68
+	err := fdoshared.CborCust.Unmarshal(bodyBytes, &deviceServiceInfo)
+	if err != nil {
+		return fmt.Errorf("Failed to decode DeviceServiceInfo with echoed binary value. %s", err.Error())
+	}
+
+	return fdoshared.Conf_CheckBinaryServiceInfoEcho(deviceServiceInfo.ServiceInfo)
+}
+
+// Owner ServiceInfo entry is resent this many times after device reports modname:error for it
+const OWNER_SIM_MAX_RETRIES uint8 = 1
+
+// resolveOwnerSIMError applies FSIM error recovery policy. Device reports failure of the last owner chunk with modname:error.
+// The chunk is resent up to OWNER_SIM_MAX_RETRIES times, then the rest of the module is skipped and the owner continues
+// with the next module. TO2 is not aborted, device decides whether it can onboard without the module.
+// Returns description of the recovery, empty when device did not report an error
+func resolveOwnerSIMError(session *dbs.SessionEntry, deviceSims []fdoshared.ServiceInfoKV) string {
+	if session.OwnerSIMsSendCounter == 0 || int(session.OwnerSIMsSendCounter) > len(session.OwnerSIMs) {
+		return ""
+	}
+
+	lastIndex := session.OwnerSIMsSendCounter - 1
+	lastModule := simModule(session.OwnerSIMs[lastIndex].ServiceInfoKey)
+
+	// Chunk holds entries of a single module. Sessions without chunk length sent one entry at a time
+	lastChunkLen := session.OwnerSIMsLastChunkLen
+	if lastChunkLen == 0 || lastChunkLen > session.OwnerSIMsSendCounter {
+		lastChunkLen = 1
+	}
+
+	deviceSimsList := fdoshared.SIMS(deviceSims)
+	deviceSimIds := deviceSimsList.GetSimIDs()
+	if !deviceSimIds.Contains(fdoshared.SIM_ID(lastModule + ":error")) {
+		session.OwnerSIMRetries = 0
+		return ""
+	}
+
+	if session.OwnerSIMRetries < OWNER_SIM_MAX_RETRIES {
+		session.OwnerSIMRetries++
+		session.OwnerSIMsSendCounter = session.OwnerSIMsSendCounter - lastChunkLen
+		session.OwnerSIMsFinishedSending = false
+
+		return fmt.Sprintf("Device reported error for %s. Resending, retry %d", session.OwnerSIMs[lastIndex].ServiceInfoKey, session.OwnerSIMRetries)
+	}
+
+	session.OwnerSIMRetries = 0
+	for int(session.OwnerSIMsSendCounter) < len(session.OwnerSIMs) && simModule(session.OwnerSIMs[session.OwnerSIMsSendCounter].ServiceInfoKey) == lastModule {
+		session.OwnerSIMsSendCounter++
+	}
+
+	return fmt.Sprintf("Device reported error for %s after %d retries. Skipping module %s", session.OwnerSIMs[lastIndex].ServiceInfoKey, OWNER_SIM_MAX_RETRIES, lastModule)
+}
@@ -0,0 +1,511 @@
+package to2
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	deviceto2 "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+)
+
+func TestConfCheckBinaryEcho(t *testing.T) {
+	echoSims := fdoshared.Conf_NewBinaryServiceInfoKVs()
+
+	echoedBytes, _ := fdoshared.CborCust.Marshal(fdoshared.DeviceServiceInfo68{ServiceInfo: echoSims[1:]})
+	if err := conf_CheckBinaryEcho(echoedBytes); err != nil {
+		t.Errorf("Expected byte accurate echo to pass. %s", err.Error())
+	}
+
+	missingBytes, _ := fdoshared.CborCust.Marshal(fdoshared.DeviceServiceInfo68{ServiceInfo: []fdoshared.ServiceInfoKV{}})
+	if err := conf_CheckBinaryEcho(missingBytes); err == nil {
+		t.Errorf("Expected missing echo to fail")
+	}
+
+	alteredBytes, _ := fdoshared.CborCust.Marshal(fdoshared.DeviceServiceInfo68{ServiceInfo: []fdoshared.ServiceInfoKV{
+		{ServiceInfoKey: fdoshared.CONF_ECHO_SIM_DATA, ServiceInfoVal: fdoshared.BytesToCborBytes([]byte{0x00, 0x01})},
+	}})
+	if err := conf_CheckBinaryEcho(alteredBytes); err == nil {
+		t.Errorf("Expected altered echo to fail")
+	}
+}
+
+func TestConfServiceInfoEdgeResponse(t *testing.T) {
+	ownerServiceInfo := fdoshared.OwnerServiceInfo69{
+		IsDone: true,
+		ServiceInfo: []fdoshared.ServiceInfoKV{
+			{ServiceInfoKey: "fdo_sys:active", ServiceInfoVal: []byte{0xf5}},
+		},
+	}
+
+	response := conf_ServiceInfoEdgeResponse(testcom.FIDO_LISTENER_DEVICE_68_DONE_WITH_MORE_SERVICEINFO, ownerServiceInfo)
+	if !response.IsDone || !response.IsMoreServiceInfo || len(response.ServiceInfo) != 1 {
+		t.Errorf("Expected IsDone and IsMoreServiceInfo with ServiceInfo kept. Got %v", response)
+	}
+
+	response = conf_ServiceInfoEdgeResponse(testcom.FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY, ownerServiceInfo)
+	if response.IsDone || !response.IsMoreServiceInfo || response.ServiceInfo == nil || len(response.ServiceInfo) != 0 {
+		t.Errorf("Expected IsMoreServiceInfo with empty ServiceInfo. Got %v", response)
+	}
+
+	response = conf_ServiceInfoEdgeResponse(testcom.FIDO_LISTENER_POSITIVE, ownerServiceInfo)
+	if !response.IsDone || response.IsMoreServiceInfo || len(response.ServiceInfo) != 1 {
+		t.Errorf("Expected response to be unchanged for non edge test. Got %v", response)
+	}
+}
+
+func TestDeviceServiceInfo68_MoreServiceInfoEmptyEdge(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	doto2 := NewDoTo2(db, context.Background())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/68", doto2.DeviceServiceInfo68)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	sessionKey := fdoshared.SessionKeyInfo{
+		ShSe:        []byte("test ShSe"),
+		ContextRand: []byte("test ContextRand"),
+	}
+
+	deviceSims := append(fdoshared.GetDeviceOSSims(),
+		fdoshared.ServiceInfoKV{ServiceInfoKey: fdoshared.SIM_DEVMOD_NUMMODULES, ServiceInfoVal: fdoshared.UintToCborBytes(1)},
+		fdoshared.ServiceInfoKV{ServiceInfoKey: fdoshared.SIM_DEVMOD_MODULES, ServiceInfoVal: fdoshared.SimsListToBytes(fdoshared.SIM_IDS{"devmod"})},
+	)
+
+	// Runs edge test, and returns its result. Device restarting onboarding is sent in a new session
+	runEdgeTest := func(restartOnboarding bool) testcom.FDOTestState {
+		guid := fdoshared.NewFdoGuid_FIDO()
+		newSession := func() []byte {
+			sessionId, err := doto2.session.NewTo2SessionEntry(dbs.SessionEntry{
+				Protocol:        fdoshared.To2,
+				PrevCMD:         fdoshared.TO2_67_OWNER_SERVICE_INFO_READY,
+				Guid:            guid,
+				SessionKey:      sessionKey,
+				CipherSuiteName: fdoshared.CIPHER_A128GCM,
+				DeviceSIMs:      deviceSims,
+				OwnerSIMs:       []fdoshared.ServiceInfoKV{{ServiceInfoKey: "fdo_sys:active", ServiceInfoVal: []byte{0xf5}}},
+			}, fdoshared.ONBOARDING_POLICY_REJECT)
+			if err != nil {
+				t.Fatalf("Failed to create session. %s", err.Error())
+			}
+
+			return sessionId
+		}
+
+		testcomListener := listenertestsdeps.RequestListenerInst{
+			Uuid: guid[:],
+			Guid: guid,
+		}
+		testcomListener.To2.Protocol = fdoshared.To2
+		testcomListener.To2.StartNewTestRun()
+		testcomListener.To2.ExpectedCmd = fdoshared.TO2_68_DEVICE_SERVICE_INFO
+		testcomListener.To2.Tests = map[fdoshared.FdoCmd][]testcom.FDOTestID{
+			fdoshared.TO2_68_DEVICE_SERVICE_INFO: {testcom.FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY, testcom.FIDO_LISTENER_POSITIVE},
+		}
+
+		err := doto2.listenerDB.Save(testcomListener)
+		if err != nil {
+			t.Fatalf("Failed to save listener. %s", err.Error())
+		}
+
+		sessionId := newSession()
+		device := deviceto2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: server.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+		device.AuthzHeader = "Bearer " + string(sessionId)
+		device.SessionKey = sessionKey
+
+		lastDeviceServiceInfo := fdoshared.DeviceServiceInfo68{ServiceInfo: []fdoshared.ServiceInfoKV{}}
+		ownerServiceInfo, _, err := device.DeviceServiceInfo68(lastDeviceServiceInfo, testcom.NULL_TEST)
+		if err != nil {
+			t.Fatalf("Failed to send DeviceServiceInfo. %s", err.Error())
+		}
+
+		if ownerServiceInfo.IsDone || !ownerServiceInfo.IsMoreServiceInfo || len(ownerServiceInfo.ServiceInfo) != 0 {
+			t.Fatalf("Expected IsMoreServiceInfo with empty ServiceInfo. Got %+v", ownerServiceInfo)
+		}
+
+		if restartOnboarding {
+			err = doto2.session.DeleteSessionEntry(sessionId, guid)
+			if err != nil {
+				t.Fatalf("Failed to delete session. %s", err.Error())
+			}
+
+			device.AuthzHeader = "Bearer " + string(newSession())
+		}
+
+		ownerServiceInfo, _, err = device.DeviceServiceInfo68(lastDeviceServiceInfo, testcom.NULL_TEST)
+		if err != nil {
+			t.Fatalf("Failed to send DeviceServiceInfo after edge response. %s", err.Error())
+		}
+
+		if !restartOnboarding && (!ownerServiceInfo.IsDone || len(ownerServiceInfo.ServiceInfo) != 1) {
+			t.Errorf("Expected owner module to be sent after edge response. Got %+v", ownerServiceInfo)
+		}
+
+		result, err := doto2.listenerDB.Get(testcomListener.Uuid)
+		if err != nil {
+			t.Fatalf("Failed to get listener. %s", err.Error())
+		}
+
+		for _, testState := range result.To2.CurrentTestRun.TestRuns {
+			if testState.TestID == testcom.FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY {
+				return testState
+			}
+		}
+
+		t.Fatalf("Expected edge test result to be recorded")
+		return testcom.FDOTestState{}
+	}
+
+	if testState := runEdgeTest(false); !testState.Passed {
+		t.Errorf("Expected device continuing in the same session to pass. %s", testState.Error)
+	}
+
+	if testState := runEdgeTest(true); testState.Passed {
+		t.Errorf("Expected device restarting onboarding to fail")
+	}
+}
+
+func TestDeviceServiceInfo68_DeviceSIMsLimit(t *testing.T) {
+	prevLimits := fdoshared.Limits
+	defer func() { fdoshared.Limits = prevLimits }()
+
+	fdoshared.Limits.MaxDeviceSIMs = 10
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	doto2 := NewDoTo2(db, context.Background())
+
+	sessionKey := fdoshared.SessionKeyInfo{
+		ShSe:        []byte("test ShSe"),
+		ContextRand: []byte("test ContextRand"),
+	}
+
+	sessionId, err := doto2.session.NewTo2SessionEntry(dbs.SessionEntry{
+		Protocol:        fdoshared.To2,
+		PrevCMD:         fdoshared.TO2_67_OWNER_SERVICE_INFO_READY,
+		Guid:            fdoshared.NewFdoGuid_FIDO(),
+		SessionKey:      sessionKey,
+		CipherSuiteName: fdoshared.CIPHER_A128GCM,
+	}, fdoshared.ONBOARDING_POLICY_REJECT)
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/68", doto2.DeviceServiceInfo68)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	device := deviceto2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: server.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	device.AuthzHeader = "Bearer " + string(sessionId)
+	device.SessionKey = sessionKey
+
+	fragment := fdoshared.DeviceServiceInfo68{
+		ServiceInfo: []fdoshared.ServiceInfoKV{
+			{ServiceInfoKey: "fido_conformance_flood:data", ServiceInfoVal: []byte{0x01}},
+			{ServiceInfoKey: "fido_conformance_flood:data", ServiceInfoVal: []byte{0x02}},
+			{ServiceInfoKey: "fido_conformance_flood:data", ServiceInfoVal: []byte{0x03}},
+		},
+		IsMoreServiceInfo: true,
+	}
+
+	// 3 fragments of 3 entries fit the limit of 10. The 4th exceeds it
+	for i := 0; i < 3; i++ {
+		_, _, err = device.DeviceServiceInfo68(fragment, testcom.NULL_TEST)
+		if err != nil {
+			t.Fatalf("Expected fragment %d within limit to be accepted. %s", i, err.Error())
+		}
+	}
+
+	_, _, err = device.DeviceServiceInfo68(fragment, testcom.NULL_TEST)
+	if err == nil {
+		t.Fatalf("Expected fragment exceeding limit to be rejected")
+	}
+
+	session, err := doto2.session.GetSessionEntry(sessionId)
+	if err != nil {
+		t.Fatalf("Failed to get session. %s", err.Error())
+	}
+
+	if len(session.DeviceSIMs) != 9 {
+		t.Errorf("Expected rejected fragment to not be accumulated. Got %d entries", len(session.DeviceSIMs))
+	}
+}
+
+func TestDeviceServiceInfo68_CCMSession(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	doto2 := NewDoTo2(db, context.Background())
+
+	sessionKey := fdoshared.SessionKeyInfo{
+		ShSe:        []byte("test ShSe"),
+		ContextRand: []byte("test ContextRand"),
+	}
+
+	for _, cipherSuite := range []fdoshared.CipherSuiteName{fdoshared.CIPHER_AES_CCM_16_128_128, fdoshared.CIPHER_AES_CCM_16_128_256} {
+		sessionId, err := doto2.session.NewTo2SessionEntry(dbs.SessionEntry{
+			Protocol:        fdoshared.To2,
+			PrevCMD:         fdoshared.TO2_67_OWNER_SERVICE_INFO_READY,
+			Guid:            fdoshared.NewFdoGuid_FIDO(),
+			SessionKey:      sessionKey,
+			CipherSuiteName: cipherSuite,
+		}, fdoshared.ONBOARDING_POLICY_REJECT)
+		if err != nil {
+			t.Fatalf("Failed to create session. %s", err.Error())
+		}
+
+		deviceServiceInfoBytes, _ := fdoshared.CborCust.Marshal(fdoshared.DeviceServiceInfo68{
+			ServiceInfo:       []fdoshared.ServiceInfoKV{{ServiceInfoKey: "devmod:active", ServiceInfoVal: fdoshared.CBOR_TRUE}},
+			IsMoreServiceInfo: true,
+		})
+		deviceServiceInfoEncBytes, err := fdoshared.AddEncryptionWrapping(deviceServiceInfoBytes, sessionKey, cipherSuite)
+		if err != nil {
+			t.Fatalf("%d: Failed to encrypt. %s", cipherSuite, err.Error())
+		}
+
+		r := httptest.NewRequest(http.MethodPost, "/fdo/101/msg/68", bytes.NewReader(deviceServiceInfoEncBytes))
+		r.Header.Set("Content-Type", fdoshared.CONTENT_TYPE_CBOR)
+		r.Header.Set("Authorization", "Bearer "+string(sessionId))
+		w := httptest.NewRecorder()
+
+		doto2.DeviceServiceInfo68(w, r)
+
+		if w.Code != http.StatusOK || w.Header().Get("Message-Type") != fdoshared.TO2_69_OWNER_SERVICE_INFO.ToString() {
+			t.Errorf("%d: Expected OwnerServiceInfo69. Got HTTP %d, Message-Type %s", cipherSuite, w.Code, w.Header().Get("Message-Type"))
+			continue
+		}
+
+		ownerServiceInfoBytes, err := fdoshared.RemoveEncryptionWrapping(w.Body.Bytes(), sessionKey, cipherSuite)
+		if err != nil {
+			t.Errorf("%d: Expected response encrypted with session cipher suite. %s", cipherSuite, err.Error())
+			continue
+		}
+
+		var ownerServiceInfo fdoshared.OwnerServiceInfo69
+		err = fdoshared.CborCust.Unmarshal(ownerServiceInfoBytes, &ownerServiceInfo)
+		if err != nil {
+			t.Errorf("%d: Failed to decode OwnerServiceInfo69. %s", cipherSuite, err.Error())
+		}
+	}
+}
+
+func TestResolveOwnerSIMError(t *testing.T) {
+	session := dbs.SessionEntry{
+		OwnerSIMs: []fdoshared.ServiceInfoKV{
+			{ServiceInfoKey: "fdo_sys:active", ServiceInfoVal: fdoshared.CBOR_TRUE},
+			{ServiceInfoKey: "fdo_sys:filedesc", ServiceInfoVal: []byte{0x01}},
+			{ServiceInfoKey: "fdo_sys:write", ServiceInfoVal: []byte{0x02}},
+			{ServiceInfoKey: "fido_alliance:dev_conformance", ServiceInfoVal: []byte{0x03}},
+		},
+		OwnerSIMsSendCounter: 2,
+	}
+
+	fsimError := []fdoshared.ServiceInfoKV{{ServiceInfoKey: "fdo_sys:error", ServiceInfoVal: fdoshared.StringToCborBytes("write failed")}}
+
+	if resolveOwnerSIMError(&session, []fdoshared.ServiceInfoKV{}) != "" || session.OwnerSIMsSendCounter != 2 {
+		t.Errorf("Expected owner to advance when device reported no error. Counter %d", session.OwnerSIMsSendCounter)
+	}
+
+	// First error resends fdo_sys:filedesc
+	if resolveOwnerSIMError(&session, fsimError) == "" || session.OwnerSIMsSendCounter != 1 || session.OwnerSIMRetries != 1 {
+		t.Errorf("Expected owner to resend failed entry. Counter %d, retries %d", session.OwnerSIMsSendCounter, session.OwnerSIMRetries)
+	}
+
+	// Resent entry fails again. Rest of fdo_sys is skipped
+	session.OwnerSIMsSendCounter = 2
+	if resolveOwnerSIMError(&session, fsimError) == "" || session.OwnerSIMsSendCounter != 3 || session.OwnerSIMRetries != 0 {
+		t.Errorf("Expected owner to skip to next module. Counter %d, retries %d", session.OwnerSIMsSendCounter, session.OwnerSIMRetries)
+	}
+
+	// Chunk of fdo_sys:filedesc and fdo_sys:write is resent as a whole
+	session.OwnerSIMsSendCounter = 3
+	session.OwnerSIMsLastChunkLen = 2
+	if resolveOwnerSIMError(&session, fsimError) == "" || session.OwnerSIMsSendCounter != 1 || session.OwnerSIMRetries != 1 {
+		t.Errorf("Expected owner to resend failed chunk. Counter %d, retries %d", session.OwnerSIMsSendCounter, session.OwnerSIMRetries)
+	}
+	session.OwnerSIMRetries = 0
+	session.OwnerSIMsLastChunkLen = 0
+
+	// Error of another module is not for the last sent entry
+	session.OwnerSIMsSendCounter = 4
+	if resolveOwnerSIMError(&session, fsimError) != "" || session.OwnerSIMsSendCounter != 4 {
+		t.Errorf("Expected error of another module to be ignored. Counter %d", session.OwnerSIMsSendCounter)
+	}
+}
+
+func TestNegotiateMTU(t *testing.T) {
+	for advertised, expected := range map[uint16]uint16{
+		0:     DEFAULT_MTU_BYTES,
+		100:   MIN_MTU_BYTES,
+		512:   512,
+		65535: MAX_MTU_BYTES,
+	} {
+		advertised := advertised
+		if mtu := negotiateMTU(&advertised); mtu != expected {
+			t.Errorf("Expected MTU %d for advertised %d. Got %d", expected, advertised, mtu)
+		}
+	}
+
+	if negotiateMTU(nil) != DEFAULT_MTU_BYTES {
+		t.Errorf("Expected default MTU when device did not advertise one")
+	}
+}
+
+// Sends owner ServiceInfo to device of the given MTU. Returns number and max size of OwnerServiceInfo69
+func sendOwnerSIMsWithMTU(t *testing.T, ownerSims []fdoshared.ServiceInfoKV, mtu uint16) (int, int) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	doto2 := NewDoTo2(db, context.Background())
+
+	sessionKey := fdoshared.SessionKeyInfo{
+		ShSe:        []byte("test ShSe"),
+		ContextRand: []byte("test ContextRand"),
+	}
+
+	sessionId, err := doto2.session.NewTo2SessionEntry(dbs.SessionEntry{
+		Protocol:        fdoshared.To2,
+		PrevCMD:         fdoshared.TO2_67_OWNER_SERVICE_INFO_READY,
+		Guid:            fdoshared.NewFdoGuid_FIDO(),
+		SessionKey:      sessionKey,
+		CipherSuiteName: fdoshared.CIPHER_A128GCM,
+		DeviceSIMs: append(fdoshared.GetDeviceOSSims(),
+			fdoshared.ServiceInfoKV{ServiceInfoKey: fdoshared.SIM_DEVMOD_NUMMODULES, ServiceInfoVal: fdoshared.UintToCborBytes(1)},
+			fdoshared.ServiceInfoKV{ServiceInfoKey: fdoshared.SIM_DEVMOD_MODULES, ServiceInfoVal: fdoshared.SimsListToBytes(fdoshared.SIM_IDS{"devmod"})},
+		),
+		OwnerSIMs:           ownerSims,
+		OwnerServiceInfoMTU: negotiateMTU(&mtu),
+	}, fdoshared.ONBOARDING_POLICY_REJECT)
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/68", doto2.DeviceServiceInfo68)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	device := deviceto2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: server.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	device.AuthzHeader = "Bearer " + string(sessionId)
+	device.SessionKey = sessionKey
+
+	var messages, maxSize, received int
+	for messages <= len(ownerSims) {
+		ownerServiceInfo, _, err := device.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{ServiceInfo: []fdoshared.ServiceInfoKV{}}, testcom.NULL_TEST)
+		if err != nil {
+			t.Fatalf("Failed to get OwnerServiceInfo69. %s", err.Error())
+		}
+
+		messages++
+		received += len(ownerServiceInfo.ServiceInfo)
+
+		ownerServiceInfoBytes, _ := fdoshared.CborCust.Marshal(ownerServiceInfo)
+		if len(ownerServiceInfoBytes) > maxSize {
+			maxSize = len(ownerServiceInfoBytes)
+		}
+
+		if ownerServiceInfo.IsDone {
+			break
+		}
+	}
+
+	if received != len(ownerSims) {
+		t.Errorf("MTU %d: Expected all %d owner entries sent once. Got %d", mtu, len(ownerSims), received)
+	}
+
+	return messages, maxSize
+}
+
+func TestDeviceServiceInfo68_MTUChunks(t *testing.T) {
+	ownerSims := []fdoshared.ServiceInfoKV{
+		{ServiceInfoKey: "fdo_sys:active", ServiceInfoVal: fdoshared.CBOR_TRUE},
+	}
+	for i := 0; i < 12; i++ {
+		ownerSims = append(ownerSims, fdoshared.ServiceInfoKV{ServiceInfoKey: "fdo_sys:write", ServiceInfoVal: bytes.Repeat([]byte{0x41}, 80)})
+	}
+
+	smallMessages, smallMaxSize := sendOwnerSIMsWithMTU(t, ownerSims, 256)
+	largeMessages, largeMaxSize := sendOwnerSIMsWithMTU(t, ownerSims, 1300)
+
+	if smallMessages <= largeMessages {
+		t.Errorf("Expected 256 byte MTU to take more OwnerServiceInfo69 than 1300. Got %d and %d", smallMessages, largeMessages)
+	}
+
+	if smallMaxSize > 256 || smallMaxSize >= largeMaxSize {
+		t.Errorf("Expected OwnerServiceInfo69 within 256 byte MTU, and smaller than with 1300. Got %d and %d", smallMaxSize, largeMaxSize)
+	}
+
+	if largeMaxSize > 1300 {
+		t.Errorf("Expected OwnerServiceInfo69 within 1300 byte MTU. Got %d", largeMaxSize)
+	}
+}
+
+func TestFragmentOwnerSIMs(t *testing.T) {
+	simData := bytes.Repeat([]byte{0x00, 0x42, 0xff}, 10*1024/3)
+	ownerSims := []fdoshared.ServiceInfoKV{
+		{ServiceInfoKey: "fdo_sys:active", ServiceInfoVal: fdoshared.CBOR_TRUE},
+		{ServiceInfoKey: "fdo_sys:write", ServiceInfoVal: fdoshared.BytesToCborBytes(simData)},
+	}
+
+	fragments := fragmentOwnerSIMs(ownerSims, 1500)
+	if len(fragments) < 8 || fragments[0].ServiceInfoKey != "fdo_sys:active" {
+		t.Fatalf("Expected 10KB fdo_sys:write to be split after fdo_sys:active. Got %d entries", len(fragments))
+	}
+
+	var reassembled []byte
+	for _, fragment := range fragments[1:] {
+		if fragment.ServiceInfoKey != "fdo_sys:write" {
+			t.Fatalf("Expected fragment of fdo_sys:write. Got %s", fragment.ServiceInfoKey)
+		}
+
+		if size := ownerServiceInfoSize([]fdoshared.ServiceInfoKV{fragment}); size > 1500 {
+			t.Errorf("Expected fragment within 1500 byte MTU. Got %d", size)
+		}
+
+		var fragmentData []byte
+		err := fdoshared.CborCust.Unmarshal(fragment.ServiceInfoVal, &fragmentData)
+		if err != nil {
+			t.Fatalf("Expected fragment value to be bstr. %s", err.Error())
+		}
+
+		reassembled = append(reassembled, fragmentData...)
+	}
+
+	if !bytes.Equal(reassembled, simData) {
+		t.Errorf("Expected fragments to reassemble to original value")
+	}
+
+	messages, maxSize := sendOwnerSIMsWithMTU(t, fragments, 1500)
+	if messages < len(fragments)-1 || maxSize > 1500 {
+		t.Errorf("Expected fragments sent in OwnerServiceInfo69 within 1500 byte MTU. Got %d messages, max %d bytes", messages, maxSize)
+	}
+
+	// Module that does not append values can not be split, and is sent alone
+	unsplittable := []fdoshared.ServiceInfoKV{{ServiceInfoKey: "fdo_sys:exec", ServiceInfoVal: fdoshared.BytesToCborBytes(simData)}}
+	if fragments := fragmentOwnerSIMs(unsplittable, 1500); len(fragments) != 1 {
+		t.Errorf("Expected fdo_sys:exec to be kept whole. Got %d entries", len(fragments))
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,14 +12,17 @@ import (
 )
 
 func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
-	log.Println("Done70: Receiving...")
-
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_70_DONE
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
-	session, _, authorizationHeader, bodyBytes, testcomListener, err := h.receiveAndDecrypt(w, r, currentCmd)
+
+	logger := fdoshared.NewMessageLogger(fdoshared.To2, currentCmd, r)
+	logger.Infof("Receiving...")
+
+	session, sessionId, authorizationHeader, bodyBytes, testcomListener, err := h.receiveAndDecrypt(w, r, currentCmd)
 	if err != nil {
 		return
 	}
+	logger.SetGuid(session.Guid)
 
 	// Test params setup
 	if testcomListener != nil {
@@ -43,7 +45,7 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Failed to decode Done70. "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
 
-		log.Println("Done70: Error decoding request..." + err.Error())
+		logger.Errorf("Error decoding request. %s", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest)
 		return
 	}
@@ -80,8 +82,42 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	session.PrevCMD = fdoshared.TO2_71_DONE2
+	err = h.session.UpdateSessionEntry(sessionId, *session)
+	if err != nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Done70: Error saving session..."+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
+		return
+	}
+
+	// For TO2_REONBOARD_POLICY
+	err = h.voucher.SetOnboarded(session.Guid)
+	if err != nil {
+		logger.Errorf("Error recording onboarded device. %s", err.Error())
+	}
+
+	// Every test session got new GUID and Owner2Key in SetupDevice. Device that accepts Done2 of a negative test still holds them
+	if fdoTestId != testcom.FIDO_LISTENER_POSITIVE && testcomListener != nil && session.ReplacementCredential != nil {
+		err := h.Conf_KeepReplacementCredential(session, testcomListener)
+		if err != nil {
+			logger.Errorf("Error saving replacement credential of negative test. %s", err.Error())
+		}
+	}
+
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE {
-		testcomListener.To2.PushSuccess()
+		if session.ReplacementCredential != nil {
+			err := h.Conf_RegisterReplacementCredential(session, testcomListener)
+			if err != nil {
+				testcomListener.To2.PushFail("Failed to register replacement credential. " + err.Error())
+			} else {
+				testcomListener.To2.PushSuccess()
+			}
+		} else {
+			testcomListener.To2.PushSuccess()
+		}
+
+		testcomListener.To2.PushTestStates(conf_ServiceInfoTestStates(session, testcomListener.To2ServiceInfo)...)
+		testcomListener.To2.PushTestStates(testcomListener.Conf_RVBypassTestStates()...)
+
 		testcomListener.To2.CompleteTestRun()
 		err := h.listenerDB.Update(testcomListener)
 		if err != nil {
@@ -93,12 +129,12 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 	if fdoTestId == testcom.NULL_TEST && h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool) {
 		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopDO)
 		if err != nil {
-			log.Println("IOT: Error getting authz header: " + err.Error())
+			logger.Errorf("IOT: Error getting authz header. %s", err.Error())
 		}
 
 		err = fdoshared.SubmitIopLoggerEvent(h.ctx, session.Guid, fdoshared.To2, session.NonceTO2SetupDv64, authzHeader)
 		if err != nil {
-			log.Println("IOT: Error sending iop logg event: " + err.Error())
+			logger.Errorf("IOT: Error sending iop logg event. %s", err.Error())
 		}
 	}
 
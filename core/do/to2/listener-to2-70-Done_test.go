@@ -0,0 +1,137 @@
+package to2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	deviceto2 "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+)
+
+func TestDone70_NegativeTestKeepsReplacementCredential(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.WithValue(context.Background(), fdoshared.CFG_ENV_FDO_SERVICE_URL, server.URL)
+	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_INTEROP_ENABLED, false)
+
+	doto2 := NewDoTo2(db, ctx)
+	rvto0 := rv.NewRvTo0(db, ctx)
+	mux.HandleFunc("/fdo/101/msg/20", rvto0.Handle20Hello)
+	mux.HandleFunc("/fdo/101/msg/22", rvto0.Handle22OwnerSign)
+	mux.HandleFunc("/fdo/101/msg/70", doto2.Done70)
+
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	_, mfgPublicKey, err := fdoshared.GenerateVoucherKeypair(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate manufacturer key. %s", err.Error())
+	}
+
+	headerBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OwnershipVoucherHeader{
+		OVHProtVer:         fdoshared.ProtVer101,
+		OVGuid:             credential.DCGuid,
+		OVRvInfo:           fdoshared.RendezvousInfo{},
+		OVDeviceInfo:       credential.DCDeviceInfo,
+		OVPublicKey:        *mfgPublicKey,
+		OVDevCertChainHash: &credential.DCCertificateChainHash,
+	})
+
+	logger := fdoshared.NewMessageLogger(fdoshared.To2, fdoshared.TO2_64_PROVE_DEVICE, httptest.NewRequest("POST", "/fdo/101/msg/64", nil))
+	_, owner2PublicKey, owner2PrivateKeyDER, err := doto2.newOwner2Key(fdoshared.StSECP256R1, logger)
+	if err != nil {
+		t.Fatalf("Failed to generate Owner2Key. %s", err.Error())
+	}
+
+	replacementGuid := fdoshared.NewFdoGuid()
+	replacementHMac, _ := fdoshared.GenerateFdoHmac([]byte("replacement header"), credential.DCHmacAlg, credential.DCHmacSecret)
+	sessionKey := fdoshared.SessionKeyInfo{
+		ShSe:        []byte("test ShSe"),
+		ContextRand: []byte("test ContextRand"),
+	}
+	nonceTO2ProveDv := fdoshared.NewFdoNonce()
+
+	sessionId, err := doto2.session.NewTo2SessionEntry(dbs.SessionEntry{
+		Protocol:          fdoshared.To2,
+		PrevCMD:           fdoshared.TO2_69_OWNER_SERVICE_INFO,
+		Guid:              credential.DCGuid,
+		SessionKey:        sessionKey,
+		CipherSuiteName:   fdoshared.CIPHER_A128GCM,
+		NonceTO2ProveDv61: nonceTO2ProveDv,
+		SignatureSgType:   fdoshared.StSECP256R1,
+		Voucher: fdoshared.OwnershipVoucher{
+			OVProtVer:      fdoshared.ProtVer101,
+			OVHeaderTag:    headerBytes,
+			OVDevCertChain: &credential.DCCertificateChain,
+		},
+		ReplacementCredential: &fdoshared.TO2SetupDevicePayload{
+			ReplacementGuid:      replacementGuid,
+			NonceTO2SetupDv:      fdoshared.NewFdoNonce(),
+			ReplacementOwner2Key: *owner2PublicKey,
+		},
+		ReplacementHMac:          &replacementHMac,
+		ReplacementPrivateKeyDER: owner2PrivateKeyDER,
+	}, fdoshared.ONBOARDING_POLICY_REJECT)
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	testcomListener := listenertestsdeps.RequestListenerInst{
+		Uuid: []byte("done-negative-listener"),
+		Guid: credential.DCGuid,
+	}
+	testcomListener.To2.Protocol = fdoshared.To2
+	testcomListener.To2.StartNewTestRun()
+	testcomListener.To2.ExpectedCmd = fdoshared.TO2_70_DONE
+	testcomListener.To2.Tests = map[fdoshared.FdoCmd][]testcom.FDOTestID{
+		fdoshared.TO2_70_DONE: {testcom.FIDO_LISTENER_DEVICE_70_BAD_NONCE_TO2SETUPDV64, testcom.FIDO_LISTENER_POSITIVE},
+	}
+
+	err = doto2.listenerDB.Save(testcomListener)
+	if err != nil {
+		t.Fatalf("Failed to save listener. %s", err.Error())
+	}
+
+	device := deviceto2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: server.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	device.AuthzHeader = "Bearer " + string(sessionId)
+	device.SessionKey = sessionKey
+	device.NonceTO2ProveDv61 = nonceTO2ProveDv
+
+	// Done2 carries bad NonceTO2SetupDv. A conformant device rejects it, but the DO can not know it did
+	device.Done70(testcom.NULL_TEST)
+
+	voucherDBEntry, err := doto2.voucher.Get(replacementGuid)
+	if err != nil || voucherDBEntry == nil {
+		t.Fatalf("Expected voucher of replacement credential to be saved. %v", err)
+	}
+
+	result, err := doto2.listenerDB.GetEntryByFdoGuid(replacementGuid)
+	if err != nil {
+		t.Fatalf("Expected replacement GUID to be mapped to the listener. %s", err.Error())
+	}
+
+	if result.Guid != credential.DCGuid || result.ReplacedGuid != nil {
+		t.Errorf("Expected listener to keep expecting previous GUID after negative test")
+	}
+
+	if !result.To2.Running {
+		t.Errorf("Expected negative test to not complete the test run")
+	}
+}
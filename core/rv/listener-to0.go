@@ -3,13 +3,13 @@ package rv
 import (
 	"bytes"
 	"context"
-	"io"
-	"log"
 	"net/http"
 
 	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 )
 
 const ServerWaitSeconds uint32 = 30 * 24 * 60 * 60 // 1 month
@@ -36,14 +36,16 @@ func NewRvTo0(db *badger.DB, ctx context.Context) RvTo0 {
 }
 
 func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving Hello20...")
+	logger := fdoshared.NewMessageLogger(fdoshared.To0, fdoshared.TO0_20_HELLO, r)
+	logger.Infof("Receiving Hello20...")
+
 	if !fdoshared.CheckHeaders(w, r, fdoshared.TO0_20_HELLO) {
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(r.Body)
+	bodyBytes, err := fdoshared.ReadMessageBody(w, r, fdoshared.TO0_20_HELLO)
 	if err != nil {
-		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_20_HELLO, "Failed to read body!", http.StatusBadRequest)
+		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_20_HELLO, "Failed to read body! "+err.Error(), fdoshared.MessageBodyErrorStatus(err))
 		return
 	}
 
@@ -51,7 +53,7 @@ func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
 
 	err = fdoshared.CborCust.Unmarshal(bodyBytes, &helloMsg)
 	if err != nil {
-		log.Println("Error decoding Hello20. " + err.Error())
+		logger.Warnf("Error decoding Hello20. %s", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_20_HELLO, "Failed to decode body!", http.StatusBadRequest)
 		return
 	}
@@ -83,98 +85,151 @@ func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
 	w.Write(helloAckBytes)
 }
 
+// conf_RespondOwnerSignError records failed OwnerSign22 check to the running TO0 test run, and responds with FDO error. NULL_TEST is recorded as failure of the current test
+func (h *RvTo0) conf_RespondOwnerSignError(w http.ResponseWriter, r *http.Request, errorCode fdoshared.FdoErrorCode, messageStr string, testcomListener *listenertestsdeps.RequestListenerInst, testId testcom.FDOTestID) {
+	if testcomListener != nil {
+		if testId == testcom.NULL_TEST {
+			testcomListener.To0.PushFail(messageStr)
+		} else {
+			testcomListener.Conf_RecordOwnerSignFail(testId, messageStr)
+		}
+
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			fdoshared.NewMessageLogger(fdoshared.To0, fdoshared.TO0_22_OWNER_SIGN, r).Errorf("Conformance module failed to save result! %s", err.Error())
+		}
+	}
+
+	listenertestsdeps.Conf_RespondFDOError(w, r, errorCode, fdoshared.TO0_22_OWNER_SIGN, messageStr, http.StatusBadRequest, nil, fdoshared.To0)
+}
+
 func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving OwnerSign22...")
-	if !fdoshared.CheckHeaders(w, r, fdoshared.TO0_22_OWNER_SIGN) {
+	var currentCmd fdoshared.FdoCmd = fdoshared.TO0_22_OWNER_SIGN
+
+	logger := fdoshared.NewMessageLogger(fdoshared.To0, currentCmd, r)
+	logger.Infof("Receiving OwnerSign22...")
+
+	var testcomListener *listenertestsdeps.RequestListenerInst
+	if !fdoshared.CheckHeaders(w, r, currentCmd) {
 		return
 	}
 
-	headerIsOk, sessionId, authorizationHeader := fdoshared.ExtractAuthorizationHeader(w, r, fdoshared.TO0_22_OWNER_SIGN)
+	headerIsOk, sessionId, authorizationHeader := fdoshared.ExtractAuthorizationHeader(w, r, currentCmd)
 	if !headerIsOk {
 		return
 	}
 
 	session, err := h.session.GetSessionEntry(sessionId)
 	if err != nil {
-		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Unauthorized", http.StatusUnauthorized)
+		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	if session.Protocol != fdoshared.To0 {
-		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Unauthorized", http.StatusUnauthorized)
+		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	/* ----- Process Body ----- */
-	bodyBytes, err := io.ReadAll(r.Body)
+	bodyBytes, err := fdoshared.ReadMessageBody(w, r, currentCmd)
 	if err != nil {
-		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_20_HELLO, "Failed to read body!", http.StatusBadRequest)
+		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to read body! "+err.Error(), fdoshared.MessageBodyErrorStatus(err))
 		return
 	}
 
+	// GUID is only known from the voucher in To0d, so undecodable OwnerSign can not be recorded to the test run
 	var ownerSign fdoshared.OwnerSign22
 	err = fdoshared.CborCust.Unmarshal(bodyBytes, &ownerSign)
 	if err != nil {
-		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to decode body!", http.StatusBadRequest)
+		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest)
 		return
 	}
 
 	var to0d fdoshared.To0d
 	err = fdoshared.CborCust.Unmarshal(ownerSign.To0d, &to0d)
 	if err != nil {
-		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to decode body!", http.StatusBadRequest)
+		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest)
 		return
 	}
 
+	ovHeader, err := to0d.OwnershipVoucher.GetOVHeader()
+	if err != nil {
+		logger.Warnf("Error decoding header. %s", err.Error())
+		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Failed to validate owner sign!", http.StatusBadRequest)
+		return
+	}
+
+	logger.SetGuid(ovHeader.OVGuid)
+
+	// Test stuff
+	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
+	listenerEntry, err := h.listenerDB.GetEntryByFdoGuid(ovHeader.OVGuid)
+	if err != nil {
+		logger.Debugf("No test case. %s", err.Error())
+	}
+
+	if listenerEntry != nil && listenerEntry.To0.Running {
+		testcomListener = listenerEntry
+	}
+
+	if testcomListener != nil && !testcomListener.To0.CheckCmdTestingIsCompleted(currentCmd) {
+		// Owner came back after negative test
+		if testcomListener.To0.CurrentTestIndex != 0 {
+			testcomListener.To0.PushSuccess()
+		}
+
+		fdoTestId = testcomListener.To0.GetNextTestID()
+
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusInternalServerError, nil, fdoshared.To0)
+			return
+		}
+	}
+
 	var to1dPayload fdoshared.To1dBlobPayload
 	err = fdoshared.CborCust.Unmarshal(ownerSign.To1d.Payload, &to1dPayload)
 	if err != nil {
-		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to decode body!", http.StatusBadRequest)
+		h.conf_RespondOwnerSignError(w, r, fdoshared.MESSAGE_BODY_ERROR, "Failed to decode To1d payload! "+err.Error(), testcomListener, testcom.FIDO_LISTENER_OWNER_22_MALFORMED_OWNERSIGN)
 		return
 	}
 
 	/* ----- Verify OwnerSign ----- */
 
 	if !bytes.Equal(to0d.NonceTO0Sign[:], session.NonceTO0Sign[:]) {
-		log.Println("OwnerSign22: NonceTO0Sign does not match!")
-		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
+		h.conf_RespondOwnerSignError(w, r, fdoshared.INVALID_MESSAGE_ERROR, "NonceTO0Sign does not match!", testcomListener, testcom.NULL_TEST)
 		return
 	}
 
 	err = to0d.OwnershipVoucher.Validate()
 	if err != nil {
-		log.Println("OwnerSign22: Error verifying voucher. " + err.Error())
-		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate voucher!", http.StatusBadRequest)
-		return
-	}
-
-	ovHeader, err := to0d.OwnershipVoucher.GetOVHeader()
-	if err != nil {
-		log.Println("OwnerSign22: Error decoding header. " + err.Error())
-		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
+		h.conf_RespondOwnerSignError(w, r, fdoshared.MESSAGE_BODY_ERROR, "Failed to validate voucher! "+err.Error(), testcomListener, testcom.NULL_TEST)
 		return
 	}
 
 	// Verify To1D
 	finalPublicKey, err := to0d.OwnershipVoucher.GetFinalOwnerPublicKey()
 	if err != nil {
-		log.Println("OwnerSign22: Error decoding final owner public key. " + err.Error())
-		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
+		h.conf_RespondOwnerSignError(w, r, fdoshared.INVALID_MESSAGE_ERROR, "Failed to decode final owner public key! "+err.Error(), testcomListener, testcom.NULL_TEST)
 		return
 	}
 
 	err = fdoshared.VerifyCoseSignature(ownerSign.To1d, finalPublicKey)
 	if err != nil {
-		log.Println("OwnerSign22: Error verifying to1d. " + err.Error())
-		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign 4!", http.StatusBadRequest)
+		h.conf_RespondOwnerSignError(w, r, fdoshared.INVALID_MESSAGE_ERROR, "Failed to verify To1d signature! "+err.Error(), testcomListener, testcom.NULL_TEST)
 		return
 	}
 
 	// Verify To0D Hash
 	err = fdoshared.VerifyHash(ownerSign.To0d, to1dPayload.To1dTo0dHash)
 	if err != nil {
-		log.Println("OwnerSign22: Error verifying to0dHash. " + err.Error())
-		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign 6!", http.StatusBadRequest)
+		h.conf_RespondOwnerSignError(w, r, fdoshared.INVALID_MESSAGE_ERROR, "To1dTo0dHash does not match To0d! "+err.Error(), testcomListener, testcom.FIDO_LISTENER_OWNER_22_BAD_TO0D_HASH)
+		return
+	}
+
+	// Zero WaitSeconds expires registration right away, so RVTO2Addr would never be served to the device
+	if testcomListener != nil && to0d.WaitSeconds == 0 {
+		h.conf_RespondOwnerSignError(w, r, fdoshared.INVALID_MESSAGE_ERROR, "To0d WaitSeconds is 0. Registration is expired on arrival", testcomListener, testcom.FIDO_LISTENER_OWNER_22_EXPIRED_WAITSECONDS)
 		return
 	}
 
@@ -186,7 +241,7 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 
 	err = h.ownersignDB.Save(ovHeader.OVGuid, ownerSign, agreedWaitSeconds)
 	if err != nil {
-		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Internal Server Error!", http.StatusInternalServerError)
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal Server Error!", http.StatusInternalServerError, nil, fdoshared.To0)
 		return
 	}
 
@@ -195,16 +250,30 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 	}
 	acceptOwnerBytes, _ := fdoshared.CborCust.Marshal(acceptOwner)
 
-	// TODO: Add testid check
-	if h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool) {
+	if fdoTestId == testcom.FIDO_LISTENER_OWNER_22_BAD_ENCODING {
+		acceptOwnerBytes = fdoshared.Conf_RandomCborBufferFuzzing(acceptOwnerBytes)
+	}
+
+	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE {
+		testcomListener.To0.PushSuccess()
+		testcomListener.To0.PushTestStates(testcomListener.Conf_OwnerSignTestStates()...)
+		testcomListener.To0.CompleteTestRun()
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusInternalServerError, nil, fdoshared.To0)
+			return
+		}
+	}
+
+	if fdoTestId == testcom.NULL_TEST && h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool) {
 		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopRV)
 		if err != nil {
-			log.Println("IOT: Error getting authz header: " + err.Error())
+			logger.Warnf("IOT: Error getting authz header: %s", err.Error())
 		}
 
 		err = fdoshared.SubmitIopLoggerEvent(h.ctx, session.Guid, fdoshared.To0, session.NonceTO1Proof, authzHeader)
 		if err != nil {
-			log.Println("IOT: Error sending iop logg event: " + err.Error())
+			logger.Warnf("IOT: Error sending iop logg event: %s", err.Error())
 		}
 	}
 
@@ -5,11 +5,10 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 
 	"github.com/dgraph-io/badger/v4"
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
@@ -20,7 +19,9 @@ type RvTo1 struct {
 	session     *SessionDB
 	ownersignDB *OwnerSignDB
 	listenerDB  *tdbs.ListenerTestDB
+	identity    *dodbs.IdentityDB
 	ctx         context.Context
+	secondary   bool
 }
 
 func NewRvTo1(db *badger.DB, ctx context.Context) RvTo1 {
@@ -33,23 +34,49 @@ func NewRvTo1(db *badger.DB, ctx context.Context) RvTo1 {
 			db: db,
 		},
 		listenerDB: newListenerDb,
+		identity:   dodbs.NewIdentityDB(db),
 		ctx:        ctx,
 	}
 }
 
-func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving HelloRV30...")
+// Conformance. Finds device GUID of the exchange for the message log
+func (h *RvTo1) ResolveMessageGuid(sessionId []byte, reqBody []byte) *fdoshared.FdoGuid {
+	if sessionId != nil {
+		session, err := h.session.GetSessionEntry(sessionId)
+		if err == nil && session != nil {
+			return &session.Guid
+		}
+	}
+
+	var helloRV fdoshared.HelloRV30
+	err := fdoshared.CborCust.Unmarshal(reqBody, &helloRV)
+	if err == nil {
+		return &helloRV.Guid
+	}
+
+	return nil
+}
 
+// conf_BadNonceLenHelloRVAck31 encodes HelloRVAck31 with truncated NonceTO1Proof. Encoded as plain array, as FdoNonce is fixed length
+func conf_BadNonceLenHelloRVAck31(helloRVAck31 fdoshared.HelloRVAck31) []byte {
+	helloRVAckBytes, _ := fdoshared.CborCust.Marshal([]interface{}{helloRVAck31.NonceTO1Proof[:len(helloRVAck31.NonceTO1Proof)-1], helloRVAck31.EBSigInfo})
+	return helloRVAckBytes
+}
+
+func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO1_30_HELLO_RV
 
+	logger := fdoshared.NewMessageLogger(fdoshared.To1, currentCmd, r)
+	logger.Infof("Receiving HelloRV30...")
+
 	var testcomListener *listenertestsdeps.RequestListenerInst
 	if !fdoshared.CheckHeaders(w, r, currentCmd) {
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(r.Body)
+	bodyBytes, err := fdoshared.ReadMessageBody(w, r, currentCmd)
 	if err != nil {
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to read body!", http.StatusBadRequest, testcomListener, fdoshared.To1)
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to read body! "+err.Error(), fdoshared.MessageBodyErrorStatus(err), testcomListener, fdoshared.To1)
 		return
 	}
 
@@ -60,11 +87,56 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = fdoshared.CheckHelloRV30GuidLen(bodyBytes)
+	if err != nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Bad GUID! "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To1)
+		return
+	}
+
+	logger.SetGuid(helloRV30.Guid)
+
 	// Test stuff
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 	testcomListener, err = h.listenerDB.GetEntryByFdoGuid(helloRV30.Guid)
 	if err != nil {
-		log.Printf("NO TEST CASE FOR %s. %s ", hex.EncodeToString(helloRV30.Guid[:]), err.Error())
+		logger.Debugf("No test case. %s", err.Error())
+	}
+
+	if testcomListener != nil && testcomListener.Conf_CheckAbandonedGuid(helloRV30.Guid) {
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			logger.Errorf("Conformance module failed to save result! %s", err.Error())
+		}
+
+		fdoshared.RespondFDOError(w, r, fdoshared.RESOURCE_NOT_FOUND, currentCmd, "Could not find guid!", http.StatusBadRequest)
+		return
+	}
+
+	// Device came back without sending TO2.ProveDevice, so it rejected the owner of mismatched To1d, or To1d countersignature
+	if testcomListener != nil && (testcomListener.To1dOwnerMismatchServed || testcomListener.To1dCountersignServed) {
+		testcomListener.To2.PushTestStates(testcomListener.Conf_To1dOwnerMismatchTestStates(false)...)
+		testcomListener.To2.PushTestStates(testcomListener.Conf_To1dCountersignTestStates(false)...)
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To1)
+			return
+		}
+	}
+
+	if testcomListener != nil && testcomListener.Conf_CheckRVBypassIgnored() {
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To1)
+			return
+		}
+	}
+
+	if testcomListener != nil && testcomListener.Conf_CheckReplacementGuid(helloRV30.Guid, h.secondary) {
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To1)
+			return
+		}
 	}
 
 	if testcomListener != nil && !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
@@ -85,19 +157,46 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	_, err = h.ownersignDB.Get(helloRV30.Guid)
+	savedOwnerSign, err := h.ownersignDB.Get(helloRV30.Guid)
 	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.RESOURCE_NOT_FOUND, currentCmd, "Could not find guid!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
 
+	var to0d fdoshared.To0d
+	err = fdoshared.CborCust.Unmarshal(savedOwnerSign.To0d, &to0d)
+	if err != nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Failed to decode stored To0d!", http.StatusInternalServerError, testcomListener, fdoshared.To1)
+		return
+	}
+
+	if listenertestsdeps.Conf_CheckTLSDeviceCert(r, to0d.OwnershipVoucher.OVDevCertChain, testcomListener, fdoshared.To1) {
+		err := h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To1)
+			return
+		}
+	}
+
+	// EBSigInfo must match actual device key, rather than what device claims
+	ebSigInfo := helloRV30.EASigInfo
+	if to0d.OwnershipVoucher.OVDevCertChain != nil {
+		deviceSigInfo, err := fdoshared.GetDeviceSigInfo(*to0d.OwnershipVoucher.OVDevCertChain, helloRV30.EASigInfo)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Failed to determine device key type. "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To1)
+			return
+		}
+
+		ebSigInfo = *deviceSigInfo
+	}
+
 	nonceTO1Proof := fdoshared.NewFdoNonce()
 
 	newSessionInst := SessionEntry{
 		Protocol:      fdoshared.To1,
 		NonceTO1Proof: nonceTO1Proof,
 		Guid:          helloRV30.Guid,
-		EASigInfo:     helloRV30.EASigInfo,
+		EASigInfo:     ebSigInfo,
 	}
 
 	sessionId, err := h.session.NewSessionEntry(newSessionInst)
@@ -106,9 +205,11 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	logger.Infof("Session started. Token %s", fdoshared.TokenCorrelationID(string(sessionId)))
+
 	helloRVAck31 := fdoshared.HelloRVAck31{
 		NonceTO1Proof: nonceTO1Proof,
-		EBSigInfo:     helloRV30.EASigInfo,
+		EBSigInfo:     ebSigInfo,
 	}
 
 	helloRVAckBytes, _ := fdoshared.CborCust.Marshal(helloRVAck31)
@@ -117,6 +218,10 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 		helloRVAckBytes = fdoshared.Conf_RandomCborBufferFuzzing(helloRVAckBytes)
 	}
 
+	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_30_BAD_NONCE_LEN {
+		helloRVAckBytes = conf_BadNonceLenHelloRVAck31(helloRVAck31)
+	}
+
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE && testcomListener.To1.CheckExpectedCmd(currentCmd) {
 		testcomListener.To1.PushSuccess()
 		testcomListener.To1.CompleteCmdAndSetNext(fdoshared.TO1_32_PROVE_TO_RV)
@@ -136,10 +241,11 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving ProveToRV32...")
-
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO1_32_PROVE_TO_RV
 
+	logger := fdoshared.NewMessageLogger(fdoshared.To1, currentCmd, r)
+	logger.Infof("Receiving ProveToRV32...")
+
 	var testcomListener *listenertestsdeps.RequestListenerInst
 	if !fdoshared.CheckHeaders(w, r, currentCmd) {
 		return
@@ -161,9 +267,11 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(r.Body)
+	logger.SetGuid(session.Guid)
+
+	bodyBytes, err := fdoshared.ReadMessageBody(w, r, currentCmd)
 	if err != nil {
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to read body!", http.StatusBadRequest, testcomListener, fdoshared.To1)
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to read body! "+err.Error(), fdoshared.MessageBodyErrorStatus(err), testcomListener, fdoshared.To1)
 		return
 	}
 
@@ -171,7 +279,7 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 	testcomListener, err = h.listenerDB.GetEntryByFdoGuid(session.Guid)
 	if err != nil {
-		log.Printf("NO TEST CASE FOR %s. %s ", hex.EncodeToString(session.Guid[:]), err.Error())
+		logger.Debugf("No test case. %s", err.Error())
 	}
 
 	if testcomListener != nil && !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
@@ -195,15 +303,23 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	var proveToRV32 fdoshared.CoseSignature
 	err = fdoshared.CborCust.Unmarshal(bodyBytes, &proveToRV32)
 	if err != nil {
-		log.Println("Failed to decode proveToRV32 request: " + err.Error())
+		logger.Errorf("Failed to decode proveToRV32 request: %s", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
 
+	if testcomListener != nil && testcomListener.Conf_CheckCanonicalCbor(fdoshared.To1, bodyBytes) {
+		err = h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To1)
+			return
+		}
+	}
+
 	var pb fdoshared.EATPayloadBase
 	err = fdoshared.CborCust.Unmarshal(proveToRV32.Payload, &pb)
 	if err != nil {
-		log.Println("Failed to decode proveToRV32 payload: " + err.Error())
+		logger.Errorf("Failed to decode proveToRV32 payload: %s", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body payload!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
@@ -216,7 +332,7 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	// Get ownerSign from ownerSign storage
 	savedOwnerSign, err := h.ownersignDB.Get(session.Guid)
 	if err != nil {
-		log.Println("Couldn't find item in database with guid" + err.Error())
+		logger.Errorf("Couldn't find item in database with guid. %s", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Server Error", http.StatusInternalServerError, testcomListener, fdoshared.To1)
 		return
 	}
@@ -224,7 +340,7 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	var to0d fdoshared.To0d
 	err = fdoshared.CborCust.Unmarshal(savedOwnerSign.To0d, &to0d)
 	if err != nil {
-		log.Println("Error decoding To0d" + err.Error())
+		logger.Errorf("Error decoding To0d. %s", err.Error())
 
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
@@ -232,13 +348,26 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 
 	pkType, ok := fdoshared.SgTypeToFdoPkType[session.EASigInfo.SgType]
 	if !ok {
-		log.Println("ProveToRV32: Unknown signature type. ")
+		logger.Errorf("Unknown signature type %d", session.EASigInfo.SgType)
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveToRV32 ", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
+	if to0d.OwnershipVoucher.OVDevCertChain == nil {
+		logger.Errorf("Voucher has no device certificate chain")
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveToRV32. Voucher has no device certificate chain", http.StatusBadRequest, testcomListener, fdoshared.To1)
+		return
+	}
+
+	err = fdoshared.VerifyCoseSignatureAlg(proveToRV32, *to0d.OwnershipVoucher.OVDevCertChain)
+	if err != nil {
+		logger.Warnf("ProveToRV32 signature alg mismatch. %s", err.Error())
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveToRV32. "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To1)
+		return
+	}
+
 	err = fdoshared.VerifyCoseSignatureWithCertificate(proveToRV32, pkType, *to0d.OwnershipVoucher.OVDevCertChain)
 	if err != nil {
-		log.Println("ProveToRV32: Error verifying ProveToRV32 signature. " + err.Error())
+		logger.Warnf("Error verifying ProveToRV32 signature. %s", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveToRV32 ", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
@@ -248,6 +377,56 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 		to1d = fdoshared.Conf_Fuzz_CoseSignature(to1d)
 	}
 
+	if testcomListener != nil && testcomListener.Conf_CheckTo1dOwnerMismatch() {
+		mismatchedTo1d, err := fdoshared.Conf_ResignCoseSignature(to1d)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Failed to sign mismatched To1d. "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To1)
+			return
+		}
+		to1d = *mismatchedTo1d
+
+		err = h.listenerDB.Update(testcomListener)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusInternalServerError, testcomListener, fdoshared.To1)
+			return
+		}
+	}
+
+	var rvIdentity *fdoshared.ServerIdentity
+	if fdoshared.RvCountersignTo1d {
+		rvIdentity, err = h.identity.Get(fdoshared.IDENTITY_ROLE_RV)
+		if err != nil {
+			logger.Errorf("Error reading RV identity. %s", err.Error())
+		}
+
+		// Startup requires RV identity with the flag, but identity can be deleted since. To1d is then served as signed by the owner
+		if rvIdentity == nil {
+			logger.Warnf("RV_COUNTERSIGN_TO1D is set, but no RV identity is imported. See /api/admin/identity. To1d is not countersigned")
+		}
+	}
+
+	if rvIdentity != nil {
+		var countersignCase listenertestsdeps.To1dCountersignCase
+		if testcomListener != nil {
+			countersignCase = testcomListener.Conf_CheckTo1dCountersign()
+		}
+
+		countersignedTo1d, err := countersignTo1d(to1d, *rvIdentity, countersignCase)
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Failed to countersign To1d. "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To1)
+			return
+		}
+		to1d = *countersignedTo1d
+
+		if countersignCase != "" {
+			err = h.listenerDB.Update(testcomListener)
+			if err != nil {
+				listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusInternalServerError, testcomListener, fdoshared.To1)
+				return
+			}
+		}
+	}
+
 	rvRedirectBytes, _ := fdoshared.CborCust.Marshal(to1d)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_32_BAD_ENCODING {
 		rvRedirectBytes = fdoshared.Conf_RandomCborBufferFuzzing(rvRedirectBytes)
@@ -266,12 +445,12 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	if fdoTestId == testcom.NULL_TEST && h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool) {
 		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopRV)
 		if err != nil {
-			log.Println("IOT: Error getting authz header: " + err.Error())
+			logger.Warnf("IOT: Error getting authz header: %s", err.Error())
 		}
 
 		err = fdoshared.SubmitIopLoggerEvent(h.ctx, session.Guid, fdoshared.To1, session.NonceTO1Proof, authzHeader)
 		if err != nil {
-			log.Println("IOT: Error sending iop logg event: " + err.Error())
+			logger.Warnf("IOT: Error sending iop logg event: %s", err.Error())
 		}
 	}
 
@@ -281,3 +460,33 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(rvRedirectBytes)
 }
+
+// countersignTo1d attaches RV countersignature to To1d with imported RV identity key. Conformance: device test run can ask for absent or invalid countersignature
+func countersignTo1d(to1d fdoshared.CoseSignature, identity fdoshared.ServerIdentity, countersignCase listenertestsdeps.To1dCountersignCase) (*fdoshared.CoseSignature, error) {
+	switch countersignCase {
+	case listenertestsdeps.TO1D_COUNTERSIGN_ABSENT:
+		to1d.Unprotected.CounterSignature = nil
+		return &to1d, nil
+	case listenertestsdeps.TO1D_COUNTERSIGN_INVALID:
+		counterSig, err := fdoshared.Conf_InvalidCoseCounterSignature(to1d, identity.SgType)
+		if err != nil {
+			return nil, err
+		}
+
+		to1d.Unprotected.CounterSignature = counterSig
+		return &to1d, nil
+	}
+
+	privateKey, err := identity.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	counterSig, err := fdoshared.GenerateCoseCounterSignature(to1d, privateKey, identity.SgType)
+	if err != nil {
+		return nil, err
+	}
+
+	to1d.Unprotected.CounterSignature = counterSig
+	return &to1d, nil
+}
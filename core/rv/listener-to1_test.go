@@ -0,0 +1,220 @@
+package rv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func newTestClientCert(t *testing.T) (*x509.CertPool, tls.Certificate) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Lab Device CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caCertBytes, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to generate CA certificate. %s", err.Error())
+	}
+	caCert, _ := x509.ParseCertificate(caCertBytes)
+
+	deviceKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	deviceTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Lab Device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	deviceCertBytes, err := x509.CreateCertificate(rand.Reader, deviceTemplate, caCert, &deviceKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to generate device certificate. %s", err.Error())
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+
+	return clientCAs, tls.Certificate{Certificate: [][]byte{deviceCertBytes}, PrivateKey: deviceKey}
+}
+
+func TestHandle30HelloRV_RequireTLSClientCert(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	clientCAs, deviceCert := newTestClientCert(t)
+
+	to1 := NewRvTo1(db, context.Background())
+	handlerReached := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/30", func(w http.ResponseWriter, r *http.Request) {
+		handlerReached = true
+		to1.Handle30HelloRV(w, r)
+	})
+
+	server := httptest.NewUnstartedServer(fdoshared.RequireTLSClientCert(clientCAs, mux))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	helloRV30Bytes, _ := fdoshared.CborCust.Marshal(fdoshared.HelloRV30{
+		Guid:      fdoshared.NewFdoGuid(),
+		EASigInfo: fdoshared.SigInfo{SgType: fdoshared.StSECP256R1},
+	})
+
+	postHelloRV := func(client *http.Client) (int, []byte) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/fdo/101/msg/30", bytes.NewReader(helloRV30Bytes))
+		req.Header.Set("Content-Type", fdoshared.CONTENT_TYPE_CBOR)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send HelloRV30. %s", err.Error())
+		}
+		defer resp.Body.Close()
+
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, bodyBytes
+	}
+
+	status, bodyBytes := postHelloRV(server.Client())
+	if status != http.StatusUnauthorized {
+		t.Errorf("Expected device without client certificate to be rejected. Got %d", status)
+	}
+
+	var fdoError fdoshared.FdoError
+	err = fdoshared.CborCust.Unmarshal(bodyBytes, &fdoError)
+	if err != nil {
+		t.Fatalf("Failed to decode FDO error. %s", err.Error())
+	}
+
+	if fdoError.EMPrevMsgID != fdoshared.TO1_30_HELLO_RV || !strings.Contains(fdoError.EMErrorStr, "TLS client certificate") {
+		t.Errorf("Expected TLS client certificate error for HelloRV30. Got %+v", fdoError)
+	}
+
+	if handlerReached {
+		t.Fatalf("Expected device without client certificate to be rejected before Handle30HelloRV")
+	}
+
+	deviceTransport := server.Client().Transport.(*http.Transport).Clone()
+	deviceTransport.TLSClientConfig.Certificates = []tls.Certificate{deviceCert}
+	deviceClient := &http.Client{Transport: deviceTransport}
+
+	status, _ = postHelloRV(deviceClient)
+	if !handlerReached {
+		t.Errorf("Expected device with valid client certificate to reach Handle30HelloRV. Got %d", status)
+	}
+}
+
+// countingZeroReader serves size zero bytes without allocating them
+type countingZeroReader struct {
+	size int64
+	read int64
+}
+
+func (h *countingZeroReader) Read(p []byte) (int, error) {
+	if h.read >= h.size {
+		return 0, io.EOF
+	}
+
+	n := int64(len(p))
+	if n > h.size-h.read {
+		n = h.size - h.read
+	}
+
+	for i := int64(0); i < n; i++ {
+		p[i] = 0
+	}
+	h.read += n
+
+	return int(n), nil
+}
+
+func TestHandle32ProveToRV_MaxBodySize(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	to1 := NewRvTo1(db, context.Background())
+	sessionId, err := to1.session.NewSessionEntry(SessionEntry{
+		Protocol: fdoshared.To1,
+		Guid:     fdoshared.NewFdoGuid(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	body := &countingZeroReader{size: 1 << 30}
+	r := httptest.NewRequest(http.MethodPost, "/fdo/101/msg/32", body)
+	r.Header.Set("Content-Type", fdoshared.CONTENT_TYPE_CBOR)
+	r.Header.Set("Authorization", "Bearer "+string(sessionId))
+
+	w := httptest.NewRecorder()
+	to1.Handle32ProveToRV(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected oversized ProveToRV32 to be rejected with 413. Got %d", w.Code)
+	}
+
+	var fdoError fdoshared.FdoError
+	err = fdoshared.CborCust.Unmarshal(w.Body.Bytes(), &fdoError)
+	if err != nil {
+		t.Fatalf("Failed to decode FDO error. %s", err.Error())
+	}
+
+	if fdoError.EMErrorCode != fdoshared.MESSAGE_BODY_ERROR || fdoError.EMPrevMsgID != fdoshared.TO1_32_PROVE_TO_RV {
+		t.Errorf("Expected MESSAGE_BODY_ERROR for ProveToRV32. Got %+v", fdoError)
+	}
+
+	maxSize := fdoshared.Limits.MaxMessageBodySize(fdoshared.TO1_32_PROVE_TO_RV)
+	if body.read > 2*maxSize {
+		t.Errorf("Expected body read to stop at the limit of %d bytes. Read %d", maxSize, body.read)
+	}
+}
+
+func TestConfBadNonceLenHelloRVAck31(t *testing.T) {
+	helloRVAck31 := fdoshared.HelloRVAck31{
+		NonceTO1Proof: fdoshared.NewFdoNonce(),
+		EBSigInfo:     fdoshared.SigInfo{SgType: fdoshared.StSECP256R1},
+	}
+
+	var decoded []interface{}
+	err := fdoshared.CborCust.Unmarshal(conf_BadNonceLenHelloRVAck31(helloRVAck31), &decoded)
+	if err != nil {
+		t.Fatalf("Error decoding HelloRVAck31. %s", err.Error())
+	}
+
+	nonce, ok := decoded[0].([]byte)
+	if !ok || len(nonce) != len(helloRVAck31.NonceTO1Proof)-1 {
+		t.Errorf("Expected NonceTO1Proof of %d bytes. Got %v", len(helloRVAck31.NonceTO1Proof)-1, decoded[0])
+	}
+}
@@ -71,3 +71,23 @@ func (h *OwnerSignDB) Get(deviceGuid fdoshared.FdoGuid) (*fdoshared.OwnerSign22,
 
 	return &ownerSignInst, nil
 }
+
+// Delete removes OwnerSign of the device, so TO1 of it fails until owner registers again. Missing entry is not an error
+func (h *OwnerSignDB) Delete(deviceGuid fdoshared.FdoGuid) error {
+	ownerSignStorageId := append([]byte("to1osstorage-"), deviceGuid[:]...)
+
+	dbtxn := h.db.NewTransaction(true)
+	defer dbtxn.Discard()
+
+	err := dbtxn.Delete(ownerSignStorageId)
+	if err != nil {
+		return errors.New("Failed deleting owner sign entry. The error is: " + err.Error())
+	}
+
+	err = dbtxn.Commit()
+	if err != nil {
+		return errors.New("Failed saving owner sign entry deletion. The error is: " + err.Error())
+	}
+
+	return nil
+}
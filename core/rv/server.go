@@ -5,14 +5,37 @@ import (
 	"net/http"
 
 	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 )
 
 func SetupServer(db *badger.DB, ctx context.Context) {
 	to0 := NewRvTo0(db, ctx)
 	to1 := NewRvTo1(db, ctx)
 
-	http.HandleFunc("/fdo/101/msg/20", to0.Handle20Hello)
-	http.HandleFunc("/fdo/101/msg/22", to0.Handle22OwnerSign)
-	http.HandleFunc("/fdo/101/msg/30", to1.Handle30HelloRV)
-	http.HandleFunc("/fdo/101/msg/32", to1.Handle32ProveToRV)
+	msgLogDb := tdbs.NewMessageLogDB(db)
+	listenerDb := tdbs.NewListenerTestDB(db)
+	delayDb := tdbs.NewResponseDelayDB(db)
+
+	http.HandleFunc("/fdo/101/msg/20", fdoshared.FdoDrainer.Track(fdoshared.TO0_20_HELLO, delayDb.Delay(fdoshared.TO0_20_HELLO, fdoshared.FdoMetrics.Instrument(fdoshared.To0, fdoshared.TO0_20_HELLO, to0.Handle20Hello))))
+	http.HandleFunc("/fdo/101/msg/22", fdoshared.FdoDrainer.Track(fdoshared.TO0_22_OWNER_SIGN, delayDb.Delay(fdoshared.TO0_22_OWNER_SIGN, fdoshared.FdoMetrics.Instrument(fdoshared.To0, fdoshared.TO0_22_OWNER_SIGN, to0.Handle22OwnerSign))))
+	http.HandleFunc("/fdo/101/msg/30", fdoshared.FdoDrainer.Track(fdoshared.TO1_30_HELLO_RV, msgLogDb.Capture(listenerDb, fdoshared.To1, fdoshared.TO1_30_HELLO_RV, to1.ResolveMessageGuid, delayDb.Delay(fdoshared.TO1_30_HELLO_RV, fdoshared.FdoMetrics.Instrument(fdoshared.To1, fdoshared.TO1_30_HELLO_RV, to1.Handle30HelloRV)))))
+	http.HandleFunc("/fdo/101/msg/32", fdoshared.FdoDrainer.Track(fdoshared.TO1_32_PROVE_TO_RV, msgLogDb.Capture(listenerDb, fdoshared.To1, fdoshared.TO1_32_PROVE_TO_RV, to1.ResolveMessageGuid, delayDb.Delay(fdoshared.TO1_32_PROVE_TO_RV, fdoshared.FdoMetrics.Instrument(fdoshared.To1, fdoshared.TO1_32_PROVE_TO_RV, to1.Handle32ProveToRV)))))
+}
+
+// SetupSecondaryServer serves TO1 for the second RV endpoint, used to verify replacement RVInfo
+func SetupSecondaryServer(db *badger.DB, ctx context.Context) *http.ServeMux {
+	to1 := NewRvTo1(db, ctx)
+	to1.secondary = true
+
+	msgLogDb := tdbs.NewMessageLogDB(db)
+	listenerDb := tdbs.NewListenerTestDB(db)
+	delayDb := tdbs.NewResponseDelayDB(db)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/30", fdoshared.FdoDrainer.Track(fdoshared.TO1_30_HELLO_RV, msgLogDb.Capture(listenerDb, fdoshared.To1, fdoshared.TO1_30_HELLO_RV, to1.ResolveMessageGuid, delayDb.Delay(fdoshared.TO1_30_HELLO_RV, fdoshared.FdoMetrics.Instrument(fdoshared.To1, fdoshared.TO1_30_HELLO_RV, to1.Handle30HelloRV)))))
+	mux.HandleFunc("/fdo/101/msg/32", fdoshared.FdoDrainer.Track(fdoshared.TO1_32_PROVE_TO_RV, msgLogDb.Capture(listenerDb, fdoshared.To1, fdoshared.TO1_32_PROVE_TO_RV, to1.ResolveMessageGuid, delayDb.Delay(fdoshared.TO1_32_PROVE_TO_RV, fdoshared.FdoMetrics.Instrument(fdoshared.To1, fdoshared.TO1_32_PROVE_TO_RV, to1.Handle32ProveToRV)))))
+	mux.HandleFunc(fdoshared.FDO_101_URL_BASE, fdoshared.RespondUnknownMessage)
+
+	return mux
 }
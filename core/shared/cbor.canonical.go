@@ -0,0 +1,253 @@
+package fdoshared
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Nesting deeper than this is rejected, so malformed input can not exhaust the stack
+const CANONICAL_CBOR_MAX_DEPTH int = 64
+
+// CheckCanonicalCbor checks that data is a single CBOR data item in core deterministic encoding, RFC 8949 4.2.1: integers, lengths
+// and floats in shortest form, no indefinite lengths, and map keys sorted bytewise without duplicates.
+// Error names the offending item by its path from the root $, e.g. $[1]{4} for value of map key 4 in the second array element
+func CheckCanonicalCbor(data []byte) error {
+	end, err := checkCanonicalItem(data, 0, "$", 0)
+	if err != nil {
+		return err
+	}
+
+	if end != len(data) {
+		return fmt.Errorf("$: %d bytes after the data item", len(data)-end)
+	}
+
+	return nil
+}
+
+// CheckCanonicalCoseSignature checks COSE_Sign1 with CheckCanonicalCbor, and then its protected header and payload, which are CBOR encoded bstr
+func CheckCanonicalCoseSignature(data []byte) error {
+	err := CheckCanonicalCbor(data)
+	if err != nil {
+		return err
+	}
+
+	var coseSignature CoseSignature
+	err = CborCust.Unmarshal(data, &coseSignature)
+	if err != nil {
+		return errors.New("error decoding COSE_Sign1. " + err.Error())
+	}
+
+	if len(coseSignature.Protected) != 0 {
+		err = CheckCanonicalCbor(coseSignature.Protected)
+		if err != nil {
+			return errors.New("protected header " + err.Error())
+		}
+	}
+
+	if len(coseSignature.Payload) != 0 {
+		err = CheckCanonicalCbor(coseSignature.Payload)
+		if err != nil {
+			return errors.New("payload " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Reads head of the item at offset. Returns major type, argument, additional info, and offset after the head
+func readCanonicalHead(data []byte, offset int, path string) (byte, uint64, byte, int, error) {
+	if offset >= len(data) {
+		return 0, 0, 0, 0, fmt.Errorf("%s: unexpected end of data", path)
+	}
+
+	major := data[offset] >> 5
+	info := data[offset] & 0x1f
+	offset++
+
+	if info < 24 {
+		return major, uint64(info), info, offset, nil
+	}
+
+	if info == 31 {
+		return 0, 0, 0, 0, fmt.Errorf("%s: indefinite length item", path)
+	}
+
+	if info > 27 {
+		return 0, 0, 0, 0, fmt.Errorf("%s: reserved additional info %d", path, info)
+	}
+
+	argLen := 1 << (info - 24)
+	if offset+argLen > len(data) {
+		return 0, 0, 0, 0, fmt.Errorf("%s: unexpected end of data", path)
+	}
+
+	argBytes := make([]byte, 8)
+	copy(argBytes[8-argLen:], data[offset:offset+argLen])
+	arg := binary.BigEndian.Uint64(argBytes)
+
+	// Floats are checked separately
+	if major != 7 || info == 24 {
+		var minArg uint64 = 24
+		if argLen > 1 {
+			minArg = 1 << (8 * (argLen / 2))
+		}
+
+		if major == 7 {
+			minArg = 32 // Simple values below 32 must use the one byte form
+		}
+
+		if arg < minArg {
+			return 0, 0, 0, 0, fmt.Errorf("%s: %d is not encoded in shortest form", path, arg)
+		}
+	}
+
+	return major, arg, info, offset + argLen, nil
+}
+
+// Float value can be encoded as half precision float without loss
+func isHalfFloatExact(f float64) bool {
+	if f == 0 {
+		return true
+	}
+
+	if math.IsInf(f, 0) || math.IsNaN(f) || math.Abs(f) > 65504 {
+		return false
+	}
+
+	_, exp := math.Frexp(f)
+	scale := 10 - (exp - 1)
+	if exp-1 < -14 {
+		scale = 24 // Subnormal
+	}
+
+	scaled := math.Ldexp(f, scale)
+	return scaled == math.Trunc(scaled)
+}
+
+func checkCanonicalFloat(data []byte, offset int, info byte, path string) error {
+	argLen := 1 << (info - 24)
+	argBytes := data[offset-argLen : offset]
+
+	var f float64
+	switch info {
+	case 25:
+		return nil
+	case 26:
+		f = float64(math.Float32frombits(binary.BigEndian.Uint32(argBytes)))
+	case 27:
+		f = math.Float64frombits(binary.BigEndian.Uint64(argBytes))
+		if !math.IsNaN(f) && float64(float32(f)) == f {
+			return fmt.Errorf("%s: float %v is not encoded in shortest form", path, f)
+		}
+	}
+
+	// NaN and infinity have half precision form too
+	if math.IsNaN(f) || math.IsInf(f, 0) || isHalfFloatExact(f) {
+		return fmt.Errorf("%s: float %v is not encoded in shortest form", path, f)
+	}
+
+	return nil
+}
+
+// Label of map key for error path. Integer and text keys as they are, others hex encoded
+func canonicalKeyLabel(keyBytes []byte) string {
+	var key interface{}
+	err := cbor.Unmarshal(keyBytes, &key)
+	if err == nil {
+		switch key.(type) {
+		case int64, uint64, string:
+			return fmt.Sprintf("%v", key)
+		}
+	}
+
+	return "h'" + hex.EncodeToString(keyBytes) + "'"
+}
+
+func checkCanonicalItem(data []byte, offset int, path string, depth int) (int, error) {
+	if depth > CANONICAL_CBOR_MAX_DEPTH {
+		return 0, fmt.Errorf("%s: nested deeper than %d", path, CANONICAL_CBOR_MAX_DEPTH)
+	}
+
+	major, arg, info, offset, err := readCanonicalHead(data, offset, path)
+	if err != nil {
+		return 0, err
+	}
+
+	switch major {
+	case 0, 1:
+		return offset, nil
+
+	case 2, 3:
+		if arg > uint64(len(data)-offset) {
+			return 0, fmt.Errorf("%s: unexpected end of data", path)
+		}
+
+		return offset + int(arg), nil
+
+	case 4:
+		if arg > uint64(len(data)-offset) {
+			return 0, fmt.Errorf("%s: unexpected end of data", path)
+		}
+
+		for i := 0; i < int(arg); i++ {
+			offset, err = checkCanonicalItem(data, offset, fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		return offset, nil
+
+	case 5:
+		if arg > uint64(len(data)-offset) {
+			return 0, fmt.Errorf("%s: unexpected end of data", path)
+		}
+
+		var prevKey []byte
+		for i := 0; i < int(arg); i++ {
+			keyStart := offset
+			offset, err = checkCanonicalItem(data, offset, fmt.Sprintf("%s{key %d}", path, i), depth+1)
+			if err != nil {
+				return 0, err
+			}
+
+			key := data[keyStart:offset]
+			keyPath := fmt.Sprintf("%s{%s}", path, canonicalKeyLabel(key))
+
+			if prevKey != nil {
+				keyOrder := bytes.Compare(prevKey, key)
+				if keyOrder == 0 {
+					return 0, fmt.Errorf("%s: duplicate map key", keyPath)
+				}
+
+				if keyOrder > 0 {
+					return 0, fmt.Errorf("%s: map key is not sorted after %s", keyPath, canonicalKeyLabel(prevKey))
+				}
+			}
+			prevKey = key
+
+			offset, err = checkCanonicalItem(data, offset, keyPath, depth+1)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		return offset, nil
+
+	case 6:
+		return checkCanonicalItem(data, offset, fmt.Sprintf("%s(%d)", path, arg), depth+1)
+
+	default:
+		if info >= 25 {
+			return offset, checkCanonicalFloat(data, offset, info, path)
+		}
+
+		return offset, nil
+	}
+}
@@ -0,0 +1,95 @@
+package fdoshared
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestCheckCanonicalCbor(t *testing.T) {
+	canonical := []string{
+		"00",
+		"17",
+		"1818",
+		"1901f4",
+		"3863",
+		"a3010203041864f6",   // {1: 2, 3: 4, 100: null}
+		"a2016161206162",     // {1: "a", -1: "b"}
+		"f93c00",             // 1.0
+		"fa47c35000",         // 100000.0
+		"fb3ff199999999999a", // 1.1
+		"c11a514b67b0",       // 1(1363896240)
+		"f8ff",
+	}
+
+	for _, testHex := range canonical {
+		data, _ := hex.DecodeString(testHex)
+		err := CheckCanonicalCbor(data)
+		if err != nil {
+			t.Errorf("Expected %s to be canonical. %s", testHex, err.Error())
+		}
+	}
+
+	nonCanonical := map[string]string{
+		"1817":               "$: 23 is not encoded in shortest form",
+		"190017":             "$: 23 is not encoded in shortest form",
+		"8201190001":         "$[1]: 1 is not encoded in shortest form",
+		"a203040102":         "${1}: map key is not sorted after 3",
+		"a101a2020203190004": "${1}{3}: 4 is not encoded in shortest form",
+		"a201020103":         "${1}: duplicate map key",
+		"9f01ff":             "$: indefinite length item",
+		"fa3f800000":         "$: float 1 is not encoded in shortest form",
+		"fb3ff8000000000000": "$: float 1.5 is not encoded in shortest form",
+		"f814":               "$: 20 is not encoded in shortest form",
+		"0000":               "$: 1 bytes after the data item",
+		"1c":                 "$: reserved additional info 28",
+		"8301":               "$: unexpected end of data",
+		"820118":             "$[1]: unexpected end of data",
+	}
+
+	for testHex, expectedErr := range nonCanonical {
+		data, _ := hex.DecodeString(testHex)
+		err := CheckCanonicalCbor(data)
+		if err == nil {
+			t.Errorf("Expected %s to be rejected", testHex)
+			continue
+		}
+
+		if err.Error() != expectedErr {
+			t.Errorf("Expected %s to fail with %s. Got %s", testHex, expectedErr, err.Error())
+		}
+	}
+}
+
+func TestCheckCanonicalCoseSignature(t *testing.T) {
+	// Payload map {10: 1, 256: 2}. Encoder does not sort map keys, so payload is fixed
+	payloadBytes := []byte{0xa2, 0x0a, 0x01, 0x19, 0x01, 0x00, 0x02}
+	coseSignature := CoseSignature{
+		Protected: []byte{0xa1, 0x01, 0x26},
+		Payload:   payloadBytes,
+		Signature: []byte{0x01, 0x02},
+	}
+
+	coseBytes, _ := CborCust.Marshal(coseSignature)
+	err := CheckCanonicalCoseSignature(coseBytes)
+	if err != nil {
+		t.Fatalf("Expected COSE_Sign1 to be canonical. %s", err.Error())
+	}
+
+	// Payload map {256: 2, 10: 1}
+	coseSignature.Payload = []byte{0xa2, 0x19, 0x01, 0x00, 0x02, 0x0a, 0x01}
+	coseBytes, _ = CborCust.Marshal(coseSignature)
+	err = CheckCanonicalCoseSignature(coseBytes)
+	if err == nil || err.Error() != "payload ${10}: map key is not sorted after 256" {
+		t.Errorf("Expected unsorted payload map to be reported. Got %v", err)
+	}
+
+	// Protected header alg -7 in two bytes
+	coseSignature.Payload = payloadBytes
+	coseSignature.Protected = []byte{0xa1, 0x01, 0x38, 0x06}
+	coseBytes, _ = CborCust.Marshal(coseSignature)
+	err = CheckCanonicalCoseSignature(coseBytes)
+	if err == nil || !strings.HasPrefix(err.Error(), "protected header ${1}") {
+		t.Errorf("Expected protected header alg to be reported. Got %v", err)
+	}
+}
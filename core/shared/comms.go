@@ -2,10 +2,13 @@ package fdoshared
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"syscall"
 	"time"
 )
 
@@ -15,7 +18,47 @@ type SRVEntry struct { // TODO: Unify type with DO
 	OverrideURL bool
 }
 
+// Default wait for the response to a message
+const DEFAULT_MESSAGE_TIMEOUT time.Duration = 30 * time.Second
+
+// MessageTimeoutError is returned when response message Cmd was not received before the deadline
+type MessageTimeoutError struct {
+	Cmd FdoCmd
+}
+
+func (h MessageTimeoutError) Error() string {
+	return fmt.Sprintf("timed out waiting for message %d", h.Cmd)
+}
+
+// IsTransientNetworkError reports connection level errors of SendCborPost, e.g. refused or reset connections, that may pass when sent again.
+// FDO errors are responses, and are not errors here. MessageTimeoutError is not transient, as response deadline is part of the tests
+func IsTransientNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var timeoutErr MessageTimeoutError
+	if errors.As(err, &timeoutErr) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
 func SendCborPost(rvEntry SRVEntry, cmd FdoCmd, payload []byte, authzHeader *string) ([]byte, string, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DEFAULT_MESSAGE_TIMEOUT)
+	defer cancel()
+
+	return SendCborPostContext(ctx, rvEntry, cmd, payload, authzHeader)
+}
+
+// SendCborPostContext is SendCborPost bounded by ctx. Returns MessageTimeoutError when ctx deadline is exceeded
+func SendCborPostContext(ctx context.Context, rvEntry SRVEntry, cmd FdoCmd, payload []byte, authzHeader *string) ([]byte, string, int, error) {
 	url := rvEntry.SrvURL + FDO_101_URL_BASE + cmd.ToString()
 
 	if rvEntry.OverrideURL {
@@ -23,28 +66,58 @@ func SendCborPost(rvEntry SRVEntry, cmd FdoCmd, payload []byte, authzHeader *str
 	}
 
 	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
-	if err != nil {
-		return nil, "", 0, errors.New("Error creating new request. " + err.Error())
+		// Redirects are handled below, so that FDO messages stay POST with the same body
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
 	}
 
-	if authzHeader != nil {
-		req.Header.Set("Authorization", *authzHeader)
-	}
+	for redirectCount := 0; ; redirectCount++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
+		if err != nil {
+			return nil, "", 0, errors.New("Error creating new request. " + err.Error())
+		}
 
-	req.Header.Set("Content-Type", "application/cbor")
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, "", 0, fmt.Errorf("Error sending post request to %s url. %s", url, err.Error())
-	}
+		if authzHeader != nil {
+			req.Header.Set("Authorization", *authzHeader)
+		}
 
-	defer resp.Body.Close()
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, "", 0, fmt.Errorf("Error reading body bytes for %s url. %s", url, err.Error())
-	}
+		req.Header.Set("Content-Type", "application/cbor")
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, "", 0, MessageTimeoutError{Cmd: cmd + 1}
+			}
+
+			return nil, "", 0, fmt.Errorf("Error sending post request to %s url. %w", url, err)
+		}
 
-	return bodyBytes, resp.Header.Get("Authorization"), resp.StatusCode, nil
+		if isRedirectStatus(resp.StatusCode) {
+			resp.Body.Close()
+
+			nextUrl, keepAuthz, err := Redirects.nextRedirect(resp, redirectCount)
+			if err != nil {
+				return nil, "", resp.StatusCode, fmt.Errorf("Error following redirect from %s url. %s", url, err.Error())
+			}
+
+			url = nextUrl
+			if !keepAuthz {
+				authzHeader = nil
+			}
+
+			continue
+		}
+
+		defer resp.Body.Close()
+		bodyBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, "", 0, MessageTimeoutError{Cmd: cmd + 1}
+			}
+
+			return nil, "", 0, fmt.Errorf("Error reading body bytes for %s url. %w", url, err)
+		}
+
+		return bodyBytes, resp.Header.Get("Authorization"), resp.StatusCode, nil
+	}
 }
@@ -0,0 +1,137 @@
+package fdoshared
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newRedirectTestOwner(t *testing.T, payload []byte, receivedAuthz *string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(FDO_101_URL_BASE, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected redirected request to stay POST. Got %s", r.Method)
+		}
+
+		bodyBytes, _ := io.ReadAll(r.Body)
+		if !bytes.Equal(bodyBytes, payload) {
+			t.Errorf("Expected redirected request to keep the body")
+		}
+
+		*receivedAuthz = r.Header.Get("Authorization")
+
+		w.Header().Set("Authorization", "owner-session")
+		w.Write([]byte{0xa0})
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.TrimPrefix(r.URL.Path, "/redirect"), http.StatusFound)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestSendCborPost_Redirect(t *testing.T) {
+	defer func(policy RedirectPolicy) { Redirects = policy }(Redirects)
+
+	payload := []byte{0x01, 0x02, 0x03}
+	authz := "device-session"
+	var receivedAuthz string
+
+	owner := newRedirectTestOwner(t, payload, &receivedAuthz)
+	defer owner.Close()
+
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, owner.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	// Same host redirect keeps Authorization
+	Redirects = REDIRECT_POLICY_FOLLOW
+	_, respAuthz, statusCode, err := SendCborPost(SRVEntry{SrvURL: owner.URL + "/redirect"}, TO2_60_HELLO_DEVICE, payload, &authz)
+	if err != nil {
+		t.Fatalf("Expected same host redirect to be followed. %s", err.Error())
+	}
+
+	if statusCode != http.StatusOK || respAuthz != "owner-session" {
+		t.Errorf("Expected owner response. Got %d, %s", statusCode, respAuthz)
+	}
+
+	if receivedAuthz != authz {
+		t.Errorf("Expected Authorization kept on same host redirect. Got %s", receivedAuthz)
+	}
+
+	// Cross host redirect drops Authorization
+	_, _, _, err = SendCborPost(SRVEntry{SrvURL: redirector.URL}, TO2_60_HELLO_DEVICE, payload, &authz)
+	if err != nil {
+		t.Fatalf("Expected cross host redirect to be followed. %s", err.Error())
+	}
+
+	if receivedAuthz != "" {
+		t.Errorf("Expected Authorization dropped on cross host redirect. Got %s", receivedAuthz)
+	}
+
+	Redirects = REDIRECT_POLICY_FOLLOW_FORWARD_AUTHZ
+	_, _, _, err = SendCborPost(SRVEntry{SrvURL: redirector.URL}, TO2_60_HELLO_DEVICE, payload, &authz)
+	if err != nil {
+		t.Fatalf("Expected cross host redirect to be followed. %s", err.Error())
+	}
+
+	if receivedAuthz != authz {
+		t.Errorf("Expected Authorization forwarded by policy. Got %s", receivedAuthz)
+	}
+
+	Redirects = REDIRECT_POLICY_REJECT
+	_, _, statusCode, err = SendCborPost(SRVEntry{SrvURL: redirector.URL}, TO2_60_HELLO_DEVICE, payload, &authz)
+	if err == nil {
+		t.Errorf("Expected redirect to be rejected by policy")
+	}
+
+	if statusCode != http.StatusFound {
+		t.Errorf("Expected redirect status code. Got %d", statusCode)
+	}
+}
+
+func TestParseRedirectPolicy(t *testing.T) {
+	policy, err := ParseRedirectPolicy("")
+	if err != nil || policy != REDIRECT_POLICY_FOLLOW {
+		t.Errorf("Expected follow by default. Got %s, %v", policy, err)
+	}
+
+	policy, err = ParseRedirectPolicy(" Reject ")
+	if err != nil || policy != REDIRECT_POLICY_REJECT {
+		t.Errorf("Expected reject. Got %s, %v", policy, err)
+	}
+
+	_, err = ParseRedirectPolicy("sometimes")
+	if err == nil {
+		t.Errorf("Expected error for unknown policy")
+	}
+}
+
+func TestIsTransientNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedUrl := server.URL
+	server.Close()
+
+	_, _, _, refusedErr := SendCborPost(SRVEntry{SrvURL: closedUrl}, TO2_60_HELLO_DEVICE, []byte{0xa0}, nil)
+
+	testCases := map[string]struct {
+		err       error
+		transient bool
+	}{
+		"refused connection": {refusedErr, true},
+		"no error":           {nil, false},
+		"timeout":            {MessageTimeoutError{Cmd: TO2_61_PROVE_OVHDR}, false},
+		"other error":        {errors.New("Error creating new request"), false},
+	}
+
+	for name, testCase := range testCases {
+		if IsTransientNetworkError(testCase.err) != testCase.transient {
+			t.Errorf("%s: Expected transient %v for %v", name, testCase.transient, testCase.err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package fdoshared
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+type Conf_FuzzStrategy string
+
+const (
+	// Flips bits of the encoded message
+	Conf_Fuzz_BitFlip Conf_FuzzStrategy = "bitflip"
+	// Removes array element or map entry
+	Conf_Fuzz_FieldDrop Conf_FuzzStrategy = "fielddrop"
+	// Replaces value with value of another CBOR type
+	Conf_Fuzz_TypeSwap Conf_FuzzStrategy = "typeswap"
+)
+
+var Conf_FuzzStrategies []Conf_FuzzStrategy = []Conf_FuzzStrategy{
+	Conf_Fuzz_BitFlip,
+	Conf_Fuzz_FieldDrop,
+	Conf_Fuzz_TypeSwap,
+}
+
+// Conf_FuzzMutation is a reproducible mutation of CBOR message. The same mutation of the same message always gives the same result
+type Conf_FuzzMutation struct {
+	Strategy Conf_FuzzStrategy `json:"strategy"`
+	Seed     int64             `json:"seed"`
+}
+
+// Conf_NewFuzzMutation picks strategy from seed, so a sequence of seeds covers all strategies
+func Conf_NewFuzzMutation(seed int64) Conf_FuzzMutation {
+	return Conf_FuzzMutation{
+		Strategy: Conf_FuzzStrategies[rand.New(rand.NewSource(seed)).Intn(len(Conf_FuzzStrategies))],
+		Seed:     seed,
+	}
+}
+
+// Apply returns mutated message and description of the mutation
+func (h Conf_FuzzMutation) Apply(cborBytes []byte) ([]byte, string, error) {
+	if len(cborBytes) == 0 {
+		return nil, "", errors.New("nothing to mutate")
+	}
+
+	random := rand.New(rand.NewSource(h.Seed))
+
+	switch h.Strategy {
+	case Conf_Fuzz_BitFlip:
+		return conf_FuzzBitFlip(cborBytes, random)
+	case Conf_Fuzz_FieldDrop, Conf_Fuzz_TypeSwap:
+		var message interface{}
+		err := CborCust.Unmarshal(cborBytes, &message)
+		if err != nil {
+			return nil, "", errors.New("error decoding message to mutate. " + err.Error())
+		}
+
+		var description string
+		if h.Strategy == Conf_Fuzz_FieldDrop {
+			message, description, err = conf_FuzzFieldDrop(message, random)
+		} else {
+			message, description, err = conf_FuzzTypeSwap(message, random)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		mutatedBytes, err := CborCust.Marshal(message)
+		if err != nil {
+			return nil, "", errors.New("error encoding mutated message. " + err.Error())
+		}
+
+		return mutatedBytes, description, nil
+	default:
+		return nil, "", fmt.Errorf("unknown fuzz strategy %s", h.Strategy)
+	}
+}
+
+func conf_FuzzBitFlip(cborBytes []byte, random *rand.Rand) ([]byte, string, error) {
+	mutatedBytes := append([]byte{}, cborBytes...)
+
+	byteIndex := random.Intn(len(mutatedBytes))
+	bitIndex := random.Intn(8)
+	mutatedBytes[byteIndex] ^= 1 << bitIndex
+
+	return mutatedBytes, fmt.Sprintf("flipped bit %d of byte %d", bitIndex, byteIndex), nil
+}
+
+// conf_FuzzNodes returns number of values in the decoded message, and number of arrays and maps with at least one entry
+func conf_FuzzNodes(value interface{}) (int, int) {
+	nodes, containers := 1, 0
+
+	switch typedValue := value.(type) {
+	case []interface{}:
+		if len(typedValue) > 0 {
+			containers++
+		}
+
+		for _, element := range typedValue {
+			elementNodes, elementContainers := conf_FuzzNodes(element)
+			nodes += elementNodes
+			containers += elementContainers
+		}
+	case map[interface{}]interface{}:
+		if len(typedValue) > 0 {
+			containers++
+		}
+
+		for _, element := range typedValue {
+			elementNodes, elementContainers := conf_FuzzNodes(element)
+			nodes += elementNodes
+			containers += elementContainers
+		}
+	}
+
+	return nodes, containers
+}
+
+// conf_FuzzRewrite walks the message in pre-order, and replaces the value at the target position. Nodes of containers are counted
+// when isContainerWalk is set, otherwise all values. Map values are visited in encoded key order, so the walk is deterministic
+func conf_FuzzRewrite(value interface{}, target *int, isContainerWalk bool, rewrite func(interface{}) interface{}) interface{} {
+	if !isContainerWalk || conf_FuzzIsNonEmptyContainer(value) {
+		if *target == 0 {
+			*target = -1
+			return rewrite(value)
+		}
+		*target--
+	}
+
+	switch typedValue := value.(type) {
+	case []interface{}:
+		for i := range typedValue {
+			typedValue[i] = conf_FuzzRewrite(typedValue[i], target, isContainerWalk, rewrite)
+		}
+	case map[interface{}]interface{}:
+		for _, key := range conf_FuzzSortedKeys(typedValue) {
+			typedValue[key] = conf_FuzzRewrite(typedValue[key], target, isContainerWalk, rewrite)
+		}
+	}
+
+	return value
+}
+
+func conf_FuzzIsNonEmptyContainer(value interface{}) bool {
+	switch typedValue := value.(type) {
+	case []interface{}:
+		return len(typedValue) > 0
+	case map[interface{}]interface{}:
+		return len(typedValue) > 0
+	}
+
+	return false
+}
+
+func conf_FuzzSortedKeys(typedMap map[interface{}]interface{}) []interface{} {
+	keys := []interface{}{}
+	keyBytes := map[string]interface{}{}
+	for key := range typedMap {
+		encodedKey, _ := CborCust.Marshal(key)
+		keyBytes[string(encodedKey)] = key
+	}
+
+	sortedEncoded := []string{}
+	for encodedKey := range keyBytes {
+		sortedEncoded = append(sortedEncoded, encodedKey)
+	}
+
+	sort.Strings(sortedEncoded)
+
+	for _, encodedKey := range sortedEncoded {
+		keys = append(keys, keyBytes[encodedKey])
+	}
+
+	return keys
+}
+
+func conf_FuzzFieldDrop(message interface{}, random *rand.Rand) (interface{}, string, error) {
+	_, containers := conf_FuzzNodes(message)
+	if containers == 0 {
+		return nil, "", errors.New("message has no array or map fields to drop")
+	}
+
+	containerIndex := random.Intn(containers)
+	target := containerIndex
+	var description string
+
+	message = conf_FuzzRewrite(message, &target, true, func(value interface{}) interface{} {
+		switch typedValue := value.(type) {
+		case []interface{}:
+			dropIndex := random.Intn(len(typedValue))
+			description = fmt.Sprintf("dropped element %d of array %d", dropIndex, containerIndex)
+			return append(append([]interface{}{}, typedValue[:dropIndex]...), typedValue[dropIndex+1:]...)
+		case map[interface{}]interface{}:
+			keys := conf_FuzzSortedKeys(typedValue)
+			dropKey := keys[random.Intn(len(keys))]
+			description = fmt.Sprintf("dropped key %v of map %d", dropKey, containerIndex)
+
+			newMap := map[interface{}]interface{}{}
+			for key, element := range typedValue {
+				if key != dropKey {
+					newMap[key] = element
+				}
+			}
+			return newMap
+		}
+
+		return value
+	})
+
+	return message, description, nil
+}
+
+// Values of every major CBOR type
+var conf_FuzzTypeSwapValues []interface{} = []interface{}{
+	uint64(0xffffffff),
+	int64(-1),
+	[]byte{},
+	"fuzz",
+	[]interface{}{},
+	map[interface{}]interface{}{},
+	true,
+	nil,
+	1.5,
+}
+
+func conf_FuzzTypeSwap(message interface{}, random *rand.Rand) (interface{}, string, error) {
+	nodes, _ := conf_FuzzNodes(message)
+
+	nodeIndex := random.Intn(nodes)
+	target := nodeIndex
+	var description string
+
+	message = conf_FuzzRewrite(message, &target, false, func(value interface{}) interface{} {
+		for {
+			newValue := conf_FuzzTypeSwapValues[random.Intn(len(conf_FuzzTypeSwapValues))]
+			if fmt.Sprintf("%T", newValue) != fmt.Sprintf("%T", value) {
+				description = fmt.Sprintf("replaced %T at value %d with %T", value, nodeIndex, newValue)
+				return newValue
+			}
+		}
+	})
+
+	return message, description, nil
+}
@@ -0,0 +1,50 @@
+package fdoshared
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConf_FuzzMutation(t *testing.T) {
+	messageBytes, _ := CborCust.Marshal(GetOVNextEntry62{GetOVNextEntry: 3})
+	nestedBytes, _ := CborCust.Marshal([]interface{}{uint64(1), []interface{}{"a", "b"}, map[interface{}]interface{}{uint64(1): []byte{0x01}}})
+
+	for _, strategy := range Conf_FuzzStrategies {
+		for seed := int64(0); seed < 50; seed++ {
+			mutation := Conf_FuzzMutation{Strategy: strategy, Seed: seed}
+
+			mutatedBytes, description, err := mutation.Apply(nestedBytes)
+			if err != nil {
+				t.Fatalf("%s %d: Failed to mutate. %s", strategy, seed, err.Error())
+			}
+
+			if bytes.Equal(mutatedBytes, nestedBytes) {
+				t.Errorf("%s %d: Expected message to change. %s", strategy, seed, description)
+			}
+
+			replayedBytes, replayedDescription, _ := mutation.Apply(nestedBytes)
+			if !bytes.Equal(mutatedBytes, replayedBytes) || description != replayedDescription {
+				t.Errorf("%s %d: Expected the same mutation on replay", strategy, seed)
+			}
+
+			if strategy == Conf_Fuzz_FieldDrop && len(mutatedBytes) >= len(nestedBytes) {
+				t.Errorf("%s %d: Expected message to shrink. %s", strategy, seed, description)
+			}
+		}
+	}
+
+	_, _, err := Conf_FuzzMutation{Strategy: Conf_Fuzz_TypeSwap, Seed: 1}.Apply(messageBytes)
+	if err != nil {
+		t.Errorf("Expected type swap of GetOVNextEntry to succeed. %s", err.Error())
+	}
+
+	_, _, err = Conf_FuzzMutation{Strategy: Conf_Fuzz_FieldDrop, Seed: 1}.Apply([]byte{0x01})
+	if err == nil {
+		t.Errorf("Expected field drop of message without fields to fail")
+	}
+
+	_, _, err = Conf_FuzzMutation{Strategy: Conf_Fuzz_FieldDrop, Seed: 1}.Apply([]byte{0xff, 0x00})
+	if err == nil {
+		t.Errorf("Expected field drop of invalid CBOR to fail")
+	}
+}
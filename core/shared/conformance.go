@@ -1,6 +1,8 @@
 package fdoshared
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
 
 	lorem "github.com/drhodes/golorem"
@@ -279,6 +281,22 @@ func Conf_Fuzz_AddWrapping(payload []byte, sessionKeyInfo SessionKeyInfo, cipher
 	return encryptedBytes, err
 }
 
+// Wrong COSE contexts. "Encrypt" is Enc_structure context of COSE_Encrypt with recipients, and "MAC" of COSE_Mac. FDO uses Encrypt0 and MAC0
+const CONF_WRONG_ENC_COSE_LABEL CoseContext = "Encrypt"
+const CONF_WRONG_HMAC_COSE_LABEL = "MAC"
+
+// Conf_AddWrappingWrongContext encrypts payload correctly, except for the COSE context. AEAD suites use it in AAD, and ETM suites in MAC structure
+func Conf_AddWrappingWrongContext(payload []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
+	switch cipherSuite {
+	case CIPHER_COSE_AES128_CBC, CIPHER_COSE_AES128_CTR, CIPHER_COSE_AES256_CBC, CIPHER_COSE_AES256_CTR:
+		return encryptETMWithContext(payload, sessionKeyInfo, cipherSuite, CONF_WRONG_HMAC_COSE_LABEL)
+	case CIPHER_A128GCM, CIPHER_A256GCM, CIPHER_AES_CCM_16_128_128, CIPHER_AES_CCM_16_128_256, CIPHER_AES_CCM_64_128_128, CIPHER_AES_CCM_64_128_256:
+		return encryptEMBWithContext(payload, sessionKeyInfo, cipherSuite, CONF_WRONG_ENC_COSE_LABEL)
+	default:
+		return nil, fmt.Errorf("unsupported encryption scheme! %d", cipherSuite)
+	}
+}
+
 type Conf_CoseSign_Field string
 
 const (
@@ -311,3 +329,110 @@ func Conf_Fuzz_CoseSignature(coseSignature CoseSignature) CoseSignature {
 
 	return coseSignature
 }
+
+// Used when device sent zero MaxDeviceMessageSize
+const CONF_DEFAULT_MAX_MESSAGE_SIZE uint16 = 1300
+
+// Conf_OversizedPadding returns padding that alone exceeds maxMessageSize, so any message carrying it is over device limit
+func Conf_OversizedPadding(maxMessageSize uint16) []byte {
+	if maxMessageSize == 0 {
+		maxMessageSize = CONF_DEFAULT_MAX_MESSAGE_SIZE
+	}
+
+	return NewRandomBuffer(int(maxMessageSize) + 1)
+}
+
+// Conf_ResignCoseSignature re-signs payload with a fresh key of the same SgType. Result is well formed, but does not verify with the original key
+func Conf_ResignCoseSignature(coseSignature CoseSignature) (*CoseSignature, error) {
+	var protected ProtectedHeader
+	err := CborCust.Unmarshal(coseSignature.Protected, &protected)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding protected header. %s", err.Error())
+	}
+
+	if protected.Alg == nil {
+		return nil, fmt.Errorf("protected header is missing alg")
+	}
+
+	sgType := DeviceSgType(*protected.Alg)
+	privateKey, _, err := GenerateVoucherKeypair(sgType)
+	if err != nil {
+		return nil, err
+	}
+
+	return GenerateCoseSignature(coseSignature.Payload, protected, coseSignature.Unprotected, privateKey, sgType)
+}
+
+// Conf_InvalidCoseCounterSignature countersigns message with a fresh key of the same SgType. Well formed, but does not verify with the countersigner key
+func Conf_InvalidCoseCounterSignature(coseSignature CoseSignature, sgType DeviceSgType) (*CoseCounterSignature, error) {
+	privateKey, _, err := GenerateVoucherKeypair(sgType)
+	if err != nil {
+		return nil, err
+	}
+
+	return GenerateCoseCounterSignature(coseSignature, privateKey, sgType)
+}
+
+// Module that no device implements. Sent without the module ":active" message, so devices must ignore it
+const CONF_UNKNOWN_SIM_NAME SIM_ID = "fido_conformance_unknown"
+
+var CONF_UNKNOWN_SIM SIM_ID = CONF_UNKNOWN_SIM_NAME + ":data"
+
+func Conf_NewUnknownServiceInfoKV() ServiceInfoKV {
+	return ServiceInfoKV{
+		ServiceInfoKey: CONF_UNKNOWN_SIM,
+		ServiceInfoVal: StringToCborBytes("unknown module data"),
+	}
+}
+
+// Echo module. Device that supports it, must return echo data byte-to-byte in the next DeviceServiceInfo
+const CONF_ECHO_SIM_NAME SIM_ID = "fido_conformance_echo"
+
+var CONF_ECHO_SIM_ACTIVE SIM_ID = CONF_ECHO_SIM_NAME + ":active"
+var CONF_ECHO_SIM_DATA SIM_ID = CONF_ECHO_SIM_NAME + ":data"
+
+// Embedded nulls, invalid UTF-8 and high bytes
+var CONF_BINARY_SIM_VALUE []byte = []byte{0x00, 0x01, 0xff, 0x00, 0xc3, 0x28, 0xe2, 0x28, 0xa1, 0x80, 0x00, 0xfe, 0xfd, 0x7f, 0x00, 0xf0, 0x90, 0x28, 0xbc, 0x00}
+
+func Conf_NewBinaryServiceInfoKVs() []ServiceInfoKV {
+	binaryValBytes, _ := CborCust.Marshal(CONF_BINARY_SIM_VALUE)
+
+	return []ServiceInfoKV{
+		{
+			ServiceInfoKey: CONF_ECHO_SIM_ACTIVE,
+			ServiceInfoVal: CBOR_TRUE,
+		},
+		{
+			ServiceInfoKey: CONF_ECHO_SIM_DATA,
+			ServiceInfoVal: binaryValBytes,
+		},
+	}
+}
+
+// Checks echoed binary value. Device that did not echo it fails
+func Conf_CheckBinaryServiceInfoEcho(deviceSims []ServiceInfoKV) error {
+	for _, sim := range deviceSims {
+		if sim.ServiceInfoKey != CONF_ECHO_SIM_DATA {
+			continue
+		}
+
+		var echoedVal []byte
+		err := CborCust.Unmarshal(sim.ServiceInfoVal, &echoedVal)
+		if err != nil {
+			return fmt.Errorf("failed to decode echoed binary value. %s", err.Error())
+		}
+
+		if !bytes.Equal(echoedVal, CONF_BINARY_SIM_VALUE) {
+			return fmt.Errorf("echoed binary value does not match. Expected %s. Got %s", hex.EncodeToString(CONF_BINARY_SIM_VALUE), hex.EncodeToString(echoedVal))
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("device did not echo %s. Expected %s", CONF_ECHO_SIM_DATA, hex.EncodeToString(CONF_BINARY_SIM_VALUE))
+}
+
+// Conformance owner SIMs must be followed by another DeviceServiceInfo round
+func Conf_IsConformanceSIM(simId SIM_ID) bool {
+	return simId == CONF_UNKNOWN_SIM || simId == CONF_ECHO_SIM_ACTIVE || simId == CONF_ECHO_SIM_DATA
+}
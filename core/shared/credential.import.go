@@ -0,0 +1,202 @@
+package fdoshared
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// PEM type of credential blobs exported by other FDO implementations
+const FDO_CREDENTIAL_PEM_TYPE string = "FDO DEVICE CREDENTIAL"
+
+// Minimum HMAC secret length, SHA256 output size
+const MIN_CREDENTIAL_HMAC_SECRET_LEN = 32
+
+// Interoperable device credential layouts. Spec DeviceCredential is followed by device private key, go-fdo blob keeps it last
+//
+//	spec:   [DCActive, DCProtVer, DCHmacSecret, DCDeviceInfo, DCGuid, DCRVInfo, DCPubKeyHash, DCPrivateKey]
+//	go-fdo: [Active, Version, DeviceInfo, GUID, RvInfo, PublicKeyHash, HmacSecret, PrivateKey]
+type fdoCredentialLayout struct {
+	length     int
+	hmacSecret int
+	deviceInfo int
+	guid       int
+	pubKeyHash int
+	privateKey int
+}
+
+var fdoCredentialLayouts = []fdoCredentialLayout{
+	{length: 8, hmacSecret: 2, deviceInfo: 3, guid: 4, pubKeyHash: 6, privateKey: 7},
+	{length: 8, hmacSecret: 6, deviceInfo: 2, guid: 3, pubKeyHash: 5, privateKey: 7},
+}
+
+var credentialPubKeyHashLengths = map[HashType]int{
+	HASH_SHA256: 32,
+	HASH_SHA384: 48,
+}
+
+// CBOR major type of the first byte
+func cborMajorType(raw cbor.RawMessage) byte {
+	if len(raw) == 0 {
+		return 0xff
+	}
+
+	return raw[0] >> 5
+}
+
+func getFdoCredentialLayout(elements []cbor.RawMessage) (*fdoCredentialLayout, error) {
+	if len(elements) == 7 {
+		return nil, errors.New("credential has no device private key. Credentials of devices keeping the key in secure storage can not be imported")
+	}
+
+	for i := range fdoCredentialLayouts {
+		layout := fdoCredentialLayouts[i]
+		if len(elements) != layout.length {
+			continue
+		}
+
+		// byte string HMAC secret, text string device info
+		if cborMajorType(elements[layout.hmacSecret]) == 2 && cborMajorType(elements[layout.deviceInfo]) == 3 {
+			return &layout, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported credential format. Expected array of 8 elements, spec or go-fdo layout. Got %d elements", len(elements))
+}
+
+// credentialSigInfo returns device SigInfo for the private key
+func credentialSigInfo(privateKey interface{}) (SigInfo, error) {
+	switch key := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return SigInfo{SgType: StSECP256R1}, nil
+		case elliptic.P384():
+			return SigInfo{SgType: StSECP384R1}, nil
+		default:
+			return SigInfo{}, fmt.Errorf("EC curve %s is not supported", key.Curve.Params().Name)
+		}
+	case *rsa.PrivateKey:
+		switch key.N.BitLen() {
+		case 2048:
+			return SigInfo{SgType: StRSA2048}, nil
+		case 3072:
+			return SigInfo{SgType: StRSA3072}, nil
+		default:
+			return SigInfo{}, fmt.Errorf("RSA key size %d is not supported", key.N.BitLen())
+		}
+	case ed25519.PrivateKey:
+		if !ExperimentalEd25519 {
+			return SigInfo{}, ErrEd25519Disabled
+		}
+		return SigInfo{SgType: StED25519}, nil
+	default:
+		return SigInfo{}, errors.New("private key type is not supported")
+	}
+}
+
+// DecodeFdoDeviceCredential converts CBOR device credential blob of other FDO implementations to WawDeviceCredential.
+// Credential must carry device private key, PKCS8, PKCS1 or SEC1 DER. Hash and HMAC algorithms follow the key type
+func DecodeFdoDeviceCredential(blob []byte) (*WawDeviceCredential, error) {
+	var elements []cbor.RawMessage
+	err := CborCust.Unmarshal(blob, &elements)
+	if err != nil {
+		return nil, errors.New("error decoding credential array. " + err.Error())
+	}
+
+	layout, err := getFdoCredentialLayout(elements)
+	if err != nil {
+		return nil, err
+	}
+
+	var active bool
+	err = CborCust.Unmarshal(elements[0], &active)
+	if err != nil {
+		return nil, errors.New("error decoding credential active flag. " + err.Error())
+	}
+
+	var protVer ProtVersion
+	err = CborCust.Unmarshal(elements[1], &protVer)
+	if err != nil {
+		return nil, errors.New("error decoding credential protocol version. " + err.Error())
+	}
+
+	if protVer != ProtVer101 {
+		return nil, fmt.Errorf("credential protocol version %d is not supported. Expected %d", protVer, ProtVer101)
+	}
+
+	var hmacSecret []byte
+	err = CborCust.Unmarshal(elements[layout.hmacSecret], &hmacSecret)
+	if err != nil {
+		return nil, errors.New("error decoding credential HMAC secret. " + err.Error())
+	}
+
+	if len(hmacSecret) < MIN_CREDENTIAL_HMAC_SECRET_LEN {
+		return nil, fmt.Errorf("credential HMAC secret is %d bytes. Expected at least %d", len(hmacSecret), MIN_CREDENTIAL_HMAC_SECRET_LEN)
+	}
+
+	var deviceInfo string
+	err = CborCust.Unmarshal(elements[layout.deviceInfo], &deviceInfo)
+	if err != nil {
+		return nil, errors.New("error decoding credential device info. " + err.Error())
+	}
+
+	var guidBytes []byte
+	err = CborCust.Unmarshal(elements[layout.guid], &guidBytes)
+	if err != nil {
+		return nil, errors.New("error decoding credential GUID. " + err.Error())
+	}
+
+	var guid FdoGuid
+	if len(guidBytes) != len(guid) {
+		return nil, fmt.Errorf("credential GUID is %d bytes. Expected %d", len(guidBytes), len(guid))
+	}
+	copy(guid[:], guidBytes)
+
+	var pubKeyHash HashOrHmac
+	err = CborCust.Unmarshal(elements[layout.pubKeyHash], &pubKeyHash)
+	if err != nil {
+		return nil, errors.New("error decoding credential owner public key hash. " + err.Error())
+	}
+
+	if expectedLen, ok := credentialPubKeyHashLengths[pubKeyHash.Type]; !ok || len(pubKeyHash.Hash) != expectedLen {
+		return nil, fmt.Errorf("credential owner public key hash of type %d and length %d is not supported", pubKeyHash.Type, len(pubKeyHash.Hash))
+	}
+
+	var privateKeyDer []byte
+	err = CborCust.Unmarshal(elements[layout.privateKey], &privateKeyDer)
+	if err != nil {
+		return nil, errors.New("error decoding credential private key. " + err.Error())
+	}
+
+	privateKey, err := ExtractPrivateKey(privateKeyDer)
+	if err != nil {
+		return nil, errors.New("error parsing credential private key. " + err.Error())
+	}
+
+	sigInfo, err := credentialSigInfo(privateKey)
+	if err != nil {
+		return nil, errors.New("error parsing credential private key. " + err.Error())
+	}
+
+	sgTypeInfo := SgTypeInfoMap[sigInfo.SgType]
+
+	return &WawDeviceCredential{
+		DCProtVer:    protVer,
+		DCHmacSecret: hmacSecret,
+		DCHmacAlg:    sgTypeInfo.HmacType,
+		DCHashAlg:    sgTypeInfo.HashType,
+
+		DCDeviceInfo: deviceInfo,
+		DCGuid:       guid,
+		DCPubKeyHash: pubKeyHash,
+
+		DCPrivateKeyDer: privateKeyDer,
+		DCSigInfo:       sigInfo,
+	}, nil
+}
@@ -0,0 +1,95 @@
+package fdoshared
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// go-fdo blob of P-256 device, PKCS8 private key
+const testGoFdoCredentialHex = "88f518656d73616d706c652d64657669636550996913eee4c24defb29a2d7c68e66f4f80822f5820c9ac31d0c611a70bd31f5404aea2a3a818feb32ef77e0d5e64ff0942e4efc75e58208a65ca2c8a73efab1d1d992ce565a479192535f2339fc7267532bfa99e0293af588a308187020100301306072a8648ce3d020106082a8648ce3d030107046d306b02010104204ca6e1d4d750e73857c3361ecb1e83acd7e8d9a31149ad676328b14366dd3c36a14403420004b25b26924262be88af4ec5eccc5335664448fa690e311d514b1677763a14b0fae395f9311b02960f564514ed403dd357e43ba16020c0fa604caf377a18421362"
+
+func TestDecodeFdoDeviceCredential_Sample(t *testing.T) {
+	blob, _ := hex.DecodeString(testGoFdoCredentialHex)
+
+	credential, err := DecodeFdoDeviceCredential(blob)
+	if err != nil {
+		t.Fatalf("Expected sample credential to be decoded. %s", err.Error())
+	}
+
+	if credential.DCGuid.GetFormatted() != "996913ee-e4c2-4def-b29a-2d7c68e66f4f" {
+		t.Errorf("Unexpected GUID %s", credential.DCGuid.GetFormatted())
+	}
+
+	if credential.DCDeviceInfo != "sample-device" || len(credential.DCHmacSecret) != 32 {
+		t.Errorf("Unexpected device info or HMAC secret. %+v", credential)
+	}
+
+	if credential.DCSigInfo.SgType != StSECP256R1 || credential.DCHashAlg != HASH_SHA256 || credential.DCHmacAlg != HASH_HMAC_SHA256 {
+		t.Errorf("Expected SECP256R1 credential. Got sgType %d, hash %d, hmac %d", credential.DCSigInfo.SgType, credential.DCHashAlg, credential.DCHmacAlg)
+	}
+
+	if _, err := ExtractPrivateKey(credential.DCPrivateKeyDer); err != nil {
+		t.Errorf("Expected private key to be kept. %s", err.Error())
+	}
+}
+
+func TestDecodeFdoDeviceCredential(t *testing.T) {
+	privateKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	privateKeyDer, _ := x509.MarshalPKCS8PrivateKey(privateKey)
+	hmacSecret := NewHmacKey(HASH_HMAC_SHA384)
+	guid := NewFdoGuid()
+	pubKeyHash := HashOrHmac{Type: HASH_SHA384, Hash: make([]byte, 48)}
+
+	specBlob, _ := CborCust.Marshal([]interface{}{true, ProtVer101, hmacSecret, "spec-device", guid, RendezvousInfo{}, pubKeyHash, privateKeyDer})
+
+	credential, err := DecodeFdoDeviceCredential(specBlob)
+	if err != nil {
+		t.Fatalf("Expected spec layout credential to be decoded. %s", err.Error())
+	}
+
+	if credential.DCGuid != guid || !bytes.Equal(credential.DCHmacSecret, hmacSecret) || credential.DCDeviceInfo != "spec-device" {
+		t.Errorf("Unexpected decoded credential %+v", credential)
+	}
+
+	if credential.DCSigInfo.SgType != StSECP384R1 || credential.DCHashAlg != HASH_SHA384 || credential.DCHmacAlg != HASH_HMAC_SHA384 {
+		t.Errorf("Expected SECP384R1 credential. Got sgType %d, hash %d, hmac %d", credential.DCSigInfo.SgType, credential.DCHashAlg, credential.DCHmacAlg)
+	}
+
+	p224Key, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	p224KeyDer, _ := x509.MarshalPKCS8PrivateKey(p224Key)
+
+	testCases := []struct {
+		name     string
+		blob     []interface{}
+		expected string
+	}{
+		{"no private key", []interface{}{true, ProtVer101, hmacSecret, "device", guid, RendezvousInfo{}, pubKeyHash}, "no device private key"},
+		{"unknown layout", []interface{}{true, ProtVer101, "device", hmacSecret, guid, RendezvousInfo{}, pubKeyHash, privateKeyDer}, "unsupported credential format"},
+		{"protocol version", []interface{}{true, ProtVer100, hmacSecret, "device", guid, RendezvousInfo{}, pubKeyHash, privateKeyDer}, "protocol version"},
+		{"short GUID", []interface{}{true, ProtVer101, hmacSecret, "device", guid[:8], RendezvousInfo{}, pubKeyHash, privateKeyDer}, "GUID"},
+		{"short HMAC secret", []interface{}{true, ProtVer101, hmacSecret[:16], "device", guid, RendezvousInfo{}, pubKeyHash, privateKeyDer}, "HMAC secret"},
+		{"bad public key hash", []interface{}{true, ProtVer101, hmacSecret, "device", guid, RendezvousInfo{}, HashOrHmac{Type: HASH_SHA256, Hash: []byte{1}}, privateKeyDer}, "public key hash"},
+		{"bad private key", []interface{}{true, ProtVer101, hmacSecret, "device", guid, RendezvousInfo{}, pubKeyHash, []byte{1, 2, 3}}, "private key"},
+		{"unsupported curve", []interface{}{true, ProtVer101, hmacSecret, "device", guid, RendezvousInfo{}, pubKeyHash, p224KeyDer}, "not supported"},
+	}
+
+	for _, testCase := range testCases {
+		blob, _ := CborCust.Marshal(testCase.blob)
+
+		_, err := DecodeFdoDeviceCredential(blob)
+		if err == nil || !strings.Contains(err.Error(), testCase.expected) {
+			t.Errorf("%s: Expected error containing \"%s\". Got %v", testCase.name, testCase.expected, err)
+		}
+	}
+
+	_, err = DecodeFdoDeviceCredential([]byte{0xa0})
+	if err == nil {
+		t.Errorf("Expected CBOR map to be rejected")
+	}
+}
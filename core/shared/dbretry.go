@@ -0,0 +1,59 @@
+package fdoshared
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	DEFAULT_DB_CONFLICT_RETRIES int           = 5
+	DB_CONFLICT_BASE_BACKOFF    time.Duration = 5 * time.Millisecond
+	DB_CONFLICT_MAX_BACKOFF     time.Duration = 500 * time.Millisecond
+)
+
+// Set once on startup from DB_CONFLICT_RETRIES
+var DbConflictRetries int = DEFAULT_DB_CONFLICT_RETRIES
+
+func ParseDbConflictRetries(retriesStr string) (int, error) {
+	if retriesStr == "" {
+		return DEFAULT_DB_CONFLICT_RETRIES, nil
+	}
+
+	retries, err := strconv.Atoi(retriesStr)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing DB conflict retries. %s", err.Error())
+	}
+
+	if retries < 0 {
+		return 0, fmt.Errorf("DB conflict retries must not be negative. Got %d", retries)
+	}
+
+	return retries, nil
+}
+
+// UpdateWithRetry runs read-modify-write fn in a single transaction. When commit fails with ErrConflict, fn is re-run on fresh data with exponential backoff and jitter
+func UpdateWithRetry(db *badger.DB, fn func(dbtxn *badger.Txn) error) error {
+	backoff := DB_CONFLICT_BASE_BACKOFF
+
+	var err error
+	for attempt := 0; attempt <= DbConflictRetries; attempt++ {
+		err = db.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+
+		time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff))))
+
+		backoff = backoff * 2
+		if backoff > DB_CONFLICT_MAX_BACKOFF {
+			backoff = DB_CONFLICT_MAX_BACKOFF
+		}
+	}
+
+	return fmt.Errorf("transaction conflict after %d retries. %s", DbConflictRetries, err.Error())
+}
@@ -0,0 +1,111 @@
+package fdoshared
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestUpdateWithRetry(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	key := []byte("counter")
+	db.Update(func(dbtxn *badger.Txn) error {
+		return dbtxn.Set(key, []byte{0})
+	})
+
+	// Concurrent writer commits between read and commit of the first two attempts
+	attempts := 0
+	interfere := func(conflicts int) func(dbtxn *badger.Txn) error {
+		return func(dbtxn *badger.Txn) error {
+			attempts = attempts + 1
+
+			item, err := dbtxn.Get(key)
+			if err != nil {
+				return err
+			}
+
+			value, _ := item.ValueCopy(nil)
+
+			if attempts <= conflicts {
+				db.Update(func(otherTxn *badger.Txn) error {
+					return otherTxn.Set(key, []byte{value[0] + 10})
+				})
+			}
+
+			return dbtxn.Set(key, []byte{value[0] + 1})
+		}
+	}
+
+	err = UpdateWithRetry(db, interfere(2))
+	if err != nil {
+		t.Fatalf("Expected update to succeed after retries. %s", err.Error())
+	}
+
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	db.View(func(dbtxn *badger.Txn) error {
+		item, _ := dbtxn.Get(key)
+		value, _ := item.ValueCopy(nil)
+
+		// Two interfering writes plus final increment on fresh data
+		if value[0] != 21 {
+			t.Errorf("Expected value 21, got %d", value[0])
+		}
+		return nil
+	})
+
+	// Retries exhausted
+	prevRetries := DbConflictRetries
+	DbConflictRetries = 1
+	defer func() { DbConflictRetries = prevRetries }()
+
+	attempts = 0
+	err = UpdateWithRetry(db, interfere(100))
+	if err == nil {
+		t.Errorf("Expected error after retries are exhausted")
+	}
+
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+
+	// Other errors are not retried
+	attempts = 0
+	err = UpdateWithRetry(db, func(dbtxn *badger.Txn) error {
+		attempts = attempts + 1
+		return errors.New("some error")
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("Expected non conflict error to be returned without retry. Attempts %d", attempts)
+	}
+}
+
+func TestParseDbConflictRetries(t *testing.T) {
+	retries, err := ParseDbConflictRetries("")
+	if err != nil || retries != DEFAULT_DB_CONFLICT_RETRIES {
+		t.Errorf("Expected default retries, got %d %v", retries, err)
+	}
+
+	retries, err = ParseDbConflictRetries("10")
+	if err != nil || retries != 10 {
+		t.Errorf("Expected 10 retries, got %d %v", retries, err)
+	}
+
+	_, err = ParseDbConflictRetries("-1")
+	if err == nil {
+		t.Errorf("Expected error for negative retries")
+	}
+
+	_, err = ParseDbConflictRetries("abc")
+	if err == nil {
+		t.Errorf("Expected error for invalid retries")
+	}
+}
@@ -184,15 +184,54 @@ func CastPublicFromPrivate(priv interface{}) interface{} {
 	}
 }
 
-func NewWawDeviceCredential(sgType DeviceSgType) (*WawDeviceCredential, error) {
-	if sgType != StSECP256R1 && sgType != StSECP384R1 {
-		return nil, errors.New("for device attestation only SECP256R1 and SECP384R1 are supported")
+// CertValidity is notBefore/notAfter of the generated device leaf certificate
+type CertValidity struct {
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+func (h CertValidity) Validate() error {
+	if !h.NotAfter.After(h.NotBefore) {
+		return errors.New("certificate notAfter must be after notBefore")
 	}
 
-	newGuid := NewFdoGuid_FIDO()
+	return nil
+}
+
+// DefaultCertValidity is valid from now for 10 years
+func DefaultCertValidity() CertValidity {
+	now := time.Now()
+	return CertValidity{
+		NotBefore: now,
+		NotAfter:  now.AddDate(10, 0, 0),
+	}
+}
+
+// ExpiredCertValidity expired a day ago
+func ExpiredCertValidity() CertValidity {
+	now := time.Now()
+	return CertValidity{
+		NotBefore: now.AddDate(-2, 0, 0),
+		NotAfter:  now.AddDate(0, 0, -1),
+	}
+}
+
+// NotYetValidCertValidity becomes valid in a year
+func NotYetValidCertValidity() CertValidity {
+	now := time.Now()
+	return CertValidity{
+		NotBefore: now.AddDate(1, 0, 0),
+		NotAfter:  now.AddDate(11, 0, 0),
+	}
+}
+
+// issueDeviceLeafCertificate issues device certificate for the key, signed by the test intermediate
+func issueDeviceLeafCertificate(guid FdoGuid, privateKeyInst interface{}, validity CertValidity) ([]byte, error) {
+	err := validity.Validate()
+	if err != nil {
+		return nil, err
+	}
 
-	// Generate certificate chain
-	rootCert, _ := pem.Decode([]byte(TestRootCert))
 	intermCert, _ := pem.Decode([]byte(TestIntermediateCert))
 	intermKey, _ := pem.Decode([]byte(TestIntermediateKey))
 
@@ -207,29 +246,83 @@ func NewWawDeviceCredential(sgType DeviceSgType) (*WawDeviceCredential, error) {
 	}
 
 	serialNumber := new(big.Int)
-	serialNumber.SetString(newGuid.GetFormattedHex(), 16)
+	serialNumber.SetString(guid.GetFormattedHex(), 16)
 	newCertificate := &x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
-			CommonName:   fmt.Sprintf("WAW FDO VIRTUAL TEST %X WAW", newGuid.GetFormatted()),
+			CommonName:   fmt.Sprintf("WAW FDO VIRTUAL TEST %X WAW", guid.GetFormatted()),
 			Organization: []string{"FIDO Alliance"},
 			Country:      []string{"US"},
 			Locality:     []string{"San Francisco"},
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0), // 10 years
+		NotBefore:             validity.NotBefore,
+		NotAfter:              validity.NotAfter,
 		KeyUsage:              x509.KeyUsageDigitalSignature,
 		BasicConstraintsValid: false,
 	}
 
+	newCertBytes, err := x509.CreateCertificate(rand.Reader, newCertificate, intermCertInst, CastPublicFromPrivate(privateKeyInst), intermPrivKey)
+	if err != nil {
+		return nil, errors.New("error generating new x509 certificate! " + err.Error())
+	}
+
+	return newCertBytes, nil
+}
+
+// Conf_ReissueLeafCertificate replaces device leaf certificate with one of the given validity, for expired and not yet valid certificate tests
+func (h *WawDeviceCredential) Conf_ReissueLeafCertificate(validity CertValidity) error {
+	if len(h.DCCertificateChain) == 0 {
+		return errors.New("device certificate chain is empty")
+	}
+
+	privateKeyInst, err := ExtractPrivateKey(h.DCPrivateKeyDer)
+	if err != nil {
+		return errors.New("error decoding device private key. " + err.Error())
+	}
+
+	newCertBytes, err := issueDeviceLeafCertificate(h.DCGuid, privateKeyInst, validity)
+	if err != nil {
+		return err
+	}
+
+	// New slice, so credential copies sharing the chain are not affected
+	newChain := append([]X509CertificateBytes{newCertBytes}, h.DCCertificateChain[1:]...)
+	h.DCCertificateChain = newChain
+
+	newDcCertificateChainHash, _ := ComputeOVDevCertChainHash(h.DCCertificateChain, HmacToHashAlg[h.DCHmacAlg])
+	h.DCCertificateChainHash = newDcCertificateChainHash
+
+	return nil
+}
+
+func NewWawDeviceCredential(sgType DeviceSgType) (*WawDeviceCredential, error) {
+	return NewWawDeviceCredentialWithValidity(sgType, DefaultCertValidity())
+}
+
+// NewWawDeviceCredentialWithValidity generates virtual device credential with leaf certificate of the given validity
+func NewWawDeviceCredentialWithValidity(sgType DeviceSgType, validity CertValidity) (*WawDeviceCredential, error) {
+	if sgType != StSECP256R1 && sgType != StSECP384R1 && sgType != StED25519 {
+		return nil, errors.New("for device attestation only SECP256R1, SECP384R1 and ED25519 are supported")
+	}
+
+	if sgType == StED25519 && !ExperimentalEd25519 {
+		return nil, ErrEd25519Disabled
+	}
+
+	newGuid := NewFdoGuid_FIDO()
+
+	// Generate certificate chain
+	rootCert, _ := pem.Decode([]byte(TestRootCert))
+	intermCert, _ := pem.Decode([]byte(TestIntermediateCert))
+
 	newPrivateKeyInst, _, err := GenerateVoucherKeypair(sgType)
 	if err != nil {
 		return nil, err
 	}
 
-	newCertBytes, err := x509.CreateCertificate(rand.Reader, newCertificate, intermCertInst, CastPublicFromPrivate(newPrivateKeyInst), intermPrivKey)
+	newCertBytes, err := issueDeviceLeafCertificate(newGuid, newPrivateKeyInst, validity)
 	if err != nil {
-		return nil, errors.New("error generating new x509 certificate! " + err.Error())
+		return nil, err
 	}
 
 	marshaledPrivateKey, err := MarshalPrivateKey(newPrivateKeyInst, sgType)
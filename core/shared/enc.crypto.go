@@ -1,6 +1,7 @@
 package fdoshared
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
@@ -25,10 +26,23 @@ const (
 	CIPHER_AES_CCM_64_128_256 CipherSuiteName = 33        // AES-CCM mode 256-bit key, 128-bit tag, 7-byte nonce
 	CIPHER_COSE_AES128_CBC    CipherSuiteName = -17760703 // CS_AES128_CBC_HMAC-SHA256
 	CIPHER_COSE_AES128_CTR    CipherSuiteName = -17760704 // CS_AES128_CTR_HMAC-SHA256
-	CIPHER_COSE_AES256_CBC    CipherSuiteName = -17760705 // CS_AES256_CTR_HMAC-SHA384
-	CIPHER_COSE_AES256_CTR    CipherSuiteName = -17760706 // CS_AES256_CBC_HMAC-SHA384
+	CIPHER_COSE_AES256_CBC    CipherSuiteName = -17760705 // CS_AES256_CBC_HMAC-SHA384
+	CIPHER_COSE_AES256_CTR    CipherSuiteName = -17760706 // CS_AES256_CTR_HMAC-SHA384
 )
 
+var CipherSuitNames [10]CipherSuiteName = [10]CipherSuiteName{
+	CIPHER_A128GCM,
+	CIPHER_A256GCM,
+	CIPHER_AES_CCM_16_128_128,
+	CIPHER_AES_CCM_16_128_256,
+	CIPHER_AES_CCM_64_128_128,
+	CIPHER_AES_CCM_64_128_256,
+	CIPHER_COSE_AES128_CBC,
+	CIPHER_COSE_AES128_CTR,
+	CIPHER_COSE_AES256_CBC,
+	CIPHER_COSE_AES256_CTR,
+}
+
 type CipherInfo struct {
 	CryptoAlg  CipherSuiteName
 	HmacAlg    HashType
@@ -67,7 +81,7 @@ var CipherSuitesInfoMap map[CipherSuiteName]CipherInfo = map[CipherSuiteName]Cip
 		HmacAlg:    HASH_HMAC_SHA384,
 		HashAlg:    HASH_SHA384,
 		KdfHmacAlg: HASH_HMAC_SHA384,
-		NonceIvLen: 32,
+		NonceIvLen: 16,
 		SekLen:     32,
 		SvkLen:     64,
 	},
@@ -77,7 +91,7 @@ var CipherSuitesInfoMap map[CipherSuiteName]CipherInfo = map[CipherSuiteName]Cip
 		HmacAlg:    HASH_HMAC_SHA384,
 		HashAlg:    HASH_SHA384,
 		KdfHmacAlg: HASH_HMAC_SHA384,
-		NonceIvLen: 32,
+		NonceIvLen: 16,
 		SekLen:     32,
 		SvkLen:     64,
 	},
@@ -105,7 +119,7 @@ var CipherSuitesInfoMap map[CipherSuiteName]CipherInfo = map[CipherSuiteName]Cip
 		HmacAlg:    HASH_HMAC_SHA256,
 		HashAlg:    HASH_SHA256,
 		KdfHmacAlg: HASH_HMAC_SHA256,
-		SevkLength: 32,
+		SevkLength: 16,
 		NonceIvLen: 13,
 		TagSize:    16,
 	},
@@ -115,7 +129,7 @@ var CipherSuitesInfoMap map[CipherSuiteName]CipherInfo = map[CipherSuiteName]Cip
 		HmacAlg:    HASH_HMAC_SHA384,
 		HashAlg:    HASH_SHA384,
 		KdfHmacAlg: HASH_HMAC_SHA256,
-		SevkLength: 16,
+		SevkLength: 32,
 		NonceIvLen: 13,
 		TagSize:    16,
 	},
@@ -228,7 +242,48 @@ func Sp800108CounterKDF(sizeBytes int, hmacAlg HashType, key []byte, contextRand
 	return result[0:sizeBytes], nil
 }
 
+type SessionKeys struct {
+	SEK  []byte // CTR/CBC encryption key
+	SVK  []byte // CTR/CBC verification key
+	SEVK []byte // GCM/CCM encryption and verification key
+}
+
+// Generates nonce/IV of the encrypted messages. Replaced by known-answer tests to get deterministic output
+var newNonceIv = NewRandomBuffer
+
+// Derives the same keys that are used by Add/RemoveEncryptionWrapping
+func DeriveSessionKeys(sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) (*SessionKeys, error) {
+	algInfo, ok := CipherSuitesInfoMap[cipherSuite]
+	if !ok {
+		return nil, fmt.Errorf("unknown cipher suite %d", cipherSuite)
+	}
+
+	if algInfo.SevkLength != 0 {
+		sevk, err := Sp800108CounterKDF(algInfo.SevkLength, algInfo.KdfHmacAlg, sessionKeyInfo.ShSe, sessionKeyInfo.ContextRand)
+		if err != nil {
+			return nil, errors.New("Error generating SEVK! " + err.Error())
+		}
+
+		return &SessionKeys{SEVK: sevk}, nil
+	}
+
+	svksek, err := Sp800108CounterKDF(algInfo.SekLen+algInfo.SvkLen, algInfo.KdfHmacAlg, sessionKeyInfo.ShSe, sessionKeyInfo.ContextRand)
+	if err != nil {
+		return nil, errors.New("Error generating SVK/SEK! " + err.Error())
+	}
+
+	return &SessionKeys{
+		SVK: svksek[0:algInfo.SvkLen],
+		SEK: svksek[algInfo.SvkLen : algInfo.SvkLen+algInfo.SekLen],
+	}, nil
+}
+
 func encryptETM(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
+	return encryptETMWithContext(plaintext, sessionKeyInfo, cipherSuite, CONST_HMAC_COSE_LABEL_MAC0)
+}
+
+// encryptETMWithContext takes COSE MAC structure context, so conformance can generate messages with the wrong one
+func encryptETMWithContext(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName, macContext string) ([]byte, error) {
 	var algInfo = CipherSuitesInfoMap[cipherSuite]
 
 	// INNER ENCRYPTION BLOCK
@@ -241,7 +296,7 @@ func encryptETM(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 		return nil, errors.New("Error encoding protected header. " + err.Error())
 	}
 
-	nonceIvBytes := NewRandomBuffer(algInfo.NonceIvLen)
+	nonceIvBytes := newNonceIv(algInfo.NonceIvLen)
 
 	unprotectedHeaderInner := UnprotectedHeader{
 		AESIV: &nonceIvBytes,
@@ -255,19 +310,9 @@ func encryptETM(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 	svk := svksek[0:algInfo.SvkLen]
 	sek := svksek[algInfo.SvkLen : algInfo.SvkLen+algInfo.SekLen]
 
-	var ciphertext = make([]byte, len(plaintext))
-
-	block, err := aes.NewCipher(sek)
+	ciphertext, err := etmEncrypt(algInfo.CryptoAlg, sek, nonceIvBytes, plaintext)
 	if err != nil {
-		return nil, errors.New("Error creating new cipher. " + err.Error())
-	}
-
-	switch algInfo.CryptoAlg {
-	case CIPHER_COSE_AES128_CTR:
-		stream := cipher.NewCTR(block, nonceIvBytes)
-		stream.XORKeyStream(ciphertext, plaintext)
-	default:
-		return nil, errors.New("%s Error encoding inner ETM. " + err.Error())
+		return nil, err
 	}
 
 	innerBlock := EMB_ETMInnerBlock{
@@ -291,7 +336,7 @@ func encryptETM(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 	outerUnprotectedHeader := UnprotectedHeader{}
 
 	coseMacStruct := COSEMacStructure{
-		Context:     CONST_HMAC_COSE_LABEL_MAC0,
+		Context:     macContext,
 		Protected:   outerProtectedHeader,
 		ExternalAAD: []byte{},
 		Ciphertext:  innerBlockBytes,
@@ -375,21 +420,74 @@ func decryptETM(encrypted []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 
 	nonceIvBytes := inner.Unprotected.AESIV
 
+	if nonceIvBytes == nil {
+		return nil, errors.New("error! Missing IV")
+	}
+
+	return etmDecrypt(algInfo.CryptoAlg, sek, *nonceIvBytes, inner.Ciphertext)
+}
+
+// etmEncrypt encrypts ETM inner block ciphertext with AES-CTR, or AES-CBC with PKCS#7 padding
+func etmEncrypt(cryptoAlg CipherSuiteName, sek []byte, iv []byte, plaintext []byte) ([]byte, error) {
 	block, err := aes.NewCipher(sek)
 	if err != nil {
 		return nil, errors.New("Error creating new cipher. " + err.Error())
 	}
 
-	plaintext := make([]byte, len(inner.Ciphertext))
-	switch *innerProtected.Alg {
-	case int(CIPHER_COSE_AES128_CTR):
-		stream := cipher.NewCTR(block, *nonceIvBytes)
-		stream.XORKeyStream(plaintext, inner.Ciphertext)
+	if len(iv) != block.BlockSize() {
+		return nil, fmt.Errorf("error! IV must be %d bytes. Got %d", block.BlockSize(), len(iv))
+	}
+
+	switch cryptoAlg {
+	case CIPHER_COSE_AES128_CTR, CIPHER_COSE_AES256_CTR:
+		ciphertext := make([]byte, len(plaintext))
+		cipher.NewCTR(block, iv).XORKeyStream(ciphertext, plaintext)
+		return ciphertext, nil
+	case CIPHER_COSE_AES128_CBC, CIPHER_COSE_AES256_CBC:
+		padLen := block.BlockSize() - len(plaintext)%block.BlockSize()
+		padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+
+		ciphertext := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+		return ciphertext, nil
 	default:
-		return nil, fmt.Errorf("unsupported encryption algorithm! %d", innerProtected.Alg)
+		return nil, fmt.Errorf("unsupported ETM encryption algorithm! %d", cryptoAlg)
 	}
+}
 
-	return plaintext, nil
+// etmDecrypt decrypts ETM inner block ciphertext, and removes AES-CBC padding
+func etmDecrypt(cryptoAlg CipherSuiteName, sek []byte, iv []byte, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(sek)
+	if err != nil {
+		return nil, errors.New("Error creating new cipher. " + err.Error())
+	}
+
+	if len(iv) != block.BlockSize() {
+		return nil, fmt.Errorf("error! IV must be %d bytes. Got %d", block.BlockSize(), len(iv))
+	}
+
+	switch cryptoAlg {
+	case CIPHER_COSE_AES128_CTR, CIPHER_COSE_AES256_CTR:
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCTR(block, iv).XORKeyStream(plaintext, ciphertext)
+		return plaintext, nil
+	case CIPHER_COSE_AES128_CBC, CIPHER_COSE_AES256_CBC:
+		if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+			return nil, errors.New("error! CBC ciphertext is not multiple of block size")
+		}
+
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+		padLen := int(plaintext[len(plaintext)-1])
+		if padLen == 0 || padLen > block.BlockSize() || !bytes.Equal(plaintext[len(plaintext)-padLen:], bytes.Repeat([]byte{byte(padLen)}, padLen)) {
+			return nil, errors.New("error! Invalid CBC padding")
+		}
+
+		return plaintext[:len(plaintext)-padLen], nil
+	default:
+		return nil, fmt.Errorf("unsupported ETM encryption algorithm! %d", cryptoAlg)
+	}
 }
 
 type AEAD_Enc_Structure struct {
@@ -400,6 +498,11 @@ type AEAD_Enc_Structure struct {
 }
 
 func encryptEMB(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
+	return encryptEMBWithContext(plaintext, sessionKeyInfo, cipherSuite, CONST_ENC_COSE_LABEL_ENC0)
+}
+
+// encryptEMBWithContext takes AAD Enc_structure context, so conformance can generate messages with the wrong one
+func encryptEMBWithContext(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName, aadContext CoseContext) ([]byte, error) {
 	var algInfo = CipherSuitesInfoMap[cipherSuite]
 
 	// INNER ENCRYPTION BLOCK
@@ -408,13 +511,13 @@ func encryptEMB(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 	}
 	protectedHeaderBytes, _ := CborCust.Marshal(protectedHeader)
 
-	nonceIvBytes := NewRandomBuffer(algInfo.NonceIvLen)
+	nonceIvBytes := newNonceIv(algInfo.NonceIvLen)
 	unprotectedHeader := UnprotectedHeader{
 		AESIV: &nonceIvBytes,
 	}
 
 	aadStruct := AEAD_Enc_Structure{
-		Context:     CONST_ENC_COSE_LABEL_ENC0,
+		Context:     aadContext,
 		Protected:   protectedHeaderBytes,
 		ExternalAad: []byte{},
 	}
@@ -493,6 +596,9 @@ func decryptEMB(encrypted []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 	}
 
 	nonceIvBytes := embInst.Unprotected.AESIV
+	if nonceIvBytes == nil || len(*nonceIvBytes) != algInfo.NonceIvLen {
+		return nil, fmt.Errorf("error! Expected %d byte IV for cipher suite %d", algInfo.NonceIvLen, algInfo.CryptoAlg)
+	}
 
 	block, err := aes.NewCipher(sevk)
 	if err != nil {
@@ -6,6 +6,15 @@ import (
 	"testing"
 )
 
+func test_sequenceBytes(start byte, size int) []byte {
+	result := make([]byte, size)
+	for i := range result {
+		result[i] = start + byte(i)
+	}
+
+	return result
+}
+
 func test_generateSessionKeyInfo() SessionKeyInfo {
 	return SessionKeyInfo{
 		ShSe:        []byte("test ShSe"),
@@ -33,3 +42,303 @@ func TestEncryptEMB(t *testing.T) {
 		t.Errorf("Decrypted payload does not match original payload %s %s", hex.EncodeToString(payload), hex.EncodeToString(decrypted))
 	}
 }
+
+type encryptionKatVector struct {
+	CipherSuite CipherSuiteName
+	IV          string
+	Ciphertext  string
+	Tag         string
+}
+
+// Known-answer vectors for fixed ShSe 00..1f, ContextRand 40..5f and IV a0... GCM, and CTR with HMAC, outputs were cross-checked against Go crypto/cipher and crypto/hmac.
+// AES-CCM outputs were computed independently per RFC 3610 on top of AES-ECB
+var encryptionKatVectors = []encryptionKatVector{
+	{
+		CipherSuite: CIPHER_A128GCM,
+		IV:          "a0a1a2a3a4a5a6a7a8a9aaab",
+		Ciphertext:  "53dace6fe488ab4ea614a5636cc9421ab97487f30dafa0bec889",
+		Tag:         "a8974d0aacb0a80e67756e42c9743f6f",
+	},
+	{
+		CipherSuite: CIPHER_A256GCM,
+		IV:          "a0a1a2a3a4a5a6a7a8a9aaab",
+		Ciphertext:  "e65312faac6973df4202b6aacc35d744de99acca14b64624c9fb",
+		Tag:         "43d55dc29abba71c62fdc835c2087261",
+	},
+	{
+		CipherSuite: CIPHER_AES_CCM_16_128_128,
+		IV:          "a0a1a2a3a4a5a6a7a8a9aaabac",
+		Ciphertext:  "1b95cc5747c828d8574c664e16ac798af81552ce15a88256d8e8",
+		Tag:         "749008e48404f8b44c807abf3fb8797b",
+	},
+	{
+		CipherSuite: CIPHER_AES_CCM_16_128_256,
+		IV:          "a0a1a2a3a4a5a6a7a8a9aaabac",
+		Ciphertext:  "30fbaccff7df2505e6110767dd5629dd66f20a44c4e66c25d40e",
+		Tag:         "dd6a0038188ba022d03ad3c4a9678bb6",
+	},
+	{
+		CipherSuite: CIPHER_AES_CCM_64_128_128,
+		IV:          "a0a1a2a3a4a5a6",
+		Ciphertext:  "b2f32033c4f4020082536ff9f09c746cfe5b0895c892a041038c",
+		Tag:         "2466102a7fa64396388f18214b67985b",
+	},
+	{
+		CipherSuite: CIPHER_AES_CCM_64_128_256,
+		IV:          "a0a1a2a3a4a5a6",
+		Ciphertext:  "fe6c5732dc439463ac358177e85a6d17fa338124ad5255e2f152",
+		Tag:         "326ca15bb98a1bcfb1000a4e556c13d6",
+	},
+	{
+		CipherSuite: CIPHER_COSE_AES128_CTR,
+		IV:          "a0a1a2a3a4a5a6a7a8a9aaabacadaeaf",
+		Ciphertext:  "a55b2d0323962347ab0ee43150e2284228dab1d090a602d8b72b",
+		Tag:         "8bf6ce20ca4eb4c5adb1f330f53947a92f4e26b3cb9405110315505236be480c",
+	},
+}
+
+// splitEncryptedMessage returns IV, ciphertext and tag of the encrypted message
+func splitEncryptedMessage(t *testing.T, encrypted []byte, cipherSuite CipherSuiteName) ([]byte, []byte, []byte) {
+	algInfo := CipherSuitesInfoMap[cipherSuite]
+
+	if algInfo.SevkLength == 0 {
+		var outer ETMOuterBlock
+		err := CborCust.Unmarshal(encrypted, &outer)
+		if err != nil {
+			t.Fatalf("%d: Failed to decode ETM outer block. %s", cipherSuite, err.Error())
+		}
+
+		var inner EMB_ETMInnerBlock
+		err = CborCust.Unmarshal(outer.Payload, &inner)
+		if err != nil {
+			t.Fatalf("%d: Failed to decode ETM inner block. %s", cipherSuite, err.Error())
+		}
+
+		return *inner.Unprotected.AESIV, inner.Ciphertext, outer.Tag
+	}
+
+	var emb EMB_ETMInnerBlock
+	err := CborCust.Unmarshal(encrypted, &emb)
+	if err != nil {
+		t.Fatalf("%d: Failed to decode EMB. %s", cipherSuite, err.Error())
+	}
+
+	// AEAD tag is appended to the ciphertext
+	tagStart := len(emb.Ciphertext) - 16
+	return *emb.Unprotected.AESIV, emb.Ciphertext[:tagStart], emb.Ciphertext[tagStart:]
+}
+
+func TestAddEncryptionWrapping_KnownAnswer(t *testing.T) {
+	prevNonceIv := newNonceIv
+	defer func() { newNonceIv = prevNonceIv }()
+
+	sessionKeyInfo := SessionKeyInfo{
+		ShSe:        test_sequenceBytes(0x00, 32),
+		ContextRand: test_sequenceBytes(0x40, 32),
+	}
+	plaintext := []byte("FDO known-answer plaintext")
+
+	for _, vector := range encryptionKatVectors {
+		expectedIv, _ := hex.DecodeString(vector.IV)
+		newNonceIv = func(size int) []byte {
+			return append([]byte{}, expectedIv...)
+		}
+
+		encrypted, err := AddEncryptionWrapping(plaintext, sessionKeyInfo, vector.CipherSuite)
+		if err != nil {
+			t.Errorf("%d: Failed to encrypt. %s", vector.CipherSuite, err.Error())
+			continue
+		}
+
+		iv, ciphertext, tag := splitEncryptedMessage(t, encrypted, vector.CipherSuite)
+
+		for _, field := range []struct {
+			name     string
+			expected string
+			actual   []byte
+		}{
+			{"IV", vector.IV, iv},
+			{"ciphertext", vector.Ciphertext, ciphertext},
+			{"tag", vector.Tag, tag},
+		} {
+			if hex.EncodeToString(field.actual) != field.expected {
+				t.Errorf("%d: %s mismatch. Expected %s. Got %s", vector.CipherSuite, field.name, field.expected, hex.EncodeToString(field.actual))
+			}
+		}
+
+		decrypted, err := RemoveEncryptionWrapping(encrypted, sessionKeyInfo, vector.CipherSuite)
+		if err != nil {
+			t.Errorf("%d: Failed to decrypt. %s", vector.CipherSuite, err.Error())
+			continue
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%d: plaintext mismatch. Expected %s. Got %s", vector.CipherSuite, hex.EncodeToString(plaintext), hex.EncodeToString(decrypted))
+		}
+	}
+}
+
+type etmCipherVector struct {
+	CryptoAlg  CipherSuiteName
+	Key        string
+	IV         string
+	Ciphertext string
+}
+
+// NIST SP 800-38A appendix F vectors. F.2.1, F.2.5 CBC and F.5.1, F.5.5 CTR, all for the same four block plaintext
+const nistSp80038aPlaintext = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+
+var etmCipherVectors = []etmCipherVector{
+	{
+		CryptoAlg:  CIPHER_COSE_AES128_CBC,
+		Key:        "2b7e151628aed2a6abf7158809cf4f3c",
+		IV:         "000102030405060708090a0b0c0d0e0f",
+		Ciphertext: "7649abac8119b246cee98e9b12e9197d5086cb9b507219ee95db113a917678b273bed6b8e3c1743b7116e69e222295163ff1caa1681fac09120eca307586e1a7",
+	},
+	{
+		CryptoAlg:  CIPHER_COSE_AES256_CBC,
+		Key:        "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+		IV:         "000102030405060708090a0b0c0d0e0f",
+		Ciphertext: "f58c4c04d6e5f1ba779eabfb5f7bfbd69cfc4e967edb808d679f777bc6702c7d39f23369a9d9bacfa530e26304231461b2eb05e2c39be9fcda6c19078c6a9d1b",
+	},
+	{
+		CryptoAlg:  CIPHER_COSE_AES128_CTR,
+		Key:        "2b7e151628aed2a6abf7158809cf4f3c",
+		IV:         "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		Ciphertext: "874d6191b620e3261bef6864990db6ce9806f66b7970fdff8617187bb9fffdff5ae4df3edbd5d35e5b4f09020db03eab1e031dda2fbe03d1792170a0f3009cee",
+	},
+	{
+		CryptoAlg:  CIPHER_COSE_AES256_CTR,
+		Key:        "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+		IV:         "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		Ciphertext: "601ec313775789a5b7a7f504bbf3d228f443e3ca4d62b59aca84e990cacaf5c52b0930daa23de94ce87017ba2d84988ddfc9c58db67aada613c2dd08457941a6",
+	},
+}
+
+func TestEtmCipher_KnownAnswer(t *testing.T) {
+	plaintext, _ := hex.DecodeString(nistSp80038aPlaintext)
+
+	for _, vector := range etmCipherVectors {
+		key, _ := hex.DecodeString(vector.Key)
+		iv, _ := hex.DecodeString(vector.IV)
+
+		ciphertext, err := etmEncrypt(vector.CryptoAlg, key, iv, plaintext)
+		if err != nil {
+			t.Errorf("%d: Failed to encrypt. %s", vector.CryptoAlg, err.Error())
+			continue
+		}
+
+		// CBC appends full padding block to block aligned plaintext, which is not part of NIST vectors
+		if len(ciphertext) < len(plaintext) || hex.EncodeToString(ciphertext[:len(plaintext)]) != vector.Ciphertext {
+			t.Errorf("%d: ciphertext mismatch. Expected %s. Got %s", vector.CryptoAlg, vector.Ciphertext, hex.EncodeToString(ciphertext))
+			continue
+		}
+
+		decrypted, err := etmDecrypt(vector.CryptoAlg, key, iv, ciphertext)
+		if err != nil {
+			t.Errorf("%d: Failed to decrypt. %s", vector.CryptoAlg, err.Error())
+			continue
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%d: plaintext mismatch. Expected %s. Got %s", vector.CryptoAlg, nistSp80038aPlaintext, hex.EncodeToString(decrypted))
+		}
+	}
+}
+
+// RFC 4231 test case 2 for the ETM suites MAC algorithms
+func TestGenerateFdoHmac_KnownAnswer(t *testing.T) {
+	for hmacAlg, expected := range map[HashType]string{
+		HASH_HMAC_SHA256: "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		HASH_HMAC_SHA384: "af45d2e376484031617f78d2b58a6b1b9c7ef464f5a01b47e42ec3736322445e8e2240ca5e69e2c78b3239ecfab21649",
+	} {
+		fdoHmac, err := GenerateFdoHmac([]byte("what do ya want for nothing?"), hmacAlg, []byte("Jefe"))
+		if err != nil {
+			t.Errorf("%d: Failed to generate HMAC. %s", hmacAlg, err.Error())
+			continue
+		}
+
+		if hex.EncodeToString(fdoHmac.Hash) != expected {
+			t.Errorf("%d: tag mismatch. Expected %s. Got %s", hmacAlg, expected, hex.EncodeToString(fdoHmac.Hash))
+		}
+	}
+}
+
+func TestEncryptionWrapping_CCMOwnerServiceInfo(t *testing.T) {
+	ownerServiceInfo := OwnerServiceInfo69{
+		IsMoreServiceInfo: true,
+		ServiceInfo: []ServiceInfoKV{
+			{ServiceInfoKey: "fdo_sys:active", ServiceInfoVal: CBOR_TRUE},
+			{ServiceInfoKey: "fdo_sys:write", ServiceInfoVal: test_sequenceBytes(0x00, 200)},
+		},
+	}
+	ownerServiceInfoBytes, _ := CborCust.Marshal(ownerServiceInfo)
+	sessionKeyInfo := test_generateSessionKeyInfo()
+
+	for _, cipherSuite := range []CipherSuiteName{CIPHER_AES_CCM_16_128_128, CIPHER_AES_CCM_16_128_256, CIPHER_AES_CCM_64_128_128, CIPHER_AES_CCM_64_128_256} {
+		encrypted, err := AddEncryptionWrapping(ownerServiceInfoBytes, sessionKeyInfo, cipherSuite)
+		if err != nil {
+			t.Errorf("%d: Failed to encrypt. %s", cipherSuite, err.Error())
+			continue
+		}
+
+		iv, _, _ := splitEncryptedMessage(t, encrypted, cipherSuite)
+		if len(iv) != CipherSuitesInfoMap[cipherSuite].NonceIvLen {
+			t.Errorf("%d: Expected %d byte IV. Got %d", cipherSuite, CipherSuitesInfoMap[cipherSuite].NonceIvLen, len(iv))
+		}
+
+		decrypted, err := RemoveEncryptionWrapping(encrypted, sessionKeyInfo, cipherSuite)
+		if err != nil {
+			t.Errorf("%d: Failed to decrypt. %s", cipherSuite, err.Error())
+			continue
+		}
+
+		var decoded OwnerServiceInfo69
+		err = CborCust.Unmarshal(decrypted, &decoded)
+		if err != nil || !decoded.IsMoreServiceInfo || len(decoded.ServiceInfo) != 2 || !bytes.Equal(decoded.ServiceInfo[1].ServiceInfoVal, ownerServiceInfo.ServiceInfo[1].ServiceInfoVal) {
+			t.Errorf("%d: Expected OwnerServiceInfo69 to round-trip. Got %v, %v", cipherSuite, decoded, err)
+		}
+
+		var emb EMB_ETMInnerBlock
+		CborCust.Unmarshal(encrypted, &emb)
+		emb.Unprotected.AESIV = nil
+		noIvBytes, _ := CborCust.Marshal(emb)
+		_, err = RemoveEncryptionWrapping(noIvBytes, sessionKeyInfo, cipherSuite)
+		if err == nil {
+			t.Errorf("%d: Expected message without IV to be rejected", cipherSuite)
+		}
+	}
+}
+
+func TestConf_AddWrappingWrongContext(t *testing.T) {
+	payload := []byte("test payload")
+	sessionKeyInfo := test_generateSessionKeyInfo()
+
+	for cipherSuite := range CipherSuitesInfoMap {
+		correctBytes, err := AddEncryptionWrapping(payload, sessionKeyInfo, cipherSuite)
+		if err != nil {
+			// Suite is not implemented. Wrong context message can not be generated either
+			_, err = Conf_AddWrappingWrongContext(payload, sessionKeyInfo, cipherSuite)
+			if err == nil {
+				t.Errorf("%d: Expected wrong context encryption to fail for unsupported suite", cipherSuite)
+			}
+			continue
+		}
+
+		decrypted, err := RemoveEncryptionWrapping(correctBytes, sessionKeyInfo, cipherSuite)
+		if err != nil || !bytes.Equal(decrypted, payload) {
+			t.Errorf("%d: Expected correct context message to decrypt. %v", cipherSuite, err)
+		}
+
+		wrongBytes, err := Conf_AddWrappingWrongContext(payload, sessionKeyInfo, cipherSuite)
+		if err != nil {
+			t.Errorf("%d: Failed to encrypt with wrong context. %s", cipherSuite, err.Error())
+			continue
+		}
+
+		_, err = RemoveEncryptionWrapping(wrongBytes, sessionKeyInfo, cipherSuite)
+		if err == nil {
+			t.Errorf("%d: Expected wrong context message to be rejected", cipherSuite)
+		}
+	}
+}
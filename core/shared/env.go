@@ -9,6 +9,95 @@ const (
 	CFG_DEV_ENV  CONFIG_ENTRY = "DEV"
 	CFG_ENV_PORT CONFIG_ENTRY = "PORT"
 
+	// Runs Badger in memory. Nothing is written to disk and all data is lost on exit
+	CFG_ENV_DB_IN_MEMORY CONFIG_ENTRY = "DB_IN_MEMORY"
+
+	// Second RV endpoint. Device test runs send it as replacement RVInfo in TO2.SetupDevice
+	CFG_ENV_SECONDARY_RV_URL  CONFIG_ENTRY = "SECONDARY_RV_URL"
+	CFG_ENV_SECONDARY_RV_PORT CONFIG_ENTRY = "SECONDARY_RV_PORT"
+
+	// HTTPS listener port. Presents imported RV identity, or owner identity, see /api/admin/identity
+	CFG_ENV_TLS_PORT CONFIG_ENTRY = "TLS_PORT"
+	// Match device TLS client certificate on the HTTPS listener against voucher OVDevCertChain. Mismatch is recorded as observation. true or false
+	CFG_ENV_VERIFY_TLS_DEVICE_CERT CONFIG_ENTRY = "VERIFY_TLS_DEVICE_CERT"
+	// Path to PEM CA bundle. When set, FDO messages are only accepted with TLS client certificate issued by one of the CAs
+	CFG_ENV_TLS_CLIENT_CA CONFIG_ENTRY = "TLS_CLIENT_CA"
+
+	// RV countersigns To1d in TO1.RVRedirect with imported RV identity key, see /api/admin/identity. true or false
+	CFG_ENV_RV_COUNTERSIGN_TO1D CONFIG_ENTRY = "RV_COUNTERSIGN_TO1D"
+
+	// Resource limits
+	CFG_ENV_MAX_OVENTRIES    CONFIG_ENTRY = "MAX_OVENTRIES"
+	CFG_ENV_MAX_OVENTRY_SIZE CONFIG_ENTRY = "MAX_OVENTRY_SIZE"
+	// Device ServiceInfo accumulated by the owner per TO2 session
+	CFG_ENV_MAX_DEVICE_SIMS      CONFIG_ENTRY = "MAX_DEVICE_SIMS"
+	CFG_ENV_MAX_DEVICE_SIMS_SIZE CONFIG_ENTRY = "MAX_DEVICE_SIMS_SIZE"
+	// Request body size of FDO messages from devices, TO1 and TO2, and from owners, TO0
+	CFG_ENV_MAX_DEVICE_MESSAGE_SIZE CONFIG_ENTRY = "MAX_DEVICE_MESSAGE_SIZE"
+	CFG_ENV_MAX_OWNER_MESSAGE_SIZE  CONFIG_ENTRY = "MAX_OWNER_MESSAGE_SIZE"
+	// Voucher uploads of device tests
+	CFG_ENV_MAX_VOUCHER_FILE_SIZE    CONFIG_ENTRY = "MAX_VOUCHER_FILE_SIZE"
+	CFG_ENV_MAX_VOUCHER_FILES        CONFIG_ENTRY = "MAX_VOUCHER_FILES"
+	CFG_ENV_MAX_USER_VOUCHER_STORAGE CONFIG_ENTRY = "MAX_USER_VOUCHER_STORAGE"
+
+	// Number of TO2.GetOVNextEntry requests in flight while DO tests fetch voucher entries. 1 to 32, default 1
+	CFG_ENV_OVENTRY_BATCH_SIZE CONFIG_ENTRY = "OVENTRY_BATCH_SIZE"
+
+	// Retries of TO2.HelloDevice and TO2.GetOVNextEntry on transient network errors in DO tests. 0 to 10, default 2
+	CFG_ENV_NETWORK_RETRIES CONFIG_ENTRY = "NETWORK_RETRIES"
+
+	// Retries of DB read-modify-write transactions on conflict
+	CFG_ENV_DB_CONFLICT_RETRIES CONFIG_ENTRY = "DB_CONFLICT_RETRIES"
+
+	// Max number of stored test runs per user. Oldest unpinned runs are evicted. 0 or empty keeps all runs
+	CFG_ENV_MAX_RUNS_PER_USER CONFIG_ENTRY = "MAX_RUNS_PER_USER"
+
+	// Record unknown FDO message numbers sent during device test runs as failed observations. true or false
+	CFG_ENV_RECORD_UNKNOWN_MESSAGES CONFIG_ENTRY = "RECORD_UNKNOWN_MESSAGES"
+
+	// Accept Ed25519 device and owner keys, pkType 12 and sgType -8, which FDO 1.1 does not assign. true or false, disabled by default
+	CFG_ENV_EXPERIMENTAL_ED25519 CONFIG_ENTRY = "EXPERIMENTAL_ED25519"
+
+	// Outbound policy for tester supplied RV/DO URLs. Comma separated host, host:port, *.domain or CIDR
+	CFG_ENV_OUTBOUND_ALLOWLIST CONFIG_ENTRY = "OUTBOUND_ALLOWLIST"
+	CFG_ENV_OUTBOUND_DENYLIST  CONFIG_ENTRY = "OUTBOUND_DENYLIST"
+
+	// HTTP redirects handling for tester supplied RV/DO URLs. follow, reject or follow_forward_authz
+	CFG_ENV_HTTP_REDIRECT_POLICY CONFIG_ENTRY = "HTTP_REDIRECT_POLICY"
+
+	// DO handling of HelloDevice while previous TO2 of the same GUID is in progress. supersede or reject
+	CFG_ENV_TO2_CONCURRENT_ONBOARDING CONFIG_ENTRY = "TO2_CONCURRENT_ONBOARDING"
+	// DO handling of HelloDevice of a device that already completed TO2 with its voucher. allow or deny
+	CFG_ENV_TO2_REONBOARD_POLICY CONFIG_ENTRY = "TO2_REONBOARD_POLICY"
+
+	// DO TO2 session TTL in seconds, default 600. With sliding TTL, true by default, it is re-applied on every message
+	CFG_ENV_TO2_SESSION_TTL         CONFIG_ENTRY = "TO2_SESSION_TTL"
+	CFG_ENV_TO2_SESSION_SLIDING_TTL CONFIG_ENTRY = "TO2_SESSION_SLIDING_TTL"
+	// Seconds a campaign may stay paused before it is aborted, default 1800
+	CFG_ENV_CAMPAIGN_PAUSE_TIMEOUT CONFIG_ENTRY = "CAMPAIGN_PAUSE_TIMEOUT"
+	// Seconds to wait for in-flight onboarding sessions on SIGTERM or SIGINT, default 30
+	CFG_ENV_SHUTDOWN_GRACE_PERIOD CONFIG_ENTRY = "SHUTDOWN_GRACE_PERIOD"
+	// Web login session TTL in seconds, default 7 days
+	CFG_ENV_LOGIN_SESSION_TTL CONFIG_ENTRY = "LOGIN_SESSION_TTL"
+	// Login requests per minute per client IP, default 10. 0 disables the limit
+	CFG_ENV_LOGIN_RATE_LIMIT CONFIG_ENTRY = "LOGIN_RATE_LIMIT"
+	CFG_ENV_LOGIN_RATE_BURST CONFIG_ENTRY = "LOGIN_RATE_BURST"
+
+	// Bearer token for /api/admin endpoints. Admin API is disabled when empty
+	CFG_ENV_ADMIN_TOKEN CONFIG_ENTRY = "ADMIN_TOKEN"
+
+	// Masks emails and other PII in logs. Disabled by default
+	CFG_ENV_LOG_REDACT_PII CONFIG_ENTRY = "LOG_REDACT_PII"
+
+	// Minimum level of protocol message logs. debug, info, warn or error. Defaults to info
+	CFG_ENV_LOG_LEVEL CONFIG_ENTRY = "LOG_LEVEL"
+
+	// Allows exporting SEK/SVK of completed DO sessions. Disabled by default
+	CFG_ENV_EXPORT_SESSION_KEYS CONFIG_ENTRY = "EXPORT_SESSION_KEYS"
+
+	// Persists raw device requests per GUID, for download with /api/capture. Disabled by default
+	CFG_ENV_CAPTURE_MESSAGES CONFIG_ENTRY = "CAPTURE_MESSAGES"
+
 	// For conformance testing
 	CFG_ENV_INTEROP_ENABLED            CONFIG_ENTRY = "INTEROP_ENABLED"
 	CFG_ENV_INTEROP_DASHBOARD_URL      CONFIG_ENTRY = "INTEROP_DASHBOARD_URL"
@@ -0,0 +1,209 @@
+package fdoshared
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type ServerIdentityRole string
+
+const (
+	// Signs TO2.SetupDevice as Owner2Key, and so replacement voucher and its To1d
+	IDENTITY_ROLE_OWNER ServerIdentityRole = "owner"
+	// TLS identity of the server. Also countersigns To1d when RV_COUNTERSIGN_TO1D is set
+	IDENTITY_ROLE_RV ServerIdentityRole = "rv"
+)
+
+var ServerIdentityRoles = []ServerIdentityRole{IDENTITY_ROLE_OWNER, IDENTITY_ROLE_RV}
+
+// ServerIdentity is lab provided certificate chain and key, used instead of self-generated keys
+type ServerIdentity struct {
+	_                struct{} `cbor:",toarray"`
+	CertificateChain []X509CertificateBytes
+	PrivateKeyDer    []byte
+	SgType           DeviceSgType
+}
+
+// NewServerIdentity decodes PEM certificate chain, leaf first, and PEM private key. The key must belong to the leaf certificate,
+// and the chain must verify up to its last certificate, as devices verify X5CHAIN keys that way
+func NewServerIdentity(certChainPem []byte, privateKeyPem []byte) (*ServerIdentity, error) {
+	certChain, err := decodeCertChainPem(certChainPem)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBlock, _ := pem.Decode(privateKeyPem)
+	if keyBlock == nil {
+		return nil, errors.New("could not find private key PEM data")
+	}
+
+	privateKey, err := ExtractPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = VerifyCertificateChain(certChain)
+	if err != nil {
+		return nil, err
+	}
+
+	leafCert, _ := x509.ParseCertificate(certChain[0])
+	if time.Now().After(leafCert.NotAfter) {
+		return nil, fmt.Errorf("leaf certificate expired on %s", leafCert.NotAfter.Format(time.RFC3339))
+	}
+
+	leafPubKeyBytes, err := x509.MarshalPKIXPublicKey(leafCert.PublicKey)
+	if err != nil {
+		return nil, errors.New("error encoding leaf certificate public key. " + err.Error())
+	}
+
+	privPubKeyBytes, err := x509.MarshalPKIXPublicKey(privateKey.(crypto.Signer).Public())
+	if err != nil {
+		return nil, errors.New("error encoding private key public key. " + err.Error())
+	}
+
+	if !bytes.Equal(leafPubKeyBytes, privPubKeyBytes) {
+		return nil, errors.New("private key does not match leaf certificate")
+	}
+
+	sgType, err := sgTypeOfPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyDer, err := MarshalPrivateKey(privateKey, sgType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServerIdentity{
+		CertificateChain: certChain,
+		PrivateKeyDer:    privateKeyDer,
+		SgType:           sgType,
+	}, nil
+}
+
+// NewServerIdentityPublicKey decodes PEM certificate chain of the server identity, leaf first, into X5CHAIN public key, the same as ServerIdentity.GetPublicKey.
+// Device uses it to verify signatures of the server, e.g. RV countersignature of To1d
+func NewServerIdentityPublicKey(certChainPem []byte) (*FdoPublicKey, error) {
+	certChain, err := decodeCertChainPem(certChainPem)
+	if err != nil {
+		return nil, err
+	}
+
+	verifiedChain, err := VerifyCertificateChain(certChain)
+	if err != nil {
+		return nil, err
+	}
+
+	sgType, err := sgTypeOfPublicKey(verifiedChain[0].PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FdoPublicKey{
+		PkType: SgTypeToFdoPkType[sgType],
+		PkEnc:  X5CHAIN,
+		PkBody: certChain,
+	}, nil
+}
+
+func decodeCertChainPem(certChainPem []byte) ([]X509CertificateBytes, error) {
+	var certChain []X509CertificateBytes
+	for rest := certChainPem; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM type %s in certificate chain", block.Type)
+		}
+
+		certChain = append(certChain, block.Bytes)
+	}
+
+	if len(certChain) < 2 {
+		return nil, errors.New("certificate chain must have leaf and at least its CA certificate")
+	}
+
+	return certChain, nil
+}
+
+func sgTypeOfPrivateKey(privateKey interface{}) (DeviceSgType, error) {
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return 0, errors.New("unsupported private key type")
+	}
+
+	return sgTypeOfPublicKey(signer.Public())
+}
+
+func sgTypeOfPublicKey(publicKey crypto.PublicKey) (DeviceSgType, error) {
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return StSECP256R1, nil
+		case elliptic.P384():
+			return StSECP384R1, nil
+		}
+
+		return 0, fmt.Errorf("unsupported EC curve %s", key.Curve.Params().Name)
+	case *rsa.PublicKey:
+		switch key.N.BitLen() {
+		case 2048:
+			return StRSA2048, nil
+		case 3072:
+			return StRSA3072, nil
+		}
+
+		return 0, fmt.Errorf("unsupported RSA key size %d", key.N.BitLen())
+	default:
+		return 0, errors.New("unsupported key type")
+	}
+}
+
+func (h ServerIdentity) GetPrivateKey() (interface{}, error) {
+	return ExtractPrivateKey(h.PrivateKeyDer)
+}
+
+// GetPublicKey returns X5CHAIN encoded public key, so devices can check it against the CAs they trust
+func (h ServerIdentity) GetPublicKey() FdoPublicKey {
+	return FdoPublicKey{
+		PkType: SgTypeToFdoPkType[h.SgType],
+		PkEnc:  X5CHAIN,
+		PkBody: h.CertificateChain,
+	}
+}
+
+func (h ServerIdentity) GetLeafCertificate() (*x509.Certificate, error) {
+	return x509.ParseCertificate(h.CertificateChain[0])
+}
+
+func (h ServerIdentity) GetTLSCertificate() (*tls.Certificate, error) {
+	privateKey, err := h.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCert := tls.Certificate{
+		PrivateKey: privateKey,
+	}
+
+	for _, cert := range h.CertificateChain {
+		tlsCert.Certificate = append(tlsCert.Certificate, cert)
+	}
+
+	return &tlsCert, nil
+}
@@ -0,0 +1,151 @@
+package fdoshared
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestIdentityPem(t *testing.T) ([]byte, []byte) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Lab CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	caCertBytes, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to generate CA certificate. %s", err.Error())
+	}
+
+	caCert, _ := x509.ParseCertificate(caCertBytes)
+
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Lab Owner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	leafCertBytes, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to generate leaf certificate. %s", err.Error())
+	}
+
+	leafKeyBytes, _ := x509.MarshalPKCS8PrivateKey(leafKey)
+
+	certChainPem := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafCertBytes}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCertBytes})...)
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: PRIVATE_KEY_PEM_TYPE, Bytes: leafKeyBytes})
+
+	return certChainPem, keyPem
+}
+
+func TestNewServerIdentity(t *testing.T) {
+	certChainPem, keyPem := newTestIdentityPem(t)
+
+	identity, err := NewServerIdentity(certChainPem, keyPem)
+	if err != nil {
+		t.Fatalf("Expected valid identity to import. %s", err.Error())
+	}
+
+	if identity.SgType != StSECP256R1 {
+		t.Errorf("Expected sgType %d, got %d", StSECP256R1, identity.SgType)
+	}
+
+	// Devices receive X5CHAIN key CBOR encoded, e.g. as Owner2Key in TO2.SetupDevice
+	privateKey, err := identity.GetPrivateKey()
+	if err != nil {
+		t.Fatalf("Failed to decode identity private key. %s", err.Error())
+	}
+
+	signature, err := GenerateCoseSignature([]byte("SetupDevice"), ProtectedHeader{}, UnprotectedHeader{}, privateKey, identity.SgType)
+	if err != nil {
+		t.Fatalf("Failed to sign with identity key. %s", err.Error())
+	}
+
+	pubKeyBytes, _ := CborCust.Marshal(identity.GetPublicKey())
+
+	var decodedPubKey FdoPublicKey
+	err = CborCust.Unmarshal(pubKeyBytes, &decodedPubKey)
+	if err != nil {
+		t.Fatalf("Failed to decode identity public key. %s", err.Error())
+	}
+
+	err = VerifyCoseSignature(*signature, decodedPubKey)
+	if err != nil {
+		t.Errorf("Expected signature to verify with decoded X5CHAIN key. %s", err.Error())
+	}
+
+	_, err = identity.GetTLSCertificate()
+	if err != nil {
+		t.Errorf("Expected identity to be usable as TLS certificate. %s", err.Error())
+	}
+
+	// Key of another identity
+	_, otherKeyPem := newTestIdentityPem(t)
+	_, err = NewServerIdentity(certChainPem, otherKeyPem)
+	if err == nil {
+		t.Errorf("Expected key not matching leaf certificate to fail")
+	}
+
+	// Leaf only. X5CHAIN needs the CA
+	leafPem, _ := pem.Decode(certChainPem)
+	_, err = NewServerIdentity(pem.EncodeToMemory(leafPem), keyPem)
+	if err == nil {
+		t.Errorf("Expected chain without CA to fail")
+	}
+}
+
+func TestNewServerIdentityPublicKey(t *testing.T) {
+	certChainPem, keyPem := newTestIdentityPem(t)
+
+	identity, err := NewServerIdentity(certChainPem, keyPem)
+	if err != nil {
+		t.Fatalf("Expected valid identity to import. %s", err.Error())
+	}
+
+	rvPubKey, err := NewServerIdentityPublicKey(certChainPem)
+	if err != nil {
+		t.Fatalf("Expected identity certificate chain to decode. %s", err.Error())
+	}
+
+	if rvPubKey.PkType != identity.GetPublicKey().PkType || rvPubKey.PkEnc != X5CHAIN {
+		t.Errorf("Expected X5CHAIN key of pkType %d. Got %d %d", identity.GetPublicKey().PkType, rvPubKey.PkEnc, rvPubKey.PkType)
+	}
+
+	// RV countersigns To1d with the identity, device verifies it with the certificate chain only
+	privateKey, _ := identity.GetPrivateKey()
+	to1d, err := GenerateCoseSignature([]byte("to1d"), ProtectedHeader{}, UnprotectedHeader{}, privateKey, identity.SgType)
+	if err != nil {
+		t.Fatalf("Failed to sign To1d. %s", err.Error())
+	}
+
+	to1d.Unprotected.CounterSignature, err = GenerateCoseCounterSignature(*to1d, privateKey, identity.SgType)
+	if err != nil {
+		t.Fatalf("Failed to countersign To1d. %s", err.Error())
+	}
+
+	err = VerifyCoseCounterSignature(*to1d, *rvPubKey)
+	if err != nil {
+		t.Errorf("Expected countersignature to verify with identity certificate chain. %s", err.Error())
+	}
+
+	leafPem, _ := pem.Decode(certChainPem)
+	_, err = NewServerIdentityPublicKey(pem.EncodeToMemory(leafPem))
+	if err == nil {
+		t.Errorf("Expected chain without CA to fail")
+	}
+}
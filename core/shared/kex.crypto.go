@@ -51,6 +51,61 @@ var KexSuitNames [6]KexSuiteName = [6]KexSuiteName{
 	KEX_ASYMKEX3072,
 }
 
+type KexCipherSuite struct {
+	KexSuiteName    KexSuiteName
+	CipherSuiteName CipherSuiteName
+}
+
+// Compatible KEX and cipher suites for the device signature type, as advertised in HelloDevice60 eASigInfo
+var SgTypeToKexCipherSuite = map[DeviceSgType]KexCipherSuite{
+	StSECP256R1: {KEX_ECDH256, CIPHER_A128GCM},
+	StSECP384R1: {KEX_ECDH384, CIPHER_A256GCM},
+	StRSA2048:   {KEX_DHKEXid14, CIPHER_A128GCM},
+	StRSA3072:   {KEX_DHKEXid15, CIPHER_A256GCM},
+}
+
+// Validate checks that KEX and cipher suite are known
+func (h KexCipherSuite) Validate() error {
+	kexKnown := false
+	for _, kexSuiteName := range KexSuitNames {
+		if kexSuiteName == h.KexSuiteName {
+			kexKnown = true
+		}
+	}
+
+	if !kexKnown {
+		return fmt.Errorf("unknown KEX suite %s", h.KexSuiteName)
+	}
+
+	_, ok := CipherSuitesInfoMap[h.CipherSuiteName]
+	if !ok {
+		return fmt.Errorf("unknown cipher suite %d", h.CipherSuiteName)
+	}
+
+	return nil
+}
+
+// KexCipherSuiteMatrix lists ECDH KEX suites combined with every cipher suite. ECDH does not depend on owner key type, so owner must support all of them
+func KexCipherSuiteMatrix() []KexCipherSuite {
+	matrix := []KexCipherSuite{}
+	for _, kexSuiteName := range []KexSuiteName{KEX_ECDH256, KEX_ECDH384} {
+		for _, cipherSuiteName := range CipherSuitNames {
+			matrix = append(matrix, KexCipherSuite{kexSuiteName, cipherSuiteName})
+		}
+	}
+
+	return matrix
+}
+
+func SelectKexCipherSuite(eASigInfo SigInfo) (KexSuiteName, CipherSuiteName, error) {
+	suite, ok := SgTypeToKexCipherSuite[eASigInfo.SgType]
+	if !ok {
+		return "", 0, fmt.Errorf("no compatible KEX for sgType %d", eASigInfo.SgType)
+	}
+
+	return suite.KexSuiteName, suite.CipherSuiteName, nil
+}
+
 type KeXParams struct {
 	_             struct{} `cbor:",toarray"`
 	Private       []byte
@@ -0,0 +1,22 @@
+package fdoshared
+
+import "testing"
+
+func TestKexCipherSuite_Validate(t *testing.T) {
+	for _, suite := range KexCipherSuiteMatrix() {
+		err := suite.Validate()
+		if err != nil {
+			t.Errorf("Expected %s %d to be valid. %s", suite.KexSuiteName, suite.CipherSuiteName, err.Error())
+		}
+	}
+
+	if len(KexCipherSuiteMatrix()) != 2*len(CipherSuitNames) {
+		t.Errorf("Expected ECDH256 and ECDH384 with each cipher suite. Got %d combinations", len(KexCipherSuiteMatrix()))
+	}
+
+	for _, suite := range []KexCipherSuite{{"ECDH521", CIPHER_A128GCM}, {KEX_ECDH256, 2}, {"", 0}} {
+		if suite.Validate() == nil {
+			t.Errorf("Expected %s %d to be rejected", suite.KexSuiteName, suite.CipherSuiteName)
+		}
+	}
+}
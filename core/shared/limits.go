@@ -0,0 +1,172 @@
+package fdoshared
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Resource limits applied by both the device requestor and the owner handlers
+type ResourceLimits struct {
+	MaxOVEntries   int
+	MaxOVEntrySize int
+	// Device ServiceInfo accumulated by the owner over IsMoreServiceInfo rounds of a session
+	MaxDeviceSIMs     int
+	MaxDeviceSIMsSize int
+	// Request body of FDO messages sent by devices, TO1 and TO2, and by owners, TO0 carrying the voucher
+	MaxDeviceMessageSize int
+	MaxOwnerMessageSize  int
+}
+
+const (
+	DEFAULT_MAX_OVENTRIES        int = 255
+	DEFAULT_MAX_OVENTRY_SIZE     int = 8192
+	MAX_OVENTRIES_UPPER_BOUND    int = 255 // NumOVEntries is uint8
+	MIN_MESSAGE_SIZE             int = 1024
+	DEFAULT_MAX_DEVICE_SIMS      int = 1024
+	DEFAULT_MAX_DEVICE_SIMS_SIZE int = 262144
+	// Fits DeviceServiceInfo68 of max MTU with encryption overhead
+	DEFAULT_MAX_DEVICE_MESSAGE_SIZE int = 131072
+	// Fits OwnerSign22 voucher of default OVEntry limits
+	DEFAULT_MAX_OWNER_MESSAGE_SIZE int = 4194304
+)
+
+var DefaultResourceLimits ResourceLimits = ResourceLimits{
+	MaxOVEntries:      DEFAULT_MAX_OVENTRIES,
+	MaxOVEntrySize:    DEFAULT_MAX_OVENTRY_SIZE,
+	MaxDeviceSIMs:     DEFAULT_MAX_DEVICE_SIMS,
+	MaxDeviceSIMsSize: DEFAULT_MAX_DEVICE_SIMS_SIZE,
+
+	MaxDeviceMessageSize: DEFAULT_MAX_DEVICE_MESSAGE_SIZE,
+	MaxOwnerMessageSize:  DEFAULT_MAX_OWNER_MESSAGE_SIZE,
+}
+
+// Limits are set once on startup from config
+var Limits ResourceLimits = DefaultResourceLimits
+
+func NewResourceLimits(maxOVEntriesStr string, maxOVEntrySizeStr string, maxDeviceSIMsStr string, maxDeviceSIMsSizeStr string, maxDeviceMessageSizeStr string, maxOwnerMessageSizeStr string) (*ResourceLimits, error) {
+	limits := DefaultResourceLimits
+
+	if maxOVEntriesStr != "" {
+		maxOVEntries, err := strconv.Atoi(maxOVEntriesStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max OVEntries limit. %s", err.Error())
+		}
+
+		if maxOVEntries < 1 || maxOVEntries > MAX_OVENTRIES_UPPER_BOUND {
+			return nil, fmt.Errorf("max OVEntries limit must be between 1 and %d. Got %d", MAX_OVENTRIES_UPPER_BOUND, maxOVEntries)
+		}
+
+		limits.MaxOVEntries = maxOVEntries
+	}
+
+	if maxOVEntrySizeStr != "" {
+		maxOVEntrySize, err := strconv.Atoi(maxOVEntrySizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max OVEntry size limit. %s", err.Error())
+		}
+
+		if maxOVEntrySize < 1 {
+			return nil, fmt.Errorf("max OVEntry size limit must be positive. Got %d", maxOVEntrySize)
+		}
+
+		limits.MaxOVEntrySize = maxOVEntrySize
+	}
+
+	if maxDeviceSIMsStr != "" {
+		maxDeviceSIMs, err := strconv.Atoi(maxDeviceSIMsStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max device ServiceInfo count limit. %s", err.Error())
+		}
+
+		if maxDeviceSIMs < len(MANDATORY_SIMS) {
+			return nil, fmt.Errorf("max device ServiceInfo count limit must fit mandatory devmod keys, at least %d. Got %d", len(MANDATORY_SIMS), maxDeviceSIMs)
+		}
+
+		limits.MaxDeviceSIMs = maxDeviceSIMs
+	}
+
+	if maxDeviceSIMsSizeStr != "" {
+		maxDeviceSIMsSize, err := strconv.Atoi(maxDeviceSIMsSizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max device ServiceInfo size limit. %s", err.Error())
+		}
+
+		if maxDeviceSIMsSize < 1 {
+			return nil, fmt.Errorf("max device ServiceInfo size limit must be positive. Got %d", maxDeviceSIMsSize)
+		}
+
+		limits.MaxDeviceSIMsSize = maxDeviceSIMsSize
+	}
+
+	if maxDeviceMessageSizeStr != "" {
+		maxDeviceMessageSize, err := strconv.Atoi(maxDeviceMessageSizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max device message size limit. %s", err.Error())
+		}
+
+		if maxDeviceMessageSize < MIN_MESSAGE_SIZE {
+			return nil, fmt.Errorf("max device message size limit must be at least %d. Got %d", MIN_MESSAGE_SIZE, maxDeviceMessageSize)
+		}
+
+		limits.MaxDeviceMessageSize = maxDeviceMessageSize
+	}
+
+	if maxOwnerMessageSizeStr != "" {
+		maxOwnerMessageSize, err := strconv.Atoi(maxOwnerMessageSizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max owner message size limit. %s", err.Error())
+		}
+
+		if maxOwnerMessageSize < MIN_MESSAGE_SIZE {
+			return nil, fmt.Errorf("max owner message size limit must be at least %d. Got %d", MIN_MESSAGE_SIZE, maxOwnerMessageSize)
+		}
+
+		limits.MaxOwnerMessageSize = maxOwnerMessageSize
+	}
+
+	return &limits, nil
+}
+
+// MaxMessageBodySize returns request body limit of the FDO message. TO0 messages come from owners, the rest from devices
+func (h ResourceLimits) MaxMessageBodySize(cmd FdoCmd) int64 {
+	switch cmd {
+	case TO0_20_HELLO, TO0_22_OWNER_SIGN:
+		return int64(h.MaxOwnerMessageSize)
+	default:
+		return int64(h.MaxDeviceMessageSize)
+	}
+}
+
+func (h ResourceLimits) CheckOVEntriesCount(numOVEntries int) error {
+	if numOVEntries > h.MaxOVEntries {
+		return fmt.Errorf("number of OVEntries %d exceeds limit of %d", numOVEntries, h.MaxOVEntries)
+	}
+
+	return nil
+}
+
+func (h ResourceLimits) CheckOVEntrySize(entrySize int) error {
+	if entrySize > h.MaxOVEntrySize {
+		return fmt.Errorf("OVEntry size %d exceeds limit of %d bytes", entrySize, h.MaxOVEntrySize)
+	}
+
+	return nil
+}
+
+// CheckDeviceSIMs checks ServiceInfo accumulated from the device. Size is the sum of key and value lengths
+func (h ResourceLimits) CheckDeviceSIMs(sims []ServiceInfoKV) error {
+	if len(sims) > h.MaxDeviceSIMs {
+		return fmt.Errorf("number of device ServiceInfo entries %d exceeds limit of %d", len(sims), h.MaxDeviceSIMs)
+	}
+
+	totalSize := 0
+	for _, sim := range sims {
+		totalSize += len(sim.ServiceInfoKey) + len(sim.ServiceInfoVal)
+	}
+
+	if totalSize > h.MaxDeviceSIMsSize {
+		return fmt.Errorf("device ServiceInfo size %d exceeds limit of %d bytes", totalSize, h.MaxDeviceSIMsSize)
+	}
+
+	return nil
+}
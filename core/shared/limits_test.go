@@ -0,0 +1,89 @@
+package fdoshared
+
+import "testing"
+
+func TestResourceLimits_OVEntriesCount(t *testing.T) {
+	limits := ResourceLimits{MaxOVEntries: 10, MaxOVEntrySize: 100}
+
+	if err := limits.CheckOVEntriesCount(10); err != nil {
+		t.Errorf("Expected count at limit to pass. %s", err.Error())
+	}
+
+	if err := limits.CheckOVEntriesCount(11); err == nil {
+		t.Errorf("Expected count above limit to fail")
+	}
+}
+
+func TestResourceLimits_OVEntrySize(t *testing.T) {
+	limits := ResourceLimits{MaxOVEntries: 10, MaxOVEntrySize: 100}
+
+	if err := limits.CheckOVEntrySize(100); err != nil {
+		t.Errorf("Expected size at limit to pass. %s", err.Error())
+	}
+
+	if err := limits.CheckOVEntrySize(101); err == nil {
+		t.Errorf("Expected size above limit to fail")
+	}
+}
+
+func TestNewResourceLimits(t *testing.T) {
+	limits, err := NewResourceLimits("", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err.Error())
+	}
+
+	if *limits != DefaultResourceLimits {
+		t.Errorf("Expected default limits. Got %v", *limits)
+	}
+
+	limits, err = NewResourceLimits("255", "1", "9", "1", "1024", "2048")
+	if err != nil {
+		t.Fatalf("Unexpected error at upper boundary. %s", err.Error())
+	}
+
+	if limits.MaxOVEntries != 255 || limits.MaxOVEntrySize != 1 || limits.MaxDeviceSIMs != 9 || limits.MaxDeviceSIMsSize != 1 || limits.MaxDeviceMessageSize != 1024 || limits.MaxOwnerMessageSize != 2048 {
+		t.Errorf("Limits were not applied. Got %v", *limits)
+	}
+
+	for _, badInput := range [][]string{{"0", "", "", "", "", ""}, {"256", "", "", "", "", ""}, {"abc", "", "", "", "", ""}, {"", "0", "", "", "", ""}, {"", "-1", "", "", "", ""}, {"", "abc", "", "", "", ""}, {"", "", "8", "", "", ""}, {"", "", "abc", "", "", ""}, {"", "", "", "0", "", ""}, {"", "", "", "abc", "", ""}, {"", "", "", "", "1023", ""}, {"", "", "", "", "abc", ""}, {"", "", "", "", "", "0"}, {"", "", "", "", "", "abc"}} {
+		_, err = NewResourceLimits(badInput[0], badInput[1], badInput[2], badInput[3], badInput[4], badInput[5])
+		if err == nil {
+			t.Errorf("Expected error for %v", badInput)
+		}
+	}
+}
+
+func TestResourceLimits_DeviceSIMs(t *testing.T) {
+	limits := ResourceLimits{MaxDeviceSIMs: 2, MaxDeviceSIMsSize: 10}
+
+	sim := ServiceInfoKV{ServiceInfoKey: "a:b", ServiceInfoVal: []byte{0x01, 0x02}}
+
+	if err := limits.CheckDeviceSIMs([]ServiceInfoKV{sim, sim}); err != nil {
+		t.Errorf("Expected ServiceInfo at limit to pass. %s", err.Error())
+	}
+
+	if err := limits.CheckDeviceSIMs([]ServiceInfoKV{sim, sim, sim}); err == nil {
+		t.Errorf("Expected ServiceInfo count above limit to fail")
+	}
+
+	largeSim := ServiceInfoKV{ServiceInfoKey: "a:b", ServiceInfoVal: make([]byte, 8)}
+	if err := limits.CheckDeviceSIMs([]ServiceInfoKV{largeSim}); err == nil {
+		t.Errorf("Expected ServiceInfo size above limit to fail")
+	}
+}
+
+func TestResourceLimits_MaxMessageBodySize(t *testing.T) {
+	limits := ResourceLimits{MaxDeviceMessageSize: 1024, MaxOwnerMessageSize: 4096}
+
+	for _, cmd := range []FdoCmd{TO0_20_HELLO, TO0_22_OWNER_SIGN} {
+		if limits.MaxMessageBodySize(cmd) != 4096 {
+			t.Errorf("Expected owner message limit for %d", cmd)
+		}
+	}
+
+	for _, cmd := range []FdoCmd{TO1_30_HELLO_RV, TO1_32_PROVE_TO_RV, TO2_60_HELLO_DEVICE, TO2_68_DEVICE_SERVICE_INFO} {
+		if limits.MaxMessageBodySize(cmd) != 1024 {
+			t.Errorf("Expected device message limit for %d", cmd)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package fdoshared
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type LogLevel int
+
+const (
+	LOG_DEBUG LogLevel = iota
+	LOG_INFO
+	LOG_WARN
+	LOG_ERROR
+)
+
+var logLevelNames = map[LogLevel]string{
+	LOG_DEBUG: "DEBUG",
+	LOG_INFO:  "INFO",
+	LOG_WARN:  "WARN",
+	LOG_ERROR: "ERROR",
+}
+
+func (h LogLevel) String() string {
+	return logLevelNames[h]
+}
+
+// Set once on startup from LOG_LEVEL. Message log lines below it are dropped
+var MinLogLevel LogLevel = LOG_INFO
+
+func ParseLogLevel(logLevelStr string) (LogLevel, error) {
+	if logLevelStr == "" {
+		return LOG_INFO, nil
+	}
+
+	for logLevel, name := range logLevelNames {
+		if strings.EqualFold(logLevelStr, name) {
+			return logLevel, nil
+		}
+	}
+
+	return LOG_INFO, fmt.Errorf("unknown log level %s. Expected debug, info, warn or error", logLevelStr)
+}
+
+// TokenCorrelationID identifies session of the authorization token in logs, without logging the token itself
+func TokenCorrelationID(authzHeader string) string {
+	token := strings.TrimSpace(strings.TrimPrefix(authzHeader, "Bearer "))
+	if token == "" {
+		return "-"
+	}
+
+	tokenHash := sha256.Sum256([]byte(token))
+	return "t-" + hex.EncodeToString(tokenHash[:4])
+}
+
+// MessageLogger logs handling of a single FDO message as "[LEVEL] TO2 68 cid=...: message".
+// Correlation ID is the device GUID once known, so lines match live log GUID filter, and the authorization token hash before that
+type MessageLogger struct {
+	protocol      FdoToProtocol
+	cmd           FdoCmd
+	correlationID string
+}
+
+func NewMessageLogger(protocol FdoToProtocol, cmd FdoCmd, r *http.Request) *MessageLogger {
+	return &MessageLogger{
+		protocol:      protocol,
+		cmd:           cmd,
+		correlationID: TokenCorrelationID(r.Header.Get("Authorization")),
+	}
+}
+
+func (h *MessageLogger) SetGuid(guid FdoGuid) {
+	h.correlationID = hex.EncodeToString(guid[:])
+}
+
+func (h *MessageLogger) CorrelationID() string {
+	return h.correlationID
+}
+
+func (h *MessageLogger) logf(logLevel LogLevel, format string, args ...interface{}) {
+	if logLevel < MinLogLevel {
+		return
+	}
+
+	log.Printf("[%s] TO%d %d cid=%s: %s", logLevel, h.protocol, h.cmd, h.correlationID, fmt.Sprintf(format, args...))
+}
+
+func (h *MessageLogger) Debugf(format string, args ...interface{}) {
+	h.logf(LOG_DEBUG, format, args...)
+}
+
+func (h *MessageLogger) Infof(format string, args ...interface{}) {
+	h.logf(LOG_INFO, format, args...)
+}
+
+func (h *MessageLogger) Warnf(format string, args ...interface{}) {
+	h.logf(LOG_WARN, format, args...)
+}
+
+func (h *MessageLogger) Errorf(format string, args ...interface{}) {
+	h.logf(LOG_ERROR, format, args...)
+}
@@ -0,0 +1,82 @@
+package fdoshared
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for logLevelStr, expected := range map[string]LogLevel{"": LOG_INFO, "debug": LOG_DEBUG, "INFO": LOG_INFO, "Warn": LOG_WARN, "error": LOG_ERROR} {
+		logLevel, err := ParseLogLevel(logLevelStr)
+		if err != nil {
+			t.Errorf("Expected %s to be accepted. %s", logLevelStr, err.Error())
+			continue
+		}
+
+		if logLevel != expected {
+			t.Errorf("Expected %s for %s. Got %s", expected, logLevelStr, logLevel)
+		}
+	}
+
+	_, err := ParseLogLevel("verbose")
+	if err == nil {
+		t.Errorf("Expected unknown log level to be rejected")
+	}
+}
+
+func TestTokenCorrelationID(t *testing.T) {
+	token := "3b7e0f1c2a9d4e5f8a6b"
+
+	correlationID := TokenCorrelationID("Bearer " + token)
+	if !strings.HasPrefix(correlationID, "t-") || strings.Contains(correlationID, token) {
+		t.Errorf("Expected token hash correlation ID. Got %s", correlationID)
+	}
+
+	if TokenCorrelationID(token) != correlationID {
+		t.Errorf("Expected correlation ID to be the same with and without Bearer prefix")
+	}
+
+	if TokenCorrelationID("") != "-" {
+		t.Errorf("Expected - for missing token. Got %s", TokenCorrelationID(""))
+	}
+}
+
+func TestMessageLogger(t *testing.T) {
+	var logBuffer bytes.Buffer
+	log.SetOutput(&logBuffer)
+	prevMinLogLevel := MinLogLevel
+	defer func() {
+		log.SetOutput(os.Stderr)
+		MinLogLevel = prevMinLogLevel
+	}()
+
+	MinLogLevel = LOG_WARN
+
+	r := httptest.NewRequest("POST", "/fdo/101/msg/68", nil)
+	r.Header.Set("Authorization", "Bearer sessiontoken")
+	logger := NewMessageLogger(To2, TO2_68_DEVICE_SERVICE_INFO, r)
+
+	logger.Infof("dropped")
+	if logBuffer.Len() != 0 {
+		t.Errorf("Expected info line to be dropped at warn level. Got %s", logBuffer.String())
+	}
+
+	logger.Warnf("before guid")
+	expected := "[WARN] TO2 68 cid=" + TokenCorrelationID("Bearer sessiontoken") + ": before guid"
+	if !strings.Contains(logBuffer.String(), expected) {
+		t.Errorf("Expected %s. Got %s", expected, logBuffer.String())
+	}
+
+	guid := NewFdoGuid()
+	logger.SetGuid(guid)
+	logger.Errorf("after guid")
+	expected = "[ERROR] TO2 68 cid=" + hex.EncodeToString(guid[:]) + ": after guid"
+	if !strings.Contains(logBuffer.String(), expected) {
+		t.Errorf("Expected %s. Got %s", expected, logBuffer.String())
+	}
+}
@@ -0,0 +1,173 @@
+package fdoshared
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Handler latency histogram buckets, in seconds. Same as Prometheus client defaults
+var METRICS_LATENCY_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricsMessage struct {
+	Protocol FdoToProtocol
+	Cmd      FdoCmd
+}
+
+func (h metricsMessage) String() string {
+	return fmt.Sprintf("TO%d_%d", h.Protocol, h.Cmd)
+}
+
+type metricsError struct {
+	Message   metricsMessage
+	ErrorCode FdoErrorCode
+}
+
+type metricsLatency struct {
+	Buckets []uint64 // Cumulative, per METRICS_LATENCY_BUCKETS
+	Count   uint64
+	Sum     float64
+}
+
+// Metrics counts FDO messages, FDO errors responded, and handler latencies, per message type. Served in Prometheus text format
+type Metrics struct {
+	mu        sync.Mutex
+	messages  map[metricsMessage]uint64
+	errors    map[metricsError]uint64
+	latencies map[metricsMessage]*metricsLatency
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		messages:  map[metricsMessage]uint64{},
+		errors:    map[metricsError]uint64{},
+		latencies: map[metricsMessage]*metricsLatency{},
+	}
+}
+
+// Shared by RV and DO handlers, served on /metrics
+var FdoMetrics *Metrics = NewMetrics()
+
+func (h *Metrics) observe(message metricsMessage, errorCode *FdoErrorCode, duration time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.messages[message]++
+
+	if errorCode != nil {
+		h.errors[metricsError{Message: message, ErrorCode: *errorCode}]++
+	}
+
+	latency, ok := h.latencies[message]
+	if !ok {
+		latency = &metricsLatency{Buckets: make([]uint64, len(METRICS_LATENCY_BUCKETS))}
+		h.latencies[message] = latency
+	}
+
+	seconds := duration.Seconds()
+	for i, bucket := range METRICS_LATENCY_BUCKETS {
+		if seconds <= bucket {
+			latency.Buckets[i]++
+		}
+	}
+	latency.Count++
+	latency.Sum += seconds
+}
+
+// metricsResponseWriter keeps FDO error code of the response, when handler responded with ErrorMessage 255
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	errorCode *FdoErrorCode
+}
+
+func (h *metricsResponseWriter) Write(body []byte) (int, error) {
+	if h.errorCode == nil && h.Header().Get("Message-Type") == TO_ERROR_255.ToString() {
+		var fdoError FdoError
+		if CborCust.Unmarshal(body, &fdoError) == nil {
+			h.errorCode = &fdoError.EMErrorCode
+		}
+	}
+
+	return h.ResponseWriter.Write(body)
+}
+
+// Instrument counts the message, FDO error the handler responded with, and the handler latency
+func (h *Metrics) Instrument(protocol FdoToProtocol, cmd FdoCmd, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metricsWriter := &metricsResponseWriter{ResponseWriter: w}
+
+		start := time.Now()
+		next(metricsWriter, r)
+
+		h.observe(metricsMessage{Protocol: protocol, Cmd: cmd}, metricsWriter.errorCode, time.Since(start))
+	}
+}
+
+func sortedMetricsMessages(messages map[metricsMessage]uint64) []metricsMessage {
+	result := make([]metricsMessage, 0, len(messages))
+	for message := range messages {
+		result = append(result, message)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Protocol != result[j].Protocol {
+			return result[i].Protocol < result[j].Protocol
+		}
+		return result[i].Cmd < result[j].Cmd
+	})
+
+	return result
+}
+
+// WriteText writes metrics in Prometheus text exposition format
+func (h *Metrics) WriteText(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	messages := sortedMetricsMessages(h.messages)
+
+	fmt.Fprintln(w, "# HELP fdo_messages_total FDO messages received, per message type.")
+	fmt.Fprintln(w, "# TYPE fdo_messages_total counter")
+	for _, message := range messages {
+		fmt.Fprintf(w, "fdo_messages_total{message=\"%s\"} %d\n", message, h.messages[message])
+	}
+
+	errorKeys := make([]metricsError, 0, len(h.errors))
+	for errorKey := range h.errors {
+		errorKeys = append(errorKeys, errorKey)
+	}
+	sort.Slice(errorKeys, func(i, j int) bool {
+		if errorKeys[i].Message != errorKeys[j].Message {
+			return errorKeys[i].Message.String() < errorKeys[j].Message.String()
+		}
+		return errorKeys[i].ErrorCode < errorKeys[j].ErrorCode
+	})
+
+	fmt.Fprintln(w, "# HELP fdo_errors_total FDO ErrorMessage responses, per message type and error code.")
+	fmt.Fprintln(w, "# TYPE fdo_errors_total counter")
+	for _, errorKey := range errorKeys {
+		fmt.Fprintf(w, "fdo_errors_total{message=\"%s\",code=\"%d\"} %d\n", errorKey.Message, errorKey.ErrorCode, h.errors[errorKey])
+	}
+
+	fmt.Fprintln(w, "# HELP fdo_handler_duration_seconds FDO message handler latency, per message type.")
+	fmt.Fprintln(w, "# TYPE fdo_handler_duration_seconds histogram")
+	for _, message := range messages {
+		latency := h.latencies[message]
+		for i, bucket := range METRICS_LATENCY_BUCKETS {
+			fmt.Fprintf(w, "fdo_handler_duration_seconds_bucket{message=\"%s\",le=\"%s\"} %d\n", message, strconv.FormatFloat(bucket, 'g', -1, 64), latency.Buckets[i])
+		}
+		fmt.Fprintf(w, "fdo_handler_duration_seconds_bucket{message=\"%s\",le=\"+Inf\"} %d\n", message, latency.Count)
+		fmt.Fprintf(w, "fdo_handler_duration_seconds_sum{message=\"%s\"} %s\n", message, strconv.FormatFloat(latency.Sum, 'g', -1, 64))
+		fmt.Fprintf(w, "fdo_handler_duration_seconds_count{message=\"%s\"} %d\n", message, latency.Count)
+	}
+}
+
+// ServeHTTP serves metrics to Prometheus scraper. Unauthenticated, as /health
+func (h *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.WriteText(w)
+}
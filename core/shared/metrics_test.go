@@ -0,0 +1,56 @@
+package fdoshared
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+
+	handler := metrics.Instrument(To2, TO2_68_DEVICE_SERVICE_INFO, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			RespondFDOError(w, r, MESSAGE_BODY_ERROR, TO2_68_DEVICE_SERVICE_INFO, "Unauthorized!", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Message-Type", TO2_69_OWNER_SERVICE_INFO.ToString())
+		w.Write([]byte{0x80})
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/fdo/101/msg/68", nil))
+
+	r := httptest.NewRequest("POST", "/fdo/101/msg/68", nil)
+	r.Header.Set("Authorization", "Bearer session")
+	handler(httptest.NewRecorder(), r)
+
+	metrics.Instrument(To1, TO1_30_HELLO_RV, func(w http.ResponseWriter, r *http.Request) {})(httptest.NewRecorder(), httptest.NewRequest("POST", "/fdo/101/msg/30", nil))
+
+	var textBuffer bytes.Buffer
+	metrics.WriteText(&textBuffer)
+	text := textBuffer.String()
+
+	for _, expected := range []string{
+		"fdo_messages_total{message=\"TO1_30\"} 1\n",
+		"fdo_messages_total{message=\"TO2_68\"} 2\n",
+		"fdo_errors_total{message=\"TO2_68\",code=\"100\"} 1\n",
+		"fdo_handler_duration_seconds_bucket{message=\"TO2_68\",le=\"+Inf\"} 2\n",
+		"fdo_handler_duration_seconds_bucket{message=\"TO2_68\",le=\"10\"} 2\n",
+		"fdo_handler_duration_seconds_count{message=\"TO1_30\"} 1\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected %s in metrics. Got %s", expected, text)
+		}
+	}
+
+	if strings.Contains(text, "message=\"TO1_30\",code=") {
+		t.Errorf("Expected no errors counted for TO1_30. Got %s", text)
+	}
+
+	if strings.Index(text, "message=\"TO1_30\"") > strings.Index(text, "message=\"TO2_68\"") {
+		t.Errorf("Expected metrics in message order")
+	}
+}
@@ -0,0 +1,62 @@
+package fdoshared
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What DO does when a device starts TO2 while its previous TO2 session is still in progress, e.g. a retrying device
+type ConcurrentOnboardingPolicy string
+
+const (
+	// Previous session is dropped, and its following messages fail
+	ONBOARDING_POLICY_SUPERSEDE ConcurrentOnboardingPolicy = "supersede"
+
+	// New HelloDevice is rejected until previous session completes or expires
+	ONBOARDING_POLICY_REJECT ConcurrentOnboardingPolicy = "reject"
+)
+
+// Policy is set once on startup from config
+var ConcurrentOnboarding ConcurrentOnboardingPolicy = ONBOARDING_POLICY_SUPERSEDE
+
+// ParseConcurrentOnboardingPolicy returns ONBOARDING_POLICY_SUPERSEDE for empty string
+func ParseConcurrentOnboardingPolicy(policyStr string) (ConcurrentOnboardingPolicy, error) {
+	policy := ConcurrentOnboardingPolicy(strings.ToLower(strings.TrimSpace(policyStr)))
+
+	switch policy {
+	case "":
+		return ONBOARDING_POLICY_SUPERSEDE, nil
+	case ONBOARDING_POLICY_SUPERSEDE, ONBOARDING_POLICY_REJECT:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown concurrent onboarding policy %s", policyStr)
+	}
+}
+
+// What DO does when a device starts TO2 again with the voucher it already completed TO2 with, e.g. a voucher resold or replayed
+type ReonboardPolicy string
+
+const (
+	// Voucher can be used again. Devices reusing their credential are onboarded again
+	REONBOARD_POLICY_ALLOW ReonboardPolicy = "allow"
+
+	// Voucher is single use. HelloDevice of an onboarded device is rejected with RESOURCE_NOT_FOUND
+	REONBOARD_POLICY_DENY ReonboardPolicy = "deny"
+)
+
+// Policy of DO is set once on startup from config
+var Reonboard ReonboardPolicy = REONBOARD_POLICY_ALLOW
+
+// ParseReonboardPolicy returns defaultPolicy for empty string
+func ParseReonboardPolicy(policyStr string, defaultPolicy ReonboardPolicy) (ReonboardPolicy, error) {
+	policy := ReonboardPolicy(strings.ToLower(strings.TrimSpace(policyStr)))
+
+	switch policy {
+	case "":
+		return defaultPolicy, nil
+	case REONBOARD_POLICY_ALLOW, REONBOARD_POLICY_DENY:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown reonboard policy %s", policyStr)
+	}
+}
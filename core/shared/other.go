@@ -3,6 +3,8 @@ package fdoshared
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math/big"
@@ -50,6 +52,46 @@ func (h *FdoGuid) FromBytes(guidBytes []byte) error {
 	return nil
 }
 
+// ParseFdoGuid accepts GUID as hex, with or without UUID dashes, or as base64url/base64, padded or not
+func ParseFdoGuid(guidStr string) (FdoGuid, error) {
+	var guid FdoGuid
+	var guidBytes []byte
+	var err error
+
+	guidStr = strings.TrimSpace(guidStr)
+	switch len(guidStr) {
+	case 32:
+		guidBytes, err = hex.DecodeString(guidStr)
+	case 36:
+		var uuidInst uuid.UUID
+		uuidInst, err = uuid.Parse(guidStr)
+		guidBytes = uuidInst[:]
+	case 22:
+		guidBytes, err = base64.RawURLEncoding.DecodeString(guidStr)
+		if err != nil {
+			guidBytes, err = base64.RawStdEncoding.DecodeString(guidStr)
+		}
+	case 24:
+		guidBytes, err = base64.URLEncoding.DecodeString(guidStr)
+		if err != nil {
+			guidBytes, err = base64.StdEncoding.DecodeString(guidStr)
+		}
+	default:
+		return guid, fmt.Errorf("invalid GUID \"%s\". Expected 32 hex characters, or 22 base64url characters", guidStr)
+	}
+
+	if err != nil {
+		return guid, fmt.Errorf("invalid GUID \"%s\". %s", guidStr, err.Error())
+	}
+
+	err = guid.FromBytes(guidBytes)
+	if err != nil {
+		return guid, fmt.Errorf("invalid GUID \"%s\". %s", guidStr, err.Error())
+	}
+
+	return guid, nil
+}
+
 func NewFdoGuid() FdoGuid {
 	newUuid, _ := uuid.NewRandom()
 	uuidBytes, _ := newUuid.MarshalBinary()
@@ -238,6 +280,30 @@ type RVTO2AddrEntry struct {
 	RVProtocol TransportProtocol
 }
 
+// ToUrl returns URL of the owner address. Only HTTP and HTTPS are supported
+func (h RVTO2AddrEntry) ToUrl() (string, error) {
+	var scheme string
+	switch h.RVProtocol {
+	case ProtHTTP:
+		scheme = "http"
+	case ProtHTTPS:
+		scheme = "https"
+	default:
+		return "", fmt.Errorf("unsupported owner address protocol %d", h.RVProtocol)
+	}
+
+	var host string
+	if h.RVDNS != nil {
+		host = *h.RVDNS
+	} else if h.RVIP != nil {
+		host = h.RVIP.String()
+	} else {
+		return "", errors.New("owner address has neither IP nor DNS")
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(int(h.RVPort)))), nil
+}
+
 func DecodeErrorResponse(bodyBytes []byte) (*FdoError, error) {
 	var errInst FdoError
 	err := CborCust.Unmarshal(bodyBytes, &errInst)
@@ -348,6 +414,95 @@ func UrlToTOAddrEntry(inurl string) (*RVTO2AddrEntry, error) {
 	return &result, nil
 }
 
+// To2AddrConfig overrides owner address that TO0 registers in To1d RVTO2Addr. Empty fields are taken from FDO service URL
+type To2AddrConfig struct {
+	Protocol string `json:"protocol,omitempty"` // http or https
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+}
+
+func (h To2AddrConfig) Validate() error {
+	if h.Protocol != "" && h.Protocol != "http" && h.Protocol != "https" {
+		return fmt.Errorf("unsupported protocol %s. Expected http or https", h.Protocol)
+	}
+
+	if h.Port < 0 || h.Port > 65535 {
+		return fmt.Errorf("port %d is out of range 1-65535", h.Port)
+	}
+
+	if strings.ContainsAny(h.Host, "/:?#@[] ") && net.ParseIP(h.Host) == nil {
+		return fmt.Errorf("invalid host %s", h.Host)
+	}
+
+	return nil
+}
+
+// ToRVTO2AddrEntry returns owner address of the service URL, with protocol, host and port replaced by the set fields.
+// Without explicit port, service URL port is kept only when protocol is not changed
+func (h To2AddrConfig) ToRVTO2AddrEntry(serviceUrl string) (*RVTO2AddrEntry, error) {
+	err := h.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(serviceUrl)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url %s. %s", serviceUrl, err.Error())
+	}
+
+	scheme := u.Scheme
+	if h.Protocol != "" {
+		scheme = h.Protocol
+	}
+
+	host := u.Hostname()
+	if h.Host != "" {
+		host = h.Host
+	}
+
+	port := ""
+	if h.Port != 0 {
+		port = strconv.Itoa(h.Port)
+	} else if scheme == u.Scheme {
+		port = u.Port()
+	}
+
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	return UrlToTOAddrEntry(scheme + "://" + host)
+}
+
+// SameFdoEndpoint returns true if both urls point to the same protocol, host and port. Default ports are resolved, and paths ignored
+func SameFdoEndpoint(urlA string, urlB string) bool {
+	entryA, err := UrlToTOAddrEntry(urlA)
+	if err != nil {
+		return false
+	}
+
+	entryB, err := UrlToTOAddrEntry(urlB)
+	if err != nil {
+		return false
+	}
+
+	if entryA.RVProtocol != entryB.RVProtocol || entryA.RVPort != entryB.RVPort {
+		return false
+	}
+
+	if entryA.RVDNS != nil && entryB.RVDNS != nil {
+		return strings.EqualFold(*entryA.RVDNS, *entryB.RVDNS)
+	}
+
+	if entryA.RVIP != nil && entryB.RVIP != nil {
+		return net.IP(*entryA.RVIP).Equal(net.IP(*entryB.RVIP))
+	}
+
+	return false
+}
+
 func UrlToRvDirective(inurl string) (RendezvousDirective, error) {
 	rvto2addr, err := UrlToTOAddrEntry(inurl)
 	if err != nil {
@@ -389,6 +544,20 @@ func UrlsToRendezvousInfo(urls []string) (RendezvousInfo, error) {
 	return rvInfo, nil
 }
 
+// UrlsToBypassRendezvousInfo generates RVInfo with RVBypass directives. Device skips TO1, and runs TO2 directly with the owner at urls
+func UrlsToBypassRendezvousInfo(ownerUrls []string) (RendezvousInfo, error) {
+	rvInfo, err := UrlsToRendezvousInfo(ownerUrls)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rvInfo {
+		rvInfo[i].AddInstr(RendezvousInstr{Key: RVBypass})
+	}
+
+	return rvInfo, nil
+}
+
 func GenerateEatGuid(fdoGuid FdoGuid) [17]byte {
 	var result [17]byte
 	copy(result[:], append([]byte{0x01}, fdoGuid[:]...))
@@ -0,0 +1,75 @@
+package fdoshared
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestParseFdoGuid(t *testing.T) {
+	guid := NewFdoGuid()
+
+	encodings := map[string]string{
+		"hex":              hex.EncodeToString(guid[:]),
+		"hex uppercase":    strings.ToUpper(hex.EncodeToString(guid[:])),
+		"uuid":             guid.GetFormatted(),
+		"base64url":        base64.RawURLEncoding.EncodeToString(guid[:]),
+		"base64url padded": base64.URLEncoding.EncodeToString(guid[:]),
+		"base64":           base64.StdEncoding.EncodeToString(guid[:]),
+	}
+
+	for name, encoded := range encodings {
+		parsedGuid, err := ParseFdoGuid(encoded)
+		if err != nil {
+			t.Errorf("Failed to parse %s GUID %s. %s", name, encoded, err.Error())
+			continue
+		}
+
+		if parsedGuid != guid {
+			t.Errorf("Parsed %s GUID %s does not match", name, encoded)
+		}
+	}
+
+	for _, malformed := range []string{
+		"",
+		"abcd",
+		strings.Repeat("z", 32),
+		strings.Repeat("!", 22),
+		hex.EncodeToString(guid[:]) + "00",
+		"not-a-uuid-but-thirty-six-chars-long",
+	} {
+		_, err := ParseFdoGuid(malformed)
+		if err == nil {
+			t.Errorf("Expected malformed GUID \"%s\" to fail", malformed)
+		}
+	}
+}
+
+func TestTo2AddrConfig_Validate(t *testing.T) {
+	invalid := []To2AddrConfig{
+		{Protocol: "coap"},
+		{Port: 65536},
+		{Port: -1},
+		{Host: "do.example.com:8080"},
+	}
+
+	for _, to2Addr := range invalid {
+		if to2Addr.Validate() == nil {
+			t.Errorf("Expected %+v to be rejected", to2Addr)
+		}
+	}
+
+	valid := []To2AddrConfig{
+		{},
+		{Protocol: "https", Host: "::1", Port: 1},
+		{Protocol: "http", Host: "do.example.com", Port: 65535},
+	}
+
+	for _, to2Addr := range valid {
+		err := to2Addr.Validate()
+		if err != nil {
+			t.Errorf("Expected %+v to be accepted. %s", to2Addr, err.Error())
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package fdoshared
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Outbound policy for tester supplied RV/DO URLs. Entries are host, host:port, *.domain or CIDR
+type OutboundPolicy struct {
+	Allowed []string
+	Denied  []string
+}
+
+// Policy is set once on startup from config. Empty policy allows everything
+var Outbound OutboundPolicy = OutboundPolicy{}
+
+var outboundLookupIP = net.LookupIP
+
+func parsePolicyList(listStr string) []string {
+	var entries []string
+	for _, entry := range strings.Split(listStr, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func NewOutboundPolicy(allowedStr string, deniedStr string) (*OutboundPolicy, error) {
+	policy := OutboundPolicy{
+		Allowed: parsePolicyList(allowedStr),
+		Denied:  parsePolicyList(deniedStr),
+	}
+
+	for _, entry := range append(policy.Allowed, policy.Denied...) {
+		if strings.Contains(entry, "/") {
+			_, _, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing outbound policy entry %s. %s", entry, err.Error())
+			}
+		}
+	}
+
+	return &policy, nil
+}
+
+func policyEntryMatches(entry string, host string, port string, ips []net.IP) bool {
+	if strings.Contains(entry, "/") {
+		_, ipNet, _ := net.ParseCIDR(entry)
+		for _, ip := range ips {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	entryHost, entryPort, err := net.SplitHostPort(entry)
+	if err != nil {
+		entryHost = entry
+		entryPort = ""
+	}
+
+	if entryPort != "" && entryPort != port {
+		return false
+	}
+
+	if strings.HasPrefix(entryHost, "*.") {
+		return strings.HasSuffix(host, entryHost[1:])
+	}
+
+	return entryHost == host
+}
+
+func (h OutboundPolicy) matchesAny(entries []string, host string, port string, ips []net.IP) bool {
+	for _, entry := range entries {
+		if policyEntryMatches(entry, host, port, ips) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckURL returns error if target of the url is not allowed by the policy
+func (h OutboundPolicy) CheckURL(rawUrl string) error {
+	if len(h.Allowed) == 0 && len(h.Denied) == 0 {
+		return nil
+	}
+
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("error parsing url. %s", err.Error())
+	}
+
+	host := strings.ToLower(parsedUrl.Hostname())
+	port := parsedUrl.Port()
+	if port == "" {
+		switch parsedUrl.Scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		}
+	}
+
+	// Resolve, so that names pointing to denied networks are caught as well
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if resolvedIps, err := outboundLookupIP(host); err == nil {
+		ips = resolvedIps
+	}
+
+	if h.matchesAny(h.Denied, host, port, ips) {
+		return fmt.Errorf("target %s is denied by outbound policy", parsedUrl.Host)
+	}
+
+	if len(h.Allowed) > 0 && !h.matchesAny(h.Allowed, host, port, ips) {
+		return fmt.Errorf("target %s is not in the outbound allowlist", parsedUrl.Host)
+	}
+
+	return nil
+}
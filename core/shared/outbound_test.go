@@ -0,0 +1,62 @@
+package fdoshared
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestOutboundPolicy(t *testing.T) {
+	outboundLookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "internal.example.com":
+			return []net.IP{net.ParseIP("10.1.2.3")}, nil
+		case "rv.example.com", "do.partner.org":
+			return []net.IP{net.ParseIP("203.0.113.10")}, nil
+		}
+
+		return nil, errors.New("not found")
+	}
+	defer func() { outboundLookupIP = net.LookupIP }()
+
+	emptyPolicy, _ := NewOutboundPolicy("", "")
+	if err := emptyPolicy.CheckURL("http://127.0.0.1:8080"); err != nil {
+		t.Errorf("Expected empty policy to allow everything. %s", err.Error())
+	}
+
+	_, err := NewOutboundPolicy("10.0.0.0/33", "")
+	if err == nil {
+		t.Errorf("Expected bad CIDR to fail")
+	}
+
+	policy, err := NewOutboundPolicy("*.example.com, do.partner.org:8443", "10.0.0.0/8, 127.0.0.0/8, 169.254.0.0/16")
+	if err != nil {
+		t.Fatalf("Failed to create policy. %s", err.Error())
+	}
+
+	allowed := []string{
+		"https://rv.example.com",
+		"http://rv.example.com:8080",
+		"https://do.partner.org:8443",
+	}
+
+	for _, targetUrl := range allowed {
+		if err := policy.CheckURL(targetUrl); err != nil {
+			t.Errorf("Expected %s to be allowed. %s", targetUrl, err.Error())
+		}
+	}
+
+	blocked := []string{
+		"http://127.0.0.1:8080",
+		"http://169.254.169.254",
+		"https://internal.example.com",
+		"https://do.partner.org",
+		"https://attacker.net",
+	}
+
+	for _, targetUrl := range blocked {
+		if err := policy.CheckURL(targetUrl); err == nil {
+			t.Errorf("Expected %s to be blocked", targetUrl)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package fdoshared
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// How requestors handle HTTP redirects from tester supplied RV/DO URLs
+type RedirectPolicy string
+
+const (
+	// Redirects are reported as an error
+	REDIRECT_POLICY_REJECT RedirectPolicy = "reject"
+
+	// Redirects are followed. Authorization header is only kept when scheme and host do not change
+	REDIRECT_POLICY_FOLLOW RedirectPolicy = "follow"
+
+	// Redirects are followed, and Authorization header is sent to any target. Only for trusted redirectors
+	REDIRECT_POLICY_FOLLOW_FORWARD_AUTHZ RedirectPolicy = "follow_forward_authz"
+)
+
+const MAX_REDIRECTS int = 10
+
+// Policy is set once on startup from config
+var Redirects RedirectPolicy = REDIRECT_POLICY_FOLLOW
+
+// ParseRedirectPolicy returns REDIRECT_POLICY_FOLLOW for empty string
+func ParseRedirectPolicy(policyStr string) (RedirectPolicy, error) {
+	policy := RedirectPolicy(strings.ToLower(strings.TrimSpace(policyStr)))
+
+	switch policy {
+	case "":
+		return REDIRECT_POLICY_FOLLOW, nil
+	case REDIRECT_POLICY_REJECT, REDIRECT_POLICY_FOLLOW, REDIRECT_POLICY_FOLLOW_FORWARD_AUTHZ:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown redirect policy %s", policyStr)
+	}
+}
+
+func isRedirectStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextRedirect checks redirect response against the policy, and returns the target url and whether Authorization header can be sent to it
+func (h RedirectPolicy) nextRedirect(resp *http.Response, redirectCount int) (string, bool, error) {
+	if h == REDIRECT_POLICY_REJECT {
+		return "", false, fmt.Errorf("Server responded with %d redirect to %s. Redirects are rejected by policy", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	if redirectCount >= MAX_REDIRECTS {
+		return "", false, fmt.Errorf("Stopped after %d redirects", MAX_REDIRECTS)
+	}
+
+	targetUrl, err := resp.Location()
+	if err != nil {
+		return "", false, fmt.Errorf("Bad redirect location. %s", err.Error())
+	}
+
+	err = Outbound.CheckURL(targetUrl.String())
+	if err != nil {
+		return "", false, fmt.Errorf("Redirect to %s is not allowed. %s", targetUrl.Host, err.Error())
+	}
+
+	keepAuthz := h == REDIRECT_POLICY_FOLLOW_FORWARD_AUTHZ || isSameOrigin(resp.Request.URL, targetUrl)
+
+	return targetUrl.String(), keepAuthz, nil
+}
+
+func isSameOrigin(a *url.URL, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
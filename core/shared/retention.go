@@ -0,0 +1,26 @@
+package fdoshared
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Set once on startup from MAX_RUNS_PER_USER. 0 keeps all runs
+var MaxRunsPerUser int = 0
+
+func ParseMaxRunsPerUser(maxRunsStr string) (int, error) {
+	if maxRunsStr == "" {
+		return 0, nil
+	}
+
+	maxRuns, err := strconv.Atoi(maxRunsStr)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing max runs per user. %s", err.Error())
+	}
+
+	if maxRuns < 0 {
+		return 0, fmt.Errorf("max runs per user must not be negative. Got %d", maxRuns)
+	}
+
+	return maxRuns, nil
+}
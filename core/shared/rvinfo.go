@@ -3,6 +3,8 @@ package fdoshared
 import (
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
 )
 
 type RVMediumValue uint8
@@ -113,6 +115,11 @@ func (h *RendezvousDirective) Validate() error {
 			return fmt.Errorf("boolean key (%d) has non-nil value", instr.Key)
 		}
 
+		if instr.Key.IsBoolean() {
+			recordedKeys[instr.Key]++
+			continue
+		}
+
 		// Is valid cbor
 		var v interface{}
 		err := CborCust.Unmarshal(instr.Value, &v)
@@ -128,6 +135,19 @@ func (h *RendezvousDirective) Validate() error {
 
 type RendezvousInfo []RendezvousDirective
 
+// HasBypass returns true if any directive tells device to skip TO1 and contact owner directly
+func (h RendezvousInfo) HasBypass() bool {
+	for _, directive := range h {
+		for _, instr := range directive {
+			if instr.Key == RVBypass {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // Mapped RV Instructions to struct
 
 func GetMappedRVInfo(instrLists RendezvousInfo) (MappedRVInfo, error) {
@@ -224,6 +244,33 @@ func (h *MappedRVDirective) GetOwnerUrls() []string {
 	return result
 }
 
+// GetDeviceUrls returns addresses device contacts for the directive. RV server, or owner with RVBypass
+func (h *MappedRVDirective) GetDeviceUrls() []string {
+	var result []string
+
+	scheme := "https"
+	selectedPort := uint16(443)
+
+	if h.RVProtocol != nil && *h.RVProtocol == RVProtHttp {
+		scheme = "http"
+		selectedPort = 80
+	}
+
+	if h.RVDevPort != nil {
+		selectedPort = *h.RVDevPort
+	}
+
+	if h.RVDns != nil {
+		result = append(result, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(*h.RVDns, strconv.Itoa(int(selectedPort)))))
+	}
+
+	for _, ipAddr := range h.RVIPAddresses {
+		result = append(result, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(ipAddr.String(), strconv.Itoa(int(selectedPort)))))
+	}
+
+	return result
+}
+
 func NewMappedRVDirective(instrList RendezvousDirective) (MappedRVDirective, error) {
 	rvib := MappedRVDirective{}
 
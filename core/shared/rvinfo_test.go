@@ -0,0 +1,91 @@
+package fdoshared
+
+import "testing"
+
+func TestUrlsToBypassRendezvousInfo(t *testing.T) {
+	rvInfo, err := UrlsToRendezvousInfo([]string{"http://localhost:8080"})
+	if err != nil {
+		t.Fatalf("Failed to generate RVInfo. %s", err.Error())
+	}
+
+	if rvInfo.HasBypass() {
+		t.Errorf("Expected regular RVInfo to not have RVBypass")
+	}
+
+	bypassRvInfo, err := UrlsToBypassRendezvousInfo([]string{"http://localhost:8080"})
+	if err != nil {
+		t.Fatalf("Failed to generate bypass RVInfo. %s", err.Error())
+	}
+
+	if !bypassRvInfo.HasBypass() {
+		t.Errorf("Expected bypass RVInfo to have RVBypass")
+	}
+
+	// Survives encoding, as it is stored in the voucher header
+	bypassRvInfoBytes, _ := CborCust.Marshal(bypassRvInfo)
+
+	var decodedRvInfo RendezvousInfo
+	err = CborCust.Unmarshal(bypassRvInfoBytes, &decodedRvInfo)
+	if err != nil {
+		t.Fatalf("Failed to decode bypass RVInfo. %s", err.Error())
+	}
+
+	mappedRvInfo, err := GetMappedRVInfo(decodedRvInfo)
+	if err != nil {
+		t.Fatalf("Expected bypass RVInfo to be valid. %s", err.Error())
+	}
+
+	if !mappedRvInfo[0].RVBypass {
+		t.Errorf("Expected mapped directive to have RVBypass")
+	}
+}
+
+func TestRVInfoDeviceUrls(t *testing.T) {
+	rvInfo, err := UrlsToRendezvousInfo([]string{"http://rv.example.com:8040", "https://10.0.0.1"})
+	if err != nil {
+		t.Fatalf("Failed to generate RVInfo. %s", err.Error())
+	}
+
+	mappedRvInfo, err := GetMappedRVInfo(rvInfo)
+	if err != nil {
+		t.Fatalf("Failed to map RVInfo. %s", err.Error())
+	}
+
+	expectedUrls := []string{"http://rv.example.com:8040", "https://10.0.0.1:443"}
+	for i, directive := range mappedRvInfo.GetDevOnly() {
+		deviceUrls := directive.GetDeviceUrls()
+		if len(deviceUrls) != 1 || deviceUrls[0] != expectedUrls[i] {
+			t.Errorf("Expected device urls [%s], got %v", expectedUrls[i], deviceUrls)
+		}
+
+		if !SameFdoEndpoint(deviceUrls[0], expectedUrls[i]+"/fdo/101/msg/30") {
+			t.Errorf("Expected %s to be the same endpoint as its url with path", deviceUrls[0])
+		}
+	}
+
+	if !SameFdoEndpoint("https://10.0.0.1", "https://10.0.0.1:443") {
+		t.Errorf("Expected default https port to be resolved")
+	}
+
+	if !SameFdoEndpoint("http://RV.example.com:8040", "http://rv.example.com:8040") {
+		t.Errorf("Expected DNS to be case insensitive")
+	}
+
+	if SameFdoEndpoint("http://rv.example.com:8040", "https://rv.example.com:8040") {
+		t.Errorf("Expected different protocols to mismatch")
+	}
+
+	if SameFdoEndpoint("http://rv.example.com:8040", "http://rv.example.com:8041") {
+		t.Errorf("Expected different ports to mismatch")
+	}
+
+	to2AddrEntry, err := UrlToTOAddrEntry("http://[::1]:8080")
+	if err != nil {
+		t.Fatalf("Failed to generate owner address. %s", err.Error())
+	}
+
+	ownerUrl, err := to2AddrEntry.ToUrl()
+	if err != nil || ownerUrl != "http://[::1]:8080" {
+		t.Errorf("Expected owner url http://[::1]:8080, got %s %v", ownerUrl, err)
+	}
+}
@@ -0,0 +1,25 @@
+package fdoshared
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ParseSessionTTL parses session TTL in seconds. Empty string returns defaultTTL
+func ParseSessionTTL(ttlStr string, defaultTTL time.Duration) (time.Duration, error) {
+	if ttlStr == "" {
+		return defaultTTL, nil
+	}
+
+	ttlSeconds, err := strconv.Atoi(ttlStr)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing session TTL. %s", err.Error())
+	}
+
+	if ttlSeconds < 1 {
+		return 0, fmt.Errorf("session TTL must be at least 1 second. Got %d", ttlSeconds)
+	}
+
+	return time.Duration(ttlSeconds) * time.Second, nil
+}
@@ -0,0 +1,25 @@
+package fdoshared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSessionTTL(t *testing.T) {
+	ttl, err := ParseSessionTTL("", time.Minute)
+	if err != nil || ttl != time.Minute {
+		t.Errorf("Expected default TTL for empty string. Got %s, %v", ttl, err)
+	}
+
+	ttl, err = ParseSessionTTL("3600", time.Minute)
+	if err != nil || ttl != time.Hour {
+		t.Errorf("Expected 1h TTL. Got %s, %v", ttl, err)
+	}
+
+	for _, badTTL := range []string{"0", "-5", "10m"} {
+		_, err = ParseSessionTTL(badTTL, time.Minute)
+		if err == nil {
+			t.Errorf("Expected %s to be rejected", badTTL)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package fdoshared
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Grace period for in-flight onboarding sessions on shutdown
+const DEFAULT_SHUTDOWN_GRACE_PERIOD time.Duration = 30 * time.Second
+
+// Set from SHUTDOWN_GRACE_PERIOD
+var ShutdownGracePeriod time.Duration = DEFAULT_SHUTDOWN_GRACE_PERIOD
+
+// Open session with no message for this long was abandoned by the device. Same as default TO2 session TTL
+const DEFAULT_DRAIN_SESSION_TTL time.Duration = 10 * time.Minute
+
+// Messages that open, and that complete TO0, TO1 and TO2 sessions
+var drainSessionStartCmds = map[FdoCmd]bool{TO0_20_HELLO: true, TO1_30_HELLO_RV: true, TO2_60_HELLO_DEVICE: true}
+var drainSessionEndCmds = map[FdoCmd]bool{TO0_22_OWNER_SIGN: true, TO1_32_PROVE_TO_RV: true, TO2_70_DONE: true, TO_ERROR_255: true}
+
+// Drainer tracks in-flight FDO requests and open onboarding sessions, so shutdown can let them complete.
+// Once draining, new sessions are refused, and messages of open sessions are still served
+type Drainer struct {
+	mu         sync.Mutex
+	draining   bool
+	requests   int
+	sessions   map[string]time.Time // By Authorization token, to time of the last message of the session
+	sessionTTL time.Duration
+	changed    chan struct{} // Closed and replaced on every change of requests or sessions
+	now        func() time.Time
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{
+		sessions:   map[string]time.Time{},
+		sessionTTL: DEFAULT_DRAIN_SESSION_TTL,
+		changed:    make(chan struct{}),
+		now:        time.Now,
+	}
+}
+
+// Shared by RV and DO handlers, checked by /ready
+var FdoDrainer *Drainer = NewDrainer()
+
+func (h *Drainer) notifyLocked() {
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// SetSessionTTL sets time after the last message of the session, after which the session is treated as abandoned and is no longer waited for
+func (h *Drainer) SetSessionTTL(sessionTTL time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sessionTTL = sessionTTL
+}
+
+// expireSessionsLocked forgets abandoned sessions. Returns when the next open session expires, or zero time when there are none
+func (h *Drainer) expireSessionsLocked() time.Time {
+	now := h.now()
+
+	var nextExpiry time.Time
+	for token, lastMessage := range h.sessions {
+		expiry := lastMessage.Add(h.sessionTTL)
+		if !now.Before(expiry) {
+			delete(h.sessions, token)
+			continue
+		}
+
+		if nextExpiry.IsZero() || expiry.Before(nextExpiry) {
+			nextExpiry = expiry
+		}
+	}
+
+	return nextExpiry
+}
+
+// Draining returns true once shutdown started
+func (h *Drainer) Draining() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.draining
+}
+
+// InFlight returns number of in-flight requests and open sessions
+func (h *Drainer) InFlight() (int, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.expireSessionsLocked()
+
+	return h.requests, len(h.sessions)
+}
+
+// Track refuses new sessions while draining, and keeps track of the open sessions and in-flight requests.
+// Session is open from successful response to its first message, until response to its last message or an error message, or until it is abandoned for session TTL
+func (h *Drainer) Track(cmd FdoCmd, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		if h.draining && drainSessionStartCmds[cmd] {
+			h.mu.Unlock()
+			RespondFDOError(w, r, INTERNAL_SERVER_ERROR, cmd, "Server is shutting down. Retry later", http.StatusServiceUnavailable)
+			return
+		}
+		h.requests++
+		h.mu.Unlock()
+
+		next(w, r)
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.requests--
+
+		failed := w.Header().Get("Message-Type") == TO_ERROR_255.ToString()
+		if drainSessionStartCmds[cmd] && !failed {
+			if token := w.Header().Get("Authorization"); token != "" {
+				h.sessions[token] = h.now()
+			}
+		} else if drainSessionEndCmds[cmd] || failed {
+			delete(h.sessions, r.Header.Get("Authorization"))
+		} else if _, ok := h.sessions[r.Header.Get("Authorization")]; ok {
+			h.sessions[r.Header.Get("Authorization")] = h.now()
+		}
+
+		h.expireSessionsLocked()
+		h.notifyLocked()
+	}
+}
+
+// Drain stops new sessions, and waits for in-flight requests and open sessions, up to the grace period.
+// Returns false when grace period expired first. Sessions abandoned by devices are not waited for once their session TTL expires
+func (h *Drainer) Drain(gracePeriod time.Duration) bool {
+	deadline := time.NewTimer(gracePeriod)
+	defer deadline.Stop()
+
+	h.mu.Lock()
+	h.draining = true
+	for {
+		nextExpiry := h.expireSessionsLocked()
+		if h.requests == 0 && len(h.sessions) == 0 {
+			break
+		}
+
+		changed := h.changed
+		var sessionExpired <-chan time.Time
+		var expiryTimer *time.Timer
+		if !nextExpiry.IsZero() {
+			expiryTimer = time.NewTimer(nextExpiry.Sub(h.now()))
+			sessionExpired = expiryTimer.C
+		}
+		h.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-sessionExpired:
+		case <-deadline.C:
+			h.mu.Lock()
+			log.Printf("Shutdown grace period expired with %d in-flight requests and %d open sessions", h.requests, len(h.sessions))
+			h.mu.Unlock()
+			return false
+		}
+
+		if expiryTimer != nil {
+			expiryTimer.Stop()
+		}
+
+		h.mu.Lock()
+	}
+	h.mu.Unlock()
+
+	return true
+}
+
+// GracefulShutdown drains FDO sessions, then shuts down servers, waiting for their in-flight requests. DB can be closed once it returns
+func GracefulShutdown(drainer *Drainer, gracePeriod time.Duration, servers ...*http.Server) {
+	log.Printf("Shutting down. Waiting up to %s for in-flight onboarding sessions...", gracePeriod)
+
+	start := time.Now()
+	if drainer.Drain(gracePeriod) {
+		log.Println("All onboarding sessions completed")
+	}
+
+	// Requests that are not FDO messages, or that outlived the grace period, get a few seconds more
+	shutdownTimeout := gracePeriod - time.Since(start)
+	if shutdownTimeout < 5*time.Second {
+		shutdownTimeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		err := server.Shutdown(ctx)
+		if err != nil {
+			log.Printf("Error shutting down server %s. %s", server.Addr, err.Error())
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package fdoshared
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func postFdoMessage(url string, authzHeader string) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader([]byte{0x80}))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", CONTENT_TYPE_CBOR)
+	if authzHeader != "" {
+		req.Header.Set("Authorization", authzHeader)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func TestGracefulShutdown_FlushesSessionState(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+
+	drainer := NewDrainer()
+	sessionKey := []byte("session-test")
+	handlerStarted := make(chan struct{})
+	releaseHandler := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/60", drainer.Track(TO2_60_HELLO_DEVICE, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Authorization", "test-token")
+		w.WriteHeader(http.StatusOK)
+	}))
+	mux.HandleFunc("/fdo/101/msg/70", drainer.Track(TO2_70_DONE, func(w http.ResponseWriter, r *http.Request) {
+		close(handlerStarted)
+		<-releaseHandler
+
+		err := db.Update(func(txn *badger.Txn) error {
+			return txn.Set(sessionKey, []byte(r.Header.Get("Authorization")))
+		})
+		if err != nil {
+			t.Errorf("Failed to save session. %s", err.Error())
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen. %s", err.Error())
+	}
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	baseUrl := "http://" + listener.Addr().String()
+
+	status, err := postFdoMessage(baseUrl+"/fdo/101/msg/60", "")
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Expected HelloDevice60 to be served. Got status %d, error %v", status, err)
+	}
+
+	if _, sessions := drainer.InFlight(); sessions != 1 {
+		t.Fatalf("Expected one open session. Got %d", sessions)
+	}
+
+	clientDone := make(chan struct{})
+	go func() {
+		defer close(clientDone)
+		status, err := postFdoMessage(baseUrl+"/fdo/101/msg/70", "test-token")
+		if err != nil || status != http.StatusOK {
+			t.Errorf("Expected in-flight Done70 to complete. Got status %d, error %v", status, err)
+		}
+	}()
+	<-handlerStarted
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		GracefulShutdown(drainer, 10*time.Second, server)
+		close(shutdownDone)
+	}()
+
+	for !drainer.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	status, err = postFdoMessage(baseUrl+"/fdo/101/msg/60", "")
+	if err != nil || status != http.StatusServiceUnavailable {
+		t.Errorf("Expected new session to be refused while draining. Got status %d, error %v", status, err)
+	}
+
+	select {
+	case <-shutdownDone:
+		t.Fatalf("Expected shutdown to wait for in-flight handler")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(releaseHandler)
+	<-shutdownDone
+	<-clientDone
+
+	if requests, sessions := drainer.InFlight(); requests != 0 || sessions != 0 {
+		t.Errorf("Expected no in-flight requests and sessions. Got %d and %d", requests, sessions)
+	}
+
+	err = db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(sessionKey)
+		return err
+	})
+	if err != nil {
+		t.Errorf("Expected session state to be saved before shutdown completed. %s", err.Error())
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Errorf("Failed to close db. %s", err.Error())
+	}
+}
+
+func TestDrainer_GracePeriodExpires(t *testing.T) {
+	drainer := NewDrainer()
+
+	handler := drainer.Track(TO1_30_HELLO_RV, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Authorization", "abandoned")
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/fdo/101/msg/30", nil))
+
+	if drainer.Drain(10 * time.Millisecond) {
+		t.Errorf("Expected drain to time out on abandoned session")
+	}
+}
+
+func TestDrainer_AbandonedSessionExpires(t *testing.T) {
+	drainer := NewDrainer()
+	now := time.Now()
+	drainer.now = func() time.Time {
+		return now
+	}
+
+	handler := drainer.Track(TO2_60_HELLO_DEVICE, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Authorization", r.Header.Get("X-Test-Token"))
+	})
+	openSession := func(token string) {
+		req := httptest.NewRequest(http.MethodPost, "/fdo/101/msg/60", nil)
+		req.Header.Set("X-Test-Token", token)
+		handler(httptest.NewRecorder(), req)
+	}
+
+	openSession("abandoned")
+	now = now.Add(DEFAULT_DRAIN_SESSION_TTL / 2)
+	openSession("active")
+
+	if _, sessions := drainer.InFlight(); sessions != 2 {
+		t.Fatalf("Expected two open sessions. Got %d", sessions)
+	}
+
+	// Message of the open session keeps it open
+	now = now.Add(DEFAULT_DRAIN_SESSION_TTL / 2)
+	next := drainer.Track(TO2_62_GET_OVNEXTENTRY, func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest(http.MethodPost, "/fdo/101/msg/62", nil)
+	req.Header.Set("Authorization", "active")
+	next(httptest.NewRecorder(), req)
+
+	if _, sessions := drainer.InFlight(); sessions != 1 {
+		t.Fatalf("Expected abandoned session to expire. Got %d open sessions", sessions)
+	}
+
+	now = now.Add(DEFAULT_DRAIN_SESSION_TTL)
+	if !drainer.Drain(time.Second) {
+		t.Errorf("Expected drain to not wait for abandoned sessions")
+	}
+
+	// Message with unknown token does not open a session
+	next(httptest.NewRecorder(), req)
+	if _, sessions := drainer.InFlight(); sessions != 0 {
+		t.Errorf("Expected no open sessions. Got %d", sessions)
+	}
+}
+
+func TestDrainer_DrainStopsWaitingOnSessionTTL(t *testing.T) {
+	drainer := NewDrainer()
+	drainer.SetSessionTTL(50 * time.Millisecond)
+
+	handler := drainer.Track(TO1_30_HELLO_RV, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Authorization", "abandoned")
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/fdo/101/msg/30", nil))
+
+	start := time.Now()
+	if !drainer.Drain(10 * time.Second) {
+		t.Errorf("Expected drain to complete once abandoned session expired")
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected drain to stop waiting after session TTL. Took %s", time.Since(start))
+	}
+}
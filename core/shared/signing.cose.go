@@ -30,6 +30,7 @@ const (
 	CA_P256         CoseAlg = 1
 	CA_P384         CoseAlg = 2
 	CA_P521         CoseAlg = 3
+	CA_Ed25519      CoseAlg = 6
 )
 
 var CoseAlgToHash map[CoseAlg]crypto.Hash = map[CoseAlg]crypto.Hash{
@@ -76,7 +77,23 @@ func CoseKeyToX509(pubKey FdoPublicKey) ([]byte, error) {
 			rawPublicKey = cosePubKey.CrvOrN.([]byte)
 			algId = cosePubKey.Alg
 		case CoseOKP:
-			return nil, errors.New("unsupported COSE key type: OKP")
+			// Curve is CoseAlg for keys built locally, and integer for keys decoded from CBOR
+			var crv CoseAlg
+			switch crvVal := cosePubKey.CrvOrN.(type) {
+			case CoseAlg:
+				crv = crvVal
+			case uint64:
+				crv = CoseAlg(crvVal)
+			case int64:
+				crv = CoseAlg(crvVal)
+			}
+
+			if crv != CA_Ed25519 {
+				return nil, errors.New("unsupported COSE key type: OKP. Only Ed25519 curve is supported")
+			}
+
+			rawPublicKey = cosePubKey.XorE
+			algId = crv
 		default:
 			return nil, fmt.Errorf("unsupported COSE key type: %d", cosePubKey.Kty)
 		}
@@ -95,6 +112,9 @@ func CoseKeyToX509(pubKey FdoPublicKey) ([]byte, error) {
 	case CA_P521:
 		buff, _ := hex.DecodeString("30819b301006072a8648ce3d020106052b8104002303818600")
 		return append(buff, rawPublicKey...), nil
+	case CA_Ed25519:
+		buff, _ := hex.DecodeString("302a300506032b6570032100")
+		return append(buff, rawPublicKey...), nil
 	case CA_PKCS1_SHA256, CA_PKCS1_SHA384, CA_PKCS1_SHA512:
 		if len(rawPublicKey) < 512 { // 2080 key
 			pkcsHeader, _ := hex.DecodeString("30820122300d06092a864886f70d01010105000382010f003082010a0282010100")
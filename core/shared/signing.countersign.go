@@ -0,0 +1,105 @@
+package fdoshared
+
+import (
+	"errors"
+	"fmt"
+)
+
+const CoseContext_CounterSignature CoseContext = "CounterSignature"
+
+// Set once on startup from RV_COUNTERSIGN_TO1D
+var RvCountersignTo1d bool = false
+
+// CoseCounterSignature is COSE_Signature of the countersigner. Its unprotected header is always empty
+type CoseCounterSignature struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int]interface{}
+	Signature   []byte
+}
+
+// Countersignature must be computed over a sig_structure:
+// Sig_structure = [
+//   context : "CounterSignature",
+//   body_protected : protected header of the countersigned message,
+//   sign_protected : protected header of the countersignature,
+//   external_aad : bstr,
+//   payload : payload of the countersigned message
+// ]
+
+type CoseCounterSignatureStructure struct {
+	_             struct{} `cbor:",toarray"`
+	Context       CoseContext
+	BodyProtected []byte
+	SignProtected []byte
+	ExternalAAD   []byte
+	Payload       []byte
+}
+
+func newCounterSignaturePayload(coseSig CoseSignature, signProtected []byte) ([]byte, error) {
+	counterSigBytes, err := CborCust.Marshal(CoseCounterSignatureStructure{
+		Context:       CoseContext_CounterSignature,
+		BodyProtected: coseSig.Protected,
+		SignProtected: signProtected,
+		ExternalAAD:   []byte{},
+		Payload:       coseSig.Payload,
+	})
+	if err != nil {
+		return []byte{}, errors.New("Error marshaling cose signature structure for CounterSignature. " + err.Error())
+	}
+
+	return counterSigBytes, nil
+}
+
+// GenerateCoseCounterSignature countersigns signed message. The message signature is not covered, as in RFC 8152
+func GenerateCoseCounterSignature(coseSig CoseSignature, privateKeyInterface interface{}, sgType DeviceSgType) (*CoseCounterSignature, error) {
+	protectedBytes, _ := CborCust.Marshal(ProtectedHeader{
+		Alg: GetIntRef(int(sgType)),
+	})
+
+	counterSigPayloadBytes, err := newCounterSignaturePayload(coseSig, protectedBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signCoseStructure(counterSigPayloadBytes, privateKeyInterface, sgType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CoseCounterSignature{
+		Protected:   protectedBytes,
+		Unprotected: map[int]interface{}{},
+		Signature:   signature,
+	}, nil
+}
+
+// VerifyCoseCounterSignature verifies countersignature of the signed message with countersigner public key. Missing countersignature is an error
+func VerifyCoseCounterSignature(coseSig CoseSignature, publicKey FdoPublicKey) error {
+	counterSig := coseSig.Unprotected.CounterSignature
+	if counterSig == nil {
+		return errors.New("countersignature is missing")
+	}
+
+	var protected ProtectedHeader
+	err := CborCust.Unmarshal(counterSig.Protected, &protected)
+	if err != nil {
+		return fmt.Errorf("error decoding countersignature protected header. %s", err.Error())
+	}
+
+	if protected.Alg == nil {
+		return errors.New("countersignature protected header is missing alg")
+	}
+
+	counterSigPayloadBytes, err := newCounterSignaturePayload(coseSig, counterSig.Protected)
+	if err != nil {
+		return err
+	}
+
+	err = verifyCoseStructure(counterSigPayloadBytes, counterSig.Signature, publicKey)
+	if err != nil {
+		return errors.New("error verifying countersignature. " + err.Error())
+	}
+
+	return nil
+}
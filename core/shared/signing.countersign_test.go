@@ -0,0 +1,80 @@
+package fdoshared
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCoseCounterSignature(t *testing.T) {
+	for _, sgType := range []DeviceSgType{StSECP256R1, StSECP384R1, StRSA2048, StRSA3072} {
+		ownerKey, ownerPubKey, err := GenerateVoucherKeypair(sgType)
+		if err != nil {
+			t.Fatalf("%d: Failed to generate owner key. %s", sgType, err.Error())
+		}
+
+		rvKey, rvPubKey, err := GenerateVoucherKeypair(sgType)
+		if err != nil {
+			t.Fatalf("%d: Failed to generate RV key. %s", sgType, err.Error())
+		}
+
+		to1d, err := GenerateCoseSignature([]byte("to1d"), ProtectedHeader{}, UnprotectedHeader{}, ownerKey, sgType)
+		if err != nil {
+			t.Fatalf("%d: Failed to sign To1d. %s", sgType, err.Error())
+		}
+
+		unsignedBytes, _ := CborCust.Marshal(to1d)
+
+		err = VerifyCoseCounterSignature(*to1d, *rvPubKey)
+		if err == nil {
+			t.Errorf("%d: Expected missing countersignature to fail", sgType)
+		}
+
+		to1d.Unprotected.CounterSignature, err = GenerateCoseCounterSignature(*to1d, rvKey, sgType)
+		if err != nil {
+			t.Fatalf("%d: Failed to countersign To1d. %s", sgType, err.Error())
+		}
+
+		// Countersignature is carried in unprotected header, so message signature still verifies
+		counterSignedBytes, _ := CborCust.Marshal(to1d)
+		var decodedTo1d CoseSignature
+		err = CborCust.Unmarshal(counterSignedBytes, &decodedTo1d)
+		if err != nil {
+			t.Fatalf("%d: Failed to decode countersigned To1d. %s", sgType, err.Error())
+		}
+
+		if bytes.Equal(unsignedBytes, counterSignedBytes) {
+			t.Errorf("%d: Expected countersignature to be encoded", sgType)
+		}
+
+		err = VerifyCoseSignature(decodedTo1d, *ownerPubKey)
+		if err != nil {
+			t.Errorf("%d: Expected To1d signature to verify. %s", sgType, err.Error())
+		}
+
+		err = VerifyCoseCounterSignature(decodedTo1d, *rvPubKey)
+		if err != nil {
+			t.Errorf("%d: Expected countersignature to verify. %s", sgType, err.Error())
+		}
+
+		err = VerifyCoseCounterSignature(decodedTo1d, *ownerPubKey)
+		if err == nil {
+			t.Errorf("%d: Expected countersignature to fail with other key", sgType)
+		}
+
+		decodedTo1d.Payload = []byte("other")
+		err = VerifyCoseCounterSignature(decodedTo1d, *rvPubKey)
+		if err == nil {
+			t.Errorf("%d: Expected countersignature to cover the payload", sgType)
+		}
+
+		to1d.Unprotected.CounterSignature, err = Conf_InvalidCoseCounterSignature(*to1d, sgType)
+		if err != nil {
+			t.Fatalf("%d: Failed to generate invalid countersignature. %s", sgType, err.Error())
+		}
+
+		err = VerifyCoseCounterSignature(*to1d, *rvPubKey)
+		if err == nil {
+			t.Errorf("%d: Expected invalid countersignature to fail", sgType)
+		}
+	}
+}
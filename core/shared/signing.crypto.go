@@ -3,6 +3,7 @@ package fdoshared
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -61,6 +62,14 @@ func VerifyCertificateChain(chain []X509CertificateBytes) ([]*x509.Certificate,
 }
 
 func VerifyCoseSignatureWithCertificate(coseSig CoseSignature, pkType FdoPkType, certs []X509CertificateBytes) error {
+	if _, ok := PkToSgType[pkType]; !ok {
+		return fmt.Errorf("device public key type %d is not supported", pkType)
+	}
+
+	if len(certs) == 0 {
+		return errors.New("device certificate chain is empty")
+	}
+
 	newPubKey := FdoPublicKey{
 		PkType: pkType,
 		PkEnc:  X5CHAIN,
@@ -70,6 +79,83 @@ func VerifyCoseSignatureWithCertificate(coseSig CoseSignature, pkType FdoPkType,
 	return VerifyCoseSignature(coseSig, newPubKey)
 }
 
+// VerifyCoseSignatureAlg checks that alg of COSE protected header is the one of the device leaf certificate key, e.g. no ES384 under EC256 key
+func VerifyCoseSignatureAlg(coseSig CoseSignature, certs []X509CertificateBytes) error {
+	var protected ProtectedHeader
+	err := CborCust.Unmarshal(coseSig.Protected, &protected)
+	if err != nil {
+		return errors.New("error decoding COSE protected header. " + err.Error())
+	}
+
+	if protected.Alg == nil {
+		return errors.New("COSE protected header is missing alg")
+	}
+
+	leafSigInfo, err := GetDeviceSigInfo(certs, SigInfo{})
+	if err != nil {
+		return err
+	}
+
+	receivedAlg := IanaCoseAlg(*protected.Alg)
+	expectedAlg := IanaCoseAlg(leafSigInfo.SgType)
+	if pssAlg, ok := rsaPssCoseAlgs[expectedAlg]; ok && receivedAlg == pssAlg {
+		return nil
+	}
+
+	if receivedAlg != expectedAlg {
+		return fmt.Errorf("COSE alg %s does not match device key type. Expected %s", receivedAlg, expectedAlg)
+	}
+
+	return nil
+}
+
+// Returns SigInfo matching the device leaf certificate key. Info is taken from eASigInfo
+func GetDeviceSigInfo(devCertChain []X509CertificateBytes, eASigInfo SigInfo) (*SigInfo, error) {
+	if len(devCertChain) == 0 {
+		return nil, errors.New("device certificate chain is empty")
+	}
+
+	leafCert, err := x509.ParseCertificate(devCertChain[0])
+	if err != nil {
+		return nil, errors.New("error decoding device leaf certificate. " + err.Error())
+	}
+
+	var sgType DeviceSgType
+	switch pubKey := leafCert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		switch pubKey.Curve.Params().BitSize {
+		case 256:
+			sgType = StSECP256R1
+		case 384:
+			sgType = StSECP384R1
+		default:
+			return nil, fmt.Errorf("unsupported device curve %s", pubKey.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		if !ExperimentalEd25519 {
+			return nil, ErrEd25519Disabled
+		}
+		sgType = StED25519
+	case *rsa.PublicKey:
+		// Same sgType for PKCS1 and PSS leaf certificates. Signature padding is declared by COSE alg
+		switch pubKey.N.BitLen() {
+		case 2048:
+			sgType = StRSA2048
+		case 3072:
+			sgType = StRSA3072
+		default:
+			return nil, fmt.Errorf("unsupported device RSA key size %d", pubKey.N.BitLen())
+		}
+	default:
+		return nil, errors.New("device leaf certificate key is not ECDSA, RSA or Ed25519")
+	}
+
+	return &SigInfo{
+		SgType: sgType,
+		Info:   eASigInfo.Info,
+	}, nil
+}
+
 func VerifySignature(payload []byte, signature []byte, publicKeyInst interface{}, pkType FdoPkType) error {
 	switch pkType {
 	case SECP256R1:
@@ -118,6 +204,26 @@ func VerifySignature(payload []byte, signature []byte, publicKeyInst interface{}
 		} else {
 			return nil
 		}
+	case ED25519:
+		if !ExperimentalEd25519 {
+			return ErrEd25519Disabled
+		}
+
+		if len(signature) != ED25519_SIG_LEN {
+			return errors.New("for EdDSA, signature must be 64 bytes long")
+		}
+
+		pubKeyCasted, ok := publicKeyInst.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("error verifying ED25519 cose signature. Could not cast pubKey instance to Ed25519 PubKey")
+		}
+
+		// EdDSA signs the message itself, without prehashing
+		if !ed25519.Verify(pubKeyCasted, payload, signature) {
+			return errors.New("failed to verify signature")
+		} else {
+			return nil
+		}
 	case RSAPKCS, RSA2048RESTR:
 		rsaPubKeyCasted, ok := publicKeyInst.(*rsa.PublicKey)
 		if !ok {
@@ -142,18 +248,65 @@ func VerifySignature(payload []byte, signature []byte, publicKeyInst interface{}
 
 		return rsa.VerifyPKCS1v15(rsaPubKeyCasted, hashingAlg, payloadHash, signature)
 	case RSAPSS:
-		return errors.New("RSAPSS is not currently implemented")
+		rsaPubKeyCasted, ok := publicKeyInst.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("error verifying RSAPSS cose signature. Could not cast pubKey instance to RSA PubKey")
+		}
+
+		var hashingAlg crypto.Hash
+		var payloadHash []byte
+		switch rsaPubKeyCasted.N.BitLen() {
+		case 2048:
+			hashingAlg = crypto.SHA256
+			sPayloadHash := sha256.Sum256(payload)
+			payloadHash = sPayloadHash[:]
+		case 3072:
+			hashingAlg = crypto.SHA384
+			sPayloadHash := sha512.Sum384(payload)
+			payloadHash = sPayloadHash[:]
+		default:
+			return fmt.Errorf("%d is an unsupported public key length for RSAPSS", rsaPubKeyCasted.N.BitLen())
+		}
+
+		return rsa.VerifyPSS(rsaPubKeyCasted, hashingAlg, payloadHash, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
 	default:
 		return fmt.Errorf("PublicKey type %d is not supported", pkType)
 	}
 }
 
+// x5ChainFromPkBody returns X5CHAIN certificates of locally built keys, and of keys decoded from CBOR
+func x5ChainFromPkBody(pkBody interface{}) ([]X509CertificateBytes, error) {
+	switch body := pkBody.(type) {
+	case []X509CertificateBytes:
+		return body, nil
+	case []interface{}:
+		var certs []X509CertificateBytes
+		for _, cert := range body {
+			certBytes, ok := cert.([]byte)
+			if !ok {
+				return nil, errors.New("failed to cast X5CHAIN certificate to []byte")
+			}
+
+			certs = append(certs, certBytes)
+		}
+
+		return certs, nil
+	default:
+		return nil, errors.New("failed to cast pubkey PkBody to []X509CertificateBytes")
+	}
+}
+
 func VerifyCoseSignature(coseSig CoseSignature, publicKey FdoPublicKey) error {
 	coseSigPayloadBytes, err := NewSig1Payload(coseSig.Protected, coseSig.Payload)
 	if err != nil {
 		return err
 	}
 
+	return verifyCoseStructure(coseSigPayloadBytes, coseSig.Signature, publicKey)
+}
+
+// verifyCoseStructure verifies signature of encoded COSE Sig_structure
+func verifyCoseStructure(coseSigPayloadBytes []byte, signature []byte, publicKey FdoPublicKey) error {
 	switch publicKey.PkEnc {
 	case Crypto:
 		return errors.New("ePID signatures are not currently supported")
@@ -168,11 +321,11 @@ func VerifyCoseSignature(coseSig CoseSignature, publicKey FdoPublicKey) error {
 			return errors.New("error parsing PKIX X509 Public Key. " + err.Error())
 		}
 
-		return VerifySignature(coseSigPayloadBytes, coseSig.Signature, pubKeyInst, publicKey.PkType)
+		return VerifySignature(coseSigPayloadBytes, signature, pubKeyInst, publicKey.PkType)
 	case X5CHAIN:
-		decCertBytes, ok := publicKey.PkBody.([]X509CertificateBytes)
-		if !ok {
-			return errors.New("failed to cast pubkey PkBody to []X509CertificateBytes")
+		decCertBytes, err := x5ChainFromPkBody(publicKey.PkBody)
+		if err != nil {
+			return err
 		}
 
 		successChain, err := VerifyCertificateChain(decCertBytes)
@@ -182,7 +335,7 @@ func VerifyCoseSignature(coseSig CoseSignature, publicKey FdoPublicKey) error {
 
 		leafCert := successChain[0]
 
-		return VerifySignature(coseSigPayloadBytes, coseSig.Signature, leafCert.PublicKey, publicKey.PkType)
+		return VerifySignature(coseSigPayloadBytes, signature, leafCert.PublicKey, publicKey.PkType)
 
 	case COSEKEY:
 		publicKeyX509, err := CoseKeyToX509(publicKey)
@@ -195,7 +348,7 @@ func VerifyCoseSignature(coseSig CoseSignature, publicKey FdoPublicKey) error {
 			return errors.New("error parsing PKIX X509 Public Key. " + err.Error())
 		}
 
-		return VerifySignature(coseSigPayloadBytes, coseSig.Signature, pubKeyInst, publicKey.PkType)
+		return VerifySignature(coseSigPayloadBytes, signature, pubKeyInst, publicKey.PkType)
 
 	default:
 		return fmt.Errorf("PublicKey encoding %d is not supported", publicKey.PkEnc)
@@ -208,7 +361,7 @@ func ExtractPrivateKey(privateKeyDer []byte) (interface{}, error) {
 	}
 	if key, err := x509.ParsePKCS8PrivateKey(privateKeyDer); err == nil {
 		switch key := key.(type) {
-		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
 			return key, nil
 		default:
 			return nil, fmt.Errorf("found unknown private key type in PKCS#8 wrapping")
@@ -259,6 +412,21 @@ func GenerateCoseSignature(payload []byte, protected ProtectedHeader, unprotecte
 		return nil, err
 	}
 
+	signature, err := signCoseStructure(coseSigPayloadBytes, privateKeyInterface, sgType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CoseSignature{
+		Protected:   protectedBytes,
+		Unprotected: unprotected,
+		Payload:     payload,
+		Signature:   signature,
+	}, nil
+}
+
+// signCoseStructure signs encoded COSE Sig_structure
+func signCoseStructure(coseSigPayloadBytes []byte, privateKeyInterface interface{}, sgType DeviceSgType) ([]byte, error) {
 	var signature []byte
 
 	switch sgType {
@@ -319,6 +487,13 @@ func GenerateCoseSignature(payload []byte, protected ProtectedHeader, unprotecte
 		}
 
 		signature = append(Rb, Sb...)
+	case StED25519:
+		privKeyCasted, ok := privateKeyInterface.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("error generating EdDSA cose signature. Could not cast privKey instance to Ed25519 PrivateKey")
+		}
+
+		signature = ed25519.Sign(privKeyCasted, coseSigPayloadBytes)
 	case StRSA3072:
 		payloadHash := sha512.Sum384(coseSigPayloadBytes)
 
@@ -353,10 +528,5 @@ func GenerateCoseSignature(payload []byte, protected ProtectedHeader, unprotecte
 		return nil, fmt.Errorf("alg %d is not supported", sgType)
 	}
 
-	return &CoseSignature{
-		Protected:   protectedBytes,
-		Unprotected: unprotected,
-		Payload:     payload,
-		Signature:   signature,
-	}, nil
+	return signature, nil
 }
@@ -1,7 +1,18 @@
 package fdoshared
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGenerateCoseSignature_ECDSACoefficients(t *testing.T) {
@@ -70,3 +81,369 @@ func TestGenerateCoseSignature(t *testing.T) {
 		t.Fatalf("failed to verify COSE signature: %v", err)
 	}
 }
+
+func TestGenerateCoseSignature_Ed25519(t *testing.T) {
+	SetExperimentalEd25519(true)
+	defer SetExperimentalEd25519(false)
+
+	payload := []byte("test payload")
+
+	privKey, pubKey, err := GenerateVoucherKeypair(StED25519)
+	if err != nil {
+		t.Fatalf("EdDSA: failed to generate private key: %v", err)
+	}
+
+	coseSig, err := GenerateCoseSignature(payload, ProtectedHeader{}, UnprotectedHeader{}, privKey, StED25519)
+	if err != nil {
+		t.Fatalf("EdDSA: failed to generate COSE signature: %v", err)
+	}
+
+	if len(coseSig.Signature) != ED25519_SIG_LEN {
+		t.Fatalf("EdDSA: Invalid signature length %d", len(coseSig.Signature))
+	}
+
+	err = VerifyCoseSignature(*coseSig, *pubKey)
+	if err != nil {
+		t.Fatalf("failed to verify COSE signature: %v", err)
+	}
+
+	// Same key as COSE OKP key
+	pkixKey, err := x509.ParsePKIXPublicKey(pubKey.PkBody.([]byte))
+	if err != nil {
+		t.Fatalf("failed to decode public key: %v", err)
+	}
+
+	coseKey := FdoPublicKey{
+		PkType: ED25519,
+		PkEnc:  COSEKEY,
+		PkBody: CosePublicKey{Kty: CoseOKP, Alg: CoseAlg(StED25519), CrvOrN: CA_Ed25519, XorE: pkixKey.(ed25519.PublicKey)},
+	}
+
+	err = VerifyCoseSignature(*coseSig, coseKey)
+	if err != nil {
+		t.Errorf("failed to verify COSE signature with OKP key: %v", err)
+	}
+
+	tamperedSig := *coseSig
+	tamperedSig.Signature = append([]byte{}, coseSig.Signature...)
+	tamperedSig.Signature[0] ^= 0xff
+	if VerifyCoseSignature(tamperedSig, *pubKey) == nil {
+		t.Errorf("Expected tampered EdDSA signature to fail")
+	}
+
+	// Ed25519 key presented as ECDSA
+	ecKey := *pubKey
+	ecKey.PkType = SECP256R1
+	if VerifyCoseSignature(*coseSig, ecKey) == nil {
+		t.Errorf("Expected Ed25519 key with SECP256R1 type to fail")
+	}
+}
+
+func TestVerifyCoseSignatureWithCertificate_Ed25519(t *testing.T) {
+	SetExperimentalEd25519(true)
+	defer SetExperimentalEd25519(false)
+
+	credential, err := NewWawDeviceCredential(StED25519)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 credential. %s", err.Error())
+	}
+
+	ebSigInfo, err := GetDeviceSigInfo(credential.DCCertificateChain, credential.DCSigInfo)
+	if err != nil || ebSigInfo.SgType != StED25519 {
+		t.Fatalf("Expected Ed25519 EBSigInfo. Got %v, %v", ebSigInfo, err)
+	}
+
+	privKey, err := ExtractPrivateKey(credential.DCPrivateKeyDer)
+	if err != nil {
+		t.Fatalf("Failed to decode Ed25519 device key. %s", err.Error())
+	}
+
+	coseSig, err := GenerateCoseSignature([]byte("eat"), ProtectedHeader{}, UnprotectedHeader{}, privKey, StED25519)
+	if err != nil {
+		t.Fatalf("Failed to sign with device key. %s", err.Error())
+	}
+
+	// Leaf certificate key. Chain check depends on x509sha1 GODEBUG, see isCertValidityError
+	leafCert, _ := x509.ParseCertificate(credential.DCCertificateChain[0])
+	leafKeyPkix, _ := x509.MarshalPKIXPublicKey(leafCert.PublicKey)
+	err = VerifyCoseSignature(*coseSig, FdoPublicKey{PkType: SgTypeToFdoPkType[StED25519], PkEnc: X509, PkBody: leafKeyPkix})
+	if err != nil {
+		t.Errorf("Expected signature to verify with Ed25519 leaf certificate key. %s", err.Error())
+	}
+
+	err = VerifyCoseSignatureWithCertificate(*coseSig, FdoPkType(99), credential.DCCertificateChain)
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("Expected unsupported key type error. Got %v", err)
+	}
+
+	err = VerifyCoseSignatureWithCertificate(*coseSig, ED25519, []X509CertificateBytes{})
+	if err == nil {
+		t.Errorf("Expected empty certificate chain to fail")
+	}
+}
+
+func TestVerifyCoseSignatureAlg(t *testing.T) {
+	credential, err := NewWawDeviceCredential(StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate credential. %s", err.Error())
+	}
+
+	privKey, err := ExtractPrivateKey(credential.DCPrivateKeyDer)
+	if err != nil {
+		t.Fatalf("Failed to decode device key. %s", err.Error())
+	}
+
+	coseSig, err := GenerateCoseSignature([]byte("eat"), ProtectedHeader{}, UnprotectedHeader{}, privKey, StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to sign with device key. %s", err.Error())
+	}
+
+	err = VerifyCoseSignatureAlg(*coseSig, credential.DCCertificateChain)
+	if err != nil {
+		t.Errorf("Expected ES256 alg to match EC256 key. %s", err.Error())
+	}
+
+	// ES384 declared under EC256 key
+	mismatchedSig := *coseSig
+	mismatchedSig.Protected, _ = CborCust.Marshal(ProtectedHeader{Alg: GetIntRef(int(StSECP384R1))})
+	err = VerifyCoseSignatureAlg(mismatchedSig, credential.DCCertificateChain)
+	if err == nil || !strings.Contains(err.Error(), "ES384 (-35)") || !strings.Contains(err.Error(), "Expected ES256 (-7)") {
+		t.Errorf("Expected ES384 alg mismatch error. Got %v", err)
+	}
+
+	noAlgSig := *coseSig
+	noAlgSig.Protected, _ = CborCust.Marshal(ProtectedHeader{})
+	err = VerifyCoseSignatureAlg(noAlgSig, credential.DCCertificateChain)
+	if err == nil || !strings.Contains(err.Error(), "missing alg") {
+		t.Errorf("Expected missing alg error. Got %v", err)
+	}
+}
+
+func TestGetDeviceSigInfo(t *testing.T) {
+	for _, sgType := range DeviceSgTypeList {
+		credential, err := NewWawDeviceCredential(sgType)
+		if err != nil {
+			t.Fatalf("Failed to generate %d credential. %s", sgType, err.Error())
+		}
+
+		// Device claims a different algorithm than its key
+		claimedSigInfo := SigInfo{SgType: Conf_NewRandomSgTypeExcept(sgType)}
+
+		ebSigInfo, err := GetDeviceSigInfo(credential.DCCertificateChain, claimedSigInfo)
+		if err != nil {
+			t.Fatalf("Unexpected error for %d. %s", sgType, err.Error())
+		}
+
+		if ebSigInfo.SgType != sgType {
+			t.Errorf("Expected EBSigInfo sgType %d. Got %d", sgType, ebSigInfo.SgType)
+		}
+	}
+
+	_, err := GetDeviceSigInfo([]X509CertificateBytes{}, SigInfo{SgType: StSECP384R1})
+	if err == nil {
+		t.Errorf("Expected empty chain to fail")
+	}
+}
+
+func newTestRSALeafCert(t *testing.T, bits int) (*rsa.PrivateKey, []X509CertificateBytes) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA%d key. %s", bits, err.Error())
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "RSA Device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &rsaKey.PublicKey, rsaKey)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA%d certificate. %s", bits, err.Error())
+	}
+
+	return rsaKey, []X509CertificateBytes{certBytes}
+}
+
+func TestGetDeviceSigInfo_RSA(t *testing.T) {
+	for bits, expectedSgType := range map[int]DeviceSgType{2048: StRSA2048, 3072: StRSA3072} {
+		_, certs := newTestRSALeafCert(t, bits)
+
+		ebSigInfo, err := GetDeviceSigInfo(certs, SigInfo{SgType: StSECP256R1})
+		if err != nil {
+			t.Fatalf("Unexpected error for RSA%d. %s", bits, err.Error())
+		}
+
+		if ebSigInfo.SgType != expectedSgType {
+			t.Errorf("Expected RSA%d EBSigInfo sgType %d. Got %d", bits, expectedSgType, ebSigInfo.SgType)
+		}
+	}
+}
+
+func TestVerifyCoseSignatureAlg_RSA(t *testing.T) {
+	rsaKey, certs := newTestRSALeafCert(t, 2048)
+
+	coseSig, err := GenerateCoseSignature([]byte("eat"), ProtectedHeader{}, UnprotectedHeader{}, rsaKey, StRSA2048)
+	if err != nil {
+		t.Fatalf("Failed to sign with RSA key. %s", err.Error())
+	}
+
+	err = VerifyCoseSignatureAlg(*coseSig, certs)
+	if err != nil {
+		t.Errorf("Expected RS256 alg to match RSA2048 key. %s", err.Error())
+	}
+
+	sig1Payload, _ := NewSig1Payload(coseSig.Protected, coseSig.Payload)
+	err = VerifySignature(sig1Payload, coseSig.Signature, &rsaKey.PublicKey, RSA2048RESTR)
+	if err != nil {
+		t.Errorf("Expected RS256 signature to verify. %s", err.Error())
+	}
+
+	pssSig := *coseSig
+	pssSig.Protected, _ = CborCust.Marshal(ProtectedHeader{Alg: GetIntRef(int(IANA_PS256))})
+	err = VerifyCoseSignatureAlg(pssSig, certs)
+	if err != nil {
+		t.Errorf("Expected PS256 alg to match RSA2048 key. %s", err.Error())
+	}
+
+	for _, mismatchedAlg := range []IanaCoseAlg{IANA_RS384, IANA_PS384, IANA_ES256} {
+		mismatchedSig := *coseSig
+		mismatchedSig.Protected, _ = CborCust.Marshal(ProtectedHeader{Alg: GetIntRef(int(mismatchedAlg))})
+		err = VerifyCoseSignatureAlg(mismatchedSig, certs)
+		if err == nil || !strings.Contains(err.Error(), "Expected RS256 (-257)") {
+			t.Errorf("Expected %s alg mismatch error. Got %v", mismatchedAlg, err)
+		}
+	}
+
+	payload := []byte("pss payload")
+	payloadHash := sha256.Sum256(payload)
+	pssSignature, err := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, payloadHash[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	if err != nil {
+		t.Fatalf("Failed to sign with RSA PSS. %s", err.Error())
+	}
+
+	err = VerifySignature(payload, pssSignature, &rsaKey.PublicKey, RSAPSS)
+	if err != nil {
+		t.Errorf("Expected PSS signature to verify. %s", err.Error())
+	}
+
+	err = VerifySignature(payload, pssSignature, &rsaKey.PublicKey, RSAPKCS)
+	if err == nil {
+		t.Errorf("Expected PSS signature to fail PKCS1 verification")
+	}
+}
+
+// Only validity period errors. Test root is SHA1 signed, so full chain result depends on x509sha1 GODEBUG
+func isCertValidityError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "expired or is not yet valid")
+}
+
+func TestDeviceCertValidity(t *testing.T) {
+	credential, err := NewWawDeviceCredential(StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate credential. %s", err.Error())
+	}
+
+	_, err = VerifyCertificateChain(credential.DCCertificateChain)
+	if isCertValidityError(err) {
+		t.Errorf("Expected default certificate to be valid. %s", err.Error())
+	}
+
+	for name, validity := range map[string]CertValidity{
+		"expired":       ExpiredCertValidity(),
+		"not yet valid": NotYetValidCertValidity(),
+	} {
+		invalidCredential, err := NewWawDeviceCredentialWithValidity(StSECP384R1, validity)
+		if err != nil {
+			t.Fatalf("Failed to generate %s credential. %s", name, err.Error())
+		}
+
+		_, err = VerifyCertificateChain(invalidCredential.DCCertificateChain)
+		if !isCertValidityError(err) {
+			t.Errorf("Expected %s certificate to fail verification", name)
+		}
+
+		// Seeded credential reissued for the test, as done for DO test vouchers
+		reissuedCredential := *credential
+		err = reissuedCredential.Conf_ReissueLeafCertificate(validity)
+		if err != nil {
+			t.Fatalf("Failed to reissue %s certificate. %s", name, err.Error())
+		}
+
+		_, err = VerifyCertificateChain(reissuedCredential.DCCertificateChain)
+		if !isCertValidityError(err) {
+			t.Errorf("Expected reissued %s certificate to fail verification", name)
+		}
+
+		if bytes.Equal(reissuedCredential.DCCertificateChainHash.Hash, credential.DCCertificateChainHash.Hash) {
+			t.Errorf("Expected reissued %s certificate chain hash to change", name)
+		}
+	}
+
+	_, err = VerifyCertificateChain(credential.DCCertificateChain)
+	if isCertValidityError(err) {
+		t.Errorf("Expected reissue to leave original credential intact. %s", err.Error())
+	}
+
+	_, err = NewWawDeviceCredentialWithValidity(StSECP256R1, CertValidity{NotBefore: time.Now(), NotAfter: time.Now().AddDate(-1, 0, 0)})
+	if err == nil {
+		t.Errorf("Expected notAfter before notBefore to fail")
+	}
+}
+
+func TestConf_ResignCoseSignature(t *testing.T) {
+	for _, sgType := range []DeviceSgType{StSECP256R1, StRSA2048} {
+		ownerPrivKey, ownerPubKey, err := GenerateVoucherKeypair(sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate owner key: %v", sgType, err)
+		}
+
+		to1d, err := GenerateCoseSignature([]byte("to1d"), ProtectedHeader{}, UnprotectedHeader{}, ownerPrivKey, sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to sign to1d: %v", sgType, err)
+		}
+
+		mismatchedTo1d, err := Conf_ResignCoseSignature(*to1d)
+		if err != nil {
+			t.Fatalf("%d: failed to re-sign to1d: %v", sgType, err)
+		}
+
+		if !bytes.Equal(mismatchedTo1d.Payload, to1d.Payload) || !bytes.Equal(mismatchedTo1d.Protected, to1d.Protected) {
+			t.Errorf("%d: expected re-signed to1d to keep payload and protected header", sgType)
+		}
+
+		err = VerifyCoseSignature(*mismatchedTo1d, *ownerPubKey)
+		if err == nil {
+			t.Errorf("%d: expected re-signed to1d to fail verification with owner key", sgType)
+		}
+	}
+}
+
+func TestExperimentalEd25519_Disabled(t *testing.T) {
+	if _, _, err := GenerateVoucherKeypair(StED25519); err == nil {
+		t.Errorf("Expected Ed25519 keypair generation to fail without EXPERIMENTAL_ED25519")
+	}
+
+	if _, ok := PkToSgType[ED25519]; ok {
+		t.Errorf("Expected pkType %d not to be supported by default", ED25519)
+	}
+
+	if _, _, err := SelectKexCipherSuite(SigInfo{SgType: StED25519}); err == nil {
+		t.Errorf("Expected no KEX for sgType %d by default", StED25519)
+	}
+
+	SetExperimentalEd25519(true)
+	defer SetExperimentalEd25519(false)
+
+	if _, ok := PkToSgType[ED25519]; !ok {
+		t.Errorf("Expected pkType %d to be supported with EXPERIMENTAL_ED25519", ED25519)
+	}
+
+	for _, pkType := range FdoPkType_List {
+		if pkType == ED25519 {
+			t.Errorf("Expected pkType %d to stay out of random selection", ED25519)
+		}
+	}
+}
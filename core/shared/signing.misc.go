@@ -12,6 +12,11 @@ type UnprotectedHeader struct {
 	EATMAROEPrefix  *[]byte       `cbor:"-258,keyasint,omitempty"`
 	EUPHNonce       *FdoNonce     `cbor:"-259,keyasint,omitempty"`
 	AESIV           *[]byte       `cbor:"5,keyasint,omitempty"`
+	// COSE countersignature, RFC 8152 section 4.5. RV countersigns To1d when configured
+	CounterSignature *CoseCounterSignature `cbor:"7,keyasint,omitempty"`
+
+	// Conformance testing. Private use label, only used to inflate message size
+	ConfPadding *[]byte `cbor:"-65537,keyasint,omitempty"`
 }
 
 type ProtectedHeader struct {
@@ -86,6 +91,7 @@ const (
 	RSAPSS       FdoPkType = 6  // RSA key, PSS
 	SECP256R1    FdoPkType = 10 // ECDSA secp256r1 = NIST-P-256 = prime256v1
 	SECP384R1    FdoPkType = 11 // ECDSA secp384r1 = NIST-P-384
+	ED25519      FdoPkType = 12 // EdDSA Ed25519. Not assigned by FDO 1.1, only accepted with EXPERIMENTAL_ED25519, see SetExperimentalEd25519
 )
 
 var FdoPkType_List []FdoPkType = []FdoPkType{
@@ -106,6 +112,7 @@ var SgTypeToFdoPkType = map[DeviceSgType]FdoPkType{
 const (
 	SECP256R1_SIG_LEN int = 64
 	SECP384R1_SIG_LEN int = 96
+	ED25519_SIG_LEN   int = 64
 )
 
 type FdoPkEnc uint8
@@ -156,15 +163,43 @@ const (
 	// https://www.iana.org/assignments/cose/cose.xhtml#algorithms
 	IANA_ES256 IanaCoseAlg = -7
 	IANA_ES384 IanaCoseAlg = -35
+	IANA_EdDSA IanaCoseAlg = -8
 	IANA_RS256 IanaCoseAlg = -257
 	IANA_RS384 IanaCoseAlg = -258
+	IANA_PS256 IanaCoseAlg = -37
+	IANA_PS384 IanaCoseAlg = -38
 )
 
+var IanaCoseAlgNames = map[IanaCoseAlg]string{
+	IANA_ES256: "ES256",
+	IANA_ES384: "ES384",
+	IANA_EdDSA: "EdDSA",
+	IANA_RS256: "RS256",
+	IANA_RS384: "RS384",
+	IANA_PS256: "PS256",
+	IANA_PS384: "PS384",
+}
+
+// RSA keys sign with PKCS1 v1.5 or PSS. Both are accepted for the key size
+var rsaPssCoseAlgs = map[IanaCoseAlg]IanaCoseAlg{
+	IANA_RS256: IANA_PS256,
+	IANA_RS384: IANA_PS384,
+}
+
+func (h IanaCoseAlg) String() string {
+	if name, ok := IanaCoseAlgNames[h]; ok {
+		return fmt.Sprintf("%s (%d)", name, int(h))
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(h))
+}
+
 type DeviceSgType int
 
 const (
 	StSECP256R1 DeviceSgType = -7
 	StSECP384R1 DeviceSgType = -35
+	StED25519   DeviceSgType = -8
 	StRSA2048   DeviceSgType = -257
 	StRSA3072   DeviceSgType = -258
 	StEPID10    DeviceSgType = 90
@@ -191,6 +226,7 @@ var DeviceSgTypeList []DeviceSgType = []DeviceSgType{
 var SgType_OwnerToDeviceAttestation = map[DeviceSgType]DeviceSgType{
 	StSECP256R1: StSECP256R1,
 	StSECP384R1: StSECP384R1,
+	StED25519:   StED25519,
 	StRSA2048:   StSECP256R1,
 	StRSA3072:   StSECP384R1,
 	StEPID10:    StEPID10,
@@ -226,6 +262,11 @@ func GetDeviceSgType(pkType FdoPkType, hashType HashType) (DeviceSgType, error)
 		return StSECP256R1, nil
 	case SECP384R1:
 		return StSECP384R1, nil
+	case ED25519:
+		if !ExperimentalEd25519 {
+			return 0, ErrEd25519Disabled
+		}
+		return StED25519, nil
 	case RSA2048RESTR, RSAPKCS, RSAPSS:
 		if hashType == HASH_SHA256 {
 			return StRSA2048, nil
@@ -274,3 +315,30 @@ var PkToSgType = map[FdoPkType]DeviceSgType{
 	RSA2048RESTR: StRSA2048,
 	RSAPKCS:      StRSA3072,
 }
+
+// Set once on startup from EXPERIMENTAL_ED25519
+var ExperimentalEd25519 bool = false
+
+var ErrEd25519Disabled = errors.New("Ed25519 is not assigned by FDO 1.1. Enable it with EXPERIMENTAL_ED25519=true")
+
+// SetExperimentalEd25519 adds Ed25519, pkType 12 and sgType -8, to the pkType and sgType lookups, or removes it. It stays out of random algorithm selection either way
+func SetExperimentalEd25519(enabled bool) {
+	ExperimentalEd25519 = enabled
+
+	if !enabled {
+		delete(SgTypeToFdoPkType, StED25519)
+		delete(SgTypeInfoMap, StED25519)
+		delete(PkToSgType, ED25519)
+		delete(SgTypeToKexCipherSuite, StED25519)
+		return
+	}
+
+	SgTypeToFdoPkType[StED25519] = ED25519
+	SgTypeInfoMap[StED25519] = SgTypeInfo{
+		PkType:   ED25519,
+		HashType: HASH_SHA256,
+		HmacType: HASH_HMAC_SHA256,
+	}
+	PkToSgType[ED25519] = StED25519
+	SgTypeToKexCipherSuite[StED25519] = KexCipherSuite{KEX_ECDH256, CIPHER_A128GCM}
+}
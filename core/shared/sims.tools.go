@@ -70,6 +70,30 @@ func GetDeviceOSSims() []ServiceInfoKV {
 	}
 }
 
+// Splits service info into fragments of at most maxFragmentSize encoded bytes. A KV that does not fit is sent on its own
+func FragmentServiceInfo(sims []ServiceInfoKV, maxFragmentSize int) [][]ServiceInfoKV {
+	var fragments [][]ServiceInfoKV
+	var current []ServiceInfoKV
+
+	for _, sim := range sims {
+		candidate := append(append([]ServiceInfoKV{}, current...), sim)
+		candidateBytes, _ := CborCust.Marshal(candidate)
+
+		if len(current) > 0 && len(candidateBytes) > maxFragmentSize {
+			fragments = append(fragments, current)
+			candidate = []ServiceInfoKV{sim}
+		}
+
+		current = candidate
+	}
+
+	if len(current) > 0 {
+		fragments = append(fragments, current)
+	}
+
+	return fragments
+}
+
 type RESULT_SIMS struct {
 	_                     struct{} `cbor:",toarray"`
 	SIM_DEVMOD_ACTIVE     *bool
@@ -198,6 +222,85 @@ func DecodeSims(sims []ServiceInfoKV) (*RESULT_SIMS, error) {
 	return &result, nil
 }
 
+// Expected CBOR types of mandatory devmod keys
+var devmodMandatoryTypes map[SIM_ID]string = map[SIM_ID]string{
+	SIM_DEVMOD_ACTIVE:     "bool",
+	SIM_DEVMOD_OS:         "tstr",
+	SIM_DEVMOD_ARCH:       "tstr",
+	SIM_DEVMOD_VERSION:    "tstr",
+	SIM_DEVMOD_DEVICE:     "tstr",
+	SIM_DEVMOD_SEP:        "tstr",
+	SIM_DEVMOD_BIN:        "tstr",
+	SIM_DEVMOD_NUMMODULES: "uint",
+	SIM_DEVMOD_MODULES:    "[uint, uint, tstr...]",
+}
+
+// ValidateDevmod checks that all mandatory devmod keys are present with correct types. Returns one error per missing or malformed key
+func ValidateDevmod(sims []ServiceInfoKV) []error {
+	errs := []error{}
+
+	deviceSims := SIMS(sims)
+	deviceSimIds := deviceSims.GetSimIDs()
+	for _, simId := range MANDATORY_SIMS {
+		if !deviceSimIds.Contains(simId) {
+			errs = append(errs, fmt.Errorf("missing mandatory %s", simId))
+		}
+	}
+
+	for _, sim := range sims {
+		expectedType, ok := devmodMandatoryTypes[sim.ServiceInfoKey]
+		if !ok {
+			continue
+		}
+
+		var simVal interface{}
+		err := cbor.Unmarshal(sim.ServiceInfoVal, &simVal)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s is not valid CBOR. %s", sim.ServiceInfoKey, err.Error()))
+			continue
+		}
+
+		if !devmodIsExpectedType(sim.ServiceInfoKey, simVal) {
+			errs = append(errs, fmt.Errorf("%s must be %s. Got %T", sim.ServiceInfoKey, expectedType, simVal))
+		}
+	}
+
+	return errs
+}
+
+func devmodIsExpectedType(simId SIM_ID, simVal interface{}) bool {
+	switch devmodMandatoryTypes[simId] {
+	case "bool":
+		_, ok := simVal.(bool)
+		return ok
+	case "tstr":
+		_, ok := simVal.(string)
+		return ok
+	case "uint":
+		_, ok := simVal.(uint64)
+		return ok
+	default:
+		modules, ok := simVal.([]interface{})
+		if !ok || len(modules) < 3 {
+			return false
+		}
+
+		for i, item := range modules {
+			if i < 2 {
+				_, ok = item.(uint64)
+			} else {
+				_, ok = item.(string)
+			}
+
+			if !ok {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
 func UintToCborBytes(val uint) []byte {
 	result, _ := cbor.Marshal(val)
 	return result
@@ -208,6 +311,11 @@ func StringToCborBytes(val string) []byte {
 	return result
 }
 
+func BytesToCborBytes(val []byte) []byte {
+	result, _ := cbor.Marshal(val)
+	return result
+}
+
 func SimsListToBytes(sims SIM_IDS) []byte {
 	var resultList []interface{} = []interface{}{
 		1,
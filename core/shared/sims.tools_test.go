@@ -0,0 +1,86 @@
+package fdoshared
+
+import "testing"
+
+func TestFragmentServiceInfo(t *testing.T) {
+	sims := GetDeviceOSSims()
+
+	fragments := FragmentServiceInfo(sims, 1)
+	if len(fragments) != len(sims) {
+		t.Fatalf("Expected %d fragments, got %d", len(sims), len(fragments))
+	}
+
+	for i, fragment := range fragments {
+		if len(fragment) != 1 || fragment[0].ServiceInfoKey != sims[i].ServiceInfoKey {
+			t.Errorf("Fragment %d does not contain single KV %s", i, sims[i].ServiceInfoKey)
+		}
+	}
+
+	fragments = FragmentServiceInfo(sims, 1500)
+	if len(fragments) != 1 || len(fragments[0]) != len(sims) {
+		t.Errorf("Expected all KVs in a single fragment, got %d fragments", len(fragments))
+	}
+
+	fragments = FragmentServiceInfo(sims, 48)
+	var reassembled []ServiceInfoKV
+	for i, fragment := range fragments {
+		fragmentBytes, _ := CborCust.Marshal(fragment)
+		if len(fragment) > 1 && len(fragmentBytes) > 48 {
+			t.Errorf("Fragment %d is %d bytes, exceeding limit", i, len(fragmentBytes))
+		}
+
+		reassembled = append(reassembled, fragment...)
+	}
+
+	if len(reassembled) != len(sims) {
+		t.Fatalf("Expected %d reassembled KVs, got %d", len(sims), len(reassembled))
+	}
+
+	for i := range sims {
+		if reassembled[i].ServiceInfoKey != sims[i].ServiceInfoKey {
+			t.Errorf("KV %d out of order. Expected %s, got %s", i, sims[i].ServiceInfoKey, reassembled[i].ServiceInfoKey)
+		}
+	}
+
+	if len(FragmentServiceInfo(nil, 1)) != 0 {
+		t.Errorf("Expected no fragments for empty service info")
+	}
+}
+
+func test_devmodSims() []ServiceInfoKV {
+	return append(GetDeviceOSSims(),
+		ServiceInfoKV{ServiceInfoKey: SIM_DEVMOD_NUMMODULES, ServiceInfoVal: UintToCborBytes(1)},
+		ServiceInfoKV{ServiceInfoKey: SIM_DEVMOD_MODULES, ServiceInfoVal: SimsListToBytes(SIM_IDS{"devmod"})},
+	)
+}
+
+func TestValidateDevmod(t *testing.T) {
+	sims := test_devmodSims()
+
+	errs := ValidateDevmod(sims)
+	if len(errs) != 0 {
+		t.Fatalf("Expected devmod to be valid. Got %v", errs)
+	}
+
+	invalidSims := []ServiceInfoKV{}
+	for _, sim := range sims {
+		switch sim.ServiceInfoKey {
+		case SIM_DEVMOD_OS, SIM_DEVMOD_BIN:
+			// Missing
+		case SIM_DEVMOD_ACTIVE:
+			invalidSims = append(invalidSims, ServiceInfoKV{ServiceInfoKey: sim.ServiceInfoKey, ServiceInfoVal: StringToCborBytes("true")})
+		case SIM_DEVMOD_NUMMODULES:
+			invalidSims = append(invalidSims, ServiceInfoKV{ServiceInfoKey: sim.ServiceInfoKey, ServiceInfoVal: []byte{0x20}})
+		case SIM_DEVMOD_MODULES:
+			modulesBytes, _ := CborCust.Marshal([]interface{}{uint(0), uint(1), []byte("devmod")})
+			invalidSims = append(invalidSims, ServiceInfoKV{ServiceInfoKey: sim.ServiceInfoKey, ServiceInfoVal: modulesBytes})
+		default:
+			invalidSims = append(invalidSims, sim)
+		}
+	}
+
+	errs = ValidateDevmod(invalidSims)
+	if len(errs) != 5 {
+		t.Errorf("Expected 2 missing and 3 malformed devmod keys. Got %v", errs)
+	}
+}
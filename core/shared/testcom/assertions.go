@@ -0,0 +1,203 @@
+package testcom
+
+import "sort"
+
+// FDOSpecAssertionID is FIDO Device Onboard 1.1 specification section, that test results are submitted against
+type FDOSpecAssertionID string
+
+const (
+	FDO_ASSERT_OWNERSHIP_VOUCHER FDOSpecAssertionID = "FDO-1.1-3.4"
+
+	FDO_ASSERT_TO0_HELLO        FDOSpecAssertionID = "FDO-1.1-5.3.1"
+	FDO_ASSERT_TO0_HELLO_ACK    FDOSpecAssertionID = "FDO-1.1-5.3.2"
+	FDO_ASSERT_TO0_OWNER_SIGN   FDOSpecAssertionID = "FDO-1.1-5.3.3"
+	FDO_ASSERT_TO0_ACCEPT_OWNER FDOSpecAssertionID = "FDO-1.1-5.3.4"
+
+	FDO_ASSERT_TO1_HELLO_RV     FDOSpecAssertionID = "FDO-1.1-5.4.1"
+	FDO_ASSERT_TO1_HELLO_RV_ACK FDOSpecAssertionID = "FDO-1.1-5.4.2"
+	FDO_ASSERT_TO1_PROVE_TO_RV  FDOSpecAssertionID = "FDO-1.1-5.4.3"
+	FDO_ASSERT_TO1_RV_REDIRECT  FDOSpecAssertionID = "FDO-1.1-5.4.4"
+
+	FDO_ASSERT_TO2_HELLO_DEVICE              FDOSpecAssertionID = "FDO-1.1-5.5.2"
+	FDO_ASSERT_TO2_PROVE_OVHDR               FDOSpecAssertionID = "FDO-1.1-5.5.3"
+	FDO_ASSERT_TO2_GET_OVNEXTENTRY           FDOSpecAssertionID = "FDO-1.1-5.5.4"
+	FDO_ASSERT_TO2_OVNEXTENTRY               FDOSpecAssertionID = "FDO-1.1-5.5.5"
+	FDO_ASSERT_TO2_PROVE_DEVICE              FDOSpecAssertionID = "FDO-1.1-5.5.6"
+	FDO_ASSERT_TO2_SETUP_DEVICE              FDOSpecAssertionID = "FDO-1.1-5.5.7"
+	FDO_ASSERT_TO2_DEVICE_SERVICE_INFO_READY FDOSpecAssertionID = "FDO-1.1-5.5.8"
+	FDO_ASSERT_TO2_OWNER_SERVICE_INFO_READY  FDOSpecAssertionID = "FDO-1.1-5.5.9"
+	FDO_ASSERT_TO2_DEVICE_SERVICE_INFO       FDOSpecAssertionID = "FDO-1.1-5.5.10"
+	FDO_ASSERT_TO2_OWNER_SERVICE_INFO        FDOSpecAssertionID = "FDO-1.1-5.5.11"
+	FDO_ASSERT_TO2_DONE                      FDOSpecAssertionID = "FDO-1.1-5.5.12"
+	FDO_ASSERT_TO2_DONE2                     FDOSpecAssertionID = "FDO-1.1-5.5.13"
+)
+
+type FDOSpecAssertion struct {
+	ID    FDOSpecAssertionID `json:"id"`
+	Title string             `json:"title"`
+}
+
+// FDO_SPEC_ASSERTIONS lists assertions in spec order. Assertions without tests are reported as not covered
+var FDO_SPEC_ASSERTIONS []FDOSpecAssertion = []FDOSpecAssertion{
+	{FDO_ASSERT_OWNERSHIP_VOUCHER, "Ownership Voucher"},
+
+	{FDO_ASSERT_TO0_HELLO, "TO0.Hello"},
+	{FDO_ASSERT_TO0_HELLO_ACK, "TO0.HelloAck"},
+	{FDO_ASSERT_TO0_OWNER_SIGN, "TO0.OwnerSign"},
+	{FDO_ASSERT_TO0_ACCEPT_OWNER, "TO0.AcceptOwner"},
+
+	{FDO_ASSERT_TO1_HELLO_RV, "TO1.HelloRV"},
+	{FDO_ASSERT_TO1_HELLO_RV_ACK, "TO1.HelloRVAck"},
+	{FDO_ASSERT_TO1_PROVE_TO_RV, "TO1.ProveToRV"},
+	{FDO_ASSERT_TO1_RV_REDIRECT, "TO1.RVRedirect"},
+
+	{FDO_ASSERT_TO2_HELLO_DEVICE, "TO2.HelloDevice"},
+	{FDO_ASSERT_TO2_PROVE_OVHDR, "TO2.ProveOVHdr"},
+	{FDO_ASSERT_TO2_GET_OVNEXTENTRY, "TO2.GetOVNextEntry"},
+	{FDO_ASSERT_TO2_OVNEXTENTRY, "TO2.OVNextEntry"},
+	{FDO_ASSERT_TO2_PROVE_DEVICE, "TO2.ProveDevice"},
+	{FDO_ASSERT_TO2_SETUP_DEVICE, "TO2.SetupDevice"},
+	{FDO_ASSERT_TO2_DEVICE_SERVICE_INFO_READY, "TO2.DeviceServiceInfoReady"},
+	{FDO_ASSERT_TO2_OWNER_SERVICE_INFO_READY, "TO2.OwnerServiceInfoReady"},
+	{FDO_ASSERT_TO2_DEVICE_SERVICE_INFO, "TO2.DeviceServiceInfo"},
+	{FDO_ASSERT_TO2_OWNER_SERVICE_INFO, "TO2.OwnerServiceInfo"},
+	{FDO_ASSERT_TO2_DONE, "TO2.Done"},
+	{FDO_ASSERT_TO2_DONE2, "TO2.Done2"},
+}
+
+// Test lists are mapped as a whole, so tests added to a list are mapped without changes here.
+// Server tests send the message and check the response, so cover both. Device tests send the response, so cover only that
+var fdoTestListAssertions = []struct {
+	tests      []FDOTestID
+	assertions []FDOSpecAssertionID
+}{
+	{FIDO_TEST_LIST_RVT_20, []FDOSpecAssertionID{FDO_ASSERT_TO0_HELLO, FDO_ASSERT_TO0_HELLO_ACK}},
+	{FIDO_TEST_LIST_RVT_22, []FDOSpecAssertionID{FDO_ASSERT_TO0_OWNER_SIGN, FDO_ASSERT_TO0_ACCEPT_OWNER}},
+	{FIDO_TEST_LIST_DEVT_30, []FDOSpecAssertionID{FDO_ASSERT_TO1_HELLO_RV, FDO_ASSERT_TO1_HELLO_RV_ACK}},
+	{FIDO_TEST_LIST_DEVT_32, []FDOSpecAssertionID{FDO_ASSERT_TO1_PROVE_TO_RV, FDO_ASSERT_TO1_RV_REDIRECT}},
+	{FIDO_TEST_LIST_DOT_60, []FDOSpecAssertionID{FDO_ASSERT_TO2_HELLO_DEVICE, FDO_ASSERT_TO2_PROVE_OVHDR}},
+	{FIDO_TEST_LIST_DOT_62, []FDOSpecAssertionID{FDO_ASSERT_TO2_GET_OVNEXTENTRY, FDO_ASSERT_TO2_OVNEXTENTRY}},
+	{FIDO_TEST_LIST_DOT_64, []FDOSpecAssertionID{FDO_ASSERT_TO2_PROVE_DEVICE, FDO_ASSERT_TO2_SETUP_DEVICE}},
+	{FIDO_TEST_LIST_DOT_64_DEVICE_CERT, []FDOSpecAssertionID{FDO_ASSERT_TO2_PROVE_DEVICE}},
+	{FIDO_TEST_LIST_DOT_66, []FDOSpecAssertionID{FDO_ASSERT_TO2_DEVICE_SERVICE_INFO_READY, FDO_ASSERT_TO2_OWNER_SERVICE_INFO_READY}},
+	{FIDO_TEST_LIST_DOT_68, []FDOSpecAssertionID{FDO_ASSERT_TO2_DEVICE_SERVICE_INFO, FDO_ASSERT_TO2_OWNER_SERVICE_INFO}},
+	{FIDO_TEST_LIST_DOT_70, []FDOSpecAssertionID{FDO_ASSERT_TO2_DONE, FDO_ASSERT_TO2_DONE2}},
+	{FIDO_TEST_LIST_DOT_RESALE, []FDOSpecAssertionID{FDO_ASSERT_TO2_HELLO_DEVICE, FDO_ASSERT_TO2_PROVE_OVHDR}},
+	{FIDO_TEST_LIST_DOT_RVINFO, []FDOSpecAssertionID{FDO_ASSERT_TO1_RV_REDIRECT}},
+	{FIDO_TEST_LIST_VOUCHER, []FDOSpecAssertionID{FDO_ASSERT_OWNERSHIP_VOUCHER}},
+
+	{FIDO_LISTENER_22_LIST, []FDOSpecAssertionID{FDO_ASSERT_TO0_ACCEPT_OWNER}},
+	{FIDO_LISTENER_30_LIST, []FDOSpecAssertionID{FDO_ASSERT_TO1_HELLO_RV_ACK}},
+	{FIDO_LISTENER_32_LIST, []FDOSpecAssertionID{FDO_ASSERT_TO1_RV_REDIRECT}},
+	{FIDO_LISTENER_60_LIST, []FDOSpecAssertionID{FDO_ASSERT_TO2_PROVE_OVHDR}},
+	{FIDO_LISTENER_62_LIST, []FDOSpecAssertionID{FDO_ASSERT_TO2_OVNEXTENTRY}},
+	{FIDO_LISTENER_64_LIST, []FDOSpecAssertionID{FDO_ASSERT_TO2_SETUP_DEVICE}},
+	{FIDO_LISTENER_66_LIST, []FDOSpecAssertionID{FDO_ASSERT_TO2_OWNER_SERVICE_INFO_READY}},
+	{FIDO_LISTENER_68_LIST, []FDOSpecAssertionID{FDO_ASSERT_TO2_OWNER_SERVICE_INFO}},
+	{FIDO_LISTENER_70_LIST, []FDOSpecAssertionID{FDO_ASSERT_TO2_DONE2}},
+}
+
+// Tests recorded outside of the test lists
+var fdoTestAssertions = map[FDOTestID][]FDOSpecAssertionID{
+	FIDO_LISTENER_OWNER_22_MALFORMED_OWNERSIGN:       {FDO_ASSERT_TO0_OWNER_SIGN},
+	FIDO_LISTENER_OWNER_22_BAD_TO0D_HASH:             {FDO_ASSERT_TO0_OWNER_SIGN},
+	FIDO_LISTENER_OWNER_22_EXPIRED_WAITSECONDS:       {FDO_ASSERT_TO0_OWNER_SIGN},
+	FIDO_LISTENER_DEVICE_30_REPLACEMENT_GUID:         {FDO_ASSERT_TO2_SETUP_DEVICE},
+	FIDO_LISTENER_DEVICE_30_REPLACEMENT_RVINFO:       {FDO_ASSERT_TO2_SETUP_DEVICE},
+	FIDO_LISTENER_DEVICE_64_NONCE_TO2PROVEDV:         {FDO_ASSERT_TO2_PROVE_DEVICE},
+	FIDO_LISTENER_DEVICE_68_DEVMOD:                   {FDO_ASSERT_TO2_DEVICE_SERVICE_INFO},
+	FIDO_LISTENER_DEVICE_70_RV_BYPASS:                {FDO_ASSERT_TO1_HELLO_RV},
+	FIDO_LISTENER_DEVICE_70_TO1D_OWNER_MISMATCH:      {FDO_ASSERT_TO1_RV_REDIRECT},
+	FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_PRESENT: {FDO_ASSERT_TO1_RV_REDIRECT},
+	FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_ABSENT:  {FDO_ASSERT_TO1_RV_REDIRECT},
+	FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_INVALID: {FDO_ASSERT_TO1_RV_REDIRECT},
+}
+
+// GetTestAssertions returns spec assertions test covers. Empty for setup and observation tests
+func GetTestAssertions(testId FDOTestID) []FDOSpecAssertionID {
+	if assertions, ok := fdoTestAssertions[testId]; ok {
+		return assertions
+	}
+
+	for _, listAssertions := range fdoTestListAssertions {
+		for _, listTestId := range listAssertions.tests {
+			if listTestId == testId {
+				return listAssertions.assertions
+			}
+		}
+	}
+
+	return []FDOSpecAssertionID{}
+}
+
+type FDOAssertionResult struct {
+	FDOSpecAssertion
+	Covered     bool           `json:"covered"`
+	Passed      bool           `json:"passed"`
+	PassedCount int            `json:"passedCount"`
+	FailedCount int            `json:"failedCount"`
+	Tests       []FDOTestState `json:"tests"`
+}
+
+// FDOAssertionReport is assertion coverage matrix of test results
+type FDOAssertionReport struct {
+	Assertions []FDOAssertionResult `json:"assertions"`
+	Covered    int                  `json:"covered"`
+	Total      int                  `json:"total"`
+	// Results of tests not mapped to any assertion
+	Unmapped []FDOTestState `json:"unmapped"`
+}
+
+// NewAssertionReport groups test results by spec assertion. Assertion passes when it has results, and all of them passed
+func NewAssertionReport(testStates []FDOTestState) FDOAssertionReport {
+	report := FDOAssertionReport{
+		Assertions: []FDOAssertionResult{},
+		Total:      len(FDO_SPEC_ASSERTIONS),
+		Unmapped:   []FDOTestState{},
+	}
+
+	sortedStates := append([]FDOTestState{}, testStates...)
+	sort.SliceStable(sortedStates, func(i, j int) bool {
+		return sortedStates[i].TestID < sortedStates[j].TestID
+	})
+
+	assertionTests := map[FDOSpecAssertionID][]FDOTestState{}
+	for _, testState := range sortedStates {
+		assertions := GetTestAssertions(testState.TestID)
+		if len(assertions) == 0 {
+			report.Unmapped = append(report.Unmapped, testState)
+			continue
+		}
+
+		for _, assertion := range assertions {
+			assertionTests[assertion] = append(assertionTests[assertion], testState)
+		}
+	}
+
+	for _, assertion := range FDO_SPEC_ASSERTIONS {
+		result := FDOAssertionResult{
+			FDOSpecAssertion: assertion,
+			Tests:            []FDOTestState{},
+		}
+
+		for _, testState := range assertionTests[assertion.ID] {
+			result.Tests = append(result.Tests, testState)
+			if testState.Passed {
+				result.PassedCount++
+			} else {
+				result.FailedCount++
+			}
+		}
+
+		result.Covered = len(result.Tests) > 0
+		result.Passed = result.Covered && result.FailedCount == 0
+
+		if result.Covered {
+			report.Covered++
+		}
+
+		report.Assertions = append(report.Assertions, result)
+	}
+
+	return report
+}
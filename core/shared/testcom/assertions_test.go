@@ -0,0 +1,73 @@
+package testcom
+
+import "testing"
+
+func TestTestListsMappedToAssertions(t *testing.T) {
+	knownAssertions := map[FDOSpecAssertionID]bool{}
+	for _, assertion := range FDO_SPEC_ASSERTIONS {
+		knownAssertions[assertion.ID] = true
+	}
+
+	for _, listAssertions := range fdoTestListAssertions {
+		for _, assertion := range listAssertions.assertions {
+			if !knownAssertions[assertion] {
+				t.Errorf("Assertion %s is not in FDO_SPEC_ASSERTIONS", assertion)
+			}
+		}
+	}
+
+	for testId, assertions := range fdoTestAssertions {
+		for _, assertion := range assertions {
+			if !knownAssertions[assertion] {
+				t.Errorf("Assertion %s of %s is not in FDO_SPEC_ASSERTIONS", assertion, testId)
+			}
+		}
+	}
+
+	if len(GetTestAssertions(FIDO_LISTENER_DEVICE_62_MISSING_OVENTRY)) == 0 {
+		t.Errorf("Expected test in a list to be mapped")
+	}
+
+	if len(GetTestAssertions(NULL_TEST)) != 0 {
+		t.Errorf("Expected NULL_TEST to not be mapped")
+	}
+}
+
+func TestNewAssertionReport(t *testing.T) {
+	report := NewAssertionReport([]FDOTestState{
+		NewSuccessTestState(FIDO_RVT_20_POSITIVE),
+		NewFailTestState(FIDO_RVT_20_BAD_ENCODING, "Bad response"),
+		NewSuccessTestState(FIDO_DOT_64_BAD_DEVICE_CERT_EXPIRED),
+		NewSuccessTestState(NULL_TEST),
+	})
+
+	if report.Total != len(FDO_SPEC_ASSERTIONS) || len(report.Assertions) != len(FDO_SPEC_ASSERTIONS) {
+		t.Fatalf("Expected all %d assertions in report. Got %d", len(FDO_SPEC_ASSERTIONS), len(report.Assertions))
+	}
+
+	// TO0.Hello, TO0.HelloAck and TO2.ProveDevice
+	if report.Covered != 3 {
+		t.Errorf("Expected 3 covered assertions. Got %d", report.Covered)
+	}
+
+	if len(report.Unmapped) != 1 || report.Unmapped[0].TestID != NULL_TEST {
+		t.Errorf("Expected NULL_TEST to be unmapped. Got %v", report.Unmapped)
+	}
+
+	for _, result := range report.Assertions {
+		switch result.ID {
+		case FDO_ASSERT_TO0_HELLO, FDO_ASSERT_TO0_HELLO_ACK:
+			if !result.Covered || result.Passed || result.PassedCount != 1 || result.FailedCount != 1 {
+				t.Errorf("Expected %s covered and failing with 1 passed and 1 failed test. Got %+v", result.ID, result)
+			}
+		case FDO_ASSERT_TO2_PROVE_DEVICE:
+			if !result.Covered || !result.Passed {
+				t.Errorf("Expected %s covered and passing. Got %+v", result.ID, result)
+			}
+		default:
+			if result.Covered || result.Passed {
+				t.Errorf("Expected %s not covered. Got %+v", result.ID, result)
+			}
+		}
+	}
+}
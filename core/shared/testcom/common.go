@@ -5,6 +5,8 @@ type FDOTestState struct {
 	Passed bool      `json:"passed"`
 	Error  string    `json:"error"`
 	TestID FDOTestID `json:"testId"`
+	// Messages sent again after transient network errors
+	Retries int `json:"retries,omitempty"`
 }
 
 func NewSuccessTestState(testId FDOTestID) FDOTestState {
@@ -0,0 +1,87 @@
+package dbs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+type CampaignRunState string
+
+const (
+	CAMPAIGN_STATE_RUNNING  CampaignRunState = "running"
+	CAMPAIGN_STATE_PAUSED   CampaignRunState = "paused"
+	CAMPAIGN_STATE_ABORTED  CampaignRunState = "aborted"
+	CAMPAIGN_STATE_FINISHED CampaignRunState = "finished"
+	// Server stopped while campaign was running or paused. Never saved, reported by API for such saved states
+	CAMPAIGN_STATE_INTERRUPTED CampaignRunState = "interrupted"
+)
+
+// CampaignState is the checkpoint of a campaign: stages that finished, and that are in flight
+type CampaignState struct {
+	State       CampaignRunState `json:"state"`
+	Completed   []string         `json:"completed"`
+	Running     []string         `json:"running"`
+	StartedAt   int64            `json:"startedAt"`
+	FinishedAt  int64            `json:"finishedAt,omitempty"`
+	AbortReason string           `json:"abortReason,omitempty"`
+}
+
+// CampaignDB keeps state of the last campaign of each RVT and DOT pair, so it can be followed after the execute request returns
+type CampaignDB struct {
+	db     *badger.DB
+	prefix []byte
+	ttl    int
+}
+
+func NewCampaignDB(db *badger.DB) *CampaignDB {
+	return &CampaignDB{
+		db:     db,
+		prefix: []byte("campaign-"),
+		ttl:    60 * 60 * 24 * 183, // Same as rvte storage
+	}
+}
+
+func (h *CampaignDB) getEntryId(campaignId string) []byte {
+	return append(append([]byte{}, h.prefix...), []byte(campaignId)...)
+}
+
+func (h *CampaignDB) Save(campaignId string, state CampaignState) error {
+	stateBytes, err := fdoshared.CborCust.Marshal(state)
+	if err != nil {
+		return errors.New("Failed to marshal campaign state. The error is: " + err.Error())
+	}
+
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		entry := badger.NewEntry(h.getEntryId(campaignId), stateBytes).WithTTL(time.Second * time.Duration(h.ttl))
+		return dbtxn.SetEntry(entry)
+	})
+}
+
+// Get returns nil when campaign never ran
+func (h *CampaignDB) Get(campaignId string) (*CampaignState, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	item, err := dbtxn.Get(h.getEntryId(campaignId))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating campaign entry. The error is: " + err.Error())
+	}
+
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading campaign entry value. The error is: " + err.Error())
+	}
+
+	var state CampaignState
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &state)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding campaign entry value. The error is: " + err.Error())
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,46 @@
+package dbs
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestCampaignDB_SaveGet(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	campaignDB := NewCampaignDB(db)
+
+	state, err := campaignDB.Get("rvt:dot")
+	if err != nil || state != nil {
+		t.Fatalf("Expected no state for campaign that never ran. Got %v, %v", state, err)
+	}
+
+	err = campaignDB.Save("rvt:dot", CampaignState{
+		State:     CAMPAIGN_STATE_PAUSED,
+		Completed: []string{"TO0 20"},
+		Running:   []string{"TO1"},
+		StartedAt: 1700000000,
+	})
+	if err != nil {
+		t.Fatalf("Failed to save campaign state. %s", err.Error())
+	}
+
+	state, err = campaignDB.Get("rvt:dot")
+	if err != nil {
+		t.Fatalf("Failed to get campaign state. %s", err.Error())
+	}
+
+	if state.State != CAMPAIGN_STATE_PAUSED || len(state.Completed) != 1 || state.Running[0] != "TO1" || state.StartedAt != 1700000000 {
+		t.Errorf("Unexpected campaign state %+v", state)
+	}
+
+	state, _ = campaignDB.Get("rvt:other")
+	if state != nil {
+		t.Errorf("Expected no state for other campaign")
+	}
+}
@@ -0,0 +1,132 @@
+package dbs
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Set once on startup from CAPTURE_MESSAGES. Raw device requests are persisted per GUID
+var CaptureMessages bool = false
+
+// Oldest captures of a device are dropped after the limit
+const CAPTURE_MAX_ENTRIES = 512
+
+type CapturedMessage struct {
+	Timestamp int64                   `cbor:"timestamp"` // Unix nanoseconds
+	Protocol  fdoshared.FdoToProtocol `cbor:"protocol"`
+	Cmd       fdoshared.FdoCmd        `cbor:"cmd"`
+	SessionId string                  `cbor:"sessionId,omitempty"`
+	Body      []byte                  `cbor:"body"`
+}
+
+// CaptureDB keeps raw request bodies sent by a device, per device GUID, to replay decode failures offline.
+// Unlike MessageLogDB, devices without listener test are captured too
+type CaptureDB struct {
+	db     *badger.DB
+	prefix []byte
+	ttl    int
+}
+
+func NewCaptureDB(db *badger.DB) *CaptureDB {
+	return &CaptureDB{
+		db:     db,
+		prefix: []byte("capture-"),
+		ttl:    60 * 60 * 24 * 7, // 7 days storage
+	}
+}
+
+func (h *CaptureDB) getEntryId(guid fdoshared.FdoGuid) []byte {
+	return append(append([]byte{}, h.prefix...), guid[:]...)
+}
+
+func (h *CaptureDB) Append(guid fdoshared.FdoGuid, newMessages ...CapturedMessage) error {
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		var messages []CapturedMessage
+
+		item, err := dbtxn.Get(h.getEntryId(guid))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return errors.New("Failed locating capture entry. The error is: " + err.Error())
+		}
+
+		if err == nil {
+			itemBytes, err := item.ValueCopy(nil)
+			if err != nil {
+				return errors.New("Failed reading capture entry value. The error is: " + err.Error())
+			}
+
+			err = fdoshared.CborCust.Unmarshal(itemBytes, &messages)
+			if err != nil {
+				return errors.New("Failed cbor decoding capture entry value. The error is: " + err.Error())
+			}
+		}
+
+		messages = append(messages, newMessages...)
+		if len(messages) > CAPTURE_MAX_ENTRIES {
+			messages = messages[len(messages)-CAPTURE_MAX_ENTRIES:]
+		}
+
+		messagesBytes, err := fdoshared.CborCust.Marshal(messages)
+		if err != nil {
+			return errors.New("Failed to marshal captures. The error is: " + err.Error())
+		}
+
+		entry := badger.NewEntry(h.getEntryId(guid), messagesBytes).WithTTL(time.Second * time.Duration(h.ttl))
+		return dbtxn.SetEntry(entry)
+	})
+}
+
+// Get returns empty list when nothing was captured for the device
+func (h *CaptureDB) Get(guid fdoshared.FdoGuid) ([]CapturedMessage, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	messages := []CapturedMessage{}
+
+	item, err := dbtxn.Get(h.getEntryId(guid))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return messages, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating capture entry. The error is: " + err.Error())
+	}
+
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading capture entry value. The error is: " + err.Error())
+	}
+
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &messages)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding capture entry value. The error is: " + err.Error())
+	}
+
+	return messages, nil
+}
+
+// WriteCapturesZip writes captured bodies as zip of raw CBOR files, named by capture order, protocol and message number. E.g. 003-TO2-64.cbor
+func WriteCapturesZip(w io.Writer, messages []CapturedMessage) error {
+	zipWriter := zip.NewWriter(w)
+
+	for i, message := range messages {
+		fileWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     fmt.Sprintf("%03d-TO%d-%d.cbor", i+1, message.Protocol, message.Cmd),
+			Method:   zip.Deflate,
+			Modified: time.Unix(0, message.Timestamp),
+		})
+		if err != nil {
+			return errors.New("error creating capture zip entry. " + err.Error())
+		}
+
+		_, err = fileWriter.Write(message.Body)
+		if err != nil {
+			return errors.New("error writing capture zip entry. " + err.Error())
+		}
+	}
+
+	return zipWriter.Close()
+}
@@ -0,0 +1,95 @@
+package dbs
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestCaptureDB_Capture(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	defer func() { CaptureMessages = false }()
+
+	listenerDB := NewListenerTestDB(db)
+	msgLogDB := NewMessageLogDB(db)
+	captureDB := NewCaptureDB(db)
+
+	// No listener test. Captured regardless
+	guid := fdoshared.NewFdoGuid()
+	resolver := func(sessionId []byte, reqBody []byte) *fdoshared.FdoGuid {
+		return &guid
+	}
+
+	handler := msgLogDB.Capture(listenerDB, fdoshared.To2, fdoshared.TO2_64_PROVE_DEVICE, resolver, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/fdo/101/msg/64", bytes.NewReader([]byte{0x01})))
+
+	messages, err := captureDB.Get(guid)
+	if err != nil {
+		t.Fatalf("Failed to get captures. %s", err.Error())
+	}
+
+	if len(messages) != 0 {
+		t.Fatalf("Expected no captures with CaptureMessages disabled. Got %d", len(messages))
+	}
+
+	CaptureMessages = true
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/fdo/101/msg/64", bytes.NewReader([]byte{0x02, 0x03})))
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/fdo/101/msg/64", bytes.NewReader([]byte{0x04})))
+
+	messages, err = captureDB.Get(guid)
+	if err != nil {
+		t.Fatalf("Failed to get captures. %s", err.Error())
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 captures, got %d", len(messages))
+	}
+
+	if messages[0].Protocol != fdoshared.To2 || messages[0].Cmd != fdoshared.TO2_64_PROVE_DEVICE || !bytes.Equal(messages[0].Body, []byte{0x02, 0x03}) {
+		t.Errorf("Unexpected capture %+v", messages[0])
+	}
+
+	var zipBuffer bytes.Buffer
+	err = WriteCapturesZip(&zipBuffer, messages)
+	if err != nil {
+		t.Fatalf("Failed to write captures zip. %s", err.Error())
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(zipBuffer.Bytes()), int64(zipBuffer.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read captures zip. %s", err.Error())
+	}
+
+	expectedFiles := map[string][]byte{"001-TO2-64.cbor": {0x02, 0x03}, "002-TO2-64.cbor": {0x04}}
+	if len(zipReader.File) != len(expectedFiles) {
+		t.Fatalf("Expected %d files in zip, got %d", len(expectedFiles), len(zipReader.File))
+	}
+
+	for _, zipFile := range zipReader.File {
+		fileReader, err := zipFile.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s. %s", zipFile.Name, err.Error())
+		}
+
+		fileBytes, _ := io.ReadAll(fileReader)
+		fileReader.Close()
+
+		if !bytes.Equal(fileBytes, expectedFiles[zipFile.Name]) {
+			t.Errorf("Unexpected content of %s. Got %x", zipFile.Name, fileBytes)
+		}
+	}
+}
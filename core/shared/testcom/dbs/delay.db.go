@@ -0,0 +1,136 @@
+package dbs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Upper bound for a single delay. Above any timeout a device is expected to wait
+const RESPONSE_DELAY_MAX_MS uint32 = 5 * 60 * 1000
+
+// Messages received by RV and DO handlers
+var RESPONSE_DELAY_CMDS = []fdoshared.FdoCmd{
+	fdoshared.TO0_20_HELLO,
+	fdoshared.TO0_22_OWNER_SIGN,
+	fdoshared.TO1_30_HELLO_RV,
+	fdoshared.TO1_32_PROVE_TO_RV,
+	fdoshared.TO2_60_HELLO_DEVICE,
+	fdoshared.TO2_62_GET_OVNEXTENTRY,
+	fdoshared.TO2_64_PROVE_DEVICE,
+	fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY,
+	fdoshared.TO2_68_DEVICE_SERVICE_INFO,
+	fdoshared.TO2_70_DONE,
+}
+
+// ResponseDelays maps FDO message number to artificial delay in milliseconds
+type ResponseDelays map[fdoshared.FdoCmd]uint32
+
+// ResponseDelayDB keeps delays applied by RV and DO handlers before responding. Used to verify device timeout and retry behaviour
+type ResponseDelayDB struct {
+	db     *badger.DB
+	prefix []byte
+}
+
+func NewResponseDelayDB(db *badger.DB) *ResponseDelayDB {
+	return &ResponseDelayDB{
+		db:     db,
+		prefix: []byte("respdelay-"),
+	}
+}
+
+func (h *ResponseDelayDB) getEntryId() []byte {
+	return append(append([]byte{}, h.prefix...), []byte("main")...)
+}
+
+func (h *ResponseDelayDB) readDelays(dbtxn *badger.Txn) (ResponseDelays, error) {
+	delays := ResponseDelays{}
+
+	item, err := dbtxn.Get(h.getEntryId())
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return delays, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating response delays entry. The error is: " + err.Error())
+	}
+
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading response delays entry value. The error is: " + err.Error())
+	}
+
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &delays)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding response delays entry value. The error is: " + err.Error())
+	}
+
+	return delays, nil
+}
+
+// Get returns empty delays when none were set
+func (h *ResponseDelayDB) Get() (ResponseDelays, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	return h.readDelays(dbtxn)
+}
+
+// Set sets delay for the message. Zero delay removes it
+func (h *ResponseDelayDB) Set(cmd fdoshared.FdoCmd, delayMs uint32) error {
+	isKnownCmd := false
+	for _, delayCmd := range RESPONSE_DELAY_CMDS {
+		isKnownCmd = isKnownCmd || delayCmd == cmd
+	}
+
+	if !isKnownCmd {
+		return fmt.Errorf("message %d is not handled by RV or DO", cmd)
+	}
+
+	if delayMs > RESPONSE_DELAY_MAX_MS {
+		return fmt.Errorf("delay %dms is above the %dms limit", delayMs, RESPONSE_DELAY_MAX_MS)
+	}
+
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		delays, err := h.readDelays(dbtxn)
+		if err != nil {
+			return err
+		}
+
+		if delayMs == 0 {
+			delete(delays, cmd)
+		} else {
+			delays[cmd] = delayMs
+		}
+
+		delaysBytes, err := fdoshared.CborCust.Marshal(delays)
+		if err != nil {
+			return errors.New("Failed to marshal response delays. The error is: " + err.Error())
+		}
+
+		return dbtxn.SetEntry(badger.NewEntry(h.getEntryId(), delaysBytes))
+	})
+}
+
+// Delay waits for the configured delay of the message before passing request to the handler
+func (h *ResponseDelayDB) Delay(cmd fdoshared.FdoCmd, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		delays, err := h.Get()
+		if err != nil {
+			log.Println("Failed to read response delays. " + err.Error())
+		}
+
+		if delayMs, ok := delays[cmd]; ok {
+			select {
+			case <-time.After(time.Duration(delayMs) * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,67 @@
+package dbs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestResponseDelayDB_Delay(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	delayDB := NewResponseDelayDB(db)
+
+	err = delayDB.Set(fdoshared.TO2_60_HELLO_DEVICE, 200)
+	if err != nil {
+		t.Fatalf("Failed to set delay. %s", err.Error())
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	startTime := time.Now()
+	w := httptest.NewRecorder()
+	delayDB.Delay(fdoshared.TO2_60_HELLO_DEVICE, handler)(w, httptest.NewRequest("POST", "/fdo/101/msg/60", nil))
+	if elapsed := time.Since(startTime); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected response to be delayed by 200ms. Took %s", elapsed)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected handler to respond after the delay. Got %d", w.Code)
+	}
+
+	startTime = time.Now()
+	delayDB.Delay(fdoshared.TO2_62_GET_OVNEXTENTRY, handler)(httptest.NewRecorder(), httptest.NewRequest("POST", "/fdo/101/msg/62", nil))
+	if elapsed := time.Since(startTime); elapsed >= 200*time.Millisecond {
+		t.Errorf("Expected message without delay to respond immediately. Took %s", elapsed)
+	}
+
+	err = delayDB.Set(fdoshared.TO2_60_HELLO_DEVICE, 0)
+	if err != nil {
+		t.Fatalf("Failed to remove delay. %s", err.Error())
+	}
+
+	delays, _ := delayDB.Get()
+	if len(delays) != 0 {
+		t.Errorf("Expected zero delay to remove the entry. Got %v", delays)
+	}
+
+	err = delayDB.Set(fdoshared.TO2_60_HELLO_DEVICE, RESPONSE_DELAY_MAX_MS+1)
+	if err == nil {
+		t.Errorf("Expected delay above the limit to be rejected")
+	}
+
+	err = delayDB.Set(fdoshared.TO2_61_PROVE_OVHDR, 100)
+	if err == nil {
+		t.Errorf("Expected delay of a response message to be rejected")
+	}
+}
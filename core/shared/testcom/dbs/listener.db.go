@@ -1,6 +1,7 @@
 package dbs
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -210,38 +211,41 @@ func (h *ListenerTestDB) GetMany(entriesIds [][]byte) (*[]listenertestsdeps.Requ
 }
 
 func (h *ListenerTestDB) RemoveTestRun(toProtocol fdoshared.FdoToProtocol, testInstId []byte, testRunId string) error {
-	testInst, err := h.Get(testInstId)
-	if err != nil {
-		return fmt.Errorf("%s test entry can not be found. %s", hex.EncodeToString(testInstId), err.Error())
-	}
+	err := fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		item, err := dbtxn.Get(h.getEntryId(testInstId))
+		if err != nil {
+			return fmt.Errorf("%s test entry can not be found. %s", hex.EncodeToString(testInstId), err.Error())
+		}
 
-	var chosenReqListRunner listenertestsdeps.RequestListenerRunnerInst
-	switch toProtocol {
-	case fdoshared.To0:
-		chosenReqListRunner = testInst.To0
-	case fdoshared.To1:
-		chosenReqListRunner = testInst.To1
-	case fdoshared.To2:
-		chosenReqListRunner = testInst.To2
-	default:
-		return fmt.Errorf("Unknown FDO protocol %d", toProtocol)
-	}
+		itemBytes, err := item.ValueCopy(nil)
+		if err != nil {
+			return errors.New("Failed reading rvte entry value." + err.Error())
+		}
 
-	err = chosenReqListRunner.RemoveTestRun(testRunId)
-	if err != nil {
-		return err
-	}
+		var testInst listenertestsdeps.RequestListenerInst
+		err = fdoshared.CborCust.Unmarshal(itemBytes, &testInst)
+		if err != nil {
+			return errors.New("Failed cbor decoding rvte entry value." + err.Error())
+		}
 
-	switch toProtocol {
-	case fdoshared.To0:
-		testInst.To0 = chosenReqListRunner
-	case fdoshared.To1:
-		testInst.To1 = chosenReqListRunner
-	case fdoshared.To2:
-		testInst.To2 = chosenReqListRunner
-	}
+		chosenReqListRunner, err := testInst.GetProtocolInst(int(toProtocol))
+		if err != nil {
+			return err
+		}
+
+		err = chosenReqListRunner.RemoveTestRun(testRunId)
+		if err != nil {
+			return err
+		}
 
-	err = h.Save(*testInst)
+		structBytes, err := fdoshared.CborCust.Marshal(testInst)
+		if err != nil {
+			return errors.New("Failed to marshal listener entry." + err.Error())
+		}
+
+		entry := badger.NewEntry(h.getEntryId(testInstId), structBytes).WithTTL(time.Second * time.Duration(h.ttl))
+		return dbtxn.SetEntry(entry)
+	})
 	if err != nil {
 		log.Printf("%s error saving test entry. %s", hex.EncodeToString(testInstId), err.Error())
 		return err
@@ -295,3 +299,70 @@ func (h *ListenerTestDB) GetEntryByFdoGuid(guid fdoshared.FdoGuid) (*listenertes
 
 	return h.Get(entryUuid)
 }
+
+/* ---- Maintenance ----- */
+
+// RebuildIndexes scans listener entries and re-creates GUID mappings. Safe to run multiple times
+func (h *ListenerTestDB) RebuildIndexes() (int, int, error) {
+	var entries []listenertestsdeps.RequestListenerInst
+
+	err := h.db.View(func(dbtxn *badger.Txn) error {
+		it := dbtxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(h.prefix); it.ValidForPrefix(h.prefix); it.Next() {
+			item := it.Item()
+			if bytes.HasPrefix(item.Key(), h.mapperGuidPrefix) {
+				continue
+			}
+
+			itemBytes, err := item.ValueCopy(nil)
+			if err != nil {
+				return errors.New("Failed reading listener entry value." + err.Error())
+			}
+
+			var reqListInst listenertestsdeps.RequestListenerInst
+			err = fdoshared.CborCust.Unmarshal(itemBytes, &reqListInst)
+			if err != nil {
+				log.Printf("Skipping listener entry %s. Failed to decode. %s", hex.EncodeToString(item.Key()), err.Error())
+				continue
+			}
+
+			entries = append(entries, reqListInst)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	indexed := 0
+	for i, entry := range entries {
+		guids := []fdoshared.FdoGuid{entry.Guid}
+		if entry.ReplacedGuid != nil {
+			guids = append(guids, *entry.ReplacedGuid)
+		}
+
+		if entry.AbandonedGuid != nil {
+			guids = append(guids, *entry.AbandonedGuid)
+		}
+
+		for _, guid := range guids {
+			err := h.SaveMapping(guid, entry.Uuid)
+			if err != nil {
+				return len(entries), indexed, err
+			}
+
+			indexed++
+		}
+
+		if (i+1)%100 == 0 {
+			log.Printf("Rebuilding listener indexes: %d/%d entries", i+1, len(entries))
+		}
+	}
+
+	log.Printf("Rebuilt listener indexes: %d entries, %d mappings", len(entries), indexed)
+
+	return len(entries), indexed, nil
+}
@@ -0,0 +1,65 @@
+package dbs
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+)
+
+func TestListenerTestDB_RebuildIndexes(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	listenerDB := NewListenerTestDB(db)
+
+	var guids []fdoshared.FdoGuid
+	for i := 0; i < 3; i++ {
+		guid := fdoshared.NewFdoGuid()
+		guids = append(guids, guid)
+
+		err := listenerDB.Save(listenertestsdeps.NewDevice_RequestListenerInst(fdoshared.VoucherDBEntry{}, guid))
+		if err != nil {
+			t.Fatalf("Failed to save listener entry. %s", err.Error())
+		}
+	}
+
+	// Simulate DB created before the index existed
+	for _, guid := range guids {
+		err := listenerDB.DeleteMapping(guid)
+		if err != nil {
+			t.Fatalf("Failed to delete mapping. %s", err.Error())
+		}
+
+		_, err = listenerDB.GetEntryByFdoGuid(guid)
+		if err == nil {
+			t.Fatalf("Expected lookup to fail without mapping")
+		}
+	}
+
+	for run := 0; run < 2; run++ {
+		scanned, indexed, err := listenerDB.RebuildIndexes()
+		if err != nil {
+			t.Fatalf("Failed to rebuild indexes. %s", err.Error())
+		}
+
+		if scanned != len(guids) || indexed != len(guids) {
+			t.Errorf("Expected %d scanned and indexed entries. Got %d and %d", len(guids), scanned, indexed)
+		}
+	}
+
+	for _, guid := range guids {
+		entry, err := listenerDB.GetEntryByFdoGuid(guid)
+		if err != nil {
+			t.Fatalf("Expected entry to be found after reindex. %s", err.Error())
+		}
+
+		if entry.Guid != guid {
+			t.Errorf("Mapping points to wrong entry")
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package dbs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// EndpointMetric counts requests to a single FDO message endpoint, by response HTTP status
+type EndpointMetric struct {
+	Hits        int         `cbor:"hits" json:"hits"`
+	StatusCodes map[int]int `cbor:"statusCodes" json:"statusCodes"`
+}
+
+// RunEndpointMetrics are endpoint metrics of a single listener test run, keyed by FDO message number
+type RunEndpointMetrics map[fdoshared.FdoCmd]EndpointMetric
+
+// EndpointMetricsDB keeps per test run counts of device requests to each FDO message endpoint
+type EndpointMetricsDB struct {
+	db     *badger.DB
+	prefix []byte
+	ttl    int
+}
+
+func NewEndpointMetricsDB(db *badger.DB) *EndpointMetricsDB {
+	return &EndpointMetricsDB{
+		db:     db,
+		prefix: []byte("endpointmetrics-"),
+		ttl:    60 * 60 * 24 * 30, // 30 days storage, same as message log
+	}
+}
+
+func (h *EndpointMetricsDB) getEntryId(testRunId string) []byte {
+	return append(append([]byte{}, h.prefix...), []byte(testRunId)...)
+}
+
+func (h *EndpointMetricsDB) Record(testRunId string, cmd fdoshared.FdoCmd, httpStatus int) error {
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		metrics := RunEndpointMetrics{}
+
+		item, err := dbtxn.Get(h.getEntryId(testRunId))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return errors.New("Failed locating endpoint metrics entry. The error is: " + err.Error())
+		}
+
+		if err == nil {
+			itemBytes, err := item.ValueCopy(nil)
+			if err != nil {
+				return errors.New("Failed reading endpoint metrics entry value. The error is: " + err.Error())
+			}
+
+			err = fdoshared.CborCust.Unmarshal(itemBytes, &metrics)
+			if err != nil {
+				return errors.New("Failed cbor decoding endpoint metrics entry value. The error is: " + err.Error())
+			}
+		}
+
+		metric, ok := metrics[cmd]
+		if !ok {
+			metric = EndpointMetric{
+				StatusCodes: map[int]int{},
+			}
+		}
+
+		metric.Hits++
+		metric.StatusCodes[httpStatus]++
+		metrics[cmd] = metric
+
+		metricsBytes, err := fdoshared.CborCust.Marshal(metrics)
+		if err != nil {
+			return errors.New("Failed to marshal endpoint metrics. The error is: " + err.Error())
+		}
+
+		entry := badger.NewEntry(h.getEntryId(testRunId), metricsBytes).WithTTL(time.Second * time.Duration(h.ttl))
+		return dbtxn.SetEntry(entry)
+	})
+}
+
+// Get returns empty metrics when run received no requests yet
+func (h *EndpointMetricsDB) Get(testRunId string) (RunEndpointMetrics, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	metrics := RunEndpointMetrics{}
+
+	item, err := dbtxn.Get(h.getEntryId(testRunId))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return metrics, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating endpoint metrics entry. The error is: " + err.Error())
+	}
+
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading endpoint metrics entry value. The error is: " + err.Error())
+	}
+
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &metrics)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding endpoint metrics entry value. The error is: " + err.Error())
+	}
+
+	return metrics, nil
+}
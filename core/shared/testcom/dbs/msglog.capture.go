@@ -0,0 +1,168 @@
+package dbs
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+)
+
+// GuidResolver finds device GUID of the captured exchange. Returns nil when it is unknown
+type GuidResolver func(sessionId []byte, reqBody []byte) *fdoshared.FdoGuid
+
+type messageLogRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (h *messageLogRecorder) WriteHeader(status int) {
+	h.status = status
+	h.ResponseWriter.WriteHeader(status)
+}
+
+func (h *messageLogRecorder) Write(b []byte) (int, error) {
+	h.body.Write(b)
+	return h.ResponseWriter.Write(b)
+}
+
+type errorReader struct {
+	err error
+}
+
+func (h errorReader) Read(p []byte) (int, error) {
+	return 0, h.err
+}
+
+func getBearerSessionId(header http.Header) []byte {
+	sessionId, err := fdoshared.ParseBearerToken(header.Get("Authorization"))
+	if err != nil {
+		return nil
+	}
+
+	return sessionId
+}
+
+// getRunState returns test run of the listener protocol runner, and whether it is running
+func getRunState(testcomListener *listenertestsdeps.RequestListenerInst, protocol fdoshared.FdoToProtocol) (string, bool) {
+	if testcomListener == nil {
+		return "", false
+	}
+
+	runner := testcomListener.To2
+	if protocol == fdoshared.To1 {
+		runner = testcomListener.To1
+	}
+
+	return runner.CurrentTestRun.Uuid, runner.Running
+}
+
+// Capture records request and response of FDO handler to the message log of device under test, and counts it in endpoint metrics of the running test run.
+// Devices without listener test are not recorded, except for raw request capture with CaptureMessages
+func (h *MessageLogDB) Capture(listenerDB *ListenerTestDB, protocol fdoshared.FdoToProtocol, cmd fdoshared.FdoCmd, resolveGuid GuidResolver, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqTimestamp := time.Now().UnixNano()
+
+		// Handler gets read error after the captured part, e.g. body exceeding message size limit
+		reqBody, err := fdoshared.ReadMessageBody(w, r, cmd)
+		if err != nil {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(reqBody), errorReader{err: err}))
+		} else {
+			r.Body = io.NopCloser(bytes.NewReader(reqBody))
+		}
+
+		recorder := messageLogRecorder{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		// Message finishing the test run, e.g. Done70, completes the run and ends the session in the handler. Run is taken before it
+		reqSessionId := getBearerSessionId(r.Header)
+		guid := resolveGuid(reqSessionId, reqBody)
+
+		var testcomListener *listenertestsdeps.RequestListenerInst
+		if guid != nil {
+			testcomListener, _ = listenerDB.GetEntryByFdoGuid(*guid)
+		}
+
+		runUuid, runRunning := getRunState(testcomListener, protocol)
+
+		next(&recorder, r)
+
+		// Session is issued in the response of the first message of the protocol
+		sessionId := getBearerSessionId(w.Header())
+		if sessionId == nil {
+			sessionId = reqSessionId
+		}
+
+		if guid == nil {
+			guid = resolveGuid(sessionId, reqBody)
+			if guid == nil {
+				return
+			}
+
+			testcomListener, _ = listenerDB.GetEntryByFdoGuid(*guid)
+			runUuid, runRunning = getRunState(testcomListener, protocol)
+		}
+
+		if CaptureMessages {
+			err = h.captures.Append(*guid, CapturedMessage{
+				Timestamp: reqTimestamp,
+				Protocol:  protocol,
+				Cmd:       cmd,
+				SessionId: string(sessionId),
+				Body:      reqBody,
+			})
+			if err != nil {
+				log.Println("Failed to save message capture. " + err.Error())
+			}
+		}
+
+		if testcomListener == nil {
+			return
+		}
+
+		respCmd := cmd + 1
+		respMsgType, err := strconv.ParseUint(w.Header().Get("Message-Type"), 10, 8)
+		if err == nil {
+			respCmd = fdoshared.FdoCmd(respMsgType)
+		}
+
+		err = h.Append(testcomListener.Uuid,
+			MessageLogEntry{
+				Direction: MSGLOG_DEVICE_TO_SERVER,
+				Timestamp: reqTimestamp,
+				Protocol:  protocol,
+				Cmd:       cmd,
+				SessionId: string(sessionId),
+				Headers:   r.Header.Clone(),
+				Body:      reqBody,
+			},
+			MessageLogEntry{
+				Direction:  MSGLOG_SERVER_TO_DEVICE,
+				Timestamp:  time.Now().UnixNano(),
+				Protocol:   protocol,
+				Cmd:        respCmd,
+				SessionId:  string(sessionId),
+				HttpStatus: recorder.status,
+				Headers:    w.Header().Clone(),
+				Body:       recorder.body.Bytes(),
+			},
+		)
+		if err != nil {
+			log.Println("Failed to save message log. " + err.Error())
+		}
+
+		if runRunning {
+			err = h.metrics.Record(runUuid, cmd, recorder.status)
+			if err != nil {
+				log.Println("Failed to save endpoint metrics. " + err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package dbs
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+type MessageLogDirection string
+
+const (
+	MSGLOG_DEVICE_TO_SERVER MessageLogDirection = "device_to_server"
+	MSGLOG_SERVER_TO_DEVICE MessageLogDirection = "server_to_device"
+)
+
+// Oldest entries are dropped after the limit
+const MSGLOG_MAX_ENTRIES = 2048
+
+type MessageLogEntry struct {
+	Direction  MessageLogDirection     `cbor:"direction"`
+	Timestamp  int64                   `cbor:"timestamp"` // Unix nanoseconds
+	Protocol   fdoshared.FdoToProtocol `cbor:"protocol"`
+	Cmd        fdoshared.FdoCmd        `cbor:"cmd"`
+	SessionId  string                  `cbor:"sessionId,omitempty"`
+	HttpStatus int                     `cbor:"httpStatus,omitempty"`
+	Headers    http.Header             `cbor:"headers"`
+	Body       []byte                  `cbor:"body"`
+}
+
+// MessageLogDB keeps raw FDO messages exchanged with a tested device, per listener test instance
+type MessageLogDB struct {
+	db     *badger.DB
+	prefix []byte
+	ttl    int
+
+	// Captured exchanges are also counted per test run
+	metrics *EndpointMetricsDB
+	// With CaptureMessages, device requests are also kept per GUID
+	captures *CaptureDB
+}
+
+func NewMessageLogDB(db *badger.DB) *MessageLogDB {
+	return &MessageLogDB{
+		db:       db,
+		prefix:   []byte("msglog-"),
+		ttl:      60 * 60 * 24 * 30, // 30 days storage
+		metrics:  NewEndpointMetricsDB(db),
+		captures: NewCaptureDB(db),
+	}
+}
+
+func (h *MessageLogDB) getEntryId(entryUuid []byte) []byte {
+	return append(append([]byte{}, h.prefix...), entryUuid...)
+}
+
+func (h *MessageLogDB) Append(entryUuid []byte, newEntries ...MessageLogEntry) error {
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		var entries []MessageLogEntry
+
+		item, err := dbtxn.Get(h.getEntryId(entryUuid))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return errors.New("Failed locating message log entry. The error is: " + err.Error())
+		}
+
+		if err == nil {
+			itemBytes, err := item.ValueCopy(nil)
+			if err != nil {
+				return errors.New("Failed reading message log entry value. The error is: " + err.Error())
+			}
+
+			err = fdoshared.CborCust.Unmarshal(itemBytes, &entries)
+			if err != nil {
+				return errors.New("Failed cbor decoding message log entry value. The error is: " + err.Error())
+			}
+		}
+
+		entries = append(entries, newEntries...)
+		if len(entries) > MSGLOG_MAX_ENTRIES {
+			entries = entries[len(entries)-MSGLOG_MAX_ENTRIES:]
+		}
+
+		entriesBytes, err := fdoshared.CborCust.Marshal(entries)
+		if err != nil {
+			return errors.New("Failed to marshal message log. The error is: " + err.Error())
+		}
+
+		entry := badger.NewEntry(h.getEntryId(entryUuid), entriesBytes).WithTTL(time.Second * time.Duration(h.ttl))
+		return dbtxn.SetEntry(entry)
+	})
+}
+
+// Get returns empty log when nothing was captured yet
+func (h *MessageLogDB) Get(entryUuid []byte) ([]MessageLogEntry, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	entries := []MessageLogEntry{}
+
+	item, err := dbtxn.Get(h.getEntryId(entryUuid))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return entries, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating message log entry. The error is: " + err.Error())
+	}
+
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading message log entry value. The error is: " + err.Error())
+	}
+
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &entries)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding message log entry value. The error is: " + err.Error())
+	}
+
+	return entries, nil
+}
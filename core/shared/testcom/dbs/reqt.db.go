@@ -1,10 +1,12 @@
 package dbs
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -15,19 +17,45 @@ import (
 )
 
 type RequestTestDB struct {
-	db     *badger.DB
-	prefix []byte
-	ttl    int
+	db              *badger.DB
+	prefix          []byte
+	changesPrefix   []byte
+	onboardedPrefix []byte
+	ttl             int
+	reports         *reportBatcher
+	// Saved reports, for live run streams
+	Results *RunResultHub
 }
 
 func NewRequestTestDB(db *badger.DB) *RequestTestDB {
 	return &RequestTestDB{
-		db:     db,
-		prefix: []byte("rvte-"),
-		ttl:    60 * 60 * 24 * 183, //6months storage
+		db:              db,
+		prefix:          []byte("rvte-"),
+		changesPrefix:   []byte("rvtechanges-"),
+		onboardedPrefix: []byte("rvteonboarded-"),
+		ttl:             60 * 60 * 24 * 183, //6months storage
+		reports:         &reportBatcher{},
+		Results:         NewRunResultHub(),
 	}
 }
 
+type reportRequest struct {
+	rvteid     []byte
+	testID     testcom.FDOTestID
+	testResult testcom.FDOTestState
+	event      RunResultEvent
+	err        error
+	done       chan struct{}
+}
+
+// reportBatcher groups concurrent ReportTest writes. Reports queued while a batch is committing are written together, in the next single transaction
+type reportBatcher struct {
+	mu       sync.Mutex
+	pending  []*reportRequest
+	flushing bool
+	commits  int
+}
+
 func (h *RequestTestDB) Save(rvte reqtestsdeps.RequestTestInst) error {
 	rvteBytes, err := fdoshared.CborCust.Marshal(rvte)
 	if err != nil {
@@ -120,73 +148,381 @@ func (h *RequestTestDB) GetMany(rvtids [][]byte) (*[]reqtestsdeps.RequestTestIns
 	return &rvts, nil
 }
 
+// modify applies fn to the stored entry within a single transaction, and retries on conflicting concurrent updates
+func (h *RequestTestDB) modify(rvteid []byte, fn func(rvte *reqtestsdeps.RequestTestInst)) error {
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		rvteInst, err := h.readRvte(dbtxn, rvteid)
+		if err != nil {
+			return err
+		}
+
+		fn(rvteInst)
+
+		return h.writeRvte(dbtxn, *rvteInst)
+	})
+}
+
+func (h *RequestTestDB) readRvte(dbtxn *badger.Txn, rvteid []byte) (*reqtestsdeps.RequestTestInst, error) {
+	item, err := dbtxn.Get(append(append([]byte{}, h.prefix...), rvteid...))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, fmt.Errorf("The rvte entry with id %s does not exist", hex.EncodeToString(rvteid))
+	} else if err != nil {
+		return nil, errors.New("Failed locating rvte entry. The error is: " + err.Error())
+	}
+
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading rvte entry value. The error is: " + err.Error())
+	}
+
+	var rvteInst reqtestsdeps.RequestTestInst
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &rvteInst)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding rvte entry value. The error is: " + err.Error())
+	}
+
+	return &rvteInst, nil
+}
+
+func (h *RequestTestDB) writeRvte(dbtxn *badger.Txn, rvteInst reqtestsdeps.RequestTestInst) error {
+	rvteBytes, err := fdoshared.CborCust.Marshal(rvteInst)
+	if err != nil {
+		return errors.New("Failed to marshal rvte. The error is: " + err.Error())
+	}
+
+	entry := badger.NewEntry(append(append([]byte{}, h.prefix...), rvteInst.Uuid...), rvteBytes).WithTTL(time.Second * time.Duration(h.ttl))
+	return dbtxn.SetEntry(entry)
+}
+
 func (h *RequestTestDB) StartNewRun(rvteid []byte) {
 	log.Printf("----- Starting New Run For %s -----", hex.EncodeToString(rvteid))
-	rvte, err := h.Get(rvteid)
+	err := h.modify(rvteid, func(rvte *reqtestsdeps.RequestTestInst) {
+		newRVTestRun := reqtestsdeps.NewRVTestRun(rvte.Protocol)
+
+		rvte.InProgress = true
+		rvte.CurrentTestRun = newRVTestRun
+		rvte.TestsHistory = append([]reqtestsdeps.RequestTestRun{newRVTestRun}, rvte.TestsHistory...)
+	})
 	if err != nil {
-		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
 	}
+}
 
-	newRVTestRun := reqtestsdeps.NewRVTestRun(rvte.Protocol)
+// SetRunKexCipherSuite records KEX and cipher suite the current run is executed with
+func (h *RequestTestDB) SetRunKexCipherSuite(rvteid []byte, suite fdoshared.KexCipherSuite) {
+	err := h.modify(rvteid, func(rvte *reqtestsdeps.RequestTestInst) {
+		rvte.CurrentTestRun.KexSuiteName = suite.KexSuiteName
+		rvte.CurrentTestRun.CipherSuiteName = suite.CipherSuiteName
+		rvte.TestsHistory[0] = rvte.CurrentTestRun
+	})
+	if err != nil {
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
+	}
+}
 
-	rvte.InProgress = true
-	rvte.CurrentTestRun = newRVTestRun
-	rvte.TestsHistory = append([]reqtestsdeps.RequestTestRun{newRVTestRun}, rvte.TestsHistory...)
+// SetRunNegotiatedSuite records KEX and cipher suite the owner under test accepted in the current run
+func (h *RequestTestDB) SetRunNegotiatedSuite(rvteid []byte, suite fdoshared.KexCipherSuite) {
+	err := h.modify(rvteid, func(rvte *reqtestsdeps.RequestTestInst) {
+		rvte.CurrentTestRun.NegotiatedKexSuiteName = suite.KexSuiteName
+		rvte.CurrentTestRun.NegotiatedCipherSuiteName = suite.CipherSuiteName
+		rvte.TestsHistory[0] = rvte.CurrentTestRun
+	})
+	if err != nil {
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
+	}
+}
 
-	err = h.Save(*rvte)
+// SetRunReonboardPolicy records reonboard policy the current run expects from the owner
+func (h *RequestTestDB) SetRunReonboardPolicy(rvteid []byte, policy fdoshared.ReonboardPolicy) {
+	err := h.modify(rvteid, func(rvte *reqtestsdeps.RequestTestInst) {
+		rvte.CurrentTestRun.ReonboardPolicy = policy
+		rvte.TestsHistory[0] = rvte.CurrentTestRun
+	})
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
 	}
 }
 
-func (h *RequestTestDB) FinishRun(rvteid []byte) {
-	rvte, err := h.Get(rvteid)
+// AddRunNote records a note on the current run, such as a test skipped for the test instance
+func (h *RequestTestDB) AddRunNote(rvteid []byte, note string) {
+	err := h.modify(rvteid, func(rvte *reqtestsdeps.RequestTestInst) {
+		rvte.CurrentTestRun.Notes = append(rvte.CurrentTestRun.Notes, note)
+		rvte.TestsHistory[0] = rvte.CurrentTestRun
+	})
 	if err != nil {
-		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
 	}
+}
 
-	rvte.InProgress = false
+// SetRunReplayConfig records run config not kept with the test instance, so the current run can be replayed
+func (h *RequestTestDB) SetRunReplayConfig(rvteid []byte, testTimeout time.Duration, suiteGuids fdoshared.FdoGuidList, replayOf string) {
+	err := h.modify(rvteid, func(rvte *reqtestsdeps.RequestTestInst) {
+		rvte.CurrentTestRun.TestTimeout = testTimeout
+		rvte.CurrentTestRun.SuiteGuids = suiteGuids
+		rvte.CurrentTestRun.ReplayOf = replayOf
+		rvte.TestsHistory[0] = rvte.CurrentTestRun
+	})
+	if err != nil {
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
+	}
+}
 
-	err = h.Save(*rvte)
+func (h *RequestTestDB) FinishRun(rvteid []byte) {
+	err := h.modify(rvteid, func(rvte *reqtestsdeps.RequestTestInst) {
+		rvte.InProgress = false
+	})
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
 	}
 
 	log.Printf("----- Finishing Run For %s -----", hex.EncodeToString(rvteid))
 }
 
+// ReportTest saves the test result to the current run, and its state change history. Safe for parallel runners:
+// concurrent reports are batched into a single transaction, and it returns once the result is written. Reports of one caller keep their order
 func (h *RequestTestDB) ReportTest(rvteid []byte, testID testcom.FDOTestID, testResult testcom.FDOTestState) {
-	rvte, err := h.Get(rvteid)
+	report := &reportRequest{
+		rvteid:     rvteid,
+		testID:     testID,
+		testResult: testResult,
+		done:       make(chan struct{}),
+	}
+
+	h.reports.mu.Lock()
+	h.reports.pending = append(h.reports.pending, report)
+	startFlush := !h.reports.flushing
+	h.reports.flushing = true
+	h.reports.mu.Unlock()
+
+	if startFlush {
+		h.flushReports()
+	}
+
+	<-report.done
+	if report.err != nil {
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), report.err.Error())
+	}
+}
+
+// flushReports writes all pending reports. Reports queued meanwhile are flushed by a new goroutine, so the caller is not held up
+func (h *RequestTestDB) flushReports() {
+	h.reports.mu.Lock()
+	batch := h.reports.pending
+	h.reports.pending = nil
+	h.reports.commits++
+	h.reports.mu.Unlock()
+
+	h.writeReports(batch)
+	for _, report := range batch {
+		if report.err == nil {
+			h.Results.Publish(report.event)
+		}
+
+		close(report.done)
+	}
+
+	h.reports.mu.Lock()
+	if len(h.reports.pending) != 0 {
+		go h.flushReports()
+	} else {
+		h.reports.flushing = false
+	}
+	h.reports.mu.Unlock()
+}
+
+func (h *RequestTestDB) writeReports(batch []*reportRequest) {
+	err := fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		rvtes := map[string]*reqtestsdeps.RequestTestInst{}
+		runsChanges := map[string]reqtestsdeps.RunStateChanges{}
+
+		for _, report := range batch {
+			report.err = nil
+
+			rvte, ok := rvtes[string(report.rvteid)]
+			if !ok {
+				var err error
+				rvte, err = h.readRvte(dbtxn, report.rvteid)
+				if err != nil {
+					report.err = err
+					continue
+				}
+
+				rvtes[string(report.rvteid)] = rvte
+			}
+
+			rvte.CurrentTestRun.Tests[report.testID] = report.testResult
+			rvte.TestsHistory[0] = rvte.CurrentTestRun
+
+			testRunId := rvte.CurrentTestRun.Uuid
+			runChanges, ok := runsChanges[testRunId]
+			if !ok {
+				var err error
+				runChanges, err = h.readStateChanges(dbtxn, testRunId)
+				if err != nil {
+					report.err = err
+					continue
+				}
+
+				runsChanges[testRunId] = runChanges
+			}
+
+			runChanges.Append(report.testID, report.testResult)
+
+			report.event = RunResultEvent{
+				RunId:  testRunId,
+				TestId: report.testID,
+				Passed: report.testResult.Passed,
+				Error:  report.testResult.Error,
+			}
+
+			for _, testState := range rvte.CurrentTestRun.Tests {
+				report.event.Totals.Reported++
+				if testState.Passed {
+					report.event.Totals.Passed++
+				} else {
+					report.event.Totals.Failed++
+				}
+			}
+		}
+
+		for _, rvte := range rvtes {
+			err := h.writeRvte(dbtxn, *rvte)
+			if err != nil {
+				return err
+			}
+		}
+
+		for testRunId, runChanges := range runsChanges {
+			err := h.writeStateChanges(dbtxn, testRunId, runChanges)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
+		for _, report := range batch {
+			report.err = err
+		}
+	}
+}
+
+func (h *RequestTestDB) getChangesId(testRunId string) []byte {
+	return append(append([]byte{}, h.changesPrefix...), []byte(testRunId)...)
+}
+
+func (h *RequestTestDB) readStateChanges(dbtxn *badger.Txn, testRunId string) (reqtestsdeps.RunStateChanges, error) {
+	changes := reqtestsdeps.RunStateChanges{}
+
+	item, err := dbtxn.Get(h.getChangesId(testRunId))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return changes, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating test state changes entry. The error is: " + err.Error())
 	}
 
-	rvte.CurrentTestRun.Tests[testID] = testResult
-	rvte.TestsHistory[0] = rvte.CurrentTestRun
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading test state changes entry value. The error is: " + err.Error())
+	}
 
-	err = h.Save(*rvte)
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &changes)
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		return nil, errors.New("Failed cbor decoding test state changes entry value. The error is: " + err.Error())
 	}
+
+	return changes, nil
 }
 
-func (h *RequestTestDB) RemoveTestRun(rvteid []byte, testRunId string) {
-	rvte, err := h.Get(rvteid)
+func (h *RequestTestDB) writeStateChanges(dbtxn *badger.Txn, testRunId string, changes reqtestsdeps.RunStateChanges) error {
+	changesBytes, err := fdoshared.CborCust.Marshal(changes)
 	if err != nil {
-		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
+		return errors.New("Failed to marshal test state changes. The error is: " + err.Error())
 	}
 
-	var updatedTestsHistory []reqtestsdeps.RequestTestRun = []reqtestsdeps.RequestTestRun{}
-	for _, testRunEntry := range rvte.TestsHistory {
-		if testRunEntry.Uuid != testRunId {
-			updatedTestsHistory = append(updatedTestsHistory, testRunEntry)
+	entry := badger.NewEntry(h.getChangesId(testRunId), changesBytes).WithTTL(time.Second * time.Duration(h.ttl))
+	return dbtxn.SetEntry(entry)
+}
+
+// GetStateChanges returns every reported result of the run tests. Empty when nothing was reported yet
+func (h *RequestTestDB) GetStateChanges(testRunId string) (reqtestsdeps.RunStateChanges, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	return h.readStateChanges(dbtxn, testRunId)
+}
+
+func (h *RequestTestDB) RemoveTestRun(rvteid []byte, testRunId string) {
+	err := h.modify(rvteid, func(rvte *reqtestsdeps.RequestTestInst) {
+		var updatedTestsHistory []reqtestsdeps.RequestTestRun = []reqtestsdeps.RequestTestRun{}
+		for _, testRunEntry := range rvte.TestsHistory {
+			if testRunEntry.Uuid != testRunId {
+				updatedTestsHistory = append(updatedTestsHistory, testRunEntry)
+			}
 		}
+
+		rvte.TestsHistory = updatedTestsHistory
+	})
+	if err != nil {
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
+		return
+	}
+
+	err = h.db.Update(func(dbtxn *badger.Txn) error {
+		return dbtxn.Delete(h.getChangesId(testRunId))
+	})
+	if err != nil {
+		log.Printf("%s error removing test state changes. %s", hex.EncodeToString(rvteid), err.Error())
+	}
+}
+
+func (h *RequestTestDB) getOnboardedId(rvteid []byte, guid fdoshared.FdoGuid) []byte {
+	return append(append(append([]byte{}, h.onboardedPrefix...), rvteid...), guid[:]...)
+}
+
+// SetGuidOnboarded records that the device completed TO2 with the owner under test
+func (h *RequestTestDB) SetGuidOnboarded(rvteid []byte, guid fdoshared.FdoGuid) error {
+	onboardedBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(onboardedBytes, uint64(time.Now().Unix()))
+
+	dbtxn := h.db.NewTransaction(true)
+	defer dbtxn.Discard()
+
+	entry := badger.NewEntry(h.getOnboardedId(rvteid, guid), onboardedBytes).WithTTL(time.Second * time.Duration(h.ttl))
+	err := dbtxn.SetEntry(entry)
+	if err != nil {
+		return errors.New("Failed creating rvte onboarded entry instance. The error is: " + err.Error())
 	}
 
-	rvte.TestsHistory = updatedTestsHistory
+	err = dbtxn.Commit()
+	if err != nil {
+		return errors.New("Failed saving rvte onboarded entry. The error is: " + err.Error())
+	}
+
+	return nil
+}
+
+// GetGuidOnboardedAt returns Unix seconds when the device completed TO2 with the owner under test, 0 when it did not
+func (h *RequestTestDB) GetGuidOnboardedAt(rvteid []byte, guid fdoshared.FdoGuid) (int64, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
 
-	err = h.Save(*rvte)
+	item, err := dbtxn.Get(h.getOnboardedId(rvteid, guid))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.New("Failed locating rvte onboarded entry. The error is: " + err.Error())
+	}
+
+	onboardedBytes, err := item.ValueCopy(nil)
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		return 0, errors.New("Failed reading rvte onboarded entry value. The error is: " + err.Error())
 	}
+
+	if len(onboardedBytes) != 8 {
+		return 0, errors.New("invalid rvte onboarded entry length")
+	}
+
+	return int64(binary.BigEndian.Uint64(onboardedBytes)), nil
 }
@@ -0,0 +1,485 @@
+package dbs
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+func TestRequestTestDB_ConcurrentReportTest(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	prevRetries := fdoshared.DbConflictRetries
+	fdoshared.DbConflictRetries = 100
+	defer func() { fdoshared.DbConflictRetries = prevRetries }()
+
+	reqtDB := NewRequestTestDB(db)
+
+	rvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+	err = reqtDB.Save(rvte)
+	if err != nil {
+		t.Fatalf("Failed to save test entry. %s", err.Error())
+	}
+
+	reqtDB.StartNewRun(rvte.Uuid)
+
+	const workers = 32
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			testId := testcom.FDOTestID(fmt.Sprintf("TEST_%d", i))
+			reqtDB.ReportTest(rvte.Uuid, testId, testcom.NewSuccessTestState(testId))
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	result, err := reqtDB.Get(rvte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test entry. %s", err.Error())
+	}
+
+	if len(result.CurrentTestRun.Tests) != workers {
+		t.Errorf("Expected %d reported tests, got %d", workers, len(result.CurrentTestRun.Tests))
+	}
+
+	if len(result.TestsHistory) != 1 || len(result.TestsHistory[0].Tests) != workers {
+		t.Errorf("Expected test history to contain all %d reported tests", workers)
+	}
+}
+
+func TestRequestTestDB_StateChanges(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	rvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+	err = reqtDB.Save(rvte)
+	if err != nil {
+		t.Fatalf("Failed to save test entry. %s", err.Error())
+	}
+
+	reqtDB.StartNewRun(rvte.Uuid)
+
+	flakyId := testcom.FDOTestID("TEST_FLAKY")
+	stableId := testcom.FDOTestID("TEST_STABLE")
+
+	reqtDB.ReportTest(rvte.Uuid, flakyId, testcom.NewFailTestState(flakyId, "first attempt failed"))
+	reqtDB.ReportTest(rvte.Uuid, flakyId, testcom.NewSuccessTestState(flakyId))
+	for i := 0; i < reqtestsdeps.MAX_STATE_CHANGES_PER_TEST+5; i++ {
+		reqtDB.ReportTest(rvte.Uuid, stableId, testcom.NewSuccessTestState(stableId))
+	}
+
+	result, err := reqtDB.Get(rvte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test entry. %s", err.Error())
+	}
+
+	changes, err := reqtDB.GetStateChanges(result.CurrentTestRun.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get state changes. %s", err.Error())
+	}
+
+	flakyChanges := changes[flakyId]
+	if len(flakyChanges) != 2 || flakyChanges[0].Passed || !flakyChanges[1].Passed {
+		t.Errorf("Expected fail then pass for %s, got %v", flakyId, flakyChanges)
+	}
+
+	if flakyChanges[0].Error != "first attempt failed" {
+		t.Errorf("Expected fail error to be recorded, got %s", flakyChanges[0].Error)
+	}
+
+	if len(changes[stableId]) != reqtestsdeps.MAX_STATE_CHANGES_PER_TEST {
+		t.Errorf("Expected %d state changes for %s, got %d", reqtestsdeps.MAX_STATE_CHANGES_PER_TEST, stableId, len(changes[stableId]))
+	}
+
+	flaky := changes.Flaky()
+	if len(flaky) != 1 || flaky[0] != flakyId {
+		t.Errorf("Expected only %s to be flaky, got %v", flakyId, flaky)
+	}
+
+	reqtDB.RemoveTestRun(rvte.Uuid, result.CurrentTestRun.Uuid)
+
+	changes, err = reqtDB.GetStateChanges(result.CurrentTestRun.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get state changes. %s", err.Error())
+	}
+
+	if len(changes) != 0 {
+		t.Errorf("Expected state changes to be removed with the run")
+	}
+}
+
+func TestRequestTestDB_BatchedReportTest(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	rvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+	err = reqtDB.Save(rvte)
+	if err != nil {
+		t.Fatalf("Failed to save test entry. %s", err.Error())
+	}
+
+	reqtDB.StartNewRun(rvte.Uuid)
+
+	// Holding the batch, as if a commit is in progress
+	reqtDB.reports.mu.Lock()
+	reqtDB.reports.flushing = true
+	reqtDB.reports.mu.Unlock()
+
+	const reports = 16
+
+	var wg sync.WaitGroup
+	for i := 0; i < reports; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			testId := testcom.FDOTestID(fmt.Sprintf("TEST_%d", i))
+			reqtDB.ReportTest(rvte.Uuid, testId, testcom.NewSuccessTestState(testId))
+		}(i)
+	}
+
+	for {
+		reqtDB.reports.mu.Lock()
+		pending := len(reqtDB.reports.pending)
+		reqtDB.reports.mu.Unlock()
+
+		if pending == reports {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	reqtDB.flushReports()
+	wg.Wait()
+
+	if reqtDB.reports.commits != 1 {
+		t.Errorf("Expected all reports written in 1 transaction, got %d", reqtDB.reports.commits)
+	}
+
+	result, err := reqtDB.Get(rvte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test entry. %s", err.Error())
+	}
+
+	if len(result.CurrentTestRun.Tests) != reports {
+		t.Errorf("Expected %d reported tests, got %d", reports, len(result.CurrentTestRun.Tests))
+	}
+
+	changes, err := reqtDB.GetStateChanges(result.CurrentTestRun.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get state changes. %s", err.Error())
+	}
+
+	if len(changes) != reports {
+		t.Errorf("Expected state changes of %d tests, got %d", reports, len(changes))
+	}
+
+	// Reports of one caller keep their order
+	orderedId := testcom.FDOTestID("TEST_ORDERED")
+	reqtDB.ReportTest(rvte.Uuid, orderedId, testcom.NewFailTestState(orderedId, "failed"))
+	reqtDB.ReportTest(rvte.Uuid, orderedId, testcom.NewSuccessTestState(orderedId))
+
+	result, err = reqtDB.Get(rvte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test entry. %s", err.Error())
+	}
+
+	if !result.CurrentTestRun.Tests[orderedId].Passed {
+		t.Errorf("Expected the last reported result to be saved")
+	}
+}
+
+// Reports txns/report. Parallel runners share transactions, where sequential ones write one per report
+func BenchmarkRequestTestDB_ReportTest(b *testing.B) {
+	for _, parallel := range []bool{false, true} {
+		name := "sequential"
+		if parallel {
+			name = "parallel"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+			if err != nil {
+				b.Fatalf("Failed to open in-memory db. %s", err.Error())
+			}
+			defer db.Close()
+
+			reqtDB := NewRequestTestDB(db)
+
+			rvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+			err = reqtDB.Save(rvte)
+			if err != nil {
+				b.Fatalf("Failed to save test entry. %s", err.Error())
+			}
+
+			reqtDB.StartNewRun(rvte.Uuid)
+
+			testId := testcom.FDOTestID("TEST_BENCH")
+			testState := testcom.NewSuccessTestState(testId)
+
+			b.ResetTimer()
+			if parallel {
+				b.SetParallelism(8)
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						reqtDB.ReportTest(rvte.Uuid, testId, testState)
+					}
+				})
+			} else {
+				for i := 0; i < b.N; i++ {
+					reqtDB.ReportTest(rvte.Uuid, testId, testState)
+				}
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(reqtDB.reports.commits)/float64(b.N), "txns/report")
+		})
+	}
+}
+
+func TestRequestTestDB_SetRunKexCipherSuite(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	rvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+	err = reqtDB.Save(rvte)
+	if err != nil {
+		t.Fatalf("Failed to save test entry. %s", err.Error())
+	}
+
+	reqtDB.StartNewRun(rvte.Uuid)
+	reqtDB.SetRunKexCipherSuite(rvte.Uuid, fdoshared.KexCipherSuite{KexSuiteName: fdoshared.KEX_ECDH384, CipherSuiteName: fdoshared.CIPHER_AES_CCM_16_128_256})
+	reqtDB.ReportTest(rvte.Uuid, testcom.FIDO_DOT_60_POSITIVE, testcom.NewSuccessTestState(testcom.FIDO_DOT_60_POSITIVE))
+
+	result, err := reqtDB.Get(rvte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test entry. %s", err.Error())
+	}
+
+	for _, testRun := range []reqtestsdeps.RequestTestRun{result.CurrentTestRun, result.TestsHistory[0]} {
+		if testRun.KexSuiteName != fdoshared.KEX_ECDH384 || testRun.CipherSuiteName != fdoshared.CIPHER_AES_CCM_16_128_256 {
+			t.Errorf("Expected run suite ECDH384 and %d. Got %s and %d", fdoshared.CIPHER_AES_CCM_16_128_256, testRun.KexSuiteName, testRun.CipherSuiteName)
+		}
+
+		if len(testRun.Tests) != 1 {
+			t.Errorf("Expected reported test to be kept with the suite")
+		}
+	}
+
+	// Next run without selected suite
+	reqtDB.StartNewRun(rvte.Uuid)
+
+	result, err = reqtDB.Get(rvte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test entry. %s", err.Error())
+	}
+
+	if result.CurrentTestRun.KexSuiteName != "" || result.TestsHistory[1].KexSuiteName != fdoshared.KEX_ECDH384 {
+		t.Errorf("Expected suite to be recorded only for the first run")
+	}
+}
+
+func TestRequestTestDB_SetRunNegotiatedSuite(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	rvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+	err = reqtDB.Save(rvte)
+	if err != nil {
+		t.Fatalf("Failed to save test entry. %s", err.Error())
+	}
+
+	// Run without selected suite, owner accepts the one the device picked
+	reqtDB.StartNewRun(rvte.Uuid)
+	reqtDB.SetRunNegotiatedSuite(rvte.Uuid, fdoshared.KexCipherSuite{KexSuiteName: fdoshared.KEX_ECDH256, CipherSuiteName: fdoshared.CIPHER_A128GCM})
+	reqtDB.ReportTest(rvte.Uuid, testcom.FIDO_DOT_64_POSITIVE, testcom.NewSuccessTestState(testcom.FIDO_DOT_64_POSITIVE))
+	reqtDB.FinishRun(rvte.Uuid)
+
+	result, err := reqtDB.Get(rvte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test entry. %s", err.Error())
+	}
+
+	testRun := result.TestsHistory[0]
+	if testRun.NegotiatedKexSuiteName != fdoshared.KEX_ECDH256 || testRun.NegotiatedCipherSuiteName != fdoshared.CIPHER_A128GCM {
+		t.Errorf("Expected negotiated suite ECDH256 and %d. Got %s and %d", fdoshared.CIPHER_A128GCM, testRun.NegotiatedKexSuiteName, testRun.NegotiatedCipherSuiteName)
+	}
+
+	if testRun.KexSuiteName != "" || !testRun.Tests[testcom.FIDO_DOT_64_POSITIVE].Passed {
+		t.Errorf("Expected passed run without selected suite. Got %+v", testRun)
+	}
+}
+
+func TestRequestTestDB_SetRunReplayConfig(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	rvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+	err = reqtDB.Save(rvte)
+	if err != nil {
+		t.Fatalf("Failed to save test entry. %s", err.Error())
+	}
+
+	suiteGuids := fdoshared.FdoGuidList{fdoshared.NewFdoGuid(), fdoshared.NewFdoGuid()}
+
+	reqtDB.StartNewRun(rvte.Uuid)
+	reqtDB.SetRunReplayConfig(rvte.Uuid, 90*time.Second, suiteGuids, "original-run")
+	reqtDB.ReportTest(rvte.Uuid, testcom.FIDO_DOT_60_POSITIVE, testcom.NewSuccessTestState(testcom.FIDO_DOT_60_POSITIVE))
+	reqtDB.ReportTest(rvte.Uuid, testcom.FIDO_DOT_62_POSITIVE, testcom.NewFailTestState(testcom.FIDO_DOT_62_POSITIVE, "failed"))
+
+	result, err := reqtDB.Get(rvte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test entry. %s", err.Error())
+	}
+
+	testRun := result.TestsHistory[0]
+	if testRun.TestTimeout != 90*time.Second || len(testRun.SuiteGuids) != 2 || testRun.SuiteGuids[1] != suiteGuids[1] || testRun.ReplayOf != "original-run" {
+		t.Errorf("Expected stored replay config. Got %s, %d guids, replay of %s", testRun.TestTimeout, len(testRun.SuiteGuids), testRun.ReplayOf)
+	}
+
+	failedTests := testRun.GetFailedTestIDs()
+	if len(failedTests) != 1 || failedTests[0] != testcom.FIDO_DOT_62_POSITIVE {
+		t.Errorf("Expected only %s failed. Got %v", testcom.FIDO_DOT_62_POSITIVE, failedTests)
+	}
+
+	rvte.OnlyTests = failedTests
+	if rvte.ShouldRun(testcom.FIDO_DOT_60_POSITIVE) || !rvte.ShouldRun(testcom.FIDO_DOT_62_POSITIVE) {
+		t.Errorf("Expected replay to run only failed tests")
+	}
+
+	rvte.OnlyTests = nil
+	if !rvte.ShouldRun(testcom.FIDO_DOT_60_POSITIVE) {
+		t.Errorf("Expected all tests to run without OnlyTests")
+	}
+}
+
+func TestRequestTestDB_ResultStream(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	rvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+	err = reqtDB.Save(rvte)
+	if err != nil {
+		t.Fatalf("Failed to save test entry. %s", err.Error())
+	}
+
+	reqtDB.StartNewRun(rvte.Uuid)
+
+	started, err := reqtDB.Get(rvte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test entry. %s", err.Error())
+	}
+	testRunId := started.CurrentTestRun.Uuid
+
+	stream := reqtDB.Results.Subscribe(testRunId)
+	defer reqtDB.Results.Unsubscribe(stream)
+
+	otherStream := reqtDB.Results.Subscribe("other-run")
+	defer reqtDB.Results.Unsubscribe(otherStream)
+
+	reqtDB.ReportTest(rvte.Uuid, "TEST_A", testcom.NewSuccessTestState("TEST_A"))
+	reqtDB.ReportTest(rvte.Uuid, "TEST_B", testcom.FDOTestState{Passed: false, Error: "bad"})
+
+	expected := []RunResultEvent{
+		{RunId: testRunId, TestId: "TEST_A", Passed: true, Totals: RunResultTotals{Reported: 1, Passed: 1}},
+		{RunId: testRunId, TestId: "TEST_B", Passed: false, Error: "bad", Totals: RunResultTotals{Reported: 2, Passed: 1, Failed: 1}},
+	}
+
+	for _, expectedEvent := range expected {
+		select {
+		case event := <-stream.Results:
+			if event != expectedEvent {
+				t.Errorf("Expected event %+v. Got %+v", expectedEvent, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected event for %s", expectedEvent.TestId)
+		}
+	}
+
+	if len(otherStream.Results) != 0 {
+		t.Errorf("Expected no events for other run")
+	}
+}
+
+func TestRequestTestDB_GuidOnboarded(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	rvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+	otherRvte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2)
+	guid := fdoshared.NewFdoGuid()
+
+	err = reqtDB.SetGuidOnboarded(rvte.Uuid, guid)
+	if err != nil {
+		t.Fatalf("Failed to record onboarded device. %s", err.Error())
+	}
+
+	onboardedAt, err := reqtDB.GetGuidOnboardedAt(rvte.Uuid, guid)
+	if err != nil || onboardedAt == 0 {
+		t.Errorf("Expected device to be onboarded. Got %d, %v", onboardedAt, err)
+	}
+
+	onboardedAt, err = reqtDB.GetGuidOnboardedAt(otherRvte.Uuid, guid)
+	if err != nil || onboardedAt != 0 {
+		t.Errorf("Expected device not to be onboarded by other test instance. Got %d, %v", onboardedAt, err)
+	}
+
+	onboardedAt, err = reqtDB.GetGuidOnboardedAt(rvte.Uuid, fdoshared.NewFdoGuid())
+	if err != nil || onboardedAt != 0 {
+		t.Errorf("Expected other device not to be onboarded. Got %d, %v", onboardedAt, err)
+	}
+}
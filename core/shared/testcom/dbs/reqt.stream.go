@@ -0,0 +1,77 @@
+package dbs
+
+import (
+	"sync"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// Max buffered results per run stream. Results are dropped for streams that do not keep up, so reporting never blocks
+const RUN_STREAM_BUFFER = 256
+
+type RunResultTotals struct {
+	Reported int `json:"reported"`
+	Passed   int `json:"passed"`
+	Failed   int `json:"failed"`
+}
+
+// RunResultEvent is a test result reported to a run, with run totals including it
+type RunResultEvent struct {
+	RunId  string            `json:"runId"`
+	TestId testcom.FDOTestID `json:"testId"`
+	Passed bool              `json:"passed"`
+	Error  string            `json:"error,omitempty"`
+	Totals RunResultTotals   `json:"totals"`
+}
+
+type RunResultStream struct {
+	Results chan RunResultEvent
+	runId   string
+}
+
+// RunResultHub fans out saved test results to live streams of their run
+type RunResultHub struct {
+	mu      sync.Mutex
+	streams map[*RunResultStream]bool
+}
+
+func NewRunResultHub() *RunResultHub {
+	return &RunResultHub{
+		streams: map[*RunResultStream]bool{},
+	}
+}
+
+func (h *RunResultHub) Publish(event RunResultEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for stream := range h.streams {
+		if stream.runId != event.RunId {
+			continue
+		}
+
+		select {
+		case stream.Results <- event:
+		default:
+		}
+	}
+}
+
+func (h *RunResultHub) Subscribe(runId string) *RunResultStream {
+	stream := &RunResultStream{
+		Results: make(chan RunResultEvent, RUN_STREAM_BUFFER),
+		runId:   runId,
+	}
+
+	h.mu.Lock()
+	h.streams[stream] = true
+	h.mu.Unlock()
+
+	return stream
+}
+
+func (h *RunResultHub) Unsubscribe(stream *RunResultStream) {
+	h.mu.Lock()
+	delete(h.streams, stream)
+	h.mu.Unlock()
+}
@@ -0,0 +1,106 @@
+package dbs
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// PinnedRuns is a set of test run ids exempt from run retention
+type PinnedRuns map[string]bool
+
+// Ids returns sorted run ids
+func (h PinnedRuns) Ids() []string {
+	ids := make([]string, 0, len(h))
+	for id := range h {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// RunPinDB keeps pinned test runs per user
+type RunPinDB struct {
+	db     *badger.DB
+	prefix []byte
+	ttl    int
+}
+
+func NewRunPinDB(db *badger.DB) *RunPinDB {
+	return &RunPinDB{
+		db:     db,
+		prefix: []byte("runpin-"),
+		ttl:    60 * 60 * 24 * 183, // Same as rvte storage
+	}
+}
+
+func (h *RunPinDB) getEntryId(ownerId []byte) []byte {
+	return append(append([]byte{}, h.prefix...), ownerId...)
+}
+
+func (h *RunPinDB) readPins(dbtxn *badger.Txn, ownerId []byte) (PinnedRuns, error) {
+	pins := PinnedRuns{}
+
+	item, err := dbtxn.Get(h.getEntryId(ownerId))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return pins, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating pinned runs entry. The error is: " + err.Error())
+	}
+
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading pinned runs entry value. The error is: " + err.Error())
+	}
+
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &pins)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding pinned runs entry value. The error is: " + err.Error())
+	}
+
+	return pins, nil
+}
+
+func (h *RunPinDB) modify(ownerId []byte, fn func(pins PinnedRuns)) error {
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		pins, err := h.readPins(dbtxn, ownerId)
+		if err != nil {
+			return err
+		}
+
+		fn(pins)
+
+		pinsBytes, err := fdoshared.CborCust.Marshal(pins)
+		if err != nil {
+			return errors.New("Failed to marshal pinned runs. The error is: " + err.Error())
+		}
+
+		entry := badger.NewEntry(h.getEntryId(ownerId), pinsBytes).WithTTL(time.Second * time.Duration(h.ttl))
+		return dbtxn.SetEntry(entry)
+	})
+}
+
+func (h *RunPinDB) Pin(ownerId []byte, runId string) error {
+	return h.modify(ownerId, func(pins PinnedRuns) {
+		pins[runId] = true
+	})
+}
+
+func (h *RunPinDB) Unpin(ownerId []byte, runId string) error {
+	return h.modify(ownerId, func(pins PinnedRuns) {
+		delete(pins, runId)
+	})
+}
+
+// Get returns empty set when nothing was pinned yet
+func (h *RunPinDB) Get(ownerId []byte) (PinnedRuns, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	return h.readPins(dbtxn, ownerId)
+}
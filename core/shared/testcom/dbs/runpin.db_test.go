@@ -0,0 +1,49 @@
+package dbs
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestRunPinDB_PinUnpin(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	pinDB := NewRunPinDB(db)
+	ownerA := []byte("a@example.com")
+	ownerB := []byte("b@example.com")
+
+	for _, runId := range []string{"run-2", "run-1"} {
+		err = pinDB.Pin(ownerA, runId)
+		if err != nil {
+			t.Fatalf("Failed to pin run. %s", err.Error())
+		}
+	}
+
+	err = pinDB.Unpin(ownerA, "run-2")
+	if err != nil {
+		t.Fatalf("Failed to unpin run. %s", err.Error())
+	}
+
+	pins, err := pinDB.Get(ownerA)
+	if err != nil {
+		t.Fatalf("Failed to get pinned runs. %s", err.Error())
+	}
+
+	if len(pins.Ids()) != 1 || !pins["run-1"] {
+		t.Errorf("Expected only run-1 pinned, got %v", pins.Ids())
+	}
+
+	pins, err = pinDB.Get(ownerB)
+	if err != nil {
+		t.Fatalf("Failed to get pinned runs. %s", err.Error())
+	}
+
+	if len(pins) != 0 {
+		t.Errorf("Expected no pinned runs for other owner, got %v", pins.Ids())
+	}
+}
@@ -0,0 +1,143 @@
+package dbs
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+const VOUCHER_TAG_MAX_LEN = 64
+
+// VoucherTags maps a suite tag, e.g. "mandatory" or "rsa-devices", to the voucher GUIDs tagged with it
+type VoucherTags map[string]fdoshared.FdoGuidList
+
+// Names returns sorted tag names
+func (h VoucherTags) Names() []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// NormalizeVoucherTag trims and lowercases tag, so "RSA Devices" and "rsa devices" are the same suite
+func NormalizeVoucherTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if len(tag) == 0 {
+		return "", errors.New("tag is empty")
+	}
+
+	if len(tag) > VOUCHER_TAG_MAX_LEN {
+		return "", errors.New("tag is too long")
+	}
+
+	return tag, nil
+}
+
+// VoucherTagDB keeps voucher tags per DOT test instance
+type VoucherTagDB struct {
+	db     *badger.DB
+	prefix []byte
+	ttl    int
+}
+
+func NewVoucherTagDB(db *badger.DB) *VoucherTagDB {
+	return &VoucherTagDB{
+		db:     db,
+		prefix: []byte("vtag-"),
+		ttl:    60 * 60 * 24 * 183, // Same as rvte storage
+	}
+}
+
+func (h *VoucherTagDB) getEntryId(entryUuid []byte) []byte {
+	return append(append([]byte{}, h.prefix...), entryUuid...)
+}
+
+func (h *VoucherTagDB) readTags(dbtxn *badger.Txn, entryUuid []byte) (VoucherTags, error) {
+	tags := VoucherTags{}
+
+	item, err := dbtxn.Get(h.getEntryId(entryUuid))
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return tags, nil
+	} else if err != nil {
+		return nil, errors.New("Failed locating voucher tags entry. The error is: " + err.Error())
+	}
+
+	itemBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading voucher tags entry value. The error is: " + err.Error())
+	}
+
+	err = fdoshared.CborCust.Unmarshal(itemBytes, &tags)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding voucher tags entry value. The error is: " + err.Error())
+	}
+
+	return tags, nil
+}
+
+func (h *VoucherTagDB) modify(entryUuid []byte, fn func(tags VoucherTags)) error {
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		tags, err := h.readTags(dbtxn, entryUuid)
+		if err != nil {
+			return err
+		}
+
+		fn(tags)
+
+		tagsBytes, err := fdoshared.CborCust.Marshal(tags)
+		if err != nil {
+			return errors.New("Failed to marshal voucher tags. The error is: " + err.Error())
+		}
+
+		entry := badger.NewEntry(h.getEntryId(entryUuid), tagsBytes).WithTTL(time.Second * time.Duration(h.ttl))
+		return dbtxn.SetEntry(entry)
+	})
+}
+
+// Tag adds guids to the tag. Already tagged guids are ignored
+func (h *VoucherTagDB) Tag(entryUuid []byte, tag string, guids fdoshared.FdoGuidList) error {
+	return h.modify(entryUuid, func(tags VoucherTags) {
+		tagged := tags[tag]
+		for _, guid := range guids {
+			if !tagged.Contains(guid) {
+				tagged = append(tagged, guid)
+			}
+		}
+
+		tags[tag] = tagged
+	})
+}
+
+// Untag removes guids from the tag. The tag is dropped once it has no vouchers left
+func (h *VoucherTagDB) Untag(entryUuid []byte, tag string, guids fdoshared.FdoGuidList) error {
+	return h.modify(entryUuid, func(tags VoucherTags) {
+		tagged := fdoshared.FdoGuidList{}
+		for _, guid := range tags[tag] {
+			if !guids.Contains(guid) {
+				tagged = append(tagged, guid)
+			}
+		}
+
+		if len(tagged) == 0 {
+			delete(tags, tag)
+		} else {
+			tags[tag] = tagged
+		}
+	})
+}
+
+// Get returns empty tags when none were set yet
+func (h *VoucherTagDB) Get(entryUuid []byte) (VoucherTags, error) {
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	return h.readTags(dbtxn, entryUuid)
+}
@@ -0,0 +1,73 @@
+package dbs
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestVoucherTagDB_TagUntag(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	tagDB := NewVoucherTagDB(db)
+	entryUuid := []byte("dot-inst")
+
+	guidA := fdoshared.NewFdoGuid()
+	guidB := fdoshared.NewFdoGuid()
+
+	err = tagDB.Tag(entryUuid, "mandatory", fdoshared.FdoGuidList{guidA, guidB})
+	if err != nil {
+		t.Fatalf("Failed to tag vouchers. %s", err.Error())
+	}
+
+	err = tagDB.Tag(entryUuid, "mandatory", fdoshared.FdoGuidList{guidA})
+	if err != nil {
+		t.Fatalf("Failed to tag vouchers. %s", err.Error())
+	}
+
+	tags, err := tagDB.Get(entryUuid)
+	if err != nil {
+		t.Fatalf("Failed to get tags. %s", err.Error())
+	}
+
+	if len(tags["mandatory"]) != 2 {
+		t.Errorf("Expected 2 vouchers tagged, got %d", len(tags["mandatory"]))
+	}
+
+	err = tagDB.Untag(entryUuid, "mandatory", fdoshared.FdoGuidList{guidA})
+	if err != nil {
+		t.Fatalf("Failed to untag vouchers. %s", err.Error())
+	}
+
+	tags, _ = tagDB.Get(entryUuid)
+	if len(tags["mandatory"]) != 1 || !tags["mandatory"][0].Equals(guidB) {
+		t.Errorf("Expected only second voucher left tagged. Got %v", tags["mandatory"])
+	}
+
+	err = tagDB.Untag(entryUuid, "mandatory", fdoshared.FdoGuidList{guidB})
+	if err != nil {
+		t.Fatalf("Failed to untag vouchers. %s", err.Error())
+	}
+
+	tags, _ = tagDB.Get(entryUuid)
+	if len(tags.Names()) != 0 {
+		t.Errorf("Expected empty tag to be dropped. Got %v", tags.Names())
+	}
+}
+
+func TestNormalizeVoucherTag(t *testing.T) {
+	tag, err := NormalizeVoucherTag("  RSA Devices ")
+	if err != nil || tag != "rsa devices" {
+		t.Errorf("Expected \"rsa devices\", got %q, %v", tag, err)
+	}
+
+	_, err = NormalizeVoucherTag("   ")
+	if err == nil {
+		t.Errorf("Expected error for empty tag")
+	}
+}
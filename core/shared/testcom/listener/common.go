@@ -8,7 +8,7 @@ import (
 )
 
 func Conf_RespondFDOError(w http.ResponseWriter, r *http.Request, errorCode fdoshared.FdoErrorCode, prevMsgId fdoshared.FdoCmd, messageStr string, httpStatusCode int, testcomListener *RequestListenerInst, fdoProtocol fdoshared.FdoToProtocol) {
-	log.Printf("Err: %d %s %d", prevMsgId, messageStr, errorCode)
+	fdoshared.NewMessageLogger(fdoProtocol, prevMsgId, r).Warnf("Responding error %d. %s", errorCode, messageStr)
 
 	if testcomListener != nil {
 		switch fdoProtocol {
@@ -23,3 +23,20 @@ func Conf_RespondFDOError(w http.ResponseWriter, r *http.Request, errorCode fdos
 
 	fdoshared.RespondFDOError(w, r, errorCode, prevMsgId, messageStr, httpStatusCode)
 }
+
+// Conf_CheckTLSDeviceCert runs TLS client certificate check, when enabled with VERIFY_TLS_DEVICE_CERT. Mismatch is logged and recorded as observation, and does not stop the protocol.
+// Returns true when testcomListener was changed and needs saving
+func Conf_CheckTLSDeviceCert(r *http.Request, ovDevCertChain *[]fdoshared.X509CertificateBytes, testcomListener *RequestListenerInst, fdoProtocol fdoshared.FdoToProtocol) bool {
+	if !fdoshared.VerifyTLSDeviceCert {
+		return false
+	}
+
+	err := fdoshared.CheckTLSDeviceCert(r, ovDevCertChain)
+	if err == nil {
+		return false
+	}
+
+	log.Printf("TLS device certificate mismatch. %s", err.Error())
+
+	return testcomListener != nil && testcomListener.Conf_RecordTLSDeviceCertMismatch(fdoProtocol, err)
+}
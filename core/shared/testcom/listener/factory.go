@@ -10,11 +10,14 @@ func NewDevice_RequestListenerInst(voucherEntry fdoshared.VoucherDBEntry, guid f
 	newUuid, _ := uuid.NewRandom()
 	uuidBytes, _ := newUuid.MarshalBinary()
 
+	ovHeader, _ := voucherEntry.Voucher.GetOVHeader()
+
 	return RequestListenerInst{
 		Uuid:        uuidBytes,
 		Guid:        guid,
 		TestVoucher: voucherEntry,
 		Type:        fdoshared.Device,
+		RVBypass:    ovHeader.OVRvInfo.HasBypass(),
 		To1: RequestListenerRunnerInst{
 			Protocol: fdoshared.To1,
 			Tests: map[fdoshared.FdoCmd][]testcom.FDOTestID{
@@ -40,6 +43,7 @@ func NewDevice_RequestListenerInst(voucherEntry fdoshared.VoucherDBEntry, guid f
 	}
 }
 
+// NewDO_RequestListenerInst creates listener of the DO under test, as owner client registering the voucher with RV
 func NewDO_RequestListenerInst(voucherEntry fdoshared.VoucherDBEntry, guid fdoshared.FdoGuid) RequestListenerInst {
 	newUuid, _ := uuid.NewRandom()
 	uuidBytes, _ := newUuid.MarshalBinary()
@@ -1,6 +1,7 @@
 package listener
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log"
 
@@ -20,6 +21,9 @@ type RequestListenerRunnerInst struct {
 	Completed        bool                                     `cbor:"completed,omitempty"`
 	CurrentTestRun   ListenerTestRun                          `cbor:"currentTestRun,omitempty"`
 	TestRunHistory   []ListenerTestRun                        `cbor:"testRunHistory,omitempty"`
+
+	// Set per TO1 or TO2 test run. ProveToRV32 and ProveDevice64 are checked for deterministic CBOR encoding
+	CanonicalCbor bool `cbor:"canonicalCbor,omitempty"`
 }
 
 type RequestListenerInst struct {
@@ -30,6 +34,312 @@ type RequestListenerInst struct {
 	To0         RequestListenerRunnerInst        `cbor:"to0,omitempty"`
 	To1         RequestListenerRunnerInst        `cbor:"to1,omitempty"`
 	To2         RequestListenerRunnerInst        `cbor:"to2,omitempty"`
+
+	// Set after TO2 installed new GUID. Cleared by the following TO1
+	ReplacedGuid *fdoshared.FdoGuid `cbor:"replacedGuid,omitempty"`
+	// Set when TO2 also replaced RVInfo with the secondary RV
+	ReplacedRvInfo bool `cbor:"replacedRvInfo,omitempty"`
+
+	// Set when TO2 was aborted after SetupDevice. Device must keep using its previous GUID
+	AbandonedGuid *fdoshared.FdoGuid `cbor:"abandonedGuid,omitempty"`
+
+	To2ServiceInfo To2ServiceInfoConfig `cbor:"to2ServiceInfo,omitempty"`
+
+	// Set when test voucher RVInfo has RVBypass. Device must go to the DO without TO1
+	RVBypass bool `cbor:"rvBypass,omitempty"`
+	// Set when device ran TO1 even though RVInfo has RVBypass. Cleared when recorded on TO2.Done
+	RVBypassIgnored bool `cbor:"rvBypassIgnored,omitempty"`
+
+	// Set per TO2 test run. RV signs To1d with a key other than the voucher owner key
+	To1dOwnerMismatch bool `cbor:"to1dOwnerMismatch,omitempty"`
+	// Set when RV served mismatched To1d. Cleared once device outcome is recorded
+	To1dOwnerMismatchServed bool `cbor:"to1dOwnerMismatchServed,omitempty"`
+
+	// Set per TO2 test run, when RV countersigns To1d. RV serves To1d with valid, absent or invalid countersignature
+	To1dCountersign To1dCountersignCase `cbor:"to1dCountersign,omitempty"`
+	// Set when RV served the countersignature case. Cleared once device outcome is recorded
+	To1dCountersignServed bool `cbor:"to1dCountersignServed,omitempty"`
+
+	// NonceTO2ProveOV of the last HelloDevice. Echoed back by the stale nonce test
+	PrevNonceTO2ProveOV *fdoshared.FdoNonce `cbor:"prevNonceTO2ProveOV,omitempty"`
+	// NonceTO2ProveDv issued in the last TO2 sessions, oldest first. Up to LISTENER_ISSUED_NONCES_MAX
+	IssuedNoncesTO2ProveDv []fdoshared.FdoNonce `cbor:"issuedNoncesTO2ProveDv,omitempty"`
+}
+
+const LISTENER_ISSUED_NONCES_MAX int = 10
+
+type To1dCountersignCase string
+
+const (
+	TO1D_COUNTERSIGN_PRESENT To1dCountersignCase = "present"
+	TO1D_COUNTERSIGN_ABSENT  To1dCountersignCase = "absent"
+	TO1D_COUNTERSIGN_INVALID To1dCountersignCase = "invalid"
+)
+
+var To1dCountersignCases []To1dCountersignCase = []To1dCountersignCase{
+	TO1D_COUNTERSIGN_PRESENT,
+	TO1D_COUNTERSIGN_ABSENT,
+	TO1D_COUNTERSIGN_INVALID,
+}
+
+var to1dCountersignTestIDs map[To1dCountersignCase]testcom.FDOTestID = map[To1dCountersignCase]testcom.FDOTestID{
+	TO1D_COUNTERSIGN_PRESENT: testcom.FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_PRESENT,
+	TO1D_COUNTERSIGN_ABSENT:  testcom.FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_ABSENT,
+	TO1D_COUNTERSIGN_INVALID: testcom.FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_INVALID,
+}
+
+func IsTo1dCountersignCase(countersignCase To1dCountersignCase) bool {
+	_, ok := to1dCountersignTestIDs[countersignCase]
+	return ok
+}
+
+// Conf_CheckRVBypassIgnored returns true, and marks bypass as ignored, if device came to TO1 with RVBypass voucher
+func (h *RequestListenerInst) Conf_CheckRVBypassIgnored() bool {
+	if !h.RVBypass {
+		return false
+	}
+
+	h.RVBypassIgnored = true
+	return true
+}
+
+// Conf_RVBypassTestStates returns result of RVBypass test, when voucher has RVBypass
+func (h *RequestListenerInst) Conf_RVBypassTestStates() []testcom.FDOTestState {
+	if !h.RVBypass {
+		return []testcom.FDOTestState{}
+	}
+
+	ignored := h.RVBypassIgnored
+	h.RVBypassIgnored = false
+
+	if ignored {
+		return []testcom.FDOTestState{testcom.NewFailTestState(testcom.FIDO_LISTENER_DEVICE_70_RV_BYPASS, "Device ran TO1 even though RVInfo has RVBypass. Expected TO2 directly with the owner")}
+	}
+
+	return []testcom.FDOTestState{testcom.NewSuccessTestState(testcom.FIDO_LISTENER_DEVICE_70_RV_BYPASS)}
+}
+
+// Conf_CheckTo1dOwnerMismatch returns true, and marks mismatched To1d as served, if running TO2 test run asked for it.
+// Served only after TO2 60 and 62 tests are done, so device coming back to TO1 before ProveDevice can only be caused by the To1d
+func (h *RequestListenerInst) Conf_CheckTo1dOwnerMismatch() bool {
+	if !h.To1dOwnerMismatch || !h.To2.Running || !h.To2.CheckCmdTestingIsCompleted(fdoshared.TO2_62_GET_OVNEXTENTRY) {
+		return false
+	}
+
+	h.To1dOwnerMismatchServed = true
+	return true
+}
+
+// Conf_To1dOwnerMismatchTestStates returns device outcome after mismatched To1d was served. Device either sent TO2.ProveDevice, so accepted the owner, or came back to TO1.
+// Mismatch is only served once, so the device can onboard with the valid To1d afterwards
+func (h *RequestListenerInst) Conf_To1dOwnerMismatchTestStates(acceptedOwner bool) []testcom.FDOTestState {
+	if !h.To1dOwnerMismatchServed {
+		return []testcom.FDOTestState{}
+	}
+
+	h.To1dOwnerMismatch = false
+	h.To1dOwnerMismatchServed = false
+
+	if acceptedOwner {
+		return []testcom.FDOTestState{testcom.NewFailTestState(testcom.FIDO_LISTENER_DEVICE_70_TO1D_OWNER_MISMATCH, "Observation: device sent TO2.ProveDevice, even though To1d from TO1.RVRedirect was not signed by the owner key from TO2.ProveOVHdr")}
+	}
+
+	return []testcom.FDOTestState{testcom.NewSuccessTestState(testcom.FIDO_LISTENER_DEVICE_70_TO1D_OWNER_MISMATCH)}
+}
+
+// Conf_CheckTo1dCountersign returns countersignature case to serve, and marks it as served, if running TO2 test run asked for it.
+// Served only after TO2 60 and 62 tests are done, same as mismatched To1d owner. Returns empty case otherwise
+func (h *RequestListenerInst) Conf_CheckTo1dCountersign() To1dCountersignCase {
+	if h.To1dCountersign == "" || !h.To2.Running || !h.To2.CheckCmdTestingIsCompleted(fdoshared.TO2_62_GET_OVNEXTENTRY) {
+		return ""
+	}
+
+	h.To1dCountersignServed = true
+	return h.To1dCountersign
+}
+
+// Conf_To1dCountersignTestStates returns device outcome after countersignature case was served. Device should only send TO2.ProveDevice
+// when countersignature is valid, and come back to TO1 otherwise. Served once, so the device can onboard afterwards
+func (h *RequestListenerInst) Conf_To1dCountersignTestStates(acceptedOwner bool) []testcom.FDOTestState {
+	if !h.To1dCountersignServed {
+		return []testcom.FDOTestState{}
+	}
+
+	countersignCase := h.To1dCountersign
+	h.To1dCountersign = ""
+	h.To1dCountersignServed = false
+
+	testId := to1dCountersignTestIDs[countersignCase]
+	if countersignCase == TO1D_COUNTERSIGN_PRESENT {
+		if !acceptedOwner {
+			return []testcom.FDOTestState{testcom.NewFailTestState(testId, "Device came back to TO1 instead of sending TO2.ProveDevice, even though To1d from TO1.RVRedirect had valid RV countersignature")}
+		}
+
+		return []testcom.FDOTestState{testcom.NewSuccessTestState(testId)}
+	}
+
+	if acceptedOwner {
+		return []testcom.FDOTestState{testcom.NewFailTestState(testId, fmt.Sprintf("Observation: device sent TO2.ProveDevice, even though To1d from TO1.RVRedirect had %s RV countersignature", countersignCase))}
+	}
+
+	return []testcom.FDOTestState{testcom.NewSuccessTestState(testId)}
+}
+
+// Conf_RecordTLSDeviceCertMismatch records TLS client certificate mismatch as observation of the running TO1 or TO2 test run.
+// Returns false when there is no running test run
+func (h *RequestListenerInst) Conf_RecordTLSDeviceCertMismatch(fdoProtocol fdoshared.FdoToProtocol, mismatch error) bool {
+	runner := &h.To2
+	if fdoProtocol == fdoshared.To1 {
+		runner = &h.To1
+	}
+
+	return runner.PushFailTest(testcom.FIDO_LISTENER_DEVICE_TLS_CLIENT_CERT, "Observation: "+mismatch.Error())
+}
+
+// Conf_CheckCanonicalCbor records whether device message is in deterministic CBOR encoding, when the running TO1 or TO2 test run was started with canonicalCbor.
+// Non-canonical message is only recorded, and is still processed. Returns false when nothing was recorded
+func (h *RequestListenerInst) Conf_CheckCanonicalCbor(fdoProtocol fdoshared.FdoToProtocol, bodyBytes []byte) bool {
+	runner := &h.To2
+	testId := testcom.FIDO_LISTENER_DEVICE_64_CANONICAL_CBOR
+	if fdoProtocol == fdoshared.To1 {
+		runner = &h.To1
+		testId = testcom.FIDO_LISTENER_DEVICE_32_CANONICAL_CBOR
+	}
+
+	if !runner.Running || !runner.CanonicalCbor {
+		return false
+	}
+
+	err := fdoshared.CheckCanonicalCoseSignature(bodyBytes)
+	if err != nil {
+		return runner.PushFailTest(testId, "Message is not in deterministic CBOR encoding. "+err.Error())
+	}
+
+	return runner.PushSuccessTest(testId)
+}
+
+// Conf_StaleNonceTO2ProveOV returns NonceTO2ProveOV of the previous HelloDevice. On the first TO2 session of the device, a random nonce
+func (h *RequestListenerInst) Conf_StaleNonceTO2ProveOV() fdoshared.FdoNonce {
+	if h.PrevNonceTO2ProveOV == nil {
+		return fdoshared.NewFdoNonce()
+	}
+
+	return *h.PrevNonceTO2ProveOV
+}
+
+// Conf_SaveIssuedNonces keeps nonces of the new TO2 session, for stale nonce test and NonceTO2ProveDv check
+func (h *RequestListenerInst) Conf_SaveIssuedNonces(nonceTO2ProveOV fdoshared.FdoNonce, nonceTO2ProveDv fdoshared.FdoNonce) {
+	h.PrevNonceTO2ProveOV = &nonceTO2ProveOV
+
+	h.IssuedNoncesTO2ProveDv = append(h.IssuedNoncesTO2ProveDv, nonceTO2ProveDv)
+	if len(h.IssuedNoncesTO2ProveDv) > LISTENER_ISSUED_NONCES_MAX {
+		h.IssuedNoncesTO2ProveDv = h.IssuedNoncesTO2ProveDv[len(h.IssuedNoncesTO2ProveDv)-LISTENER_ISSUED_NONCES_MAX:]
+	}
+}
+
+// Conf_NonceTO2ProveDvMismatch describes EatNonce of ProveDevice that is not NonceTO2ProveDv of the session: either issued in a previous TO2 session, or never issued.
+// Empty when nonce matches
+func (h *RequestListenerInst) Conf_NonceTO2ProveDvMismatch(eatNonce fdoshared.FdoNonce, sessionNonce fdoshared.FdoNonce) string {
+	if eatNonce == sessionNonce {
+		return ""
+	}
+
+	for i := len(h.IssuedNoncesTO2ProveDv) - 1; i >= 0; i-- {
+		if h.IssuedNoncesTO2ProveDv[i] == eatNonce {
+			sessionsAgo := len(h.IssuedNoncesTO2ProveDv) - 1 - i
+			if h.IssuedNoncesTO2ProveDv[len(h.IssuedNoncesTO2ProveDv)-1] != sessionNonce {
+				sessionsAgo++
+			}
+
+			return fmt.Sprintf("EatNonce is stale NonceTO2ProveDv61 issued %d TO2 sessions ago. Expected %s. Got %s", sessionsAgo, hex.EncodeToString(sessionNonce[:]), hex.EncodeToString(eatNonce[:]))
+		}
+	}
+
+	return fmt.Sprintf("EatNonce was never issued as NonceTO2ProveDv61. Expected %s. Got %s", hex.EncodeToString(sessionNonce[:]), hex.EncodeToString(eatNonce[:]))
+}
+
+// Conf_RecordNonceTO2ProveDv records NonceTO2ProveDv check of ProveDevice, with mismatch from Conf_NonceTO2ProveDvMismatch. Returns false when there is no running TO2 test run
+func (h *RequestListenerInst) Conf_RecordNonceTO2ProveDv(mismatch string) bool {
+	if mismatch != "" {
+		return h.To2.PushFailTest(testcom.FIDO_LISTENER_DEVICE_64_NONCE_TO2PROVEDV, mismatch)
+	}
+
+	return h.To2.PushSuccessTest(testcom.FIDO_LISTENER_DEVICE_64_NONCE_TO2PROVEDV)
+}
+
+// Conf_CheckAbandonedGuid returns true, and records TO2 failure, if device came back with GUID from the aborted TO2
+func (h *RequestListenerInst) Conf_CheckAbandonedGuid(guid fdoshared.FdoGuid) bool {
+	if h.AbandonedGuid == nil {
+		return false
+	}
+
+	isAbandoned := guid == *h.AbandonedGuid
+	h.AbandonedGuid = nil
+
+	if !isAbandoned {
+		return false
+	}
+
+	h.To2.PushFail("Device adopted GUID from TO2.SetupDevice, even though TO2 failed before Done. Expected previous GUID")
+	return true
+}
+
+// Conf_CheckReplacementGuid records whether device came to TO1 with the GUID, and to the RV, installed by the last TO2
+func (h *RequestListenerInst) Conf_CheckReplacementGuid(guid fdoshared.FdoGuid, isSecondaryRv bool) bool {
+	if h.ReplacedGuid == nil || !h.To1.Running {
+		return false
+	}
+
+	if guid == *h.ReplacedGuid {
+		h.To1.PushFailTest(testcom.FIDO_LISTENER_DEVICE_30_REPLACEMENT_GUID, "Device used previous GUID. Expected GUID from TO2.SetupDevice")
+	} else {
+		h.To1.PushSuccessTest(testcom.FIDO_LISTENER_DEVICE_30_REPLACEMENT_GUID)
+	}
+
+	if h.ReplacedRvInfo {
+		if isSecondaryRv {
+			h.To1.PushSuccessTest(testcom.FIDO_LISTENER_DEVICE_30_REPLACEMENT_RVINFO)
+		} else {
+			h.To1.PushFailTest(testcom.FIDO_LISTENER_DEVICE_30_REPLACEMENT_RVINFO, "Device contacted previous RV. Expected RV from TO2.SetupDevice RVInfo")
+		}
+	}
+
+	h.ReplacedGuid = nil
+	h.ReplacedRvInfo = false
+
+	return true
+}
+
+var ownerSignTestIDs []testcom.FDOTestID = []testcom.FDOTestID{
+	testcom.FIDO_LISTENER_OWNER_22_MALFORMED_OWNERSIGN,
+	testcom.FIDO_LISTENER_OWNER_22_BAD_TO0D_HASH,
+	testcom.FIDO_LISTENER_OWNER_22_EXPIRED_WAITSECONDS,
+}
+
+// Conf_RecordOwnerSignFail records failed OwnerSign22 check of the running TO0 test run. Returns false when there is no running test run
+func (h *RequestListenerInst) Conf_RecordOwnerSignFail(testId testcom.FDOTestID, errorMsg string) bool {
+	return h.To0.PushFailTest(testId, errorMsg)
+}
+
+// Conf_OwnerSignTestStates returns passed OwnerSign22 checks, on completion of TO0 test run. Checks failed earlier in the run are skipped
+func (h *RequestListenerInst) Conf_OwnerSignTestStates() []testcom.FDOTestState {
+	testStates := []testcom.FDOTestState{}
+
+	for _, testId := range ownerSignTestIDs {
+		failed := false
+		for _, testState := range h.To0.CurrentTestRun.TestRuns {
+			if testState.TestID == testId && !testState.Passed {
+				failed = true
+				break
+			}
+		}
+
+		if !failed {
+			testStates = append(testStates, testcom.NewSuccessTestState(testId))
+		}
+	}
+
+	return testStates
 }
 
 func (h *RequestListenerInst) GetProtocolInst(toProtocol int) (*RequestListenerRunnerInst, error) {
@@ -69,6 +379,15 @@ func (h *RequestListenerRunnerInst) CheckCmdTestingIsCompleted(currentCmd fdosha
 	return false
 }
 
+// GetTestRuns returns test run history, with running test run first
+func (h *RequestListenerRunnerInst) GetTestRuns() []ListenerTestRun {
+	if h.Running {
+		return append([]ListenerTestRun{h.CurrentTestRun}, h.TestRunHistory...)
+	}
+
+	return h.TestRunHistory
+}
+
 func (h *RequestListenerRunnerInst) StartNewTestRun() {
 	if len(h.TestRunHistory) != 0 {
 		h.TestRunHistory = append([]ListenerTestRun{h.CurrentTestRun}, h.TestRunHistory...)
@@ -83,7 +402,8 @@ func (h *RequestListenerRunnerInst) StartNewTestRun() {
 
 	switch h.Protocol {
 	case fdoshared.To0:
-		h.ExpectedCmd = fdoshared.TO0_20_HELLO
+		// Hello20 has no GUID, so the run can only be matched on OwnerSign22
+		h.ExpectedCmd = fdoshared.TO0_22_OWNER_SIGN
 	case fdoshared.To1:
 		h.ExpectedCmd = fdoshared.TO1_30_HELLO_RV
 	case fdoshared.To2:
@@ -153,9 +473,29 @@ func (h *RequestListenerRunnerInst) CompleteTestRun() {
 }
 
 func (h *RequestListenerRunnerInst) PushFail(errorMsg string) {
-	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testcom.NewFailTestState(h.GetLastTestID(), errorMsg))
+	h.PushFailTest(h.GetLastTestID(), errorMsg)
 }
 
 func (h *RequestListenerRunnerInst) PushSuccess() {
-	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testcom.NewSuccessTestState(h.GetLastTestID()))
+	h.PushSuccessTest(h.GetLastTestID())
+}
+
+// PushFailTest records failure of testId, that is not necessarily the last test ID, e.g. an observation. Returns false when there is no running test run
+func (h *RequestListenerRunnerInst) PushFailTest(testId testcom.FDOTestID, errorMsg string) bool {
+	return h.PushTestStates(testcom.NewFailTestState(testId, errorMsg))
+}
+
+// PushSuccessTest records success of testId. Returns false when there is no running test run
+func (h *RequestListenerRunnerInst) PushSuccessTest(testId testcom.FDOTestID) bool {
+	return h.PushTestStates(testcom.NewSuccessTestState(testId))
+}
+
+// PushTestStates records test states in the running test run. States are dropped when there is no running test run, so they do not end up in a completed one
+func (h *RequestListenerRunnerInst) PushTestStates(testStates ...testcom.FDOTestState) bool {
+	if !h.Running {
+		return false
+	}
+
+	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testStates...)
+	return true
 }
@@ -0,0 +1,242 @@
+package listener
+
+import (
+	"strings"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func TestRequestListenerInst_RVBypass(t *testing.T) {
+	listenerInst := RequestListenerInst{}
+	if listenerInst.Conf_CheckRVBypassIgnored() || len(listenerInst.Conf_RVBypassTestStates()) != 0 {
+		t.Errorf("Expected no RVBypass test without RVBypass voucher")
+	}
+
+	listenerInst.RVBypass = true
+
+	testStates := listenerInst.Conf_RVBypassTestStates()
+	if len(testStates) != 1 || testStates[0].TestID != testcom.FIDO_LISTENER_DEVICE_70_RV_BYPASS || !testStates[0].Passed {
+		t.Errorf("Expected RVBypass test to pass when device skipped TO1")
+	}
+
+	if !listenerInst.Conf_CheckRVBypassIgnored() {
+		t.Errorf("Expected TO1 to be recorded for RVBypass voucher")
+	}
+
+	testStates = listenerInst.Conf_RVBypassTestStates()
+	if len(testStates) != 1 || testStates[0].Passed {
+		t.Errorf("Expected RVBypass test to fail when device ran TO1")
+	}
+
+	// Recorded once
+	testStates = listenerInst.Conf_RVBypassTestStates()
+	if !testStates[0].Passed {
+		t.Errorf("Expected ignored RVBypass to be cleared after it was recorded")
+	}
+}
+
+func TestRequestListenerInst_To1dOwnerMismatch(t *testing.T) {
+	listenerInst := RequestListenerInst{
+		To1dOwnerMismatch: true,
+		To2: RequestListenerRunnerInst{
+			Protocol:      fdoshared.To2,
+			Running:       true,
+			CompletedCmds: []fdoshared.FdoCmd{fdoshared.TO2_60_HELLO_DEVICE},
+		},
+	}
+
+	if listenerInst.Conf_CheckTo1dOwnerMismatch() {
+		t.Errorf("Expected mismatched To1d to wait for TO2 62 tests")
+	}
+
+	listenerInst.To2.CompletedCmds = append(listenerInst.To2.CompletedCmds, fdoshared.TO2_62_GET_OVNEXTENTRY)
+	if !listenerInst.Conf_CheckTo1dOwnerMismatch() {
+		t.Fatalf("Expected mismatched To1d to be served")
+	}
+
+	testStates := listenerInst.Conf_To1dOwnerMismatchTestStates(false)
+	if len(testStates) != 1 || testStates[0].TestID != testcom.FIDO_LISTENER_DEVICE_70_TO1D_OWNER_MISMATCH || !testStates[0].Passed {
+		t.Errorf("Expected test to pass when device came back to TO1")
+	}
+
+	// Served once per test run
+	if listenerInst.Conf_CheckTo1dOwnerMismatch() || len(listenerInst.Conf_To1dOwnerMismatchTestStates(true)) != 0 {
+		t.Errorf("Expected mismatched To1d to be served only once")
+	}
+
+	listenerInst.To1dOwnerMismatch = true
+	listenerInst.Conf_CheckTo1dOwnerMismatch()
+
+	testStates = listenerInst.Conf_To1dOwnerMismatchTestStates(true)
+	if len(testStates) != 1 || testStates[0].Passed {
+		t.Errorf("Expected test to fail when device sent TO2.ProveDevice")
+	}
+}
+
+func TestRequestListenerInst_To1dCountersign(t *testing.T) {
+	listenerInst := RequestListenerInst{
+		To1dCountersign: TO1D_COUNTERSIGN_INVALID,
+		To2: RequestListenerRunnerInst{
+			Protocol:      fdoshared.To2,
+			Running:       true,
+			CompletedCmds: []fdoshared.FdoCmd{fdoshared.TO2_60_HELLO_DEVICE},
+		},
+	}
+
+	if listenerInst.Conf_CheckTo1dCountersign() != "" {
+		t.Errorf("Expected countersignature case to wait for TO2 62 tests")
+	}
+
+	listenerInst.To2.CompletedCmds = append(listenerInst.To2.CompletedCmds, fdoshared.TO2_62_GET_OVNEXTENTRY)
+	if listenerInst.Conf_CheckTo1dCountersign() != TO1D_COUNTERSIGN_INVALID {
+		t.Fatalf("Expected invalid countersignature to be served")
+	}
+
+	testStates := listenerInst.Conf_To1dCountersignTestStates(true)
+	if len(testStates) != 1 || testStates[0].TestID != testcom.FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_INVALID || testStates[0].Passed {
+		t.Errorf("Expected test to fail when device accepted invalid countersignature")
+	}
+
+	// Served once per test run
+	if listenerInst.Conf_CheckTo1dCountersign() != "" || len(listenerInst.Conf_To1dCountersignTestStates(false)) != 0 {
+		t.Errorf("Expected countersignature case to be served only once")
+	}
+
+	listenerInst.To1dCountersign = TO1D_COUNTERSIGN_ABSENT
+	listenerInst.Conf_CheckTo1dCountersign()
+
+	testStates = listenerInst.Conf_To1dCountersignTestStates(false)
+	if len(testStates) != 1 || testStates[0].TestID != testcom.FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_ABSENT || !testStates[0].Passed {
+		t.Errorf("Expected test to pass when device rejected absent countersignature")
+	}
+
+	listenerInst.To1dCountersign = TO1D_COUNTERSIGN_PRESENT
+	listenerInst.Conf_CheckTo1dCountersign()
+
+	testStates = listenerInst.Conf_To1dCountersignTestStates(false)
+	if len(testStates) != 1 || testStates[0].TestID != testcom.FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_PRESENT || testStates[0].Passed {
+		t.Errorf("Expected test to fail when device rejected valid countersignature")
+	}
+}
+
+func TestRequestListenerInst_OwnerSign(t *testing.T) {
+	listenerInst := RequestListenerInst{
+		To0: RequestListenerRunnerInst{
+			Protocol: fdoshared.To0,
+		},
+	}
+
+	if listenerInst.Conf_RecordOwnerSignFail(testcom.FIDO_LISTENER_OWNER_22_BAD_TO0D_HASH, "bad hash") {
+		t.Errorf("Expected no OwnerSign check without running TO0 test run")
+	}
+
+	listenerInst.To0.StartNewTestRun()
+	if !listenerInst.To0.CheckExpectedCmd(fdoshared.TO0_22_OWNER_SIGN) {
+		t.Errorf("Expected TO0 test run to start on OwnerSign22. Got %d", listenerInst.To0.ExpectedCmd)
+	}
+
+	if !listenerInst.Conf_RecordOwnerSignFail(testcom.FIDO_LISTENER_OWNER_22_BAD_TO0D_HASH, "bad hash") {
+		t.Fatalf("Expected OwnerSign check to be recorded")
+	}
+
+	testStates := listenerInst.Conf_OwnerSignTestStates()
+	if len(testStates) != 2 {
+		t.Fatalf("Expected 2 passed checks. Got %d", len(testStates))
+	}
+
+	for _, testState := range testStates {
+		if testState.TestID == testcom.FIDO_LISTENER_OWNER_22_BAD_TO0D_HASH || !testState.Passed {
+			t.Errorf("Expected failed To0d hash check to be skipped. Got %+v", testState)
+		}
+	}
+}
+
+func TestRequestListenerInst_NonceTO2ProveDv(t *testing.T) {
+	listenerInst := RequestListenerInst{
+		To2: RequestListenerRunnerInst{
+			Protocol: fdoshared.To2,
+			Running:  true,
+		},
+	}
+
+	firstNonceTO2ProveOV := fdoshared.NewFdoNonce()
+	if listenerInst.Conf_StaleNonceTO2ProveOV() == firstNonceTO2ProveOV {
+		t.Errorf("Expected random stale nonce before the first HelloDevice")
+	}
+
+	issuedNonces := []fdoshared.FdoNonce{}
+	for i := 0; i < LISTENER_ISSUED_NONCES_MAX+2; i++ {
+		issuedNonces = append(issuedNonces, fdoshared.NewFdoNonce())
+		listenerInst.Conf_SaveIssuedNonces(firstNonceTO2ProveOV, issuedNonces[i])
+		firstNonceTO2ProveOV = fdoshared.NewFdoNonce()
+	}
+
+	if len(listenerInst.IssuedNoncesTO2ProveDv) != LISTENER_ISSUED_NONCES_MAX {
+		t.Errorf("Expected %d issued nonces to be kept. Got %d", LISTENER_ISSUED_NONCES_MAX, len(listenerInst.IssuedNoncesTO2ProveDv))
+	}
+
+	sessionNonce := issuedNonces[len(issuedNonces)-1]
+	if mismatch := listenerInst.Conf_NonceTO2ProveDvMismatch(sessionNonce, sessionNonce); mismatch != "" {
+		t.Errorf("Expected no mismatch for session nonce. Got %s", mismatch)
+	}
+
+	mismatch := listenerInst.Conf_NonceTO2ProveDvMismatch(issuedNonces[len(issuedNonces)-3], sessionNonce)
+	if !strings.HasPrefix(mismatch, "EatNonce is stale NonceTO2ProveDv61 issued 2 TO2 sessions ago") {
+		t.Errorf("Expected stale nonce of 2 sessions ago. Got %s", mismatch)
+	}
+
+	mismatch = listenerInst.Conf_NonceTO2ProveDvMismatch(fdoshared.NewFdoNonce(), sessionNonce)
+	if !strings.HasPrefix(mismatch, "EatNonce was never issued") {
+		t.Errorf("Expected never issued nonce. Got %s", mismatch)
+	}
+
+	if !listenerInst.Conf_RecordNonceTO2ProveDv(mismatch) {
+		t.Fatalf("Expected nonce check to be recorded for running test run")
+	}
+
+	testRuns := listenerInst.To2.CurrentTestRun.TestRuns
+	if len(testRuns) != 1 || testRuns[0].TestID != testcom.FIDO_LISTENER_DEVICE_64_NONCE_TO2PROVEDV || testRuns[0].Passed {
+		t.Errorf("Expected failed nonce check. Got %v", testRuns)
+	}
+}
+
+func TestRequestListenerRunnerInst_PushTestStates(t *testing.T) {
+	runner := RequestListenerRunnerInst{Protocol: fdoshared.To2}
+
+	if runner.PushFailTest(testcom.FIDO_LISTENER_DEVICE_UNKNOWN_MESSAGE, "Observation") || len(runner.CurrentTestRun.TestRuns) != 0 {
+		t.Errorf("Expected test state to be dropped without running test run")
+	}
+
+	runner.StartNewTestRun()
+	runner.LastTestID = testcom.FIDO_LISTENER_POSITIVE
+
+	runner.PushSuccess()
+	if !runner.PushFailTest(testcom.FIDO_LISTENER_DEVICE_UNKNOWN_MESSAGE, "Observation") {
+		t.Fatalf("Expected test state to be recorded in running test run")
+	}
+
+	testRuns := runner.CurrentTestRun.TestRuns
+	if len(testRuns) != 2 || testRuns[0].TestID != testcom.FIDO_LISTENER_POSITIVE || !testRuns[0].Passed || testRuns[1].TestID != testcom.FIDO_LISTENER_DEVICE_UNKNOWN_MESSAGE || testRuns[1].Passed {
+		t.Errorf("Expected positive success and unknown message failure. Got %v", testRuns)
+	}
+
+	runner.CompleteTestRun()
+	runner.PushFail("After completion")
+	if len(runner.CurrentTestRun.TestRuns) != 2 {
+		t.Errorf("Expected no test state after test run completed")
+	}
+}
+
+func TestNewRequestListenerInst_To0(t *testing.T) {
+	deviceInst := NewDevice_RequestListenerInst(fdoshared.VoucherDBEntry{}, fdoshared.NewFdoGuid())
+	if len(deviceInst.To0.Tests) != 0 {
+		t.Errorf("Expected no TO0 tests for device listener. Got %v", deviceInst.To0.Tests)
+	}
+
+	doInst := NewDO_RequestListenerInst(fdoshared.VoucherDBEntry{}, fdoshared.NewFdoGuid())
+	if len(doInst.To0.Tests[fdoshared.TO0_22_OWNER_SIGN]) == 0 {
+		t.Errorf("Expected OwnerSign22 tests for DO listener")
+	}
+}
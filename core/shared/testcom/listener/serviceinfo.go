@@ -0,0 +1,75 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Smaller MaxDeviceServiceInfoSz would not fit devmod
+const MIN_MAX_DEVICE_SERVICE_INFO_SIZE uint16 = 256
+
+const MAX_PREACTIVATED_MODULES int = 16
+
+// Owner side of TO2 ServiceInfo handshake. Set when starting TO2 test run
+type To2ServiceInfoConfig struct {
+	// Sent in OwnerServiceInfoReady67. Device DeviceServiceInfo68 must not exceed it. 0 keeps the default negotiation
+	MaxDeviceServiceInfoSz uint16 `cbor:"maxDeviceServiceInfoSz,omitempty" json:"maxDeviceServiceInfoSz,omitempty"`
+
+	// Modules owner activates with modname:active before any other ServiceInfo. Device must respond with modname:active
+	ActiveModules []string `cbor:"activeModules,omitempty" json:"activeModules,omitempty"`
+}
+
+func (h To2ServiceInfoConfig) Validate() error {
+	if h.MaxDeviceServiceInfoSz != 0 && h.MaxDeviceServiceInfoSz < MIN_MAX_DEVICE_SERVICE_INFO_SIZE {
+		return fmt.Errorf("maxDeviceServiceInfoSz must be at least %d", MIN_MAX_DEVICE_SERVICE_INFO_SIZE)
+	}
+
+	if len(h.ActiveModules) > MAX_PREACTIVATED_MODULES {
+		return fmt.Errorf("at most %d modules can be pre-activated", MAX_PREACTIVATED_MODULES)
+	}
+
+	for _, module := range h.ActiveModules {
+		if module == "" || strings.Contains(module, ":") {
+			return fmt.Errorf("invalid module name \"%s\"", module)
+		}
+
+		if module == "devmod" {
+			return errors.New("devmod is activated by the device")
+		}
+	}
+
+	return nil
+}
+
+// ActivationSIMs returns modname:active = true for each pre-activated module
+func (h To2ServiceInfoConfig) ActivationSIMs() []fdoshared.ServiceInfoKV {
+	sims := []fdoshared.ServiceInfoKV{}
+	for _, module := range h.ActiveModules {
+		sims = append(sims, fdoshared.ServiceInfoKV{
+			ServiceInfoKey: fdoshared.SIM_ID(module + ":active"),
+			ServiceInfoVal: fdoshared.CBOR_TRUE,
+		})
+	}
+
+	return sims
+}
+
+// Conf_ResolveModuleActivations returns modules from pending that device did not yet respond to with modname:active
+func Conf_ResolveModuleActivations(pending []string, deviceSims []fdoshared.ServiceInfoKV) []string {
+	remaining := []string{}
+	for _, module := range pending {
+		responded := false
+		for _, sim := range deviceSims {
+			responded = responded || sim.ServiceInfoKey == fdoshared.SIM_ID(module+":active")
+		}
+
+		if !responded {
+			remaining = append(remaining, module)
+		}
+	}
+
+	return remaining
+}
@@ -0,0 +1,60 @@
+package listener
+
+import (
+	"bytes"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestTo2ServiceInfoConfig_Validate(t *testing.T) {
+	validConfigs := []To2ServiceInfoConfig{
+		{},
+		{MaxDeviceServiceInfoSz: 1300},
+		{ActiveModules: []string{"fdo_sys", "fido_alliance"}},
+	}
+
+	for _, config := range validConfigs {
+		if err := config.Validate(); err != nil {
+			t.Errorf("Expected %v to be valid. %s", config, err.Error())
+		}
+	}
+
+	invalidConfigs := []To2ServiceInfoConfig{
+		{MaxDeviceServiceInfoSz: 100},
+		{ActiveModules: []string{""}},
+		{ActiveModules: []string{"fdo_sys:active"}},
+		{ActiveModules: []string{"devmod"}},
+		{ActiveModules: make([]string, MAX_PREACTIVATED_MODULES+1)},
+	}
+
+	for _, config := range invalidConfigs {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected %v to be invalid", config)
+		}
+	}
+}
+
+func TestTo2ServiceInfoConfig_PreactivatedModule(t *testing.T) {
+	config := To2ServiceInfoConfig{ActiveModules: []string{"fdo_sys", "fido_alliance"}}
+
+	sims := config.ActivationSIMs()
+	if len(sims) != 2 || sims[0].ServiceInfoKey != "fdo_sys:active" || !bytes.Equal(sims[0].ServiceInfoVal, fdoshared.CBOR_TRUE) {
+		t.Fatalf("Expected fdo_sys:active = true first. Got %v", sims)
+	}
+
+	pending := Conf_ResolveModuleActivations(config.ActiveModules, []fdoshared.ServiceInfoKV{
+		{ServiceInfoKey: fdoshared.SIM_DEVMOD_ACTIVE, ServiceInfoVal: fdoshared.CBOR_TRUE},
+	})
+	if len(pending) != 2 {
+		t.Errorf("Expected both modules pending before device responded. Got %v", pending)
+	}
+
+	// Device may respond false for unsupported module, it still handled activation
+	pending = Conf_ResolveModuleActivations(pending, []fdoshared.ServiceInfoKV{
+		{ServiceInfoKey: "fdo_sys:active", ServiceInfoVal: fdoshared.CBOR_FALSE},
+	})
+	if len(pending) != 1 || pending[0] != "fido_alliance" {
+		t.Errorf("Expected only fido_alliance pending. Got %v", pending)
+	}
+}
@@ -4,20 +4,40 @@ const (
 	FIDO_LISTENER_POSITIVE FDOTestID = "FIDO_LISTENER_POSITIVE"
 	// 30
 	FIDO_LISTENER_DEVICE_30_BAD_ENCODING FDOTestID = "FIDO_LISTENER_DEVICE_30_BAD_ENCODING"
+	// HelloRVAck31 with NonceTO1Proof one byte short of its 16 bytes. Device must reject it, expected MESSAGE_BODY_ERROR
+	FIDO_LISTENER_DEVICE_30_BAD_NONCE_LEN FDOTestID = "FIDO_LISTENER_DEVICE_30_BAD_NONCE_LEN"
+	// Not in the 30 list. Recorded on the first TO1 after TO2 installed new GUID and owner key
+	FIDO_LISTENER_DEVICE_30_REPLACEMENT_GUID   FDOTestID = "FIDO_LISTENER_DEVICE_30_REPLACEMENT_GUID"
+	FIDO_LISTENER_DEVICE_30_REPLACEMENT_RVINFO FDOTestID = "FIDO_LISTENER_DEVICE_30_REPLACEMENT_RVINFO"
 
 	// 32
 	FIDO_LISTENER_DEVICE_32_BAD_ENCODING FDOTestID = "FIDO_LISTENER_DEVICE_32_BAD_ENCODING"
 	FIDO_LISTENER_DEVICE_32_BAD_TO1D     FDOTestID = "FIDO_LISTENER_DEVICE_32_BAD_TO1D"
+	// Not in the 32 list. Recorded when TO1 test run was started with canonicalCbor. ProveToRV must be in deterministic CBOR encoding
+	FIDO_LISTENER_DEVICE_32_CANONICAL_CBOR FDOTestID = "FIDO_LISTENER_DEVICE_32_CANONICAL_CBOR"
 )
 
-// RV
+// RV, owner client
+const (
+	// 22
+	FIDO_LISTENER_OWNER_22_BAD_ENCODING FDOTestID = "FIDO_LISTENER_OWNER_22_BAD_ENCODING"
+	// Not in the 22 list. Recorded on OwnerSign22 of TO0 test run. Fail is recorded right away, pass once the run is completed
+	FIDO_LISTENER_OWNER_22_MALFORMED_OWNERSIGN FDOTestID = "FIDO_LISTENER_OWNER_22_MALFORMED_OWNERSIGN"
+	FIDO_LISTENER_OWNER_22_BAD_TO0D_HASH       FDOTestID = "FIDO_LISTENER_OWNER_22_BAD_TO0D_HASH"
+	FIDO_LISTENER_OWNER_22_EXPIRED_WAITSECONDS FDOTestID = "FIDO_LISTENER_OWNER_22_EXPIRED_WAITSECONDS"
+)
+
+// Hello20 has no GUID, so TO0 test runs are driven by OwnerSign22
 var FIDO_LISTENER_20_LIST []FDOTestID = []FDOTestID{}
 
-var FIDO_LISTENER_22_LIST []FDOTestID = []FDOTestID{}
+var FIDO_LISTENER_22_LIST []FDOTestID = []FDOTestID{
+	FIDO_LISTENER_OWNER_22_BAD_ENCODING,
+}
 
 // RV
 var FIDO_LISTENER_30_LIST []FDOTestID = []FDOTestID{
 	FIDO_LISTENER_DEVICE_30_BAD_ENCODING,
+	FIDO_LISTENER_DEVICE_30_BAD_NONCE_LEN,
 }
 
 var FIDO_LISTENER_32_LIST []FDOTestID = []FDOTestID{
@@ -36,11 +56,21 @@ const (
 	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING"
 	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING         FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING"
 	FIDO_LISTENER_DEVICE_60_MISSING_AUTHZ_HEADER          FDOTestID = "FIDO_LISTENER_DEVICE_60_MISSING_AUTHZ_HEADER"
+	FIDO_LISTENER_DEVICE_60_BAD_OWNER_PUBKEY              FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_OWNER_PUBKEY"
+	FIDO_LISTENER_DEVICE_60_OVERSIZED_PROVEOVHDR          FDOTestID = "FIDO_LISTENER_DEVICE_60_OVERSIZED_PROVEOVHDR"
+	// ProveOVHdr with wrong or missing Content-Type and Message-Type headers. Must be rejected
+	FIDO_LISTENER_DEVICE_60_BAD_CONTENT_TYPE     FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_CONTENT_TYPE"
+	FIDO_LISTENER_DEVICE_60_MISSING_CONTENT_TYPE FDOTestID = "FIDO_LISTENER_DEVICE_60_MISSING_CONTENT_TYPE"
+	FIDO_LISTENER_DEVICE_60_BAD_MESSAGE_TYPE     FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_MESSAGE_TYPE"
+	FIDO_LISTENER_DEVICE_60_MISSING_MESSAGE_TYPE FDOTestID = "FIDO_LISTENER_DEVICE_60_MISSING_MESSAGE_TYPE"
+	// ProveOVHdr echoes NonceTO2ProveOV of the previous HelloDevice. Device must reject the reused nonce
+	FIDO_LISTENER_DEVICE_60_STALE_NONCE_TO2PROVEOV FDOTestID = "FIDO_LISTENER_DEVICE_60_STALE_NONCE_TO2PROVEOV"
 
 	// 62
 	FIDO_LISTENER_DEVICE_62_BAD_OVENTRY_COSE_SIGNATURE FDOTestID = "FIDO_LISTENER_DEVICE_62_BAD_OVENTRY_COSE_SIGNATURE"
 	FIDO_LISTENER_DEVICE_62_BAD_OVNEXTENTRY_PAYLOAD    FDOTestID = "FIDO_LISTENER_DEVICE_62_BAD_OVNEXTENTRY_PAYLOAD"
 	FIDO_LISTENER_DEVICE_62_BAD_OVENTRYNUM             FDOTestID = "FIDO_LISTENER_DEVICE_62_BAD_OVENTRYNUM"
+	FIDO_LISTENER_DEVICE_62_MISSING_OVENTRY            FDOTestID = "FIDO_LISTENER_DEVICE_62_MISSING_OVENTRY"
 
 	// 64
 	FIDO_LISTENER_DEVICE_64_BAD_NONCE_TO2SETUPDV           FDOTestID = "FIDO_LISTENER_DEVICE_64_BAD_NONCE_TO2SETUPDV"
@@ -49,32 +79,74 @@ const (
 	FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_BYTES          FDOTestID = "FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_BYTES"
 	FIDO_LISTENER_DEVICE_64_BAD_ENC_WRAPPING               FDOTestID = "FIDO_LISTENER_DEVICE_64_BAD_ENC_WRAPPING"
 	FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_ENCODING       FDOTestID = "FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_ENCODING"
+	FIDO_LISTENER_DEVICE_64_OVERSIZED_SETUPDEVICE          FDOTestID = "FIDO_LISTENER_DEVICE_64_OVERSIZED_SETUPDEVICE"
+	// Not in the 64 list. Recorded on ProveDevice of TO2 test run. EatNonce must be NonceTO2ProveDv of the current session, not of a previous one
+	FIDO_LISTENER_DEVICE_64_NONCE_TO2PROVEDV FDOTestID = "FIDO_LISTENER_DEVICE_64_NONCE_TO2PROVEDV"
+	// Not in the 64 list. Recorded when TO2 test run was started with canonicalCbor. ProveDevice must be in deterministic CBOR encoding
+	FIDO_LISTENER_DEVICE_64_CANONICAL_CBOR FDOTestID = "FIDO_LISTENER_DEVICE_64_CANONICAL_CBOR"
 
 	// 66
 	FIDO_LISTENER_DEVICE_66_BAD_ENCODING     FDOTestID = "FIDO_LISTENER_DEVICE_66_BAD_ENCODING"
 	FIDO_LISTENER_DEVICE_66_BAD_ENC_WRAPPING FDOTestID = "FIDO_LISTENER_DEVICE_66_BAD_ENC_WRAPPING"
+	FIDO_LISTENER_DEVICE_66_ROLLBACK         FDOTestID = "FIDO_LISTENER_DEVICE_66_ROLLBACK"
+
+	// 68
+	FIDO_LISTENER_DEVICE_68_UNKNOWN_SIM FDOTestID = "FIDO_LISTENER_DEVICE_68_UNKNOWN_SIM"
+	FIDO_LISTENER_DEVICE_68_BINARY_SIM  FDOTestID = "FIDO_LISTENER_DEVICE_68_BINARY_SIM"
+	// OwnerServiceInfo completion edges. IsDone with IsMoreServiceInfo is invalid and must be rejected. IsMoreServiceInfo with empty ServiceInfo is legal
+	FIDO_LISTENER_DEVICE_68_DONE_WITH_MORE_SERVICEINFO FDOTestID = "FIDO_LISTENER_DEVICE_68_DONE_WITH_MORE_SERVICEINFO"
+	FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY     FDOTestID = "FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY"
+	// OwnerServiceInfo encrypted with "Encrypt" AAD context instead of "Encrypt0", or "MAC" instead of "MAC0" for ETM suites. Must be rejected
+	FIDO_LISTENER_DEVICE_68_WRONG_ENC_CONTEXT FDOTestID = "FIDO_LISTENER_DEVICE_68_WRONG_ENC_CONTEXT"
+	// Not in the 68 list. Recorded on Done when test run configured owner MaxDeviceServiceInfoSz or pre-activated modules
+	FIDO_LISTENER_DEVICE_68_MAX_DEVICE_SERVICEINFO_SZ FDOTestID = "FIDO_LISTENER_DEVICE_68_MAX_DEVICE_SERVICEINFO_SZ"
+	FIDO_LISTENER_DEVICE_68_MODULE_ACTIVATION         FDOTestID = "FIDO_LISTENER_DEVICE_68_MODULE_ACTIVATION"
+	// Not in the 68 list. Recorded when owner validates device devmod, with one failure per missing or malformed mandatory key
+	FIDO_LISTENER_DEVICE_68_DEVMOD FDOTestID = "FIDO_LISTENER_DEVICE_68_DEVMOD"
 
 	// 70
 	FIDO_LISTENER_DEVICE_70_BAD_NONCE_TO2SETUPDV64 FDOTestID = "FIDO_LISTENER_DEVICE_70_BAD_NONCE_TO2SETUPDV64"
 	FIDO_LISTENER_DEVICE_70_BAD_DONE71_ENCODING    FDOTestID = "FIDO_LISTENER_DEVICE_70_BAD_DONE71_ENCODING"
 	FIDO_LISTENER_DEVICE_70_BAD_ENC_WRAPPING       FDOTestID = "FIDO_LISTENER_DEVICE_70_BAD_ENC_WRAPPING"
+	// Not in the 70 list. Recorded on Done when voucher RVInfo has RVBypass
+	FIDO_LISTENER_DEVICE_70_RV_BYPASS FDOTestID = "FIDO_LISTENER_DEVICE_70_RV_BYPASS"
+	// Not in the 70 list. Recorded when TO2 test run was started with mismatched To1d owner key. Device should abort before TO2.ProveDevice
+	FIDO_LISTENER_DEVICE_70_TO1D_OWNER_MISMATCH FDOTestID = "FIDO_LISTENER_DEVICE_70_TO1D_OWNER_MISMATCH"
+	// Not in the 70 list. Recorded when TO2 test run was started with To1d countersignature case, and RV countersigns To1d. Device should only accept valid countersignature
+	FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_PRESENT FDOTestID = "FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_PRESENT"
+	FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_ABSENT  FDOTestID = "FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_ABSENT"
+	FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_INVALID FDOTestID = "FIDO_LISTENER_DEVICE_70_TO1D_COUNTERSIGN_INVALID"
+	// Not in the 70 list. Recorded when RECORD_UNKNOWN_MESSAGES is set and device sent unknown message number during TO2 test run
+	FIDO_LISTENER_DEVICE_UNKNOWN_MESSAGE FDOTestID = "FIDO_LISTENER_DEVICE_UNKNOWN_MESSAGE"
+	// Not in the 70 list. Recorded when device aborted TO2 test run with error message. Passes only when DO was serving a negative test
+	FIDO_LISTENER_DEVICE_ERROR_MESSAGE FDOTestID = "FIDO_LISTENER_DEVICE_ERROR_MESSAGE"
+	// Not in any list. Recorded when VERIFY_TLS_DEVICE_CERT is set and device TLS client certificate is not the voucher device certificate
+	FIDO_LISTENER_DEVICE_TLS_CLIENT_CERT FDOTestID = "FIDO_LISTENER_DEVICE_TLS_CLIENT_CERT"
 )
 
 var FIDO_LISTENER_60_LIST []FDOTestID = []FDOTestID{
 	FIDO_LISTENER_DEVICE_60_BAD_OVHDR_OVHEADER,
 	FIDO_LISTENER_DEVICE_60_BAD_NONCE_TO2PROVEOV,
+	FIDO_LISTENER_DEVICE_60_STALE_NONCE_TO2PROVEOV,
 	FIDO_LISTENER_DEVICE_60_BAD_EBSIGNINFO,
 	FIDO_LISTENER_DEVICE_60_BAD_HELLODEVICEHASH,
 	FIDO_LISTENER_DEVICE_60_BAD_COSE_SIGNATURE,
 	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING,
 	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING,
 	FIDO_LISTENER_DEVICE_60_MISSING_AUTHZ_HEADER,
+	FIDO_LISTENER_DEVICE_60_BAD_OWNER_PUBKEY,
+	FIDO_LISTENER_DEVICE_60_OVERSIZED_PROVEOVHDR,
+	FIDO_LISTENER_DEVICE_60_BAD_CONTENT_TYPE,
+	FIDO_LISTENER_DEVICE_60_MISSING_CONTENT_TYPE,
+	FIDO_LISTENER_DEVICE_60_BAD_MESSAGE_TYPE,
+	FIDO_LISTENER_DEVICE_60_MISSING_MESSAGE_TYPE,
 }
 
 var FIDO_LISTENER_62_LIST []FDOTestID = []FDOTestID{
 	FIDO_LISTENER_DEVICE_62_BAD_OVENTRY_COSE_SIGNATURE,
 	FIDO_LISTENER_DEVICE_62_BAD_OVNEXTENTRY_PAYLOAD,
 	FIDO_LISTENER_DEVICE_62_BAD_OVENTRYNUM,
+	FIDO_LISTENER_DEVICE_62_MISSING_OVENTRY,
 }
 
 var FIDO_LISTENER_64_LIST []FDOTestID = []FDOTestID{
@@ -84,14 +156,22 @@ var FIDO_LISTENER_64_LIST []FDOTestID = []FDOTestID{
 	FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_BYTES,
 	FIDO_LISTENER_DEVICE_64_BAD_ENC_WRAPPING,
 	FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_ENCODING,
+	FIDO_LISTENER_DEVICE_64_OVERSIZED_SETUPDEVICE,
 }
 
 var FIDO_LISTENER_66_LIST []FDOTestID = []FDOTestID{
 	FIDO_LISTENER_DEVICE_66_BAD_ENCODING,
 	FIDO_LISTENER_DEVICE_66_BAD_ENC_WRAPPING,
+	FIDO_LISTENER_DEVICE_66_ROLLBACK,
 }
 
-var FIDO_LISTENER_68_LIST []FDOTestID = []FDOTestID{}
+var FIDO_LISTENER_68_LIST []FDOTestID = []FDOTestID{
+	FIDO_LISTENER_DEVICE_68_UNKNOWN_SIM,
+	FIDO_LISTENER_DEVICE_68_BINARY_SIM,
+	FIDO_LISTENER_DEVICE_68_DONE_WITH_MORE_SERVICEINFO,
+	FIDO_LISTENER_DEVICE_68_MORE_SERVICEINFO_EMPTY,
+	FIDO_LISTENER_DEVICE_68_WRONG_ENC_CONTEXT,
+}
 
 var FIDO_LISTENER_70_LIST []FDOTestID = []FDOTestID{
 	FIDO_LISTENER_DEVICE_70_BAD_NONCE_TO2SETUPDV64,
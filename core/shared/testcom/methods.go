@@ -54,3 +54,18 @@ func ExpectGroupTests(testIds []FDOTestID, inputTestId FDOTestID) FDOTestID {
 
 	return FIDO_TEST_GROUP_SKIP
 }
+
+// Conf_DeviceCertValidity returns device certificate validity the test voucher must be generated with, or nil for the default
+func Conf_DeviceCertValidity(testId FDOTestID) *fdoshared.CertValidity {
+	var validity fdoshared.CertValidity
+	switch testId {
+	case FIDO_DOT_64_BAD_DEVICE_CERT_EXPIRED:
+		validity = fdoshared.ExpiredCertValidity()
+	case FIDO_DOT_64_BAD_DEVICE_CERT_NOT_YET_VALID:
+		validity = fdoshared.NotYetValidCertValidity()
+	default:
+		return nil
+	}
+
+	return &validity
+}
@@ -25,8 +25,10 @@ const (
 	FIDO_DEVT_30_BAD_ENCODING     FDOTestID = "FIDO_DEVT_30_BAD_ENCODING"
 	FIDO_DEVT_30_BAD_UNKNOWN_GUID FDOTestID = "FIDO_DEVT_30_BAD_UNKNOWN_GUID"
 	FIDO_DEVT_30_BAD_SIGINFO      FDOTestID = "FIDO_DEVT_30_BAD_SIGINFO"
-	FIDO_DEVT_30_POSITIVE         FDOTestID = "FIDO_DEVT_30_POSITIVE"
-	FIDO_DEVT_31_CHECK_RESP       FDOTestID = "FIDO_DEVT_31_CHECK_RESP"
+	// HelloRV30 with 15 byte GUID. RV must reject it with MESSAGE_BODY_ERROR, instead of padding it to a GUID it knows
+	FIDO_DEVT_30_BAD_GUID_LEN FDOTestID = "FIDO_DEVT_30_BAD_GUID_LEN"
+	FIDO_DEVT_30_POSITIVE     FDOTestID = "FIDO_DEVT_30_POSITIVE"
+	FIDO_DEVT_31_CHECK_RESP   FDOTestID = "FIDO_DEVT_31_CHECK_RESP"
 
 	// DEVT 32
 	FIDO_DEVT_32_BAD_PROVE_TO_RV_PAYLOAD_ENCODING FDOTestID = "FIDO_DEVT_32_BAD_PROVE_TO_RV_PAYLOAD_ENCODING"
@@ -51,6 +53,9 @@ const (
 	FIDO_DOT_64_BAD_SIGNATURE       FDOTestID = "FIDO_DOT_64_BAD_SIGNATURE"
 	FIDO_DOT_64_BAD_NONCE_PROVEDV61 FDOTestID = "FIDO_DOT_64_BAD_NONCE_PROVEDV61"
 	FIDO_DOT_64_POSITIVE            FDOTestID = "FIDO_DOT_64_POSITIVE"
+	// Use own vouchers, with device leaf certificate outside of its validity period
+	FIDO_DOT_64_BAD_DEVICE_CERT_EXPIRED       FDOTestID = "FIDO_DOT_64_BAD_DEVICE_CERT_EXPIRED"
+	FIDO_DOT_64_BAD_DEVICE_CERT_NOT_YET_VALID FDOTestID = "FIDO_DOT_64_BAD_DEVICE_CERT_NOT_YET_VALID"
 
 	// DOT66
 	FIDO_DOT_66_BAD_ENCODING        FDOTestID = "FIDO_DOT_66_BAD_ENCODING"
@@ -62,6 +67,7 @@ const (
 	FIDO_DOT_68_BAD_ENCODING         FDOTestID = "FIDO_DOT_68_BAD_ENCODING"
 	FIDO_DOT_68_BAD_ENCRYPTION       FDOTestID = "FIDO_DOT_68_BAD_ENCRYPTION"
 	FIDO_DOT_68_BAD_COMPLETION_LOGIC FDOTestID = "FIDO_DOT_68_BAD_COMPLETION_LOGIC"
+	FIDO_DOT_68_FRAGMENTED_SIMS      FDOTestID = "FIDO_DOT_68_FRAGMENTED_SIMS"
 	FIDO_DOT_68_POSITIVE             FDOTestID = "FIDO_DOT_68_POSITIVE"
 
 	// DOT70
@@ -69,6 +75,10 @@ const (
 	FIDO_DOT_70_BAD_ENCRYPTION        FDOTestID = "FIDO_DOT_70_BAD_ENCRYPTION"
 	FIDO_DOT_70_BAD_NONCE_PROVE_DV_61 FDOTestID = "FIDO_DOT_70_BAD_NONCE_PROVE_DV_61"
 	FIDO_DOT_70_POSITIVE              FDOTestID = "FIDO_DOT_70_POSITIVE"
+	// Use own vouchers. Device completes TO2, then sends HelloDevice again with the same voucher. Expected result follows run reonboard policy
+	FIDO_DOT_70_RESALE FDOTestID = "FIDO_DOT_70_RESALE"
+	// Device follows voucher RVInfo with TO1, and To1d owner address must be the DO under test. Runs only when a voucher is selected
+	FIDO_DOT_RVINFO_OWNER_ADDRESS FDOTestID = "FIDO_DOT_RVINFO_OWNER_ADDRESS"
 
 	// Voucher tests
 	FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION     FDOTestID = "FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION"
@@ -115,6 +125,7 @@ var FIDO_TEST_LIST_DEVT_30 []FDOTestID = []FDOTestID{
 	FIDO_DEVT_30_BAD_ENCODING,
 	FIDO_DEVT_30_BAD_UNKNOWN_GUID,
 	FIDO_DEVT_30_BAD_SIGINFO,
+	FIDO_DEVT_30_BAD_GUID_LEN,
 	FIDO_DEVT_30_POSITIVE,
 	FIDO_DEVT_31_CHECK_RESP,
 }
@@ -147,6 +158,11 @@ var FIDO_TEST_LIST_DOT_64 []FDOTestID = []FDOTestID{
 	FIDO_DOT_64_POSITIVE,
 }
 
+var FIDO_TEST_LIST_DOT_64_DEVICE_CERT []FDOTestID = []FDOTestID{
+	FIDO_DOT_64_BAD_DEVICE_CERT_EXPIRED,
+	FIDO_DOT_64_BAD_DEVICE_CERT_NOT_YET_VALID,
+}
+
 var FIDO_TEST_LIST_DOT_66 []FDOTestID = []FDOTestID{
 	FIDO_DOT_66_BAD_ENCODING,
 	FIDO_DOT_66_BAD_SRVINFO_PAYLOAD,
@@ -158,6 +174,7 @@ var FIDO_TEST_LIST_DOT_68 []FDOTestID = []FDOTestID{
 	FIDO_DOT_68_BAD_ENCODING,
 	FIDO_DOT_68_BAD_ENCRYPTION,
 	FIDO_DOT_68_BAD_COMPLETION_LOGIC,
+	FIDO_DOT_68_FRAGMENTED_SIMS,
 	FIDO_DOT_68_POSITIVE,
 }
 
@@ -168,6 +185,14 @@ var FIDO_TEST_LIST_DOT_70 []FDOTestID = []FDOTestID{
 	FIDO_DOT_70_POSITIVE,
 }
 
+var FIDO_TEST_LIST_DOT_RESALE []FDOTestID = []FDOTestID{
+	FIDO_DOT_70_RESALE,
+}
+
+var FIDO_TEST_LIST_DOT_RVINFO []FDOTestID = []FDOTestID{
+	FIDO_DOT_RVINFO_OWNER_ADDRESS,
+}
+
 var FIDO_TEST_LIST_VOUCHER []FDOTestID = []FDOTestID{
 	FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION,
 	FIDO_TEST_VOUCHER_HEADER_BAD_RVINFO_EMPTY,
@@ -200,6 +225,7 @@ var FIDO_TEST_TO_FDO_ERROR_CODE map[FDOTestID]fdoshared.FdoErrorCode = map[FDOTe
 	FIDO_DEVT_30_BAD_ENCODING:     fdoshared.MESSAGE_BODY_ERROR,
 	FIDO_DEVT_30_BAD_UNKNOWN_GUID: fdoshared.RESOURCE_NOT_FOUND,
 	FIDO_DEVT_30_BAD_SIGINFO:      fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DEVT_30_BAD_GUID_LEN:     fdoshared.MESSAGE_BODY_ERROR,
 
 	FIDO_DEVT_32_BAD_ENCODING:                     fdoshared.MESSAGE_BODY_ERROR,
 	FIDO_DEVT_32_BAD_PROVE_TO_RV_PAYLOAD_ENCODING: fdoshared.MESSAGE_BODY_ERROR,
@@ -216,6 +242,9 @@ var FIDO_TEST_TO_FDO_ERROR_CODE map[FDOTestID]fdoshared.FdoErrorCode = map[FDOTe
 	FIDO_DOT_64_BAD_SIGNATURE:       fdoshared.INVALID_MESSAGE_ERROR,
 	FIDO_DOT_64_BAD_NONCE_PROVEDV61: fdoshared.INVALID_MESSAGE_ERROR,
 
+	FIDO_DOT_64_BAD_DEVICE_CERT_EXPIRED:       fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DOT_64_BAD_DEVICE_CERT_NOT_YET_VALID: fdoshared.INVALID_MESSAGE_ERROR,
+
 	FIDO_DOT_66_BAD_ENCODING:        fdoshared.MESSAGE_BODY_ERROR,
 	FIDO_DOT_66_BAD_SRVINFO_PAYLOAD: fdoshared.MESSAGE_BODY_ERROR,
 	FIDO_DOT_66_BAD_ENCRYPTION:      fdoshared.MESSAGE_BODY_ERROR,
@@ -0,0 +1,73 @@
+package request
+
+import (
+	"encoding/hex"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// CampaignProtocolReport is the latest run of a single protocol test instance
+type CampaignProtocolReport struct {
+	Protocol   fdoshared.FdoToProtocol `json:"protocol"`
+	Id         string                  `json:"id"`
+	URL        string                  `json:"url"`
+	TestRunId  string                  `json:"testRunId,omitempty"`
+	Timestamp  int64                   `json:"timestamp,omitempty"`
+	InProgress bool                    `json:"inprogress"`
+	Passed     bool                    `json:"passed"`
+	Tests      RequestTestResultMap    `json:"tests"`
+}
+
+// CampaignReport consolidates TO0, TO1 and TO2 results of a certification campaign with an overall verdict
+type CampaignReport struct {
+	Timestamp int64                    `json:"timestamp"`
+	Passed    bool                     `json:"passed"`
+	Protocols []CampaignProtocolReport `json:"protocols"`
+}
+
+// NewCampaignReport uses latest run of each test instance. Campaign only passes if every protocol has a finished run with all tests passing
+func NewCampaignReport(reqtes ...RequestTestInst) CampaignReport {
+	report := CampaignReport{
+		Timestamp: time.Now().Unix(),
+		Passed:    len(reqtes) > 0,
+		Protocols: []CampaignProtocolReport{},
+	}
+
+	for _, reqte := range reqtes {
+		protocolReport := CampaignProtocolReport{
+			Protocol:   reqte.Protocol,
+			Id:         hex.EncodeToString(reqte.Uuid),
+			URL:        reqte.URL,
+			InProgress: reqte.InProgress,
+			Tests:      RequestTestResultMap{},
+		}
+
+		if len(reqte.TestsHistory) > 0 {
+			latestRun := reqte.TestsHistory[0]
+
+			protocolReport.TestRunId = latestRun.Uuid
+			protocolReport.Timestamp = latestRun.Timestamp
+			protocolReport.Tests = latestRun.Tests
+			protocolReport.Passed = !reqte.InProgress && len(latestRun.Tests) > 0 && latestRun.PassingAllTests()
+		}
+
+		report.Passed = report.Passed && protocolReport.Passed
+		report.Protocols = append(report.Protocols, protocolReport)
+	}
+
+	return report
+}
+
+// TestStates returns results of all protocols, for grouping by spec assertion
+func (h CampaignReport) TestStates() []testcom.FDOTestState {
+	testStates := []testcom.FDOTestState{}
+	for _, protocolReport := range h.Protocols {
+		for _, testState := range protocolReport.Tests {
+			testStates = append(testStates, testState)
+		}
+	}
+
+	return testStates
+}
@@ -0,0 +1,120 @@
+package request
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+}
+
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Id        string          `xml:"id,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	TestSuites []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuites maps every protocol to a test suite, so CI fails on the same verdict as the campaign. Protocol without a finished run is reported as a failed "run" test case
+func (h CampaignReport) JUnitTestSuites() JUnitTestSuites {
+	suites := JUnitTestSuites{
+		Name:       "FDO conformance campaign",
+		TestSuites: []JUnitTestSuite{},
+	}
+
+	for _, protocolReport := range h.Protocols {
+		suiteName := fmt.Sprintf("TO%d", protocolReport.Protocol)
+		suite := JUnitTestSuite{
+			Name:      suiteName,
+			Id:        protocolReport.Id,
+			TestCases: []JUnitTestCase{},
+		}
+
+		if protocolReport.Timestamp != 0 {
+			suite.Timestamp = time.Unix(protocolReport.Timestamp, 0).UTC().Format("2006-01-02T15:04:05")
+		}
+
+		testIds := []string{}
+		for testId := range protocolReport.Tests {
+			testIds = append(testIds, string(testId))
+		}
+		sort.Strings(testIds)
+
+		for _, testId := range testIds {
+			testState := protocolReport.Tests[testcom.FDOTestID(testId)]
+			testCase := JUnitTestCase{
+				Name:      testId,
+				ClassName: suiteName,
+			}
+
+			if !testState.Passed {
+				testCase.Failure = &JUnitFailure{
+					Message: testState.Error,
+					Text:    testState.Error,
+				}
+			}
+
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+
+		if protocolReport.InProgress || len(protocolReport.Tests) == 0 {
+			runFailure := "No finished test run"
+			if protocolReport.InProgress {
+				runFailure = "Test run is in progress"
+			}
+
+			suite.TestCases = append(suite.TestCases, JUnitTestCase{
+				Name:      "run",
+				ClassName: suiteName,
+				Failure: &JUnitFailure{
+					Message: runFailure,
+				},
+			})
+		}
+
+		for _, testCase := range suite.TestCases {
+			suite.Tests++
+			if testCase.Failure != nil {
+				suite.Failures++
+			}
+		}
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.TestSuites = append(suites.TestSuites, suite)
+	}
+
+	return suites
+}
+
+// JUnit encodes report as JUnit XML, one test suite per protocol
+func (h CampaignReport) JUnit() ([]byte, error) {
+	junitBytes, err := xml.MarshalIndent(h.JUnitTestSuites(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), junitBytes...), nil
+}
@@ -0,0 +1,98 @@
+package request
+
+import (
+	"encoding/xml"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func newCampaignTestInst(protocol fdoshared.FdoToProtocol, testState testcom.FDOTestState) RequestTestInst {
+	reqte := NewRequestTestInst("http://fdo.example.com", protocol)
+
+	testRun := NewRVTestRun(protocol)
+	testRun.Tests[testcom.NULL_TEST] = testState
+	reqte.TestsHistory = []RequestTestRun{testRun}
+
+	return reqte
+}
+
+func TestNewCampaignReport(t *testing.T) {
+	to0 := newCampaignTestInst(fdoshared.To0, testcom.NewSuccessTestState(testcom.NULL_TEST))
+	to1 := newCampaignTestInst(fdoshared.To1, testcom.NewSuccessTestState(testcom.NULL_TEST))
+	to2 := newCampaignTestInst(fdoshared.To2, testcom.NewSuccessTestState(testcom.NULL_TEST))
+
+	report := NewCampaignReport(to0, to1, to2)
+	if !report.Passed {
+		t.Errorf("Expected campaign to pass when all protocols pass")
+	}
+
+	if len(report.Protocols) != 3 || report.Protocols[2].Protocol != fdoshared.To2 {
+		t.Fatalf("Expected TO0, TO1 and TO2 in report order. Got %v", report.Protocols)
+	}
+
+	failedTo2 := newCampaignTestInst(fdoshared.To2, testcom.NewFailTestState(testcom.NULL_TEST, "Bad response"))
+	report = NewCampaignReport(to0, to1, failedTo2)
+	if report.Passed || report.Protocols[2].Passed || !report.Protocols[0].Passed {
+		t.Errorf("Expected only TO2 and overall verdict to fail")
+	}
+
+	inProgressTo1 := to1
+	inProgressTo1.InProgress = true
+	report = NewCampaignReport(to0, inProgressTo1, to2)
+	if report.Passed {
+		t.Errorf("Expected campaign with run in progress to not pass")
+	}
+
+	neverRun := NewRequestTestInst("http://fdo.example.com", fdoshared.To2)
+	report = NewCampaignReport(to0, to1, neverRun)
+	if report.Passed || report.Protocols[2].TestRunId != "" {
+		t.Errorf("Expected campaign with protocol that was never run to not pass")
+	}
+
+	if NewCampaignReport().Passed {
+		t.Errorf("Expected empty campaign to not pass")
+	}
+}
+
+func TestCampaignReport_JUnit(t *testing.T) {
+	to0 := newCampaignTestInst(fdoshared.To0, testcom.NewSuccessTestState(testcom.NULL_TEST))
+	to1 := newCampaignTestInst(fdoshared.To1, testcom.NewFailTestState(testcom.NULL_TEST, "Bad response"))
+	neverRun := NewRequestTestInst("http://fdo.example.com", fdoshared.To2)
+
+	report := NewCampaignReport(to0, to1, neverRun)
+
+	junitBytes, err := report.JUnit()
+	if err != nil {
+		t.Fatalf("Failed to encode JUnit report. %s", err.Error())
+	}
+
+	var decoded JUnitTestSuites
+	err = xml.Unmarshal(junitBytes, &decoded)
+	if err != nil {
+		t.Fatalf("Failed to decode JUnit report. %s", err.Error())
+	}
+
+	if len(decoded.TestSuites) != 3 || decoded.TestSuites[0].Name != "TO0" || decoded.TestSuites[2].Name != "TO2" {
+		t.Fatalf("Expected TO0, TO1 and TO2 test suites. Got %+v", decoded.TestSuites)
+	}
+
+	if decoded.Tests != 3 || decoded.Failures != 2 {
+		t.Errorf("Expected 3 tests with 2 failures. Got %d tests with %d failures", decoded.Tests, decoded.Failures)
+	}
+
+	failedCase := decoded.TestSuites[1].TestCases[0]
+	if failedCase.Name != string(testcom.NULL_TEST) || failedCase.Failure == nil || failedCase.Failure.Message != "Bad response" {
+		t.Errorf("Expected failed TO1 test case with error message. Got %+v", failedCase)
+	}
+
+	if decoded.TestSuites[0].TestCases[0].Failure != nil {
+		t.Errorf("Expected passing TO0 test case without failure")
+	}
+
+	runCase := decoded.TestSuites[2].TestCases
+	if len(runCase) != 1 || runCase[0].Name != "run" || runCase[0].Failure == nil {
+		t.Errorf("Expected TO2 that never ran to be reported as failed run. Got %+v", runCase)
+	}
+}
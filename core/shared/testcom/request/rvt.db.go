@@ -11,6 +11,19 @@ import (
 
 type TestVouchers map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher
 
+// GetVoucherByGuid returns voucher of the device, from any test
+func (h TestVouchers) GetVoucherByGuid(guid fdoshared.FdoGuid) (*fdoshared.DeviceCredAndVoucher, error) {
+	for _, vouchers := range h {
+		for i := range vouchers {
+			if vouchers[i].WawDeviceCredential.DCGuid == guid {
+				return &vouchers[i], nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("No voucher found for the guid %s", guid.GetFormatted())
+}
+
 func (h *TestVouchers) GetVoucher(testId testcom.FDOTestID) (*fdoshared.DeviceCredAndVoucher, error) {
 	for k, v := range *h {
 		if k == testId {
@@ -23,6 +36,20 @@ func (h *TestVouchers) GetVoucher(testId testcom.FDOTestID) (*fdoshared.DeviceCr
 	return nil, fmt.Errorf("No vouchers found for the id %s", testId)
 }
 
+// FilterByGuids returns only vouchers with guids in the list. Tests left without vouchers are dropped
+func (h TestVouchers) FilterByGuids(guids fdoshared.FdoGuidList) TestVouchers {
+	result := TestVouchers{}
+	for testId, vouchers := range h {
+		for _, voucher := range vouchers {
+			if guids.Contains(voucher.WawDeviceCredential.DCGuid) {
+				result[testId] = append(result[testId], voucher)
+			}
+		}
+	}
+
+	return result
+}
+
 type RequestTestInst struct {
 	_              struct{} `cbor:",toarray"`
 	Uuid           []byte
@@ -33,6 +60,46 @@ type RequestTestInst struct {
 	CurrentTestRun RequestTestRun
 	TestsHistory   []RequestTestRun
 	TestVouchers   TestVouchers
+	// Wait for each message of the run. Set from execution request, not stored
+	TestTimeout time.Duration `cbor:"-"`
+	// KEX and cipher suite of the run. Set from execution request, not stored. Nil selects suite from each voucher device SigInfo
+	KexCipherSuite *fdoshared.KexCipherSuite `cbor:"-"`
+	// Vouchers of the named suite the run is limited to. Set from execution request, not stored
+	SuiteGuids fdoshared.FdoGuidList `cbor:"-"`
+	// Tests the run is limited to, and run it replays. Set from replay request, not stored. Empty runs all tests
+	OnlyTests []testcom.FDOTestID `cbor:"-"`
+	ReplayOf  string              `cbor:"-"`
+	// Expected owner handling of device onboarded again with the same voucher. Set from execution request, not stored. Empty skips FIDO_DOT_70_RESALE
+	ReonboardPolicy fdoshared.ReonboardPolicy `cbor:"-"`
+	// Voucher, e.g. imported, whose RVInfo the run follows before TO2 tests. Set from execution request, not stored. Nil skips FIDO_DOT_RVINFO_OWNER_ADDRESS
+	RVInfoGuid *fdoshared.FdoGuid `cbor:"-"`
+}
+
+const DEFAULT_TEST_TIMEOUT time.Duration = 30 * time.Second
+const MAX_TEST_TIMEOUT time.Duration = 10 * time.Minute
+
+// ShouldRun reports whether the test is part of the run. All tests are, unless OnlyTests is set
+func (h RequestTestInst) ShouldRun(testId testcom.FDOTestID) bool {
+	if len(h.OnlyTests) == 0 {
+		return true
+	}
+
+	for _, onlyTest := range h.OnlyTests {
+		if onlyTest == testId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetTestTimeout returns TestTimeout, or DEFAULT_TEST_TIMEOUT when not set
+func (h RequestTestInst) GetTestTimeout() time.Duration {
+	if h.TestTimeout <= 0 {
+		return DEFAULT_TEST_TIMEOUT
+	}
+
+	return h.TestTimeout
 }
 
 func NewRequestTestInst(url string, protocol fdoshared.FdoToProtocol) RequestTestInst {
@@ -56,6 +123,20 @@ type RequestTestRun struct {
 	Timestamp int64                   `json:"timestamp"`
 	Tests     RequestTestResultMap    `json:"tests"`
 	Protocol  fdoshared.FdoToProtocol `json:"protocol"`
+	// Set when the run was executed with a selected KEX and cipher suite
+	KexSuiteName    fdoshared.KexSuiteName    `json:"kexSuiteName,omitempty"`
+	CipherSuiteName fdoshared.CipherSuiteName `json:"cipherSuiteName,omitempty"`
+	// Run config kept for replay
+	TestTimeout time.Duration         `cbor:"testTimeout" json:"-"`
+	SuiteGuids  fdoshared.FdoGuidList `cbor:"suiteGuids" json:"-"`
+	ReplayOf    string                `json:"replayOf,omitempty"`
+	// KEX and cipher suite the owner accepted in ProveDevice64, selected for the run or not
+	NegotiatedKexSuiteName    fdoshared.KexSuiteName    `json:"negotiatedKexSuiteName,omitempty"`
+	NegotiatedCipherSuiteName fdoshared.CipherSuiteName `json:"negotiatedCipherSuiteName,omitempty"`
+	// Reonboard policy FIDO_DOT_70_RESALE expected from the owner
+	ReonboardPolicy fdoshared.ReonboardPolicy `json:"reonboardPolicy,omitempty"`
+	// Tests skipped or reported by the run without failing it
+	Notes []string `json:"notes,omitempty"`
 }
 
 func (h *RequestTestRun) PassingAllTests() bool {
@@ -68,6 +149,18 @@ func (h *RequestTestRun) PassingAllTests() bool {
 	return true
 }
 
+// GetFailedTestIDs returns tests that did not pass, in no particular order
+func (h *RequestTestRun) GetFailedTestIDs() []testcom.FDOTestID {
+	result := []testcom.FDOTestID{}
+	for fi, testState := range h.Tests {
+		if !testState.Passed {
+			result = append(result, fi)
+		}
+	}
+
+	return result
+}
+
 func (h *RequestTestRun) GetAllTestIDs() []testcom.FDOTestID {
 	result := make([]testcom.FDOTestID, 0, len(h.Tests))
 	for fi := range h.Tests {
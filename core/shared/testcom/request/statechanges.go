@@ -0,0 +1,57 @@
+package request
+
+import (
+	"sort"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// Max number of recorded state changes per test of a run. Oldest changes are dropped first
+const MAX_STATE_CHANGES_PER_TEST int = 32
+
+// TestStateChange is a single reported result of a test, e.g. fail followed by pass on retry
+type TestStateChange struct {
+	Timestamp int64  `cbor:"timestamp" json:"timestamp"`
+	Passed    bool   `cbor:"passed" json:"passed"`
+	Error     string `cbor:"error,omitempty" json:"error,omitempty"`
+}
+
+// RunStateChanges is append-only history of reported results of a run, per test
+type RunStateChanges map[testcom.FDOTestID][]TestStateChange
+
+func (h RunStateChanges) Append(testID testcom.FDOTestID, testState testcom.FDOTestState) {
+	changes := append(h[testID], TestStateChange{
+		Timestamp: time.Now().UnixMilli(),
+		Passed:    testState.Passed,
+		Error:     testState.Error,
+	})
+
+	if len(changes) > MAX_STATE_CHANGES_PER_TEST {
+		changes = changes[len(changes)-MAX_STATE_CHANGES_PER_TEST:]
+	}
+
+	h[testID] = changes
+}
+
+// Flaky returns tests that both passed and failed within the run
+func (h RunStateChanges) Flaky() []testcom.FDOTestID {
+	flaky := []testcom.FDOTestID{}
+	for testID, changes := range h {
+		passed, failed := false, false
+		for _, change := range changes {
+			passed = passed || change.Passed
+			failed = failed || !change.Passed
+		}
+
+		if passed && failed {
+			flaky = append(flaky, testID)
+		}
+	}
+
+	sort.Slice(flaky, func(i, j int) bool {
+		return flaky[i] < flaky[j]
+	})
+
+	return flaky
+}
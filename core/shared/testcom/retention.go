@@ -0,0 +1,41 @@
+package testcom
+
+import (
+	"sort"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// StoredRun locates a single run in the test history of a request test or listener test instance
+type StoredRun struct {
+	InstId    []byte
+	Protocol  fdoshared.FdoToProtocol
+	Listener  bool
+	RunId     string
+	Timestamp int64
+	Pinned    bool
+}
+
+// SelectRunsToEvict returns unpinned runs beyond the newest keep ones, oldest first. Pinned runs are never evicted and do not count towards keep
+func SelectRunsToEvict(runs []StoredRun, keep int) []StoredRun {
+	if keep < 0 {
+		keep = 0
+	}
+
+	unpinned := []StoredRun{}
+	for _, run := range runs {
+		if !run.Pinned {
+			unpinned = append(unpinned, run)
+		}
+	}
+
+	if len(unpinned) <= keep {
+		return []StoredRun{}
+	}
+
+	sort.SliceStable(unpinned, func(i, j int) bool {
+		return unpinned[i].Timestamp < unpinned[j].Timestamp
+	})
+
+	return unpinned[:len(unpinned)-keep]
+}
@@ -0,0 +1,41 @@
+package testcom
+
+import "testing"
+
+func TestSelectRunsToEvict(t *testing.T) {
+	runs := []StoredRun{
+		{RunId: "run-3", Timestamp: 300},
+		{RunId: "run-1", Timestamp: 100, Pinned: true},
+		{RunId: "run-5", Timestamp: 500},
+		{RunId: "run-2", Timestamp: 200},
+		{RunId: "run-4", Timestamp: 400, Pinned: true},
+		{RunId: "run-0", Timestamp: 50},
+	}
+
+	evicted := SelectRunsToEvict(runs, 2)
+
+	expectedOrder := []string{"run-0", "run-2"}
+	if len(evicted) != len(expectedOrder) {
+		t.Fatalf("Expected %d runs evicted, got %d", len(expectedOrder), len(evicted))
+	}
+
+	for i, run := range evicted {
+		if run.RunId != expectedOrder[i] {
+			t.Errorf("Expected eviction %d to be %s, got %s", i, expectedOrder[i], run.RunId)
+		}
+
+		if run.Pinned {
+			t.Errorf("Pinned run %s must not be evicted", run.RunId)
+		}
+	}
+
+	evicted = SelectRunsToEvict(runs, 0)
+	if len(evicted) != 4 {
+		t.Errorf("Expected all 4 unpinned runs evicted, got %d", len(evicted))
+	}
+
+	evicted = SelectRunsToEvict(runs, 10)
+	if len(evicted) != 0 {
+		t.Errorf("Expected no runs evicted under the cap, got %d", len(evicted))
+	}
+}
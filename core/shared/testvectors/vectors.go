@@ -0,0 +1,270 @@
+package testvectors
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+type VectorType string
+
+const (
+	VECTOR_VOUCHER    VectorType = "voucher"
+	VECTOR_COSE_SIGN1 VectorType = "cose_sign1"
+	VECTOR_ENCRYPTED  VectorType = "encrypted"
+)
+
+type VectorExpect string
+
+const (
+	EXPECT_VALID   VectorExpect = "valid"
+	EXPECT_INVALID VectorExpect = "invalid"
+)
+
+// TestVector is a single reference vector. Binary fields are hex encoded
+type TestVector struct {
+	Name   string       `json:"name"`
+	Type   VectorType   `json:"type"`
+	Input  string       `json:"input"`
+	Expect VectorExpect `json:"expect"`
+
+	// voucher: optional device HMAC secret to verify OVHeaderHMac
+	HmacSecret string `json:"hmacSecret,omitempty"`
+
+	// cose_sign1: CBOR encoded FdoPublicKey
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// encrypted: session key material and expected plaintext
+	CipherSuite fdoshared.CipherSuiteName `json:"cipherSuite,omitempty"`
+	ShSe        string                    `json:"shSe,omitempty"`
+	ContextRand string                    `json:"contextRand,omitempty"`
+	Plaintext   string                    `json:"plaintext,omitempty"`
+}
+
+type VectorResult struct {
+	File   string `json:"file"`
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+
+	// voucher: OVEntry key anomalies. Valid per spec, so they do not fail the vector
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func decodeHexField(name string, value string) ([]byte, error) {
+	result, err := hex.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s hex. %s", name, err.Error())
+	}
+
+	return result, nil
+}
+
+// verifyVoucher returns OVEntry key anomalies of the valid voucher as warnings
+func verifyVoucher(vector TestVector, input []byte) ([]string, error) {
+	var voucherInst fdoshared.OwnershipVoucher
+	err := fdoshared.CborCust.Unmarshal(input, &voucherInst)
+	if err != nil {
+		return nil, errors.New("error decoding voucher. " + err.Error())
+	}
+
+	err = voucherInst.Validate()
+	if err != nil {
+		return nil, errors.New("error validating voucher. " + err.Error())
+	}
+
+	anomalies, err := voucherInst.OVEntryArray.CheckKeyAnomalies(voucherInst.OVHeaderTag)
+	if err != nil {
+		return nil, errors.New("error validating voucher. " + err.Error())
+	}
+
+	if vector.HmacSecret != "" {
+		hmacSecret, err := decodeHexField("hmacSecret", vector.HmacSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		err = fdoshared.VerifyHMac(voucherInst.OVHeaderTag, voucherInst.OVHeaderHMac, hmacSecret)
+		if err != nil {
+			return nil, errors.New("error verifying OVHeaderHMac. " + err.Error())
+		}
+	}
+
+	var warnings []string
+	for _, anomaly := range anomalies {
+		warnings = append(warnings, anomaly.Message)
+	}
+
+	return warnings, nil
+}
+
+func verifyCoseSign1(vector TestVector, input []byte) error {
+	publicKeyBytes, err := decodeHexField("publicKey", vector.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	var publicKey fdoshared.FdoPublicKey
+	err = fdoshared.CborCust.Unmarshal(publicKeyBytes, &publicKey)
+	if err != nil {
+		return errors.New("error decoding public key. " + err.Error())
+	}
+
+	var coseSig fdoshared.CoseSignature
+	err = fdoshared.CborCust.Unmarshal(input, &coseSig)
+	if err != nil {
+		return errors.New("error decoding COSE_Sign1. " + err.Error())
+	}
+
+	return fdoshared.VerifyCoseSignature(coseSig, publicKey)
+}
+
+func verifyEncrypted(vector TestVector, input []byte) error {
+	shSe, err := decodeHexField("shSe", vector.ShSe)
+	if err != nil {
+		return err
+	}
+
+	contextRand, err := decodeHexField("contextRand", vector.ContextRand)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := decodeHexField("plaintext", vector.Plaintext)
+	if err != nil {
+		return err
+	}
+
+	decrypted, err := fdoshared.RemoveEncryptionWrapping(input, fdoshared.SessionKeyInfo{
+		ShSe:        shSe,
+		ContextRand: contextRand,
+	}, vector.CipherSuite)
+	if err != nil {
+		return errors.New("error decrypting. " + err.Error())
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		return errors.New("decrypted payload does not match expected plaintext")
+	}
+
+	return nil
+}
+
+// RunVector runs vector through the server parsing/verification. Passes when outcome matches vector expectation
+func RunVector(vector TestVector) VectorResult {
+	result := VectorResult{
+		Name: vector.Name,
+	}
+
+	if vector.Expect != EXPECT_VALID && vector.Expect != EXPECT_INVALID {
+		result.Error = fmt.Sprintf("unknown expect \"%s\"", vector.Expect)
+		return result
+	}
+
+	input, err := decodeHexField("input", vector.Input)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	var verifyErr error
+	switch vector.Type {
+	case VECTOR_VOUCHER:
+		result.Warnings, verifyErr = verifyVoucher(vector, input)
+	case VECTOR_COSE_SIGN1:
+		verifyErr = verifyCoseSign1(vector, input)
+	case VECTOR_ENCRYPTED:
+		verifyErr = verifyEncrypted(vector, input)
+	default:
+		result.Error = fmt.Sprintf("unknown vector type \"%s\"", vector.Type)
+		return result
+	}
+
+	if vector.Expect == EXPECT_VALID && verifyErr != nil {
+		result.Error = "expected valid. " + verifyErr.Error()
+		return result
+	}
+
+	if vector.Expect == EXPECT_INVALID && verifyErr == nil {
+		result.Error = "expected invalid, but server accepted it"
+		return result
+	}
+
+	result.Passed = true
+	return result
+}
+
+// LoadVectorFile reads JSON file containing a single vector or an array of vectors
+func LoadVectorFile(filePath string) ([]TestVector, error) {
+	fileBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file \"%s\". %s", filePath, err.Error())
+	}
+
+	fileBytes = bytes.TrimSpace(fileBytes)
+	if len(fileBytes) > 0 && fileBytes[0] == '[' {
+		var vectors []TestVector
+		err = json.Unmarshal(fileBytes, &vectors)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding file \"%s\". %s", filePath, err.Error())
+		}
+
+		return vectors, nil
+	}
+
+	var vector TestVector
+	err = json.Unmarshal(fileBytes, &vector)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding file \"%s\". %s", filePath, err.Error())
+	}
+
+	return []TestVector{vector}, nil
+}
+
+// RunDirectory runs every vector from *.json files in the folder, in file name order
+func RunDirectory(folderPath string) ([]VectorResult, error) {
+	files, err := os.ReadDir(folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileNames []string
+	for _, file := range files {
+		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
+			fileNames = append(fileNames, file.Name())
+		}
+	}
+	sort.Strings(fileNames)
+
+	if len(fileNames) == 0 {
+		return nil, errors.New("no vector files found in folder")
+	}
+
+	var results []VectorResult
+	for _, fileName := range fileNames {
+		vectors, err := LoadVectorFile(filepath.Join(folderPath, fileName))
+		if err != nil {
+			results = append(results, VectorResult{
+				File:  fileName,
+				Error: err.Error(),
+			})
+			continue
+		}
+
+		for _, vector := range vectors {
+			result := RunVector(vector)
+			result.File = fileName
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,226 @@
+package testvectors
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// newTestVoucherVector signs a voucher chain where OVEntry i carries owner key entryKeys[i]. Key 0 is the manufacturer key
+func newTestVoucherVector(t *testing.T, name string, entryKeys []int, expect VectorExpect) TestVector {
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential: %v", err)
+	}
+
+	var privKeys []interface{}
+	var pubKeys []fdoshared.FdoPublicKey
+	for i := 0; i < 3; i++ {
+		privKey, pubKey, err := fdoshared.GeneratePKIXECKeypair(fdoshared.StSECP256R1)
+		if err != nil {
+			t.Fatalf("Failed to generate keypair: %v", err)
+		}
+
+		privKeys = append(privKeys, privKey)
+		pubKeys = append(pubKeys, *pubKey)
+	}
+
+	ovHeaderBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OwnershipVoucherHeader{
+		OVHProtVer:         fdoshared.ProtVer101,
+		OVGuid:             credential.DCGuid,
+		OVRvInfo:           fdoshared.RendezvousInfo{},
+		OVDeviceInfo:       credential.DCDeviceInfo,
+		OVPublicKey:        pubKeys[0],
+		OVDevCertChainHash: &credential.DCCertificateChainHash,
+	})
+
+	ovHeaderHmac, err := fdoshared.GenerateFdoHmac(ovHeaderBytes, credential.DCHmacAlg, credential.DCHmacSecret)
+	if err != nil {
+		t.Fatalf("Failed to generate header HMAC: %v", err)
+	}
+
+	ovHeaderHmacBytes, _ := fdoshared.CborCust.Marshal(ovHeaderHmac)
+	prevEntryHash, _ := fdoshared.GenerateFdoHash(append(append([]byte{}, ovHeaderBytes...), ovHeaderHmacBytes...), credential.DCHashAlg)
+	hdrInfoHash, _ := fdoshared.GenerateFdoHash(append(credential.DCGuid[:], []byte(credential.DCDeviceInfo)...), credential.DCHashAlg)
+
+	ovEntries := fdoshared.OVEntryArray{}
+	prevKey := 0
+	for _, keyIndex := range entryKeys {
+		payloadBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OVEntryPayload{
+			OVEHashPrevEntry: prevEntryHash,
+			OVEHashHdrInfo:   hdrInfoHash,
+			OVEPubKey:        pubKeys[keyIndex],
+		})
+
+		ovEntry, err := fdoshared.GenerateCoseSignature(payloadBytes, fdoshared.ProtectedHeader{Alg: fdoshared.GetIntRef(int(fdoshared.StSECP256R1))}, fdoshared.UnprotectedHeader{}, privKeys[prevKey], fdoshared.StSECP256R1)
+		if err != nil {
+			t.Fatalf("Failed to sign OVEntry: %v", err)
+		}
+
+		ovEntryBytes, _ := fdoshared.CborCust.Marshal(ovEntry)
+		prevEntryHash, _ = fdoshared.GenerateFdoHash(ovEntryBytes, credential.DCHashAlg)
+		prevKey = keyIndex
+
+		ovEntries = append(ovEntries, *ovEntry)
+	}
+
+	voucherBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OwnershipVoucher{
+		OVProtVer:      fdoshared.ProtVer101,
+		OVHeaderTag:    ovHeaderBytes,
+		OVHeaderHMac:   ovHeaderHmac,
+		OVDevCertChain: &credential.DCCertificateChain,
+		OVEntryArray:   ovEntries,
+	})
+
+	return TestVector{
+		Name:       name,
+		Type:       VECTOR_VOUCHER,
+		Input:      hex.EncodeToString(voucherBytes),
+		Expect:     expect,
+		HmacSecret: hex.EncodeToString(credential.DCHmacSecret),
+	}
+}
+
+func newTestVectors(t *testing.T) []TestVector {
+	privKey, pubKey, err := fdoshared.GeneratePKIXECKeypair(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate keypair: %v", err)
+	}
+
+	coseSig, err := fdoshared.GenerateCoseSignature([]byte("test vector"), fdoshared.ProtectedHeader{Alg: fdoshared.GetIntRef(int(fdoshared.StSECP256R1))}, fdoshared.UnprotectedHeader{}, privKey, fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate COSE signature: %v", err)
+	}
+
+	coseSigBytes, _ := fdoshared.CborCust.Marshal(coseSig)
+	pubKeyBytes, _ := fdoshared.CborCust.Marshal(pubKey)
+
+	tamperedSig := *coseSig
+	tamperedSig.Payload = []byte("tampered")
+	tamperedSigBytes, _ := fdoshared.CborCust.Marshal(tamperedSig)
+
+	sessionKey := fdoshared.SessionKeyInfo{
+		ShSe:        fdoshared.NewHmacKey(fdoshared.HASH_HMAC_SHA256),
+		ContextRand: fdoshared.NewHmacKey(fdoshared.HASH_HMAC_SHA256),
+	}
+	plaintext := []byte{0x82, 0x01, 0x02}
+
+	encrypted, err := fdoshared.AddEncryptionWrapping(plaintext, sessionKey, fdoshared.CIPHER_A128GCM)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	encryptedVector := TestVector{
+		Name:        "A128GCM roundtrip",
+		Type:        VECTOR_ENCRYPTED,
+		Input:       hex.EncodeToString(encrypted),
+		Expect:      EXPECT_VALID,
+		CipherSuite: fdoshared.CIPHER_A128GCM,
+		ShSe:        hex.EncodeToString(sessionKey.ShSe),
+		ContextRand: hex.EncodeToString(sessionKey.ContextRand),
+		Plaintext:   hex.EncodeToString(plaintext),
+	}
+
+	wrongPlaintextVector := encryptedVector
+	wrongPlaintextVector.Name = "A128GCM wrong plaintext"
+	wrongPlaintextVector.Plaintext = "00"
+	wrongPlaintextVector.Expect = EXPECT_INVALID
+
+	return []TestVector{
+		{
+			Name:      "ES256 valid signature",
+			Type:      VECTOR_COSE_SIGN1,
+			Input:     hex.EncodeToString(coseSigBytes),
+			Expect:    EXPECT_VALID,
+			PublicKey: hex.EncodeToString(pubKeyBytes),
+		},
+		{
+			Name:      "ES256 tampered payload",
+			Type:      VECTOR_COSE_SIGN1,
+			Input:     hex.EncodeToString(tamperedSigBytes),
+			Expect:    EXPECT_INVALID,
+			PublicKey: hex.EncodeToString(pubKeyBytes),
+		},
+		encryptedVector,
+		wrongPlaintextVector,
+		{
+			Name:   "Garbage voucher",
+			Type:   VECTOR_VOUCHER,
+			Input:  "a0",
+			Expect: EXPECT_INVALID,
+		},
+		newTestVoucherVector(t, "Voucher chain", []int{1, 2}, EXPECT_VALID),
+		newTestVoucherVector(t, "Voucher chain owner extended to itself", []int{1, 1, 2}, EXPECT_VALID),
+		newTestVoucherVector(t, "Voucher chain reused OVEntry key", []int{1, 2, 1}, EXPECT_VALID),
+		newTestVoucherVector(t, "Voucher chain reused manufacturer key", []int{1, 0}, EXPECT_VALID),
+	}
+}
+
+func TestRunVector(t *testing.T) {
+	for _, vector := range newTestVectors(t) {
+		result := RunVector(vector)
+		if !result.Passed {
+			t.Errorf("Vector \"%s\" failed: %s", vector.Name, result.Error)
+		}
+	}
+
+	// Server outcome disagreeing with expectation must fail
+	vector := newTestVectors(t)[0]
+	vector.Expect = EXPECT_INVALID
+	if RunVector(vector).Passed {
+		t.Errorf("Expected vector with wrong expectation to fail")
+	}
+
+	vector.Type = "unknown"
+	if RunVector(vector).Passed {
+		t.Errorf("Expected vector with unknown type to fail")
+	}
+
+	// Resale back to a previous owner is valid, and is only reported as warning
+	vector = newTestVoucherVector(t, "Voucher chain reused OVEntry key", []int{1, 2, 1}, EXPECT_VALID)
+	if result := RunVector(vector); !result.Passed || len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "non-adjacent") {
+		t.Errorf("Expected reused key to pass with warning. Got %v, %s", result.Warnings, result.Error)
+	}
+}
+
+func TestRunDirectory(t *testing.T) {
+	vectors := newTestVectors(t)
+	folderPath := t.TempDir()
+
+	arrayBytes, _ := json.Marshal(vectors[1:])
+	singleBytes, _ := json.Marshal(vectors[0])
+
+	os.WriteFile(filepath.Join(folderPath, "a.json"), singleBytes, 0644)
+	os.WriteFile(filepath.Join(folderPath, "b.json"), arrayBytes, 0644)
+	os.WriteFile(filepath.Join(folderPath, "c.json"), []byte("{broken"), 0644)
+	os.WriteFile(filepath.Join(folderPath, "readme.txt"), []byte("ignored"), 0644)
+
+	results, err := RunDirectory(folderPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(results) != len(vectors)+1 {
+		t.Fatalf("Expected %d results, got %d", len(vectors)+1, len(results))
+	}
+
+	for i, result := range results[:len(vectors)] {
+		if !result.Passed {
+			t.Errorf("Result %d (%s) failed: %s", i, result.Name, result.Error)
+		}
+	}
+
+	if results[len(vectors)].Passed || results[len(vectors)].File != "c.json" {
+		t.Errorf("Expected broken file to be reported as failed")
+	}
+
+	_, err = RunDirectory(t.TempDir())
+	if err == nil {
+		t.Errorf("Expected error for empty folder")
+	}
+}
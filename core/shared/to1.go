@@ -1,11 +1,40 @@
 package fdoshared
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
 type HelloRV30 struct {
 	_         struct{} `cbor:",toarray"`
 	Guid      FdoGuid
 	EASigInfo SigInfo
 }
 
+// FdoGuid silently pads or truncates byte strings of other length, so GUID is checked as sent
+type helloRV30RawGuid struct {
+	_         struct{} `cbor:",toarray"`
+	Guid      []byte
+	EASigInfo cbor.RawMessage
+}
+
+// CheckHelloRV30GuidLen rejects HelloRV30 with GUID that is not 16 bytes
+func CheckHelloRV30GuidLen(helloRV30Bytes []byte) error {
+	var helloRV30 helloRV30RawGuid
+	err := CborCust.Unmarshal(helloRV30Bytes, &helloRV30)
+	if err != nil {
+		return errors.New("error decoding HelloRV30 GUID. " + err.Error())
+	}
+
+	if len(helloRV30.Guid) != len(FdoGuid{}) {
+		return fmt.Errorf("HelloRV30 GUID must be %d bytes. Got %d", len(FdoGuid{}), len(helloRV30.Guid))
+	}
+
+	return nil
+}
+
 type HelloRVAck31 struct {
 	_             struct{} `cbor:",toarray"`
 	NonceTO1Proof FdoNonce
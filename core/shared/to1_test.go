@@ -0,0 +1,20 @@
+package fdoshared
+
+import "testing"
+
+func TestCheckHelloRV30GuidLen(t *testing.T) {
+	guid := NewFdoGuid()
+
+	helloRV30Bytes, _ := CborCust.Marshal(HelloRV30{Guid: guid})
+	err := CheckHelloRV30GuidLen(helloRV30Bytes)
+	if err != nil {
+		t.Errorf("Expected 16 byte GUID to be accepted. %s", err.Error())
+	}
+
+	for _, badGuid := range [][]byte{guid[:15], append(guid[:], 0x00), {}} {
+		badHelloRV30Bytes, _ := CborCust.Marshal([]interface{}{badGuid, SigInfo{}})
+		if CheckHelloRV30GuidLen(badHelloRV30Bytes) == nil {
+			t.Errorf("Expected %d byte GUID to be rejected", len(badGuid))
+		}
+	}
+}
@@ -1,8 +1,15 @@
 package fdoshared
 
 import (
+	"bytes"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 )
 
@@ -20,6 +27,146 @@ func RespondFDOError(w http.ResponseWriter, r *http.Request, errorCode FdoErrorC
 	w.Write(fdoErrorBytes)
 }
 
+// Set once on startup from RECORD_UNKNOWN_MESSAGES
+var RecordUnknownMessages bool = false
+
+// Set once on startup from VERIFY_TLS_DEVICE_CERT
+var VerifyTLSDeviceCert bool = false
+
+// CheckTLSDeviceCert checks that TLS client certificate of the request is the device certificate of the voucher.
+// Requests without TLS, e.g. on the plain HTTP port, are not checked
+func CheckTLSDeviceCert(r *http.Request, ovDevCertChain *[]X509CertificateBytes) error {
+	if r.TLS == nil {
+		return nil
+	}
+
+	clientCert := GetTLSClientCert(r)
+	if clientCert == nil {
+		return errors.New("device did not present TLS client certificate")
+	}
+
+	if ovDevCertChain == nil || len(*ovDevCertChain) == 0 {
+		return errors.New("voucher has no OVDevCertChain to match TLS client certificate against")
+	}
+
+	if !bytes.Equal(clientCert.Raw, (*ovDevCertChain)[0]) {
+		return fmt.Errorf("TLS client certificate %s is not the device certificate of the voucher", clientCert.Subject.String())
+	}
+
+	return nil
+}
+
+// Set once on startup from TLS_CLIENT_CA. nil when mTLS is disabled
+var TLSClientCAs *x509.CertPool = nil
+
+// LoadTLSClientCAs reads PEM CA bundle used to verify TLS client certificates
+func LoadTLSClientCAs(path string) (*x509.CertPool, error) {
+	caBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("Failed to read TLS client CA bundle. " + err.Error())
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caBytes) {
+		return nil, fmt.Errorf("TLS client CA bundle %s contains no certificates", path)
+	}
+
+	return clientCAs, nil
+}
+
+// GetTLSClientCert returns TLS client certificate presented with the request, verified leaf when the chain was verified.
+// Returns nil for requests without TLS or without client certificate
+func GetTLSClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil {
+		return nil
+	}
+
+	if len(r.TLS.VerifiedChains) != 0 && len(r.TLS.VerifiedChains[0]) != 0 {
+		return r.TLS.VerifiedChains[0][0]
+	}
+
+	if len(r.TLS.PeerCertificates) != 0 {
+		return r.TLS.PeerCertificates[0]
+	}
+
+	return nil
+}
+
+// RequireTLSClientCert rejects FDO messages that did not come with TLS client certificate verified against clientCAs, before they reach the handlers.
+// Chain itself is verified by the TLS listener. Other paths, and all requests when clientCAs is nil, are passed through
+func RequireTLSClientCert(clientCAs *x509.CertPool, next http.Handler) http.Handler {
+	if clientCAs == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msgNum, isFdoMessage := ParseFdoMessageNumber(r.URL.Path)
+		if !isFdoMessage {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			log.Printf("Rejected %s from %s: missing verified TLS client certificate", r.URL.Path, r.RemoteAddr)
+			RespondFDOError(w, r, MESSAGE_BODY_ERROR, msgNum, "Unauthorized! Missing verified TLS client certificate!", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ParseFdoMessageNumber returns message number of FDO message path. Returns false for non numeric or out of range numbers
+func ParseFdoMessageNumber(urlPath string) (FdoCmd, bool) {
+	if !strings.HasPrefix(urlPath, FDO_101_URL_BASE) {
+		return 0, false
+	}
+
+	msgNum, err := strconv.ParseUint(strings.TrimPrefix(urlPath, FDO_101_URL_BASE), 10, 8)
+	if err != nil {
+		return 0, false
+	}
+
+	return FdoCmd(msgNum), true
+}
+
+// RespondUnknownMessage responds to the message number that is not served, with FDO error instead of a bare 404
+func RespondUnknownMessage(w http.ResponseWriter, r *http.Request) {
+	msgNum, _ := ParseFdoMessageNumber(r.URL.Path)
+
+	RespondFDOError(w, r, INVALID_MESSAGE_ERROR, msgNum, fmt.Sprintf("Unknown message %s", strings.TrimPrefix(r.URL.Path, FDO_101_URL_BASE)), http.StatusBadRequest)
+}
+
+const (
+	BEARER_TOKEN_MIN_LENGTH int = 16
+	BEARER_TOKEN_MAX_LENGTH int = 256
+)
+
+func isBearerTokenChar(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || strings.IndexByte("-._~+/=", c) != -1
+}
+
+// ParseBearerToken returns the token of "Bearer <token>" header. Token charset is b64token from RFC6750
+func ParseBearerToken(authorizationHeader string) ([]byte, error) {
+	if !strings.HasPrefix(authorizationHeader, "Bearer ") {
+		return nil, errors.New("authorization token is not of type bearer")
+	}
+
+	token := strings.TrimPrefix(authorizationHeader, "Bearer ")
+
+	if len(token) < BEARER_TOKEN_MIN_LENGTH || len(token) > BEARER_TOKEN_MAX_LENGTH {
+		return nil, fmt.Errorf("authorization token length must be between %d and %d", BEARER_TOKEN_MIN_LENGTH, BEARER_TOKEN_MAX_LENGTH)
+	}
+
+	for i := 0; i < len(token); i++ {
+		if !isBearerTokenChar(token[i]) {
+			return nil, errors.New("authorization token contains invalid characters")
+		}
+	}
+
+	return []byte(token), nil
+}
+
 func ExtractAuthorizationHeader(w http.ResponseWriter, r *http.Request, currentCmd FdoCmd) (bool, []byte, string) {
 	authorizationHeader := r.Header.Get("Authorization")
 	if authorizationHeader == "" {
@@ -27,18 +174,42 @@ func ExtractAuthorizationHeader(w http.ResponseWriter, r *http.Request, currentC
 		return false, nil, ""
 	}
 
-	authorizationHeaderParts := strings.Split(authorizationHeader, " ")
-	if len(authorizationHeaderParts) != 2 {
-		RespondFDOError(w, r, MESSAGE_BODY_ERROR, currentCmd, "Unauthorized! Invalid authorization header!", http.StatusUnauthorized)
+	sessionId, err := ParseBearerToken(authorizationHeader)
+	if err != nil {
+		RespondFDOError(w, r, INVALID_MESSAGE_ERROR, currentCmd, "Unauthorized! Invalid authorization header! "+err.Error(), http.StatusUnauthorized)
 		return false, nil, ""
 	}
 
-	if authorizationHeaderParts[0] != "Bearer" {
-		RespondFDOError(w, r, MESSAGE_BODY_ERROR, currentCmd, "Unauthorized! Authorization token is not of type bearer!", http.StatusUnauthorized)
-		return false, nil, ""
+	return true, sessionId, authorizationHeader
+}
+
+var ErrMessageBodyTooLarge = errors.New("message body exceeds size limit")
+
+// ReadMessageBody reads request body of the FDO message, and stops once it exceeds the message size limit, so large bodies are never buffered
+func ReadMessageBody(w http.ResponseWriter, r *http.Request, currentCmd FdoCmd) ([]byte, error) {
+	maxSize := Limits.MaxMessageBodySize(currentCmd)
+
+	bodyBytes, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return bodyBytes, fmt.Errorf("%w of %d bytes", ErrMessageBodyTooLarge, maxSize)
+		}
+
+		return bodyBytes, err
+	}
+
+	return bodyBytes, nil
+}
+
+// MessageBodyErrorStatus returns HTTP status for ReadMessageBody error
+func MessageBodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, ErrMessageBodyTooLarge) || errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
 	}
 
-	return true, []byte(authorizationHeaderParts[1]), authorizationHeader
+	return http.StatusBadRequest
 }
 
 func CheckHeaders(w http.ResponseWriter, r *http.Request, currentCmd FdoCmd) bool {
@@ -0,0 +1,238 @@
+package fdoshared
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseBearerToken(t *testing.T) {
+	validToken := "4d9c1d3e-0b7f-4a51-9f4e-3c2a1b0d9e8f"
+
+	token, err := ParseBearerToken("Bearer " + validToken)
+	if err != nil {
+		t.Fatalf("Expected valid token to be accepted. %s", err.Error())
+	}
+
+	if string(token) != validToken {
+		t.Errorf("Expected %s, got %s", validToken, token)
+	}
+
+	badHeaders := map[string]string{
+		"missing prefix":   validToken,
+		"wrong scheme":     "Basic " + validToken,
+		"lowercase scheme": "bearer " + validToken,
+		"empty token":      "Bearer ",
+		"short token":      "Bearer abc",
+		"double space":     "Bearer  " + validToken,
+		"extra part":       "Bearer " + validToken + " extra",
+		"garbage":          "Bearer \x00\xff<script>alert(1)</script>",
+		"too long":         "Bearer " + string(make([]byte, BEARER_TOKEN_MAX_LENGTH+1)),
+	}
+
+	for name, header := range badHeaders {
+		_, err := ParseBearerToken(header)
+		if err == nil {
+			t.Errorf("Expected %s header to be rejected", name)
+		}
+	}
+}
+
+func TestExtractAuthorizationHeader_Malformed(t *testing.T) {
+	r := httptest.NewRequest("POST", FDO_101_URL_BASE+"32", nil)
+	r.Header.Set("Authorization", "Bearer garbage!")
+
+	w := httptest.NewRecorder()
+	ok, sessionId, _ := ExtractAuthorizationHeader(w, r, TO1_32_PROVE_TO_RV)
+	if ok || sessionId != nil {
+		t.Fatalf("Expected malformed header to be rejected")
+	}
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	var fdoError FdoError
+	err := CborCust.Unmarshal(w.Body.Bytes(), &fdoError)
+	if err != nil {
+		t.Fatalf("Failed to decode FDO error. %s", err.Error())
+	}
+
+	if fdoError.EMErrorCode != INVALID_MESSAGE_ERROR {
+		t.Errorf("Expected INVALID_MESSAGE_ERROR, got %d", fdoError.EMErrorCode)
+	}
+}
+
+func TestRespondUnknownMessage(t *testing.T) {
+	r := httptest.NewRequest("POST", FDO_101_URL_BASE+"250", nil)
+	w := httptest.NewRecorder()
+
+	RespondUnknownMessage(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if w.Header().Get("Content-Type") != CONTENT_TYPE_CBOR {
+		t.Errorf("Expected Content-Type %s, got %s", CONTENT_TYPE_CBOR, w.Header().Get("Content-Type"))
+	}
+
+	if w.Header().Get("Message-Type") != TO_ERROR_255.ToString() {
+		t.Errorf("Expected Message-Type %s, got %s", TO_ERROR_255.ToString(), w.Header().Get("Message-Type"))
+	}
+
+	var fdoError FdoError
+	err := CborCust.Unmarshal(w.Body.Bytes(), &fdoError)
+	if err != nil {
+		t.Fatalf("Failed to decode FDO error. %s", err.Error())
+	}
+
+	if fdoError.EMErrorCode != INVALID_MESSAGE_ERROR || fdoError.EMPrevMsgID != 250 {
+		t.Errorf("Expected error %d for message 250, got %d for %d", INVALID_MESSAGE_ERROR, fdoError.EMErrorCode, fdoError.EMPrevMsgID)
+	}
+
+	for _, urlPath := range []string{FDO_101_URL_BASE + "256", FDO_101_URL_BASE + "abc", "/fdo/100/msg/30"} {
+		if _, ok := ParseFdoMessageNumber(urlPath); ok {
+			t.Errorf("Expected %s to have no valid message number", urlPath)
+		}
+	}
+}
+
+func TestCheckTLSDeviceCert(t *testing.T) {
+	deviceChainPem, _ := newTestIdentityPem(t)
+	otherChainPem, _ := newTestIdentityPem(t)
+
+	deviceCertBlock, _ := pem.Decode(deviceChainPem)
+	deviceCert, _ := x509.ParseCertificate(deviceCertBlock.Bytes)
+	otherCertBlock, _ := pem.Decode(otherChainPem)
+	otherCert, _ := x509.ParseCertificate(otherCertBlock.Bytes)
+
+	ovDevCertChain := []X509CertificateBytes{deviceCert.Raw}
+
+	req := httptest.NewRequest("POST", FDO_101_URL_BASE+"60", nil)
+	err := CheckTLSDeviceCert(req, &ovDevCertChain)
+	if err != nil {
+		t.Errorf("Expected plain HTTP request not to be checked. %s", err.Error())
+	}
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{deviceCert}}
+	err = CheckTLSDeviceCert(req, &ovDevCertChain)
+	if err != nil {
+		t.Errorf("Expected voucher device certificate to match. %s", err.Error())
+	}
+
+	err = CheckTLSDeviceCert(req, nil)
+	if err == nil {
+		t.Errorf("Expected voucher without OVDevCertChain to fail")
+	}
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{otherCert}}
+	err = CheckTLSDeviceCert(req, &ovDevCertChain)
+	if err == nil {
+		t.Errorf("Expected other client certificate to fail")
+	}
+
+	req.TLS = &tls.ConnectionState{}
+	err = CheckTLSDeviceCert(req, &ovDevCertChain)
+	if err == nil {
+		t.Errorf("Expected missing client certificate to fail")
+	}
+}
+
+func TestRequireTLSClientCert(t *testing.T) {
+	chainPem, _ := newTestIdentityPem(t)
+	certBlock, _ := pem.Decode(chainPem)
+	clientCert, _ := x509.ParseCertificate(certBlock.Bytes)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(chainPem)
+
+	var receivedCert *x509.Certificate
+	handlerReached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerReached = true
+		receivedCert = GetTLSClientCert(r)
+	})
+
+	testCases := []struct {
+		name       string
+		clientCAs  *x509.CertPool
+		urlPath    string
+		tlsState   *tls.ConnectionState
+		shouldPass bool
+	}{
+		{"mTLS disabled", nil, FDO_101_URL_BASE + "30", nil, true},
+		{"plain HTTP", clientCAs, FDO_101_URL_BASE + "30", nil, false},
+		{"no client certificate", clientCAs, FDO_101_URL_BASE + "30", &tls.ConnectionState{}, false},
+		{"unverified client certificate", clientCAs, FDO_101_URL_BASE + "30", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}}, false},
+		{"verified client certificate", clientCAs, FDO_101_URL_BASE + "30", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}, VerifiedChains: [][]*x509.Certificate{{clientCert}}}, true},
+		{"non FDO path", clientCAs, "/api/user/login", nil, true},
+	}
+
+	for _, testCase := range testCases {
+		handlerReached = false
+		receivedCert = nil
+
+		r := httptest.NewRequest("POST", testCase.urlPath, nil)
+		r.TLS = testCase.tlsState
+		w := httptest.NewRecorder()
+
+		RequireTLSClientCert(testCase.clientCAs, next).ServeHTTP(w, r)
+
+		if handlerReached != testCase.shouldPass {
+			t.Errorf("%s: Expected handler reached to be %v", testCase.name, testCase.shouldPass)
+			continue
+		}
+
+		if !testCase.shouldPass {
+			var fdoError FdoError
+			err := CborCust.Unmarshal(w.Body.Bytes(), &fdoError)
+			if err != nil {
+				t.Fatalf("%s: Failed to decode FDO error. %s", testCase.name, err.Error())
+			}
+
+			if w.Code != http.StatusUnauthorized || fdoError.EMPrevMsgID != TO1_30_HELLO_RV {
+				t.Errorf("%s: Expected 401 FDO error for message 30. Got %d for %d", testCase.name, w.Code, fdoError.EMPrevMsgID)
+			}
+		}
+
+		if testCase.tlsState != nil && testCase.shouldPass && receivedCert != clientCert {
+			t.Errorf("%s: Expected client certificate to be available to handler", testCase.name)
+		}
+	}
+}
+
+func TestReadMessageBody_Limit(t *testing.T) {
+	defer func(limits ResourceLimits) { Limits = limits }(Limits)
+	Limits.MaxDeviceMessageSize = 16
+
+	r := httptest.NewRequest(http.MethodPost, "/fdo/101/msg/60", strings.NewReader(strings.Repeat("a", 17)))
+	_, err := ReadMessageBody(httptest.NewRecorder(), r, TO2_60_HELLO_DEVICE)
+	if !errors.Is(err, ErrMessageBodyTooLarge) {
+		t.Fatalf("Expected body over limit to fail with ErrMessageBodyTooLarge. Got %v", err)
+	}
+
+	if MessageBodyErrorStatus(err) != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d. Got %d", http.StatusRequestEntityTooLarge, MessageBodyErrorStatus(err))
+	}
+
+	// Body of exactly the limit is accepted
+	r = httptest.NewRequest(http.MethodPost, "/fdo/101/msg/60", strings.NewReader(strings.Repeat("a", 16)))
+	bodyBytes, err := ReadMessageBody(httptest.NewRecorder(), r, TO2_60_HELLO_DEVICE)
+	if err != nil || len(bodyBytes) != 16 {
+		t.Errorf("Expected body of limit size to be read. Got %d bytes, %v", len(bodyBytes), err)
+	}
+
+	if MessageBodyErrorStatus(&http.MaxBytesError{Limit: 16}) != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected http.MaxBytesError to map to status %d", http.StatusRequestEntityTooLarge)
+	}
+
+	if MessageBodyErrorStatus(errors.New("connection reset")) != http.StatusBadRequest {
+		t.Errorf("Expected read error to map to status %d", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,127 @@
+package fdoshared
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Guardrails of voucher uploads. Storage is the CBOR encoded size of stored vouchers and keys
+type VoucherUploadLimits struct {
+	MaxFileSize    int
+	MaxFiles       int
+	MaxUserStorage int
+}
+
+const (
+	DEFAULT_MAX_VOUCHER_FILE_SIZE    int = 65536
+	DEFAULT_MAX_VOUCHER_FILES        int = 100
+	DEFAULT_MAX_USER_VOUCHER_STORAGE int = 16777216
+)
+
+var DefaultVoucherUploadLimits VoucherUploadLimits = VoucherUploadLimits{
+	MaxFileSize:    DEFAULT_MAX_VOUCHER_FILE_SIZE,
+	MaxFiles:       DEFAULT_MAX_VOUCHER_FILES,
+	MaxUserStorage: DEFAULT_MAX_USER_VOUCHER_STORAGE,
+}
+
+// UploadLimits are set once on startup from config
+var UploadLimits VoucherUploadLimits = DefaultVoucherUploadLimits
+
+func NewVoucherUploadLimits(maxFileSizeStr string, maxFilesStr string, maxUserStorageStr string) (*VoucherUploadLimits, error) {
+	limits := DefaultVoucherUploadLimits
+
+	if maxFileSizeStr != "" {
+		maxFileSize, err := strconv.Atoi(maxFileSizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max voucher file size limit. %s", err.Error())
+		}
+
+		if maxFileSize < 1 {
+			return nil, fmt.Errorf("max voucher file size limit must be positive. Got %d", maxFileSize)
+		}
+
+		limits.MaxFileSize = maxFileSize
+	}
+
+	if maxFilesStr != "" {
+		maxFiles, err := strconv.Atoi(maxFilesStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max voucher files limit. %s", err.Error())
+		}
+
+		if maxFiles < 1 {
+			return nil, fmt.Errorf("max voucher files limit must be positive. Got %d", maxFiles)
+		}
+
+		limits.MaxFiles = maxFiles
+	}
+
+	if maxUserStorageStr != "" {
+		maxUserStorage, err := strconv.Atoi(maxUserStorageStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max user voucher storage limit. %s", err.Error())
+		}
+
+		if maxUserStorage < 1 {
+			return nil, fmt.Errorf("max user voucher storage limit must be positive. Got %d", maxUserStorage)
+		}
+
+		limits.MaxUserStorage = maxUserStorage
+	}
+
+	if limits.MaxUserStorage < limits.MaxFileSize {
+		return nil, fmt.Errorf("max voucher storage per user %d must fit at least one voucher file of %d bytes", limits.MaxUserStorage, limits.MaxFileSize)
+	}
+
+	return &limits, nil
+}
+
+// MaxRequestSize is the upper bound of a request body carrying MaxFiles files. JSON escaping can double PEM size
+func (h VoucherUploadLimits) MaxRequestSize() int64 {
+	return int64(h.MaxFiles)*int64(2*h.MaxFileSize+1024) + 4096
+}
+
+func (h VoucherUploadLimits) CheckFilesCount(numFiles int) error {
+	if numFiles == 0 {
+		return errors.New("no voucher files")
+	}
+
+	if numFiles > h.MaxFiles {
+		return fmt.Errorf("number of voucher files %d exceeds limit of %d per request", numFiles, h.MaxFiles)
+	}
+
+	return nil
+}
+
+// ErrUserStorageQuota is returned when uploaded vouchers do not fit the user voucher storage quota
+var ErrUserStorageQuota = errors.New("voucher storage quota exceeded")
+
+// CheckFile checks file size, and that the file is PEM, before any voucher decoding. Vouchers are only uploaded as PEM voucher and private key
+func (h VoucherUploadLimits) CheckFile(fileBytes []byte) error {
+	if len(fileBytes) > h.MaxFileSize {
+		return fmt.Errorf("voucher file size %d exceeds limit of %d bytes", len(fileBytes), h.MaxFileSize)
+	}
+
+	if !isPemContent(fileBytes) {
+		return errors.New("voucher file is not PEM encoded")
+	}
+
+	return nil
+}
+
+// CheckUserStorage checks that addedSize bytes fit the user quota next to usedSize bytes already stored
+func (h VoucherUploadLimits) CheckUserStorage(usedSize int, addedSize int) error {
+	if usedSize+addedSize > h.MaxUserStorage {
+		return fmt.Errorf("%w. %d bytes stored, %d bytes uploaded, quota is %d bytes", ErrUserStorageQuota, usedSize, addedSize, h.MaxUserStorage)
+	}
+
+	return nil
+}
+
+func isPemContent(fileBytes []byte) bool {
+	block, _ := pem.Decode(bytes.TrimSpace(fileBytes))
+	return block != nil
+}
@@ -0,0 +1,90 @@
+package fdoshared
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestVoucherUploadLimits_CheckFile(t *testing.T) {
+	limits := VoucherUploadLimits{MaxFileSize: 256, MaxFiles: 2, MaxUserStorage: 1024}
+
+	// Largest PEM file within the limit
+	var pemBytes []byte
+	for payloadSize := 1; ; payloadSize++ {
+		candidate := pem.EncodeToMemory(&pem.Block{Type: OWNERSHIP_VOUCHER_PEM_TYPE, Bytes: bytes.Repeat([]byte{0x01}, payloadSize)})
+		if len(candidate) > limits.MaxFileSize {
+			break
+		}
+		pemBytes = candidate
+	}
+
+	if err := limits.CheckFile(pemBytes); err != nil {
+		t.Errorf("Expected PEM file within limit to pass. %s", err.Error())
+	}
+
+	pemBytes = pem.EncodeToMemory(&pem.Block{Type: OWNERSHIP_VOUCHER_PEM_TYPE, Bytes: bytes.Repeat([]byte{0x01}, limits.MaxFileSize)})
+	if err := limits.CheckFile(pemBytes); err == nil {
+		t.Errorf("Expected file above limit to fail")
+	}
+
+	// Vouchers are decoded from PEM only
+	cborBytes, _ := CborCust.Marshal(bytes.Repeat([]byte{0x01}, 16))
+	if err := limits.CheckFile(cborBytes); err == nil {
+		t.Errorf("Expected CBOR file to fail")
+	}
+
+	for _, content := range [][]byte{[]byte("not a voucher"), {}, {0xff, 0x00}, []byte("{\"voucher\": 1}")} {
+		if err := limits.CheckFile(content); err == nil {
+			t.Errorf("Expected non PEM content %q to fail", content)
+		}
+	}
+}
+
+func TestVoucherUploadLimits_Counts(t *testing.T) {
+	limits := VoucherUploadLimits{MaxFileSize: 256, MaxFiles: 2, MaxUserStorage: 1024}
+
+	if err := limits.CheckFilesCount(2); err != nil {
+		t.Errorf("Expected files count at limit to pass. %s", err.Error())
+	}
+
+	if err := limits.CheckFilesCount(3); err == nil {
+		t.Errorf("Expected files count above limit to fail")
+	}
+
+	if err := limits.CheckFilesCount(0); err == nil {
+		t.Errorf("Expected request without files to fail")
+	}
+
+	if err := limits.CheckUserStorage(1000, 24); err != nil {
+		t.Errorf("Expected storage at quota to pass. %s", err.Error())
+	}
+
+	if err := limits.CheckUserStorage(1000, 25); !errors.Is(err, ErrUserStorageQuota) {
+		t.Errorf("Expected storage above quota to fail with quota error. Got %v", err)
+	}
+
+	if limits.MaxRequestSize() < int64(limits.MaxFiles*limits.MaxFileSize*2) {
+		t.Errorf("Expected max request size to fit max number of escaped max size files. Got %d", limits.MaxRequestSize())
+	}
+}
+
+func TestNewVoucherUploadLimits(t *testing.T) {
+	limits, err := NewVoucherUploadLimits("", "", "")
+	if err != nil || *limits != DefaultVoucherUploadLimits {
+		t.Errorf("Expected defaults when not configured. Got %v %v", limits, err)
+	}
+
+	limits, err = NewVoucherUploadLimits("1024", "10", "4096")
+	if err != nil || limits.MaxFileSize != 1024 || limits.MaxFiles != 10 || limits.MaxUserStorage != 4096 {
+		t.Errorf("Expected configured limits. Got %v %v", limits, err)
+	}
+
+	for _, invalid := range [][3]string{{"abc", "", ""}, {"0", "", ""}, {"", "-1", ""}, {"", "", "0"}, {"2048", "", "1024"}} {
+		_, err = NewVoucherUploadLimits(invalid[0], invalid[1], invalid[2])
+		if err == nil {
+			t.Errorf("Expected %v to fail", invalid)
+		}
+	}
+}
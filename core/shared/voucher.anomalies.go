@@ -0,0 +1,201 @@
+package fdoshared
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type OVEntryAnomalyType string
+
+const (
+	OVENTRY_ANOMALY_KEY_REUSE  OVEntryAnomalyType = "key_reuse"
+	OVENTRY_ANOMALY_CERT_CHAIN OVEntryAnomalyType = "cert_chain"
+)
+
+// OVEntryAnomaly is owner key use that VerifyEntries accepts, but that some implementations mishandle.
+// Entries are OVEntry indexes, -1 is the manufacturer key of OVHeader
+type OVEntryAnomaly struct {
+	Type    OVEntryAnomalyType `json:"type"`
+	Entries []int              `json:"entries"`
+	Message string             `json:"message"`
+}
+
+func ovEntryPositionName(position int) string {
+	if position == -1 {
+		return "OVHeader"
+	}
+
+	return fmt.Sprintf("OVEntry %d", position)
+}
+
+// ovEntryKeyId returns SubjectPublicKeyInfo of the key, so the same key is found in any encoding
+func ovEntryKeyId(publicKey FdoPublicKey) ([]byte, error) {
+	switch publicKey.PkEnc {
+	case X509:
+		publicKeyBytes, ok := publicKey.PkBody.([]byte)
+		if !ok {
+			return nil, errors.New("failed to cast pubkey PkBody to []byte")
+		}
+
+		return publicKeyBytes, nil
+	case X5CHAIN:
+		certs, err := x5ChainFromPkBody(publicKey.PkBody)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(certs) == 0 {
+			return nil, errors.New("X5CHAIN is empty")
+		}
+
+		leafCert, err := x509.ParseCertificate(certs[0])
+		if err != nil {
+			return nil, errors.New("error parsing X5CHAIN leaf certificate. " + err.Error())
+		}
+
+		return leafCert.RawSubjectPublicKeyInfo, nil
+	case COSEKEY:
+		return CoseKeyToX509(publicKey)
+	default:
+		return nil, fmt.Errorf("PublicKey encoding %d is not supported", publicKey.PkEnc)
+	}
+}
+
+// checkOVEntryCertChain returns problems of X5CHAIN certificates, leaf first. Signatures are checked by VerifyEntries
+func checkOVEntryCertChain(publicKey FdoPublicKey) []string {
+	if publicKey.PkEnc != X5CHAIN {
+		return nil
+	}
+
+	certBytes, err := x5ChainFromPkBody(publicKey.PkBody)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	var certs []*x509.Certificate
+	for i, cert := range certBytes {
+		for j := 0; j < i; j++ {
+			if bytes.Equal(cert, certBytes[j]) {
+				problems = append(problems, fmt.Sprintf("certificate %d duplicates certificate %d", i, j))
+			}
+		}
+
+		parsedCert, err := x509.ParseCertificate(cert)
+		if err != nil {
+			return append(problems, fmt.Sprintf("certificate %d can not be parsed. %s", i, err.Error()))
+		}
+
+		certs = append(certs, parsedCert)
+	}
+
+	for i := 0; i < len(certs)-1; i++ {
+		if !bytes.Equal(certs[i].RawIssuer, certs[i+1].RawSubject) {
+			problems = append(problems, fmt.Sprintf("certificate %d is not issued by certificate %d", i, i+1))
+		}
+	}
+
+	return problems
+}
+
+// CheckKeyAnomalies reports owner keys used again in non-adjacent positions of the chain, OVHeader key included, and X5CHAIN keys
+// with duplicated certificates or certificates out of issuing order. Same key in adjacent positions, owner extending voucher to itself, is not reported
+func (h OVEntryArray) CheckKeyAnomalies(ovHeaderTag []byte) ([]OVEntryAnomaly, error) {
+	var voucherHeader OwnershipVoucherHeader
+	err := CborCust.Unmarshal(ovHeaderTag, &voucherHeader)
+	if err != nil {
+		return nil, errors.New("error decoding VoucherHeader: " + err.Error())
+	}
+
+	publicKeys := []FdoPublicKey{voucherHeader.OVPublicKey}
+	for i, ovEntry := range h {
+		publicKey, err := ovEntry.GetOVEntryPubKey()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding OVEntry %d public key: %s", i, err.Error())
+		}
+
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	anomalies := []OVEntryAnomaly{}
+
+	var keyIds []string
+	keyPositions := map[string][]int{}
+	for i, publicKey := range publicKeys {
+		position := i - 1
+
+		keyIdBytes, err := ovEntryKeyId(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding %s public key: %s", ovEntryPositionName(position), err.Error())
+		}
+
+		keyId := string(keyIdBytes)
+		if _, ok := keyPositions[keyId]; !ok {
+			keyIds = append(keyIds, keyId)
+		}
+		keyPositions[keyId] = append(keyPositions[keyId], position)
+
+		if problems := checkOVEntryCertChain(publicKey); len(problems) != 0 {
+			anomalies = append(anomalies, OVEntryAnomaly{
+				Type:    OVENTRY_ANOMALY_CERT_CHAIN,
+				Entries: []int{position},
+				Message: fmt.Sprintf("%s X5CHAIN: %s", ovEntryPositionName(position), strings.Join(problems, ", ")),
+			})
+		}
+	}
+
+	for _, keyId := range keyIds {
+		positions := keyPositions[keyId]
+
+		adjacent := true
+		for i := 1; i < len(positions); i++ {
+			if positions[i] != positions[i-1]+1 {
+				adjacent = false
+			}
+		}
+
+		if adjacent {
+			continue
+		}
+
+		positionNames := []string{}
+		for _, position := range positions {
+			positionNames = append(positionNames, ovEntryPositionName(position))
+		}
+
+		anomalies = append(anomalies, OVEntryAnomaly{
+			Type:    OVENTRY_ANOMALY_KEY_REUSE,
+			Entries: positions,
+			Message: "Same owner key is used in non-adjacent " + strings.Join(positionNames, ", "),
+		})
+	}
+
+	return anomalies, nil
+}
+
+// VerifyNoKeyAnomalies returns error listing anomalies found by CheckKeyAnomalies
+func (h OVEntryArray) VerifyNoKeyAnomalies(ovHeaderTag []byte) error {
+	anomalies, err := h.CheckKeyAnomalies(ovHeaderTag)
+	if err != nil {
+		return err
+	}
+
+	return KeyAnomaliesError(anomalies)
+}
+
+// KeyAnomaliesError returns anomaly messages as error, nil when there are none
+func KeyAnomaliesError(anomalies []OVEntryAnomaly) error {
+	if len(anomalies) == 0 {
+		return nil
+	}
+
+	messages := []string{}
+	for _, anomaly := range anomalies {
+		messages = append(messages, anomaly.Message)
+	}
+
+	return errors.New("suspicious OVEntry keys. " + strings.Join(messages, ". "))
+}
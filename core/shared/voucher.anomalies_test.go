@@ -0,0 +1,98 @@
+package fdoshared
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Entries carry only public keys. Anomaly check does not verify signatures
+func newTestAnomalyChain(t *testing.T, keys []FdoPublicKey, entryKeys []int) (OVEntryArray, []byte) {
+	ovHeaderTag, _ := CborCust.Marshal(OwnershipVoucherHeader{
+		OVHProtVer:  ProtVer101,
+		OVGuid:      NewFdoGuid(),
+		OVRvInfo:    RendezvousInfo{},
+		OVPublicKey: keys[0],
+	})
+
+	var ovEntries OVEntryArray
+	for _, keyIndex := range entryKeys {
+		payloadBytes, err := CborCust.Marshal(OVEntryPayload{OVEPubKey: keys[keyIndex]})
+		if err != nil {
+			t.Fatalf("Failed to marshal OVEntry payload. %s", err.Error())
+		}
+
+		ovEntries = append(ovEntries, CoseSignature{Payload: payloadBytes})
+	}
+
+	return ovEntries, ovHeaderTag
+}
+
+func TestOVEntryArray_CheckKeyAnomalies(t *testing.T) {
+	var keys []FdoPublicKey
+	for i := 0; i < 3; i++ {
+		_, publicKey, err := GenerateVoucherKeypair(StSECP256R1)
+		if err != nil {
+			t.Fatalf("Failed to generate key. %s", err.Error())
+		}
+		keys = append(keys, *publicKey)
+	}
+
+	identity, err := NewServerIdentity(newTestIdentityPem(t))
+	if err != nil {
+		t.Fatalf("Failed to load identity. %s", err.Error())
+	}
+	keys = append(keys, identity.GetPublicKey())
+
+	// Same key as X5CHAIN leaf, and as X509
+	leafKeyId, _ := ovEntryKeyId(identity.GetPublicKey())
+	keys = append(keys, FdoPublicKey{PkType: SECP256R1, PkEnc: X509, PkBody: leafKeyId})
+
+	badChainKey := identity.GetPublicKey()
+	badChainKey.PkBody = []X509CertificateBytes{identity.CertificateChain[0], identity.CertificateChain[0]}
+	keys = append(keys, badChainKey)
+
+	testCases := []struct {
+		name      string
+		entryKeys []int
+		expected  []OVEntryAnomaly
+	}{
+		{"distinct keys", []int{1, 2, 3}, []OVEntryAnomaly{}},
+		{"owner extends to itself", []int{0, 1, 1, 2}, []OVEntryAnomaly{}},
+		{"reused entry key", []int{1, 2, 1}, []OVEntryAnomaly{{Type: OVENTRY_ANOMALY_KEY_REUSE, Entries: []int{0, 2}}}},
+		{"reused manufacturer key", []int{1, 0}, []OVEntryAnomaly{{Type: OVENTRY_ANOMALY_KEY_REUSE, Entries: []int{-1, 1}}}},
+		{"reused key in other encoding", []int{3, 1, 4}, []OVEntryAnomaly{{Type: OVENTRY_ANOMALY_KEY_REUSE, Entries: []int{0, 2}}}},
+		{"duplicated certificate", []int{1, 5}, []OVEntryAnomaly{{Type: OVENTRY_ANOMALY_CERT_CHAIN, Entries: []int{1}}}},
+	}
+
+	for _, testCase := range testCases {
+		ovEntries, ovHeaderTag := newTestAnomalyChain(t, keys, testCase.entryKeys)
+
+		anomalies, err := ovEntries.CheckKeyAnomalies(ovHeaderTag)
+		if err != nil {
+			t.Fatalf("%s: Failed to check key anomalies. %s", testCase.name, err.Error())
+		}
+
+		for i := range anomalies {
+			if anomalies[i].Message == "" {
+				t.Errorf("%s: Expected anomaly message", testCase.name)
+			}
+			anomalies[i].Message = ""
+		}
+
+		if !reflect.DeepEqual(anomalies, testCase.expected) {
+			t.Errorf("%s: Expected anomalies %+v. Got %+v", testCase.name, testCase.expected, anomalies)
+		}
+
+		err = ovEntries.VerifyNoKeyAnomalies(ovHeaderTag)
+		if (err != nil) != (len(testCase.expected) != 0) {
+			t.Errorf("%s: Unexpected VerifyNoKeyAnomalies result %v", testCase.name, err)
+		}
+	}
+
+	ovEntries, ovHeaderTag := newTestAnomalyChain(t, keys, []int{1, 2, 1})
+	err = ovEntries.VerifyNoKeyAnomalies(ovHeaderTag)
+	if err == nil || !strings.Contains(err.Error(), "OVEntry 0, OVEntry 2") {
+		t.Errorf("Expected error to name reusing entries. Got %v", err)
+	}
+}
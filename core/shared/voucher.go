@@ -3,6 +3,7 @@ package fdoshared
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -241,6 +242,24 @@ func GeneratePKIXECKeypair(sgType DeviceSgType) (interface{}, *FdoPublicKey, err
 	}, nil
 }
 
+func GeneratePKIXEd25519Keypair() (interface{}, *FdoPublicKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, errors.New("error generating new Ed25519 private key. " + err.Error())
+	}
+
+	publicKeyPkix, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, nil, errors.New("error marshaling Ed25519 public key. " + err.Error())
+	}
+
+	return privateKey, &FdoPublicKey{
+		PkType: ED25519,
+		PkEnc:  X509,
+		PkBody: publicKeyPkix,
+	}, nil
+}
+
 func GeneratePKIXRSAKeypair(sgType DeviceSgType) (interface{}, *FdoPublicKey, error) {
 	var pkType FdoPkType
 	var rsaKeySize int
@@ -277,6 +296,11 @@ func GenerateVoucherKeypair(sgType DeviceSgType) (interface{}, *FdoPublicKey, er
 	switch sgType {
 	case StSECP256R1, StSECP384R1:
 		return GeneratePKIXECKeypair(sgType)
+	case StED25519:
+		if !ExperimentalEd25519 {
+			return nil, nil, ErrEd25519Disabled
+		}
+		return GeneratePKIXEd25519Keypair()
 	case StRSA2048, StRSA3072:
 		return GeneratePKIXRSAKeypair(sgType)
 	default:
@@ -289,6 +313,9 @@ func MarshalPrivateKey(privKey interface{}, sgType DeviceSgType) ([]byte, error)
 	case StSECP256R1, StSECP384R1:
 		return x509.MarshalPKCS8PrivateKey(privKey.(*ecdsa.PrivateKey))
 
+	case StED25519:
+		return x509.MarshalPKCS8PrivateKey(privKey.(ed25519.PrivateKey))
+
 	case StRSA2048, StRSA3072:
 		return x509.MarshalPKCS8PrivateKey(privKey.(*rsa.PrivateKey))
 
@@ -296,3 +323,74 @@ func MarshalPrivateKey(privKey interface{}, sgType DeviceSgType) ([]byte, error)
 		return []byte{}, fmt.Errorf("%d is an unsupported SgType", sgType)
 	}
 }
+
+// NewReplacementVoucher builds voucher for the credential installed by TO2.SetupDevice.
+// Header uses replacement GUID, RVInfo and Owner2Key, and is extended once by Owner2Key to itself.
+func NewReplacementVoucher(prevVoucher OwnershipVoucher, setupDevice TO2SetupDevicePayload, replacementHMac HashOrHmac, owner2PrivateKey interface{}, owner2SgType DeviceSgType) (*OwnershipVoucher, error) {
+	prevHeader, err := prevVoucher.GetOVHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	if prevHeader.OVDevCertChainHash == nil {
+		return nil, errors.New("EPID not supported")
+	}
+
+	hashType := prevHeader.OVDevCertChainHash.Type
+
+	newHeader := OwnershipVoucherHeader{
+		OVHProtVer:         ProtVer101,
+		OVGuid:             setupDevice.ReplacementGuid,
+		OVRvInfo:           setupDevice.RendezvousInfo,
+		OVDeviceInfo:       prevHeader.OVDeviceInfo,
+		OVPublicKey:        setupDevice.ReplacementOwner2Key,
+		OVDevCertChainHash: prevHeader.OVDevCertChainHash,
+	}
+
+	newHeaderBytes, err := CborCust.Marshal(newHeader)
+	if err != nil {
+		return nil, errors.New("error marshaling OVHeader. " + err.Error())
+	}
+
+	headerHmacBytes, err := CborCust.Marshal(replacementHMac)
+	if err != nil {
+		return nil, errors.New("error marshaling ReplacementHMac. " + err.Error())
+	}
+
+	prevEntryHash, err := GenerateFdoHash(append(newHeaderBytes, headerHmacBytes...), hashType)
+	if err != nil {
+		return nil, errors.New("error generating OVEntry prev hash. " + err.Error())
+	}
+
+	hdrInfoHash, err := GenerateFdoHash(append(setupDevice.ReplacementGuid[:], []byte(prevHeader.OVDeviceInfo)...), hashType)
+	if err != nil {
+		return nil, errors.New("error generating OVEntry hdrinfo hash. " + err.Error())
+	}
+
+	ovEntryPayloadBytes, err := CborCust.Marshal(OVEntryPayload{
+		OVEHashPrevEntry: prevEntryHash,
+		OVEHashHdrInfo:   hdrInfoHash,
+		OVEExtra:         nil,
+		OVEPubKey:        setupDevice.ReplacementOwner2Key,
+	})
+	if err != nil {
+		return nil, errors.New("error marshaling OVEntry. " + err.Error())
+	}
+
+	protectedHeader := ProtectedHeader{
+		Alg: GetIntRef(int(owner2SgType)),
+	}
+
+	ovEntry, err := GenerateCoseSignature(ovEntryPayloadBytes, protectedHeader, UnprotectedHeader{}, owner2PrivateKey, owner2SgType)
+	if err != nil {
+		return nil, errors.New("error generating OVEntry. " + err.Error())
+	}
+
+	return &OwnershipVoucher{
+		OVProtVer:      ProtVer101,
+		OVHeaderTag:    newHeaderBytes,
+		OVHeaderHMac:   replacementHMac,
+		OVDevCertChain: prevVoucher.OVDevCertChain,
+		OVEntryArray:   OVEntryArray{*ovEntry},
+	}, nil
+}
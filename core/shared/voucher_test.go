@@ -0,0 +1,82 @@
+package fdoshared
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewReplacementVoucher(t *testing.T) {
+	credential, err := NewWawDeviceCredential(StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	_, mfgPublicKey, err := GenerateVoucherKeypair(StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate manufacturer key. %s", err.Error())
+	}
+
+	prevHeaderBytes, _ := CborCust.Marshal(OwnershipVoucherHeader{
+		OVHProtVer:         ProtVer101,
+		OVGuid:             credential.DCGuid,
+		OVRvInfo:           RendezvousInfo{},
+		OVDeviceInfo:       credential.DCDeviceInfo,
+		OVPublicKey:        *mfgPublicKey,
+		OVDevCertChainHash: &credential.DCCertificateChainHash,
+	})
+
+	prevVoucher := OwnershipVoucher{
+		OVProtVer:      ProtVer101,
+		OVHeaderTag:    prevHeaderBytes,
+		OVDevCertChain: &credential.DCCertificateChain,
+	}
+
+	owner2PrivateKey, owner2PublicKey, err := GenerateVoucherKeypair(StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate Owner2Key. %s", err.Error())
+	}
+
+	setupDevice := TO2SetupDevicePayload{
+		ReplacementGuid:      NewFdoGuid(),
+		NonceTO2SetupDv:      NewFdoNonce(),
+		ReplacementOwner2Key: *owner2PublicKey,
+	}
+
+	replacementHMac, _ := GenerateFdoHmac([]byte("replacement header"), credential.DCHmacAlg, credential.DCHmacSecret)
+
+	newVoucher, err := NewReplacementVoucher(prevVoucher, setupDevice, replacementHMac, owner2PrivateKey, StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate replacement voucher. %s", err.Error())
+	}
+
+	newHeader, err := newVoucher.GetOVHeader()
+	if err != nil {
+		t.Fatalf("Failed to decode replacement header. %s", err.Error())
+	}
+
+	if newHeader.OVGuid != setupDevice.ReplacementGuid {
+		t.Errorf("Expected header GUID to be replacement GUID")
+	}
+
+	owner2PublicKeyBytes, _ := CborCust.Marshal(owner2PublicKey)
+
+	headerPublicKeyBytes, _ := CborCust.Marshal(newHeader.OVPublicKey)
+	if !bytes.Equal(headerPublicKeyBytes, owner2PublicKeyBytes) {
+		t.Errorf("Expected header public key to be Owner2Key")
+	}
+
+	finalOwnerKey, err := newVoucher.GetFinalOwnerPublicKey()
+	if err != nil {
+		t.Fatalf("Failed to get final owner key. %s", err.Error())
+	}
+
+	finalOwnerKeyBytes, _ := CborCust.Marshal(finalOwnerKey)
+	if !bytes.Equal(finalOwnerKeyBytes, owner2PublicKeyBytes) {
+		t.Errorf("Expected final owner key to be Owner2Key")
+	}
+
+	err = newVoucher.Validate()
+	if err != nil {
+		t.Errorf("Replacement voucher failed validation. %s", err.Error())
+	}
+}
@@ -50,6 +50,23 @@ func (h *ConfigDB) Save(mainCfg MainConfig) error {
 	return nil
 }
 
+// Exists checks that MainConfig is saved, i.e. the DB is seeded, without reading the entry value
+func (h *ConfigDB) Exists() (bool, error) {
+	storageId := append(h.prefix, []byte("main")...)
+
+	dbtxn := h.db.NewTransaction(false)
+	defer dbtxn.Discard()
+
+	_, err := dbtxn.Get(storageId)
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New("Failed locating MainConfig entry. The error is: " + err.Error())
+	}
+
+	return true, nil
+}
+
 func (h *ConfigDB) Get() (*MainConfig, error) {
 	storageId := append(h.prefix, []byte("main")...)
 
@@ -1,9 +1,12 @@
 package dbs
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -23,7 +26,10 @@ func NewSessionDB(db *badger.DB) *SessionDB {
 	}
 }
 
-const MAX_SESSION_TIME time.Duration = 7 * 24 * time.Hour
+const DEFAULT_LOGIN_SESSION_TTL time.Duration = 7 * 24 * time.Hour
+
+// Set once on startup from LOGIN_SESSION_TTL. Web login sessions, independent from TO2 session TTL
+var LoginSessionTTL time.Duration = DEFAULT_LOGIN_SESSION_TTL
 
 type SessionEntry struct {
 	_        struct{} `cbor:",toarray"`
@@ -38,9 +44,31 @@ type SessionEntry struct {
 
 	PasswordResetEmail     string
 	PasswordResetTimestamp time.Time
+
+	// Set by NewSessionEntry
+	CreatedAt time.Time
+}
+
+// SessionInfo is session metadata for admins. Ref identifies the session without revealing the cookie value
+type SessionInfo struct {
+	Ref       string
+	Email     string
+	LoggedIn  bool
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// getSessionRef returns hash of session id, so sessions can be listed and revoked without exposing ids usable as cookies
+func getSessionRef(entryId []byte) string {
+	refHash := sha256.Sum256(entryId)
+	return hex.EncodeToString(refHash[:16])
 }
 
 func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
+	if sessionInst.CreatedAt.IsZero() {
+		sessionInst.CreatedAt = time.Now()
+	}
+
 	sessionBytes, err := fdoshared.CborCust.Marshal(sessionInst)
 	if err != nil {
 		return []byte{}, errors.New("Failed to marshal session. The error is: " + err.Error())
@@ -53,7 +81,7 @@ func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(sessionEntryId, sessionBytes).WithTTL(MAX_SESSION_TIME)
+	entry := badger.NewEntry(sessionEntryId, sessionBytes).WithTTL(LoginSessionTTL)
 	err = dbtxn.SetEntry(entry)
 	if err != nil {
 		return []byte{}, errors.New("Failed creating session db entry instance. The error is: " + err.Error())
@@ -136,3 +164,116 @@ func (h *SessionDB) DeleteSessionEntry(entryId []byte) error {
 
 	return nil
 }
+
+// List returns all stored sessions, including not logged in OAuth2 and password reset ones. Entries that fail to decode are skipped
+func (h *SessionDB) List() ([]SessionInfo, error) {
+	sessions := []SessionInfo{}
+
+	err := h.db.View(func(dbtxn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = h.prefix
+
+		it := dbtxn.NewIterator(iteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.ValidForPrefix(h.prefix); it.Next() {
+			item := it.Item()
+			entryId := item.KeyCopy(nil)[len(h.prefix):]
+
+			itemBytes, err := item.ValueCopy(nil)
+			if err != nil {
+				return errors.New("Failed reading entry value. The error is: " + err.Error())
+			}
+
+			var sessionEntryInst SessionEntry
+			err = fdoshared.CborCust.Unmarshal(itemBytes, &sessionEntryInst)
+			if err != nil {
+				log.Printf("Skipping session %s. %s", getSessionRef(entryId), err.Error())
+				continue
+			}
+
+			sessionInfo := SessionInfo{
+				Ref:       getSessionRef(entryId),
+				Email:     sessionEntryInst.Email,
+				LoggedIn:  sessionEntryInst.LoggedIn,
+				CreatedAt: sessionEntryInst.CreatedAt,
+			}
+
+			if item.ExpiresAt() != 0 {
+				sessionInfo.ExpiresAt = time.Unix(int64(item.ExpiresAt()), 0)
+			}
+
+			sessions = append(sessions, sessionInfo)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("Failed listing sessions. The error is: " + err.Error())
+	}
+
+	return sessions, nil
+}
+
+// deleteMatching deletes sessions for which match returns true, and returns how many were deleted
+func (h *SessionDB) deleteMatching(match func(entryId []byte, sessionInst SessionEntry) bool) (int, error) {
+	deleted := 0
+
+	err := fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		deleted = 0
+
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = h.prefix
+
+		it := dbtxn.NewIterator(iteratorOptions)
+		defer it.Close()
+
+		var matchingKeys [][]byte
+		for it.Rewind(); it.ValidForPrefix(h.prefix); it.Next() {
+			item := it.Item()
+			key := item.KeyCopy(nil)
+
+			itemBytes, err := item.ValueCopy(nil)
+			if err != nil {
+				return errors.New("Failed reading entry value. The error is: " + err.Error())
+			}
+
+			var sessionEntryInst SessionEntry
+			if fdoshared.CborCust.Unmarshal(itemBytes, &sessionEntryInst) != nil {
+				continue
+			}
+
+			if match(key[len(h.prefix):], sessionEntryInst) {
+				matchingKeys = append(matchingKeys, key)
+			}
+		}
+
+		for _, key := range matchingKeys {
+			err := dbtxn.Delete(key)
+			if err != nil {
+				return errors.New("Failed to delete session. The error is: " + err.Error())
+			}
+			deleted++
+		}
+
+		return nil
+	})
+
+	return deleted, err
+}
+
+// RevokeSession deletes the session with SessionInfo Ref. Returns false when there is no such session
+func (h *SessionDB) RevokeSession(ref string) (bool, error) {
+	deleted, err := h.deleteMatching(func(entryId []byte, sessionInst SessionEntry) bool {
+		return getSessionRef(entryId) == ref
+	})
+
+	return deleted != 0, err
+}
+
+// DeleteUserSessions logs the user out of all sessions. Returns number of deleted sessions
+func (h *SessionDB) DeleteUserSessions(email string) (int, error) {
+	return h.deleteMatching(func(entryId []byte, sessionInst SessionEntry) bool {
+		return strings.EqualFold(sessionInst.Email, email)
+	})
+}
@@ -1,6 +1,7 @@
 package dbs
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -46,6 +47,12 @@ func (h *UserTestDB) Save(usere UserTestDBEntry) error {
 	return nil
 }
 
+// getUserRef returns hash of user email, so errors reaching logs carry no PII. Same hash as redacted emails in logs
+func getUserRef(email string) string {
+	emailHash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(emailHash[:6])
+}
+
 func (h *UserTestDB) Get(email string) (*UserTestDBEntry, error) {
 	email = strings.ToLower(email)
 
@@ -56,7 +63,7 @@ func (h *UserTestDB) Get(email string) (*UserTestDBEntry, error) {
 
 	item, err := dbtxn.Get(userEStorageId)
 	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
-		return nil, fmt.Errorf("The user entry with id %s does not exist", email)
+		return nil, fmt.Errorf("The user entry with ref %s does not exist", getUserRef(email))
 	} else if err != nil {
 		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
 	}
@@ -75,6 +82,50 @@ func (h *UserTestDB) Get(email string) (*UserTestDBEntry, error) {
 	return &usertEntryInst, nil
 }
 
+// Update runs update on user entry read in the same transaction as the write, so checks in update see the latest entry. Retried on conflict
+func (h *UserTestDB) Update(email string, update func(usere *UserTestDBEntry) error) error {
+	email = strings.ToLower(email)
+
+	userEStorageId := append(h.prefix, []byte(email)...)
+
+	return fdoshared.UpdateWithRetry(h.db, func(dbtxn *badger.Txn) error {
+		item, err := dbtxn.Get(userEStorageId)
+		if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+			return fmt.Errorf("The user entry with ref %s does not exist", getUserRef(email))
+		} else if err != nil {
+			return errors.New("Failed locating entry. The error is: " + err.Error())
+		}
+
+		itemBytes, err := item.ValueCopy(nil)
+		if err != nil {
+			return errors.New("Failed reading entry value. The error is: " + err.Error())
+		}
+
+		var usertEntryInst UserTestDBEntry
+		err = fdoshared.CborCust.Unmarshal(itemBytes, &usertEntryInst)
+		if err != nil {
+			return errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
+		}
+
+		err = update(&usertEntryInst)
+		if err != nil {
+			return err
+		}
+
+		usereBytes, err := fdoshared.CborCust.Marshal(usertEntryInst)
+		if err != nil {
+			return errors.New("Failed to marshal User entry. The error is: " + err.Error())
+		}
+
+		err = dbtxn.SetEntry(badger.NewEntry(userEStorageId, usereBytes))
+		if err != nil {
+			return errors.New("Failed creating User db entry instance. The error is: " + err.Error())
+		}
+
+		return nil
+	})
+}
+
 func (h *UserTestDB) ResetUsers() error {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
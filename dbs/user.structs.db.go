@@ -14,21 +14,23 @@ type UserTestDB struct {
 }
 
 type DOTestInst struct {
-	_           struct{} `cbor:",toarray"`
-	Uuid        []byte
-	Url         string
-	To2         []byte
+	_    struct{} `cbor:",toarray"`
+	Uuid []byte
+	Url  string
+	To2  []byte
+	// Listener of the DO registering its voucher with RV. Empty for DO tests created before TO0 owner tests
 	ListenerTo0 []byte
 }
 
-func NewDOTestInst(url string, to2 []byte) DOTestInst {
+func NewDOTestInst(url string, to2 []byte, listenerTo0 []byte) DOTestInst {
 	newUuid, _ := uuid.NewRandom()
 	uuidBytes, _ := newUuid.MarshalBinary()
 
 	return DOTestInst{
-		Uuid: uuidBytes,
-		Url:  url,
-		To2:  to2,
+		Uuid:        uuidBytes,
+		Url:         url,
+		To2:         to2,
+		ListenerTo0: listenerTo0,
 	}
 }
 
@@ -89,6 +91,9 @@ type UserTestDBEntry struct {
 	RVTestInsts     []RVTestInst     `cbor:"test_rv"`
 	DOTestInsts     []DOTestInst     `cbor:"test_do"`
 	DeviceTestInsts []DeviceTestInst `cbor:"test_device"`
+
+	// Grants access to admin API with the user session, see ADMIN_TOKEN
+	IsAdmin bool `cbor:"is_admin"`
 }
 
 func (h *UserTestDBEntry) RVT_ContainID(rvtid []byte) bool {
@@ -101,6 +106,36 @@ func (h *UserTestDBEntry) RVT_ContainID(rvtid []byte) bool {
 	return false
 }
 
+func (h *UserTestDBEntry) RVT_GetInst(rvtid []byte) (*RVTestInst, bool) {
+	for _, rvt := range h.RVTestInsts {
+		if bytes.Equal(rvt.Uuid, rvtid) {
+			return &rvt, true
+		}
+	}
+
+	return nil, false
+}
+
+func (h *UserTestDBEntry) DOT_GetInst(dotid []byte) (*DOTestInst, bool) {
+	for _, dotinst := range h.DOTestInsts {
+		if bytes.Equal(dotinst.Uuid, dotid) {
+			return &dotinst, true
+		}
+	}
+
+	return nil, false
+}
+
+func (h *UserTestDBEntry) DOT_GetInstByListenerTo0(listenerid []byte) (*DOTestInst, bool) {
+	for _, dotinst := range h.DOTestInsts {
+		if len(dotinst.ListenerTo0) != 0 && bytes.Equal(dotinst.ListenerTo0, listenerid) {
+			return &dotinst, true
+		}
+	}
+
+	return nil, false
+}
+
 func (h *UserTestDBEntry) DOT_ContainID(dotid []byte) bool {
 	for _, dotinst := range h.DOTestInsts {
 		if bytes.Equal(dotinst.To2, dotid) || bytes.Equal(dotinst.ListenerTo0, dotid) {
@@ -111,6 +146,16 @@ func (h *UserTestDBEntry) DOT_ContainID(dotid []byte) bool {
 	return false
 }
 
+func (h *UserTestDBEntry) DeviceT_ContainGuid(guid fdoshared.FdoGuid) bool {
+	for _, devtinst := range h.DeviceTestInsts {
+		if devtinst.DeviceGuid == guid {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (h *UserTestDBEntry) DeviceT_ContainID(id []byte) bool {
 	for _, devtinst := range h.DeviceTestInsts {
 		if bytes.Equal(devtinst.ListenerUuid, id) {
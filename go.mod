@@ -15,6 +15,7 @@ require (
 	github.com/drhodes/golorem v0.0.0-20220328165741-da82e5b29246
 	github.com/fido-alliance/dhkx v0.3.4
 	github.com/joho/godotenv v1.5.1
+	github.com/ugorji/go/codec v1.2.11
 )
 
 require (
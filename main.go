@@ -2,18 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
 	fdodocommon "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/common"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to1"
@@ -25,7 +32,9 @@ import (
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testcomdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testvectors"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/testexec"
 
 	"github.com/joho/godotenv"
 
@@ -64,12 +73,123 @@ func TryReadingWawDIFile(filepath string) (*fdoshared.WawDeviceCredential, error
 	return &wawdicred, nil
 }
 
+// loadTLSIdentity returns imported RV identity, or owner identity, as RV and DO share the listener. Read on every handshake, so imports apply without restart
+func loadTLSIdentity(identityDb *dodbs.IdentityDB) (*tls.Certificate, error) {
+	for _, role := range []fdoshared.ServerIdentityRole{fdoshared.IDENTITY_ROLE_RV, fdoshared.IDENTITY_ROLE_OWNER} {
+		identity, err := identityDb.Get(role)
+		if err != nil {
+			return nil, err
+		}
+
+		if identity != nil {
+			return identity.GetTLSCertificate()
+		}
+	}
+
+	return nil, errors.New("no RV or owner identity imported. See /api/admin/identity")
+}
+
+// TryReadingVoucherRVInfo reads RVInfo from the header of the voucher of wawcred
+func TryReadingVoucherRVInfo(filepath string, wawcred fdoshared.WawDeviceCredential) (fdoshared.RendezvousInfo, error) {
+	fileBytes, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file \"%s\". %s ", filepath, err.Error())
+	}
+
+	vandk, err := fdodocommon.DecodePemVoucherAndKey(string(fileBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%s: error decoding voucher. %s", filepath, err.Error())
+	}
+
+	ovHeader, err := vandk.Voucher.GetOVHeader()
+	if err != nil {
+		return nil, fmt.Errorf("%s: error decoding voucher header. %s", filepath, err.Error())
+	}
+
+	if ovHeader.OVGuid != wawcred.DCGuid {
+		return nil, fmt.Errorf("%s: voucher GUID %s does not match credential GUID %s", filepath, ovHeader.OVGuid.GetFormatted(), wawcred.DCGuid.GetFormatted())
+	}
+
+	return ovHeader.OVRvInfo, nil
+}
+
+// saveFuzzCases writes each crashing or non graceful case as JSON, so it can be replayed with --replay
+func saveFuzzCases(outDir string, report testexec.FuzzReport) error {
+	err := os.MkdirAll(outDir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating fuzz output folder. %s", err.Error())
+	}
+
+	for _, fuzzCase := range report.Interesting {
+		fuzzCaseBytes, _ := json.MarshalIndent(fuzzCase, "", "  ")
+
+		casePath := filepath.Join(outDir, fmt.Sprintf("to2-%d-%s-%d.json", fuzzCase.Cmd, fuzzCase.Outcome, fuzzCase.Mutation.Seed))
+		err = os.WriteFile(casePath, fuzzCaseBytes, 0644)
+		if err != nil {
+			return fmt.Errorf("error saving fuzz case. %s", err.Error())
+		}
+
+		log.Printf("Saved %s case to %s", fuzzCase.Outcome, casePath)
+	}
+
+	return nil
+}
+
+func readFuzzCase(casePath string) (*testexec.FuzzCase, error) {
+	fuzzCaseBytes, err := os.ReadFile(casePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fuzz case file. %s", err.Error())
+	}
+
+	var fuzzCase testexec.FuzzCase
+	err = json.Unmarshal(fuzzCaseBytes, &fuzzCase)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding fuzz case file. %s", err.Error())
+	}
+
+	return &fuzzCase, nil
+}
+
+// followRVInfo locates the owner with the voucher RVInfo, the way the device would, and logs mismatches with rvUrl and doUrl
+func followRVInfo(voucherPath string, wawcred fdoshared.WawDeviceCredential, rvUrl string, doUrl string) (*testexec.RVInfoReport, error) {
+	rvInfo, err := TryReadingVoucherRVInfo(voucherPath, wawcred)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := testexec.FollowCredentialRVInfo(wawcred, rvInfo, rvUrl, doUrl)
+	if report != nil {
+		for _, logLine := range report.Logs {
+			log.Println(logLine)
+		}
+
+		for _, mismatch := range report.Mismatches {
+			log.Println("RVInfo mismatch: " + mismatch)
+		}
+	}
+
+	return report, err
+}
+
+// Badger is an embedded single-process DB. Only one server instance may own BADGER_LOCATION,
+// so the lock guard must stay enabled. Sessions and listener states are not shared between instances.
+// With DB_IN_MEMORY=true nothing is written to BADGER_LOCATION, and the DB vanishes on exit.
 func InitBadgerDB() *badger.DB {
 	options := badger.DefaultOptions(BADGER_LOCATION)
+	if os.Getenv(string(fdoshared.CFG_ENV_DB_IN_MEMORY)) == "true" {
+		log.Println("Running Badger DB in memory. All data will be lost on exit")
+		options = badger.DefaultOptions("").WithInMemory(true)
+	}
+
 	options.Logger = nil
+	options.BypassLockGuard = false
 
 	db, err := badger.Open(options)
 	if err != nil {
+		if strings.Contains(err.Error(), "Cannot acquire directory lock") {
+			log.Panicln("Error opening Badger DB. Another server instance is already using " + BADGER_LOCATION + ". Running multiple instances against the same DB is not supported.")
+		}
+
 		log.Panicln("Error opening Badger DB. " + err.Error())
 	}
 
@@ -111,6 +231,176 @@ func loadEnvCtx() context.Context {
 
 	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_DEV_ENV, fdoshared.CFG_ENV_PROD, false)
 
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_EXPORT_SESSION_KEYS, "false", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_ADMIN_TOKEN, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_CAPTURE_MESSAGES, "false", false)
+	testcomdbs.CaptureMessages = ctx.Value(fdoshared.CFG_ENV_CAPTURE_MESSAGES) == "true"
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_LOG_REDACT_PII, "false", false)
+	commonapi.RedactPII = ctx.Value(fdoshared.CFG_ENV_LOG_REDACT_PII) == "true"
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_LOG_LEVEL, "", false)
+	logLevel, err := fdoshared.ParseLogLevel(ctx.Value(fdoshared.CFG_ENV_LOG_LEVEL).(string))
+	if err != nil {
+		log.Fatalf("Error loading log level: %v", err)
+	}
+	fdoshared.MinLogLevel = logLevel
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_SECONDARY_RV_URL, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_SECONDARY_RV_PORT, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_TLS_PORT, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_VERIFY_TLS_DEVICE_CERT, "false", false)
+	fdoshared.VerifyTLSDeviceCert = ctx.Value(fdoshared.CFG_ENV_VERIFY_TLS_DEVICE_CERT) == "true"
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_EXPERIMENTAL_ED25519, "false", false)
+	fdoshared.SetExperimentalEd25519(ctx.Value(fdoshared.CFG_ENV_EXPERIMENTAL_ED25519) == "true")
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_TLS_CLIENT_CA, "", false)
+	if tlsClientCaPath := ctx.Value(fdoshared.CFG_ENV_TLS_CLIENT_CA).(string); tlsClientCaPath != "" {
+		fdoshared.TLSClientCAs, err = fdoshared.LoadTLSClientCAs(tlsClientCaPath)
+		if err != nil {
+			log.Fatalf("Error loading TLS client CA: %v", err)
+		}
+	}
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_RV_COUNTERSIGN_TO1D, "false", false)
+	fdoshared.RvCountersignTo1d = ctx.Value(fdoshared.CFG_ENV_RV_COUNTERSIGN_TO1D) == "true"
+
+	// Resource limits
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_OVENTRIES, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_OVENTRY_SIZE, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_DEVICE_SIMS, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_DEVICE_SIMS_SIZE, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_DEVICE_MESSAGE_SIZE, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_OWNER_MESSAGE_SIZE, "", false)
+
+	resourceLimits, err := fdoshared.NewResourceLimits(ctx.Value(fdoshared.CFG_ENV_MAX_OVENTRIES).(string), ctx.Value(fdoshared.CFG_ENV_MAX_OVENTRY_SIZE).(string), ctx.Value(fdoshared.CFG_ENV_MAX_DEVICE_SIMS).(string), ctx.Value(fdoshared.CFG_ENV_MAX_DEVICE_SIMS_SIZE).(string), ctx.Value(fdoshared.CFG_ENV_MAX_DEVICE_MESSAGE_SIZE).(string), ctx.Value(fdoshared.CFG_ENV_MAX_OWNER_MESSAGE_SIZE).(string))
+	if err != nil {
+		log.Fatalf("Error loading resource limits: %v", err)
+	}
+	fdoshared.Limits = *resourceLimits
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_VOUCHER_FILE_SIZE, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_VOUCHER_FILES, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_USER_VOUCHER_STORAGE, "", false)
+
+	uploadLimits, err := fdoshared.NewVoucherUploadLimits(ctx.Value(fdoshared.CFG_ENV_MAX_VOUCHER_FILE_SIZE).(string), ctx.Value(fdoshared.CFG_ENV_MAX_VOUCHER_FILES).(string), ctx.Value(fdoshared.CFG_ENV_MAX_USER_VOUCHER_STORAGE).(string))
+	if err != nil {
+		log.Fatalf("Error loading voucher upload limits: %v", err)
+	}
+	fdoshared.UploadLimits = *uploadLimits
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_OVENTRY_BATCH_SIZE, "", false)
+
+	ovEntryBatchSize, err := to2.ParseOVEntryBatchSize(ctx.Value(fdoshared.CFG_ENV_OVENTRY_BATCH_SIZE).(string))
+	if err != nil {
+		log.Fatalf("Error loading OVEntry batch size: %v", err)
+	}
+	to2.OVEntryBatchSize = ovEntryBatchSize
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_NETWORK_RETRIES, "", false)
+
+	networkRetries, err := to2.ParseNetworkRetries(ctx.Value(fdoshared.CFG_ENV_NETWORK_RETRIES).(string))
+	if err != nil {
+		log.Fatalf("Error loading network retries: %v", err)
+	}
+	to2.NetworkRetries = networkRetries
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_DB_CONFLICT_RETRIES, "", false)
+
+	dbConflictRetries, err := fdoshared.ParseDbConflictRetries(ctx.Value(fdoshared.CFG_ENV_DB_CONFLICT_RETRIES).(string))
+	if err != nil {
+		log.Fatalf("Error loading DB conflict retries: %v", err)
+	}
+	fdoshared.DbConflictRetries = dbConflictRetries
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_MAX_RUNS_PER_USER, "", false)
+
+	maxRunsPerUser, err := fdoshared.ParseMaxRunsPerUser(ctx.Value(fdoshared.CFG_ENV_MAX_RUNS_PER_USER).(string))
+	if err != nil {
+		log.Fatalf("Error loading max runs per user: %v", err)
+	}
+	fdoshared.MaxRunsPerUser = maxRunsPerUser
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_RECORD_UNKNOWN_MESSAGES, "false", false)
+	fdoshared.RecordUnknownMessages = ctx.Value(fdoshared.CFG_ENV_RECORD_UNKNOWN_MESSAGES) == "true"
+
+	// Outbound policy
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_OUTBOUND_ALLOWLIST, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_OUTBOUND_DENYLIST, "", false)
+
+	outboundPolicy, err := fdoshared.NewOutboundPolicy(ctx.Value(fdoshared.CFG_ENV_OUTBOUND_ALLOWLIST).(string), ctx.Value(fdoshared.CFG_ENV_OUTBOUND_DENYLIST).(string))
+	if err != nil {
+		log.Fatalf("Error loading outbound policy: %v", err)
+	}
+	fdoshared.Outbound = *outboundPolicy
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_HTTP_REDIRECT_POLICY, "", false)
+
+	redirectPolicy, err := fdoshared.ParseRedirectPolicy(ctx.Value(fdoshared.CFG_ENV_HTTP_REDIRECT_POLICY).(string))
+	if err != nil {
+		log.Fatalf("Error loading redirect policy: %v", err)
+	}
+	fdoshared.Redirects = redirectPolicy
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_TO2_CONCURRENT_ONBOARDING, "", false)
+
+	onboardingPolicy, err := fdoshared.ParseConcurrentOnboardingPolicy(ctx.Value(fdoshared.CFG_ENV_TO2_CONCURRENT_ONBOARDING).(string))
+	if err != nil {
+		log.Fatalf("Error loading concurrent onboarding policy: %v", err)
+	}
+	fdoshared.ConcurrentOnboarding = onboardingPolicy
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_TO2_REONBOARD_POLICY, "", false)
+
+	reonboardPolicy, err := fdoshared.ParseReonboardPolicy(ctx.Value(fdoshared.CFG_ENV_TO2_REONBOARD_POLICY).(string), fdoshared.REONBOARD_POLICY_ALLOW)
+	if err != nil {
+		log.Fatalf("Error loading reonboard policy: %v", err)
+	}
+	fdoshared.Reonboard = reonboardPolicy
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_TO2_SESSION_TTL, "", false)
+
+	to2SessionTTL, err := fdoshared.ParseSessionTTL(ctx.Value(fdoshared.CFG_ENV_TO2_SESSION_TTL).(string), dodbs.DEFAULT_SESSION_TTL)
+	if err != nil {
+		log.Fatalf("Error loading TO2 session TTL: %v", err)
+	}
+	dodbs.SessionTTL = to2SessionTTL
+	fdoshared.FdoDrainer.SetSessionTTL(to2SessionTTL)
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_TO2_SESSION_SLIDING_TTL, "true", false)
+	dodbs.SessionSlidingTTL = ctx.Value(fdoshared.CFG_ENV_TO2_SESSION_SLIDING_TTL) == "true"
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_CAMPAIGN_PAUSE_TIMEOUT, "", false)
+
+	campaignPauseTimeout, err := fdoshared.ParseSessionTTL(ctx.Value(fdoshared.CFG_ENV_CAMPAIGN_PAUSE_TIMEOUT).(string), testexec.DEFAULT_CAMPAIGN_PAUSE_TIMEOUT)
+	if err != nil {
+		log.Fatalf("Error loading campaign pause timeout: %v", err)
+	}
+	testexec.CampaignPauseTimeout = campaignPauseTimeout
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_SHUTDOWN_GRACE_PERIOD, "", false)
+
+	shutdownGracePeriod, err := fdoshared.ParseSessionTTL(ctx.Value(fdoshared.CFG_ENV_SHUTDOWN_GRACE_PERIOD).(string), fdoshared.DEFAULT_SHUTDOWN_GRACE_PERIOD)
+	if err != nil {
+		log.Fatalf("Error loading shutdown grace period: %v", err)
+	}
+	fdoshared.ShutdownGracePeriod = shutdownGracePeriod
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_LOGIN_SESSION_TTL, "", false)
+
+	loginSessionTTL, err := fdoshared.ParseSessionTTL(ctx.Value(fdoshared.CFG_ENV_LOGIN_SESSION_TTL).(string), dbs.DEFAULT_LOGIN_SESSION_TTL)
+	if err != nil {
+		log.Fatalf("Error loading login session TTL: %v", err)
+	}
+	dbs.LoginSessionTTL = loginSessionTTL
+
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_LOGIN_RATE_LIMIT, "", false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_LOGIN_RATE_BURST, "", false)
+
+	loginLimiter, err := commonapi.ParseRateLimit(ctx.Value(fdoshared.CFG_ENV_LOGIN_RATE_LIMIT).(string), ctx.Value(fdoshared.CFG_ENV_LOGIN_RATE_BURST).(string), commonapi.DEFAULT_LOGIN_RATE_LIMIT, commonapi.DEFAULT_LOGIN_RATE_BURST)
+	if err != nil {
+		log.Fatalf("Error loading login rate limit: %v", err)
+	}
+	commonapi.LoginLimiter = loginLimiter
+
 	// For interop testing
 	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_INTEROP_DASHBOARD_URL, "", false)
 	iopEnabled := ctx.Value(fdoshared.CFG_ENV_INTEROP_DASHBOARD_URL).(string) != ""
@@ -208,19 +498,102 @@ func main() {
 
 					ctx := loadEnvCtx()
 
+					// Countersigning needs RV identity key. Refuse to start, rather than serve To1d without the countersignature devices expect
+					if fdoshared.RvCountersignTo1d {
+						rvIdentity, err := dodbs.NewIdentityDB(db).Get(fdoshared.IDENTITY_ROLE_RV)
+						if err != nil || rvIdentity == nil {
+							return fmt.Errorf("RV_COUNTERSIGN_TO1D is set, but no RV identity is imported. Import it with /api/admin/identity, then restart with RV_COUNTERSIGN_TO1D=true")
+						}
+					}
+
+					// Live log streams of /api/admin/logs
+					log.SetOutput(io.MultiWriter(os.Stderr, commonapi.Logs))
+
 					// Setup FDO listeners
 					fdodo.SetupServer(db, ctx)
 					fdorv.SetupServer(db, ctx)
 					api.SetupServer(db, ctx)
 
 					selectedPort := ctx.Value(fdoshared.CFG_ENV_PORT).(int)
+					mainServer := &http.Server{
+						Addr:    fmt.Sprintf(":%d", selectedPort),
+						Handler: fdoshared.RequireTLSClientCert(fdoshared.TLSClientCAs, http.DefaultServeMux),
+					}
+					servers := []*http.Server{mainServer}
+
+					secondaryRvPort := ctx.Value(fdoshared.CFG_ENV_SECONDARY_RV_PORT).(string)
+					if secondaryRvPort != "" {
+						secondaryRvServer := &http.Server{
+							Addr:    ":" + secondaryRvPort,
+							Handler: fdoshared.RequireTLSClientCert(fdoshared.TLSClientCAs, fdorv.SetupSecondaryServer(db, ctx)),
+						}
+						servers = append(servers, secondaryRvServer)
+
+						go func() {
+							log.Printf("Starting secondary RV at port %s...", secondaryRvPort)
+							err := secondaryRvServer.ListenAndServe()
+							if err != nil && !errors.Is(err, http.ErrServerClosed) {
+								log.Panicln("Error starting secondary RV server. " + err.Error())
+							}
+						}()
+					}
+
+					tlsPort := ctx.Value(fdoshared.CFG_ENV_TLS_PORT).(string)
+					if tlsPort != "" {
+						identityDb := dodbs.NewIdentityDB(db)
+						tlsServer := &http.Server{
+							Addr:    ":" + tlsPort,
+							Handler: fdoshared.RequireTLSClientCert(fdoshared.TLSClientCAs, http.DefaultServeMux),
+							TLSConfig: &tls.Config{
+								GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+									return loadTLSIdentity(identityDb)
+								},
+							},
+						}
+
+						// Device certificates chain to manufacturer CAs, so the chain is not verified here. Client certificate is matched against the voucher instead
+						if fdoshared.VerifyTLSDeviceCert {
+							tlsServer.TLSConfig.ClientAuth = tls.RequestClientCert
+						}
+
+						// With mTLS, client certificate chain is verified by the listener. Handshake without certificate is allowed, so the device gets FDO error
+						if fdoshared.TLSClientCAs != nil {
+							tlsServer.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+							tlsServer.TLSConfig.ClientCAs = fdoshared.TLSClientCAs
+						}
+
+						servers = append(servers, tlsServer)
+
+						go func() {
+							log.Printf("Starting HTTPS server at port %s...", tlsPort)
+							err := tlsServer.ListenAndServeTLS("", "")
+							if err != nil && !errors.Is(err, http.ErrServerClosed) {
+								log.Panicln("Error starting HTTPS server. " + err.Error())
+							}
+						}()
+					}
+
+					// On SIGTERM or SIGINT, let onboarding sessions complete before DB is closed
+					shutdownDone := make(chan struct{})
+					go func() {
+						signals := make(chan os.Signal, 1)
+						signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+						<-signals
+
+						fdoshared.GracefulShutdown(fdoshared.FdoDrainer, fdoshared.ShutdownGracePeriod, servers...)
+						close(shutdownDone)
+					}()
+
 					log.Printf("Starting server at port %d... \n. http://localhost:%d", selectedPort, selectedPort)
 
-					err = http.ListenAndServe(fmt.Sprintf(":%d", selectedPort), nil)
-					if err != nil {
+					err = mainServer.ListenAndServe()
+					if err != nil && !errors.Is(err, http.ErrServerClosed) {
 						log.Panicln("Error starting HTTP server. " + err.Error())
 					}
 
+					<-shutdownDone
+					log.Println("Server stopped")
+
 					return nil
 				},
 			},
@@ -272,6 +645,39 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:      "test_vectors",
+				Usage:     "Runs reference test vectors through server parsing and verification",
+				UsageText: "[Path to vectors folder]",
+				Action: func(c *cli.Context) error {
+					if c.Args().Len() != 1 {
+						return fmt.Errorf("missing folder path")
+					}
+
+					results, err := testvectors.RunDirectory(c.Args().Get(0))
+					if err != nil {
+						return err
+					}
+
+					failed := 0
+					for _, result := range results {
+						if result.Passed {
+							log.Printf("PASS %s: %s", result.File, result.Name)
+						} else {
+							failed = failed + 1
+							log.Printf("FAIL %s: %s. %s", result.File, result.Name, result.Error)
+						}
+					}
+
+					log.Printf("%d/%d vectors passed", len(results)-failed, len(results))
+
+					if failed > 0 {
+						return fmt.Errorf("%d vectors failed", failed)
+					}
+
+					return nil
+				},
+			},
 			{
 				Name: "test_devmod",
 				Action: func(c *cli.Context) error {
@@ -372,6 +778,12 @@ func main() {
 					{
 						Name:  "generate",
 						Usage: "Generate virtual device credential and voucher",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "rv-bypass",
+								Usage: "Owner (DO) URL. Generates RVBypass RVInfo, so device skips TO1 and runs TO2 directly with the owner",
+							},
+						},
 						Action: func(c *cli.Context) error {
 							enforceSha1GoDebug()
 							deviceSgType := fdoshared.RandomDeviceSgType()
@@ -390,6 +802,13 @@ func main() {
 								log.Panicln(err)
 							}
 
+							if c.String("rv-bypass") != "" {
+								rvInfo, err = fdoshared.UrlsToBypassRendezvousInfo([]string{c.String("rv-bypass")})
+								if err != nil {
+									log.Panicln(err)
+								}
+							}
+
 							// rvinfob, err := fdoshared.CborCust.Marshal(rvInfo)
 
 							// print("RVINFO: ", hex.EncodeToString(rvinfob))
@@ -407,6 +826,16 @@ func main() {
 						Name:      "to1",
 						Usage:     "Execute TO1 exchange with RV server",
 						UsageText: "[FDO RV Server URL] [Path to DI file]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "rvinfo",
+								Usage: "Path to the voucher of the device. Runs TO1 with RV servers of the voucher RVInfo instead, and reports if RV Server URL is not one of them",
+							},
+							&cli.StringFlag{
+								Name:  "rvcert",
+								Usage: "Path to PEM certificate chain of the RV identity, leaf first. Device requires To1d in TO1.RVRedirect to carry valid RV countersignature",
+							},
+						},
 						Action: func(c *cli.Context) error {
 							enforceSha1GoDebug()
 							if c.Args().Len() != 2 {
@@ -422,10 +851,34 @@ func main() {
 								return err
 							}
 
+							if c.String("rvinfo") != "" {
+								report, err := followRVInfo(c.String("rvinfo"), *wawcred, url, "")
+								if err != nil {
+									return fmt.Errorf("error following RVInfo. %s", err.Error())
+								}
+
+								log.Println("Success RV: " + report.RvUrl + " Owner: " + report.OwnerUrl)
+								return nil
+							}
+
 							to1inst := to1.NewTo1Requestor(fdoshared.SRVEntry{
 								SrvURL: url,
 							}, *wawcred)
 
+							if c.String("rvcert") != "" {
+								rvCertChainPem, err := os.ReadFile(c.String("rvcert"))
+								if err != nil {
+									return fmt.Errorf("error reading RV certificate chain. %s", err.Error())
+								}
+
+								rvPublicKey, err := fdoshared.NewServerIdentityPublicKey(rvCertChainPem)
+								if err != nil {
+									return fmt.Errorf("error decoding RV certificate chain. %s", err.Error())
+								}
+
+								to1inst.RequireRVCountersign(*rvPublicKey)
+							}
+
 							helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
 							if err != nil {
 								log.Printf("Error running HelloRV30. %s", err.Error())
@@ -468,6 +921,12 @@ func main() {
 						Name:      "to2",
 						Usage:     "Execute TO exchange with RV server",
 						UsageText: "[FDO RV Server URL] [Path to DI file]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "rvinfo",
+								Usage: "Path to the voucher of the device. Locates the owner with the voucher RVInfo and TO1 first, runs TO2 with it, and reports if the given URL is not the owner",
+							},
+						},
 						Action: func(c *cli.Context) error {
 							enforceSha1GoDebug()
 							if c.Args().Len() != 2 {
@@ -485,10 +944,22 @@ func main() {
 								return err
 							}
 
+							if c.String("rvinfo") != "" {
+								report, err := followRVInfo(c.String("rvinfo"), *wawcred, "", url)
+								if err != nil {
+									return fmt.Errorf("error following RVInfo. %s", err.Error())
+								}
+
+								url = report.OwnerUrl
+							}
+
 							log.Println("Starting HelloDevice60")
-							to2inst := to2.NewTo2Requestor(fdoshared.SRVEntry{
+							to2inst, err := to2.NewTo2RequestorAutoSuite(fdoshared.SRVEntry{
 								SrvURL: url,
-							}, *wawcred, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+							}, *wawcred)
+							if err != nil {
+								return err
+							}
 
 							to2proveOvhdrPayload, _, err := to2inst.HelloDevice60(testcom.NULL_TEST)
 							if err != nil {
@@ -521,13 +992,9 @@ func main() {
 								return nil
 							}
 
-							lastOvEntry := ovEntries[len(ovEntries)-1]
-							loePubKey, _ := lastOvEntry.GetOVEntryPubKey()
-
-							err = to2inst.ProveOVHdr61PubKey.Equal(loePubKey)
+							err = to2inst.VerifyOwnerPubKey(ovEntriesS)
 							if err != nil {
-								log.Println(err)
-								return nil
+								return err
 							}
 
 							//64
@@ -630,6 +1097,85 @@ func main() {
 							return nil
 						},
 					},
+					{
+						Name:      "fuzz",
+						Usage:     "Fuzz TO2 message of the owner with mutated messages, and report crashes and non graceful errors",
+						UsageText: "[FDO DO Server URL] [Path to DI file]",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "cmd",
+								Usage: "TO2 message number to mutate. 60, 62, 64, 66, 68 or 70",
+								Value: int(fdoshared.TO2_60_HELLO_DEVICE),
+							},
+							&cli.IntFlag{
+								Name:  "iterations",
+								Usage: "Number of mutated messages",
+								Value: 100,
+							},
+							&cli.Int64Flag{
+								Name:  "seed",
+								Usage: "Seed of the first mutation. Defaults to current time",
+							},
+							&cli.StringFlag{
+								Name:  "out",
+								Usage: "Folder for crashing and non graceful cases",
+								Value: "./fuzz",
+							},
+							&cli.StringFlag{
+								Name:  "replay",
+								Usage: "Path to saved case. Runs only its mutation",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							enforceSha1GoDebug()
+							if c.Args().Len() != 2 {
+								log.Println("Missing URL or Filename")
+								return nil
+							}
+
+							srvEntry := fdoshared.SRVEntry{
+								SrvURL: c.Args().Get(0),
+							}
+
+							wawcred, err := TryReadingWawDIFile(c.Args().Get(1))
+							if err != nil {
+								return err
+							}
+
+							if c.String("replay") != "" {
+								savedCase, err := readFuzzCase(c.String("replay"))
+								if err != nil {
+									return err
+								}
+
+								fuzzCase, err := testexec.RunFuzzCase(srvEntry, *wawcred, savedCase.Cmd, savedCase.Mutation)
+								if err != nil {
+									return err
+								}
+
+								log.Printf("TO2 %d %s: %s. %s", fuzzCase.Cmd, fuzzCase.Description, fuzzCase.Outcome, fuzzCase.Detail)
+								return nil
+							}
+
+							seed := c.Int64("seed")
+							if !c.IsSet("seed") {
+								seed = time.Now().UnixNano()
+							}
+
+							report, err := testexec.FuzzTo2(srvEntry, *wawcred, fdoshared.FdoCmd(c.Int("cmd")), c.Int("iterations"), seed)
+							if err != nil && report == nil {
+								return err
+							}
+
+							if err != nil {
+								log.Printf("Fuzzing stopped. %s", err.Error())
+							}
+
+							log.Printf("Fuzzed TO2 %d with seed %d. Outcomes: %v", report.Cmd, report.Seed, report.Outcomes)
+
+							return saveFuzzCases(c.String("out"), *report)
+						},
+					},
 					{
 						Name:      "do_load_vouchers",
 						Usage:     "Loads vouchers into DO DB from a folder",
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api"
+	fdodo "github.com/fido-alliance/iot-fdo-conformance-tools/core/do"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestServeInMemory(t *testing.T) {
+	_, err := os.Stat(BADGER_LOCATION)
+	dbExistedBefore := err == nil
+
+	t.Setenv(string(fdoshared.CFG_ENV_DB_IN_MEMORY), "true")
+
+	db := InitBadgerDB()
+	defer db.Close()
+
+	if !db.Opts().InMemory {
+		t.Fatalf("Expected Badger DB to be in memory")
+	}
+
+	_, err = os.Stat(BADGER_LOCATION)
+	if !dbExistedBefore && err == nil {
+		t.Errorf("Expected in-memory DB not to create %s", BADGER_LOCATION)
+	}
+
+	ctx := loadEnvCtx()
+	fdodo.SetupServer(db, ctx)
+	fdorv.SetupServer(db, ctx)
+	api.SetupServer(db, ctx)
+
+	server := httptest.NewServer(http.DefaultServeMux)
+	defer server.Close()
+
+	// User and session are written to and read back from the in-memory DB
+	loginResp, err := http.Post(server.URL+"/api/user/login/onprem", "application/json", bytes.NewBufferString("{}"))
+	if err != nil {
+		t.Fatalf("Failed to login. %s", err.Error())
+	}
+	loginResp.Body.Close()
+
+	if loginResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected login to succeed, got %d", loginResp.StatusCode)
+	}
+
+	loggedInReq, _ := http.NewRequest("GET", server.URL+"/api/user/loggedin", nil)
+	for _, cookie := range loginResp.Cookies() {
+		loggedInReq.AddCookie(cookie)
+	}
+
+	loggedInResp, err := http.DefaultClient.Do(loggedInReq)
+	if err != nil {
+		t.Fatalf("Failed to check session. %s", err.Error())
+	}
+	loggedInResp.Body.Close()
+
+	if loggedInResp.StatusCode != http.StatusOK {
+		t.Errorf("Expected session from in-memory DB to be valid, got %d", loggedInResp.StatusCode)
+	}
+
+	// FDO endpoints are served too, and reject garbage with FDO error
+	helloRVResp, err := http.Post(server.URL+"/fdo/101/msg/30", fdoshared.CONTENT_TYPE_CBOR, bytes.NewBuffer([]byte{0xff}))
+	if err != nil {
+		t.Fatalf("Failed to send HelloRV. %s", err.Error())
+	}
+	helloRVResp.Body.Close()
+
+	if helloRVResp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected HelloRV with bad body to be rejected, got %d", helloRVResp.StatusCode)
+	}
+
+	// Unknown message numbers get FDO error instead of a bare 404
+	unknownResp, err := http.Post(server.URL+"/fdo/101/msg/99", fdoshared.CONTENT_TYPE_CBOR, bytes.NewBuffer([]byte{0xf6}))
+	if err != nil {
+		t.Fatalf("Failed to send unknown message. %s", err.Error())
+	}
+	unknownResp.Body.Close()
+
+	if unknownResp.StatusCode != http.StatusBadRequest || unknownResp.Header.Get("Message-Type") != fdoshared.TO_ERROR_255.ToString() {
+		t.Errorf("Expected unknown message to get FDO error, got %d %s", unknownResp.StatusCode, unknownResp.Header.Get("Message-Type"))
+	}
+}
@@ -0,0 +1,259 @@
+package testexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+// campaignStage is a group of tests dispatched together. Campaign can only be paused between stages
+type campaignStage struct {
+	Name string
+	Run  func()
+}
+
+const DEFAULT_CAMPAIGN_PAUSE_TIMEOUT = 30 * time.Minute
+
+// Set once on startup from CAMPAIGN_PAUSE_TIMEOUT. Campaign paused for longer is aborted
+var CampaignPauseTimeout time.Duration = DEFAULT_CAMPAIGN_PAUSE_TIMEOUT
+
+// CampaignControl pauses, resumes and aborts a running campaign. Paused or aborted campaign does not dispatch new stages, stages in flight finish and keep their results.
+// Every state change is passed to persist
+type CampaignControl struct {
+	mutex      sync.Mutex
+	resumed    *sync.Cond
+	state      testdbs.CampaignState
+	persist    func(state testdbs.CampaignState)
+	pauseTimer *time.Timer
+	// Increased on every pause, so timeout of an earlier pause does not abort the campaign
+	pauseGeneration uint64
+}
+
+func NewCampaignControl(persist func(state testdbs.CampaignState)) *CampaignControl {
+	control := CampaignControl{
+		state: testdbs.CampaignState{
+			State:     testdbs.CAMPAIGN_STATE_RUNNING,
+			Completed: []string{},
+			Running:   []string{},
+			StartedAt: time.Now().Unix(),
+		},
+		persist: persist,
+	}
+	control.resumed = sync.NewCond(&control.mutex)
+	control.saveLocked()
+
+	return &control
+}
+
+// saveLocked persists state copy. Caller holds the mutex
+func (h *CampaignControl) saveLocked() {
+	if h.persist != nil {
+		h.persist(h.checkpointLocked())
+	}
+}
+
+func (h *CampaignControl) checkpointLocked() testdbs.CampaignState {
+	state := h.state
+	state.Completed = append([]string{}, h.state.Completed...)
+	state.Running = append([]string{}, h.state.Running...)
+
+	return state
+}
+
+// Pause stops dispatching stages. Campaign not resumed within CampaignPauseTimeout is aborted
+func (h *CampaignControl) Pause() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.state.State != testdbs.CAMPAIGN_STATE_RUNNING {
+		return
+	}
+
+	h.state.State = testdbs.CAMPAIGN_STATE_PAUSED
+	h.pauseGeneration++
+	pauseGeneration := h.pauseGeneration
+	pauseTimeout := CampaignPauseTimeout
+	h.pauseTimer = time.AfterFunc(pauseTimeout, func() {
+		h.pauseTimedOut(pauseGeneration, pauseTimeout)
+	})
+	h.saveLocked()
+}
+
+// pauseTimedOut aborts campaign still paused by the same pause. Timer Stop does not cancel callback that already started, so resume racing the timeout is checked here
+func (h *CampaignControl) pauseTimedOut(pauseGeneration uint64, pauseTimeout time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.state.State != testdbs.CAMPAIGN_STATE_PAUSED || h.pauseGeneration != pauseGeneration {
+		return
+	}
+
+	h.abortLocked(fmt.Sprintf("not resumed within %s", pauseTimeout))
+}
+
+func (h *CampaignControl) Resume() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.state.State != testdbs.CAMPAIGN_STATE_PAUSED {
+		return
+	}
+
+	h.pauseTimer.Stop()
+	h.state.State = testdbs.CAMPAIGN_STATE_RUNNING
+	h.resumed.Broadcast()
+	h.saveLocked()
+}
+
+// Abort stops dispatching stages for good. Stages in flight finish, and runs are finished with the results so far
+func (h *CampaignControl) Abort() {
+	h.abort("aborted by user")
+}
+
+func (h *CampaignControl) abort(reason string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.abortLocked(reason)
+}
+
+// abortLocked caller holds the mutex
+func (h *CampaignControl) abortLocked(reason string) {
+	if h.state.State != testdbs.CAMPAIGN_STATE_RUNNING && h.state.State != testdbs.CAMPAIGN_STATE_PAUSED {
+		return
+	}
+
+	if h.pauseTimer != nil {
+		h.pauseTimer.Stop()
+	}
+
+	h.state.State = testdbs.CAMPAIGN_STATE_ABORTED
+	h.state.AbortReason = reason
+	h.resumed.Broadcast()
+	h.saveLocked()
+}
+
+// finish marks campaign that dispatched all its stages, or was aborted, as done
+func (h *CampaignControl) finish() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.state.State != testdbs.CAMPAIGN_STATE_ABORTED {
+		h.state.State = testdbs.CAMPAIGN_STATE_FINISHED
+	}
+	h.state.FinishedAt = time.Now().Unix()
+	h.saveLocked()
+}
+
+func (h *CampaignControl) Checkpoint() testdbs.CampaignState {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.checkpointLocked()
+}
+
+// runStages dispatches stages in order, waiting while campaign is paused. Stops dispatching once campaign is aborted
+func (h *CampaignControl) runStages(stages []campaignStage) {
+	for _, stage := range stages {
+		h.mutex.Lock()
+		for h.state.State == testdbs.CAMPAIGN_STATE_PAUSED {
+			h.resumed.Wait()
+		}
+
+		if h.state.State == testdbs.CAMPAIGN_STATE_ABORTED {
+			h.mutex.Unlock()
+			return
+		}
+
+		h.state.Running = append(h.state.Running, stage.Name)
+		h.saveLocked()
+		h.mutex.Unlock()
+
+		stage.Run()
+
+		h.mutex.Lock()
+		for i, runningName := range h.state.Running {
+			if runningName == stage.Name {
+				h.state.Running = append(h.state.Running[:i], h.state.Running[i+1:]...)
+				break
+			}
+		}
+		h.state.Completed = append(h.state.Completed, stage.Name)
+		h.saveLocked()
+		h.mutex.Unlock()
+	}
+}
+
+var campaignControls map[string]*CampaignControl = map[string]*CampaignControl{}
+var campaignControlsMutex sync.Mutex
+
+// StartCampaignControl registers control of a campaign. Returns error if campaign is already running
+func StartCampaignControl(campaignId string, persist func(state testdbs.CampaignState)) (*CampaignControl, error) {
+	campaignControlsMutex.Lock()
+	defer campaignControlsMutex.Unlock()
+
+	if _, ok := campaignControls[campaignId]; ok {
+		return nil, errors.New("campaign is already running")
+	}
+
+	control := NewCampaignControl(persist)
+	campaignControls[campaignId] = control
+
+	return control, nil
+}
+
+// GetCampaignControl returns control of a running campaign
+func GetCampaignControl(campaignId string) (*CampaignControl, bool) {
+	campaignControlsMutex.Lock()
+	defer campaignControlsMutex.Unlock()
+
+	control, ok := campaignControls[campaignId]
+	return control, ok
+}
+
+func EndCampaignControl(campaignId string) {
+	campaignControlsMutex.Lock()
+	defer campaignControlsMutex.Unlock()
+
+	delete(campaignControls, campaignId)
+}
+
+// Runs RV TO0/TO1 and DO TO2 suites of a campaign in parallel, dispatching stages through control, and marks it finished. Results stay in each protocol test instance, see reqtestsdeps.NewCampaignReport
+func ExecuteCampaign(reqteTo0 reqtestsdeps.RequestTestInst, reqteTo1 reqtestsdeps.RequestTestInst, reqteTo2 reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context, control *CampaignControl) {
+	var wg sync.WaitGroup
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+
+		reqtDB.StartNewRun(reqteTo0.Uuid)
+		control.runStages(to0Stages(reqteTo0, reqtDB, devDB, ctx))
+		reqtDB.FinishRun(reqteTo0.Uuid)
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		// TO1 run is a single stage, as its tests share one enrolled voucher
+		control.runStages([]campaignStage{
+			{Name: "TO1", Run: func() { ExecuteRVTestsTo1(reqteTo1, reqtDB, devDB, ctx) }},
+		})
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		reqtDB.StartNewRun(reqteTo2.Uuid)
+		control.runStages(to2Stages(reqteTo2, reqtDB))
+		reqtDB.FinishRun(reqteTo2.Uuid)
+	}()
+
+	wg.Wait()
+	control.finish()
+}
@@ -0,0 +1,235 @@
+package testexec
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+)
+
+func TestCampaignControl_PauseResume(t *testing.T) {
+	control := NewCampaignControl(nil)
+
+	runCounts := make([]int, 5)
+	inFlight := make(chan struct{})
+	finishInFlight := make(chan struct{})
+
+	stages := []campaignStage{}
+	for i := range runCounts {
+		i := i
+		stages = append(stages, campaignStage{
+			Name: fmt.Sprintf("stage %d", i),
+			Run: func() {
+				runCounts[i]++
+
+				if i == 1 {
+					inFlight <- struct{}{}
+					<-finishInFlight
+				}
+			},
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		control.runStages(stages)
+		close(done)
+	}()
+
+	// Pausing while stage 1 is in flight
+	<-inFlight
+	control.Pause()
+
+	checkpoint := control.Checkpoint()
+	if checkpoint.State != testdbs.CAMPAIGN_STATE_PAUSED || len(checkpoint.Running) != 1 || checkpoint.Running[0] != "stage 1" {
+		t.Errorf("Expected paused campaign with stage 1 in flight. Got %+v", checkpoint)
+	}
+
+	close(finishInFlight)
+
+	// Stage in flight finishes, next one is not dispatched
+	for i := 0; i < 100 && len(control.Checkpoint().Completed) < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	checkpoint = control.Checkpoint()
+	if len(checkpoint.Completed) != 2 || len(checkpoint.Running) != 0 || runCounts[2] != 0 {
+		t.Fatalf("Expected 2 completed stages and none dispatched while paused. Got %+v", checkpoint)
+	}
+
+	control.Resume()
+	<-done
+
+	for i, runCount := range runCounts {
+		if runCount != 1 {
+			t.Errorf("Expected stage %d to run once. Ran %d times", i, runCount)
+		}
+	}
+
+	checkpoint = control.Checkpoint()
+	for i, stageName := range checkpoint.Completed {
+		if stageName != stages[i].Name {
+			t.Errorf("Expected %s completed at %d. Got %s", stages[i].Name, i, stageName)
+		}
+	}
+
+	if checkpoint.State != testdbs.CAMPAIGN_STATE_RUNNING || len(checkpoint.Completed) != len(stages) {
+		t.Errorf("Expected all stages completed after resume. Got %+v", checkpoint)
+	}
+}
+
+func TestStartCampaignControl(t *testing.T) {
+	_, err := StartCampaignControl("rvt:dot", nil)
+	if err != nil {
+		t.Fatalf("Failed to start campaign control. %s", err.Error())
+	}
+	defer EndCampaignControl("rvt:dot")
+
+	_, err = StartCampaignControl("rvt:dot", nil)
+	if err == nil {
+		t.Errorf("Expected second start of the same campaign to fail")
+	}
+
+	if _, ok := GetCampaignControl("rvt:dot"); !ok {
+		t.Errorf("Expected running campaign control")
+	}
+
+	if _, ok := GetCampaignControl("other:dot"); ok {
+		t.Errorf("Expected no control for campaign that is not running")
+	}
+}
+
+func TestCampaignControl_Abort(t *testing.T) {
+	defer func(timeout time.Duration) { CampaignPauseTimeout = timeout }(CampaignPauseTimeout)
+	CampaignPauseTimeout = time.Hour
+
+	var mutex sync.Mutex
+	savedStates := []testdbs.CampaignState{}
+	control := NewCampaignControl(func(state testdbs.CampaignState) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		savedStates = append(savedStates, state)
+	})
+
+	runCounts := make([]int, 3)
+	stages := []campaignStage{}
+	for i := range runCounts {
+		i := i
+		stages = append(stages, campaignStage{
+			Name: fmt.Sprintf("stage %d", i),
+			Run:  func() { runCounts[i]++ },
+		})
+	}
+
+	// Abort wakes up paused campaign, and nothing else is dispatched
+	control.Pause()
+
+	done := make(chan struct{})
+	go func() {
+		control.runStages(stages)
+		control.finish()
+		close(done)
+	}()
+
+	control.Abort()
+	<-done
+
+	for i, runCount := range runCounts {
+		if runCount != 0 {
+			t.Errorf("Expected stage %d not to run after abort. Ran %d times", i, runCount)
+		}
+	}
+
+	checkpoint := control.Checkpoint()
+	if checkpoint.State != testdbs.CAMPAIGN_STATE_ABORTED || checkpoint.AbortReason == "" || checkpoint.FinishedAt == 0 {
+		t.Errorf("Expected finished aborted campaign. Got %+v", checkpoint)
+	}
+
+	// Resume after abort has no effect
+	control.Resume()
+	if control.Checkpoint().State != testdbs.CAMPAIGN_STATE_ABORTED {
+		t.Errorf("Expected aborted campaign to stay aborted")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	expectedStates := []testdbs.CampaignRunState{testdbs.CAMPAIGN_STATE_RUNNING, testdbs.CAMPAIGN_STATE_PAUSED, testdbs.CAMPAIGN_STATE_ABORTED, testdbs.CAMPAIGN_STATE_ABORTED}
+	if len(savedStates) != len(expectedStates) {
+		t.Fatalf("Expected %d saved states. Got %+v", len(expectedStates), savedStates)
+	}
+
+	for i, expectedState := range expectedStates {
+		if savedStates[i].State != expectedState {
+			t.Errorf("Expected saved state %d to be %s. Got %s", i, expectedState, savedStates[i].State)
+		}
+	}
+}
+
+func TestCampaignControl_PauseTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { CampaignPauseTimeout = timeout }(CampaignPauseTimeout)
+	CampaignPauseTimeout = 10 * time.Millisecond
+
+	control := NewCampaignControl(nil)
+	control.Pause()
+
+	done := make(chan struct{})
+	go func() {
+		control.runStages([]campaignStage{{Name: "stage", Run: func() { t.Errorf("Unexpected stage run") }}})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected paused campaign to be aborted after pause timeout")
+	}
+
+	if checkpoint := control.Checkpoint(); checkpoint.State != testdbs.CAMPAIGN_STATE_ABORTED {
+		t.Errorf("Expected aborted campaign. Got %+v", checkpoint)
+	}
+}
+
+func TestCampaignControl_ResumeRacingPauseTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { CampaignPauseTimeout = timeout }(CampaignPauseTimeout)
+	CampaignPauseTimeout = time.Hour
+
+	// Timeout callback of a pause that was already resumed, or followed by another pause, does nothing
+	control := NewCampaignControl(nil)
+	control.Pause()
+	control.Resume()
+	control.pauseTimedOut(1, CampaignPauseTimeout)
+
+	control.Pause()
+	control.pauseTimedOut(1, CampaignPauseTimeout)
+
+	if checkpoint := control.Checkpoint(); checkpoint.State != testdbs.CAMPAIGN_STATE_PAUSED {
+		t.Fatalf("Expected stale pause timeout to keep campaign paused. Got %+v", checkpoint)
+	}
+
+	control.pauseTimedOut(2, CampaignPauseTimeout)
+	if checkpoint := control.Checkpoint(); checkpoint.State != testdbs.CAMPAIGN_STATE_ABORTED {
+		t.Fatalf("Expected current pause timeout to abort campaign. Got %+v", checkpoint)
+	}
+
+	// Resume racing pause timer. Campaign that was resumed stays running, even if timer already fired
+	CampaignPauseTimeout = time.Microsecond
+	for i := 0; i < 200; i++ {
+		control := NewCampaignControl(nil)
+		control.Pause()
+		control.Resume()
+
+		if control.Checkpoint().State != testdbs.CAMPAIGN_STATE_RUNNING {
+			continue
+		}
+
+		time.Sleep(time.Millisecond)
+		if checkpoint := control.Checkpoint(); checkpoint.State != testdbs.CAMPAIGN_STATE_RUNNING {
+			t.Fatalf("Expected resumed campaign not to be aborted by pause timeout. Got %+v", checkpoint)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package testexec
 
 import (
-	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
@@ -10,6 +8,10 @@ import (
 
 func executeTo2_60(reqte reqtestsdeps.RequestTestInst, reqtDB *dbs.RequestTestDB) {
 	for _, fdoTestId := range testcom.FIDO_TEST_LIST_DOT_60 {
+		if !reqte.ShouldRun(fdoTestId) {
+			continue
+		}
+
 		testCred, err := reqte.TestVouchers.GetVoucher(testcom.NULL_TEST)
 		if err != nil {
 			errTestState := testcom.NewFailTestState(fdoTestId, "Error getting voucher for TO2 60. "+err.Error())
@@ -19,9 +21,13 @@ func executeTo2_60(reqte reqtestsdeps.RequestTestInst, reqtDB *dbs.RequestTestDB
 		}
 
 		// Generating TO0 handler
-		to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
-			SrvURL: reqte.URL,
-		}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+		to2requestor, err := newTo2Requestor(reqte, testCred.WawDeviceCredential)
+		if err != nil {
+			errTestState := testcom.NewFailTestState(fdoTestId, "Error selecting KEX for TO2 60. "+err.Error())
+
+			reqtDB.ReportTest(reqte.Uuid, testcom.NULL_TEST, errTestState)
+			return
+		}
 
 		switch fdoTestId {
 		case testcom.FIDO_DOT_60_POSITIVE:
@@ -1,8 +1,6 @@
 package testexec
 
 import (
-	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
@@ -10,6 +8,10 @@ import (
 
 func executeTo2_60_Vouchers(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
 	for _, testId := range testcom.FIDO_TEST_LIST_VOUCHER {
+		if !reqte.ShouldRun(testId) {
+			continue
+		}
+
 		testCred, err := reqte.TestVouchers.GetVoucher(testId)
 		if err != nil {
 			errTestState := testcom.FDOTestState{
@@ -22,9 +24,16 @@ func executeTo2_60_Vouchers(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.
 		}
 
 		// Generating TO0 handler
-		to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
-			SrvURL: reqte.URL,
-		}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+		to2requestor, err := newTo2Requestor(reqte, testCred.WawDeviceCredential)
+		if err != nil {
+			errTestState := testcom.FDOTestState{
+				Passed: false,
+				Error:  "Error selecting KEX for TO2 60. " + err.Error(),
+			}
+
+			reqtDB.ReportTest(reqte.Uuid, testId, errTestState)
+			return
+		}
 
 		_, rvtTestState, err := to2requestor.HelloDevice60(testId)
 
@@ -37,6 +46,7 @@ func executeTo2_60_Vouchers(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.
 			rvtTestState = &errTestState
 		}
 
+		rvtTestState.Retries = to2requestor.NetworkRetryCount()
 		reqtDB.ReportTest(reqte.Uuid, testId, *rvtTestState)
 	}
 
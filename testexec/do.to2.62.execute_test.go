@@ -0,0 +1,104 @@
+package testexec
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// Owner claiming more OVEntries in ProveOVHdr than it has. Returns entries below availableEntries, and error for the rest
+func newTruncatedVoucherTestOwner(t *testing.T, availableEntries uint8, entryNums map[uint8]uint8) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var getOVNextEntry fdoshared.GetOVNextEntry62
+		bodyBytes, _ := io.ReadAll(r.Body)
+		err := fdoshared.CborCust.Unmarshal(bodyBytes, &getOVNextEntry)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		entryNum := getOVNextEntry.GetOVNextEntry
+		if entryNum >= availableEntries {
+			fdoErrorBytes, _ := fdoshared.CborCust.Marshal(fdoshared.FdoError{
+				EMErrorCode: fdoshared.INVALID_MESSAGE_ERROR,
+				EMPrevMsgID: fdoshared.TO2_62_GET_OVNEXTENTRY,
+				EMErrorStr:  "No such entry",
+			})
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(fdoErrorBytes)
+			return
+		}
+
+		if mappedNum, ok := entryNums[entryNum]; ok {
+			entryNum = mappedNum
+		}
+
+		entryBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OVNextEntry63{
+			OVEntryNum: entryNum,
+			OVEntry: fdoshared.CoseSignature{
+				Payload: []byte{0xa0},
+			},
+		})
+		w.Write(entryBytes)
+	}))
+}
+
+func TestGetAllOVEntries_NumOVEntriesMismatch(t *testing.T) {
+	owner := newTruncatedVoucherTestOwner(t, 2, nil)
+	defer owner.Close()
+
+	requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+
+	ovEntries, err := requestor.GetAllOVEntries(2)
+	if err != nil || len(ovEntries) != 2 {
+		t.Fatalf("Expected 2 entries to be fetched. %v", err)
+	}
+
+	_, err = requestor.GetAllOVEntries(5)
+	if err == nil {
+		t.Errorf("Expected error for owner claiming more entries than it returns")
+	}
+
+	// Out of order entry
+	outOfOrderOwner := newTruncatedVoucherTestOwner(t, 5, map[uint8]uint8{1: 3})
+	defer outOfOrderOwner.Close()
+
+	requestor = to2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: outOfOrderOwner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+	_, err = requestor.GetAllOVEntries(5)
+	if err == nil {
+		t.Errorf("Expected error for out of order entry")
+	}
+}
+
+func TestRunTo2_62NegativeTest_NumOVEntriesMismatch(t *testing.T) {
+	owner := newTruncatedVoucherTestOwner(t, 2, nil)
+	defer owner.Close()
+
+	requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{SrvURL: owner.URL}, fdoshared.WawDeviceCredential{}, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+
+	// Owner claims 5 entries and has 2. Test index is random, so either the entries before it fail, or owner rejects the test
+	for _, testId := range []testcom.FDOTestID{testcom.FIDO_DOT_62_GETOVNEXT_BAD_INDEX, testcom.FIDO_DOT_62_BAD_ENCODING} {
+		for i := 0; i < 10; i++ {
+			testState := runTo2_62NegativeTest(&requestor, 5, testId)
+			if !testState.Passed && testState.Error == "" {
+				t.Errorf("Expected error for failed %s", testId)
+			}
+		}
+	}
+
+	testState := runTo2_62NegativeTest(&requestor, 0, testcom.FIDO_DOT_62_GETOVNEXT_BAD_INDEX)
+	if testState.Passed {
+		t.Errorf("Expected test to fail for owner claiming no entries")
+	}
+
+	// Single entry, so the negative test is sent first
+	testState = runTo2_62NegativeTest(&requestor, 1, testcom.FIDO_DOT_62_GETOVNEXT_BAD_INDEX)
+	if !testState.Passed {
+		t.Errorf("Expected bad index to be rejected by owner. %s", testState.Error)
+	}
+}
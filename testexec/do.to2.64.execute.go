@@ -1,6 +1,8 @@
 package testexec
 
 import (
+	"fmt"
+
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
@@ -8,35 +10,26 @@ import (
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func preExecuteTo2_64(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, error) {
-	testCred, err := reqte.TestVouchers.GetVoucher(testcom.NULL_TEST)
+func preExecuteTo2_64(reqte reqtestsdeps.RequestTestInst, voucherTestId testcom.FDOTestID) (*to2.To2Requestor, error) {
+	testCred, err := reqte.TestVouchers.GetVoucher(voucherTestId)
 	if err != nil {
 		return nil, err
 	}
 
 	// Generating TO0 handler
-	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
-		SrvURL: reqte.URL,
-	}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+	to2requestor, err := newTo2Requestor(reqte, testCred.WawDeviceCredential)
+	if err != nil {
+		return nil, err
+	}
 
 	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 	if err != nil {
 		return nil, err
 	}
 
-	var ovEntries fdoshared.OVEntryArray
-	for i := 0; i < int(proveOVHdrPayload61.NumOVEntries); i++ {
-		nextEntry, _, err := to2requestor.GetOVNextEntry62(uint8(i), testcom.NULL_TEST)
-		if err != nil {
-			return nil, err
-
-		}
-
-		if nextEntry.OVEntryNum != uint8(i) {
-			return nil, err
-		}
-
-		ovEntries = append(ovEntries, nextEntry.OVEntry)
+	ovEntries, err := to2requestor.GetAllOVEntries(proveOVHdrPayload61.NumOVEntries)
+	if err != nil {
+		return nil, err
 	}
 
 	err = ovEntries.VerifyEntries(proveOVHdrPayload61.OVHeader, proveOVHdrPayload61.HMac)
@@ -44,21 +37,22 @@ func preExecuteTo2_64(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 		return nil, err
 	}
 
-	lastOvEntry := ovEntries[len(ovEntries)-1]
-	loePubKey, _ := lastOvEntry.GetOVEntryPubKey()
-
-	err = to2requestor.ProveOVHdr61PubKey.Equal(loePubKey)
+	err = to2requestor.VerifyOwnerPubKey(ovEntries)
 	if err != nil {
 		return nil, err
 	}
 
-	return &to2requestor, nil
+	return to2requestor, nil
 
 }
 
 func executeTo2_64(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
 	for _, testId := range testcom.FIDO_TEST_LIST_DOT_64 {
-		to2requestor, err := preExecuteTo2_64(reqte)
+		if !reqte.ShouldRun(testId) {
+			continue
+		}
+
+		to2requestor, err := preExecuteTo2_64(reqte, testcom.NULL_TEST)
 		if err != nil {
 			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
 				Passed: false,
@@ -79,6 +73,12 @@ func executeTo2_64(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTe
 				reqtDB.ReportTest(reqte.Uuid, testId, errTestState)
 				return
 			} else {
+				// SetupDevice65 decrypted, so the owner derived session keys with the suite the device sent
+				reqtDB.SetRunNegotiatedSuite(reqte.Uuid, fdoshared.KexCipherSuite{
+					KexSuiteName:    to2requestor.KexSuiteName,
+					CipherSuiteName: to2requestor.CipherSuiteName,
+				})
+
 				errTestState = testcom.FDOTestState{
 					Passed: true,
 				}
@@ -100,3 +100,39 @@ func executeTo2_64(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTe
 		}
 	}
 }
+
+// executeTo2_64_DeviceCerts runs ProveDevice64 with vouchers of devices with expired, or not yet valid, certificates. DO must reject them
+func executeTo2_64_DeviceCerts(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
+	for _, testId := range testcom.FIDO_TEST_LIST_DOT_64_DEVICE_CERT {
+		if !reqte.ShouldRun(testId) {
+			continue
+		}
+
+		// DO test instances created before these tests have no such vouchers
+		if _, ok := reqte.TestVouchers[testId]; !ok {
+			reqtDB.AddRunNote(reqte.Uuid, fmt.Sprintf("%s skipped. Test instance has no device certificate vouchers, create it again to regenerate vouchers", testId))
+			continue
+		}
+
+		to2requestor, err := preExecuteTo2_64(reqte, testId)
+		if err != nil {
+			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
+				Passed: false,
+				Error:  "Error running TO2 ProveDevice64 device certificate test. Pre setup failed. " + err.Error(),
+			})
+			continue
+		}
+
+		_, rvtTestState, err := to2requestor.ProveDevice64(testId)
+		if rvtTestState == nil && err != nil {
+			errTestState := testcom.FDOTestState{
+				Passed: false,
+				Error:  err.Error(),
+			}
+
+			rvtTestState = &errTestState
+		}
+
+		reqtDB.ReportTest(reqte.Uuid, testId, *rvtTestState)
+	}
+}
@@ -0,0 +1,55 @@
+package testexec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+func TestExecuteTo2_64_DeviceCerts_Skipped(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := testdbs.NewRequestTestDB(db)
+
+	// Owner must never be contacted
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s", r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer owner.Close()
+
+	// Test instances created before the tests have no device certificate vouchers
+	reqte := reqtestsdeps.NewRequestTestInst(owner.URL, fdoshared.To2)
+	err = reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatalf("Failed to save test instance. %s", err.Error())
+	}
+	reqtDB.StartNewRun(reqte.Uuid)
+
+	executeTo2_64_DeviceCerts(reqte, reqtDB)
+
+	savedReqte, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	for _, testId := range testcom.FIDO_TEST_LIST_DOT_64_DEVICE_CERT {
+		if _, ok := savedReqte.CurrentTestRun.Tests[testId]; ok {
+			t.Errorf("Expected %s not to be reported", testId)
+		}
+	}
+
+	if len(savedReqte.CurrentTestRun.Notes) != len(testcom.FIDO_TEST_LIST_DOT_64_DEVICE_CERT) {
+		t.Errorf("Expected one note per skipped test. Got %v", savedReqte.CurrentTestRun.Notes)
+	}
+}
@@ -2,7 +2,6 @@ package testexec
 
 import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
@@ -15,28 +14,19 @@ func preExecuteTo2_66(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 	}
 
 	// Generating TO0 handler
-	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
-		SrvURL: reqte.URL,
-	}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+	to2requestor, err := newTo2Requestor(reqte, testCred.WawDeviceCredential)
+	if err != nil {
+		return nil, err
+	}
 
 	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 	if err != nil {
 		return nil, err
 	}
 
-	var ovEntries fdoshared.OVEntryArray
-	for i := 0; i < int(proveOVHdrPayload61.NumOVEntries); i++ {
-		nextEntry, _, err := to2requestor.GetOVNextEntry62(uint8(i), testcom.NULL_TEST)
-		if err != nil {
-			return nil, err
-
-		}
-
-		if nextEntry.OVEntryNum != uint8(i) {
-			return nil, err
-		}
-
-		ovEntries = append(ovEntries, nextEntry.OVEntry)
+	ovEntries, err := to2requestor.GetAllOVEntries(proveOVHdrPayload61.NumOVEntries)
+	if err != nil {
+		return nil, err
 	}
 
 	err = ovEntries.VerifyEntries(proveOVHdrPayload61.OVHeader, proveOVHdrPayload61.HMac)
@@ -44,10 +34,7 @@ func preExecuteTo2_66(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 		return nil, err
 	}
 
-	lastOvEntry := ovEntries[len(ovEntries)-1]
-	loePubKey, _ := lastOvEntry.GetOVEntryPubKey()
-
-	err = to2requestor.ProveOVHdr61PubKey.Equal(loePubKey)
+	err = to2requestor.VerifyOwnerPubKey(ovEntries)
 	if err != nil {
 		return nil, err
 	}
@@ -57,12 +44,16 @@ func preExecuteTo2_66(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 		return nil, err
 	}
 
-	return &to2requestor, nil
+	return to2requestor, nil
 
 }
 
 func executeTo2_66(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
 	for _, testId := range testcom.FIDO_TEST_LIST_DOT_66 {
+		if !reqte.ShouldRun(testId) {
+			continue
+		}
+
 		to2requestor, err := preExecuteTo2_66(reqte)
 		if err != nil {
 			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
@@ -1,6 +1,7 @@
 package testexec
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
@@ -17,28 +18,19 @@ func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 	}
 
 	// Generating TO0 handler
-	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
-		SrvURL: reqte.URL,
-	}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+	to2requestor, err := newTo2Requestor(reqte, testCred.WawDeviceCredential)
+	if err != nil {
+		return nil, err
+	}
 
 	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 	if err != nil {
 		return nil, err
 	}
 
-	var ovEntries fdoshared.OVEntryArray
-	for i := 0; i < int(proveOVHdrPayload61.NumOVEntries); i++ {
-		nextEntry, _, err := to2requestor.GetOVNextEntry62(uint8(i), testcom.NULL_TEST)
-		if err != nil {
-			return nil, err
-
-		}
-
-		if nextEntry.OVEntryNum != uint8(i) {
-			return nil, err
-		}
-
-		ovEntries = append(ovEntries, nextEntry.OVEntry)
+	ovEntries, err := to2requestor.GetAllOVEntries(proveOVHdrPayload61.NumOVEntries)
+	if err != nil {
+		return nil, err
 	}
 
 	err = ovEntries.VerifyEntries(proveOVHdrPayload61.OVHeader, proveOVHdrPayload61.HMac)
@@ -46,10 +38,7 @@ func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 		return nil, err
 	}
 
-	lastOvEntry := ovEntries[len(ovEntries)-1]
-	loePubKey, _ := lastOvEntry.GetOVEntryPubKey()
-
-	err = to2requestor.ProveOVHdr61PubKey.Equal(loePubKey)
+	err = to2requestor.VerifyOwnerPubKey(ovEntries)
 	if err != nil {
 		return nil, err
 	}
@@ -65,12 +54,65 @@ func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 		return nil, err
 	}
 
-	return &to2requestor, nil
+	return to2requestor, nil
 
 }
 
+// Emulates tiny MTU device: every devmod KV is sent in its own 68, interleaved with empty fragments.
+// Owner must acknowledge each fragment with empty OwnerServiceInfo, and then send its own service info until IsDone, within 255 messages
+func executeTo2_68_Fragmented(to2requestor *to2.To2Requestor, testId testcom.FDOTestID) testcom.FDOTestState {
+	var deviceFragments [][]fdoshared.ServiceInfoKV
+	for _, fragment := range fdoshared.FragmentServiceInfo(fdoshared.GetDeviceOSSims(), 1) {
+		deviceFragments = append(deviceFragments, []fdoshared.ServiceInfoKV{}, fragment)
+	}
+
+	for i, fragment := range deviceFragments {
+		ownerSim, _, err := to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo:       fragment,
+			IsMoreServiceInfo: true,
+		}, testcom.NULL_TEST)
+		if err != nil {
+			return testcom.NewFailTestState(testId, fmt.Sprintf("Owner failed on device fragment %d of %d. %s", i+1, len(deviceFragments), err.Error()))
+		}
+
+		if ownerSim.IsDone || ownerSim.IsMoreServiceInfo || len(ownerSim.ServiceInfo) != 0 {
+			return testcom.NewFailTestState(testId, fmt.Sprintf("Device fragment %d of %d: owner must respond with empty OwnerServiceInfo while device has more service info. Got IsDone %t, IsMoreServiceInfo %t, %d KVs", i+1, len(deviceFragments), ownerSim.IsDone, ownerSim.IsMoreServiceInfo, len(ownerSim.ServiceInfo)))
+		}
+	}
+
+	maxCounter := 255
+	for round := 0; ; round++ {
+		ownerSim, _, err := to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo:       []fdoshared.ServiceInfoKV{},
+			IsMoreServiceInfo: false,
+		}, testcom.NULL_TEST)
+		if err != nil {
+			return testcom.NewFailTestState(testId, fmt.Sprintf("Owner failed to process reassembled device service info on round %d. %s", round, err.Error()))
+		}
+
+		if ownerSim.IsDone && ownerSim.IsMoreServiceInfo {
+			return testcom.NewFailTestState(testId, "Owner set both IsDone and IsMoreServiceInfo")
+		}
+
+		if ownerSim.IsDone {
+			break
+		}
+
+		maxCounter = maxCounter - 1
+		if maxCounter <= 0 {
+			return testcom.NewFailTestState(testId, "Owner sent more than 255 SIMs")
+		}
+	}
+
+	return testcom.NewSuccessTestState(testId)
+}
+
 func executeTo2_68(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
 	for _, testId := range testcom.FIDO_TEST_LIST_DOT_68 {
+		if !reqte.ShouldRun(testId) {
+			continue
+		}
+
 		to2requestor, err := preExecuteTo2_68(reqte)
 		if err != nil {
 			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
@@ -81,6 +123,9 @@ func executeTo2_68(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTe
 		}
 
 		switch testId {
+		case testcom.FIDO_DOT_68_FRAGMENTED_SIMS:
+			reqtDB.ReportTest(reqte.Uuid, testId, executeTo2_68_Fragmented(to2requestor, testId))
+
 		case testcom.FIDO_DOT_68_POSITIVE:
 			var deviceSims []fdoshared.ServiceInfoKV = fdoshared.GetDeviceOSSims()
 
@@ -16,29 +16,25 @@ func preExecuteTo2_70(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 		return nil, err
 	}
 
+	return preExecuteTo2_70WithCredential(reqte, testCred.WawDeviceCredential)
+}
+
+// preExecuteTo2_70WithCredential runs TO2 of the device up to Done70
+func preExecuteTo2_70WithCredential(reqte reqtestsdeps.RequestTestInst, credential fdoshared.WawDeviceCredential) (*to2.To2Requestor, error) {
 	// Generating TO2 handler
-	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
-		SrvURL: reqte.URL,
-	}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+	to2requestor, err := newTo2Requestor(reqte, credential)
+	if err != nil {
+		return nil, err
+	}
 
 	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 	if err != nil {
 		return nil, err
 	}
 
-	var ovEntries fdoshared.OVEntryArray
-	for i := 0; i < int(proveOVHdrPayload61.NumOVEntries); i++ {
-		nextEntry, _, err := to2requestor.GetOVNextEntry62(uint8(i), testcom.NULL_TEST)
-		if err != nil {
-			return nil, err
-
-		}
-
-		if nextEntry.OVEntryNum != uint8(i) {
-			return nil, err
-		}
-
-		ovEntries = append(ovEntries, nextEntry.OVEntry)
+	ovEntries, err := to2requestor.GetAllOVEntries(proveOVHdrPayload61.NumOVEntries)
+	if err != nil {
+		return nil, err
 	}
 
 	err = ovEntries.VerifyEntries(proveOVHdrPayload61.OVHeader, proveOVHdrPayload61.HMac)
@@ -46,10 +42,7 @@ func preExecuteTo2_70(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 		return nil, err
 	}
 
-	lastOvEntry := ovEntries[len(ovEntries)-1]
-	loePubKey, _ := lastOvEntry.GetOVEntryPubKey()
-
-	err = to2requestor.ProveOVHdr61PubKey.Equal(loePubKey)
+	err = to2requestor.VerifyOwnerPubKey(ovEntries)
 	if err != nil {
 		return nil, err
 	}
@@ -104,11 +97,15 @@ func preExecuteTo2_70(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 		}
 	}
 
-	return &to2requestor, nil
+	return to2requestor, nil
 }
 
 func executeTo2_70(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
 	for _, testId := range testcom.FIDO_TEST_LIST_DOT_68 {
+		if !reqte.ShouldRun(testId) {
+			continue
+		}
+
 		to2requestor, err := preExecuteTo2_68(reqte)
 		if err != nil {
 			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
@@ -0,0 +1,91 @@
+package testexec
+
+import (
+	"fmt"
+	"log"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// executeTo2_70_Resale completes TO2 of a device, and then sends HelloDevice again with the same voucher. Owner must follow the run reonboard policy.
+// Runs only when the policy is given
+func executeTo2_70_Resale(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
+	if reqte.ReonboardPolicy == "" {
+		return
+	}
+
+	for _, testId := range testcom.FIDO_TEST_LIST_DOT_RESALE {
+		if !reqte.ShouldRun(testId) {
+			continue
+		}
+
+		// DO test instances created before this test have no such vouchers
+		if _, ok := reqte.TestVouchers[testId]; !ok {
+			reqtDB.AddRunNote(reqte.Uuid, fmt.Sprintf("%s skipped. Test instance has no resale vouchers, create it again to run the test", testId))
+			continue
+		}
+
+		reqtDB.ReportTest(reqte.Uuid, testId, checkTo2Resale(reqte, reqtDB, testId))
+	}
+}
+
+// checkTo2Resale onboards the device only once per test instance. Owner denying reonboarding would refuse TO2 of a device onboarded in a previous run,
+// so such devices go straight to the repeated HelloDevice
+func checkTo2Resale(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, testId testcom.FDOTestID) testcom.FDOTestState {
+	testCred, err := reqte.TestVouchers.GetVoucher(testId)
+	if err != nil {
+		return testcom.NewFailTestState(testId, "Error getting voucher for TO2 resale test. "+err.Error())
+	}
+
+	guid := testCred.WawDeviceCredential.DCGuid
+
+	onboardedAt, err := reqtDB.GetGuidOnboardedAt(reqte.Uuid, guid)
+	if err != nil {
+		return testcom.NewFailTestState(testId, "Error reading onboarded devices. "+err.Error())
+	}
+
+	if onboardedAt == 0 {
+		to2requestor, err := preExecuteTo2_70WithCredential(reqte, testCred.WawDeviceCredential)
+		if err != nil {
+			return testcom.NewFailTestState(testId, fmt.Sprintf("Error onboarding device %s. %s", guid.GetFormatted(), err.Error()))
+		}
+
+		_, _, err = to2requestor.Done70(testcom.NULL_TEST)
+		if err != nil {
+			return testcom.NewFailTestState(testId, fmt.Sprintf("Error onboarding device %s. %s", guid.GetFormatted(), err.Error()))
+		}
+
+		err = reqtDB.SetGuidOnboarded(reqte.Uuid, guid)
+		if err != nil {
+			log.Printf("%s: error recording onboarded device %s. %s", reqte.URL, guid.GetFormatted(), err.Error())
+		}
+	}
+
+	to2requestor, err := newTo2Requestor(reqte, testCred.WawDeviceCredential)
+	if err != nil {
+		return testcom.NewFailTestState(testId, "Error selecting KEX for TO2 resale test. "+err.Error())
+	}
+
+	if reqte.ReonboardPolicy == fdoshared.REONBOARD_POLICY_ALLOW {
+		_, _, err = to2requestor.HelloDevice60(testcom.NULL_TEST)
+		if err != nil {
+			return testcom.NewFailTestState(testId, fmt.Sprintf("Expected owner to onboard device %s again. %s", guid.GetFormatted(), err.Error()))
+		}
+
+		return testcom.NewSuccessTestState(testId)
+	}
+
+	_, testState, err := to2requestor.HelloDevice60(testId)
+	if testState == nil && err != nil {
+		return testcom.NewFailTestState(testId, err.Error())
+	}
+
+	if !testState.Passed {
+		testState.Error = fmt.Sprintf("Expected owner to reject HelloDevice of onboarded device %s, voucher is single use. %s", guid.GetFormatted(), testState.Error)
+	}
+
+	return *testState
+}
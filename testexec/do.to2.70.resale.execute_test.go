@@ -0,0 +1,132 @@
+package testexec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// Owner answering every message with FDO error, or with HTTP 200 and no ProveOVHdr
+func newResaleTestOwner(rejects bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rejects {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		fdoErrorBytes, _ := fdoshared.CborCust.Marshal(fdoshared.FdoError{
+			EMErrorCode: fdoshared.RESOURCE_NOT_FOUND,
+			EMPrevMsgID: fdoshared.TO2_60_HELLO_DEVICE,
+			EMErrorStr:  "Voucher was already used to onboard the device.",
+		})
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(fdoErrorBytes)
+	}))
+}
+
+func TestCheckTo2Resale(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := testdbs.NewRequestTestDB(db)
+
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	rejectingOwner := newResaleTestOwner(true)
+	defer rejectingOwner.Close()
+
+	acceptingOwner := newResaleTestOwner(false)
+	defer acceptingOwner.Close()
+
+	reqte := reqtestsdeps.NewRequestTestInst(rejectingOwner.URL, fdoshared.To2)
+	reqte.TestVouchers[testcom.FIDO_DOT_70_RESALE] = []fdoshared.DeviceCredAndVoucher{{WawDeviceCredential: *credential}}
+
+	// Not onboarded yet, so TO2 is completed first, and rejecting owner fails it
+	testState := checkTo2Resale(reqte, reqtDB, testcom.FIDO_DOT_70_RESALE)
+	if testState.Passed {
+		t.Errorf("Expected test to fail when device can not be onboarded")
+	}
+
+	err = reqtDB.SetGuidOnboarded(reqte.Uuid, credential.DCGuid)
+	if err != nil {
+		t.Fatalf("Failed to record onboarded device. %s", err.Error())
+	}
+
+	testCases := []struct {
+		name     string
+		url      string
+		policy   fdoshared.ReonboardPolicy
+		expected bool
+	}{
+		{"deny, owner rejects", rejectingOwner.URL, fdoshared.REONBOARD_POLICY_DENY, true},
+		{"deny, owner accepts", acceptingOwner.URL, fdoshared.REONBOARD_POLICY_DENY, false},
+		{"allow, owner rejects", rejectingOwner.URL, fdoshared.REONBOARD_POLICY_ALLOW, false},
+	}
+
+	for _, testCase := range testCases {
+		reqte.URL = testCase.url
+		reqte.ReonboardPolicy = testCase.policy
+
+		testState := checkTo2Resale(reqte, reqtDB, testcom.FIDO_DOT_70_RESALE)
+		if testState.Passed != testCase.expected {
+			t.Errorf("%s: Expected passed %t. Got %t %s", testCase.name, testCase.expected, testState.Passed, testState.Error)
+		}
+	}
+
+}
+
+func TestExecuteTo2_70_Resale_Skipped(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db. %s", err.Error())
+	}
+	defer db.Close()
+
+	reqtDB := testdbs.NewRequestTestDB(db)
+
+	// Owner must never be contacted
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s", r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer owner.Close()
+
+	reqte := reqtestsdeps.NewRequestTestInst(owner.URL, fdoshared.To2)
+	err = reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatalf("Failed to save test instance. %s", err.Error())
+	}
+	reqtDB.StartNewRun(reqte.Uuid)
+
+	// No policy, test is opt-in
+	executeTo2_70_Resale(reqte, reqtDB)
+
+	// Test instances created before the test have no resale vouchers
+	reqte.ReonboardPolicy = fdoshared.REONBOARD_POLICY_DENY
+	executeTo2_70_Resale(reqte, reqtDB)
+
+	savedReqte, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	if _, ok := savedReqte.CurrentTestRun.Tests[testcom.FIDO_DOT_70_RESALE]; ok {
+		t.Errorf("Expected %s not to be reported", testcom.FIDO_DOT_70_RESALE)
+	}
+
+	if len(savedReqte.CurrentTestRun.Notes) != 1 {
+		t.Errorf("Expected one note for skipped test. Got %v", savedReqte.CurrentTestRun.Notes)
+	}
+}
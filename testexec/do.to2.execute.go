@@ -5,6 +5,7 @@ import (
 	"log"
 	"sync"
 
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
@@ -49,18 +50,21 @@ func GenerateTo2Vouchers_Thread(testId testcom.FDOTestID, guids fdoshared.FdoGui
 func GenerateTo2Vouchers(guidList fdoshared.FdoGuidList, devDB *dbs.DeviceBaseDB) (map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher, error) {
 	var vouchers map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher = map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher{}
 
-	totalThreads := len(testcom.FIDO_TEST_LIST_VOUCHER) + TEST_POSITIVE_BATCHES
+	negativeTestIds := append(append([]testcom.FDOTestID{}, testcom.FIDO_TEST_LIST_VOUCHER...), testcom.FIDO_TEST_LIST_DOT_64_DEVICE_CERT...)
+	negativeTestIds = append(negativeTestIds, testcom.FIDO_TEST_LIST_DOT_RESALE...)
+
+	totalThreads := len(negativeTestIds) + TEST_POSITIVE_BATCHES
 
 	var wg sync.WaitGroup
 
 	chn := make(chan GenVouchersResult, totalThreads)
 
-	testsLen := len(testcom.FIDO_TEST_LIST_VOUCHER)
+	testsLen := len(negativeTestIds)
 	randomGuids := guidList.GetRandomSelection(testsLen*TEST_NEGATIVE_PER_TEST_VOUCHERS + TEST_POSITIVE_BATCHES*TEST_POSITIVE_BATCH_SIZE)
 
 	randomNegativeTestGuids := randomGuids[0 : testsLen*TEST_NEGATIVE_PER_TEST_VOUCHERS]
 
-	for i, testId := range testcom.FIDO_TEST_LIST_VOUCHER {
+	for i, testId := range negativeTestIds {
 		indexStart := i * TEST_NEGATIVE_PER_TEST_VOUCHERS
 		indexEnd := (i + 1) * TEST_NEGATIVE_PER_TEST_VOUCHERS
 
@@ -92,16 +96,75 @@ func GenerateTo2Vouchers(guidList fdoshared.FdoGuidList, devDB *dbs.DeviceBaseDB
 	return vouchers, nil
 }
 
+// newTo2Requestor creates requestor for the owner under test, with the run KEX and cipher suite when selected. Each message waits at most the run test timeout
+func newTo2Requestor(reqte reqtestsdeps.RequestTestInst, credential fdoshared.WawDeviceCredential) (*to2.To2Requestor, error) {
+	to2requestor, err := to2.NewTo2RequestorAutoSuite(fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	if reqte.KexCipherSuite != nil {
+		to2requestor.KexSuiteName = reqte.KexCipherSuite.KexSuiteName
+		to2requestor.CipherSuiteName = reqte.KexCipherSuite.CipherSuiteName
+	}
+
+	to2requestor.MessageTimeout = reqte.GetTestTimeout()
+	to2requestor.OVEntryProgress = func(fetched int, total int) {
+		log.Printf("%s: fetched entry %d of %d", reqte.URL, fetched, total)
+	}
+
+	return to2requestor, nil
+}
+
 func ExecuteDOTestsTo2(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
 	reqtDB.StartNewRun(reqte.Uuid)
+	if reqte.KexCipherSuite != nil {
+		reqtDB.SetRunKexCipherSuite(reqte.Uuid, *reqte.KexCipherSuite)
+	}
+	reqtDB.SetRunReplayConfig(reqte.Uuid, reqte.TestTimeout, reqte.SuiteGuids, reqte.ReplayOf)
+	if reqte.ReonboardPolicy != "" && reqte.ShouldRun(testcom.FIDO_DOT_70_RESALE) {
+		reqtDB.SetRunReonboardPolicy(reqte.Uuid, reqte.ReonboardPolicy)
+	}
 
-	executeTo2_60(reqte, reqtDB)
-	executeTo2_60_Vouchers(reqte, reqtDB)
-	executeTo2_62(reqte, reqtDB)
-	executeTo2_64(reqte, reqtDB)
-	executeTo2_66(reqte, reqtDB)
-	executeTo2_68(reqte, reqtDB)
-	executeTo2_70(reqte, reqtDB)
+	for _, stage := range to2Stages(reqte, reqtDB) {
+		stage.Run()
+	}
 
 	reqtDB.FinishRun(reqte.Uuid)
 }
+
+func to2Stages(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) []campaignStage {
+	return []campaignStage{
+		{Name: "RVInfo", Run: func() { executeRVInfo(reqte, reqtDB) }},
+		{Name: "TO2 60", Run: func() { executeTo2_60(reqte, reqtDB) }},
+		{Name: "TO2 60 vouchers", Run: func() { executeTo2_60_Vouchers(reqte, reqtDB) }},
+		{Name: "TO2 62", Run: func() { executeTo2_62(reqte, reqtDB) }},
+		{Name: "TO2 64", Run: func() { executeTo2_64(reqte, reqtDB) }},
+		{Name: "TO2 64 device certs", Run: func() { executeTo2_64_DeviceCerts(reqte, reqtDB) }},
+		{Name: "TO2 66", Run: func() { executeTo2_66(reqte, reqtDB) }},
+		{Name: "TO2 68", Run: func() { executeTo2_68(reqte, reqtDB) }},
+		{Name: "TO2 70", Run: func() { executeTo2_70(reqte, reqtDB) }},
+		{Name: "TO2 resale", Run: func() { executeTo2_70_Resale(reqte, reqtDB) }},
+	}
+}
+
+// ExecuteDOTestsTo2Matrix runs TO2 tests once for each KEX and cipher suite of fdoshared.KexCipherSuiteMatrix. Each combination is a separate test run
+func ExecuteDOTestsTo2Matrix(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
+	for _, suite := range fdoshared.KexCipherSuiteMatrix() {
+		suite := suite
+		log.Printf("%s: running TO2 tests with %s and cipher suite %d", reqte.URL, suite.KexSuiteName, suite.CipherSuiteName)
+
+		reqte.KexCipherSuite = &suite
+		ExecuteDOTestsTo2(reqte, reqtDB)
+	}
+}
+
+// ExecuteDOTestsTo2Suite runs TO2 tests using only the vouchers of a named suite, see VoucherTagDB
+func ExecuteDOTestsTo2Suite(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, suiteGuids fdoshared.FdoGuidList) {
+	reqte.TestVouchers = reqte.TestVouchers.FilterByGuids(suiteGuids)
+	reqte.SuiteGuids = suiteGuids
+
+	ExecuteDOTestsTo2(reqte, reqtDB)
+}
@@ -0,0 +1,206 @@
+package testexec
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+type FuzzOutcome string
+
+const (
+	// Owner rejected mutated message with FDO error message
+	FUZZ_OUTCOME_GRACEFUL FuzzOutcome = "graceful"
+	// Owner accepted mutated message
+	FUZZ_OUTCOME_ACCEPTED FuzzOutcome = "accepted"
+	// Owner failed without FDO error message, e.g. HTTP 500 with empty or non CBOR body
+	FUZZ_OUTCOME_NON_GRACEFUL FuzzOutcome = "nongraceful"
+	// Owner did not respond, e.g. connection reset or timeout
+	FUZZ_OUTCOME_CRASH FuzzOutcome = "crash"
+	// Mutation strategy is not applicable to the message. Message was sent unchanged
+	FUZZ_OUTCOME_SKIPPED FuzzOutcome = "skipped"
+)
+
+var FuzzableTo2Cmds []fdoshared.FdoCmd = []fdoshared.FdoCmd{
+	fdoshared.TO2_60_HELLO_DEVICE,
+	fdoshared.TO2_62_GET_OVNEXTENTRY,
+	fdoshared.TO2_64_PROVE_DEVICE,
+	fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY,
+	fdoshared.TO2_68_DEVICE_SERVICE_INFO,
+	fdoshared.TO2_70_DONE,
+}
+
+// FuzzCase is a single mutated message and owner response. Replayed by its command and mutation
+type FuzzCase struct {
+	Iteration   int                         `json:"iteration"`
+	Cmd         fdoshared.FdoCmd            `json:"cmd"`
+	Mutation    fdoshared.Conf_FuzzMutation `json:"mutation"`
+	Description string                      `json:"description"`
+	// Hex encoded mutated plaintext. Later messages differ on replay, as they include session nonces
+	Input      string      `json:"input"`
+	Outcome    FuzzOutcome `json:"outcome"`
+	HttpStatus int         `json:"httpStatus"`
+	Detail     string      `json:"detail"`
+}
+
+func (h FuzzCase) IsInteresting() bool {
+	return h.Outcome == FUZZ_OUTCOME_CRASH || h.Outcome == FUZZ_OUTCOME_NON_GRACEFUL
+}
+
+type FuzzReport struct {
+	Cmd        fdoshared.FdoCmd    `json:"cmd"`
+	Seed       int64               `json:"seed"`
+	Iterations int                 `json:"iterations"`
+	Outcomes   map[FuzzOutcome]int `json:"outcomes"`
+	// Crashes and non graceful errors
+	Interesting []FuzzCase `json:"interesting"`
+}
+
+func isFuzzableTo2Cmd(cmd fdoshared.FdoCmd) bool {
+	for _, fuzzableCmd := range FuzzableTo2Cmds {
+		if fuzzableCmd == cmd {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FuzzTo2 runs TO2 with the owner iterations times. Each time the message cmd is mutated with the next seed, and owner response is classified
+func FuzzTo2(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential, cmd fdoshared.FdoCmd, iterations int, seed int64) (*FuzzReport, error) {
+	if !isFuzzableTo2Cmd(cmd) {
+		return nil, fmt.Errorf("TO2 message %d can not be fuzzed. Device sends %v", cmd, FuzzableTo2Cmds)
+	}
+
+	report := FuzzReport{
+		Cmd:         cmd,
+		Seed:        seed,
+		Iterations:  iterations,
+		Outcomes:    map[FuzzOutcome]int{},
+		Interesting: []FuzzCase{},
+	}
+
+	for i := 0; i < iterations; i++ {
+		fuzzCase, err := RunFuzzCase(srvEntry, credential, cmd, fdoshared.Conf_NewFuzzMutation(seed+int64(i)))
+		if err != nil {
+			return &report, fmt.Errorf("iteration %d. %s", i, err.Error())
+		}
+
+		fuzzCase.Iteration = i
+		report.Outcomes[fuzzCase.Outcome]++
+
+		if fuzzCase.IsInteresting() {
+			report.Interesting = append(report.Interesting, *fuzzCase)
+		}
+	}
+
+	return &report, nil
+}
+
+// RunFuzzCase runs TO2 up to and including the mutated message. Returns error when TO2 failed before it, as the owner then fails without any mutation
+func RunFuzzCase(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential, cmd fdoshared.FdoCmd, mutation fdoshared.Conf_FuzzMutation) (*FuzzCase, error) {
+	to2requestor, err := to2.NewTo2RequestorAutoSuite(srvEntry, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	to2requestor.Fuzzer = &to2.To2Fuzzer{
+		Cmd:      cmd,
+		Mutation: mutation,
+	}
+
+	err = runTo2ForFuzzing(to2requestor, cmd)
+	if !to2requestor.Fuzzer.Sent {
+		if err == nil {
+			err = fmt.Errorf("owner did not ask for TO2 %d", cmd)
+		}
+
+		return nil, fmt.Errorf("TO2 failed before message %d. %s", cmd, err.Error())
+	}
+
+	fuzzer := to2requestor.Fuzzer
+	fuzzCase := FuzzCase{
+		Cmd:         cmd,
+		Mutation:    mutation,
+		Description: fuzzer.Description,
+		Input:       hex.EncodeToString(fuzzer.Input),
+		HttpStatus:  fuzzer.ResponseStatus,
+	}
+
+	switch {
+	case fuzzer.Input == nil:
+		fuzzCase.Outcome = FUZZ_OUTCOME_SKIPPED
+	case fuzzer.ResponseStatus == 0:
+		fuzzCase.Outcome = FUZZ_OUTCOME_CRASH
+		if fuzzer.ResponseErr != nil {
+			fuzzCase.Detail = fuzzer.ResponseErr.Error()
+		}
+	case fuzzer.ResponseStatus == http.StatusOK:
+		fuzzCase.Outcome = FUZZ_OUTCOME_ACCEPTED
+	default:
+		fdoErrInst, err := fdoshared.DecodeErrorResponse(fuzzer.ResponseBytes)
+		if err != nil {
+			fuzzCase.Outcome = FUZZ_OUTCOME_NON_GRACEFUL
+			fuzzCase.Detail = fmt.Sprintf("HTTP %d without FDO error message. %s", fuzzer.ResponseStatus, err.Error())
+		} else {
+			fuzzCase.Outcome = FUZZ_OUTCOME_GRACEFUL
+			fuzzCase.Detail = fmt.Sprintf("FDO error %d. %s", fdoErrInst.EMErrorCode, fdoErrInst.EMErrorStr)
+		}
+	}
+
+	return &fuzzCase, nil
+}
+
+// runTo2ForFuzzing runs positive TO2 and stops after the first message cmd. Owner responses are not verified, as only owner robustness is tested
+func runTo2ForFuzzing(to2requestor *to2.To2Requestor, cmd fdoshared.FdoCmd) error {
+	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
+	if err != nil || cmd == fdoshared.TO2_60_HELLO_DEVICE {
+		return err
+	}
+
+	_, err = to2requestor.GetAllOVEntries(proveOVHdrPayload61.NumOVEntries)
+	if err != nil || cmd == fdoshared.TO2_62_GET_OVNEXTENTRY {
+		return err
+	}
+
+	_, _, err = to2requestor.ProveDevice64(testcom.NULL_TEST)
+	if err != nil || cmd == fdoshared.TO2_64_PROVE_DEVICE {
+		return err
+	}
+
+	_, _, err = to2requestor.DeviceServiceInfoReady66(testcom.NULL_TEST)
+	if err != nil || cmd == fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY {
+		return err
+	}
+
+	deviceSims := fdoshared.GetDeviceOSSims()
+	for i, deviceSim := range deviceSims {
+		_, _, err := to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo:       []fdoshared.ServiceInfoKV{deviceSim},
+			IsMoreServiceInfo: i+1 <= len(deviceSims),
+		}, testcom.NULL_TEST)
+		if err != nil || cmd == fdoshared.TO2_68_DEVICE_SERVICE_INFO {
+			return err
+		}
+	}
+
+	for maxCounter := 255; maxCounter > 0; maxCounter-- {
+		ownerSim, _, err := to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			IsMoreServiceInfo: false,
+		}, testcom.NULL_TEST)
+		if err != nil {
+			return err
+		}
+
+		if ownerSim.IsDone {
+			break
+		}
+	}
+
+	_, _, err = to2requestor.Done70(testcom.NULL_TEST)
+	return err
+}
@@ -0,0 +1,175 @@
+package testexec
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to1"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// RVInfoReport is the result of following credential RVInfo, the way a real device would
+type RVInfoReport struct {
+	// Owner address for TO2, from To1d or RVBypass directive
+	OwnerUrl string
+	// RV server that returned To1d. Empty with RVBypass
+	RvUrl      string
+	Logs       []string
+	Mismatches []string
+}
+
+// FollowCredentialRVInfo contacts device addresses of the voucher RVInfo in order. It runs TO1 with RV servers until one returns To1d,
+// or stops at the first RVBypass directive, and returns the owner address device would run TO2 with.
+// expectedRvUrl and expectedDoUrl are the addresses the test is set up with. Differences from RVInfo and To1d are reported as mismatches. Empty values are not checked
+func FollowCredentialRVInfo(credential fdoshared.WawDeviceCredential, rvInfo fdoshared.RendezvousInfo, expectedRvUrl string, expectedDoUrl string) (*RVInfoReport, error) {
+	var report RVInfoReport
+
+	mappedRvInfo, err := fdoshared.GetMappedRVInfo(rvInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error getting mapped RVInfo. %s", err.Error())
+	}
+
+	devMappedRvInfo := mappedRvInfo.GetDevOnly()
+	if len(devMappedRvInfo) == 0 {
+		return nil, errors.New("RVInfo has no device directives")
+	}
+
+	if expectedRvUrl != "" {
+		rvUrls := []string{}
+		for _, directive := range devMappedRvInfo {
+			if !directive.RVBypass {
+				rvUrls = append(rvUrls, directive.GetDeviceUrls()...)
+			}
+		}
+
+		if !sameFdoEndpointInList(expectedRvUrl, rvUrls) {
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf("RV %s is not in credential RVInfo. Device contacts %s", expectedRvUrl, strings.Join(rvUrls, ", ")))
+		}
+	}
+
+	for directiveIndex, directive := range devMappedRvInfo {
+		for _, urlOption := range directive.GetDeviceUrls() {
+			if directive.RVBypass {
+				report.Logs = append(report.Logs, fmt.Sprintf("(%d)[%s]. RVBypass, skipping TO1", directiveIndex, urlOption))
+				report.OwnerUrl = urlOption
+				break
+			}
+
+			ownerUrl, err := runTo1ForOwnerUrl(credential, urlOption)
+			if err != nil {
+				report.Logs = append(report.Logs, fmt.Sprintf("(%d)[%s]. %s", directiveIndex, urlOption, err.Error()))
+				continue
+			}
+
+			report.Logs = append(report.Logs, fmt.Sprintf("(%d)[%s]. To1d owner address %s", directiveIndex, urlOption, ownerUrl))
+			report.RvUrl = urlOption
+			report.OwnerUrl = ownerUrl
+			break
+		}
+
+		if report.OwnerUrl != "" {
+			break
+		}
+	}
+
+	if report.OwnerUrl == "" {
+		return &report, fmt.Errorf("device could not locate owner with credential RVInfo. %s", strings.Join(report.Logs, "; "))
+	}
+
+	if expectedDoUrl != "" && !fdoshared.SameFdoEndpoint(expectedDoUrl, report.OwnerUrl) {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("DO %s does not match owner address %s, device runs TO2 with", expectedDoUrl, report.OwnerUrl))
+	}
+
+	return &report, nil
+}
+
+func runTo1ForOwnerUrl(credential fdoshared.WawDeviceCredential, rvUrl string) (string, error) {
+	err := fdoshared.Outbound.CheckURL(rvUrl)
+	if err != nil {
+		return "", fmt.Errorf("RV URL not allowed. %s", err.Error())
+	}
+
+	to1inst := to1.NewTo1Requestor(fdoshared.SRVEntry{
+		SrvURL: rvUrl,
+	}, credential)
+
+	helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
+	if err != nil {
+		return "", fmt.Errorf("error running HelloRV30. %s", err.Error())
+	}
+
+	to1d, _, err := to1inst.ProveToRV32(*helloRvAck31, testcom.NULL_TEST)
+	if err != nil {
+		return "", fmt.Errorf("error running ProveToRV32. %s", err.Error())
+	}
+
+	var to1dPayload fdoshared.To1dBlobPayload
+	err = fdoshared.CborCust.Unmarshal(to1d.Payload, &to1dPayload)
+	if err != nil {
+		return "", fmt.Errorf("error decoding To1d payload. %s", err.Error())
+	}
+
+	for _, to1dRv := range to1dPayload.To1dRV {
+		ownerUrl, err := to1dRv.ToUrl()
+		if err == nil {
+			return ownerUrl, nil
+		}
+	}
+
+	return "", errors.New("To1d has no HTTP(S) owner address")
+}
+
+// executeRVInfo follows RVInfo of the selected voucher, and checks that the device would run TO2 with the DO under test
+func executeRVInfo(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
+	if reqte.RVInfoGuid == nil || !reqte.ShouldRun(testcom.FIDO_DOT_RVINFO_OWNER_ADDRESS) {
+		return
+	}
+
+	reqtDB.ReportTest(reqte.Uuid, testcom.FIDO_DOT_RVINFO_OWNER_ADDRESS, checkRVInfo(reqte, *reqte.RVInfoGuid))
+}
+
+func checkRVInfo(reqte reqtestsdeps.RequestTestInst, guid fdoshared.FdoGuid) testcom.FDOTestState {
+	testId := testcom.FIDO_DOT_RVINFO_OWNER_ADDRESS
+
+	testCred, err := reqte.TestVouchers.GetVoucherByGuid(guid)
+	if err != nil {
+		return testcom.NewFailTestState(testId, "Error getting voucher for RVInfo test. "+err.Error())
+	}
+
+	ovHeader, err := testCred.VoucherDBEntry.Voucher.GetOVHeader()
+	if err != nil {
+		return testcom.NewFailTestState(testId, "Error decoding voucher header. "+err.Error())
+	}
+
+	report, err := FollowCredentialRVInfo(testCred.WawDeviceCredential, ovHeader.OVRvInfo, "", reqte.URL)
+	if report != nil {
+		for _, logLine := range report.Logs {
+			log.Printf("%s: RVInfo %s", reqte.URL, logLine)
+		}
+	}
+
+	if err != nil {
+		return testcom.NewFailTestState(testId, err.Error())
+	}
+
+	if len(report.Mismatches) != 0 {
+		return testcom.NewFailTestState(testId, strings.Join(report.Mismatches, ". "))
+	}
+
+	return testcom.NewSuccessTestState(testId)
+}
+
+func sameFdoEndpointInList(url string, urls []string) bool {
+	for _, listUrl := range urls {
+		if fdoshared.SameFdoEndpoint(url, listUrl) {
+			return true
+		}
+	}
+
+	return false
+}
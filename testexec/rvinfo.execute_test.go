@@ -0,0 +1,116 @@
+package testexec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// RV answering TO1 with RVRedirect33, that points device to ownerUrl
+func newRedirectingRV(t *testing.T, ownerUrl string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var respBytes []byte
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/30"):
+			respBytes, _ = fdoshared.CborCust.Marshal(fdoshared.HelloRVAck31{
+				NonceTO1Proof: fdoshared.NewFdoNonce(),
+				EBSigInfo:     fdoshared.SigInfo{SgType: fdoshared.StSECP256R1},
+			})
+			w.Header().Set("Authorization", "Bearer rvsession")
+		case strings.HasSuffix(r.URL.Path, "/32"):
+			ownerAddr, err := fdoshared.UrlToTOAddrEntry(ownerUrl)
+			if err != nil {
+				t.Errorf("Failed to encode owner address. %s", err.Error())
+			}
+
+			payloadBytes, _ := fdoshared.CborCust.Marshal(fdoshared.To1dBlobPayload{
+				To1dRV: []fdoshared.RVTO2AddrEntry{*ownerAddr},
+			})
+			respBytes, _ = fdoshared.CborCust.Marshal(fdoshared.CoseSignature{
+				Payload: payloadBytes,
+			})
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", fdoshared.CONTENT_TYPE_CBOR)
+		w.WriteHeader(http.StatusOK)
+		w.Write(respBytes)
+	}))
+}
+
+func TestCheckRVInfo_Redirect(t *testing.T) {
+	const doUrl = "http://127.0.0.1:8043"
+
+	rvServer := newRedirectingRV(t, doUrl)
+	defer rvServer.Close()
+
+	rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{rvServer.URL})
+	if err != nil {
+		t.Fatalf("Failed to generate RVInfo. %s", err.Error())
+	}
+
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	credAndVoucher, err := fdodeviceimplementation.NewVirtualDeviceAndVoucher(*credential, fdoshared.StSECP256R1, rvInfo, testcom.NULL_TEST)
+	if err != nil {
+		t.Fatalf("Failed to generate voucher. %s", err.Error())
+	}
+
+	guid := credAndVoucher.WawDeviceCredential.DCGuid
+
+	report, err := FollowCredentialRVInfo(credAndVoucher.WawDeviceCredential, rvInfo, rvServer.URL, doUrl)
+	if err != nil {
+		t.Fatalf("Expected device to locate owner. %s", err.Error())
+	}
+
+	if !fdoshared.SameFdoEndpoint(report.RvUrl, rvServer.URL) || !fdoshared.SameFdoEndpoint(report.OwnerUrl, doUrl) || len(report.Mismatches) != 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	reqte := reqtestsdeps.NewRequestTestInst(doUrl, fdoshared.To2)
+	reqte.TestVouchers[testcom.NULL_TEST] = []fdoshared.DeviceCredAndVoucher{*credAndVoucher}
+
+	if testState := checkRVInfo(reqte, guid); !testState.Passed {
+		t.Errorf("Expected test to pass when RV redirects to DO under test. %s", testState.Error)
+	}
+
+	reqte.URL = "http://127.0.0.1:9043"
+	if testState := checkRVInfo(reqte, guid); testState.Passed {
+		t.Errorf("Expected test to fail when RV redirects to another owner")
+	}
+
+	if testState := checkRVInfo(reqte, fdoshared.NewFdoGuid()); testState.Passed {
+		t.Errorf("Expected test to fail for unknown voucher")
+	}
+}
+
+func TestFollowCredentialRVInfo_Unreachable(t *testing.T) {
+	rvServer := newRedirectingRV(t, "http://127.0.0.1:8043")
+	rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{rvServer.URL})
+	if err != nil {
+		t.Fatalf("Failed to generate RVInfo. %s", err.Error())
+	}
+	rvServer.Close()
+
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to generate device credential. %s", err.Error())
+	}
+
+	_, err = FollowCredentialRVInfo(*credential, rvInfo, "", "")
+	if err == nil {
+		t.Errorf("Expected error when no RV can be reached")
+	}
+}
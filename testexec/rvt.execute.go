@@ -0,0 +1,28 @@
+package testexec
+
+import (
+	"context"
+	"sync"
+
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+// Runs TO0 and TO1 against external RV in parallel. Each protocol reports to its own test instance
+func ExecuteRVTests(reqteTo0 reqtestsdeps.RequestTestInst, reqteTo1 reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ExecuteRVTestsTo0(reqteTo0, reqtDB, devDB, ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		ExecuteRVTestsTo1(reqteTo1, reqtDB, devDB, ctx)
+	}()
+
+	wg.Wait()
+}
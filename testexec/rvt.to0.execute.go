@@ -15,6 +15,22 @@ import (
 func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
 	reqtDB.StartNewRun(reqte.Uuid)
 
+	for _, stage := range to0Stages(reqte, reqtDB, devDB, ctx) {
+		stage.Run()
+	}
+
+	reqtDB.FinishRun(reqte.Uuid)
+}
+
+func to0Stages(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) []campaignStage {
+	return []campaignStage{
+		{Name: "TO0 20", Run: func() { executeTo0_20(reqte, reqtDB, devDB, ctx) }},
+		{Name: "TO0 22", Run: func() { executeTo0_22(reqte, reqtDB, devDB, ctx) }},
+		{Name: "TO0 22 vouchers", Run: func() { executeTo0_22_Vouchers(reqte, reqtDB, devDB, ctx) }},
+	}
+}
+
+func executeTo0_20(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
 	for _, rv20test := range testcom.FIDO_TEST_LIST_RVT_20 {
 		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
 		testCredV, err := devDB.GetVANDV(randomGuid, rv20test)
@@ -63,7 +79,9 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 			reqtDB.ReportTest(reqte.Uuid, rv20test, *testState)
 		}
 	}
+}
 
+func executeTo0_22(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
 	for _, rv22test := range testcom.FIDO_TEST_LIST_RVT_22 {
 		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
 		testCredV, err := devDB.GetVANDV(randomGuid, rv22test)
@@ -124,7 +142,9 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 			reqtDB.ReportTest(reqte.Uuid, rv22test, *rvtTestState)
 		}
 	}
+}
 
+func executeTo0_22_Vouchers(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
 	for _, rv22VoucherTest := range testcom.FIDO_TEST_LIST_VOUCHER {
 		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
 		testCredV, err := devDB.GetVANDV(randomGuid, rv22VoucherTest)
@@ -165,6 +185,4 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 
 		reqtDB.ReportTest(reqte.Uuid, rv22VoucherTest, *rvtTestState)
 	}
-
-	reqtDB.FinishRun(reqte.Uuid)
 }
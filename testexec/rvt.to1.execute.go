@@ -61,6 +61,15 @@ func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 	}, testCredV.WawDeviceCredential)
 
 	// Starting tests
+	if executeTo1_30(reqte, reqtDB, &to1inst) {
+		executeTo1_32(reqte, reqtDB, &to1inst)
+	}
+
+	reqtDB.FinishRun(reqte.Uuid)
+}
+
+// Returns false if positive test failed, and further testing makes no sense
+func executeTo1_30(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, to1inst *to1.To1Requestor) bool {
 	for _, rv30test := range testcom.FIDO_TEST_LIST_DEVT_30 {
 		switch rv30test {
 
@@ -74,7 +83,7 @@ func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 					Error:  err.Error(),
 				}
 				reqtDB.ReportTest(reqte.Uuid, rv30test, errTestState)
-				return
+				return false
 			} else {
 				errTestState = testcom.FDOTestState{
 					Passed: true,
@@ -97,10 +106,14 @@ func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 		}
 	}
 
+	return true
+}
+
+func executeTo1_32(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, to1inst *to1.To1Requestor) {
 	for _, rv32test := range testcom.FIDO_TEST_LIST_DEVT_32 {
 		helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
 		if err != nil {
-			errTestState = testcom.FDOTestState{
+			errTestState := testcom.FDOTestState{
 				Passed: false,
 				Error:  "Error running test. Hello RV30 failed!" + err.Error(),
 			}
@@ -142,6 +155,4 @@ func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 			reqtDB.ReportTest(reqte.Uuid, rv32test, *rvtTestState)
 		}
 	}
-
-	reqtDB.FinishRun(reqte.Uuid)
 }